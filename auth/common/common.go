@@ -18,8 +18,60 @@ type Configuration struct {
 	LogMaxBackups int    `json:"log_max_backups"`
 	LogMaxAge     int    `json:"log_max_age"`
 
-	AccessSecret  string `json:"access_secret"`
 	RefreshSecret string `json:"refresh_secret"`
+	// InternalSecret authenticates the calls of the other services to the
+	// internal routes, the routes are disabled when it's empty
+	InternalSecret string `json:"internal_secret"`
+	// KeyRotationHours defines how often the access token signing key is rotated
+	KeyRotationHours int `json:"key_rotation_hours"`
+
+	// Issuer defines the public URL of the authentication service used as the
+	// issuer of the ID tokens and the base of the OAuth endpoints
+	Issuer string `json:"issuer"`
+	// TokenAudience defines the services accepting the access tokens, each of
+	// them checks if it's in the aud claim
+	TokenAudience []string `json:"token_audience"`
+	// OAuthAuthorizeURL defines the consent page of the web frontend, which
+	// receives the authorization requests of the OAuth clients
+	OAuthAuthorizeURL string `json:"oauth_authorize_url"`
+	// OAuthDeviceURL defines the page of the web frontend where the users
+	// enter the codes displayed by their devices
+	OAuthDeviceURL string `json:"oauth_device_url"`
+
+	// CookieMode allows the browsers to receive the refresh token in the
+	// HttpOnly cookie instead of the response body, see handler/cookie.go
+	CookieMode bool `json:"cookie_mode"`
+	// CookieSameSite defines the SameSite attribute of the cookies: strict (default), lax or none
+	CookieSameSite string `json:"cookie_same_site"`
+	CookieDomain   string `json:"cookie_domain"`
+	// CORSAllowedOrigins defines the origins of the web frontend allowed to call the service with the credentials
+	CORSAllowedOrigins []string `json:"cors_allowed_origins"`
+
+	// CredentialBackend defines where Login checks the passwords: user (default) or ldap
+	CredentialBackend string     `json:"credential_backend"`
+	LDAP              LDAPConfig `json:"ldap"`
+
+	// OIDCProviders defines the external identity providers for the single
+	// sign-on, keyed by the name used in the URLs
+	OIDCProviders map[string]OIDCProvider `json:"oidc_providers"`
+
+	// WebAuthnRPID defines the domain the passkeys are bound to, WebAuthnOrigins
+	// the origins of the web frontend allowed to use them
+	WebAuthnRPID    string   `json:"webauthn_rp_id"`
+	WebAuthnRPName  string   `json:"webauthn_rp_name"`
+	WebAuthnOrigins []string `json:"webauthn_origins"`
+
+	// TrustedProxies defines the addresses or CIDR ranges of the reverse proxies
+	// e.g. traefik, the client address is read from X-Forwarded-For and X-Real-IP
+	// only if the request comes from one of them
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	// login throttling, zero values fall back to the defaults
+	LoginMaxAttempts       int `json:"login_max_attempts"`
+	LoginMaxIPAttempts     int `json:"login_max_ip_attempts"`
+	LoginWindowSeconds     int `json:"login_window_seconds"`
+	LoginLockoutSeconds    int `json:"login_lockout_seconds"`
+	LoginMaxLockoutSeconds int `json:"login_max_lockout_seconds"`
 
 	RedisHost     string `json:"redis_host"`
 	RedisPort     string `json:"redis_port"`
@@ -27,6 +79,47 @@ type Configuration struct {
 	RedisDB       int    `json:"redis_db"`
 }
 
+// LDAPConfig stores the settings of the LDAP credential backend
+type LDAPConfig struct {
+	// URL of the directory e.g. ldap://ldap.example.com:389 or ldaps://ldap.example.com:636
+	URL      string `json:"url"`
+	StartTLS bool   `json:"start_tls"`
+	// BindDN and BindPassword define the service account used to search for
+	// the users, the anonymous bind is used when they're empty
+	BindDN       string `json:"bind_dn"`
+	BindPassword string `json:"bind_password"`
+	BaseDN       string `json:"base_dn"`
+	// UserFilter is the search filter with %s replaced by the escaped username
+	UserFilter string `json:"user_filter"`
+	// GroupAttribute defines the attribute of the user entry listing its groups
+	GroupAttribute string `json:"group_attribute"`
+	// AdminGroups defines the DNs of the groups whose members are admins
+	AdminGroups []string `json:"admin_groups"`
+}
+
+// OIDCProvider stores the settings of the external OpenID Connect identity provider
+type OIDCProvider struct {
+	// DisplayName is shown on the "Sign in with ..." button
+	DisplayName string `json:"display_name"`
+	// Issuer is the URL of the provider, its discovery document is fetched
+	// from /.well-known/openid-configuration
+	Issuer       string `json:"issuer"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// RedirectURL is the callback page of the web frontend registered in the provider
+	RedirectURL string   `json:"redirect_url"`
+	Scopes      []string `json:"scopes"`
+	// UsernameClaim defines the claim of the ID token used as the username (default preferred_username)
+	UsernameClaim string `json:"username_claim"`
+	// GroupsClaim and AdminGroups map the groups of the user to the admin
+	// role, the role isn't changed when the groups claim isn't configured
+	GroupsClaim string   `json:"groups_claim"`
+	AdminGroups []string `json:"admin_groups"`
+	// AutoCreate creates the local user on the first login, otherwise only
+	// the existing users can sign in
+	AutoCreate bool `json:"auto_create"`
+}
+
 // Config shares the global configuration
 var (
 	Config *Configuration
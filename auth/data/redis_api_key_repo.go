@@ -0,0 +1,116 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/0x113/x-media/auth/databases"
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	apiKeyKeyPrefix     = "api_key:"
+	apiKeyHashKeyPrefix = "api_key_hash:"
+	apiKeysKeyPrefix    = "api_keys:"
+)
+
+// SaveAPIKey stores the API key, indexed by its hash, until it expires and
+// adds it to the keys of the user
+func (r *authRepository) SaveAPIKey(key *models.APIKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	var ttl time.Duration // keys without the expiration time are kept until revoked
+	if key.ExpiresAt != nil {
+		ttl = key.ExpiresAt.Sub(time.Now())
+	}
+	pipe := databases.Database.DB.TxPipeline()
+	pipe.Set(ctx, apiKeyKeyPrefix+key.ID, value, ttl)
+	pipe.Set(ctx, apiKeyHashKeyPrefix+key.Hash, key.ID, ttl)
+	pipe.SAdd(ctx, apiKeysKeyPrefix+key.Username, key.ID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetAPIKey returns the API key with provided id
+func (r *authRepository) GetAPIKey(id string) (*models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value, err := databases.Database.DB.Get(ctx, apiKeyKeyPrefix+id).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	key := new(models.APIKey)
+	if err := json.Unmarshal(value, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// GetAPIKeyByHash returns the API key with provided hash
+func (r *authRepository) GetAPIKeyByHash(hash string) (*models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id, err := databases.Database.DB.Get(ctx, apiKeyHashKeyPrefix+hash).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetAPIKey(id)
+}
+
+// GetAPIKeys returns all of the API keys of the user, expired keys are
+// removed from the keys of the user
+func (r *authRepository) GetAPIKeys(username string) ([]*models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	keysKey := apiKeysKeyPrefix + username
+	ids, err := databases.Database.DB.SMembers(ctx, keysKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []*models.APIKey{}
+	for _, id := range ids {
+		value, err := databases.Database.DB.Get(ctx, apiKeyKeyPrefix+id).Bytes()
+		if err == redis.Nil {
+			databases.Database.DB.SRem(ctx, keysKey, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		key := new(models.APIKey)
+		if err := json.Unmarshal(value, key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// DeleteAPIKey removes the API key from the Redis database and from the keys of the user
+func (r *authRepository) DeleteAPIKey(key *models.APIKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipe := databases.Database.DB.TxPipeline()
+	pipe.Del(ctx, apiKeyKeyPrefix+key.ID, apiKeyHashKeyPrefix+key.Hash)
+	pipe.SRem(ctx, apiKeysKeyPrefix+key.Username, key.ID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,75 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/0x113/x-media/auth/databases"
+	"github.com/0x113/x-media/auth/models"
+)
+
+const (
+	signingKeysKey     = "signing_keys"
+	keyRotationLockKey = "signing_keys_rotation_lock"
+)
+
+// keyRepository manages the signing keys CRUD
+type keyRepository struct{}
+
+// NewRedisKeyRepository returns a new instance of the signing keys repository
+func NewRedisKeyRepository() KeyRepository {
+	return &keyRepository{}
+}
+
+// SaveKey stores the signing key in the Redis hash
+func (r *keyRepository) SaveKey(key *models.SigningKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	return databases.Database.DB.HSet(ctx, signingKeysKey, key.Kid, value).Err()
+}
+
+// GetKeys returns all of the stored signing keys
+func (r *keyRepository) GetKeys() ([]*models.SigningKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	values, err := databases.Database.DB.HGetAll(ctx, signingKeysKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []*models.SigningKey{}
+	for _, value := range values {
+		key := new(models.SigningKey)
+		if err := json.Unmarshal([]byte(value), key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// DeleteKey removes the signing key from the Redis hash
+func (r *keyRepository) DeleteKey(kid string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return databases.Database.DB.HDel(ctx, signingKeysKey, kid).Err()
+}
+
+// LockRotation claims the rotation of the signing key for the ttl, false is
+// returned if another instance is already rotating the key
+func (r *keyRepository) LockRotation(ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return databases.Database.DB.SetNX(ctx, keyRotationLockKey, 1, ttl).Result()
+}
@@ -0,0 +1,102 @@
+package data
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/0x113/x-media/auth/databases"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	failuresKeyPrefix     = "login_failures:"
+	lockoutKeyPrefix      = "login_lockout:"
+	lockoutLevelKeyPrefix = "login_lockout_level:"
+)
+
+// loginAttemptRepository manages the failed login attempts in the Redis database
+type loginAttemptRepository struct{}
+
+// NewRedisLoginAttemptRepository returns a new instance of the login attempts repository
+func NewRedisLoginAttemptRepository() LoginAttemptRepository {
+	return &loginAttemptRepository{}
+}
+
+// AddFailure adds the failed attempt to the sliding window stored in the sorted
+// set and returns the number of failures within the window
+func (r *loginAttemptRepository) AddFailure(key string, at time.Time, window time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	failuresKey := failuresKeyPrefix + key
+	nanos := at.UnixNano()
+	pipe := databases.Database.DB.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, failuresKey, "-inf", strconv.FormatInt(at.Add(-window).UnixNano(), 10))
+	pipe.ZAdd(ctx, failuresKey, &redis.Z{Score: float64(nanos), Member: nanos})
+	count := pipe.ZCard(ctx, failuresKey)
+	pipe.Expire(ctx, failuresKey, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return int(count.Val()), nil
+}
+
+// ClearFailures removes all of the failed attempts
+func (r *loginAttemptRepository) ClearFailures(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return databases.Database.DB.Del(ctx, failuresKeyPrefix+key).Err()
+}
+
+// IncrLockoutLevel increments the number of the consecutive lockouts, the
+// counter is reset after provided ttl
+func (r *loginAttemptRepository) IncrLockoutLevel(key string, ttl time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	levelKey := lockoutLevelKeyPrefix + key
+	pipe := databases.Database.DB.TxPipeline()
+	level := pipe.Incr(ctx, levelKey)
+	pipe.Expire(ctx, levelKey, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return int(level.Val()), nil
+}
+
+// Lock blocks the login attempts for provided duration
+func (r *loginAttemptRepository) Lock(key string, duration time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return databases.Database.DB.Set(ctx, lockoutKeyPrefix+key, 1, duration).Err()
+}
+
+// GetLockout returns for how long the login attempts are blocked, zero if they aren't
+func (r *loginAttemptRepository) GetLockout(key string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ttl, err := databases.Database.DB.PTTL(ctx, lockoutKeyPrefix+key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 { // key doesn't exist
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+// Unlock removes the lockout, its level and the failed attempts
+func (r *loginAttemptRepository) Unlock(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return databases.Database.DB.Del(ctx, failuresKeyPrefix+key, lockoutKeyPrefix+key, lockoutLevelKeyPrefix+key).Err()
+}
@@ -0,0 +1,58 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/0x113/x-media/auth/databases"
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const mfaChallengeKeyPrefix = "mfa_challenge:"
+
+// SaveMFAChallenge stores the challenge under the hash of the MFA token until it expires
+func (r *authRepository) SaveMFAChallenge(hash string, challenge *models.MFAChallenge) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the negative TTL would keep the key forever
+	ttl := time.Until(challenge.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	value, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+
+	return databases.Database.DB.Set(ctx, mfaChallengeKeyPrefix+hash, value, ttl).Err()
+}
+
+// TakeMFAChallenge returns the challenge and removes it, so the concurrent
+// requests can't try the codes with the same token
+func (r *authRepository) TakeMFAChallenge(hash string) (*models.MFAChallenge, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	key := mfaChallengeKeyPrefix + hash
+	pipe := databases.Database.DB.TxPipeline()
+	get := pipe.Get(ctx, key)
+	del := pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	// the challenge has been already taken by the concurrent request
+	if del.Val() == 0 {
+		return nil, redis.Nil
+	}
+
+	challenge := new(models.MFAChallenge)
+	if err := json.Unmarshal([]byte(get.Val()), challenge); err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
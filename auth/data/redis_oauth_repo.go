@@ -0,0 +1,225 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/0x113/x-media/auth/databases"
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	oauthClientsKey       = "oauth_clients"
+	oauthCodeKeyPrefix    = "oauth_code:"
+	oauthConsentKeyPrefix = "oauth_consents:"
+	deviceCodeKeyPrefix   = "device_code:"
+	userCodeKeyPrefix     = "device_user_code:"
+	devicePollKeyPrefix   = "device_poll:"
+)
+
+// oauthRepository manages the OAuth CRUD
+type oauthRepository struct{}
+
+// NewRedisOAuthRepository returns a new instance of the OAuth repository
+func NewRedisOAuthRepository() OAuthRepository {
+	return &oauthRepository{}
+}
+
+// SaveClient stores the client in the Redis hash
+func (r *oauthRepository) SaveClient(client *models.OAuthClient) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value, err := json.Marshal(client)
+	if err != nil {
+		return err
+	}
+
+	return databases.Database.DB.HSet(ctx, oauthClientsKey, client.ClientID, value).Err()
+}
+
+// GetClient returns the client with provided id
+func (r *oauthRepository) GetClient(id string) (*models.OAuthClient, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value, err := databases.Database.DB.HGet(ctx, oauthClientsKey, id).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	client := new(models.OAuthClient)
+	if err := json.Unmarshal(value, client); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// GetClients returns all of the registered clients
+func (r *oauthRepository) GetClients() ([]*models.OAuthClient, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	values, err := databases.Database.DB.HGetAll(ctx, oauthClientsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	clients := []*models.OAuthClient{}
+	for _, value := range values {
+		client := new(models.OAuthClient)
+		if err := json.Unmarshal([]byte(value), client); err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+// DeleteClient removes the client from the Redis hash
+func (r *oauthRepository) DeleteClient(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	deleted, err := databases.Database.DB.HDel(ctx, oauthClientsKey, id).Result()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return fmt.Errorf("No rows has been deleted")
+	}
+
+	return nil
+}
+
+// SaveCode stores the authorization code under its hash until it expires
+func (r *oauthRepository) SaveCode(hash string, code *models.AuthorizationCode, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value, err := json.Marshal(code)
+	if err != nil {
+		return err
+	}
+
+	return databases.Database.DB.Set(ctx, oauthCodeKeyPrefix+hash, value, ttl).Err()
+}
+
+// TakeCode returns the authorization code and removes it, so it can be used only once
+func (r *oauthRepository) TakeCode(hash string) (*models.AuthorizationCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	key := oauthCodeKeyPrefix + hash
+	pipe := databases.Database.DB.TxPipeline()
+	get := pipe.Get(ctx, key)
+	del := pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	// the code has been already taken by the concurrent request
+	if del.Val() == 0 {
+		return nil, redis.Nil
+	}
+
+	code := new(models.AuthorizationCode)
+	if err := json.Unmarshal([]byte(get.Val()), code); err != nil {
+		return nil, err
+	}
+
+	return code, nil
+}
+
+// SaveConsent stores the scopes granted by the user to the client
+func (r *oauthRepository) SaveConsent(username, clientID, scope string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return databases.Database.DB.HSet(ctx, oauthConsentKeyPrefix+username, clientID, scope).Err()
+}
+
+// GetConsent returns the scopes granted by the user to the client
+func (r *oauthRepository) GetConsent(username, clientID string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return databases.Database.DB.HGet(ctx, oauthConsentKeyPrefix+username, clientID).Result()
+}
+
+// SaveDeviceAuthorization stores the device authorization under the hash of
+// the device code and its user code until it expires
+func (r *oauthRepository) SaveDeviceAuthorization(auth *models.DeviceAuthorization) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value, err := json.Marshal(auth)
+	if err != nil {
+		return err
+	}
+
+	ttl := auth.ExpiresAt.Sub(time.Now())
+	pipe := databases.Database.DB.TxPipeline()
+	pipe.Set(ctx, deviceCodeKeyPrefix+auth.DeviceCodeHash, value, ttl)
+	pipe.Set(ctx, userCodeKeyPrefix+auth.UserCode, auth.DeviceCodeHash, ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetDeviceAuthorization returns the device authorization with provided hash of the device code
+func (r *oauthRepository) GetDeviceAuthorization(hash string) (*models.DeviceAuthorization, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value, err := databases.Database.DB.Get(ctx, deviceCodeKeyPrefix+hash).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	auth := new(models.DeviceAuthorization)
+	if err := json.Unmarshal(value, auth); err != nil {
+		return nil, err
+	}
+
+	return auth, nil
+}
+
+// GetDeviceAuthorizationByUserCode returns the device authorization with provided user code
+func (r *oauthRepository) GetDeviceAuthorizationByUserCode(userCode string) (*models.DeviceAuthorization, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	hash, err := databases.Database.DB.Get(ctx, userCodeKeyPrefix+userCode).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetDeviceAuthorization(hash)
+}
+
+// DeleteDeviceAuthorization removes the device authorization along with its user code
+func (r *oauthRepository) DeleteDeviceAuthorization(auth *models.DeviceAuthorization) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return databases.Database.DB.Del(ctx,
+		deviceCodeKeyPrefix+auth.DeviceCodeHash,
+		userCodeKeyPrefix+auth.UserCode,
+		devicePollKeyPrefix+auth.DeviceCodeHash,
+	).Err()
+}
+
+// TouchDevicePoll records the poll of the device, false is returned if the
+// device has already polled within the interval
+func (r *oauthRepository) TouchDevicePoll(hash string, interval time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return databases.Database.DB.SetNX(ctx, devicePollKeyPrefix+hash, 1, interval).Result()
+}
@@ -2,11 +2,19 @@ package data
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/0x113/x-media/auth/databases"
 	"github.com/0x113/x-media/auth/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	familyKeyPrefix   = "family:"
+	sessionsKeyPrefix = "sessions:"
 )
 
 // authRepository manages the authentication CRUD
@@ -37,6 +45,14 @@ func (r *authRepository) Save(username string, token *models.TokenDetails) error
 	return nil
 }
 
+// Get returns the username of the token owner
+func (r *authRepository) Get(uuid string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return databases.Database.DB.Get(ctx, uuid).Result()
+}
+
 // Delete the token from the Redis database
 func (r *authRepository) Delete(uuid string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -53,3 +69,86 @@ func (r *authRepository) Delete(uuid string) error {
 
 	return nil
 }
+
+// SaveFamily stores the refresh token family until its newest refresh token
+// expires and adds it to the sessions of the user
+func (r *authRepository) SaveFamily(family *models.TokenFamily, expires time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value, err := json.Marshal(family)
+	if err != nil {
+		return err
+	}
+
+	sessionsKey := sessionsKeyPrefix + family.Username
+	pipe := databases.Database.DB.TxPipeline()
+	pipe.Set(ctx, familyKeyPrefix+family.ID, value, expires.Sub(time.Now()))
+	pipe.SAdd(ctx, sessionsKey, family.ID)
+	pipe.Expire(ctx, sessionsKey, expires.Sub(time.Now())) // the newest family expires as the last one
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetFamily returns the refresh token family with provided id
+func (r *authRepository) GetFamily(id string) (*models.TokenFamily, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value, err := databases.Database.DB.Get(ctx, familyKeyPrefix+id).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	family := new(models.TokenFamily)
+	if err := json.Unmarshal(value, family); err != nil {
+		return nil, err
+	}
+
+	return family, nil
+}
+
+// GetFamilies returns all of the refresh token families of the user, expired
+// families are removed from the user sessions
+func (r *authRepository) GetFamilies(username string) ([]*models.TokenFamily, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessionsKey := sessionsKeyPrefix + username
+	ids, err := databases.Database.DB.SMembers(ctx, sessionsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	families := []*models.TokenFamily{}
+	for _, id := range ids {
+		value, err := databases.Database.DB.Get(ctx, familyKeyPrefix+id).Bytes()
+		if err == redis.Nil {
+			databases.Database.DB.SRem(ctx, sessionsKey, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		family := new(models.TokenFamily)
+		if err := json.Unmarshal(value, family); err != nil {
+			return nil, err
+		}
+		families = append(families, family)
+	}
+
+	return families, nil
+}
+
+// DeleteFamily removes the refresh token family from the Redis database and
+// from the sessions of the user
+func (r *authRepository) DeleteFamily(family *models.TokenFamily) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipe := databases.Database.DB.TxPipeline()
+	pipe.Del(ctx, familyKeyPrefix+family.ID)
+	pipe.SRem(ctx, sessionsKeyPrefix+family.Username, family.ID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
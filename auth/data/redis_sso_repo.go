@@ -0,0 +1,84 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/0x113/x-media/auth/databases"
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	ssoStateKeyPrefix = "sso_state:"
+	ssoLinksKeyPrefix = "sso_links:"
+)
+
+// ssoRepository manages the SSO states CRUD
+type ssoRepository struct{}
+
+// NewRedisSSORepository returns a new instance of the SSO repository
+func NewRedisSSORepository() SSORepository {
+	return &ssoRepository{}
+}
+
+// SaveState stores the state under its hash until it expires
+func (r *ssoRepository) SaveState(hash string, state *models.SSOState, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return databases.Database.DB.Set(ctx, ssoStateKeyPrefix+hash, value, ttl).Err()
+}
+
+// TakeState returns the state and removes it, so the callback can be handled only once
+func (r *ssoRepository) TakeState(hash string) (*models.SSOState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	key := ssoStateKeyPrefix + hash
+	pipe := databases.Database.DB.TxPipeline()
+	get := pipe.Get(ctx, key)
+	del := pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	// the state has been already taken by the concurrent request
+	if del.Val() == 0 {
+		return nil, redis.Nil
+	}
+
+	state := new(models.SSOState)
+	if err := json.Unmarshal([]byte(get.Val()), state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// GetLink returns the user linked with the subject of the identity provider,
+// the empty username is returned if the identity isn't linked
+func (r *ssoRepository) GetLink(provider, subject string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	username, err := databases.Database.DB.HGet(ctx, ssoLinksKeyPrefix+provider, subject).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return username, err
+}
+
+// SaveLink links the subject of the identity provider with the user
+func (r *ssoRepository) SaveLink(provider, subject, username string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return databases.Database.DB.HSet(ctx, ssoLinksKeyPrefix+provider, subject, username).Err()
+}
@@ -0,0 +1,60 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/0x113/x-media/auth/databases"
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const webAuthnChallengeKeyPrefix = "webauthn_challenge:"
+
+// webAuthnRepository manages the WebAuthn challenges CRUD
+type webAuthnRepository struct{}
+
+// NewRedisWebAuthnRepository returns a new instance of the WebAuthn repository
+func NewRedisWebAuthnRepository() WebAuthnRepository {
+	return &webAuthnRepository{}
+}
+
+// SaveChallenge stores the challenge under its hash until it expires
+func (r *webAuthnRepository) SaveChallenge(hash string, challenge *models.WebAuthnChallenge, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+
+	return databases.Database.DB.Set(ctx, webAuthnChallengeKeyPrefix+hash, value, ttl).Err()
+}
+
+// TakeChallenge returns the challenge and removes it, so it can be signed only once
+func (r *webAuthnRepository) TakeChallenge(hash string) (*models.WebAuthnChallenge, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	key := webAuthnChallengeKeyPrefix + hash
+	pipe := databases.Database.DB.TxPipeline()
+	get := pipe.Get(ctx, key)
+	del := pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	// the challenge has been already taken by the concurrent request
+	if del.Val() == 0 {
+		return nil, redis.Nil
+	}
+
+	challenge := new(models.WebAuthnChallenge)
+	if err := json.Unmarshal([]byte(get.Val()), challenge); err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
@@ -1,10 +1,75 @@
 package data
 
-import "github.com/0x113/x-media/auth/models"
+import (
+	"time"
+
+	"github.com/0x113/x-media/auth/models"
+)
 
 // AuthRepository manages the operations on the database for
 // the authetication service
 type AuthRepository interface {
 	Save(username string, token *models.TokenDetails) error
+	Get(uuid string) (string, error)
 	Delete(uuid string) error
+	SaveFamily(family *models.TokenFamily, expires time.Time) error
+	GetFamily(id string) (*models.TokenFamily, error)
+	GetFamilies(username string) ([]*models.TokenFamily, error)
+	DeleteFamily(family *models.TokenFamily) error
+	SaveAPIKey(key *models.APIKey) error
+	GetAPIKey(id string) (*models.APIKey, error)
+	GetAPIKeyByHash(hash string) (*models.APIKey, error)
+	GetAPIKeys(username string) ([]*models.APIKey, error)
+	DeleteAPIKey(key *models.APIKey) error
+	SaveMFAChallenge(hash string, challenge *models.MFAChallenge) error
+	TakeMFAChallenge(hash string) (*models.MFAChallenge, error)
+}
+
+// KeyRepository manages the signing keys of the authentication service
+type KeyRepository interface {
+	SaveKey(key *models.SigningKey) error
+	GetKeys() ([]*models.SigningKey, error)
+	DeleteKey(kid string) error
+	LockRotation(ttl time.Duration) (bool, error)
+}
+
+// LoginAttemptRepository manages the failed login attempts and lockouts
+type LoginAttemptRepository interface {
+	AddFailure(key string, at time.Time, window time.Duration) (int, error)
+	ClearFailures(key string) error
+	IncrLockoutLevel(key string, ttl time.Duration) (int, error)
+	Lock(key string, duration time.Duration) error
+	GetLockout(key string) (time.Duration, error)
+	Unlock(key string) error
+}
+
+// OAuthRepository manages the OAuth clients, authorization codes and consents
+type OAuthRepository interface {
+	SaveClient(client *models.OAuthClient) error
+	GetClient(id string) (*models.OAuthClient, error)
+	GetClients() ([]*models.OAuthClient, error)
+	DeleteClient(id string) error
+	SaveCode(hash string, code *models.AuthorizationCode, ttl time.Duration) error
+	TakeCode(hash string) (*models.AuthorizationCode, error)
+	SaveConsent(username, clientID, scope string) error
+	GetConsent(username, clientID string) (string, error)
+	SaveDeviceAuthorization(auth *models.DeviceAuthorization) error
+	GetDeviceAuthorization(hash string) (*models.DeviceAuthorization, error)
+	GetDeviceAuthorizationByUserCode(userCode string) (*models.DeviceAuthorization, error)
+	DeleteDeviceAuthorization(auth *models.DeviceAuthorization) error
+	TouchDevicePoll(hash string, interval time.Duration) (bool, error)
+}
+
+// SSORepository manages the pending sign ins with the external identity providers
+type SSORepository interface {
+	SaveState(hash string, state *models.SSOState, ttl time.Duration) error
+	TakeState(hash string) (*models.SSOState, error)
+	GetLink(provider, subject string) (string, error)
+	SaveLink(provider, subject, username string) error
+}
+
+// WebAuthnRepository manages the pending passkey registrations and logins
+type WebAuthnRepository interface {
+	SaveChallenge(hash string, challenge *models.WebAuthnChallenge, ttl time.Duration) error
+	TakeChallenge(hash string) (*models.WebAuthnChallenge, error)
 }
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+)
+
+// @Summary Create API key
+// @Description Creates new API key of the authenticated user, the key is returned only once
+// @ID create-api-key
+// @Accept  json
+// @Produce  json
+// @Param name body models.APIKeyRequest true "Name, scopes and optional expiration time"
+// @Success 201 {object} models.CreatedAPIKey
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /keys [post]
+// CreateAPIKey calls the service layer to create new API key
+func (h *authHandler) CreateAPIKey(c echo.Context) error {
+	errMsg := new(models.Error)
+	req := new(models.APIKeyRequest)
+	if err := c.Bind(req); err != nil {
+		errMsg.Code = http.StatusBadRequest
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	key, err := h.authService.CreateAPIKey(getAccessDetails(c).AccessDetails, req)
+	if err != nil {
+		errMsg.Code = http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			errMsg.Code = http.StatusBadRequest
+		}
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, key)
+}
+
+// @Summary Get API keys
+// @Description Returns the API keys of the authenticated user or, for admins, the API keys of the user from the path
+// @ID get-api-keys
+// @Produce  json
+// @Param username path string false "Username, admin only"
+// @Success 200 {array} models.APIKey
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /keys [get]
+// @Router /users/{username}/keys [get]
+// GetAPIKeys calls the service layer to get the API keys of the user
+func (h *authHandler) GetAPIKeys(c echo.Context) error {
+	keys, err := h.authService.GetAPIKeys(resourceOwner(c))
+	if err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+// @Summary Revoke API key
+// @Description Revokes the API key of the authenticated user or, for admins, the API key of the user from the path
+// @ID revoke-api-key
+// @Produce  json
+// @Param username path string false "Username, admin only"
+// @Param id path string true "API key ID"
+// @Success 204
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /keys/{id} [delete]
+// @Router /users/{username}/keys/{id} [delete]
+// RevokeAPIKey calls the service layer to revoke the API key with provided id
+func (h *authHandler) RevokeAPIKey(c echo.Context) error {
+	if err := h.authService.RevokeAPIKey(resourceOwner(c), c.Param("id")); err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			errMsg.Code = http.StatusNotFound
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+)
+
+func (suite *AuthHandlerTestSuite) TestAPIKeys() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	e := echo.New()
+	NewAuthHandler(e, suite.authService)
+
+	user := suite.generateToken("JohnDoe", false)
+	admin := suite.generateToken("admin", true)
+
+	request := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request(http.MethodPost, "/api/v1/auth/keys", user.AccessToken, `{"name": "Scan", "scopes": ["library:scan"]}`)
+	suite.Equal(http.StatusBadRequest, rec.Code)
+
+	rec = request(http.MethodPost, "/api/v1/auth/keys", user.AccessToken, `{"name": "Home Assistant", "scopes": ["library:read"]}`)
+	suite.Require().Equal(http.StatusCreated, rec.Code)
+	key := new(models.CreatedAPIKey)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), key))
+
+	// the validate endpoint accepts the API keys
+	rec = request(http.MethodPost, "/api/v1/auth/token/validate", "", `{"token": "`+key.Key+`"}`)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	details := new(models.UuidAccessDetails)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), details))
+	suite.Equal("JohnDoe", details.Username)
+	suite.Equal([]string{"library:read"}, details.Scopes)
+
+	// API key can't manage the API keys
+	rec = request(http.MethodGet, "/api/v1/auth/keys", key.Key, "")
+	suite.Equal(http.StatusForbidden, rec.Code)
+
+	// nor sign the user out, the API key doesn't belong to any session
+	rec = request(http.MethodGet, "/api/v1/auth/sessions", key.Key, "")
+	suite.Equal(http.StatusForbidden, rec.Code)
+	rec = request(http.MethodDelete, "/api/v1/auth/sessions", key.Key, "")
+	suite.Equal(http.StatusForbidden, rec.Code)
+
+	rec = request(http.MethodGet, "/api/v1/auth/keys", user.AccessToken, "")
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.NotContains(rec.Body.String(), key.Key)
+	suite.NotContains(rec.Body.String(), `"hash"`)
+
+	// admins manage the keys of other users
+	rec = request(http.MethodGet, "/api/v1/auth/users/JohnDoe/keys", user.AccessToken, "")
+	suite.Equal(http.StatusForbidden, rec.Code)
+	rec = request(http.MethodGet, "/api/v1/auth/users/JohnDoe/keys", admin.AccessToken, "")
+	suite.Equal(http.StatusOK, rec.Code)
+	rec = request(http.MethodDelete, "/api/v1/auth/users/JohnDoe/keys/"+key.ID, admin.AccessToken, "")
+	suite.Equal(http.StatusNoContent, rec.Code)
+	rec = request(http.MethodDelete, "/api/v1/auth/keys/"+key.ID, user.AccessToken, "")
+	suite.Equal(http.StatusNotFound, rec.Code)
+
+	rec = request(http.MethodPost, "/api/v1/auth/token/validate", "", `{"token": "`+key.Key+`"}`)
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/models"
+	authmw "github.com/0x113/x-media/auth/pkg/middleware"
+
+	"github.com/labstack/echo"
+)
+
+// clientInfo returns the address and the user agent of the client
+func clientInfo(c echo.Context) *models.ClientInfo {
+	return &models.ClientInfo{
+		IP:        clientIP(c.Request()),
+		UserAgent: c.Request().UserAgent(),
+	}
+}
+
+// clientIP returns the address of the client behind the trusted proxies from the config
+func clientIP(req *http.Request) string {
+	return authmw.ClientIP(req, common.Config.TrustedProxies)
+}
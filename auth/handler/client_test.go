@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+)
+
+func (suite *AuthHandlerTestSuite) TestClientIP() {
+	common.Config.TrustedProxies = []string{"172.18.0.0/16", "10.0.0.1"}
+
+	testCases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		expectedIP string
+	}{
+		{
+			name:       "Direct request",
+			remoteAddr: "192.168.1.10:51234",
+			expectedIP: "192.168.1.10",
+		},
+		{
+			name:       "Spoofed X-Forwarded-For",
+			remoteAddr: "192.168.1.10:51234",
+			forwarded:  "203.0.113.7",
+			expectedIP: "192.168.1.10",
+		},
+		{
+			name:       "Spoofed X-Real-IP",
+			remoteAddr: "192.168.1.10:51234",
+			realIP:     "203.0.113.7",
+			expectedIP: "192.168.1.10",
+		},
+		{
+			name:       "Trusted proxy",
+			remoteAddr: "172.18.0.5:51234",
+			forwarded:  "192.168.1.10",
+			expectedIP: "192.168.1.10",
+		},
+		{
+			name:       "Spoofed address prepended by the client",
+			remoteAddr: "172.18.0.5:51234",
+			forwarded:  "203.0.113.7, 192.168.1.10",
+			expectedIP: "192.168.1.10",
+		},
+		{
+			name:       "Chain of trusted proxies",
+			remoteAddr: "172.18.0.5:51234",
+			forwarded:  "192.168.1.10, 10.0.0.1",
+			expectedIP: "192.168.1.10",
+		},
+		{
+			name:       "Trusted proxy setting X-Real-IP",
+			remoteAddr: "10.0.0.1:51234",
+			realIP:     "192.168.1.10",
+			expectedIP: "192.168.1.10",
+		},
+		{
+			name:       "Trusted proxy without the headers",
+			remoteAddr: "172.18.0.5:51234",
+			expectedIP: "172.18.0.5",
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/token/generate", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set(echo.HeaderXForwardedFor, tt.forwarded)
+			}
+			if tt.realIP != "" {
+				req.Header.Set(echo.HeaderXRealIP, tt.realIP)
+			}
+			suite.Equal(tt.expectedIP, clientIP(req))
+		})
+	}
+}
+
+func (suite *AuthHandlerTestSuite) TestLoginSpoofedForwardedFor() {
+	common.Config.LoginMaxIPAttempts = 2
+	suite.httpClient = &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			jsonStr := `{"code": 500, "message": "Invalid user credentials"}`
+			return &http.Response{
+				StatusCode: 500,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(jsonStr))),
+			}, nil
+		},
+	}
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	e := echo.New()
+	NewAuthHandler(e, suite.authService)
+
+	// rotating X-Forwarded-For doesn't change the limiter key
+	login := func(i int) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"username": "user%d", "password": "wrong"}`, i)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/token/generate", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(echo.HeaderXForwardedFor, fmt.Sprintf("203.0.113.%d", i))
+		req.RemoteAddr = "192.168.1.10:51234"
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	suite.Equal(http.StatusInternalServerError, login(1).Code)
+	suite.Equal(http.StatusInternalServerError, login(2).Code)
+	suite.Equal(http.StatusTooManyRequests, login(3).Code)
+}
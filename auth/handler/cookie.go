@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	// SessionModeHeader set to "cookie" by the browser frontend switches the
+	// token endpoints to the cookie session mode
+	SessionModeHeader = "X-Session-Mode"
+	// CSRFHeader carries the copy of the CSRF cookie (double-submit)
+	CSRFHeader = "X-CSRF-Token"
+
+	cookieSessionMode = "cookie"
+	refreshCookieName = "xmedia_refresh"
+	csrfCookieName    = "xmedia_csrf"
+	// refreshCookiePath limits the refresh cookie to the refresh endpoint
+	refreshCookiePath = "/api/v1/auth/token/refresh"
+)
+
+var (
+	// errInvalidCSRFToken is returned when the CSRF header doesn't match the CSRF cookie
+	errInvalidCSRFToken = errors.New("Invalid CSRF token")
+	// errMissingRefreshCookie is returned when the refresh cookie has expired or has been removed
+	errMissingRefreshCookie = errors.New("Missing refresh token cookie")
+)
+
+// cookieMode checks if the cookie mode is enabled and requested by the client
+func cookieMode(c echo.Context) bool {
+	return common.Config.CookieMode && strings.EqualFold(c.Request().Header.Get(SessionModeHeader), cookieSessionMode)
+}
+
+// setSessionCookies moves the refresh token from the response body to the
+// HttpOnly cookie and issues new CSRF token readable by the frontend
+func setSessionCookies(c echo.Context, token *models.TokenDetails) error {
+	csrf := make([]byte, 32)
+	if _, err := rand.Read(csrf); err != nil {
+		return err
+	}
+	expires := time.Unix(token.RtExpires, 0)
+
+	refresh := sessionCookie(refreshCookieName, token.RefreshToken, refreshCookiePath, expires)
+	refresh.HttpOnly = true
+	c.SetCookie(refresh)
+	c.SetCookie(sessionCookie(csrfCookieName, base64.RawURLEncoding.EncodeToString(csrf), "/", expires))
+
+	token.RefreshToken = ""
+	return nil
+}
+
+// clearSessionCookies removes the refresh and CSRF cookies from the browser
+func clearSessionCookies(c echo.Context) {
+	for _, cookie := range []*http.Cookie{
+		sessionCookie(refreshCookieName, "", refreshCookiePath, time.Unix(0, 0)),
+		sessionCookie(csrfCookieName, "", "/", time.Unix(0, 0)),
+	} {
+		cookie.MaxAge = -1
+		c.SetCookie(cookie)
+	}
+}
+
+// checkCSRF compares the CSRF header with the CSRF cookie, a cross-site
+// request can send the cookie, but can't read it to copy it to the header
+func checkCSRF(c echo.Context) error {
+	cookie, err := c.Cookie(csrfCookieName)
+	header := c.Request().Header.Get(CSRFHeader)
+	if err != nil || cookie.Value == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) != 1 {
+		return errInvalidCSRFToken
+	}
+	return nil
+}
+
+// refreshCookie returns the refresh token stored in the cookie
+func refreshCookie(c echo.Context) (string, error) {
+	cookie, err := c.Cookie(refreshCookieName)
+	if err != nil || cookie.Value == "" {
+		return "", errMissingRefreshCookie
+	}
+	return cookie.Value, nil
+}
+
+// sessionCookie creates the Secure cookie with the configured SameSite attribute
+func sessionCookie(name, value, path string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   common.Config.CookieDomain,
+		Expires:  expires,
+		Secure:   true,
+		SameSite: sameSite(),
+	}
+}
+
+// sameSite returns the configured SameSite attribute of the cookies
+func sameSite() http.SameSite {
+	switch strings.ToLower(common.Config.CookieSameSite) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteStrictMode
+	}
+}
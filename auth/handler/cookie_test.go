@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+)
+
+// findCookie returns the cookie with provided name set by the response
+func findCookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func (suite *AuthHandlerTestSuite) TestCookieSession() {
+	common.Config.CookieMode = true
+	suite.httpClient = &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"username": "JohnDoe", "is_admin": false}`))),
+			}, nil
+		},
+	}
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	e := echo.New()
+	NewAuthHandler(e, suite.authService)
+
+	request := func(target, body string, cookies []*http.Cookie, csrf string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(SessionModeHeader, "cookie")
+		if csrf != "" {
+			req.Header.Set(CSRFHeader, csrf)
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// the refresh token is returned only in the cookie
+	rec := request("/api/v1/auth/token/generate", `{"username": "JohnDoe", "password": "test1231"}`, nil, "")
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.NotContains(rec.Body.String(), "refresh_token")
+	refresh := findCookie(rec, refreshCookieName)
+	suite.Require().NotNil(refresh)
+	suite.True(refresh.HttpOnly)
+	suite.True(refresh.Secure)
+	suite.Equal(http.SameSiteStrictMode, refresh.SameSite)
+	suite.Equal("/api/v1/auth/token/refresh", refresh.Path)
+	csrf := findCookie(rec, csrfCookieName)
+	suite.Require().NotNil(csrf)
+	suite.False(csrf.HttpOnly)
+	cookies := []*http.Cookie{refresh, csrf}
+
+	// refresh requires the CSRF header matching the cookie
+	rec = request("/api/v1/auth/token/refresh", "", cookies, "")
+	suite.Equal(http.StatusForbidden, rec.Code)
+	rec = request("/api/v1/auth/token/refresh", "", cookies, "forged")
+	suite.Equal(http.StatusForbidden, rec.Code)
+	rec = request("/api/v1/auth/token/refresh", "", []*http.Cookie{csrf}, csrf.Value)
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+	rec = request("/api/v1/auth/token/refresh", "", cookies, csrf.Value)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	token := new(models.TokenDetails)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), token))
+	suite.Empty(token.RefreshToken)
+	rotated := findCookie(rec, refreshCookieName)
+	suite.Require().NotNil(rotated)
+	suite.NotEqual(refresh.Value, rotated.Value)
+	csrf = findCookie(rec, csrfCookieName)
+	cookies = []*http.Cookie{rotated, csrf}
+
+	// logout requires the CSRF token too and removes the cookies
+	logout := `{"token": "` + token.AccessToken + `"}`
+	rec = request("/api/v1/auth/token/logout", logout, cookies, "")
+	suite.Equal(http.StatusForbidden, rec.Code)
+	rec = request("/api/v1/auth/token/logout", logout, cookies, csrf.Value)
+	suite.Require().Equal(http.StatusNoContent, rec.Code)
+	suite.Equal(-1, findCookie(rec, refreshCookieName).MaxAge)
+	suite.Equal(-1, findCookie(rec, csrfCookieName).MaxAge)
+}
+
+func (suite *AuthHandlerTestSuite) TestCookieModeDisabled() {
+	suite.httpClient = &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"username": "JohnDoe", "is_admin": false}`))),
+			}, nil
+		},
+	}
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	e := echo.New()
+	NewAuthHandler(e, suite.authService)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/token/generate", strings.NewReader(`{"username": "JohnDoe", "password": "test1231"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(SessionModeHeader, "cookie")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Contains(rec.Body.String(), "refresh_token")
+	suite.Empty(rec.Result().Cookies())
+}
+
+func (suite *AuthHandlerTestSuite) TestCORS() {
+	e := echo.New()
+	e.Use(NewCORSMiddleware([]string{"http://localhost:3000"}))
+	NewAuthHandler(e, service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter))
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/auth/token/refresh", nil)
+	req.Header.Set(echo.HeaderOrigin, "http://localhost:3000")
+	req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodPost)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	suite.Equal(http.StatusNoContent, rec.Code)
+	suite.Equal("http://localhost:3000", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	suite.Equal("true", rec.Header().Get(echo.HeaderAccessControlAllowCredentials))
+	suite.Contains(rec.Header().Get(echo.HeaderAccessControlAllowHeaders), CSRFHeader)
+
+	req = httptest.NewRequest(http.MethodOptions, "/api/v1/auth/token/refresh", nil)
+	req.Header.Set(echo.HeaderOrigin, "https://evil.example.com")
+	req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodPost)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	suite.Empty(rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/labstack/echo"
+)
+
+// InternalSecretHeader carries the shared secret of the internal routes
+const InternalSecretHeader = "X-Internal-Secret"
+
+// requireInternalSecret allows only the other services knowing the shared
+// secret to call the internal routes, they're disabled without the secret
+func requireInternalSecret(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		secret := common.Config.InternalSecret
+		provided := c.Request().Header.Get(InternalSecretHeader)
+		if secret == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) != 1 {
+			errMsg := &models.Error{
+				Code:    http.StatusForbidden,
+				Message: "Invalid internal secret",
+			}
+			return c.JSON(errMsg.Code, errMsg)
+		}
+		return next(c)
+	}
+}
+
+// @Summary Revoke user sessions
+// @Description Revokes all of the sessions of the user, called by the user service after the password reset, the change of the role and when the user is disabled or deleted
+// @ID revoke-user-sessions
+// @Produce  json
+// @Param username path string true "Username"
+// @Param X-Internal-Secret header string true "Shared secret of the services"
+// @Success 204
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /internal/users/{username}/sessions [delete]
+// RevokeUserSessions calls the service layer to revoke all of the sessions of the user
+func (h *authHandler) RevokeUserSessions(c echo.Context) error {
+	if err := h.authService.RevokeSessions(c.Param("username"), ""); err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Revoke user API keys
+// @Description Revokes all of the API keys of the user, called by the user service after the change of the role and when the user is disabled or deleted
+// @ID revoke-user-api-keys
+// @Produce  json
+// @Param username path string true "Username"
+// @Param X-Internal-Secret header string true "Shared secret of the services"
+// @Success 204
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /internal/users/{username}/api-keys [delete]
+// RevokeUserAPIKeys calls the service layer to revoke all of the API keys of the user
+func (h *authHandler) RevokeUserAPIKeys(c echo.Context) error {
+	if err := h.authService.RevokeAPIKeys(c.Param("username")); err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
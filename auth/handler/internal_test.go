@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+)
+
+func (suite *AuthHandlerTestSuite) TestRevokeUserSessions() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	e := echo.New()
+	NewAuthHandler(e, suite.authService)
+
+	phone := suite.generateToken("JohnDoe", false)
+	tv := suite.generateToken("JohnDoe", false)
+	admin := suite.generateToken("admin", true)
+
+	request := func(secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/auth/internal/users/JohnDoe/sessions", nil)
+		if secret != "" {
+			req.Header.Set(InternalSecretHeader, secret)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// the route is disabled without the configured secret
+	suite.Equal(http.StatusForbidden, request("").Code)
+	suite.Equal(http.StatusForbidden, request("internal_secret").Code)
+
+	common.Config.InternalSecret = "internal_secret"
+	suite.Equal(http.StatusForbidden, request("").Code)
+	suite.Equal(http.StatusForbidden, request("wrong_secret").Code)
+	suite.Equal(http.StatusNoContent, request("internal_secret").Code)
+
+	for _, token := range []string{phone.AccessToken, tv.AccessToken} {
+		_, err := suite.authService.ValidateToken(token)
+		suite.NotNil(err)
+	}
+	sessions, err := suite.authService.GetSessions("JohnDoe", "")
+	suite.Require().Nil(err)
+	suite.Empty(sessions)
+
+	// the sessions of the other users are kept
+	_, err = suite.authService.ValidateToken(admin.AccessToken)
+	suite.Nil(err)
+}
+
+func (suite *AuthHandlerTestSuite) TestRevokeUserAPIKeys() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	e := echo.New()
+	NewAuthHandler(e, suite.authService)
+
+	isAdmin := false
+	owner := &models.AccessDetails{Username: "JohnDoe", IsAdmin: &isAdmin}
+	other := &models.AccessDetails{Username: "alice", IsAdmin: &isAdmin}
+	var keys []*models.CreatedAPIKey
+	for _, details := range []*models.AccessDetails{owner, owner, other} {
+		key, err := suite.authService.CreateAPIKey(details, &models.APIKeyRequest{Name: "Kodi", Scopes: []string{service.ScopeStream}})
+		suite.Require().Nil(err)
+		keys = append(keys, key)
+	}
+
+	request := func(secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/auth/internal/users/JohnDoe/api-keys", nil)
+		if secret != "" {
+			req.Header.Set(InternalSecretHeader, secret)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	suite.Equal(http.StatusForbidden, request("internal_secret").Code)
+	common.Config.InternalSecret = "internal_secret"
+	suite.Equal(http.StatusForbidden, request("wrong_secret").Code)
+	suite.Equal(http.StatusNoContent, request("internal_secret").Code)
+
+	for _, key := range keys[:2] {
+		_, err := suite.authService.ValidateToken(key.Key)
+		suite.True(errors.Is(err, service.ErrTokenRevoked), "unexpected error: %v", err)
+	}
+	remaining, err := suite.authService.GetAPIKeys("JohnDoe")
+	suite.Require().Nil(err)
+	suite.Empty(remaining)
+
+	// the API keys of the other users are kept
+	_, err = suite.authService.ValidateToken(keys[2].Key)
+	suite.Nil(err)
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/labstack/echo"
+)
+
+// @Summary Unlock user
+// @Description Removes the login lockout of the user, admin only
+// @ID unlock-user
+// @Produce  json
+// @Param username path string true "Username"
+// @Success 204
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /users/{username}/lockout [delete]
+// UnlockUser calls the service layer to remove the login lockout of the user
+func (h *authHandler) UnlockUser(c echo.Context) error {
+	if err := h.authService.UnlockUser(c.Param("username")); err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Unlock ip address
+// @Description Removes the login lockout of the ip address, admin only
+// @ID unlock-ip
+// @Produce  json
+// @Param ip path string true "IP address"
+// @Success 204
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /ips/{ip}/lockout [delete]
+// UnlockIP calls the service layer to remove the login lockout of the ip address
+func (h *authHandler) UnlockIP(c echo.Context) error {
+	if err := h.authService.UnlockIP(c.Param("ip")); err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
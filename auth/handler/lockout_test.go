@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+)
+
+func (suite *AuthHandlerTestSuite) TestLoginLockout() {
+	common.Config.LoginMaxAttempts = 2
+	suite.httpClient = &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			jsonStr := `{"code": 500, "message": "Invalid user credentials"}`
+			return &http.Response{
+				StatusCode: 500,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(jsonStr))),
+			}, nil
+		},
+	}
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	e := echo.New()
+	NewAuthHandler(e, suite.authService)
+	admin := suite.generateToken("admin", true)
+	user := suite.generateToken("JohnDoe", false)
+
+	login := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/token/generate", strings.NewReader(`{"username": "JaneDoe", "password": "wrong"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	unlock := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/auth/users/JaneDoe/lockout", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	suite.Equal(http.StatusInternalServerError, login().Code)
+	suite.Equal(http.StatusInternalServerError, login().Code)
+	rec := login()
+	suite.Equal(http.StatusTooManyRequests, rec.Code)
+	suite.Equal("60", rec.Header().Get("Retry-After"))
+
+	suite.Equal(http.StatusForbidden, unlock(user.AccessToken).Code)
+	suite.Equal(http.StatusTooManyRequests, login().Code)
+
+	suite.Equal(http.StatusNoContent, unlock(admin.AccessToken).Code)
+	suite.Equal(http.StatusInternalServerError, login().Code)
+}
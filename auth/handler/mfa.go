@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+)
+
+type mfaHandler struct {
+	mfaService service.MFAService
+}
+
+// NewMFAHandler initiates the handlers of the two-factor authentication enrollment
+func NewMFAHandler(router *echo.Echo, mfaService service.MFAService, authService service.AuthService) {
+	handler := &mfaHandler{mfaService}
+
+	totp := router.Group("/api/v1/auth/mfa/totp", authenticate(authService), requireAccessToken)
+	totp.POST("/enroll", handler.EnrollTOTP)
+	totp.POST("/confirm", handler.ConfirmTOTP)
+	totp.POST("/disable", handler.DisableTOTP)
+}
+
+// mfaError responds with the status code matching the error of the MFA service
+func mfaError(c echo.Context, err error) error {
+	errMsg := &models.Error{
+		Code:    http.StatusInternalServerError,
+		Message: err.Error(),
+	}
+	switch {
+	case errors.Is(err, service.ErrInvalidMFACode):
+		errMsg.Code = http.StatusUnauthorized
+	case errors.Is(err, service.ErrMFAConflict):
+		errMsg.Code = http.StatusConflict
+	}
+	var rateLimitErr *service.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		errMsg.Code = http.StatusTooManyRequests
+		c.Response().Header().Set("Retry-After", strconv.Itoa(rateLimitErr.RetryAfterSeconds()))
+	}
+	c.JSON(errMsg.Code, errMsg)
+	return err
+}
+
+// bindCode binds the code of the request or responds with 400
+func bindCode(c echo.Context) (*models.TOTPCode, error) {
+	code := new(models.TOTPCode)
+	if err := c.Bind(code); err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return nil, err
+	}
+	return code, nil
+}
+
+// @Summary Enroll authenticator app
+// @Description Generates the TOTP secret and the recovery codes of the authenticated user, the second factor is required after the confirmation
+// @ID enroll-totp
+// @Produce  json
+// @Success 200 {object} models.TOTPEnrollment
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /mfa/totp/enroll [post]
+// EnrollTOTP calls the service layer to generate the secret of the authenticator app
+func (h *mfaHandler) EnrollTOTP(c echo.Context) error {
+	enrollment, err := h.mfaService.EnrollTOTP(getAccessDetails(c).Username)
+	if err != nil {
+		return mfaError(c, err)
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, enrollment)
+}
+
+// @Summary Confirm authenticator app
+// @Description Enables the two-factor authentication with the first code from the authenticator app
+// @ID confirm-totp
+// @Accept  json
+// @Produce  json
+// @Param name body models.TOTPCode true "Code from the authenticator app"
+// @Success 204
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /mfa/totp/confirm [post]
+// ConfirmTOTP calls the service layer to enable the second factor
+func (h *mfaHandler) ConfirmTOTP(c echo.Context) error {
+	code, err := bindCode(c)
+	if err != nil {
+		return err
+	}
+	if err := h.mfaService.ConfirmTOTP(getAccessDetails(c).Username, code.Code); err != nil {
+		return mfaError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Disable two-factor authentication
+// @Description Removes the authenticator app and the recovery codes of the authenticated user
+// @ID disable-totp
+// @Accept  json
+// @Produce  json
+// @Param name body models.TOTPCode true "Code from the authenticator app or one of the recovery codes"
+// @Success 204
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /mfa/totp/disable [post]
+// DisableTOTP calls the service layer to remove the second factor
+func (h *mfaHandler) DisableTOTP(c echo.Context) error {
+	code, err := bindCode(c)
+	if err != nil {
+		return err
+	}
+	if err := h.mfaService.DisableTOTP(getAccessDetails(c).Username, code.Code); err != nil {
+		return mfaError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
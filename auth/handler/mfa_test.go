@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+)
+
+func (suite *AuthHandlerTestSuite) TestTwoFactorAuthentication() {
+	common.Config.CookieMode = true
+	users := mocks.NewMockMFAUserService("123456")
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(users.Client()), suite.authRepo, suite.keys, suite.limiter)
+	e := echo.New()
+	NewAuthHandler(e, suite.authService)
+	NewMFAHandler(e, service.NewMFAService(users.Client()), suite.authService)
+
+	user := suite.generateToken("JohnDoe", false)
+
+	request := func(target, token, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("/api/v1/auth/mfa/totp/enroll", "", "", nil)
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+
+	// enroll the authenticator app
+	rec = request("/api/v1/auth/mfa/totp/enroll", user.AccessToken, "", nil)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.Equal("no-store", rec.Header().Get("Cache-Control"))
+	suite.Contains(rec.Body.String(), `"recovery_codes":["k7mzq-4tn2x"]`)
+	rec = request("/api/v1/auth/mfa/totp/confirm", user.AccessToken, `{"code": "000000"}`, nil)
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+	rec = request("/api/v1/auth/mfa/totp/confirm", user.AccessToken, `{"code": "123456"}`, nil)
+	suite.Equal(http.StatusNoContent, rec.Code)
+	rec = request("/api/v1/auth/mfa/totp/enroll", user.AccessToken, "", nil)
+	suite.Equal(http.StatusConflict, rec.Code)
+
+	// the password alone returns the MFA token
+	rec = request("/api/v1/auth/token/generate", "", `{"username": "JohnDoe", "password": "password"}`, nil)
+	suite.Require().Equal(http.StatusUnauthorized, rec.Code)
+	mfa := new(models.MFARequired)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), mfa))
+	suite.True(mfa.MFARequired)
+	suite.Equal(300, mfa.ExpiresIn)
+	suite.NotContains(rec.Body.String(), "access_token")
+
+	rec = request("/api/v1/auth/token/mfa", "", `{"mfa_token": "`+mfa.MFAToken+`", "code": "000000"}`, nil)
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+	rec = request("/api/v1/auth/token/mfa", "", `{"mfa_token": "`+mfa.MFAToken+`", "code": "123456"}`, http.Header{SessionModeHeader: {"cookie"}})
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.NotContains(rec.Body.String(), "refresh_token")
+	suite.NotNil(findCookie(rec, refreshCookieName))
+	rec = request("/api/v1/auth/token/mfa", "", `{"mfa_token": "`+mfa.MFAToken+`", "code": "123456"}`, nil)
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+
+	// the lockout of the user service is passed along with its Retry-After
+	users.Locked["JohnDoe"] = true
+	rec = request("/api/v1/auth/mfa/totp/disable", user.AccessToken, `{"code": "123456"}`, nil)
+	suite.Equal(http.StatusTooManyRequests, rec.Code)
+	suite.Equal("60", rec.Header().Get("Retry-After"))
+	users.Locked["JohnDoe"] = false
+
+	rec = request("/api/v1/auth/mfa/totp/disable", user.AccessToken, `{"code": "123456"}`, nil)
+	suite.Equal(http.StatusNoContent, rec.Code)
+	rec = request("/api/v1/auth/token/generate", "", `{"username": "JohnDoe", "password": "password"}`, nil)
+	suite.Equal(http.StatusOK, rec.Code)
+}
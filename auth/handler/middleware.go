@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+	echomw "github.com/labstack/echo/middleware"
+)
+
+// accessDetailsKey is the key under which the access details are stored in the echo context
+const accessDetailsKey = "access_details"
+
+// authenticate validates the bearer token from the Authorization header and
+// stores its access details in the echo context
+func authenticate(authService service.AuthService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			errMsg := new(models.Error)
+
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			parts := strings.SplitN(header, " ", 2)
+			if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+				errMsg.Code = http.StatusUnauthorized
+				errMsg.Message = "Missing authentication token"
+				return c.JSON(errMsg.Code, errMsg)
+			}
+
+			accessDetails, err := authService.ValidateToken(strings.TrimSpace(parts[1]))
+			if err != nil {
+				errMsg.Code = http.StatusUnauthorized
+				errMsg.Message = err.Error()
+				return c.JSON(errMsg.Code, errMsg)
+			}
+
+			c.Set(accessDetailsKey, accessDetails)
+			return next(c)
+		}
+	}
+}
+
+// requireScope allows only the users granted the scope to call the route, must be used after authenticate
+func requireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			accessDetails := getAccessDetails(c)
+			if accessDetails == nil || !service.HasScope(accessDetails.AccessDetails, scope) {
+				errMsg := &models.Error{
+					Code:    http.StatusForbidden,
+					Message: fmt.Sprintf("The %s scope is required", scope),
+				}
+				return c.JSON(errMsg.Code, errMsg)
+			}
+			return next(c)
+		}
+	}
+}
+
+// getAccessDetails returns the access details of the authenticated user
+func getAccessDetails(c echo.Context) *models.UuidAccessDetails {
+	accessDetails, _ := c.Get(accessDetailsKey).(*models.UuidAccessDetails)
+	return accessDetails
+}
+
+// requireAccessToken rejects the requests authenticated with the API key, must be used after authenticate
+func requireAccessToken(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		accessDetails := getAccessDetails(c)
+		if accessDetails == nil || accessDetails.APIKey != "" {
+			errMsg := &models.Error{
+				Code:    http.StatusForbidden,
+				Message: "API keys can't be used for this operation",
+			}
+			return c.JSON(errMsg.Code, errMsg)
+		}
+		return next(c)
+	}
+}
+
+// NewCORSMiddleware allows the web frontend from the configured origins to call
+// the service with the cookies and the authentication headers
+func NewCORSMiddleware(origins []string) echo.MiddlewareFunc {
+	return echomw.CORSWithConfig(echomw.CORSConfig{
+		AllowOrigins:     origins,
+		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		AllowHeaders:     []string{echo.HeaderAuthorization, echo.HeaderContentType, SessionModeHeader, CSRFHeader},
+		AllowCredentials: true,
+		MaxAge:           600,
+	})
+}
@@ -0,0 +1,428 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+)
+
+type oauthHandler struct {
+	oauthService service.OAuthService
+}
+
+// NewOAuthHandler initiates the OAuth 2.0 and OpenID Connect handlers
+func NewOAuthHandler(router *echo.Echo, oauthService service.OAuthService, authService service.AuthService) {
+	handler := &oauthHandler{oauthService}
+	auth := authenticate(authService)
+	manageUsers := requireScope(service.ScopeUsersManage)
+
+	router.GET("/.well-known/openid-configuration", handler.GetOpenIDConfiguration)
+
+	clients := router.Group("/api/v1/auth/oauth/clients", auth, manageUsers)
+	clients.POST("", handler.RegisterClient)
+	clients.GET("", handler.GetClients)
+	clients.DELETE("/:id", handler.DeleteClient)
+
+	router.GET("/api/v1/auth/oauth/authorize", handler.CheckAuthorization, auth, requireAccessToken)
+	router.POST("/api/v1/auth/oauth/authorize", handler.Authorize, auth, requireAccessToken)
+	router.POST("/api/v1/auth/oauth/token", handler.Token)
+	router.POST("/api/v1/auth/oauth/introspect", handler.Introspect)
+	router.POST("/api/v1/auth/oauth/device/code", handler.DeviceAuthorization)
+	router.GET("/api/v1/auth/oauth/device", handler.CheckDeviceCode, auth, requireAccessToken)
+	router.POST("/api/v1/auth/oauth/device", handler.ApproveDevice, auth, requireAccessToken)
+	router.GET("/api/v1/auth/oauth/userinfo", handler.UserInfo, auth)
+	router.POST("/api/v1/auth/oauth/userinfo", handler.UserInfo, auth)
+}
+
+// @Summary Register OAuth client
+// @Description Registers new OAuth client, the secret of the confidential client is returned only once
+// @ID register-oauth-client
+// @Accept  json
+// @Produce  json
+// @Param name body models.ClientRegistration true "Client registration"
+// @Success 201 {object} models.RegisteredClient
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /oauth/clients [post]
+// RegisterClient calls the service layer to register new OAuth client
+func (h *oauthHandler) RegisterClient(c echo.Context) error {
+	errMsg := new(models.Error)
+	reg := new(models.ClientRegistration)
+	if err := c.Bind(reg); err != nil {
+		errMsg.Code = http.StatusBadRequest
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	client, err := h.oauthService.RegisterClient(reg)
+	if err != nil {
+		errMsg.Code = http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidRegistration) {
+			errMsg.Code = http.StatusBadRequest
+		}
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, client)
+}
+
+// @Summary Get OAuth clients
+// @Description Returns all of the registered OAuth clients
+// @ID get-oauth-clients
+// @Produce  json
+// @Success 200 {array} models.OAuthClient
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /oauth/clients [get]
+// GetClients calls the service layer to get the registered OAuth clients
+func (h *oauthHandler) GetClients(c echo.Context) error {
+	clients, err := h.oauthService.GetClients()
+	if err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, clients)
+}
+
+// @Summary Delete OAuth client
+// @Description Deletes the OAuth client, its refresh tokens can no longer be used
+// @ID delete-oauth-client
+// @Produce  json
+// @Param id path string true "Client ID"
+// @Success 204
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Router /oauth/clients/{id} [delete]
+// DeleteClient calls the service layer to delete the OAuth client
+func (h *oauthHandler) DeleteClient(c echo.Context) error {
+	if err := h.oauthService.DeleteClient(c.Param("id")); err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		if errors.Is(err, service.ErrClientNotFound) {
+			errMsg.Code = http.StatusNotFound
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Check authorization request
+// @Description Validates the authorization request of the OAuth client and returns the details for the consent screen
+// @ID check-authorization
+// @Produce  json
+// @Param response_type query string true "Must be code"
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string true "Registered redirect uri"
+// @Param scope query string false "Space separated scopes"
+// @Param state query string false "Opaque value returned to the client"
+// @Param nonce query string false "Nonce included in the ID token"
+// @Param code_challenge query string true "PKCE code challenge"
+// @Param code_challenge_method query string true "Must be S256"
+// @Success 200 {object} models.AuthorizationInfo
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /oauth/authorize [get]
+// CheckAuthorization calls the service layer to validate the authorization request
+func (h *oauthHandler) CheckAuthorization(c echo.Context) error {
+	errMsg := new(models.Error)
+	req := new(models.AuthorizeRequest)
+	if err := c.Bind(req); err != nil {
+		errMsg.Code = http.StatusBadRequest
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	info, err := h.oauthService.CheckAuthorization(req, getAccessDetails(c))
+	if err != nil {
+		return authorizationError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, info)
+}
+
+// @Summary Authorize OAuth client
+// @Description Approves or denies the authorization request and returns the redirect uri of the client with the authorization code or the error
+// @ID authorize
+// @Accept  json
+// @Produce  json
+// @Param name body models.ConsentRequest true "Authorization request and the decision of the user"
+// @Success 200 {object} models.AuthorizationResult
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /oauth/authorize [post]
+// Authorize calls the service layer to issue the authorization code
+func (h *oauthHandler) Authorize(c echo.Context) error {
+	errMsg := new(models.Error)
+	req := new(models.ConsentRequest)
+	if err := c.Bind(req); err != nil {
+		errMsg.Code = http.StatusBadRequest
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	result, err := h.oauthService.Authorize(req, getAccessDetails(c))
+	if err != nil {
+		return authorizationError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// @Summary Token endpoint
+// @Description Exchanges the authorization code, the device code or the refresh token for new tokens (RFC 6749, RFC 8628)
+// @ID oauth-token
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param grant_type formData string true "authorization_code, refresh_token or urn:ietf:params:oauth:grant-type:device_code"
+// @Param code formData string false "Authorization code"
+// @Param redirect_uri formData string false "Redirect uri used in the authorization request"
+// @Param code_verifier formData string false "PKCE code verifier"
+// @Param refresh_token formData string false "Refresh token"
+// @Param device_code formData string false "Device code"
+// @Param client_id formData string false "Client ID, if HTTP Basic authentication isn't used"
+// @Param client_secret formData string false "Secret of the confidential client"
+// @Success 200 {object} models.OAuthTokenResponse
+// @Failure 400 {object} models.OAuthError
+// @Failure 401 {object} models.OAuthError
+// @Failure 500 {object} models.OAuthError
+// @Router /oauth/token [post]
+// Token calls the service layer to issue new tokens for the OAuth client
+func (h *oauthHandler) Token(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().Header().Set("Pragma", "no-cache")
+
+	req := new(models.TokenRequest)
+	if err := c.Bind(req); err != nil {
+		c.JSON(http.StatusBadRequest, &models.OAuthError{Code: "invalid_request", Description: "Request must be form encoded"})
+		return err
+	}
+	// client_secret_basic, credentials are form encoded before they're put in the header
+	if id, secret, ok := c.Request().BasicAuth(); ok {
+		req.ClientID, _ = url.QueryUnescape(id)
+		req.ClientSecret, _ = url.QueryUnescape(secret)
+	}
+
+	token, err := h.oauthService.Token(req, clientInfo(c))
+	if err != nil {
+		return tokenError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, token)
+}
+
+// @Summary Token introspection
+// @Description Returns the state and the claims of the access token, refresh token or API key (RFC 7662), available only to the confidential clients
+// @ID introspect-token
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param token formData string true "Token to introspect"
+// @Param token_type_hint formData string false "access_token or refresh_token"
+// @Param client_id formData string false "Client ID, if HTTP Basic authentication isn't used"
+// @Param client_secret formData string false "Secret of the confidential client"
+// @Success 200 {object} models.Introspection
+// @Failure 400 {object} models.OAuthError
+// @Failure 401 {object} models.OAuthError
+// @Router /oauth/introspect [post]
+// Introspect calls the service layer to check the token sent by the client
+func (h *oauthHandler) Introspect(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	req := new(models.IntrospectionRequest)
+	if err := c.Bind(req); err != nil {
+		c.JSON(http.StatusBadRequest, &models.OAuthError{Code: "invalid_request", Description: "Request must be form encoded"})
+		return err
+	}
+	if id, secret, ok := c.Request().BasicAuth(); ok {
+		req.ClientID, _ = url.QueryUnescape(id)
+		req.ClientSecret, _ = url.QueryUnescape(secret)
+	}
+
+	introspection, err := h.oauthService.Introspect(req)
+	if err != nil {
+		return tokenError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, introspection)
+}
+
+// @Summary Device authorization
+// @Description Issues the device code and the user code which the user enters at the verification uri (RFC 8628)
+// @ID device-authorization
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param client_id formData string false "Client ID, if HTTP Basic authentication isn't used"
+// @Param client_secret formData string false "Secret of the confidential client"
+// @Param scope formData string false "Space separated scopes"
+// @Success 200 {object} models.DeviceCodeResponse
+// @Failure 400 {object} models.OAuthError
+// @Failure 401 {object} models.OAuthError
+// @Failure 500 {object} models.OAuthError
+// @Router /oauth/device/code [post]
+// DeviceAuthorization calls the service layer to issue the device code
+func (h *oauthHandler) DeviceAuthorization(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	req := new(models.DeviceCodeRequest)
+	if err := c.Bind(req); err != nil {
+		c.JSON(http.StatusBadRequest, &models.OAuthError{Code: "invalid_request", Description: "Request must be form encoded"})
+		return err
+	}
+	if id, secret, ok := c.Request().BasicAuth(); ok {
+		req.ClientID, _ = url.QueryUnescape(id)
+		req.ClientSecret, _ = url.QueryUnescape(secret)
+	}
+
+	codes, err := h.oauthService.DeviceAuthorization(req)
+	if err != nil {
+		return tokenError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, codes)
+}
+
+// @Summary Check user code
+// @Description Returns what the device with provided user code requests, presented to the user before the approval
+// @ID check-device-code
+// @Produce  json
+// @Param user_code query string true "User code displayed by the device"
+// @Success 200 {object} models.AuthorizationInfo
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Router /oauth/device [get]
+// CheckDeviceCode calls the service layer to get the pending device authorization
+func (h *oauthHandler) CheckDeviceCode(c echo.Context) error {
+	info, err := h.oauthService.CheckDeviceCode(c.QueryParam("user_code"), getAccessDetails(c))
+	if err != nil {
+		return deviceError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, info)
+}
+
+// @Summary Approve device
+// @Description Approves or denies the device with provided user code
+// @ID approve-device
+// @Accept  json
+// @Produce  json
+// @Param name body models.DeviceApproval true "User code and the decision of the user"
+// @Success 204
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /oauth/device [post]
+// ApproveDevice calls the service layer to store the decision of the user
+func (h *oauthHandler) ApproveDevice(c echo.Context) error {
+	req := new(models.DeviceApproval)
+	if err := c.Bind(req); err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	if err := h.oauthService.ApproveDevice(req, getAccessDetails(c)); err != nil {
+		return deviceError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary User info
+// @Description Returns the claims about the user authenticated with the access token
+// @ID userinfo
+// @Produce  json
+// @Success 200 {object} models.UserInfo
+// @Failure 401 {object} models.Error
+// @Router /oauth/userinfo [get]
+// UserInfo calls the service layer to get the claims about the authenticated user
+func (h *oauthHandler) UserInfo(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.oauthService.UserInfo(getAccessDetails(c)))
+}
+
+// @Summary OpenID Connect discovery
+// @Description Returns the OpenID Connect discovery document
+// @ID openid-configuration
+// @Produce  json
+// @Success 200 {object} models.OpenIDConfiguration
+// @Router /.well-known/openid-configuration [get]
+// GetOpenIDConfiguration calls the service layer to get the discovery document
+func (h *oauthHandler) GetOpenIDConfiguration(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.oauthService.Discovery())
+}
+
+// tokenError writes the OAuth error response (RFC 6749 section 5.2)
+func tokenError(c echo.Context, err error) error {
+	var oauthErr *models.OAuthError
+	if !errors.As(err, &oauthErr) {
+		c.JSON(http.StatusInternalServerError, &models.OAuthError{Code: "server_error", Description: err.Error()})
+		return err
+	}
+	code := http.StatusBadRequest
+	if oauthErr.Code == "invalid_client" {
+		code = http.StatusUnauthorized
+	}
+	c.JSON(code, oauthErr)
+	return err
+}
+
+// deviceError writes the error of the device approval
+func deviceError(c echo.Context, err error) error {
+	errMsg := &models.Error{
+		Code:    http.StatusInternalServerError,
+		Message: err.Error(),
+	}
+	var oauthErr *models.OAuthError
+	if errors.Is(err, service.ErrDeviceCodeNotFound) {
+		errMsg.Code = http.StatusNotFound
+	} else if errors.As(err, &oauthErr) {
+		errMsg.Code = http.StatusBadRequest
+	}
+	c.JSON(errMsg.Code, errMsg)
+	return err
+}
+
+// authorizationError writes the error of the invalid authorization request
+func authorizationError(c echo.Context, err error) error {
+	errMsg := &models.Error{
+		Code:    http.StatusInternalServerError,
+		Message: err.Error(),
+	}
+	var oauthErr *models.OAuthError
+	if errors.As(err, &oauthErr) {
+		errMsg.Code = http.StatusBadRequest
+	}
+	c.JSON(errMsg.Code, errMsg)
+	return err
+}
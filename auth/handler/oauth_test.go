@@ -0,0 +1,282 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+)
+
+func (suite *AuthHandlerTestSuite) TestOAuthFlow() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	oauthService := service.NewOAuthService(mocks.NewMockOAuthRepository(), suite.authService, suite.keys)
+	e := echo.New()
+	NewOAuthHandler(e, oauthService, suite.authService)
+
+	user := suite.generateToken("JohnDoe", false)
+	admin := suite.generateToken("admin", true)
+
+	request := func(method, target, token, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		if contentType != "" {
+			req.Header.Set(echo.HeaderContentType, contentType)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// only admins can register the clients
+	registration := `{"name": "Web", "redirect_uris": ["https://media.example.com/callback"], "confidential": true}`
+	rec := request(http.MethodPost, "/api/v1/auth/oauth/clients", user.AccessToken, echo.MIMEApplicationJSON, registration)
+	suite.Equal(http.StatusForbidden, rec.Code)
+	rec = request(http.MethodPost, "/api/v1/auth/oauth/clients", admin.AccessToken, echo.MIMEApplicationJSON, `{"name": "Web"}`)
+	suite.Equal(http.StatusBadRequest, rec.Code)
+	rec = request(http.MethodPost, "/api/v1/auth/oauth/clients", admin.AccessToken, echo.MIMEApplicationJSON, registration)
+	suite.Require().Equal(http.StatusCreated, rec.Code)
+	client := new(models.RegisteredClient)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), client))
+	suite.NotEmpty(client.ClientSecret)
+
+	verifier := "dBjftJeZ4CVP-mJ0kz4ugigDZa4ZvTQlWJ4Bfp8kz3ZV"
+	sum := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.ClientID},
+		"redirect_uri":          {"https://media.example.com/callback"},
+		"scope":                 {"openid profile"},
+		"state":                 {"xyz"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	// consent screen
+	rec = request(http.MethodGet, "/api/v1/auth/oauth/authorize?"+params.Encode(), "", "", "")
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+	rec = request(http.MethodGet, "/api/v1/auth/oauth/authorize?response_type=token&client_id="+client.ClientID, user.AccessToken, "", "")
+	suite.Equal(http.StatusBadRequest, rec.Code)
+
+	// the API key can't grant the access to the client
+	key, err := suite.authService.CreateAPIKey(&models.AccessDetails{Username: "JohnDoe", IsAdmin: new(bool)},
+		&models.APIKeyRequest{Name: "Home Assistant", Scopes: []string{service.ScopeLibraryRead}})
+	suite.Require().Nil(err)
+	rec = request(http.MethodGet, "/api/v1/auth/oauth/authorize?"+params.Encode(), key.Key, "", "")
+	suite.Equal(http.StatusForbidden, rec.Code)
+	rec = request(http.MethodGet, "/api/v1/auth/oauth/authorize?"+params.Encode(), user.AccessToken, "", "")
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	info := new(models.AuthorizationInfo)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), info))
+	suite.Equal("Web", info.ClientName)
+
+	consent, _ := json.Marshal(map[string]interface{}{
+		"response_type":         "code",
+		"client_id":             client.ClientID,
+		"redirect_uri":          "https://media.example.com/callback",
+		"scope":                 "openid profile",
+		"state":                 "xyz",
+		"code_challenge":        params.Get("code_challenge"),
+		"code_challenge_method": "S256",
+		"approved":              true,
+	})
+	rec = request(http.MethodPost, "/api/v1/auth/oauth/authorize", key.Key, echo.MIMEApplicationJSON, string(consent))
+	suite.Equal(http.StatusForbidden, rec.Code)
+	rec = request(http.MethodPost, "/api/v1/auth/oauth/authorize", user.AccessToken, echo.MIMEApplicationJSON, string(consent))
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	result := new(models.AuthorizationResult)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), result))
+	redirect, err := url.Parse(result.RedirectTo)
+	suite.Require().Nil(err)
+	suite.Equal("media.example.com", redirect.Host)
+	suite.Equal("xyz", redirect.Query().Get("state"))
+
+	// token endpoint with the client_secret_basic authentication
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {redirect.Query().Get("code")},
+		"redirect_uri":  {"https://media.example.com/callback"},
+		"code_verifier": {verifier},
+	}
+	tokenRequest := func(clientID, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		req.SetBasicAuth(clientID, secret)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	rec = tokenRequest(client.ClientID, "wrong")
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+	suite.Contains(rec.Body.String(), `"error":"invalid_client"`)
+
+	rec = tokenRequest(client.ClientID, client.ClientSecret)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.Equal("no-store", rec.Header().Get("Cache-Control"))
+	token := new(models.OAuthTokenResponse)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), token))
+	suite.NotEmpty(token.IDToken)
+
+	rec = tokenRequest(client.ClientID, client.ClientSecret)
+	suite.Equal(http.StatusBadRequest, rec.Code)
+	suite.Contains(rec.Body.String(), `"error":"invalid_grant"`)
+
+	// userinfo
+	rec = request(http.MethodGet, "/api/v1/auth/oauth/userinfo", token.AccessToken, "", "")
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	userInfo := new(models.UserInfo)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), userInfo))
+	suite.Equal("JohnDoe", userInfo.Sub)
+
+	// deleted client can't use its tokens
+	rec = request(http.MethodDelete, "/api/v1/auth/oauth/clients/"+client.ClientID, admin.AccessToken, "", "")
+	suite.Equal(http.StatusNoContent, rec.Code)
+	rec = request(http.MethodDelete, "/api/v1/auth/oauth/clients/"+client.ClientID, admin.AccessToken, "", "")
+	suite.Equal(http.StatusNotFound, rec.Code)
+	form = url.Values{"grant_type": {"refresh_token"}, "refresh_token": {token.RefreshToken}}
+	rec = tokenRequest(client.ClientID, client.ClientSecret)
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+}
+
+func (suite *AuthHandlerTestSuite) TestGetOpenIDConfiguration() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	oauthService := service.NewOAuthService(mocks.NewMockOAuthRepository(), suite.authService, suite.keys)
+	e := echo.New()
+	NewOAuthHandler(e, oauthService, suite.authService)
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+
+	config := new(models.OpenIDConfiguration)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), config))
+	suite.Equal("http://localhost:8003", config.Issuer)
+	suite.Equal("http://localhost:8003/.well-known/jwks.json", config.JWKSURI)
+	suite.Equal("http://localhost:8003/api/v1/auth/oauth/introspect", config.IntrospectionEndpoint)
+	suite.Equal([]string{"S256"}, config.CodeChallengeMethodsSupported)
+}
+
+func (suite *AuthHandlerTestSuite) TestDeviceFlow() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	oauthRepo := mocks.NewMockOAuthRepository()
+	oauthService := service.NewOAuthService(oauthRepo, suite.authService, suite.keys)
+	e := echo.New()
+	NewOAuthHandler(e, oauthService, suite.authService)
+
+	client, err := oauthService.RegisterClient(&models.ClientRegistration{
+		Name:         "Kodi add-on",
+		RedirectURIs: []string{"http://127.0.0.1:8123/callback"},
+	})
+	suite.Require().Nil(err)
+	user := suite.generateToken("JohnDoe", false)
+
+	request := func(method, target, token, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		if contentType != "" {
+			req.Header.Set(echo.HeaderContentType, contentType)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request(http.MethodPost, "/api/v1/auth/oauth/device/code", "", echo.MIMEApplicationForm, "client_id=unknown")
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+	rec = request(http.MethodPost, "/api/v1/auth/oauth/device/code", "", echo.MIMEApplicationForm, "client_id="+client.ClientID+"&scope=library:read+stream")
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	codes := new(models.DeviceCodeResponse)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), codes))
+
+	poll := url.Values{
+		"grant_type":  {service.DeviceCodeGrantType},
+		"device_code": {codes.DeviceCode},
+		"client_id":   {client.ClientID},
+	}.Encode()
+	rec = request(http.MethodPost, "/api/v1/auth/oauth/token", "", echo.MIMEApplicationForm, poll)
+	suite.Equal(http.StatusBadRequest, rec.Code)
+	suite.Contains(rec.Body.String(), `"error":"authorization_pending"`)
+
+	rec = request(http.MethodGet, "/api/v1/auth/oauth/device?user_code=BBBB-BBBB", user.AccessToken, "", "")
+	suite.Equal(http.StatusNotFound, rec.Code)
+	rec = request(http.MethodGet, "/api/v1/auth/oauth/device?user_code="+codes.UserCode, "", "", "")
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+
+	// the API key can't approve the device
+	key, err := suite.authService.CreateAPIKey(&models.AccessDetails{Username: "JohnDoe", IsAdmin: new(bool)},
+		&models.APIKeyRequest{Name: "Home Assistant", Scopes: []string{service.ScopeLibraryRead}})
+	suite.Require().Nil(err)
+	rec = request(http.MethodGet, "/api/v1/auth/oauth/device?user_code="+codes.UserCode, key.Key, "", "")
+	suite.Equal(http.StatusForbidden, rec.Code)
+	rec = request(http.MethodPost, "/api/v1/auth/oauth/device", key.Key, echo.MIMEApplicationJSON,
+		`{"user_code": "`+codes.UserCode+`", "approved": true}`)
+	suite.Equal(http.StatusForbidden, rec.Code)
+
+	rec = request(http.MethodGet, "/api/v1/auth/oauth/device?user_code="+codes.UserCode, user.AccessToken, "", "")
+	suite.Equal(http.StatusOK, rec.Code)
+	rec = request(http.MethodPost, "/api/v1/auth/oauth/device", user.AccessToken, echo.MIMEApplicationJSON,
+		`{"user_code": "`+codes.UserCode+`", "approved": true}`)
+	suite.Equal(http.StatusNoContent, rec.Code)
+
+	for hash := range oauthRepo.Polls {
+		delete(oauthRepo.Polls, hash)
+	}
+	rec = request(http.MethodPost, "/api/v1/auth/oauth/token", "", echo.MIMEApplicationForm, poll)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	token := new(models.OAuthTokenResponse)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), token))
+	suite.NotEmpty(token.AccessToken)
+}
+
+func (suite *AuthHandlerTestSuite) TestIntrospect() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	oauthService := service.NewOAuthService(mocks.NewMockOAuthRepository(), suite.authService, suite.keys)
+	e := echo.New()
+	NewOAuthHandler(e, oauthService, suite.authService)
+
+	client, err := oauthService.RegisterClient(&models.ClientRegistration{
+		Name:         "Reverse proxy",
+		RedirectURIs: []string{"https://media.example.com/callback"},
+		Confidential: true,
+	})
+	suite.Require().Nil(err)
+	user := suite.generateToken("JohnDoe", false)
+
+	introspect := func(token, secret string) *httptest.ResponseRecorder {
+		form := url.Values{"token": {token}}
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/oauth/introspect", strings.NewReader(form.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		req.SetBasicAuth(client.ClientID, secret)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := introspect(user.AccessToken, "wrong")
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+
+	rec = introspect(user.AccessToken, client.ClientSecret)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.Equal("no-store", rec.Header().Get("Cache-Control"))
+	introspection := new(models.Introspection)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), introspection))
+	suite.True(introspection.Active)
+	suite.Equal("JohnDoe", introspection.Subject)
+
+	rec = introspect("invalid", client.ClientSecret)
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.JSONEq(`{"active": false}`, rec.Body.String())
+}
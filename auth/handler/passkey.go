@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+)
+
+type passkeyHandler struct {
+	passkeyService service.PasskeyService
+}
+
+// NewPasskeyHandler initiates the handlers of the passkey registration and login
+func NewPasskeyHandler(router *echo.Echo, passkeyService service.PasskeyService, authService service.AuthService) {
+	handler := &passkeyHandler{passkeyService}
+	auth := authenticate(authService)
+
+	router.POST("/api/v1/auth/passkeys/login/begin", handler.BeginLogin)
+	router.POST("/api/v1/auth/passkeys/login/finish", handler.FinishLogin)
+
+	passkeys := router.Group("/api/v1/auth/passkeys", auth, requireAccessToken)
+	passkeys.POST("/register/begin", handler.BeginRegistration)
+	passkeys.POST("/register/finish", handler.FinishRegistration)
+	passkeys.GET("", handler.GetPasskeys)
+	passkeys.DELETE("/:id", handler.DeletePasskey)
+	router.DELETE("/api/v1/auth/password", handler.RemovePassword, auth, requireAccessToken)
+}
+
+// passkeyError responds with the status code matching the error of the passkey service
+func passkeyError(c echo.Context, err error) error {
+	errMsg := &models.Error{
+		Code:    http.StatusInternalServerError,
+		Message: err.Error(),
+	}
+	switch {
+	case errors.Is(err, service.ErrPasskeyInvalid):
+		errMsg.Code = http.StatusBadRequest
+	case errors.Is(err, service.ErrPasskeyRejected):
+		errMsg.Code = http.StatusUnauthorized
+	case errors.Is(err, service.ErrPasskeyNotFound):
+		errMsg.Code = http.StatusNotFound
+	case errors.Is(err, service.ErrLastCredential):
+		errMsg.Code = http.StatusConflict
+	}
+	c.JSON(errMsg.Code, errMsg)
+	return err
+}
+
+// @Summary Start passkey registration
+// @Description Returns the options for navigator.credentials.create, the challenge expires after 5 minutes
+// @ID begin-passkey-registration
+// @Produce  json
+// @Success 200 {object} models.PublicKeyCredentialCreationOptions
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /passkeys/register/begin [post]
+// BeginRegistration calls the service layer to create the registration challenge
+func (h *passkeyHandler) BeginRegistration(c echo.Context) error {
+	options, err := h.passkeyService.BeginRegistration(getAccessDetails(c))
+	if err != nil {
+		return passkeyError(c, err)
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, options)
+}
+
+// @Summary Finish passkey registration
+// @Description Verifies the new credential created by the authenticator and adds it to the account
+// @ID finish-passkey-registration
+// @Accept  json
+// @Produce  json
+// @Param name body models.PasskeyRegistration true "Credential serialized with PublicKeyCredential.toJSON and its optional name"
+// @Success 201 {object} models.Passkey
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /passkeys/register/finish [post]
+// FinishRegistration calls the service layer to verify and store the new passkey
+func (h *passkeyHandler) FinishRegistration(c echo.Context) error {
+	registration := new(models.PasskeyRegistration)
+	if err := c.Bind(registration); err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	passkey, err := h.passkeyService.FinishRegistration(getAccessDetails(c), registration)
+	if err != nil {
+		return passkeyError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, passkey)
+}
+
+// @Summary Start passkey login
+// @Description Returns the options for navigator.credentials.get, without the username any passkey stored on the device can be used
+// @ID begin-passkey-login
+// @Accept  json
+// @Produce  json
+// @Param name body models.PasskeyLoginRequest false "Optional username"
+// @Success 200 {object} models.PublicKeyCredentialRequestOptions
+// @Failure 500 {object} models.Error
+// @Router /passkeys/login/begin [post]
+// BeginLogin calls the service layer to create the login challenge
+func (h *passkeyHandler) BeginLogin(c echo.Context) error {
+	req := new(models.PasskeyLoginRequest)
+	// the body is optional
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(req); err != nil {
+			errMsg := &models.Error{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			}
+			c.JSON(errMsg.Code, errMsg)
+			return err
+		}
+	}
+
+	options, err := h.passkeyService.BeginLogin(req)
+	if err != nil {
+		return passkeyError(c, err)
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, options)
+}
+
+// @Summary Finish passkey login
+// @Description Verifies the signed challenge and returns the access and refresh token of the owner of the passkey
+// @ID finish-passkey-login
+// @Accept  json
+// @Produce  json
+// @Param X-Session-Mode header string false "cookie - the refresh token is set in the HttpOnly cookie"
+// @Param name body models.PasskeyAssertion true "Assertion serialized with PublicKeyCredential.toJSON"
+// @Success 200 {object} models.TokenDetails
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /passkeys/login/finish [post]
+// FinishLogin calls the service layer to sign in the user with the passkey
+func (h *passkeyHandler) FinishLogin(c echo.Context) error {
+	errMsg := new(models.Error)
+	assertion := new(models.PasskeyAssertion)
+	if err := c.Bind(assertion); err != nil {
+		errMsg.Code = http.StatusBadRequest
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	token, err := h.passkeyService.FinishLogin(assertion, clientInfo(c))
+	if err != nil {
+		return passkeyError(c, err)
+	}
+	if cookieMode(c) {
+		if err := setSessionCookies(c, token); err != nil {
+			errMsg.Code = http.StatusInternalServerError
+			errMsg.Message = "Couldn't generate the CSRF token"
+			c.JSON(errMsg.Code, errMsg)
+			return err
+		}
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, token)
+}
+
+// @Summary Get passkeys
+// @Description Returns the passkeys of the authenticated user
+// @ID get-passkeys
+// @Produce  json
+// @Success 200 {array} models.Passkey
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /passkeys [get]
+// GetPasskeys calls the service layer to get the passkeys of the user
+func (h *passkeyHandler) GetPasskeys(c echo.Context) error {
+	passkeys, err := h.passkeyService.GetPasskeys(getAccessDetails(c).Username)
+	if err != nil {
+		return passkeyError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, passkeys)
+}
+
+// @Summary Delete passkey
+// @Description Deletes the passkey of the authenticated user, the last passkey of the account without the password is kept
+// @ID delete-passkey
+// @Produce  json
+// @Param id path string true "Passkey id"
+// @Success 204
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /passkeys/{id} [delete]
+// DeletePasskey calls the service layer to delete the passkey
+func (h *passkeyHandler) DeletePasskey(c echo.Context) error {
+	if err := h.passkeyService.DeletePasskey(getAccessDetails(c).Username, c.Param("id")); err != nil {
+		return passkeyError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Remove password
+// @Description Removes the password of the authenticated user, afterwards the user signs in only with the passkeys
+// @ID remove-password
+// @Produce  json
+// @Success 204
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /password [delete]
+// RemovePassword calls the service layer to make the account passwordless
+func (h *passkeyHandler) RemovePassword(c echo.Context) error {
+	if err := h.passkeyService.RemovePassword(getAccessDetails(c).Username); err != nil {
+		return passkeyError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+)
+
+func (suite *AuthHandlerTestSuite) TestPasskeys() {
+	common.Config.WebAuthnRPID = "localhost"
+	common.Config.WebAuthnOrigins = []string{"http://localhost:3000"}
+	common.Config.CookieMode = true
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	users := mocks.NewMockPasskeyUserService("JohnDoe")
+	e := echo.New()
+	NewPasskeyHandler(e, service.NewPasskeyService(users.Client(), mocks.NewMockWebAuthnRepository(), suite.authService), suite.authService)
+	authenticator, err := mocks.NewMockAuthenticator("localhost", "http://localhost:3000")
+	suite.Require().Nil(err)
+
+	user := suite.generateToken("JohnDoe", false)
+
+	request := func(method, target, token string, body interface{}, header http.Header) *httptest.ResponseRecorder {
+		var payload string
+		if body != nil {
+			data, err := json.Marshal(body)
+			suite.Require().Nil(err)
+			payload = string(data)
+		}
+		req := httptest.NewRequest(method, target, strings.NewReader(payload))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request(http.MethodPost, "/api/v1/auth/passkeys/register/begin", "", nil, nil)
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+
+	// register the passkey
+	rec = request(http.MethodPost, "/api/v1/auth/passkeys/register/begin", user.AccessToken, nil, nil)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.Equal("no-store", rec.Header().Get("Cache-Control"))
+	creationOptions := new(models.PublicKeyCredentialCreationOptions)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), creationOptions))
+	registration, err := authenticator.Register(creationOptions, "Laptop")
+	suite.Require().Nil(err)
+	rec = request(http.MethodPost, "/api/v1/auth/passkeys/register/finish", user.AccessToken, registration, nil)
+	suite.Require().Equal(http.StatusCreated, rec.Code)
+
+	// the same response can't be used again
+	rec = request(http.MethodPost, "/api/v1/auth/passkeys/register/finish", user.AccessToken, registration, nil)
+	suite.Equal(http.StatusBadRequest, rec.Code)
+
+	rec = request(http.MethodGet, "/api/v1/auth/passkeys", user.AccessToken, nil, nil)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.Contains(rec.Body.String(), `"name":"Laptop"`)
+	suite.NotContains(rec.Body.String(), "public_key")
+
+	// sign in with the discoverable credential, the body is optional
+	rec = request(http.MethodPost, "/api/v1/auth/passkeys/login/begin", "", nil, nil)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	requestOptions := new(models.PublicKeyCredentialRequestOptions)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), requestOptions))
+	assertion, err := authenticator.Login(requestOptions, "JohnDoe")
+	suite.Require().Nil(err)
+	rec = request(http.MethodPost, "/api/v1/auth/passkeys/login/finish", "", assertion, nil)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	token := new(models.TokenDetails)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), token))
+	details, err := suite.authService.ValidateToken(token.AccessToken)
+	suite.Require().Nil(err)
+	suite.Equal("JohnDoe", details.Username)
+
+	// replayed assertion is rejected
+	rec = request(http.MethodPost, "/api/v1/auth/passkeys/login/finish", "", assertion, nil)
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+
+	// the refresh token is set in the cookie in the cookie mode
+	rec = request(http.MethodPost, "/api/v1/auth/passkeys/login/begin", "", &models.PasskeyLoginRequest{Username: "JohnDoe"}, nil)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), requestOptions))
+	suite.Len(requestOptions.AllowCredentials, 1)
+	assertion, err = authenticator.Login(requestOptions, "")
+	suite.Require().Nil(err)
+	rec = request(http.MethodPost, "/api/v1/auth/passkeys/login/finish", "", assertion, http.Header{SessionModeHeader: {"cookie"}})
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.NotContains(rec.Body.String(), "refresh_token")
+	suite.NotNil(findCookie(rec, refreshCookieName))
+
+	// the account becomes passwordless, its only passkey is kept
+	rec = request(http.MethodDelete, "/api/v1/auth/password", user.AccessToken, nil, nil)
+	suite.Equal(http.StatusNoContent, rec.Code)
+	rec = request(http.MethodDelete, "/api/v1/auth/passkeys/"+authenticator.ID(), user.AccessToken, nil, nil)
+	suite.Equal(http.StatusConflict, rec.Code)
+	rec = request(http.MethodDelete, "/api/v1/auth/passkeys/unknown", user.AccessToken, nil, nil)
+	suite.Equal(http.StatusNotFound, rec.Code)
+}
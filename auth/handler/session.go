@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+)
+
+// @Summary Get sessions
+// @Description Returns the sessions of the authenticated user or, for admins, the sessions of the user from the path
+// @ID get-sessions
+// @Produce  json
+// @Param username path string false "Username, admin only"
+// @Success 200 {array} models.Session
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /sessions [get]
+// @Router /users/{username}/sessions [get]
+// GetSessions calls the service layer to get the sessions of the user
+func (h *authHandler) GetSessions(c echo.Context) error {
+	username, currentID := sessionOwner(c)
+	sessions, err := h.authService.GetSessions(username, currentID)
+	if err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, sessions)
+}
+
+// @Summary Revoke session
+// @Description Revokes the session of the authenticated user or, for admins, the session of the user from the path
+// @ID revoke-session
+// @Produce  json
+// @Param username path string false "Username, admin only"
+// @Param id path string true "Session ID"
+// @Success 204
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Router /sessions/{id} [delete]
+// @Router /users/{username}/sessions/{id} [delete]
+// RevokeSession calls the service layer to revoke the session with provided id
+func (h *authHandler) RevokeSession(c echo.Context) error {
+	username, _ := sessionOwner(c)
+	if err := h.authService.RevokeSession(username, c.Param("id")); err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		if errors.Is(err, service.ErrSessionNotFound) {
+			errMsg.Code = http.StatusNotFound
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Revoke sessions
+// @Description Revokes all of the sessions of the user except the current one
+// @ID revoke-sessions
+// @Produce  json
+// @Param username path string false "Username, admin only"
+// @Success 204
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /sessions [delete]
+// @Router /users/{username}/sessions [delete]
+// RevokeSessions calls the service layer to revoke the sessions of the user
+func (h *authHandler) RevokeSessions(c echo.Context) error {
+	username, currentID := sessionOwner(c)
+	if err := h.authService.RevokeSessions(username, currentID); err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// sessionOwner returns the username whose sessions are managed and the id of
+// the current session if the user manages their own sessions
+func sessionOwner(c echo.Context) (string, string) {
+	accessDetails := getAccessDetails(c)
+	username := resourceOwner(c)
+	if username == accessDetails.Username {
+		return username, accessDetails.Family
+	}
+	return username, ""
+}
+
+// resourceOwner returns the username from the path of the admin routes or
+// the username of the authenticated user
+func resourceOwner(c echo.Context) string {
+	if username := c.Param("username"); username != "" {
+		return username
+	}
+	return getAccessDetails(c).Username
+}
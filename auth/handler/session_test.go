@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+)
+
+func (suite *AuthHandlerTestSuite) TestSessions() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	e := echo.New()
+	NewAuthHandler(e, suite.authService)
+
+	current := suite.generateToken("JohnDoe", false)
+	tv := suite.generateToken("JohnDoe", false)
+	phone := suite.generateToken("JohnDoe", false)
+	admin := suite.generateToken("admin", true)
+
+	request := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	testCases := []struct {
+		name               string
+		method             string
+		target             string
+		token              string
+		expectedStatusCode int
+	}{
+		{
+			name:               "Missing token",
+			method:             http.MethodGet,
+			target:             "/api/v1/auth/sessions",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Refresh token instead of access token",
+			method:             http.MethodGet,
+			target:             "/api/v1/auth/sessions",
+			token:              current.RefreshToken,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Get own sessions",
+			method:             http.MethodGet,
+			target:             "/api/v1/auth/sessions",
+			token:              current.AccessToken,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Revoke unknown session",
+			method:             http.MethodDelete,
+			target:             "/api/v1/auth/sessions/non-existent",
+			token:              current.AccessToken,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Revoke own session",
+			method:             http.MethodDelete,
+			target:             "/api/v1/auth/sessions/" + tv.FamilyID,
+			token:              current.AccessToken,
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "Revoked session can't be used",
+			method:             http.MethodGet,
+			target:             "/api/v1/auth/sessions",
+			token:              tv.AccessToken,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Revoke session of another user",
+			method:             http.MethodDelete,
+			target:             "/api/v1/auth/sessions/" + admin.FamilyID,
+			token:              current.AccessToken,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Admin route as user",
+			method:             http.MethodGet,
+			target:             "/api/v1/auth/users/admin/sessions",
+			token:              current.AccessToken,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Revoke all other sessions",
+			method:             http.MethodDelete,
+			target:             "/api/v1/auth/sessions",
+			token:              current.AccessToken,
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "Other session is revoked",
+			method:             http.MethodGet,
+			target:             "/api/v1/auth/sessions",
+			token:              phone.AccessToken,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Admin gets user sessions",
+			method:             http.MethodGet,
+			target:             "/api/v1/auth/users/JohnDoe/sessions",
+			token:              admin.AccessToken,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Admin revokes all user sessions",
+			method:             http.MethodDelete,
+			target:             "/api/v1/auth/users/JohnDoe/sessions",
+			token:              admin.AccessToken,
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "Current session is revoked by admin",
+			method:             http.MethodGet,
+			target:             "/api/v1/auth/sessions",
+			token:              current.AccessToken,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			rec := request(tt.method, tt.target, tt.token)
+			suite.Equal(tt.expectedStatusCode, rec.Code)
+		})
+	}
+
+	// admin keeps the current session when revoking their own sessions
+	rec := request(http.MethodDelete, "/api/v1/auth/users/admin/sessions", admin.AccessToken)
+	suite.Equal(http.StatusNoContent, rec.Code)
+	rec = request(http.MethodGet, "/api/v1/auth/users/admin/sessions", admin.AccessToken)
+	suite.Equal(http.StatusOK, rec.Code)
+	sessions := []*models.Session{}
+	suite.Nil(json.NewDecoder(rec.Body).Decode(&sessions))
+	suite.Len(sessions, 1)
+	suite.True(sessions[0].Current)
+}
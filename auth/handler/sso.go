@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/labstack/echo"
+)
+
+type ssoHandler struct {
+	ssoService service.SSOService
+}
+
+// NewSSOHandler initiates the handlers of the sign in with the external identity providers
+func NewSSOHandler(router *echo.Echo, ssoService service.SSOService, authService service.AuthService) {
+	handler := &ssoHandler{ssoService}
+
+	router.GET("/api/v1/auth/sso/providers", handler.GetProviders)
+	router.POST("/api/v1/auth/sso/:provider/authorize", handler.Authorize)
+	router.POST("/api/v1/auth/sso/:provider/link", handler.Link, authenticate(authService), requireAccessToken)
+	router.POST("/api/v1/auth/sso/:provider/callback", handler.Callback)
+}
+
+// @Summary Get identity providers
+// @Description Returns the external identity providers the users can sign in with
+// @ID get-sso-providers
+// @Produce  json
+// @Success 200 {array} models.SSOProvider
+// @Router /sso/providers [get]
+// GetProviders returns the configured identity providers
+func (h *ssoHandler) GetProviders(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.ssoService.Providers())
+}
+
+// @Summary Start sign in with identity provider
+// @Description Returns the authorization URL of the identity provider, the frontend redirects the user there and keeps the state for the callback page
+// @ID sso-authorize
+// @Produce  json
+// @Param provider path string true "Name of the identity provider"
+// @Success 200 {object} models.SSOAuthorization
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /sso/{provider}/authorize [post]
+// Authorize calls the service layer to start the sign in with the identity provider
+func (h *ssoHandler) Authorize(c echo.Context) error {
+	authorization, err := h.ssoService.Authorize(c.Param("provider"))
+	if err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		if errors.Is(err, service.ErrSSOProviderNotFound) {
+			errMsg.Code = http.StatusNotFound
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, authorization)
+}
+
+// @Summary Link identity provider
+// @Description Returns the authorization URL of the identity provider, the callback links the identity with the account of the signed in user
+// @ID sso-link
+// @Produce  json
+// @Param provider path string true "Name of the identity provider"
+// @Param Authorization header string true "Bearer access token"
+// @Success 200 {object} models.SSOAuthorization
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /sso/{provider}/link [post]
+// Link calls the service layer to start linking the identity provider with the account of the user
+func (h *ssoHandler) Link(c echo.Context) error {
+	accessDetails := getAccessDetails(c)
+	authorization, err := h.ssoService.Link(c.Param("provider"), accessDetails.Username)
+	if err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		if errors.Is(err, service.ErrSSOProviderNotFound) {
+			errMsg.Code = http.StatusNotFound
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, authorization)
+}
+
+// @Summary Finish sign in with identity provider
+// @Description Exchanges the code the identity provider redirected the user with for the access and refresh token
+// @ID sso-callback
+// @Accept  json
+// @Produce  json
+// @Param provider path string true "Name of the identity provider"
+// @Param X-Session-Mode header string false "cookie - the refresh token is set in the HttpOnly cookie"
+// @Param name body models.SSOCallback true "Code and state from the callback URL"
+// @Success 200 {object} models.TokenDetails
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.MFARequired
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /sso/{provider}/callback [post]
+// Callback calls the service layer to sign in the user with the identity provider
+func (h *ssoHandler) Callback(c echo.Context) error {
+	errMsg := new(models.Error)
+	callback := new(models.SSOCallback)
+	if err := c.Bind(callback); err != nil {
+		errMsg.Code = http.StatusBadRequest
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	token, err := h.ssoService.Callback(c.Param("provider"), callback, clientInfo(c))
+	if err != nil {
+		var mfaErr *service.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			mfaRequired(c, mfaErr)
+			return err
+		}
+		errMsg.Code = http.StatusInternalServerError
+		errMsg.Message = err.Error()
+		switch {
+		case errors.Is(err, service.ErrSSOProviderNotFound):
+			errMsg.Code = http.StatusNotFound
+		case errors.Is(err, service.ErrSSOStateInvalid):
+			errMsg.Code = http.StatusBadRequest
+		case errors.Is(err, service.ErrSSOLoginRejected):
+			errMsg.Code = http.StatusUnauthorized
+		case errors.Is(err, service.ErrSSOUserNotFound):
+			errMsg.Code = http.StatusForbidden
+		case errors.Is(err, service.ErrSSOAccountNotLinked), errors.Is(err, service.ErrSSOIdentityLinked):
+			errMsg.Code = http.StatusConflict
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+	if cookieMode(c) {
+		if err := setSessionCookies(c, token); err != nil {
+			errMsg.Code = http.StatusInternalServerError
+			errMsg.Message = "Couldn't generate the CSRF token"
+			c.JSON(errMsg.Code, errMsg)
+			return err
+		}
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, token)
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+)
+
+func (suite *AuthHandlerTestSuite) TestSSO() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	idp, err := mocks.NewMockOIDCProvider("x-media", "client_secret")
+	suite.Require().Nil(err)
+	defer idp.Close()
+	common.Config.CookieMode = true
+	common.Config.OIDCProviders = map[string]common.OIDCProvider{
+		"stub": {
+			Issuer:       idp.URL,
+			ClientID:     "x-media",
+			ClientSecret: "client_secret",
+			RedirectURL:  "http://localhost:3000/sso/callback",
+			AutoCreate:   false,
+		},
+	}
+	// only JohnDoe, dave with the second factor and mallory with the password have the local account
+	client := &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Host != "xmedia-user-svc:8002" {
+				return http.DefaultClient.Do(req)
+			}
+			body, _ := ioutil.ReadAll(req.Body)
+			response := func(code int, body string) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: code,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+				}, nil
+			}
+			switch {
+			case strings.Contains(string(body), `"username":"JohnDoe"`):
+				return response(200, `{"username": "JohnDoe", "is_admin": false}`)
+			case strings.Contains(string(body), `"username":"dave"`):
+				return response(200, `{"username": "dave", "is_admin": false, "mfa_required": true}`)
+			case strings.Contains(string(body), `"username":"mallory"`) && strings.Contains(string(body), `"without_password":true`):
+				return response(409, `{"code": 409, "message": "User signs in with the password"}`)
+			case strings.Contains(string(body), `"username":"mallory"`):
+				return response(200, `{"username": "mallory", "is_admin": false}`)
+			}
+			return response(404, `{"code": 404, "message": "User not found"}`)
+		},
+	}
+	e := echo.New()
+	NewSSOHandler(e, service.NewSSOService(client, mocks.NewMockSSORepository(), suite.authService), suite.authService)
+
+	request := func(method, target, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	link := func(username, accessToken string) (string, string) {
+		header := http.Header{}
+		path := "/api/v1/auth/sso/stub/authorize"
+		if accessToken != "" {
+			header.Set(echo.HeaderAuthorization, "Bearer "+accessToken)
+			path = "/api/v1/auth/sso/stub/link"
+		}
+		rec := request(http.MethodPost, path, "", header)
+		suite.Require().Equal(http.StatusOK, rec.Code)
+		authorization := new(models.SSOAuthorization)
+		suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), authorization))
+		code, err := idp.Authorize(authorization.AuthorizationURL, jwt.MapClaims{"preferred_username": username})
+		suite.Require().Nil(err)
+		return code, authorization.State
+	}
+
+	signIn := func(username string) (string, string) {
+		return link(username, "")
+	}
+	rec := request(http.MethodGet, "/api/v1/auth/sso/providers", "", nil)
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.JSONEq(`[{"name": "stub", "display_name": "stub"}]`, rec.Body.String())
+
+	rec = request(http.MethodPost, "/api/v1/auth/sso/github/authorize", "", nil)
+	suite.Equal(http.StatusNotFound, rec.Code)
+
+	// existing user gets the token pair
+	code, state := signIn("JohnDoe")
+	rec = request(http.MethodPost, "/api/v1/auth/sso/stub/callback", `{"code": "`+code+`", "state": "`+state+`"}`, nil)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	token := new(models.TokenDetails)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), token))
+	suite.NotEmpty(token.AccessToken)
+	suite.NotEmpty(token.RefreshToken)
+
+	// the state can't be used again
+	rec = request(http.MethodPost, "/api/v1/auth/sso/stub/callback", `{"code": "`+code+`", "state": "`+state+`"}`, nil)
+	suite.Equal(http.StatusBadRequest, rec.Code)
+
+	// the refresh token is set in the cookie in the cookie mode
+	code, state = signIn("JohnDoe")
+	rec = request(http.MethodPost, "/api/v1/auth/sso/stub/callback", `{"code": "`+code+`", "state": "`+state+`"}`,
+		http.Header{SessionModeHeader: {"cookie"}})
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.NotContains(rec.Body.String(), "refresh_token")
+	suite.NotNil(findCookie(rec, refreshCookieName))
+
+	// unknown users aren't created
+	code, state = signIn("stranger")
+	rec = request(http.MethodPost, "/api/v1/auth/sso/stub/callback", `{"code": "`+code+`", "state": "`+state+`"}`, nil)
+	suite.Equal(http.StatusForbidden, rec.Code)
+
+	// the second factor of the local account is required
+	code, state = signIn("dave")
+	rec = request(http.MethodPost, "/api/v1/auth/sso/stub/callback", `{"code": "`+code+`", "state": "`+state+`"}`, nil)
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+	suite.Contains(rec.Body.String(), `"mfa_required":true`)
+	suite.NotContains(rec.Body.String(), "access_token")
+
+	// the account with the password has to be linked by the signed in user first
+	code, state = signIn("mallory")
+	rec = request(http.MethodPost, "/api/v1/auth/sso/stub/callback", `{"code": "`+code+`", "state": "`+state+`"}`, nil)
+	suite.Equal(http.StatusConflict, rec.Code)
+	rec = request(http.MethodPost, "/api/v1/auth/sso/stub/link", "", nil)
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+	code, state = link("mallory", suite.generateToken("mallory", false).AccessToken)
+	rec = request(http.MethodPost, "/api/v1/auth/sso/stub/callback", `{"code": "`+code+`", "state": "`+state+`"}`, nil)
+	suite.Equal(http.StatusOK, rec.Code)
+	code, state = signIn("mallory")
+	rec = request(http.MethodPost, "/api/v1/auth/sso/stub/callback", `{"code": "`+code+`", "state": "`+state+`"}`, nil)
+	suite.Equal(http.StatusOK, rec.Code)
+
+	// the code is rejected by the provider
+	_, state = signIn("JohnDoe")
+	rec = request(http.MethodPost, "/api/v1/auth/sso/stub/callback", `{"code": "forged", "state": "`+state+`"}`, nil)
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+
+	rec = request(http.MethodPost, "/api/v1/auth/sso/stub/callback", `{"code": "code"}`, nil)
+	suite.Equal(http.StatusBadRequest, rec.Code)
+}
@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/0x113/x-media/auth/common"
 	"github.com/0x113/x-media/auth/models"
 	"github.com/0x113/x-media/auth/service"
 
@@ -18,6 +20,8 @@ type authHandler struct {
 // NewAuthHandler initiates authentication handlers
 func NewAuthHandler(router *echo.Echo, authService service.AuthService) {
 	handler := &authHandler{authService}
+	auth := authenticate(authService)
+	manageUsers := requireScope(service.ScopeUsersManage)
 	// swagger
 	opts := middleware.RedocOpts{SpecURL: "/swagger.yaml"}
 	sh := middleware.Redoc(opts, nil)
@@ -25,8 +29,36 @@ func NewAuthHandler(router *echo.Echo, authService service.AuthService) {
 	router.GET("/docs", echo.WrapHandler(sh))
 
 	router.POST("/api/v1/auth/token/generate", handler.GenerateToken)
+	router.POST("/api/v1/auth/token/mfa", handler.VerifyMFA)
 	router.POST("/api/v1/auth/token/validate", handler.GetTokenMetadata)
 	router.POST("/api/v1/auth/token/refresh", handler.RefreshToken)
+	router.POST("/api/v1/auth/token/logout", handler.Logout)
+	router.GET("/.well-known/jwks.json", handler.GetJWKS)
+
+	sessions := router.Group("/api/v1/auth/sessions", auth, requireAccessToken)
+	sessions.GET("", handler.GetSessions)
+	sessions.DELETE("", handler.RevokeSessions)
+	sessions.DELETE("/:id", handler.RevokeSession)
+
+	userSessions := router.Group("/api/v1/auth/users/:username/sessions", auth, manageUsers, requireAccessToken)
+	userSessions.GET("", handler.GetSessions)
+	userSessions.DELETE("", handler.RevokeSessions)
+	userSessions.DELETE("/:id", handler.RevokeSession)
+
+	keys := router.Group("/api/v1/auth/keys", auth, requireAccessToken)
+	keys.GET("", handler.GetAPIKeys)
+	keys.POST("", handler.CreateAPIKey)
+	keys.DELETE("/:id", handler.RevokeAPIKey)
+
+	userKeys := router.Group("/api/v1/auth/users/:username/keys", auth, manageUsers, requireAccessToken)
+	userKeys.GET("", handler.GetAPIKeys)
+	userKeys.DELETE("/:id", handler.RevokeAPIKey)
+
+	router.DELETE("/api/v1/auth/users/:username/lockout", handler.UnlockUser, auth, manageUsers)
+	router.DELETE("/api/v1/auth/ips/:ip/lockout", handler.UnlockIP, auth, manageUsers)
+
+	router.DELETE("/api/v1/auth/internal/users/:username/sessions", handler.RevokeUserSessions, requireInternalSecret)
+	router.DELETE("/api/v1/auth/internal/users/:username/api-keys", handler.RevokeUserAPIKeys, requireInternalSecret)
 }
 
 // @Summary Generate token
@@ -35,8 +67,11 @@ func NewAuthHandler(router *echo.Echo, authService service.AuthService) {
 // @Accept  json
 // @Produce  json
 // @Param name body generateTokenPayload true "User credentials"
+// @Param X-Session-Mode header string false "cookie to receive the refresh token in the HttpOnly cookie"
 // @Success 200 {object} models.TokenDetails
 // @Failure 400 {object} models.Error
+// @Failure 401 {object} models.MFARequired
+// @Failure 429 {object} models.Error
 // @Failure 500 {object} models.Error
 // @Router /generate [post]
 // GenerateToken calls the service layer and generates new JSON Web Token
@@ -50,45 +85,160 @@ func (h *authHandler) GenerateToken(c echo.Context) error {
 		return err
 	}
 
-	token, err := h.authService.Login(creds)
+	token, err := h.authService.Login(creds, clientInfo(c))
 	if err != nil {
 		errMsg.Code = http.StatusInternalServerError
 		errMsg.Message = err.Error()
+		var rateLimitErr *service.RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			errMsg.Code = http.StatusTooManyRequests
+			c.Response().Header().Set("Retry-After", strconv.Itoa(rateLimitErr.RetryAfterSeconds()))
+		}
+		var mfaErr *service.MFARequiredError
+		if errors.As(err, &mfaErr) {
+			mfaRequired(c, mfaErr)
+			return err
+		}
 		c.JSON(errMsg.Code, errMsg)
 		return err
 	}
+	if cookieMode(c) {
+		if err := setSessionCookies(c, token); err != nil {
+			errMsg.Code = http.StatusInternalServerError
+			errMsg.Message = "Couldn't generate the CSRF token"
+			c.JSON(errMsg.Code, errMsg)
+			return err
+		}
+	}
 
 	return c.JSON(http.StatusOK, token)
 }
 
+// mfaRequired writes the MFA token the client finishes the login with
+func mfaRequired(c echo.Context, mfaErr *service.MFARequiredError) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusUnauthorized, &models.MFARequired{
+		Code:        http.StatusUnauthorized,
+		Message:     mfaErr.Error(),
+		MFARequired: true,
+		MFAToken:    mfaErr.Token,
+		ExpiresIn:   int(mfaErr.ExpiresIn / time.Second),
+	})
+}
+
+// @Summary Verify second factor
+// @Description Finishes the login of the user with the two-factor authentication, the MFA token is returned by the generate endpoint along with 401
+// @ID verify-mfa
+// @Accept  json
+// @Produce  json
+// @Param name body models.MFALogin true "MFA token and the code from the authenticator app or one of the recovery codes"
+// @Param X-Session-Mode header string false "cookie to receive the refresh token in the HttpOnly cookie"
+// @Success 200 {object} models.TokenDetails
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 429 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /mfa [post]
+// VerifyMFA calls the service layer to check the second factor and generates
+// new JSON Web Token
+func (h *authHandler) VerifyMFA(c echo.Context) error {
+	errMsg := new(models.Error)
+	req := new(models.MFALogin)
+	if err := c.Bind(req); err != nil {
+		errMsg.Code = http.StatusBadRequest
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	token, err := h.authService.VerifyMFA(req, clientInfo(c))
+	if err != nil {
+		errMsg.Code = http.StatusInternalServerError
+		errMsg.Message = err.Error()
+		if errors.Is(err, service.ErrInvalidMFACode) || errors.Is(err, service.ErrMFAChallengeInvalid) {
+			errMsg.Code = http.StatusUnauthorized
+		}
+		var rateLimitErr *service.RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			errMsg.Code = http.StatusTooManyRequests
+			c.Response().Header().Set("Retry-After", strconv.Itoa(rateLimitErr.RetryAfterSeconds()))
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+	if cookieMode(c) {
+		if err := setSessionCookies(c, token); err != nil {
+			errMsg.Code = http.StatusInternalServerError
+			errMsg.Message = "Couldn't generate the CSRF token"
+			c.JSON(errMsg.Code, errMsg)
+			return err
+		}
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, token)
+}
+
 // @Summary Refresh token
 // @Description Generates new access and refresh token for the user
 // @ID refresh-token
 // @Accept  json
 // @Produce  json
-// @Param name body models.TokenString true "Refresh token"
+// @Param name body models.TokenString false "Refresh token, not used in the cookie mode"
+// @Param X-Session-Mode header string false "cookie to read the refresh token from the cookie"
+// @Param X-CSRF-Token header string false "Value of the xmedia_csrf cookie, required in the cookie mode"
 // @Success 200 {object} models.TokenDetails
 // @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
 // @Failure 500 {object} models.Error
 // @Router /refresh [post]
-// RefreshToken calls the service layer to generate new access and refresh token
+// RefreshToken calls the service layer to generate new access and refresh
+// token. In the cookie mode the refresh token is read from the cookie and the
+// request must carry the CSRF token.
 func (h *authHandler) RefreshToken(c echo.Context) error {
 	errMsg := new(models.Error)
 	ts := new(models.TokenString)
-	if err := c.Bind(ts); err != nil {
+	if cookieMode(c) {
+		if err := checkCSRF(c); err != nil {
+			errMsg.Code = http.StatusForbidden
+			errMsg.Message = err.Error()
+			c.JSON(errMsg.Code, errMsg)
+			return err
+		}
+		refresh, err := refreshCookie(c)
+		if err != nil {
+			errMsg.Code = http.StatusUnauthorized
+			errMsg.Message = err.Error()
+			c.JSON(errMsg.Code, errMsg)
+			return err
+		}
+		ts.Token = refresh
+	} else if err := c.Bind(ts); err != nil {
 		errMsg.Code = http.StatusBadRequest
 		errMsg.Message = err.Error()
 		c.JSON(errMsg.Code, errMsg)
 		return err
 	}
 
-	token, err := h.authService.Refresh(ts.Token)
+	token, err := h.authService.Refresh(ts.Token, clientInfo(c))
 	if err != nil {
 		errMsg.Code = http.StatusInternalServerError
+		if errors.Is(err, service.ErrTokenRevoked) || errors.Is(err, service.ErrTokenReused) || errors.Is(err, service.ErrClientMismatch) {
+			errMsg.Code = http.StatusUnauthorized
+		}
 		errMsg.Message = err.Error()
 		c.JSON(errMsg.Code, errMsg)
 		return err
 	}
+	if cookieMode(c) {
+		if err := setSessionCookies(c, token); err != nil {
+			errMsg.Code = http.StatusInternalServerError
+			errMsg.Message = "Couldn't generate the CSRF token"
+			c.JSON(errMsg.Code, errMsg)
+			return err
+		}
+	}
 
 	return c.JSON(http.StatusOK, token)
 }
@@ -105,9 +255,12 @@ func (h *authHandler) GetTokenMetadata(c echo.Context) error {
 		return err
 	}
 
-	accessDetails, err := h.authService.ExtractTokenMetadata(ts.Token, common.Config.AccessSecret)
+	accessDetails, err := h.authService.ValidateToken(ts.Token)
 	if err != nil {
 		errMsg.Code = http.StatusInternalServerError
+		if errors.Is(err, service.ErrTokenRevoked) {
+			errMsg.Code = http.StatusUnauthorized
+		}
 		errMsg.Message = err.Error()
 		c.JSON(errMsg.Code, errMsg)
 		return err
@@ -115,3 +268,75 @@ func (h *authHandler) GetTokenMetadata(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, accessDetails)
 }
+
+// @Summary Logout
+// @Description Revokes the access token and the refresh token issued along with it
+// @ID logout
+// @Accept  json
+// @Produce  json
+// @Param name body models.TokenString true "Access token"
+// @Param X-Session-Mode header string false "cookie to remove the session cookies"
+// @Param X-CSRF-Token header string false "Value of the xmedia_csrf cookie, required in the cookie mode"
+// @Success 204
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /logout [post]
+// Logout calls the service layer to revoke provided access token, in the
+// cookie mode the request must carry the CSRF token and the cookies are removed
+func (h *authHandler) Logout(c echo.Context) error {
+	errMsg := new(models.Error)
+	if cookieMode(c) {
+		if err := checkCSRF(c); err != nil {
+			errMsg.Code = http.StatusForbidden
+			errMsg.Message = err.Error()
+			c.JSON(errMsg.Code, errMsg)
+			return err
+		}
+	}
+	ts := new(models.TokenString)
+	if err := c.Bind(ts); err != nil {
+		errMsg.Code = http.StatusBadRequest
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	if err := h.authService.Logout(ts.Token); err != nil {
+		errMsg.Code = http.StatusInternalServerError
+		if errors.Is(err, service.ErrTokenRevoked) {
+			errMsg.Code = http.StatusUnauthorized
+		}
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+	if cookieMode(c) {
+		clearSessionCookies(c)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary JSON Web Key Set
+// @Description Returns the public keys which can be used to verify the access tokens
+// @ID get-jwks
+// @Produce  json
+// @Success 200 {object} models.JWKS
+// @Failure 500 {object} models.Error
+// @Router /.well-known/jwks.json [get]
+// GetJWKS calls the service layer to get the published public keys
+func (h *authHandler) GetJWKS(c echo.Context) error {
+	jwks, err := h.authService.JWKS()
+	if err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, jwks)
+}
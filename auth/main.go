@@ -11,6 +11,7 @@ package main
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/0x113/x-media/auth/common"
 	"github.com/0x113/x-media/auth/data"
@@ -40,6 +41,9 @@ func (srv *Server) initServer() error {
 	// set up router
 	srv.router = echo.New()
 	srv.router.HideBanner = true
+	if len(common.Config.CORSAllowedOrigins) > 0 {
+		srv.router.Use(handler.NewCORSMiddleware(common.Config.CORSAllowedOrigins))
+	}
 	return nil
 }
 
@@ -52,8 +56,24 @@ func main() {
 
 	httpClient := &http.Client{}
 	authRepository := data.NewRedisAuthRepository()
-	authService := service.NewAuthService(httpClient, authRepository)
+	keyManager := service.NewKeyManager(data.NewRedisKeyRepository())
+	rotationInterval := time.Duration(common.Config.KeyRotationHours) * time.Hour
+	if rotationInterval <= 0 {
+		rotationInterval = 7 * 24 * time.Hour
+	}
+	keyManager.StartRotation(rotationInterval)
+	loginLimiter := service.NewLoginLimiter(data.NewRedisLoginAttemptRepository())
+	credentialBackend, err := service.NewCredentialBackend(httpClient)
+	if err != nil {
+		log.Fatalf("Couldn't initialize credential backend: %v", err)
+	}
+	authService := service.NewAuthService(credentialBackend, authRepository, keyManager, loginLimiter)
+	oauthService := service.NewOAuthService(data.NewRedisOAuthRepository(), authService, keyManager)
 	handler.NewAuthHandler(srv.router, authService)
+	handler.NewOAuthHandler(srv.router, oauthService, authService)
+	handler.NewSSOHandler(srv.router, service.NewSSOService(httpClient, data.NewRedisSSORepository(), authService), authService)
+	handler.NewPasskeyHandler(srv.router, service.NewPasskeyService(httpClient, data.NewRedisWebAuthnRepository(), authService), authService)
+	handler.NewMFAHandler(srv.router, service.NewMFAService(httpClient), authService)
 
 	srv.router.Start(":" + common.Config.Port)
 }
@@ -0,0 +1,54 @@
+package mocks
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/0x113/x-media/auth/models"
+)
+
+// MockClaimsUserService represents the claims endpoint of the user service
+// checked on every refresh, the users missing in Admins are reported as
+// deleted and the Disabled ones get 403
+type MockClaimsUserService struct {
+	// Admins maps the existing users to their admin flag
+	Admins   map[string]bool
+	Disabled map[string]bool
+}
+
+// NewMockClaimsUserService creates the user service knowing the regular users
+func NewMockClaimsUserService(usernames ...string) *MockClaimsUserService {
+	m := &MockClaimsUserService{
+		Admins:   map[string]bool{},
+		Disabled: map[string]bool{},
+	}
+	for _, username := range usernames {
+		m.Admins[username] = false
+	}
+	return m
+}
+
+// Client returns the HTTP client calling the mocked endpoint
+func (m *MockClaimsUserService) Client() *MockClient {
+	return &MockClient{DoFunc: m.do}
+}
+
+func (m *MockClaimsUserService) do(req *http.Request) (*http.Response, error) {
+	if strings.TrimPrefix(req.URL.Path, "/api/v1/user") != "/claims" {
+		return jsonResponse(http.StatusNotFound, &models.Error{Code: 404, Message: "Not Found"})
+	}
+	username := req.URL.Query().Get("username")
+	admin, ok := m.Admins[username]
+	if !ok {
+		return jsonResponse(http.StatusNotFound, &models.Error{Code: 404, Message: "User not found"})
+	}
+	if m.Disabled[username] {
+		return jsonResponse(http.StatusForbidden, &models.Error{Code: 403, Message: "User account is disabled"})
+	}
+
+	scopes := []string{"library:read", "stream"}
+	if admin {
+		scopes = append(scopes, "library:scan", "library:edit", "users:manage")
+	}
+	return jsonResponse(http.StatusOK, &models.AccessDetails{Username: username, IsAdmin: &admin, Scopes: scopes})
+}
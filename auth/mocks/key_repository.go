@@ -0,0 +1,52 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/0x113/x-media/auth/models"
+)
+
+// MockKeyRepository represents in-memory signing keys repository
+type MockKeyRepository struct {
+	Keys        map[string]*models.SigningKey
+	Loads       int       // number of the GetKeys calls
+	LockedUntil time.Time // expiration time of the rotation lock
+}
+
+// NewMockKeyRepository creates new instance of the mocked signing keys repository
+func NewMockKeyRepository() *MockKeyRepository {
+	return &MockKeyRepository{Keys: map[string]*models.SigningKey{}}
+}
+
+// SaveKey stores the copy of the signing key in memory
+func (m *MockKeyRepository) SaveKey(key *models.SigningKey) error {
+	k := *key
+	m.Keys[key.Kid] = &k
+	return nil
+}
+
+// GetKeys returns copies of all of the signing keys
+func (m *MockKeyRepository) GetKeys() ([]*models.SigningKey, error) {
+	m.Loads++
+	keys := []*models.SigningKey{}
+	for _, key := range m.Keys {
+		k := *key
+		keys = append(keys, &k)
+	}
+	return keys, nil
+}
+
+// DeleteKey removes the signing key from memory
+func (m *MockKeyRepository) DeleteKey(kid string) error {
+	delete(m.Keys, kid)
+	return nil
+}
+
+// LockRotation claims the rotation lock if it has expired
+func (m *MockKeyRepository) LockRotation(ttl time.Duration) (bool, error) {
+	if time.Now().Before(m.LockedUntil) {
+		return false, nil
+	}
+	m.LockedUntil = time.Now().Add(ttl)
+	return true, nil
+}
@@ -0,0 +1,232 @@
+package mocks
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPEntry defines the entry of the mocked LDAP directory, the entries with
+// the password can be used to bind
+type LDAPEntry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// MockLDAPServer represents in-process LDAP server, it supports only the
+// simple bind and the search with the equality, presence, and, or and not filters
+type MockLDAPServer struct {
+	URL      string
+	listener net.Listener
+	entries  []*LDAPEntry
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// NewMockLDAPServer starts the LDAP server listening on the random local port
+func NewMockLDAPServer(entries ...*LDAPEntry) (*MockLDAPServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &MockLDAPServer{
+		URL:      "ldap://" + listener.Addr().String(),
+		listener: listener,
+		entries:  entries,
+		conns:    map[net.Conn]struct{}{},
+	}
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the server and closes all of the connections
+func (s *MockLDAPServer) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *MockLDAPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+// handle reads the requests from the connection until the unbind request
+func (s *MockLDAPServer) handle(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			conn.Write(s.bind(messageID, op).Bytes())
+		case ldap.ApplicationSearchRequest:
+			for _, res := range s.search(messageID, op) {
+				conn.Write(res.Bytes())
+			}
+		case ldap.ApplicationUnbindRequest:
+			return
+		default:
+			conn.Write(result(messageID, ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError).Bytes())
+		}
+	}
+}
+
+// bind checks the password of the entry, the bind with the empty password is
+// the unauthenticated bind which always succeeds
+func (s *MockLDAPServer) bind(messageID int64, op *ber.Packet) *ber.Packet {
+	dn := op.Children[1].Data.String()
+	password := op.Children[2].Data.String()
+	if password == "" {
+		return result(messageID, ldap.ApplicationBindResponse, ldap.LDAPResultSuccess)
+	}
+
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.DN, dn) && entry.Password != "" && entry.Password == password {
+			return result(messageID, ldap.ApplicationBindResponse, ldap.LDAPResultSuccess)
+		}
+	}
+	return result(messageID, ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials)
+}
+
+// search returns the entries under the base DN matching the filter
+func (s *MockLDAPServer) search(messageID int64, op *ber.Packet) []*ber.Packet {
+	baseDN := strings.ToLower(op.Children[0].Data.String())
+	filter := op.Children[6]
+	var requested []string
+	for _, attr := range op.Children[7].Children {
+		requested = append(requested, attr.Data.String())
+	}
+
+	var packets []*ber.Packet
+	for _, entry := range s.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.DN), baseDN) || !matches(entry, filter) {
+			continue
+		}
+		packets = append(packets, searchEntry(messageID, entry, requested))
+	}
+	return append(packets, result(messageID, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+}
+
+// matches evaluates the search filter against the entry
+func matches(entry *LDAPEntry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matches(entry, child) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matches(entry, child) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !matches(entry, filter.Children[0])
+	case ldap.FilterPresent:
+		return len(attributeValues(entry, filter.Data.String())) > 0
+	case ldap.FilterEqualityMatch:
+		value := filter.Children[1].Data.Bytes()
+		for _, v := range attributeValues(entry, filter.Children[0].Data.String()) {
+			if bytes.EqualFold([]byte(v), value) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// attributeValues returns the values of the attribute, the attribute names are case-insensitive
+func attributeValues(entry *LDAPEntry, name string) []string {
+	for attr, values := range entry.Attributes {
+		if strings.EqualFold(attr, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// searchEntry encodes the entry with the requested attributes, all of them
+// are returned when none is requested
+func searchEntry(messageID int64, entry *LDAPEntry, requested []string) *ber.Packet {
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for attr, values := range entry.Attributes {
+		if len(requested) > 0 && !containsFold(requested, attr) {
+			continue
+		}
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attr, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "DN"))
+	op.AppendChild(attributes)
+	return envelope(messageID, op)
+}
+
+// result encodes the LDAPResult response with the code
+func result(messageID int64, tag ber.Tag, code uint16) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return envelope(messageID, op)
+}
+
+// envelope wraps the protocol operation in the LDAP message
+func envelope(messageID int64, op *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	packet.AppendChild(op)
+	return packet
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
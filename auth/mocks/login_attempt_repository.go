@@ -0,0 +1,67 @@
+package mocks
+
+import (
+	"time"
+)
+
+// MockLoginAttemptRepository represents in-memory login attempts repository
+type MockLoginAttemptRepository struct {
+	failures map[string][]time.Time
+	lockouts map[string]time.Time
+	Levels   map[string]int
+}
+
+// NewMockLoginAttemptRepository creates new instance of the mocked login attempts repository
+func NewMockLoginAttemptRepository() *MockLoginAttemptRepository {
+	return &MockLoginAttemptRepository{
+		failures: map[string][]time.Time{},
+		lockouts: map[string]time.Time{},
+		Levels:   map[string]int{},
+	}
+}
+
+// AddFailure adds the failed attempt and returns the number of failures within the window
+func (m *MockLoginAttemptRepository) AddFailure(key string, at time.Time, window time.Duration) (int, error) {
+	failures := []time.Time{}
+	for _, failure := range m.failures[key] {
+		if failure.After(at.Add(-window)) {
+			failures = append(failures, failure)
+		}
+	}
+	m.failures[key] = append(failures, at)
+	return len(m.failures[key]), nil
+}
+
+// ClearFailures removes all of the failed attempts
+func (m *MockLoginAttemptRepository) ClearFailures(key string) error {
+	delete(m.failures, key)
+	return nil
+}
+
+// IncrLockoutLevel increments the number of the consecutive lockouts
+func (m *MockLoginAttemptRepository) IncrLockoutLevel(key string, ttl time.Duration) (int, error) {
+	m.Levels[key]++
+	return m.Levels[key], nil
+}
+
+// Lock blocks the login attempts for provided duration
+func (m *MockLoginAttemptRepository) Lock(key string, duration time.Duration) error {
+	m.lockouts[key] = time.Now().Add(duration)
+	return nil
+}
+
+// GetLockout returns for how long the login attempts are blocked
+func (m *MockLoginAttemptRepository) GetLockout(key string) (time.Duration, error) {
+	if ttl := time.Until(m.lockouts[key]); ttl > 0 {
+		return ttl, nil
+	}
+	return 0, nil
+}
+
+// Unlock removes the lockout, its level and the failed attempts
+func (m *MockLoginAttemptRepository) Unlock(key string) error {
+	delete(m.failures, key)
+	delete(m.lockouts, key)
+	delete(m.Levels, key)
+	return nil
+}
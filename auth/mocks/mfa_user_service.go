@@ -0,0 +1,86 @@
+package mocks
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/0x113/x-media/auth/models"
+)
+
+// MockMFAUserService represents the login and TOTP endpoints of the user
+// service, every user has the password "password" and accepts the Code as
+// the second factor once enabled
+type MockMFAUserService struct {
+	Code    string
+	Enabled map[string]bool
+	// Enrolled contains the users who started the enrollment
+	Enrolled map[string]bool
+	// Locked contains the users locked out after too many invalid codes
+	Locked map[string]bool
+}
+
+// NewMockMFAUserService creates the user service accepting the code
+func NewMockMFAUserService(code string) *MockMFAUserService {
+	return &MockMFAUserService{
+		Code:     code,
+		Enabled:  map[string]bool{},
+		Enrolled: map[string]bool{},
+		Locked:   map[string]bool{},
+	}
+}
+
+// Client returns the HTTP client calling the mocked endpoints
+func (m *MockMFAUserService) Client() *MockClient {
+	return &MockClient{DoFunc: m.do}
+}
+
+func (m *MockMFAUserService) do(req *http.Request) (*http.Response, error) {
+	path := strings.TrimPrefix(req.URL.Path, "/api/v1/user")
+	body := map[string]string{}
+	json.NewDecoder(req.Body).Decode(&body)
+	username := body["username"]
+
+	admin := false
+	invalidCode := &models.Error{Code: 401, Message: "Invalid authentication code"}
+	switch path {
+	case "/validate":
+		if body["password"] != "password" {
+			return jsonResponse(http.StatusInternalServerError, &models.Error{Code: 500, Message: "Invalid user credentials"})
+		}
+		return jsonResponse(http.StatusOK, &models.AccessDetails{Username: username, IsAdmin: &admin, MFARequired: m.Enabled[username]})
+	case "/totp/enroll":
+		if m.Enabled[username] {
+			return jsonResponse(http.StatusConflict, &models.Error{Code: 409, Message: "Two-factor authentication is already enabled"})
+		}
+		m.Enrolled[username] = true
+		return jsonResponse(http.StatusOK, &models.TOTPEnrollment{Secret: "JBSWY3DPEHPK3PXP", RecoveryCodes: []string{"k7mzq-4tn2x"}})
+	case "/totp/confirm":
+		if m.Enabled[username] || !m.Enrolled[username] {
+			return jsonResponse(http.StatusConflict, &models.Error{Code: 409, Message: "Two-factor authentication isn't enabled"})
+		}
+		if body["code"] != m.Code {
+			return jsonResponse(http.StatusUnauthorized, invalidCode)
+		}
+		m.Enabled[username] = true
+		return jsonResponse(http.StatusOK, map[string]string{"message": "Successfully enabled two-factor authentication"})
+	case "/totp/verify", "/totp/disable":
+		if !m.Enabled[username] {
+			return jsonResponse(http.StatusConflict, &models.Error{Code: 409, Message: "Two-factor authentication isn't enabled"})
+		}
+		if m.Locked[username] {
+			res, err := jsonResponse(http.StatusTooManyRequests, &models.Error{Code: 429, Message: "Too many invalid authentication codes, try again in 60 seconds"})
+			res.Header = http.Header{"Retry-After": {"60"}}
+			return res, err
+		}
+		if body["code"] != m.Code {
+			return jsonResponse(http.StatusUnauthorized, invalidCode)
+		}
+		if path == "/totp/disable" {
+			m.Enabled[username], m.Enrolled[username] = false, false
+			return jsonResponse(http.StatusOK, map[string]string{"message": "Successfully disabled two-factor authentication"})
+		}
+		return jsonResponse(http.StatusOK, &models.AccessDetails{Username: username, IsAdmin: &admin})
+	}
+	return jsonResponse(http.StatusNotFound, &models.Error{Code: 404, Message: "Not Found"})
+}
@@ -0,0 +1,139 @@
+package mocks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/0x113/x-media/auth/models"
+)
+
+// MockOAuthRepository represents in-memory OAuth repository
+type MockOAuthRepository struct {
+	Clients  map[string]*models.OAuthClient
+	Codes    map[string]*models.AuthorizationCode
+	Consents map[string]string
+	Devices  map[string]*models.DeviceAuthorization
+	Polls    map[string]time.Time
+}
+
+// NewMockOAuthRepository creates new instance of the mocked OAuth repository
+func NewMockOAuthRepository() *MockOAuthRepository {
+	return &MockOAuthRepository{
+		Clients:  map[string]*models.OAuthClient{},
+		Codes:    map[string]*models.AuthorizationCode{},
+		Consents: map[string]string{},
+		Devices:  map[string]*models.DeviceAuthorization{},
+		Polls:    map[string]time.Time{},
+	}
+}
+
+// SaveClient stores the copy of the client in memory
+func (m *MockOAuthRepository) SaveClient(client *models.OAuthClient) error {
+	c := *client
+	m.Clients[client.ClientID] = &c
+	return nil
+}
+
+// GetClient returns the copy of the client
+func (m *MockOAuthRepository) GetClient(id string) (*models.OAuthClient, error) {
+	client, ok := m.Clients[id]
+	if !ok {
+		return nil, fmt.Errorf("There is no client with ID: %s", id)
+	}
+	c := *client
+	return &c, nil
+}
+
+// GetClients returns copies of all of the clients
+func (m *MockOAuthRepository) GetClients() ([]*models.OAuthClient, error) {
+	clients := []*models.OAuthClient{}
+	for _, client := range m.Clients {
+		c := *client
+		clients = append(clients, &c)
+	}
+	return clients, nil
+}
+
+// DeleteClient removes the client from memory
+func (m *MockOAuthRepository) DeleteClient(id string) error {
+	if _, ok := m.Clients[id]; !ok {
+		return fmt.Errorf("There is no client with ID: %s", id)
+	}
+	delete(m.Clients, id)
+	return nil
+}
+
+// SaveCode stores the copy of the authorization code in memory, the code never expires
+func (m *MockOAuthRepository) SaveCode(hash string, code *models.AuthorizationCode, ttl time.Duration) error {
+	c := *code
+	m.Codes[hash] = &c
+	return nil
+}
+
+// TakeCode returns the authorization code and removes it from memory
+func (m *MockOAuthRepository) TakeCode(hash string) (*models.AuthorizationCode, error) {
+	code, ok := m.Codes[hash]
+	if !ok {
+		return nil, fmt.Errorf("There is no authorization code with hash: %s", hash)
+	}
+	delete(m.Codes, hash)
+	return code, nil
+}
+
+// SaveConsent stores the granted scopes in memory
+func (m *MockOAuthRepository) SaveConsent(username, clientID, scope string) error {
+	m.Consents[username+":"+clientID] = scope
+	return nil
+}
+
+// GetConsent returns the granted scopes
+func (m *MockOAuthRepository) GetConsent(username, clientID string) (string, error) {
+	scope, ok := m.Consents[username+":"+clientID]
+	if !ok {
+		return "", fmt.Errorf("%s hasn't consented to client %s", username, clientID)
+	}
+	return scope, nil
+}
+
+// SaveDeviceAuthorization stores the copy of the device authorization in memory
+func (m *MockOAuthRepository) SaveDeviceAuthorization(auth *models.DeviceAuthorization) error {
+	a := *auth
+	m.Devices[auth.DeviceCodeHash] = &a
+	return nil
+}
+
+// GetDeviceAuthorization returns the copy of the device authorization if it hasn't expired
+func (m *MockOAuthRepository) GetDeviceAuthorization(hash string) (*models.DeviceAuthorization, error) {
+	auth, ok := m.Devices[hash]
+	if !ok || time.Now().After(auth.ExpiresAt) {
+		return nil, fmt.Errorf("There is no device authorization with hash: %s", hash)
+	}
+	a := *auth
+	return &a, nil
+}
+
+// GetDeviceAuthorizationByUserCode returns the copy of the device authorization with provided user code
+func (m *MockOAuthRepository) GetDeviceAuthorizationByUserCode(userCode string) (*models.DeviceAuthorization, error) {
+	for hash, auth := range m.Devices {
+		if auth.UserCode == userCode {
+			return m.GetDeviceAuthorization(hash)
+		}
+	}
+	return nil, fmt.Errorf("There is no device authorization with user code: %s", userCode)
+}
+
+// DeleteDeviceAuthorization removes the device authorization from memory
+func (m *MockOAuthRepository) DeleteDeviceAuthorization(auth *models.DeviceAuthorization) error {
+	delete(m.Devices, auth.DeviceCodeHash)
+	delete(m.Polls, auth.DeviceCodeHash)
+	return nil
+}
+
+// TouchDevicePoll records the poll time, false is returned if the previous poll was within the interval
+func (m *MockOAuthRepository) TouchDevicePoll(hash string, interval time.Duration) (bool, error) {
+	if last, ok := m.Polls[hash]; ok && time.Since(last) < interval {
+		return false, nil
+	}
+	m.Polls[hash] = time.Now()
+	return true, nil
+}
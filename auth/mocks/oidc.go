@@ -0,0 +1,191 @@
+package mocks
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/twinj/uuid"
+)
+
+// MockOIDCProvider represents the local OpenID Connect identity provider, it
+// issues the ID tokens for the codes created with Authorize
+type MockOIDCProvider struct {
+	URL          string
+	ClientID     string
+	ClientSecret string
+	Kid          string
+
+	server *httptest.Server
+	mu     sync.Mutex
+	key    *rsa.PrivateKey
+	codes  map[string]*mockOIDCCode
+}
+
+// mockOIDCCode defines the authorization code along with the claims of the ID token
+type mockOIDCCode struct {
+	redirectURI   string
+	codeChallenge string
+	claims        jwt.MapClaims
+}
+
+// NewMockOIDCProvider starts the identity provider with the registered client
+func NewMockOIDCProvider(clientID, clientSecret string) (*MockOIDCProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &MockOIDCProvider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Kid:          uuid.NewV4().String(),
+		key:          key,
+		codes:        map[string]*mockOIDCCode{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/token", p.token)
+	p.server = httptest.NewServer(mux)
+	p.URL = p.server.URL
+	return p, nil
+}
+
+// Close stops the identity provider
+func (p *MockOIDCProvider) Close() {
+	p.server.Close()
+}
+
+// RotateKey replaces the signing key, the new key is published with the new kid
+func (p *MockOIDCProvider) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.key = key
+	p.Kid = uuid.NewV4().String()
+	return nil
+}
+
+// Authorize signs the user in as if the authorization URL was opened and
+// returns the code, the claims override the default claims of the ID token.
+// The subject defaults to the one derived from the preferred_username claim.
+func (p *MockOIDCProvider) Authorize(authorizationURL string, claims jwt.MapClaims) (string, error) {
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	now := time.Now()
+	idClaims := jwt.MapClaims{
+		"iss":   p.URL,
+		"aud":   query.Get("client_id"),
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(idClaims, name)
+			continue
+		}
+		idClaims[name] = value
+	}
+	if _, ok := claims["sub"]; !ok {
+		if username, ok := claims["preferred_username"].(string); ok {
+			idClaims["sub"] = "subject-" + username
+		}
+	}
+
+	code := uuid.NewV4().String()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.codes[code] = &mockOIDCCode{
+		redirectURI:   query.Get("redirect_uri"),
+		codeChallenge: query.Get("code_challenge"),
+		claims:        idClaims,
+	}
+	return code, nil
+}
+
+func (p *MockOIDCProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, &models.OpenIDConfiguration{
+		Issuer:                           p.URL,
+		AuthorizationEndpoint:            p.URL + "/authorize",
+		TokenEndpoint:                    p.URL + "/token",
+		JWKSURI:                          p.URL + "/jwks",
+		ResponseTypesSupported:           []string{"code"},
+		IDTokenSigningAlgValuesSupported: []string{"RS256"},
+		CodeChallengeMethodsSupported:    []string{"S256"},
+	})
+}
+
+func (p *MockOIDCProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	writeJSON(w, http.StatusOK, &models.JWKS{Keys: []*models.JWK{{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: p.Kid,
+		N:   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+	}}})
+}
+
+// token exchanges the code for the ID token, the client must authenticate
+// with the basic auth and send the matching PKCE verifier
+func (p *MockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok || clientID != p.ClientID || secret != p.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, &models.OAuthError{Code: "invalid_client"})
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, &models.OAuthError{Code: "unsupported_grant_type"})
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	code, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || code.redirectURI != r.PostForm.Get("redirect_uri") || code.codeChallenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		writeJSON(w, http.StatusBadRequest, &models.OAuthError{Code: "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, code.claims)
+	token.Header["kid"] = p.Kid
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, &models.OAuthError{Code: "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, &models.OIDCTokenResponse{
+		AccessToken: uuid.NewV4().String(),
+		TokenType:   "Bearer",
+		IDToken:     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
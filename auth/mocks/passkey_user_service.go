@@ -0,0 +1,117 @@
+package mocks
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0x113/x-media/auth/models"
+)
+
+// MockPasskeyUserService represents the passkey endpoints of the user
+// service, the users listed in Passwords have the password
+type MockPasskeyUserService struct {
+	Passkeys  map[string]*models.StoredPasskey
+	Passwords map[string]bool
+	Admins    map[string]bool
+}
+
+// NewMockPasskeyUserService creates the user service with the users having the password
+func NewMockPasskeyUserService(usernames ...string) *MockPasskeyUserService {
+	m := &MockPasskeyUserService{
+		Passkeys:  map[string]*models.StoredPasskey{},
+		Passwords: map[string]bool{},
+		Admins:    map[string]bool{},
+	}
+	for _, username := range usernames {
+		m.Passwords[username] = true
+	}
+	return m
+}
+
+// Client returns the HTTP client calling the mocked endpoints
+func (m *MockPasskeyUserService) Client() *MockClient {
+	return &MockClient{DoFunc: m.do}
+}
+
+func (m *MockPasskeyUserService) do(req *http.Request) (*http.Response, error) {
+	path := strings.TrimPrefix(req.URL.Path, "/api/v1/user")
+	username := req.URL.Query().Get("username")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case req.Method == http.MethodPost && path == "/passkeys":
+		p := new(models.StoredPasskey)
+		json.NewDecoder(req.Body).Decode(p)
+		if _, exists := m.Passkeys[p.ID]; exists {
+			return jsonResponse(http.StatusInternalServerError, &models.Error{Code: 500, Message: "Passkey already exists"})
+		}
+		p.CreatedAt = time.Now()
+		m.Passkeys[p.ID] = p
+		return jsonResponse(http.StatusCreated, map[string]string{"message": "Successfully added new passkey"})
+	case req.Method == http.MethodGet && path == "/passkeys":
+		return jsonResponse(http.StatusOK, m.passkeysOf(username))
+	case req.Method == http.MethodGet && len(segments) == 2:
+		p, ok := m.Passkeys[segments[1]]
+		if !ok {
+			return jsonResponse(http.StatusNotFound, &models.Error{Code: 404, Message: "Passkey not found"})
+		}
+		return jsonResponse(http.StatusOK, p)
+	case req.Method == http.MethodPost && len(segments) == 3 && segments[2] == "login":
+		p, ok := m.Passkeys[segments[1]]
+		if !ok {
+			return jsonResponse(http.StatusNotFound, &models.Error{Code: 404, Message: "Passkey not found"})
+		}
+		login := new(models.StoredPasskey)
+		json.NewDecoder(req.Body).Decode(login)
+		if login.SignCount <= p.SignCount && (login.SignCount != 0 || p.SignCount != 0) {
+			return jsonResponse(http.StatusForbidden, &models.Error{Code: 403, Message: "Passkey signature counter didn't increase"})
+		}
+		now := time.Now()
+		p.SignCount, p.LastUsedAt = login.SignCount, &now
+		admin := m.Admins[p.Username]
+		return jsonResponse(http.StatusOK, &models.AccessDetails{Username: p.Username, IsAdmin: &admin})
+	case req.Method == http.MethodDelete && len(segments) == 2:
+		p, ok := m.Passkeys[segments[1]]
+		if !ok || p.Username != username {
+			return jsonResponse(http.StatusNotFound, &models.Error{Code: 404, Message: "Passkey not found"})
+		}
+		if !m.Passwords[username] && len(m.passkeysOf(username)) == 1 {
+			return jsonResponse(http.StatusConflict, &models.Error{Code: 409, Message: "Account must keep either the password or a passkey"})
+		}
+		delete(m.Passkeys, p.ID)
+		return jsonResponse(http.StatusOK, map[string]string{"message": "Successfully deleted the passkey"})
+	case req.Method == http.MethodDelete && path == "/password":
+		if len(m.passkeysOf(username)) == 0 {
+			return jsonResponse(http.StatusConflict, &models.Error{Code: 409, Message: "Account must keep either the password or a passkey"})
+		}
+		m.Passwords[username] = false
+		return jsonResponse(http.StatusOK, map[string]string{"message": "Successfully removed the password"})
+	}
+	return jsonResponse(http.StatusNotFound, &models.Error{Code: 404, Message: "Not Found"})
+}
+
+func (m *MockPasskeyUserService) passkeysOf(username string) []*models.StoredPasskey {
+	passkeys := []*models.StoredPasskey{}
+	for _, p := range m.Passkeys {
+		if p.Username == username {
+			passkeys = append(passkeys, p)
+		}
+	}
+	sort.Slice(passkeys, func(i, j int) bool {
+		return passkeys[i].CreatedAt.Before(passkeys[j].CreatedAt)
+	})
+	return passkeys
+}
+
+func jsonResponse(status int, v interface{}) (*http.Response, error) {
+	body, _ := json.Marshal(v)
+	return &http.Response{
+		StatusCode: status,
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
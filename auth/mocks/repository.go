@@ -2,20 +2,24 @@ package mocks
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/0x113/x-media/auth/models"
 )
 
 // MockAuthRepository represents in-memory authentication repository
 type MockAuthRepository struct {
-	tokens map[string]string
+	tokens   map[string]string
+	Families map[string]*models.TokenFamily
+	APIKeys  map[string]*models.APIKey
+	// MFAChallenges are stored under the hash of the MFA token
+	MFAChallenges map[string]*models.MFAChallenge
 }
 
 // NewMockAuthRepository creates new instance of the mocked auth repository
 func NewMockAuthRepository() *MockAuthRepository {
 	var tokens = map[string]string{}
-	tokens["b66a7219-f07f-49cf-8163-189da2f5c8cc"] = "JohnDoe"
-	return &MockAuthRepository{tokens}
+	return &MockAuthRepository{tokens, map[string]*models.TokenFamily{}, map[string]*models.APIKey{}, map[string]*models.MFAChallenge{}}
 }
 
 // Save the token in memory
@@ -33,6 +37,15 @@ func (m *MockAuthRepository) Save(username string, token *models.TokenDetails) e
 	return nil
 }
 
+// Get returns the username of the token owner
+func (m *MockAuthRepository) Get(uuid string) (string, error) {
+	username, ok := m.tokens[uuid]
+	if !ok {
+		return "", fmt.Errorf("There is no token with UUID: %s", uuid)
+	}
+	return username, nil
+}
+
 // Delete the token from memory
 func (m *MockAuthRepository) Delete(uuid string) error {
 	if _, ok := m.tokens[uuid]; !ok {
@@ -42,3 +55,102 @@ func (m *MockAuthRepository) Delete(uuid string) error {
 	delete(m.tokens, uuid)
 	return nil
 }
+
+// SaveFamily stores the copy of the token family in memory
+func (m *MockAuthRepository) SaveFamily(family *models.TokenFamily, expires time.Time) error {
+	f := *family
+	m.Families[family.ID] = &f
+	return nil
+}
+
+// GetFamily returns the copy of the token family
+func (m *MockAuthRepository) GetFamily(id string) (*models.TokenFamily, error) {
+	family, ok := m.Families[id]
+	if !ok {
+		return nil, fmt.Errorf("There is no token family with ID: %s", id)
+	}
+	f := *family
+	return &f, nil
+}
+
+// GetFamilies returns copies of the token families of the user
+func (m *MockAuthRepository) GetFamilies(username string) ([]*models.TokenFamily, error) {
+	families := []*models.TokenFamily{}
+	for _, family := range m.Families {
+		if family.Username == username {
+			f := *family
+			families = append(families, &f)
+		}
+	}
+	return families, nil
+}
+
+// DeleteFamily removes the token family from memory
+func (m *MockAuthRepository) DeleteFamily(family *models.TokenFamily) error {
+	delete(m.Families, family.ID)
+	return nil
+}
+
+// SaveAPIKey stores the copy of the API key in memory
+func (m *MockAuthRepository) SaveAPIKey(key *models.APIKey) error {
+	k := *key
+	m.APIKeys[key.ID] = &k
+	return nil
+}
+
+// GetAPIKey returns the copy of the API key if it hasn't expired
+func (m *MockAuthRepository) GetAPIKey(id string) (*models.APIKey, error) {
+	key, ok := m.APIKeys[id]
+	if !ok || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		return nil, fmt.Errorf("There is no API key with ID: %s", id)
+	}
+	k := *key
+	return &k, nil
+}
+
+// GetAPIKeyByHash returns the copy of the API key with provided hash
+func (m *MockAuthRepository) GetAPIKeyByHash(hash string) (*models.APIKey, error) {
+	for id, key := range m.APIKeys {
+		if key.Hash == hash {
+			return m.GetAPIKey(id)
+		}
+	}
+	return nil, fmt.Errorf("There is no API key with hash: %s", hash)
+}
+
+// GetAPIKeys returns copies of the API keys of the user which haven't expired
+func (m *MockAuthRepository) GetAPIKeys(username string) ([]*models.APIKey, error) {
+	keys := []*models.APIKey{}
+	for id, key := range m.APIKeys {
+		if key.Username != username {
+			continue
+		}
+		if k, err := m.GetAPIKey(id); err == nil {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// DeleteAPIKey removes the API key from memory
+func (m *MockAuthRepository) DeleteAPIKey(key *models.APIKey) error {
+	delete(m.APIKeys, key.ID)
+	return nil
+}
+
+// SaveMFAChallenge stores the copy of the challenge in memory
+func (m *MockAuthRepository) SaveMFAChallenge(hash string, challenge *models.MFAChallenge) error {
+	c := *challenge
+	m.MFAChallenges[hash] = &c
+	return nil
+}
+
+// TakeMFAChallenge returns the challenge if it hasn't expired and removes it from memory
+func (m *MockAuthRepository) TakeMFAChallenge(hash string) (*models.MFAChallenge, error) {
+	challenge, ok := m.MFAChallenges[hash]
+	delete(m.MFAChallenges, hash)
+	if !ok || time.Now().After(challenge.ExpiresAt) {
+		return nil, fmt.Errorf("There is no MFA challenge with hash: %s", hash)
+	}
+	return challenge, nil
+}
@@ -0,0 +1,47 @@
+package mocks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/0x113/x-media/auth/models"
+)
+
+// MockSSORepository represents in-memory SSO repository
+type MockSSORepository struct {
+	States map[string]*models.SSOState
+	Links  map[string]string // usernames by provider and subject joined with a space
+}
+
+// NewMockSSORepository creates new instance of the mocked SSO repository
+func NewMockSSORepository() *MockSSORepository {
+	return &MockSSORepository{States: map[string]*models.SSOState{}, Links: map[string]string{}}
+}
+
+// SaveState stores the copy of the state in memory, the state never expires
+func (m *MockSSORepository) SaveState(hash string, state *models.SSOState, ttl time.Duration) error {
+	s := *state
+	m.States[hash] = &s
+	return nil
+}
+
+// TakeState returns the state and removes it from memory
+func (m *MockSSORepository) TakeState(hash string) (*models.SSOState, error) {
+	state, ok := m.States[hash]
+	if !ok {
+		return nil, fmt.Errorf("There is no SSO state with hash: %s", hash)
+	}
+	delete(m.States, hash)
+	return state, nil
+}
+
+// GetLink returns the linked username or the empty string
+func (m *MockSSORepository) GetLink(provider, subject string) (string, error) {
+	return m.Links[provider+" "+subject], nil
+}
+
+// SaveLink stores the link in memory
+func (m *MockSSORepository) SaveLink(provider, subject, username string) error {
+	m.Links[provider+" "+subject] = username
+	return nil
+}
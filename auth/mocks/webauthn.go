@@ -0,0 +1,174 @@
+package mocks
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// MockAuthenticator represents the software WebAuthn authenticator with the
+// single ES256 credential, the exported fields can be changed to forge the
+// responses of the misbehaving authenticators
+type MockAuthenticator struct {
+	CredentialID []byte
+	RPID         string
+	Origin       string
+	SignCount    uint32
+	// Attestation defines the attestation format, none or packed self attestation
+	Attestation string
+	// Unverified signs without the user verification, only the user presence is set
+	Unverified bool
+
+	key *ecdsa.PrivateKey
+}
+
+// NewMockAuthenticator creates the authenticator with the new key pair
+func NewMockAuthenticator(rpID, origin string) (*MockAuthenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return &MockAuthenticator{
+		CredentialID: id,
+		RPID:         rpID,
+		Origin:       origin,
+		Attestation:  "none",
+		key:          key,
+	}, nil
+}
+
+// ID returns the base64url encoded credential id
+func (a *MockAuthenticator) ID() string {
+	return base64.RawURLEncoding.EncodeToString(a.CredentialID)
+}
+
+// PublicKey returns the COSE encoded public key of the credential
+func (a *MockAuthenticator) PublicKey() []byte {
+	// the canonical encoding keeps the key the same between the calls
+	encoder, _ := cbor.CanonicalEncOptions().EncMode()
+	key, _ := encoder.Marshal(map[int]interface{}{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: padded(a.key.X),
+		-3: padded(a.key.Y),
+	})
+	return key
+}
+
+// Register creates the credential as navigator.credentials.create would
+func (a *MockAuthenticator) Register(options *models.PublicKeyCredentialCreationOptions, name string) (*models.PasskeyRegistration, error) {
+	clientDataJSON := a.clientData("webauthn.create", options.Challenge)
+	authData := a.authData(0x41) // user present, attested credential
+	idLen := make([]byte, 2)
+	binary.BigEndian.PutUint16(idLen, uint16(len(a.CredentialID)))
+	authData = append(authData, make([]byte, 16)...) // aaguid
+	authData = append(authData, idLen...)
+	authData = append(authData, a.CredentialID...)
+	authData = append(authData, a.PublicKey()...)
+
+	statement := map[string]interface{}{}
+	if a.Attestation == "packed" {
+		clientDataHash := sha256.Sum256(clientDataJSON)
+		sig, err := a.sign(append(append([]byte{}, authData...), clientDataHash[:]...))
+		if err != nil {
+			return nil, err
+		}
+		statement = map[string]interface{}{"alg": -7, "sig": sig}
+	}
+	attestationObject, err := cbor.Marshal(map[string]interface{}{
+		"fmt":      a.Attestation,
+		"attStmt":  statement,
+		"authData": authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PasskeyRegistration{
+		Name:  name,
+		ID:    a.ID(),
+		RawID: a.CredentialID,
+		Type:  "public-key",
+		Response: models.AttestationResponse{
+			ClientDataJSON:    clientDataJSON,
+			AttestationObject: attestationObject,
+		},
+	}, nil
+}
+
+// Login signs the challenge as navigator.credentials.get would, the signature counter is incremented
+func (a *MockAuthenticator) Login(options *models.PublicKeyCredentialRequestOptions, userHandle string) (*models.PasskeyAssertion, error) {
+	a.SignCount++
+	clientDataJSON := a.clientData("webauthn.get", options.Challenge)
+	authData := a.authData(0x01) // user present
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	sig, err := a.sign(append(append([]byte{}, authData...), clientDataHash[:]...))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PasskeyAssertion{
+		ID:    a.ID(),
+		RawID: a.CredentialID,
+		Type:  "public-key",
+		Response: models.AssertionResponse{
+			ClientDataJSON:    clientDataJSON,
+			AuthenticatorData: authData,
+			Signature:         sig,
+			UserHandle:        models.Base64URL(userHandle),
+		},
+	}, nil
+}
+
+func (a *MockAuthenticator) clientData(ceremony string, challenge []byte) []byte {
+	clientDataJSON, _ := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    a.Origin,
+	})
+	return clientDataJSON
+}
+
+// authData returns the authenticator data without the attested credential,
+// the user verified flag is added unless the authenticator is unverified
+func (a *MockAuthenticator) authData(flags byte) []byte {
+	if !a.Unverified {
+		flags |= 0x04
+	}
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	authData := append(rpIDHash[:], flags)
+	counter := make([]byte, 4)
+	binary.BigEndian.PutUint32(counter, a.SignCount)
+	return append(authData, counter...)
+}
+
+// sign returns the ASN.1 encoded ECDSA signature of the data
+func (a *MockAuthenticator) sign(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+}
+
+// padded returns the coordinate as 32 bytes
+func padded(n *big.Int) []byte {
+	b := n.Bytes()
+	return append(make([]byte, 32-len(b)), b...)
+}
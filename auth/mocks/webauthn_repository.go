@@ -0,0 +1,35 @@
+package mocks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/0x113/x-media/auth/models"
+)
+
+// MockWebAuthnRepository represents in-memory WebAuthn repository
+type MockWebAuthnRepository struct {
+	Challenges map[string]*models.WebAuthnChallenge
+}
+
+// NewMockWebAuthnRepository creates new instance of the mocked WebAuthn repository
+func NewMockWebAuthnRepository() *MockWebAuthnRepository {
+	return &MockWebAuthnRepository{Challenges: map[string]*models.WebAuthnChallenge{}}
+}
+
+// SaveChallenge stores the copy of the challenge in memory, the challenge never expires
+func (m *MockWebAuthnRepository) SaveChallenge(hash string, challenge *models.WebAuthnChallenge, ttl time.Duration) error {
+	c := *challenge
+	m.Challenges[hash] = &c
+	return nil
+}
+
+// TakeChallenge returns the challenge and removes it from memory
+func (m *MockWebAuthnRepository) TakeChallenge(hash string) (*models.WebAuthnChallenge, error) {
+	challenge, ok := m.Challenges[hash]
+	if !ok {
+		return nil, fmt.Errorf("There is no WebAuthn challenge with hash: %s", hash)
+	}
+	delete(m.Challenges, hash)
+	return challenge, nil
+}
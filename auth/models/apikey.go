@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// APIKey defines the long-lived credential used by the scripts and automations
+type APIKey struct {
+	ID         string     `json:"id" example:"0f3c5b5e-8e0a-4b4e-9a53-2bd4a1e5e7a1"`
+	Name       string     `json:"name" example:"Home Assistant"`
+	Username   string     `json:"username" example:"JohnDoe"`
+	IsAdmin    bool       `json:"is_admin" example:"false"`
+	Scopes     []string   `json:"scopes" example:"library:read,stream"`
+	Prefix     string     `json:"prefix" example:"xmk_hbQ4vHqT"`
+	Hash       string     `json:"hash,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" example:"2020-09-05T15:04:05Z"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" example:"2021-09-05T15:04:05Z"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" example:"2020-09-06T10:04:05Z"`
+}
+
+// APIKeyRequest defines the request to create new API key
+type APIKeyRequest struct {
+	Name      string     `json:"name" validate:"required" example:"Home Assistant"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1" example:"library:read,stream"`
+	ExpiresAt *time.Time `json:"expires_at" example:"2021-09-05T15:04:05Z"`
+}
+
+// CreatedAPIKey defines newly created API key along with the key itself,
+// the key is returned only once
+type CreatedAPIKey struct {
+	*APIKey
+	Key string `json:"key" example:"xmk_hbQ4vHqTtJ3b7WcZ2Qx0aQhbQ4vHqTtJ3b7WcZ2Qx0a"`
+}
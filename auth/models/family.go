@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// TokenFamily defines the chain of refresh tokens issued from a single login.
+// Only the newest refresh token of the family can be used.
+type TokenFamily struct {
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	AccessUuid  string    `json:"access_uuid"`
+	RefreshUuid string    `json:"refresh_uuid"`
+	IP          string    `json:"ip"`
+	UserAgent   string    `json:"user_agent"`
+	ClientID    string    `json:"client_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastUsedAt  time.Time `json:"last_used_at"`
+}
+
+// ClientInfo defines the client which requested the token, ClientID is set
+// only for the tokens issued to the OAuth clients
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+	ClientID  string
+}
+
+// Session defines the token family presented to the user
+type Session struct {
+	ID         string    `json:"id" example:"0f3c5b5e-8e0a-4b4e-9a53-2bd4a1e5e7a1"`
+	IP         string    `json:"ip" example:"192.168.1.10"`
+	UserAgent  string    `json:"user_agent" example:"Kodi/18.9"`
+	ClientID   string    `json:"client_id,omitempty" example:"0f3c5b5e-8e0a-4b4e-9a53-2bd4a1e5e7a1"`
+	CreatedAt  time.Time `json:"created_at" example:"2020-09-05T15:04:05Z"`
+	LastUsedAt time.Time `json:"last_used_at" example:"2020-09-06T10:04:05Z"`
+	Current    bool      `json:"current" example:"true"`
+}
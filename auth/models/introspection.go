@@ -0,0 +1,27 @@
+package models
+
+// IntrospectionRequest defines the form sent to the introspection endpoint (RFC 7662)
+type IntrospectionRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// Introspection defines the state and the claims of the introspected token,
+// inactive tokens have only the active field
+type Introspection struct {
+	Active    bool     `json:"active" example:"true"`
+	Scope     string   `json:"scope,omitempty" example:"library:read stream"`
+	ClientID  string   `json:"client_id,omitempty" example:"0f3c5b5e-8e0a-4b4e-9a53-2bd4a1e5e7a1"`
+	Username  string   `json:"username,omitempty" example:"JohnDoe"`
+	TokenType string   `json:"token_type,omitempty" example:"Bearer"`
+	ExpiresAt int64    `json:"exp,omitempty" example:"1594575030"`
+	IssuedAt  int64    `json:"iat,omitempty" example:"1594574130"`
+	NotBefore int64    `json:"nbf,omitempty" example:"1594574130"`
+	Subject   string   `json:"sub,omitempty" example:"JohnDoe"`
+	Audience  []string `json:"aud,omitempty" example:"movie-svc,tvshow-svc"`
+	Issuer    string   `json:"iss,omitempty" example:"http://localhost:8003"`
+	ID        string   `json:"jti,omitempty" example:"f194afdc-b505-4c2f-a754-6e44609c6e80"`
+	IsAdmin   bool     `json:"is_admin,omitempty" example:"false"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// SigningKey defines the RSA key used to sign the access tokens
+type SigningKey struct {
+	Kid        string     `json:"kid"`
+	PrivateKey string     `json:"private_key"` // PEM encoded
+	CreatedAt  time.Time  `json:"created_at"`
+	RetiredAt  *time.Time `json:"retired_at,omitempty"`
+}
+
+// JWK defines the public key in the JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty" example:"RSA"`
+	Use string `json:"use" example:"sig"`
+	Alg string `json:"alg" example:"RS256"`
+	Kid string `json:"kid" example:"0f3c5b5e-8e0a-4b4e-9a53-2bd4a1e5e7a1"`
+	N   string `json:"n" example:"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"`
+	E   string `json:"e" example:"AQAB"`
+}
+
+// JWKS defines the JSON Web Key Set with the public keys of the authentication service
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// MFAChallenge defines the login waiting for the second factor, it's stored under the hash of the MFA token
+type MFAChallenge struct {
+	Username  string    `json:"username"`
+	IP        string    `json:"ip"`
+	Attempts  int       `json:"attempts"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// MFARequired defines the response of the login with the correct password
+// when the user has to provide the code of the second factor
+type MFARequired struct {
+	Code        int    `json:"code" example:"401"`
+	Message     string `json:"message" example:"Two-factor authentication code is required"`
+	MFARequired bool   `json:"mfa_required" example:"true"`
+	MFAToken    string `json:"mfa_token" example:"mDzKu1b4Yb1BJ_AsFHBW6mUs6t4qOQ6x3pBFvtEDP5o"`
+	ExpiresIn   int    `json:"expires_in" example:"300"`
+}
+
+// MFALogin defines the second step of the login
+type MFALogin struct {
+	MFAToken string `json:"mfa_token" validate:"required" example:"mDzKu1b4Yb1BJ_AsFHBW6mUs6t4qOQ6x3pBFvtEDP5o"`
+	Code     string `json:"code" validate:"required,max=32" example:"123456"`
+}
+
+// TOTPCode defines the code from the authenticator app or one of the recovery codes
+type TOTPCode struct {
+	Code string `json:"code" validate:"required,max=32" example:"123456"`
+}
+
+// TOTPEnrollment defines the secret of the new authenticator app along with
+// the recovery codes, they are returned only once
+type TOTPEnrollment struct {
+	Secret        string   `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	URI           string   `json:"uri" example:"otpauth://totp/x-media:JohnDoe?algorithm=SHA1&digits=6&issuer=x-media&period=30&secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	QRCode        string   `json:"qr_code" example:"data:image/png;base64,iVBORw0KGgo="`
+	RecoveryCodes []string `json:"recovery_codes" example:"k7mzq-4tn2x,v3hpa-9cw6e"`
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Base64URL defines the binary value encoded as the unpadded base64url
+// string, the encoding used by the WebAuthn JSON serialization
+type Base64URL []byte
+
+// MarshalJSON encodes the value as the unpadded base64url string
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// UnmarshalJSON decodes the base64url string, the padding is optional
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// Passkey defines the WebAuthn credential of the user
+type Passkey struct {
+	ID         string     `json:"id" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+	Name       string     `json:"name" example:"YubiKey"`
+	CreatedAt  time.Time  `json:"created_at" example:"2020-09-05T15:04:05Z"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" example:"2020-09-06T15:04:05Z"`
+}
+
+// StoredPasskey defines the passkey stored by the user service along with its public key
+type StoredPasskey struct {
+	ID         string     `json:"id"`
+	Username   string     `json:"username"`
+	Name       string     `json:"name"`
+	PublicKey  []byte     `json:"public_key"` // COSE encoded
+	SignCount  uint32     `json:"sign_count"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// WebAuthnChallenge defines the pending registration or login, stored under the hash of the challenge
+type WebAuthnChallenge struct {
+	Type     string `json:"type"` // webauthn.create or webauthn.get
+	Username string `json:"username,omitempty"`
+}
+
+// RelyingParty defines the WebAuthn relying party
+type RelyingParty struct {
+	ID   string `json:"id,omitempty" example:"localhost"`
+	Name string `json:"name" example:"x-media"`
+}
+
+// WebAuthnUser defines the account the passkey is created for
+type WebAuthnUser struct {
+	ID          Base64URL `json:"id" swaggertype:"string" example:"Sm9obkRvZQ"`
+	Name        string    `json:"name" example:"JohnDoe"`
+	DisplayName string    `json:"displayName" example:"JohnDoe"`
+}
+
+// CredentialParameter defines the accepted type and algorithm of the credential
+type CredentialParameter struct {
+	Type string `json:"type" example:"public-key"`
+	Alg  int    `json:"alg" example:"-7"`
+}
+
+// CredentialDescriptor identifies the existing credential
+type CredentialDescriptor struct {
+	Type string    `json:"type" example:"public-key"`
+	ID   Base64URL `json:"id" swaggertype:"string" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+}
+
+// AuthenticatorSelection defines the requirements of the authenticator
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey" example:"preferred"`
+	UserVerification string `json:"userVerification" example:"required"`
+}
+
+// PublicKeyCredentialCreationOptions defines the options of navigator.credentials.create
+type PublicKeyCredentialCreationOptions struct {
+	RP                     RelyingParty            `json:"rp"`
+	User                   WebAuthnUser            `json:"user"`
+	Challenge              Base64URL               `json:"challenge" swaggertype:"string" example:"Sm9obkRvZUNoYWxsZW5nZQ"`
+	PubKeyCredParams       []CredentialParameter   `json:"pubKeyCredParams"`
+	Timeout                int64                   `json:"timeout" example:"300000"`
+	ExcludeCredentials     []*CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection  `json:"authenticatorSelection"`
+	Attestation            string                  `json:"attestation" example:"none"`
+}
+
+// PublicKeyCredentialRequestOptions defines the options of navigator.credentials.get
+type PublicKeyCredentialRequestOptions struct {
+	Challenge        Base64URL               `json:"challenge" swaggertype:"string" example:"Sm9obkRvZUNoYWxsZW5nZQ"`
+	Timeout          int64                   `json:"timeout" example:"300000"`
+	RPID             string                  `json:"rpId" example:"localhost"`
+	AllowCredentials []*CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                  `json:"userVerification" example:"required"`
+}
+
+// PasskeyLoginRequest defines the optional username of the passkey login,
+// the discoverable credentials are used without it
+type PasskeyLoginRequest struct {
+	Username string `json:"username" example:"JohnDoe"`
+}
+
+// AttestationResponse defines the response of the authenticator to navigator.credentials.create
+type AttestationResponse struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON" swaggertype:"string"`
+	AttestationObject Base64URL `json:"attestationObject" swaggertype:"string"`
+}
+
+// PasskeyRegistration defines the new credential serialized by PublicKeyCredential.toJSON
+type PasskeyRegistration struct {
+	Name     string              `json:"name" example:"YubiKey"`
+	ID       string              `json:"id" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+	RawID    Base64URL           `json:"rawId" swaggertype:"string" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+	Type     string              `json:"type" example:"public-key"`
+	Response AttestationResponse `json:"response"`
+}
+
+// AssertionResponse defines the response of the authenticator to navigator.credentials.get
+type AssertionResponse struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON" swaggertype:"string"`
+	AuthenticatorData Base64URL `json:"authenticatorData" swaggertype:"string"`
+	Signature         Base64URL `json:"signature" swaggertype:"string"`
+	UserHandle        Base64URL `json:"userHandle,omitempty" swaggertype:"string"`
+}
+
+// PasskeyAssertion defines the signed challenge serialized by PublicKeyCredential.toJSON
+type PasskeyAssertion struct {
+	ID       string            `json:"id" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+	RawID    Base64URL         `json:"rawId" swaggertype:"string" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+	Type     string            `json:"type" example:"public-key"`
+	Response AssertionResponse `json:"response"`
+}
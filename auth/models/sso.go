@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// SSOProvider defines the external identity provider offered on the login page
+type SSOProvider struct {
+	Name        string `json:"name" example:"keycloak"`
+	DisplayName string `json:"display_name" example:"Keycloak"`
+}
+
+// SSOAuthorization defines where the user agent should be redirected to sign
+// in with the identity provider, the frontend keeps the state to check it on the callback page
+type SSOAuthorization struct {
+	AuthorizationURL string `json:"authorization_url" example:"http://localhost:8080/realms/xmedia/protocol/openid-connect/auth?client_id=x-media&response_type=code&state=af0ifjsldkj"`
+	State            string `json:"state" example:"af0ifjsldkj"`
+}
+
+// SSOCallback defines the params the identity provider redirected the user agent with
+type SSOCallback struct {
+	Code  string `json:"code" validate:"required" example:"SplxlOBeZQQYbYS6WxSbIA"`
+	State string `json:"state" validate:"required" example:"af0ifjsldkj"`
+}
+
+// SSOState defines the pending sign in with the identity provider
+type SSOState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	// Link is the signed in user who links the identity with their account
+	Link      string    `json:"link,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OIDCTokenResponse defines the response of the token endpoint of the identity provider
+type OIDCTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import "net/http"
+
+// AccessDetails defines the details of the authenticated user returned by the
+// authentication service
+type AccessDetails struct {
+	Username string   `json:"username" example:"JohnDoe"`
+	IsAdmin  bool     `json:"is_admin" example:"false"`
+	Scopes   []string `json:"scopes,omitempty" example:"library:read,stream"`
+}
+
+// Error defines the error response of the middleware
+type Error struct {
+	Code    int    `json:"code" example:"401"`
+	Message string `json:"message" example:"Missing authentication token"`
+}
+
+// JWK defines the public key of the authentication service in the JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKS defines the JSON Web Key Set of the authentication service
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// HTTPClient defines the http client used to call the authentication service
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// ClientIP returns the address of the client. The X-Forwarded-For and X-Real-IP
+// headers can be set by anyone, so they're read only if the request comes from
+// one of the trusted proxies. X-Forwarded-For is read from the right, the first
+// address which isn't a trusted proxy is the client.
+func ClientIP(req *http.Request, trustedProxies []string) string {
+	remoteIP, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		remoteIP = req.RemoteAddr
+	}
+	if !isTrustedProxy(remoteIP, trustedProxies) {
+		return remoteIP
+	}
+
+	if forwarded := req.Header.Get(echo.HeaderXForwardedFor); forwarded != "" {
+		addrs := strings.Split(forwarded, ",")
+		for i := len(addrs) - 1; i >= 0; i-- {
+			ip := strings.TrimSpace(addrs[i])
+			if net.ParseIP(ip) == nil {
+				break
+			}
+			if i == 0 || !isTrustedProxy(ip, trustedProxies) {
+				return ip
+			}
+		}
+	}
+	if ip := strings.TrimSpace(req.Header.Get(echo.HeaderXRealIP)); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return remoteIP
+}
+
+// isTrustedProxy checks if the address matches one of the trusted proxies,
+// the proxies are defined by their addresses or CIDR ranges
+func isTrustedProxy(addr string, trustedProxies []string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range trustedProxies {
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if proxyIP := net.ParseIP(proxy); proxyIP != nil && proxyIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
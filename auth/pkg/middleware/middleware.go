@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// ContextKey is the key under which the access details are stored in the echo context
+const ContextKey = "access_details"
+
+// QueryTokenParam is the query param accepted by QueryTokenMiddleware
+const QueryTokenParam = "access_token"
+
+// Middleware validates the bearer token from the Authorization header and
+// stores the access details in the echo context. Requests with the token in
+// the query string are rejected, the query ends up in the logs and Referer headers.
+func Middleware(validator Validator) echo.MiddlewareFunc {
+	return middleware(validator, false)
+}
+
+// QueryTokenMiddleware works like Middleware, but also accepts the token in the
+// access_token query param. It's meant only for the stream and download routes
+// opened directly by the video players, which can't set the headers.
+func QueryTokenMiddleware(validator Validator) echo.MiddlewareFunc {
+	return middleware(validator, true)
+}
+
+func middleware(validator Validator, allowQuery bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			errMsg := new(Error)
+
+			if !allowQuery && c.QueryParam(QueryTokenParam) != "" {
+				errMsg.Code = http.StatusBadRequest
+				errMsg.Message = "The token can't be passed in the query string on this route"
+				return c.JSON(errMsg.Code, errMsg)
+			}
+
+			tokenStr := bearerToken(c.Request(), allowQuery)
+			if tokenStr == "" {
+				errMsg.Code = http.StatusUnauthorized
+				errMsg.Message = "Missing authentication token"
+				return c.JSON(errMsg.Code, errMsg)
+			}
+
+			details, err := validator.Validate(tokenStr)
+			if err != nil {
+				errMsg.Code = http.StatusInternalServerError
+				if errors.Is(err, ErrInvalidToken) {
+					errMsg.Code = http.StatusUnauthorized
+				}
+				errMsg.Message = err.Error()
+				return c.JSON(errMsg.Code, errMsg)
+			}
+
+			c.Set(ContextKey, details)
+			return next(c)
+		}
+	}
+}
+
+// RequireScope allows only the users granted the scope to call the route, must be used after Middleware
+func RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !HasScope(GetAccessDetails(c), scope) {
+				errMsg := &Error{
+					Code:    http.StatusForbidden,
+					Message: fmt.Sprintf("The %s scope is required", scope),
+				}
+				return c.JSON(errMsg.Code, errMsg)
+			}
+			return next(c)
+		}
+	}
+}
+
+// GetAccessDetails returns the access details of the authenticated user
+func GetAccessDetails(c echo.Context) *AccessDetails {
+	details, _ := c.Get(ContextKey).(*AccessDetails)
+	return details
+}
+
+// bearerToken returns the token from the Authorization header or, if allowed,
+// from the access_token query param
+func bearerToken(req *http.Request, allowQuery bool) string {
+	header := req.Header.Get(echo.HeaderAuthorization)
+	if parts := strings.SplitN(header, " ", 2); len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+		return strings.TrimSpace(parts[1])
+	}
+	if allowQuery {
+		return req.URL.Query().Get(QueryTokenParam)
+	}
+	return ""
+}
@@ -0,0 +1,455 @@
+package middleware_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/pkg/middleware"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+)
+
+// MiddlewareTestSuite defines the test suite for the token validators and middleware
+type MiddlewareTestSuite struct {
+	suite.Suite
+}
+
+// SetupTest disables the logrus output
+func (suite *MiddlewareTestSuite) SetupTest() {
+	logrus.SetOutput(ioutil.Discard)
+}
+
+// TestMiddlewareTestSuite runs the test suite
+func TestMiddlewareTestSuite(t *testing.T) {
+	suite.Run(t, new(MiddlewareTestSuite))
+}
+
+// signToken creates the token in the same format as the authentication service
+func (suite *MiddlewareTestSuite) signToken(key *rsa.PrivateKey, kid string, isAdmin bool, expiresAt time.Time) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"Details": map[string]interface{}{"username": "JohnDoe", "is_admin": isAdmin},
+		"Uuid":    "b66a7219-f07f-49cf-8163-189da2f5c8cc",
+		"exp":     expiresAt.Unix(),
+	})
+	token.Header["kid"] = kid
+	tokenStr, err := token.SignedString(key)
+	suite.Require().Nil(err)
+	return tokenStr
+}
+
+func (suite *MiddlewareTestSuite) TestLocalValidator() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Require().Nil(err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Require().Nil(err)
+
+	fetches := 0
+	client := &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			fetches++
+			jwks := &middleware.JWKS{Keys: []*middleware.JWK{{
+				Kty: "RSA",
+				Kid: "current",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}}
+			body, _ := json.Marshal(jwks)
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader(body)),
+			}, nil
+		},
+	}
+	validator := middleware.NewLocalValidator(client, "http://auth/.well-known/jwks.json")
+
+	testCases := []struct {
+		name    string
+		token   string
+		isAdmin bool
+		wantErr bool
+	}{
+		{
+			name:    "Success",
+			token:   suite.signToken(key, "current", true, time.Now().Add(time.Minute)),
+			isAdmin: true,
+			wantErr: false,
+		},
+		{
+			name:    "Cached key",
+			token:   suite.signToken(key, "current", false, time.Now().Add(time.Minute)),
+			isAdmin: false,
+			wantErr: false,
+		},
+		{
+			name:    "Wrong key",
+			token:   suite.signToken(otherKey, "current", false, time.Now().Add(time.Minute)),
+			wantErr: true,
+		},
+		{
+			name:    "Unknown key id",
+			token:   suite.signToken(otherKey, "other", false, time.Now().Add(time.Minute)),
+			wantErr: true,
+		},
+		{
+			name:    "Expired token",
+			token:   suite.signToken(key, "current", false, time.Now().Add(-time.Minute)),
+			wantErr: true,
+		},
+		{
+			name:    "Not a token",
+			token:   "it's definitely not a token",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			details, err := validator.Validate(tt.token)
+			if tt.wantErr {
+				suite.Equal(middleware.ErrInvalidToken, err)
+				suite.Nil(details)
+			} else {
+				suite.Nil(err)
+				suite.Equal("JohnDoe", details.Username)
+				suite.Equal(tt.isAdmin, details.IsAdmin)
+			}
+		})
+	}
+	// keys are fetched once, unknown key doesn't trigger another fetch right away
+	suite.Equal(1, fetches)
+}
+
+func (suite *MiddlewareTestSuite) TestRemoteValidator() {
+	calls := 0
+	client := &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			calls++
+			body, _ := ioutil.ReadAll(req.Body)
+			if string(body) != `{"token":"valid"}` {
+				return &http.Response{
+					StatusCode: 401,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"code": 401, "message": "Token has been revoked"}`))),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"username": "JohnDoe", "is_admin": false, "Uuid": "b66a7219"}`))),
+			}, nil
+		},
+	}
+	validator := middleware.NewRemoteValidator(client, "http://auth/validate", time.Minute)
+
+	details, err := validator.Validate("valid")
+	suite.Nil(err)
+	suite.Equal("JohnDoe", details.Username)
+
+	// second call is served from the cache
+	details, err = validator.Validate("valid")
+	suite.Nil(err)
+	suite.Equal("JohnDoe", details.Username)
+	suite.Equal(1, calls)
+
+	// invalid tokens aren't cached
+	for i := 0; i < 2; i++ {
+		details, err = validator.Validate("revoked")
+		suite.Equal(middleware.ErrInvalidToken, err)
+		suite.Nil(details)
+	}
+	suite.Equal(3, calls)
+}
+
+func (suite *MiddlewareTestSuite) TestRemoteValidatorCacheExpiration() {
+	calls := 0
+	client := &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"username": "JohnDoe", "is_admin": false}`))),
+			}, nil
+		},
+	}
+	validator := middleware.NewRemoteValidator(client, "http://auth/validate", time.Millisecond)
+
+	_, err := validator.Validate("valid")
+	suite.Nil(err)
+	time.Sleep(5 * time.Millisecond)
+	_, err = validator.Validate("valid")
+	suite.Nil(err)
+	suite.Equal(2, calls)
+}
+
+// stubValidator accepts "admin", "user", "scanner" and "kid" tokens
+type stubValidator struct{}
+
+func (v *stubValidator) Validate(token string) (*middleware.AccessDetails, error) {
+	switch token {
+	case "admin":
+		return &middleware.AccessDetails{Username: "admin", IsAdmin: true}, nil
+	case "user":
+		return &middleware.AccessDetails{Username: "JohnDoe", IsAdmin: false}, nil
+	case "scanner":
+		return &middleware.AccessDetails{Username: "scanner", Scopes: []string{middleware.ScopeLibraryScan}}, nil
+	case "kid":
+		return &middleware.AccessDetails{Username: "kid", Scopes: []string{middleware.ScopeLibraryRead, middleware.ScopeStream}}, nil
+	}
+	return nil, middleware.ErrInvalidToken
+}
+
+func (suite *MiddlewareTestSuite) TestMiddleware() {
+	e := echo.New()
+	api := e.Group("/api", middleware.Middleware(&stubValidator{}))
+	api.GET("/read", func(c echo.Context) error {
+		return c.String(http.StatusOK, middleware.GetAccessDetails(c).Username)
+	}, middleware.RequireScope(middleware.ScopeLibraryRead))
+	api.POST("/update", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}, middleware.RequireScope(middleware.ScopeLibraryScan))
+	api.DELETE("/users", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}, middleware.RequireScope(middleware.ScopeUsersManage))
+	e.GET("/stream", func(c echo.Context) error {
+		return c.String(http.StatusOK, middleware.GetAccessDetails(c).Username)
+	}, middleware.QueryTokenMiddleware(&stubValidator{}))
+
+	testCases := []struct {
+		name               string
+		method             string
+		target             string
+		authorization      string
+		expectedStatusCode int
+	}{
+		{
+			name:               "Read as user",
+			method:             http.MethodGet,
+			target:             "/api/read",
+			authorization:      "Bearer user",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Token in the query param",
+			method:             http.MethodGet,
+			target:             "/api/read?access_token=user",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Token in the query param along with the header",
+			method:             http.MethodGet,
+			target:             "/api/read?access_token=admin",
+			authorization:      "Bearer user",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Stream with the token in the query param",
+			method:             http.MethodGet,
+			target:             "/stream?access_token=user",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Stream with the token in the header",
+			method:             http.MethodGet,
+			target:             "/stream",
+			authorization:      "Bearer user",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Stream with invalid token in the query param",
+			method:             http.MethodGet,
+			target:             "/stream?access_token=expired",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Missing token",
+			method:             http.MethodGet,
+			target:             "/api/read",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Invalid token",
+			method:             http.MethodGet,
+			target:             "/api/read",
+			authorization:      "Bearer expired",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Update as user",
+			method:             http.MethodPost,
+			target:             "/api/update",
+			authorization:      "Bearer user",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Update as admin",
+			method:             http.MethodPost,
+			target:             "/api/update",
+			authorization:      "Bearer admin",
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "Update as scanner",
+			method:             http.MethodPost,
+			target:             "/api/update",
+			authorization:      "Bearer scanner",
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "Read as scanner",
+			method:             http.MethodGet,
+			target:             "/api/read",
+			authorization:      "Bearer scanner",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Manage users as admin",
+			method:             http.MethodDelete,
+			target:             "/api/users",
+			authorization:      "Bearer admin",
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "Manage users as scanner",
+			method:             http.MethodDelete,
+			target:             "/api/users",
+			authorization:      "Bearer scanner",
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Read as kid",
+			method:             http.MethodGet,
+			target:             "/api/read",
+			authorization:      "Bearer kid",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Update as kid",
+			method:             http.MethodPost,
+			target:             "/api/update",
+			authorization:      "Bearer kid",
+			expectedStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.authorization != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			suite.Equal(tt.expectedStatusCode, rec.Code)
+		})
+	}
+}
+
+func (suite *MiddlewareTestSuite) TestLocalModeAPIKeys() {
+	client := &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if !strings.HasSuffix(req.URL.Path, "/validate") {
+				return &http.Response{
+					StatusCode: 200,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"keys": []}`))),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"username": "JohnDoe", "is_admin": false, "api_key": "0f3c5b5e"}`))),
+			}, nil
+		},
+	}
+	validator, err := middleware.NewValidator(client, middleware.Config{Mode: middleware.LocalMode})
+	suite.Require().Nil(err)
+
+	// API keys are validated by the authentication service
+	details, err := validator.Validate("xmk_hbQ4vHqTtJ3b7WcZ2Qx0aQ")
+	suite.Nil(err)
+	suite.Equal("JohnDoe", details.Username)
+
+	// access tokens are still validated locally
+	_, err = validator.Validate("it's definitely not a token")
+	suite.Equal(middleware.ErrInvalidToken, err)
+}
+
+func (suite *MiddlewareTestSuite) TestIssuerAndAudience() {
+	client := &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"username": "JohnDoe", "is_admin": false}`))),
+			}, nil
+		},
+	}
+	validator, err := middleware.NewValidator(client, middleware.Config{
+		Mode:     middleware.RemoteMode,
+		Issuer:   "http://localhost:8003",
+		Audience: "movie-svc",
+	})
+	suite.Require().Nil(err)
+
+	// the signature is checked by the authentication service
+	sign := func(claims jwt.MapClaims) string {
+		tokenStr, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		suite.Require().Nil(err)
+		return tokenStr
+	}
+
+	testCases := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{
+			name:    "Success",
+			token:   sign(jwt.MapClaims{"iss": "http://localhost:8003", "aud": []string{"movie-svc", "tvshow-svc"}}),
+			wantErr: false,
+		},
+		{
+			name:    "Another issuer",
+			token:   sign(jwt.MapClaims{"iss": "https://middleware.example.com", "aud": []string{"movie-svc", "tvshow-svc"}}),
+			wantErr: true,
+		},
+		{
+			name:    "Another audience",
+			token:   sign(jwt.MapClaims{"iss": "http://localhost:8003", "aud": []string{"music-svc"}}),
+			wantErr: true,
+		},
+		{
+			name:    "Missing claims",
+			token:   sign(jwt.MapClaims{"sub": "JohnDoe"}),
+			wantErr: true,
+		},
+		{
+			name:    "API key",
+			token:   "xmk_secret",
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			details, err := validator.Validate(tt.token)
+			if tt.wantErr {
+				suite.Equal(middleware.ErrInvalidToken, err)
+				suite.Nil(details)
+			} else {
+				suite.Nil(err)
+				suite.Equal("JohnDoe", details.Username)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+const (
+	// ScopeLibraryRead allows browsing the movies and the tv shows
+	ScopeLibraryRead = "library:read"
+	// ScopeLibraryScan allows updating the library from the disk and the metadata providers
+	ScopeLibraryScan = "library:scan"
+	// ScopeLibraryEdit allows fixing the metadata of the library
+	ScopeLibraryEdit = "library:edit"
+	// ScopeUsersManage allows managing the users
+	ScopeUsersManage = "users:manage"
+	// ScopeStream allows streaming the videos
+	ScopeStream = "stream"
+)
+
+// Scopes returns the scopes granted to the user, the tokens issued before the
+// scopes were introduced get the scopes of their admin flag
+func Scopes(details *AccessDetails) []string {
+	if len(details.Scopes) > 0 {
+		return details.Scopes
+	}
+	if details.IsAdmin {
+		return []string{ScopeLibraryRead, ScopeStream, ScopeLibraryScan, ScopeLibraryEdit, ScopeUsersManage}
+	}
+	return []string{ScopeLibraryRead, ScopeStream}
+}
+
+// HasScope checks if the user has been granted the scope
+func HasScope(details *AccessDetails, scope string) bool {
+	return details != nil && containsString(Scopes(details), scope)
+}
+
+// containsString checks if the value is in the slice
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
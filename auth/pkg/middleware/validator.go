@@ -0,0 +1,343 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// LocalMode validates tokens offline with the public keys of the authentication service
+	LocalMode = "local"
+	// RemoteMode validates tokens by calling the authentication service
+	RemoteMode = "remote"
+
+	defaultValidateURL = "http://xmedia-auth-svc:8003/api/v1/auth/token/validate"
+	defaultJWKSURL     = "http://xmedia-auth-svc:8003/.well-known/jwks.json"
+	defaultCacheTTL    = 30 * time.Second
+	// jwksRefreshInterval limits how often the public keys are fetched when
+	// the token is signed with an unknown key
+	jwksRefreshInterval = 10 * time.Second
+	// apiKeyPrefix distinguishes the API keys of the authentication service from the access tokens
+	apiKeyPrefix = "xmk_"
+)
+
+// ErrInvalidToken is returned when the token is missing, malformed, expired or revoked
+var ErrInvalidToken = errors.New("Invalid or expired token")
+
+// Validator validates the bearer token and returns the details of its owner
+type Validator interface {
+	Validate(token string) (*AccessDetails, error)
+}
+
+// Config defines how the tokens are validated, the empty fields get the defaults
+type Config struct {
+	// Mode is either LocalMode or RemoteMode, RemoteMode by default
+	Mode string
+	// JWKSURL is the JWKS endpoint of the authentication service used in LocalMode
+	JWKSURL string
+	// ValidateURL is the token validation endpoint of the authentication service
+	ValidateURL string
+	// CacheTTL is the number of seconds the remotely validated tokens are cached
+	CacheTTL int
+	// Issuer is the expected iss claim of the access tokens
+	Issuer string
+	// Audience is the service which has to be in the aud claim of the access tokens
+	Audience string
+}
+
+// NewValidator creates the token validator based on the configuration, the
+// issuer and the audience of the access tokens are checked if they're configured
+func NewValidator(httpClient HTTPClient, config Config) (Validator, error) {
+	var validator Validator
+	switch config.Mode {
+	case LocalMode:
+		url := config.JWKSURL
+		if url == "" {
+			url = defaultJWKSURL
+		}
+		// API keys aren't signed tokens, only the authentication service can validate them
+		validator = &apiKeyValidator{
+			tokens:  NewLocalValidator(httpClient, url),
+			apiKeys: newRemoteValidator(httpClient, config),
+		}
+	case RemoteMode, "":
+		validator = newRemoteValidator(httpClient, config)
+	default:
+		return nil, fmt.Errorf("Unknown auth mode: %s", config.Mode)
+	}
+
+	if config.Issuer == "" && config.Audience == "" {
+		return validator, nil
+	}
+	return &claimsValidator{
+		next:     validator,
+		issuer:   config.Issuer,
+		audience: config.Audience,
+	}, nil
+}
+
+// newRemoteValidator creates the remote validator from the configuration
+func newRemoteValidator(httpClient HTTPClient, config Config) Validator {
+	url := config.ValidateURL
+	if url == "" {
+		url = defaultValidateURL
+	}
+	ttl := defaultCacheTTL
+	if config.CacheTTL > 0 {
+		ttl = time.Duration(config.CacheTTL) * time.Second
+	}
+	return NewRemoteValidator(httpClient, url, ttl)
+}
+
+// apiKeyValidator validates the API keys with the authentication service and
+// the access tokens with another validator
+type apiKeyValidator struct {
+	tokens  Validator
+	apiKeys Validator
+}
+
+// Validate chooses the validator based on the token prefix
+func (v *apiKeyValidator) Validate(tokenStr string) (*AccessDetails, error) {
+	if strings.HasPrefix(tokenStr, apiKeyPrefix) {
+		return v.apiKeys.Validate(tokenStr)
+	}
+	return v.tokens.Validate(tokenStr)
+}
+
+// claimsValidator rejects the access tokens issued by another issuer or for
+// another audience before passing them to the next validator
+type claimsValidator struct {
+	next     Validator
+	issuer   string
+	audience string
+}
+
+// Validate checks the iss and aud claims of the access token, the signature
+// is verified by the next validator
+func (v *claimsValidator) Validate(tokenStr string) (*AccessDetails, error) {
+	if strings.HasPrefix(tokenStr, apiKeyPrefix) {
+		return v.next.Validate(tokenStr)
+	}
+
+	claims := new(tokenClaims)
+	if _, _, err := new(jwt.Parser).ParseUnverified(tokenStr, claims); err != nil {
+		log.Debugf("Couldn't parse the token: %v", err)
+		return nil, ErrInvalidToken
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		log.Debugf("Token [jti=%s] has been issued by %s", claims.Id, claims.Issuer)
+		return nil, ErrInvalidToken
+	}
+	if v.audience != "" && !containsString(claims.Audience, v.audience) {
+		log.Debugf("Token [jti=%s] hasn't been issued for %s", claims.Id, v.audience)
+		return nil, ErrInvalidToken
+	}
+	return v.next.Validate(tokenStr)
+}
+
+// tokenClaims defines the claims of the access token generated by the authentication service
+type tokenClaims struct {
+	Details  *AccessDetails
+	Audience []string `json:"aud,omitempty"` // shadows the single audience of jwt.StandardClaims
+	jwt.StandardClaims
+}
+
+type localValidator struct {
+	httpClient HTTPClient
+	url        string
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// NewLocalValidator creates the validator which checks the token signature
+// offline with the keys from the JWKS endpoint of the authentication service.
+// Revoked tokens are accepted until they expire.
+func NewLocalValidator(httpClient HTTPClient, url string) Validator {
+	return &localValidator{
+		httpClient: httpClient,
+		url:        url,
+		keys:       map[string]*rsa.PublicKey{},
+	}
+}
+
+// Validate checks the token signature and expiration time
+func (v *localValidator) Validate(tokenStr string) (*AccessDetails, error) {
+	claims := new(tokenClaims)
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return v.publicKey(kid)
+	})
+	if err != nil || !token.Valid || claims.Details == nil {
+		log.Debugf("Couldn't validate the token: %v", err)
+		return nil, ErrInvalidToken
+	}
+
+	return claims.Details, nil
+}
+
+// publicKey returns the key with provided id, keys are fetched again if the key is unknown
+func (v *localValidator) publicKey(kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(v.fetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("Unknown signing key: %s", kid)
+	}
+	v.fetchedAt = time.Now()
+
+	keys, err := fetchJWKS(v.httpClient, v.url)
+	if err != nil {
+		log.Errorf("Couldn't fetch the public keys: %v", err)
+		return nil, err
+	}
+	v.keys = keys
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("Unknown signing key: %s", kid)
+}
+
+// fetchJWKS calls the authentication service and returns its RSA public keys by id
+func fetchJWKS(httpClient HTTPClient, url string) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Expected 200 status code, got %d", res.StatusCode)
+	}
+
+	jwks := new(JWKS)
+	if err := json.NewDecoder(res.Body).Decode(jwks); err != nil {
+		return nil, err
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+// cachedDetails defines access details validated by the authentication service
+type cachedDetails struct {
+	details *AccessDetails
+	expires time.Time
+}
+
+type remoteValidator struct {
+	httpClient HTTPClient
+	url        string
+	ttl        time.Duration
+
+	mu    sync.Mutex
+	cache map[string]*cachedDetails
+}
+
+// NewRemoteValidator creates the validator which calls the authentication
+// service and caches valid tokens for the provided duration
+func NewRemoteValidator(httpClient HTTPClient, url string, ttl time.Duration) Validator {
+	return &remoteValidator{
+		httpClient: httpClient,
+		url:        url,
+		ttl:        ttl,
+		cache:      map[string]*cachedDetails{},
+	}
+}
+
+// Validate returns cached access details or calls the authentication service
+func (v *remoteValidator) Validate(tokenStr string) (*AccessDetails, error) {
+	if details := v.cached(tokenStr); details != nil {
+		return details, nil
+	}
+
+	body, err := json.Marshal(map[string]string{"token": tokenStr})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, v.url, bytes.NewBuffer(body))
+	if err != nil {
+		log.Errorf("Couldn't prepare the token validation request: %v", err)
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := v.httpClient.Do(req)
+	if err != nil {
+		log.Errorf("Couldn't connect to the authentication service: %v", err)
+		return nil, fmt.Errorf("Couldn't connect to the authentication service")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		log.Debugf("Token validation failed; status code: %d", res.StatusCode)
+		return nil, ErrInvalidToken
+	}
+	details := new(AccessDetails)
+	if err := json.NewDecoder(res.Body).Decode(details); err != nil {
+		log.Errorf("Couldn't decode the response from the authentication service: %v", err)
+		return nil, fmt.Errorf("Couldn't decode the response from the authentication service")
+	}
+
+	v.mu.Lock()
+	v.cache[tokenStr] = &cachedDetails{details, time.Now().Add(v.ttl)}
+	v.mu.Unlock()
+
+	return details, nil
+}
+
+// cached returns access details of the token if they haven't expired yet
+// and removes expired entries from the cache
+func (v *remoteValidator) cached(tokenStr string) *AccessDetails {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	for token, entry := range v.cache {
+		if now.After(entry.expires) {
+			delete(v.cache, token)
+		}
+	}
+	if entry, ok := v.cache[tokenStr]; ok {
+		return entry.details
+	}
+	return nil
+}
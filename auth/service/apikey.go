@@ -0,0 +1,167 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/go-playground/validator"
+	log "github.com/sirupsen/logrus"
+	"github.com/twinj/uuid"
+)
+
+const (
+	// APIKeyPrefix distinguishes the API keys from the access tokens
+	APIKeyPrefix = "xmk_"
+	// apiKeyLastUsedInterval limits how often the last used time of the key is saved
+	apiKeyLastUsedInterval = time.Minute
+)
+
+var (
+	// ErrAPIKeyNotFound is returned when the user has no API key with provided id
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrInvalidAPIKey is returned when the API key request is incomplete or
+	// requests the scopes which can't be granted to the user
+	ErrInvalidAPIKey = errors.New("Invalid API key request")
+)
+
+// CreateAPIKey creates new API key of the user, the key is stored only as a hash
+func (s *authService) CreateAPIKey(owner *models.AccessDetails, req *models.APIKeyRequest) (*models.CreatedAPIKey, error) {
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		log.Errorf("Couldn't validate the API key request of %s: %v", owner.Username, err)
+		return nil, fmt.Errorf("%w: name and at least one scope are required", ErrInvalidAPIKey)
+	}
+	isAdmin := owner.IsAdmin != nil && *owner.IsAdmin
+	allowed := allowedScopes(owner)
+	if !containsAll(allowed, req.Scopes) {
+		return nil, fmt.Errorf("%w: allowed scopes are %s", ErrInvalidAPIKey, strings.Join(allowed, ", "))
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return nil, fmt.Errorf("%w: expiration time must be in the future", ErrInvalidAPIKey)
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		log.Errorf("Couldn't generate the API key: %v", err)
+		return nil, fmt.Errorf("Couldn't generate the API key")
+	}
+	keyStr := APIKeyPrefix + secret
+	key := &models.APIKey{
+		ID:        uuid.NewV4().String(),
+		Name:      req.Name,
+		Username:  owner.Username,
+		IsAdmin:   isAdmin,
+		Scopes:    req.Scopes,
+		Prefix:    keyStr[:len(APIKeyPrefix)+8],
+		Hash:      hashToken(keyStr),
+		CreatedAt: time.Now(),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.repo.SaveAPIKey(key); err != nil {
+		log.Errorf("Couldn't save the API key of %s: %v", owner.Username, err)
+		return nil, fmt.Errorf("Couldn't save the API key")
+	}
+	key.Hash = ""
+
+	log.Infof("Successfully created the API key %s [id=%s] for %s", key.Name, key.ID, key.Username)
+	return &models.CreatedAPIKey{APIKey: key, Key: keyStr}, nil
+}
+
+// GetAPIKeys returns the API keys of the user sorted from the newest one
+func (s *authService) GetAPIKeys(username string) ([]*models.APIKey, error) {
+	keys, err := s.repo.GetAPIKeys(username)
+	if err != nil {
+		log.Errorf("Couldn't get the API keys of %s: %v", username, err)
+		return nil, fmt.Errorf("Couldn't get the API keys")
+	}
+	for _, key := range keys {
+		key.Hash = ""
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// RevokeAPIKey removes the API key of the user
+func (s *authService) RevokeAPIKey(username, id string) error {
+	key, err := s.repo.GetAPIKey(id)
+	if err != nil || key.Username != username {
+		log.Errorf("Couldn't find the API key [id=%s] of %s: %v", id, username, err)
+		return ErrAPIKeyNotFound
+	}
+
+	if err := s.repo.DeleteAPIKey(key); err != nil {
+		log.Errorf("Couldn't delete the API key [id=%s] of %s: %v", id, username, err)
+		return fmt.Errorf("Couldn't revoke the API key")
+	}
+
+	log.Infof("Successfully revoked the API key [id=%s] of %s", id, username)
+	return nil
+}
+
+// RevokeAPIKeys removes all of the API keys of the user
+func (s *authService) RevokeAPIKeys(username string) error {
+	keys, err := s.repo.GetAPIKeys(username)
+	if err != nil {
+		log.Errorf("Couldn't get the API keys of %s: %v", username, err)
+		return fmt.Errorf("Couldn't get the API keys")
+	}
+
+	for _, key := range keys {
+		if err := s.repo.DeleteAPIKey(key); err != nil {
+			log.Errorf("Couldn't delete the API key [id=%s] of %s: %v", key.ID, username, err)
+			return fmt.Errorf("Couldn't revoke the API keys")
+		}
+	}
+
+	log.Infof("Successfully revoked %d API keys of %s", len(keys), username)
+	return nil
+}
+
+// validateAPIKey checks if the API key exists and hasn't expired, the admin
+// privileges are kept only if the key has any of the admin scopes
+func (s *authService) validateAPIKey(keyStr string) (*models.UuidAccessDetails, error) {
+	key, err := s.activeAPIKey(keyStr)
+	if err != nil {
+		return nil, err
+	}
+
+	isAdmin := key.IsAdmin && containsAny(key.Scopes, adminScopes)
+	return &models.UuidAccessDetails{
+		AccessDetails: &models.AccessDetails{
+			Username: key.Username,
+			IsAdmin:  &isAdmin,
+			Scopes:   key.Scopes,
+		},
+		APIKey: key.ID,
+	}, nil
+}
+
+// activeAPIKey returns the API key which exists and hasn't expired, its last
+// used time is updated
+func (s *authService) activeAPIKey(keyStr string) (*models.APIKey, error) {
+	key, err := s.repo.GetAPIKeyByHash(hashToken(keyStr))
+	if err != nil {
+		log.Errorf("API key is no longer valid: %v", err)
+		return nil, ErrTokenRevoked
+	}
+	now := time.Now()
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		log.Errorf("API key [id=%s] of %s has expired", key.ID, key.Username)
+		return nil, ErrTokenRevoked
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyLastUsedInterval {
+		key.LastUsedAt = &now
+		if err := s.repo.SaveAPIKey(key); err != nil {
+			log.Warnf("Couldn't save the last used time of the API key [id=%s]: %v", key.ID, err)
+		}
+	}
+	return key, nil
+}
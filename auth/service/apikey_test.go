@@ -0,0 +1,136 @@
+package service_test
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+)
+
+func (suite *AuthServiceTestSuite) TestCreateAPIKey() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	isAdmin := true
+	user := &models.AccessDetails{Username: "JohnDoe", IsAdmin: new(bool)}
+	admin := &models.AccessDetails{Username: "admin", IsAdmin: &isAdmin}
+	scanner := &models.AccessDetails{Username: "scanner", IsAdmin: new(bool), Scopes: []string{"library:scan"}}
+	past := time.Now().Add(-time.Hour)
+
+	testCases := []struct {
+		name    string
+		owner   *models.AccessDetails
+		req     *models.APIKeyRequest
+		wantErr bool
+	}{
+		{
+			name:    "User scopes",
+			owner:   user,
+			req:     &models.APIKeyRequest{Name: "Home Assistant", Scopes: []string{"library:read", "stream"}},
+			wantErr: false,
+		},
+		{
+			name:    "Admin scopes",
+			owner:   admin,
+			req:     &models.APIKeyRequest{Name: "Nightly scan", Scopes: []string{"library:scan"}},
+			wantErr: false,
+		},
+		{
+			name:    "Admin scope requested by user",
+			owner:   user,
+			req:     &models.APIKeyRequest{Name: "Nightly scan", Scopes: []string{"library:scan"}},
+			wantErr: true,
+		},
+		{
+			name:    "Scope not granted by the role",
+			owner:   scanner,
+			req:     &models.APIKeyRequest{Name: "Home Assistant", Scopes: []string{"library:read"}},
+			wantErr: true,
+		},
+		{
+			name:    "Unknown scope",
+			owner:   admin,
+			req:     &models.APIKeyRequest{Name: "Everything", Scopes: []string{"*"}},
+			wantErr: true,
+		},
+		{
+			name:    "Missing scopes",
+			owner:   user,
+			req:     &models.APIKeyRequest{Name: "Home Assistant"},
+			wantErr: true,
+		},
+		{
+			name:    "Expiration time in the past",
+			owner:   user,
+			req:     &models.APIKeyRequest{Name: "Home Assistant", Scopes: []string{"stream"}, ExpiresAt: &past},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			key, err := suite.authService.CreateAPIKey(tt.owner, tt.req)
+			if tt.wantErr {
+				suite.True(errors.Is(err, service.ErrInvalidAPIKey))
+				suite.Nil(key)
+			} else {
+				suite.Nil(err)
+				suite.True(strings.HasPrefix(key.Key, service.APIKeyPrefix))
+				suite.True(strings.HasPrefix(key.Key, key.Prefix))
+				suite.Empty(key.Hash)
+				// only the hash is stored
+				stored := suite.authRepo.APIKeys[key.ID]
+				suite.NotEmpty(stored.Hash)
+				suite.NotContains(stored.Hash, key.Key)
+			}
+		})
+	}
+}
+
+func (suite *AuthServiceTestSuite) TestValidateAPIKey() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	isAdmin := true
+	admin := &models.AccessDetails{Username: "admin", IsAdmin: &isAdmin}
+
+	readOnly, err := suite.authService.CreateAPIKey(admin, &models.APIKeyRequest{Name: "Dashboard", Scopes: []string{"library:read"}})
+	suite.Require().Nil(err)
+	scan, err := suite.authService.CreateAPIKey(admin, &models.APIKeyRequest{Name: "Nightly scan", Scopes: []string{"library:scan"}})
+	suite.Require().Nil(err)
+	soon := time.Now().Add(time.Hour)
+	expiring, err := suite.authService.CreateAPIKey(admin, &models.APIKeyRequest{Name: "Temporary", Scopes: []string{"stream"}, ExpiresAt: &soon})
+	suite.Require().Nil(err)
+
+	// read only key of the admin doesn't have the admin privileges
+	details, err := suite.authService.ValidateToken(readOnly.Key)
+	suite.Require().Nil(err)
+	suite.Equal("admin", details.Username)
+	suite.False(*details.IsAdmin)
+	suite.Equal([]string{"library:read"}, details.Scopes)
+	suite.Equal(readOnly.ID, details.APIKey)
+	suite.NotNil(suite.authRepo.APIKeys[readOnly.ID].LastUsedAt)
+
+	details, err = suite.authService.ValidateToken(scan.Key)
+	suite.Require().Nil(err)
+	suite.True(*details.IsAdmin)
+
+	// expired key
+	suite.authRepo.APIKeys[expiring.ID].ExpiresAt = &time.Time{}
+	_, err = suite.authService.ValidateToken(expiring.Key)
+	suite.Equal(service.ErrTokenRevoked, err)
+
+	// unknown key
+	_, err = suite.authService.ValidateToken(service.APIKeyPrefix + "unknown")
+	suite.Equal(service.ErrTokenRevoked, err)
+
+	// revoked key
+	suite.Equal(service.ErrAPIKeyNotFound, suite.authService.RevokeAPIKey("JohnDoe", readOnly.ID))
+	suite.Nil(suite.authService.RevokeAPIKey("admin", readOnly.ID))
+	_, err = suite.authService.ValidateToken(readOnly.Key)
+	suite.Equal(service.ErrTokenRevoked, err)
+
+	keys, err := suite.authService.GetAPIKeys("admin")
+	suite.Nil(err)
+	suite.Require().Len(keys, 1)
+	suite.Equal(scan.ID, keys[0].ID)
+	suite.Empty(keys[0].Hash)
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/httpclient"
+	"github.com/0x113/x-media/auth/models"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// userServiceURL defines the base URL of the user service API
+	userServiceURL = "http://xmedia-user-svc:8002/api/v1/user"
+	// internalSecretHeader carries the shared secret of the internal routes of the user service
+	internalSecretHeader = "X-Internal-Secret"
+)
+
+var (
+	// ErrInvalidCredentials is returned by the credential backends when the
+	// username or password is wrong, only these failures count towards the lockout
+	ErrInvalidCredentials = errors.New("Invalid user credentials")
+	// ErrInvalidMFACode is returned when the code of the second factor is
+	// wrong, it counts towards the lockout as well
+	ErrInvalidMFACode = errors.New("Invalid authentication code")
+)
+
+var (
+	// errUserServiceRejected is returned when the user service responds with an error
+	errUserServiceRejected = errors.New("User service rejected the request")
+	// errUserNotFound is returned when the user service doesn't know the user
+	errUserNotFound = errors.New("User not found")
+	// errUserInactive is returned when the user has been deleted or disabled
+	errUserInactive = errors.New("User has been deleted or disabled")
+	// errUserHasPassword is returned when the provisioned user signs in with the password
+	errUserHasPassword = errors.New("User has the password")
+)
+
+// CredentialBackend checks the username and password provided to Login and
+// returns the details which are embedded in the access token, the details
+// with MFARequired set must be confirmed with the second factor. CurrentUser
+// returns the current role of the user checked on every refresh
+type CredentialBackend interface {
+	Authenticate(creds *models.Credentials) (*models.AccessDetails, error)
+	VerifySecondFactor(username, code string) (*models.AccessDetails, error)
+	CurrentUser(username string) (*models.AccessDetails, error)
+}
+
+// NewCredentialBackend creates the credential backend selected in the configuration
+func NewCredentialBackend(httpClient httpclient.HTTPClient) (CredentialBackend, error) {
+	switch common.Config.CredentialBackend {
+	case "", "user":
+		return NewUserServiceBackend(httpClient), nil
+	case "ldap":
+		return NewLDAPBackend(&common.Config.LDAP, httpClient), nil
+	default:
+		return nil, fmt.Errorf("Unknown credential backend: %s", common.Config.CredentialBackend)
+	}
+}
+
+type userServiceBackend struct {
+	httpClient httpclient.HTTPClient
+}
+
+// NewUserServiceBackend creates the credential backend validating the
+// passwords stored by the user service
+func NewUserServiceBackend(httpClient httpclient.HTTPClient) CredentialBackend {
+	return &userServiceBackend{httpClient}
+}
+
+// Authenticate calls the user service to check if provided credentials are correct
+func (b *userServiceBackend) Authenticate(creds *models.Credentials) (*models.AccessDetails, error) {
+	accessDetails, err := callUserService(b.httpClient, "/validate", creds)
+	if errors.Is(err, errUserServiceRejected) {
+		return nil, ErrInvalidCredentials
+	}
+	return accessDetails, err
+}
+
+// userServiceError is returned when the user service responds with an error,
+// it matches errUserServiceRejected, errUserNotFound for 404 and
+// errUserHasPassword for 409
+type userServiceError struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is sent along with 429 when the second factor is locked out
+	RetryAfter time.Duration
+}
+
+// rateLimitError converts 429 of the user service to RateLimitError
+func rateLimitError(err error) error {
+	var userErr *userServiceError
+	if errors.As(err, &userErr) && userErr.StatusCode == http.StatusTooManyRequests {
+		return &RateLimitError{userErr.RetryAfter}
+	}
+	return err
+}
+
+func (e *userServiceError) Error() string {
+	return fmt.Sprintf("%s: %s", errUserServiceRejected, e.Message)
+}
+
+func (e *userServiceError) Is(target error) bool {
+	return target == errUserServiceRejected ||
+		target == errUserNotFound && e.StatusCode == http.StatusNotFound ||
+		target == errUserHasPassword && e.StatusCode == http.StatusConflict
+}
+
+// VerifySecondFactor calls the user service to check the TOTP or recovery code
+func (b *userServiceBackend) VerifySecondFactor(username, code string) (*models.AccessDetails, error) {
+	return verifyTOTP(b.httpClient, username, code)
+}
+
+// verifyTOTP checks the second factor stored by the user service, it's used
+// by all of the backends as the secrets are always kept locally
+func verifyTOTP(httpClient httpclient.HTTPClient, username, code string) (*models.AccessDetails, error) {
+	payload := map[string]string{"username": username, "code": code}
+	accessDetails, err := callUserService(httpClient, "/totp/verify", payload)
+	var userErr *userServiceError
+	if errors.As(err, &userErr) && userErr.StatusCode == http.StatusUnauthorized {
+		return nil, ErrInvalidMFACode
+	}
+	return accessDetails, rateLimitError(err)
+}
+
+// CurrentUser calls the user service to get the current role of the user
+func (b *userServiceBackend) CurrentUser(username string) (*models.AccessDetails, error) {
+	return currentUser(b.httpClient, username)
+}
+
+// currentUser gets the current claims of the user from the user service, the
+// users of all of the backends have their local record there
+func currentUser(httpClient httpclient.HTTPClient, username string) (*models.AccessDetails, error) {
+	accessDetails := new(models.AccessDetails)
+	err := userServiceRequest(httpClient, http.MethodGet, "/claims?username="+url.QueryEscape(username), nil, accessDetails)
+	var userErr *userServiceError
+	if errors.As(err, &userErr) && (userErr.StatusCode == http.StatusNotFound || userErr.StatusCode == http.StatusForbidden) {
+		return nil, fmt.Errorf("%w: %s", errUserInactive, username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return accessDetails, nil
+}
+
+// callUserService posts the payload to the endpoint of the user service and
+// decodes the returned token claims
+func callUserService(httpClient httpclient.HTTPClient, endpoint string, payload interface{}) (*models.AccessDetails, error) {
+	accessDetails := new(models.AccessDetails)
+	if err := userServiceRequest(httpClient, http.MethodPost, endpoint, payload, accessDetails); err != nil {
+		return nil, err
+	}
+	return accessDetails, nil
+}
+
+// userServiceRequest sends the payload as json to the endpoint of the user
+// service and decodes the response into out, both of them are optional
+func userServiceRequest(httpClient httpclient.HTTPClient, method, endpoint string, payload, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		// convert payload to json
+		data, err := json.Marshal(payload)
+		if err != nil {
+			log.Errorf("Couldn't convert payload to json: %v", err)
+			return fmt.Errorf("Couldn't convert credentials to the json")
+		}
+		body = bytes.NewBuffer(data)
+	}
+	req, err := http.NewRequest(method, userServiceURL+endpoint, body)
+	if err != nil {
+		log.Errorf("Couldn't prepare request: %v", err)
+		return fmt.Errorf("Couldn't prepare the request")
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if common.Config.InternalSecret != "" {
+		req.Header.Set(internalSecretHeader, common.Config.InternalSecret)
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		log.Errorf("Couldn't to execute request: %v", err)
+		return fmt.Errorf("Couldn't connect to the user service")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		log.Errorf("Expected status code: %d, got: %d", http.StatusOK, res.StatusCode)
+		errMsg := new(models.Error)
+		if err := json.NewDecoder(res.Body).Decode(errMsg); err != nil {
+			return fmt.Errorf("Couldn't decode the response from the user service")
+		}
+		log.Errorf("User service rejected the request [endpoint=%s]: %s", endpoint, errMsg.Message)
+		retryAfter, _ := strconv.Atoi(res.Header.Get("Retry-After"))
+		return &userServiceError{StatusCode: res.StatusCode, Message: errMsg.Message, RetryAfter: time.Duration(retryAfter) * time.Second}
+	}
+
+	if out == nil {
+		return nil
+	}
+	// decode the response
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		log.Errorf("Couldn't decode the response: %v", err)
+		return fmt.Errorf("Couldn't decode the response from the user service")
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/models"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DeviceCodeGrantType defines the grant type used by the devices to poll the token endpoint (RFC 8628)
+	DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+	// DeviceCodeTTL defines how long the user can approve the device
+	DeviceCodeTTL = 10 * time.Minute
+	// DevicePollInterval defines the minimum number of seconds between the polls of the device
+	DevicePollInterval = 5
+
+	// userCodeAlphabet contains only consonants, so the user codes are easy
+	// to type on a TV remote and never form words
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+
+	devicePending  = "pending"
+	deviceApproved = "approved"
+	deviceDenied   = "denied"
+)
+
+// ErrDeviceCodeNotFound is returned when the user code doesn't exist, has expired or has been already used
+var ErrDeviceCodeNotFound = errors.New("User code not found or already used")
+
+// DeviceAuthorization issues the device code and the user code for the device
+func (s *oauthService) DeviceAuthorization(req *models.DeviceCodeRequest) (*models.DeviceCodeResponse, error) {
+	client, err := s.authenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	scopes, err := parseScopes(req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceCode, err := randomToken()
+	if err != nil {
+		log.Errorf("Couldn't generate the device code: %v", err)
+		return nil, fmt.Errorf("Couldn't generate the device code")
+	}
+	userCode, err := s.uniqueUserCode()
+	if err != nil {
+		log.Errorf("Couldn't generate the user code: %v", err)
+		return nil, fmt.Errorf("Couldn't generate the user code")
+	}
+
+	auth := &models.DeviceAuthorization{
+		DeviceCodeHash: hashToken(deviceCode),
+		UserCode:       userCode,
+		ClientID:       client.ClientID,
+		Scope:          strings.Join(scopes, " "),
+		Interval:       DevicePollInterval,
+		ExpiresAt:      time.Now().Add(DeviceCodeTTL),
+		Status:         devicePending,
+	}
+	if err := s.repo.SaveDeviceAuthorization(auth); err != nil {
+		log.Errorf("Couldn't save the device authorization of the client %s: %v", client.ClientID, err)
+		return nil, fmt.Errorf("Couldn't save the device authorization")
+	}
+
+	verificationURI := deviceURL()
+	log.Infof("Successfully issued the device code for the client %s", client.ClientID)
+	return &models.DeviceCodeResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(userCode),
+		ExpiresIn:               int64(DeviceCodeTTL.Seconds()),
+		Interval:                DevicePollInterval,
+	}, nil
+}
+
+// CheckDeviceCode returns what the device with provided user code requests,
+// presented to the user before the approval
+func (s *oauthService) CheckDeviceCode(userCode string, user *models.UuidAccessDetails) (*models.AuthorizationInfo, error) {
+	auth, err := s.pendingDevice(userCode)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.repo.GetClient(auth.ClientID)
+	if err != nil {
+		log.Errorf("Couldn't get the client [id=%s] of the device: %v", auth.ClientID, err)
+		return nil, ErrDeviceCodeNotFound
+	}
+
+	scopes := grantedScopes(strings.Fields(auth.Scope), user.AccessDetails)
+	consented := false
+	if granted, err := s.repo.GetConsent(user.Username, client.ClientID); err == nil {
+		consented = containsAll(strings.Fields(granted), scopes)
+	}
+
+	return &models.AuthorizationInfo{
+		ClientID:   client.ClientID,
+		ClientName: client.Name,
+		Scopes:     scopes,
+		Consented:  consented,
+	}, nil
+}
+
+// ApproveDevice stores the decision of the user, the device receives the
+// tokens on its next poll
+func (s *oauthService) ApproveDevice(req *models.DeviceApproval, user *models.UuidAccessDetails) error {
+	auth, err := s.pendingDevice(req.UserCode)
+	if err != nil {
+		return err
+	}
+
+	auth.Status = deviceDenied
+	if req.Approved {
+		scopes := grantedScopes(strings.Fields(auth.Scope), user.AccessDetails)
+		if len(scopes) == 0 {
+			return &models.OAuthError{Code: "invalid_scope", Description: "User hasn't been granted any of the requested scopes"}
+		}
+		auth.Status = deviceApproved
+		auth.Username = user.Username
+		auth.Scope = strings.Join(scopes, " ")
+		auth.IsAdmin = user.IsAdmin != nil && *user.IsAdmin && containsAny(scopes, adminScopes)
+		auth.UserScopes = scopes
+		if err := s.repo.SaveConsent(user.Username, auth.ClientID, auth.Scope); err != nil {
+			log.Errorf("Couldn't save the consent of %s to the client %s: %v", user.Username, auth.ClientID, err)
+			return fmt.Errorf("Couldn't save the consent")
+		}
+	}
+	if err := s.repo.SaveDeviceAuthorization(auth); err != nil {
+		log.Errorf("Couldn't save the device authorization [user_code=%s]: %v", auth.UserCode, err)
+		return fmt.Errorf("Couldn't save the device authorization")
+	}
+
+	log.Infof("%s %s the device [user_code=%s] of the client %s", user.Username, auth.Status, auth.UserCode, auth.ClientID)
+	return nil
+}
+
+// exchangeDeviceCode issues the tokens once the user approves the device,
+// devices polling more often than the interval are asked to slow down
+func (s *oauthService) exchangeDeviceCode(req *models.TokenRequest, client *models.ClientInfo) (*models.OAuthTokenResponse, error) {
+	hash := hashToken(req.DeviceCode)
+	auth, err := s.repo.GetDeviceAuthorization(hash)
+	if err != nil {
+		log.Errorf("Couldn't get the device authorization of the client %s: %v", client.ClientID, err)
+		return nil, &models.OAuthError{Code: "expired_token", Description: "Device code is invalid or expired"}
+	}
+	if auth.ClientID != client.ClientID {
+		log.Errorf("Device code has been issued to the client %s, used by %s", auth.ClientID, client.ClientID)
+		return nil, &models.OAuthError{Code: "invalid_grant", Description: "Device code has been issued to another client"}
+	}
+
+	allowed, err := s.repo.TouchDevicePoll(hash, time.Duration(auth.Interval)*time.Second)
+	if err != nil {
+		log.Errorf("Couldn't record the poll of the device [user_code=%s]: %v", auth.UserCode, err)
+		return nil, fmt.Errorf("Couldn't check the device authorization")
+	}
+	if !allowed {
+		auth.Interval += DevicePollInterval
+		if err := s.repo.SaveDeviceAuthorization(auth); err != nil {
+			log.Errorf("Couldn't save the device authorization [user_code=%s]: %v", auth.UserCode, err)
+		}
+		return nil, &models.OAuthError{Code: "slow_down", Description: fmt.Sprintf("Poll at most every %d seconds", auth.Interval)}
+	}
+
+	switch auth.Status {
+	case deviceApproved:
+	case deviceDenied:
+		s.repo.DeleteDeviceAuthorization(auth)
+		return nil, &models.OAuthError{Code: "access_denied", Description: "User denied access to the device"}
+	default:
+		return nil, &models.OAuthError{Code: "authorization_pending", Description: "User hasn't approved the device yet"}
+	}
+
+	// the device code can be exchanged only once
+	if err := s.repo.DeleteDeviceAuthorization(auth); err != nil {
+		log.Errorf("Couldn't delete the device authorization [user_code=%s]: %v", auth.UserCode, err)
+		return nil, fmt.Errorf("Couldn't delete the device authorization")
+	}
+	token, err := s.authService.IssueTokens(&models.AccessDetails{
+		Username: auth.Username,
+		IsAdmin:  &auth.IsAdmin,
+		Scopes:   auth.UserScopes,
+	}, client)
+	if err != nil {
+		return nil, err // no need to log, IssueTokens does it
+	}
+	response := tokenResponse(token, auth.Scope)
+
+	if containsAll(strings.Fields(auth.Scope), []string{"openid"}) {
+		response.IDToken, err = s.idToken(auth.Username, auth.Scope, "", client.ClientID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// pendingDevice returns the device authorization with provided user code which
+// hasn't been approved or denied yet
+func (s *oauthService) pendingDevice(userCode string) (*models.DeviceAuthorization, error) {
+	auth, err := s.repo.GetDeviceAuthorizationByUserCode(normalizeUserCode(userCode))
+	if err != nil {
+		log.Errorf("Couldn't get the device authorization [user_code=%s]: %v", userCode, err)
+		return nil, ErrDeviceCodeNotFound
+	}
+	if auth.Status != devicePending {
+		log.Errorf("Device authorization [user_code=%s] has been already %s", userCode, auth.Status)
+		return nil, ErrDeviceCodeNotFound
+	}
+	return auth, nil
+}
+
+// uniqueUserCode generates the user code which isn't used by another pending device
+func (s *oauthService) uniqueUserCode() (string, error) {
+	for i := 0; i < 5; i++ {
+		code, err := generateUserCode()
+		if err != nil {
+			return "", err
+		}
+		if _, err := s.repo.GetDeviceAuthorizationByUserCode(code); err != nil {
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("Couldn't generate unique user code")
+}
+
+// generateUserCode generates random user code in the XXXX-XXXX format
+func generateUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return normalizeUserCode(string(code)), nil
+}
+
+// normalizeUserCode converts the code entered by the user to the XXXX-XXXX
+// format, case and separators are ignored
+func normalizeUserCode(userCode string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(userCode) {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteRune(r)
+		}
+	}
+	code := b.String()
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
+
+// deviceURL returns the page where the users enter the codes of their devices
+func deviceURL() string {
+	if common.Config.OAuthDeviceURL != "" {
+		return common.Config.OAuthDeviceURL
+	}
+	return issuer() + "/device"
+}
@@ -0,0 +1,124 @@
+package service_test
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+)
+
+func (suite *AuthServiceTestSuite) TestDeviceFlow() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	oauthRepo := mocks.NewMockOAuthRepository()
+	oauthService := service.NewOAuthService(oauthRepo, suite.authService, suite.keys)
+	client, err := oauthService.RegisterClient(&models.ClientRegistration{
+		Name:         "Kodi add-on",
+		RedirectURIs: []string{"http://127.0.0.1:8123/callback"},
+	})
+	suite.Require().Nil(err)
+	user := &models.UuidAccessDetails{AccessDetails: &models.AccessDetails{Username: "JohnDoe", IsAdmin: new(bool)}}
+
+	_, err = oauthService.DeviceAuthorization(&models.DeviceCodeRequest{ClientID: client.ClientID, Scope: "admin"})
+	suite.Equal("invalid_scope", oauthErrorCode(err))
+	_, err = oauthService.DeviceAuthorization(&models.DeviceCodeRequest{ClientID: client.ClientID})
+	suite.Equal("invalid_scope", oauthErrorCode(err))
+
+	codes, err := oauthService.DeviceAuthorization(&models.DeviceCodeRequest{ClientID: client.ClientID, Scope: "openid stream users:manage"})
+	suite.Require().Nil(err)
+	suite.Regexp(`^[BCDFGHJKLMNPQRSTVWXZ]{4}-[BCDFGHJKLMNPQRSTVWXZ]{4}$`, codes.UserCode)
+	suite.Equal(service.DevicePollInterval, codes.Interval)
+	suite.Equal("http://localhost:8003/device?user_code="+codes.UserCode, codes.VerificationURIComplete)
+
+	poll := func() (*models.OAuthTokenResponse, error) {
+		return oauthService.Token(&models.TokenRequest{
+			GrantType:  service.DeviceCodeGrantType,
+			DeviceCode: codes.DeviceCode,
+			ClientID:   client.ClientID,
+		}, testClient)
+	}
+	// allows the next poll right away
+	resetPolls := func() {
+		for hash := range oauthRepo.Polls {
+			delete(oauthRepo.Polls, hash)
+		}
+	}
+
+	_, err = poll()
+	suite.Equal("authorization_pending", oauthErrorCode(err))
+	_, err = poll()
+	suite.Equal("slow_down", oauthErrorCode(err))
+	for _, device := range oauthRepo.Devices {
+		suite.Equal(2*service.DevicePollInterval, device.Interval)
+	}
+
+	// user code is case and separator insensitive
+	typed := strings.ToLower(strings.Replace(codes.UserCode, "-", " ", 1))
+	info, err := oauthService.CheckDeviceCode(typed, user)
+	suite.Nil(err)
+	suite.Equal("Kodi add-on", info.ClientName)
+	suite.Equal([]string{"openid", service.ScopeStream}, info.Scopes) // the user can't grant users:manage
+	_, err = oauthService.CheckDeviceCode("BBBB-BBBB", user)
+	suite.Equal(service.ErrDeviceCodeNotFound, err)
+
+	suite.Nil(oauthService.ApproveDevice(&models.DeviceApproval{UserCode: typed, Approved: true}, user))
+	// user code can be used only once
+	suite.Equal(service.ErrDeviceCodeNotFound, oauthService.ApproveDevice(&models.DeviceApproval{UserCode: typed, Approved: false}, user))
+
+	resetPolls()
+	token, err := poll()
+	suite.Require().Nil(err)
+	suite.NotEmpty(token.IDToken)
+	suite.Equal("openid stream", token.Scope)
+	accessDetails, err := suite.authService.ValidateToken(token.AccessToken)
+	suite.Nil(err)
+	suite.Equal("JohnDoe", accessDetails.Username)
+	suite.Equal([]string{"openid", service.ScopeStream}, accessDetails.Scopes)
+
+	// device code can be exchanged only once
+	resetPolls()
+	_, err = poll()
+	suite.Equal("expired_token", oauthErrorCode(err))
+}
+
+func (suite *AuthServiceTestSuite) TestDeviceFlowDenied() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	oauthService := service.NewOAuthService(mocks.NewMockOAuthRepository(), suite.authService, suite.keys)
+	client, err := oauthService.RegisterClient(&models.ClientRegistration{
+		Name:         "Kodi add-on",
+		RedirectURIs: []string{"http://127.0.0.1:8123/callback"},
+	})
+	suite.Require().Nil(err)
+	other, err := oauthService.RegisterClient(&models.ClientRegistration{
+		Name:         "Other",
+		RedirectURIs: []string{"http://127.0.0.1:8123/callback"},
+	})
+	suite.Require().Nil(err)
+	user := &models.UuidAccessDetails{AccessDetails: &models.AccessDetails{Username: "JohnDoe", IsAdmin: new(bool)}}
+
+	codes, err := oauthService.DeviceAuthorization(&models.DeviceCodeRequest{ClientID: client.ClientID, Scope: "stream"})
+	suite.Require().Nil(err)
+	suite.Nil(oauthService.ApproveDevice(&models.DeviceApproval{UserCode: codes.UserCode, Approved: false}, user))
+
+	req := &models.TokenRequest{
+		GrantType:  service.DeviceCodeGrantType,
+		DeviceCode: codes.DeviceCode,
+		ClientID:   other.ClientID,
+	}
+	_, err = oauthService.Token(req, testClient)
+	suite.Equal("invalid_grant", oauthErrorCode(err))
+
+	req.ClientID = client.ClientID
+	_, err = oauthService.Token(req, testClient)
+	suite.Equal("access_denied", oauthErrorCode(err))
+}
+
+// oauthErrorCode returns the code of the OAuth error
+func oauthErrorCode(err error) string {
+	var oauthErr *models.OAuthError
+	if errors.As(err, &oauthErr) {
+		return oauthErr.Code
+	}
+	return ""
+}
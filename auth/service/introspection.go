@@ -0,0 +1,117 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/dgrijalva/jwt-go"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	accessTokenHint  = "access_token"
+	refreshTokenHint = "refresh_token"
+)
+
+// Introspect returns the state and the claims of the access token, refresh
+// token or API key (RFC 7662). Invalid, expired and revoked tokens are inactive.
+func (s *authService) Introspect(tokenStr, tokenTypeHint string) *models.Introspection {
+	if strings.HasPrefix(tokenStr, APIKeyPrefix) {
+		return s.introspectAPIKey(tokenStr)
+	}
+
+	// the hint only changes the order in which the token types are tried
+	hints := []string{accessTokenHint, refreshTokenHint}
+	if tokenTypeHint == refreshTokenHint {
+		hints = []string{refreshTokenHint, accessTokenHint}
+	}
+	for _, hint := range hints {
+		keyFunc := s.publicKeyFunc
+		if hint == refreshTokenHint {
+			keyFunc = secretKeyFunc(common.Config.RefreshSecret)
+		}
+		if introspection := s.introspectJWT(tokenStr, keyFunc); introspection != nil {
+			if hint == accessTokenHint {
+				introspection.TokenType = "Bearer"
+			}
+			return introspection
+		}
+	}
+	return &models.Introspection{Active: false}
+}
+
+// introspectJWT returns the claims of the token signed with the key from the
+// key func, nil is returned if the token is invalid or revoked
+func (s *authService) introspectJWT(tokenStr string, keyFunc jwt.Keyfunc) *models.Introspection {
+	claims, err := parseClaims(tokenStr, keyFunc)
+	if err != nil {
+		return nil
+	}
+	if username, err := s.repo.Get(claims.TokenID()); err != nil || username != claims.Details.Username {
+		log.Debugf("Introspected token [jti=%s] of %s has been revoked: %v", claims.TokenID(), claims.Details.Username, err)
+		return nil
+	}
+
+	introspection := &models.Introspection{
+		Active:    true,
+		Scope:     strings.Join(allowedScopes(claims.Details), " "),
+		Username:  claims.Details.Username,
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		NotBefore: claims.NotBefore,
+		Subject:   claims.Subject,
+		Audience:  claims.Audience,
+		Issuer:    claims.Issuer,
+		ID:        claims.TokenID(),
+		IsAdmin:   claims.Details.IsAdmin != nil && *claims.Details.IsAdmin,
+	}
+	if family, err := s.repo.GetFamily(claims.Family); err == nil {
+		introspection.ClientID = family.ClientID
+	}
+	return introspection
+}
+
+// introspectAPIKey returns the claims of the API key
+func (s *authService) introspectAPIKey(keyStr string) *models.Introspection {
+	key, err := s.activeAPIKey(keyStr)
+	if err != nil {
+		return &models.Introspection{Active: false}
+	}
+
+	introspection := &models.Introspection{
+		Active:    true,
+		Scope:     strings.Join(key.Scopes, " "),
+		Username:  key.Username,
+		TokenType: "Bearer",
+		IssuedAt:  key.CreatedAt.Unix(),
+		Subject:   key.Username,
+		Audience:  tokenAudience(),
+		Issuer:    issuer(),
+		ID:        key.ID,
+		IsAdmin:   key.IsAdmin && containsAny(key.Scopes, adminScopes),
+	}
+	if key.ExpiresAt != nil {
+		introspection.ExpiresAt = key.ExpiresAt.Unix()
+	}
+	return introspection
+}
+
+// Introspect returns the state of the token to the confidential client, e.g.
+// a reverse proxy protecting the services
+func (s *oauthService) Introspect(req *models.IntrospectionRequest) (*models.Introspection, error) {
+	client, err := s.authenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !client.Confidential {
+		log.Errorf("Public client [id=%s] can't introspect the tokens", client.ClientID)
+		return nil, &models.OAuthError{Code: "invalid_client", Description: "Only the confidential clients can introspect the tokens"}
+	}
+	if req.Token == "" {
+		return nil, &models.OAuthError{Code: "invalid_request", Description: "Token is required"}
+	}
+
+	return s.authService.Introspect(req.Token, req.TokenTypeHint), nil
+}
@@ -0,0 +1,136 @@
+package service_test
+
+import (
+	"errors"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func (suite *AuthServiceTestSuite) TestRegisteredClaims() {
+	token := suite.generateToken("JohnDoe", false)
+
+	claims := new(models.TokenClaims)
+	_, _, err := new(jwt.Parser).ParseUnverified(token.AccessToken, claims)
+	suite.Require().Nil(err)
+	suite.Equal("JohnDoe", claims.Subject)
+	suite.Equal("http://localhost:8003", claims.Issuer)
+	suite.Equal([]string{"movie-svc", "tvshow-svc", "user-svc"}, claims.Audience)
+	suite.Equal(token.AccessUuid, claims.Id)
+	suite.Empty(claims.Uuid)
+	suite.NotZero(claims.IssuedAt)
+	suite.Equal(claims.IssuedAt, claims.NotBefore)
+
+	// refresh tokens are accepted only by the authentication service
+	claims = new(models.TokenClaims)
+	_, _, err = new(jwt.Parser).ParseUnverified(token.RefreshToken, claims)
+	suite.Require().Nil(err)
+	suite.Equal([]string{"http://localhost:8003"}, claims.Audience)
+	suite.Equal(token.RefreshUuid, claims.Id)
+}
+
+func (suite *AuthServiceTestSuite) TestRejectForeignIssuer() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	token := suite.generateToken("JohnDoe", false)
+
+	common.Config.Issuer = "https://auth.example.com"
+	_, err := suite.authService.ValidateToken(token.AccessToken)
+	suite.NotNil(err)
+	_, err = suite.authService.Refresh(token.RefreshToken, testClient)
+	suite.NotNil(err)
+}
+
+func (suite *AuthServiceTestSuite) TestIntrospect() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	token := suite.generateToken("JohnDoe", false)
+	isAdmin := true
+	key, err := suite.authService.CreateAPIKey(&models.AccessDetails{Username: "admin", IsAdmin: &isAdmin}, &models.APIKeyRequest{
+		Name:   "Nightly scan",
+		Scopes: []string{service.ScopeLibraryScan},
+	})
+	suite.Require().Nil(err)
+
+	access := suite.authService.Introspect(token.AccessToken, "")
+	suite.True(access.Active)
+	suite.Equal("Bearer", access.TokenType)
+	suite.Equal("JohnDoe", access.Subject)
+	suite.Equal("library:read stream", access.Scope)
+	suite.Equal(token.AccessUuid, access.ID)
+	suite.Equal(token.AtExpires, access.ExpiresAt)
+
+	refresh := suite.authService.Introspect(token.RefreshToken, "refresh_token")
+	suite.True(refresh.Active)
+	suite.Equal(token.RefreshUuid, refresh.ID)
+	suite.Empty(refresh.TokenType)
+	// the hint is only an optimization
+	suite.True(suite.authService.Introspect(token.RefreshToken, "access_token").Active)
+
+	apiKey := suite.authService.Introspect(key.Key, "")
+	suite.True(apiKey.Active)
+	suite.Equal("admin", apiKey.Username)
+	suite.Equal("library:scan", apiKey.Scope)
+	suite.True(apiKey.IsAdmin)
+
+	// invalid and revoked tokens are inactive
+	suite.Equal(&models.Introspection{Active: false}, suite.authService.Introspect("invalid", ""))
+	suite.Equal(&models.Introspection{Active: false}, suite.authService.Introspect(service.APIKeyPrefix+"invalid", ""))
+	suite.Require().Nil(suite.authService.Logout(token.AccessToken))
+	suite.False(suite.authService.Introspect(token.AccessToken, "").Active)
+	suite.False(suite.authService.Introspect(token.RefreshToken, "refresh_token").Active)
+}
+
+func (suite *AuthServiceTestSuite) TestOAuthIntrospect() {
+	oauthService, publicClient := suite.newOAuthService()
+	confidential, err := oauthService.RegisterClient(&models.ClientRegistration{
+		Name:         "Reverse proxy",
+		RedirectURIs: []string{"https://media.example.com/callback"},
+		Confidential: true,
+	})
+	suite.Require().Nil(err)
+	token := suite.generateToken("JohnDoe", false)
+
+	testCases := []struct {
+		name     string
+		req      *models.IntrospectionRequest
+		wantCode string
+	}{
+		{
+			name:     "Public client",
+			req:      &models.IntrospectionRequest{Token: token.AccessToken, ClientID: publicClient.ClientID},
+			wantCode: "invalid_client",
+		},
+		{
+			name:     "Wrong secret",
+			req:      &models.IntrospectionRequest{Token: token.AccessToken, ClientID: confidential.ClientID, ClientSecret: "wrong"},
+			wantCode: "invalid_client",
+		},
+		{
+			name:     "Missing token",
+			req:      &models.IntrospectionRequest{ClientID: confidential.ClientID, ClientSecret: confidential.ClientSecret},
+			wantCode: "invalid_request",
+		},
+		{
+			name: "Success",
+			req:  &models.IntrospectionRequest{Token: token.AccessToken, ClientID: confidential.ClientID, ClientSecret: confidential.ClientSecret},
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			introspection, err := oauthService.Introspect(tt.req)
+			if tt.wantCode != "" {
+				var oauthErr *models.OAuthError
+				suite.True(errors.As(err, &oauthErr))
+				suite.Equal(tt.wantCode, oauthErr.Code)
+				suite.Nil(introspection)
+			} else {
+				suite.Nil(err)
+				suite.True(introspection.Active)
+				suite.Equal("JohnDoe", introspection.Username)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0x113/x-media/auth/data"
+	"github.com/0x113/x-media/auth/models"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/twinj/uuid"
+)
+
+const (
+	rsaKeySize = 2048
+	// keyCheckInterval defines how often the key manager reloads the keys and
+	// checks if the signing key should be rotated
+	keyCheckInterval = time.Minute
+	// keyReloadInterval limits how often the requests for the key set or an
+	// unknown key id can reload the keys from the database
+	keyReloadInterval = 10 * time.Second
+	// KeyRetentionMargin defines how long the retired key is published after
+	// its last access token has expired, it covers the clock skew between the services
+	KeyRetentionMargin = 5 * time.Minute
+	// keyRotationLockTTL defines how long the instance rotating the signing key
+	// blocks the rotation on the other instances
+	keyRotationLockTTL = 30 * time.Second
+)
+
+// KeyManager manages the RSA keys used to sign the access tokens
+type KeyManager interface {
+	SigningKey() (string, *rsa.PrivateKey, error)
+	PublicKey(kid string) (*rsa.PublicKey, error)
+	JWKS() (*models.JWKS, error)
+	Rotate() error
+	RotateIfDue(interval time.Duration) error
+	StartRotation(interval time.Duration)
+}
+
+// parsedKey defines the signing key along with its parsed private key
+type parsedKey struct {
+	*models.SigningKey
+	privateKey *rsa.PrivateKey
+}
+
+type keyManager struct {
+	repo data.KeyRepository
+
+	mu       sync.RWMutex
+	keys     []*parsedKey // sorted from the newest one
+	loadedAt time.Time
+}
+
+// NewKeyManager creates new instance of the key manager
+func NewKeyManager(repo data.KeyRepository) KeyManager {
+	return &keyManager{repo: repo}
+}
+
+// SigningKey returns the id and the private key of the newest key, new key
+// is generated if there is no key yet
+func (m *keyManager) SigningKey() (string, *rsa.PrivateKey, error) {
+	if key := m.current(); key != nil {
+		return key.Kid, key.privateKey, nil
+	}
+	if err := m.reload(); err != nil {
+		return "", nil, err
+	}
+	if key := m.current(); key != nil {
+		return key.Kid, key.privateKey, nil
+	}
+	if err := m.Rotate(); err != nil {
+		return "", nil, err
+	}
+	key := m.current()
+	return key.Kid, key.privateKey, nil
+}
+
+// PublicKey returns the public key with provided id, keys are reloaded from
+// the database if the key is unknown e.g. it has been generated by another instance
+func (m *keyManager) PublicKey(kid string) (*rsa.PublicKey, error) {
+	if key := m.find(kid); key != nil {
+		return &key.privateKey.PublicKey, nil
+	}
+	if err := m.reloadIfStale(); err != nil {
+		return nil, err
+	}
+	if key := m.find(kid); key != nil {
+		return &key.privateKey.PublicKey, nil
+	}
+	return nil, fmt.Errorf("Unknown signing key: %s", kid)
+}
+
+// JWKS returns all of the published public keys
+func (m *keyManager) JWKS() (*models.JWKS, error) {
+	if err := m.reloadIfStale(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	jwks := &models.JWKS{Keys: []*models.JWK{}}
+	for _, key := range m.keys {
+		pub := key.privateKey.PublicKey
+		jwks.Keys = append(jwks.Keys, &models.JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: "RS256",
+			Kid: key.Kid,
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		})
+	}
+	return jwks, nil
+}
+
+// Rotate generates new signing key and retires the older ones. Retired keys
+// are still published until the access tokens signed with them expire.
+func (m *keyManager) Rotate() error {
+	privateKey, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
+	if err != nil {
+		log.Errorf("Couldn't generate the RSA key: %v", err)
+		return fmt.Errorf("Couldn't generate new signing key")
+	}
+	now := time.Now()
+	key := &models.SigningKey{
+		Kid: uuid.NewV4().String(),
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		})),
+		CreatedAt: now,
+	}
+	if err := m.repo.SaveKey(key); err != nil {
+		log.Errorf("Couldn't save the signing key: %v", err)
+		return fmt.Errorf("Couldn't save new signing key")
+	}
+
+	// retire the older keys, the key generated by another instance in the
+	// meantime stays the current one
+	keys, err := m.repo.GetKeys()
+	if err != nil {
+		log.Errorf("Couldn't get the signing keys: %v", err)
+		return fmt.Errorf("Couldn't get the signing keys")
+	}
+	for _, k := range keys {
+		if k.RetiredAt != nil || !k.CreatedAt.Before(key.CreatedAt) {
+			continue
+		}
+		k.RetiredAt = &now
+		if err := m.repo.SaveKey(k); err != nil {
+			log.Errorf("Couldn't retire the signing key [kid=%s]: %v", k.Kid, err)
+			return fmt.Errorf("Couldn't retire the previous signing key")
+		}
+	}
+
+	log.Infof("Successfully rotated the signing key [kid=%s]", key.Kid)
+	return m.reload()
+}
+
+// RotateIfDue rotates the signing key if it's older than provided interval,
+// only one of the instances sharing the database rotates the key at a time
+func (m *keyManager) RotateIfDue(interval time.Duration) error {
+	if err := m.reload(); err != nil {
+		return err
+	}
+	if !m.isDue(interval) {
+		return nil
+	}
+
+	locked, err := m.repo.LockRotation(keyRotationLockTTL)
+	if err != nil {
+		log.Errorf("Couldn't lock the signing key rotation: %v", err)
+		return fmt.Errorf("Couldn't lock the signing key rotation")
+	}
+	if !locked {
+		log.Debug("The signing key is being rotated by another instance")
+		return nil
+	}
+	// the key could have been rotated by another instance before the lock was claimed
+	if err := m.reload(); err != nil {
+		return err
+	}
+	if !m.isDue(interval) {
+		return nil
+	}
+	return m.Rotate()
+}
+
+// isDue checks if the current signing key is older than provided interval
+func (m *keyManager) isDue(interval time.Duration) bool {
+	key := m.current()
+	return key == nil || time.Since(key.CreatedAt) >= interval
+}
+
+// StartRotation checks periodically in the background if the signing key
+// should be rotated
+func (m *keyManager) StartRotation(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(keyCheckInterval)
+		defer ticker.Stop()
+		for {
+			if err := m.RotateIfDue(interval); err != nil {
+				log.Errorf("Couldn't rotate the signing key: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// reload loads the keys from the database and removes the retired keys
+// which can no longer verify any access token
+func (m *keyManager) reload() error {
+	keys, err := m.repo.GetKeys()
+	if err != nil {
+		log.Errorf("Couldn't get the signing keys: %v", err)
+		return fmt.Errorf("Couldn't get the signing keys")
+	}
+
+	parsed := []*parsedKey{}
+	for _, key := range keys {
+		if key.RetiredAt != nil && time.Since(*key.RetiredAt) > AccessTokenTTL+KeyRetentionMargin {
+			if err := m.repo.DeleteKey(key.Kid); err != nil {
+				log.Errorf("Couldn't delete expired signing key [kid=%s]: %v", key.Kid, err)
+			}
+			continue
+		}
+		block, _ := pem.Decode([]byte(key.PrivateKey))
+		if block == nil {
+			log.Errorf("Couldn't decode the signing key [kid=%s]", key.Kid)
+			continue
+		}
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			log.Errorf("Couldn't parse the signing key [kid=%s]: %v", key.Kid, err)
+			continue
+		}
+		parsed = append(parsed, &parsedKey{key, privateKey})
+	}
+	sort.Slice(parsed, func(i, j int) bool {
+		return parsed[i].CreatedAt.After(parsed[j].CreatedAt)
+	})
+
+	m.mu.Lock()
+	m.keys = parsed
+	m.loadedAt = time.Now()
+	m.mu.Unlock()
+	return nil
+}
+
+// reloadIfStale reloads the keys only if they haven't been loaded within the
+// keyReloadInterval, so the unauthenticated requests can't hammer the database
+func (m *keyManager) reloadIfStale() error {
+	m.mu.Lock()
+	loadedAt := m.loadedAt
+	if time.Since(loadedAt) < keyReloadInterval {
+		m.mu.Unlock()
+		return nil
+	}
+	// claim the reload, the concurrent requests are served from the cache
+	m.loadedAt = time.Now()
+	m.mu.Unlock()
+
+	if err := m.reload(); err != nil {
+		m.mu.Lock()
+		m.loadedAt = loadedAt
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// current returns the newest key which hasn't been retired
+func (m *keyManager) current() *parsedKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, key := range m.keys {
+		if key.RetiredAt == nil {
+			return key
+		}
+	}
+	return nil
+}
+
+// find returns the loaded key with provided id
+func (m *keyManager) find(kid string) *parsedKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, key := range m.keys {
+		if key.Kid == kid {
+			return key
+		}
+	}
+	return nil
+}
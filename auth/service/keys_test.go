@@ -0,0 +1,150 @@
+package service_test
+
+import (
+	"time"
+
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/twinj/uuid"
+)
+
+func (suite *AuthServiceTestSuite) TestKeyRotation() {
+	keyRepo := mocks.NewMockKeyRepository()
+	keys := service.NewKeyManager(keyRepo)
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, keys, suite.limiter)
+
+	// the first key is generated on demand
+	token, err := suite.authService.GenerateJWT(&models.AccessDetails{Username: "JohnDoe", IsAdmin: new(bool)})
+	suite.Require().Nil(err)
+	suite.Len(keyRepo.Keys, 1)
+
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token.AccessToken, &models.TokenClaims{})
+	suite.Require().Nil(err)
+	suite.Equal("RS256", parsed.Header["alg"])
+	oldKid := parsed.Header["kid"].(string)
+	suite.NotEmpty(oldKid)
+
+	// key isn't rotated before the interval passes
+	suite.Nil(keys.RotateIfDue(time.Hour))
+	suite.Len(keyRepo.Keys, 1)
+
+	suite.Nil(keys.RotateIfDue(0))
+	suite.Len(keyRepo.Keys, 2)
+
+	// new tokens are signed with the new key, old ones are still valid
+	newToken, err := suite.authService.GenerateJWT(&models.AccessDetails{Username: "JohnDoe", IsAdmin: new(bool)})
+	suite.Require().Nil(err)
+	parsed, _, err = new(jwt.Parser).ParseUnverified(newToken.AccessToken, &models.TokenClaims{})
+	suite.Require().Nil(err)
+	suite.NotEqual(oldKid, parsed.Header["kid"])
+
+	_, err = suite.authService.ExtractAccessTokenMetadata(token.AccessToken)
+	suite.Nil(err)
+	_, err = suite.authService.ExtractAccessTokenMetadata(newToken.AccessToken)
+	suite.Nil(err)
+
+	jwks, err := suite.authService.JWKS()
+	suite.Nil(err)
+	suite.Len(jwks.Keys, 2)
+	for _, jwk := range jwks.Keys {
+		suite.Equal("RSA", jwk.Kty)
+		suite.Equal("RS256", jwk.Alg)
+		suite.Equal("AQAB", jwk.E)
+	}
+
+	// retired key is still published for the clock skew margin after its access tokens have expired
+	retiredAt := time.Now().Add(-service.AccessTokenTTL - time.Minute)
+	keyRepo.Keys[oldKid].RetiredAt = &retiredAt
+	suite.Nil(keys.RotateIfDue(time.Hour))
+	jwks, err = suite.authService.JWKS()
+	suite.Nil(err)
+	suite.Len(jwks.Keys, 2)
+
+	_, err = suite.authService.ExtractAccessTokenMetadata(token.AccessToken)
+	suite.Nil(err)
+
+	// and removed on the next reload once the margin passes
+	retiredAt = time.Now().Add(-service.AccessTokenTTL - service.KeyRetentionMargin - time.Minute)
+	keyRepo.Keys[oldKid].RetiredAt = &retiredAt
+	suite.Nil(keys.RotateIfDue(time.Hour))
+	jwks, err = suite.authService.JWKS()
+	suite.Nil(err)
+	suite.Len(jwks.Keys, 1)
+	suite.NotContains(keyRepo.Keys, oldKid)
+
+	_, err = suite.authService.ExtractAccessTokenMetadata(token.AccessToken)
+	suite.NotNil(err)
+}
+
+func (suite *AuthServiceTestSuite) TestKeyRotationLock() {
+	keyRepo := mocks.NewMockKeyRepository()
+	keys := service.NewKeyManager(keyRepo)
+	kid, _, err := keys.SigningKey()
+	suite.Require().Nil(err)
+
+	// the key isn't rotated while another instance holds the lock
+	keyRepo.LockedUntil = time.Now().Add(time.Minute)
+	suite.Nil(keys.RotateIfDue(0))
+	suite.Len(keyRepo.Keys, 1)
+
+	keyRepo.LockedUntil = time.Time{}
+	suite.Nil(keys.RotateIfDue(0))
+	suite.Len(keyRepo.Keys, 2)
+	suite.NotNil(keyRepo.Keys[kid].RetiredAt)
+
+	// the newer key saved by another instance in the meantime isn't retired
+	newer := *keyRepo.Keys[kid]
+	newer.Kid = uuid.NewV4().String()
+	newer.CreatedAt = time.Now().Add(time.Second)
+	newer.RetiredAt = nil
+	keyRepo.Keys[newer.Kid] = &newer
+	suite.Nil(keys.Rotate())
+	suite.Len(keyRepo.Keys, 4)
+	suite.Nil(keyRepo.Keys[newer.Kid].RetiredAt)
+
+	current, _, err := keys.SigningKey()
+	suite.Nil(err)
+	suite.Equal(newer.Kid, current)
+}
+
+func (suite *AuthServiceTestSuite) TestKeyReloadLimit() {
+	keyRepo := mocks.NewMockKeyRepository()
+	kid, _, err := service.NewKeyManager(keyRepo).SigningKey()
+	suite.Require().Nil(err)
+
+	// the key generated by another instance is loaded on demand
+	keys := service.NewKeyManager(keyRepo)
+	loads := keyRepo.Loads
+	_, err = keys.PublicKey(kid)
+	suite.Nil(err)
+	suite.Equal(loads+1, keyRepo.Loads)
+
+	// the cached keys are served to the following requests
+	for i := 0; i < 10; i++ {
+		_, err = keys.PublicKey(uuid.NewV4().String())
+		suite.NotNil(err)
+		jwks, err := keys.JWKS()
+		suite.Nil(err)
+		suite.Len(jwks.Keys, 1)
+	}
+	suite.Equal(loads+1, keyRepo.Loads)
+}
+
+func (suite *AuthServiceTestSuite) TestTokenTypes() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	token, err := suite.authService.GenerateJWT(&models.AccessDetails{Username: "JohnDoe", IsAdmin: new(bool)})
+	suite.Require().Nil(err)
+
+	// refresh token can't be used as the access token and vice versa
+	_, err = suite.authService.ExtractAccessTokenMetadata(token.RefreshToken)
+	suite.NotNil(err)
+	_, err = suite.authService.ExtractTokenMetadata(token.AccessToken, "refresh_secret")
+	suite.NotNil(err)
+
+	details, err := suite.authService.ExtractTokenMetadata(token.RefreshToken, "refresh_secret")
+	suite.Nil(err)
+	suite.Equal("JohnDoe", details.Username)
+}
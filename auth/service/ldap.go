@@ -0,0 +1,175 @@
+package service
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/httpclient"
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/go-ldap/ldap/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ldapTimeout defines how long the LDAP requests can take
+	ldapTimeout = 10 * time.Second
+	// defaultUserFilter is used when the user filter isn't configured
+	defaultUserFilter = "(uid=%s)"
+	// defaultGroupAttribute is used when the group attribute isn't configured
+	defaultGroupAttribute = "memberOf"
+)
+
+type ldapBackend struct {
+	config     *common.LDAPConfig
+	httpClient httpclient.HTTPClient
+}
+
+// provisionRequest describes the user authenticated by the directory or the
+// identity provider, the user service creates its local record on the first login
+type provisionRequest struct {
+	Username        string `json:"username"`
+	Admin           *bool  `json:"admin,omitempty"` // the role of the existing user is kept when it's nil
+	ExistingOnly    bool   `json:"existing_only"`
+	WithoutPassword bool   `json:"without_password,omitempty"` // the existing users with the password are refused
+}
+
+// NewLDAPBackend creates the credential backend which binds to the LDAP
+// directory as the user, the local user record is provisioned by the user service
+func NewLDAPBackend(config *common.LDAPConfig, httpClient httpclient.HTTPClient) CredentialBackend {
+	return &ldapBackend{config, httpClient}
+}
+
+// VerifySecondFactor calls the user service to check the TOTP or recovery code,
+// the directory doesn't know the second factor
+func (b *ldapBackend) VerifySecondFactor(username, code string) (*models.AccessDetails, error) {
+	return verifyTOTP(b.httpClient, username, code)
+}
+
+// CurrentUser calls the user service to get the current role of the
+// provisioned user, the groups are checked again only on the next login
+func (b *ldapBackend) CurrentUser(username string) (*models.AccessDetails, error) {
+	return currentUser(b.httpClient, username)
+}
+
+// Authenticate searches the directory for the user, binds as the found entry
+// with provided password and maps its groups to the admin role
+func (b *ldapBackend) Authenticate(creds *models.Credentials) (*models.AccessDetails, error) {
+	// the empty password would result in the unauthenticated bind, which succeeds
+	if creds.Username == "" || creds.Password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := b.dial()
+	if err != nil {
+		log.Errorf("Couldn't connect to the LDAP server: %v", err)
+		return nil, fmt.Errorf("Couldn't connect to the LDAP server")
+	}
+	defer conn.Close()
+
+	if b.config.BindDN != "" {
+		err = conn.Bind(b.config.BindDN, b.config.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		log.Errorf("Couldn't bind to the LDAP server as the service account: %v", err)
+		return nil, fmt.Errorf("Couldn't connect to the LDAP server")
+	}
+
+	entry, err := b.findUser(conn, creds.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, creds.Password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			log.Errorf("Wrong LDAP password for user [username=%s]", creds.Username)
+			return nil, ErrInvalidCredentials
+		}
+		log.Errorf("Couldn't bind to the LDAP server as the user [username=%s]: %v", creds.Username, err)
+		return nil, fmt.Errorf("Couldn't connect to the LDAP server")
+	}
+
+	admin := b.isAdmin(entry)
+	accessDetails, err := callUserService(b.httpClient, "/provision", &provisionRequest{Username: creds.Username, Admin: &admin})
+	if err != nil {
+		log.Errorf("Couldn't provision LDAP user [username=%s]: %v", creds.Username, err)
+		return nil, fmt.Errorf("Couldn't provision the local user")
+	}
+
+	log.Infof("Successfully authenticated LDAP user [username=%s, admin=%t]", creds.Username, admin)
+	return accessDetails, nil
+}
+
+// dial connects to the directory and upgrades the connection if StartTLS is enabled
+func (b *ldapBackend) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(b.config.URL)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(ldapTimeout)
+
+	if b.config.StartTLS {
+		u, err := url.Parse(b.config.URL)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// findUser searches for the entry of the user, the username must match exactly one entry
+func (b *ldapBackend) findUser(conn *ldap.Conn, username string) (*ldap.Entry, error) {
+	filter := b.config.UserFilter
+	if filter == "" {
+		filter = defaultUserFilter
+	}
+	req := ldap.NewSearchRequest(
+		b.config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout/time.Second), false,
+		fmt.Sprintf(filter, ldap.EscapeFilter(username)),
+		[]string{"dn", b.groupAttribute()},
+		nil,
+	)
+
+	res, err := conn.Search(req)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		log.Errorf("Couldn't search for LDAP user [username=%s]: %v", username, err)
+		return nil, fmt.Errorf("Couldn't search the LDAP directory")
+	}
+	if res == nil || len(res.Entries) != 1 {
+		log.Errorf("Expected exactly one LDAP entry for user [username=%s]", username)
+		return nil, ErrInvalidCredentials
+	}
+	return res.Entries[0], nil
+}
+
+// isAdmin checks if the user is a member of any of the admin groups
+func (b *ldapBackend) isAdmin(entry *ldap.Entry) bool {
+	for _, group := range entry.GetEqualFoldAttributeValues(b.groupAttribute()) {
+		for _, adminGroup := range b.config.AdminGroups {
+			if strings.EqualFold(strings.TrimSpace(group), strings.TrimSpace(adminGroup)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// groupAttribute returns the attribute listing the groups of the user
+func (b *ldapBackend) groupAttribute() string {
+	if b.config.GroupAttribute != "" {
+		return b.config.GroupAttribute
+	}
+	return defaultGroupAttribute
+}
@@ -0,0 +1,221 @@
+package service_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+)
+
+const (
+	ldapBaseDN     = "ou=people,dc=example,dc=org"
+	ldapAdminGroup = "cn=xmedia-admins,ou=groups,dc=example,dc=org"
+)
+
+// newLDAPServer starts the directory with the service account and three users
+func (suite *AuthServiceTestSuite) newLDAPServer() *mocks.MockLDAPServer {
+	server, err := mocks.NewMockLDAPServer(
+		&mocks.LDAPEntry{DN: "cn=xmedia,ou=services,dc=example,dc=org", Password: "service"},
+		&mocks.LDAPEntry{
+			DN:       "uid=alice," + ldapBaseDN,
+			Password: "alicepass",
+			Attributes: map[string][]string{
+				"uid":      {"alice"},
+				"memberOf": {"CN=xmedia-admins,ou=groups,dc=example,dc=org", "cn=staff,ou=groups,dc=example,dc=org"},
+			},
+		},
+		&mocks.LDAPEntry{
+			DN:         "uid=bob," + ldapBaseDN,
+			Password:   "bobpass",
+			Attributes: map[string][]string{"uid": {"bob"}, "memberOf": {"cn=staff,ou=groups,dc=example,dc=org"}},
+		},
+		// two entries with the same uid are ambiguous
+		&mocks.LDAPEntry{DN: "uid=carol,ou=a," + ldapBaseDN, Password: "carolpass", Attributes: map[string][]string{"uid": {"carol"}}},
+		&mocks.LDAPEntry{DN: "uid=carol,ou=b," + ldapBaseDN, Password: "carolpass", Attributes: map[string][]string{"uid": {"carol"}}},
+	)
+	suite.Require().Nil(err)
+	return server
+}
+
+// provisionClient mocks the provision endpoint of the user service and stores the received requests
+func provisionClient(received *[]map[string]interface{}) *mocks.MockClient {
+	return &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			payload := map[string]interface{}{"url": req.URL.String(), "secret": req.Header.Get("X-Internal-Secret")}
+			json.NewDecoder(req.Body).Decode(&payload)
+			*received = append(*received, payload)
+			jsonStr := fmt.Sprintf(`{"username": %q, "is_admin": %t}`, payload["username"], payload["admin"])
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(jsonStr))),
+			}, nil
+		},
+	}
+}
+
+func (suite *AuthServiceTestSuite) TestLDAPBackend() {
+	server := suite.newLDAPServer()
+	defer server.Close()
+	config := &common.LDAPConfig{
+		URL:          server.URL,
+		BindDN:       "cn=xmedia,ou=services,dc=example,dc=org",
+		BindPassword: "service",
+		BaseDN:       ldapBaseDN,
+		AdminGroups:  []string{ldapAdminGroup},
+	}
+	common.Config.InternalSecret = "internal_secret"
+
+	testCases := []struct {
+		name          string
+		creds         *models.Credentials
+		expectedAdmin bool
+		wantErr       error
+	}{
+		{
+			name:          "Admin group member",
+			creds:         &models.Credentials{Username: "alice", Password: "alicepass"},
+			expectedAdmin: true,
+		},
+		{
+			name:          "Regular user",
+			creds:         &models.Credentials{Username: "bob", Password: "bobpass"},
+			expectedAdmin: false,
+		},
+		{
+			name:    "Wrong password",
+			creds:   &models.Credentials{Username: "bob", Password: "alicepass"},
+			wantErr: service.ErrInvalidCredentials,
+		},
+		{
+			name:    "Empty password",
+			creds:   &models.Credentials{Username: "bob", Password: ""},
+			wantErr: service.ErrInvalidCredentials,
+		},
+		{
+			name:    "Unknown user",
+			creds:   &models.Credentials{Username: "dave", Password: "davepass"},
+			wantErr: service.ErrInvalidCredentials,
+		},
+		{
+			name:    "Ambiguous user",
+			creds:   &models.Credentials{Username: "carol", Password: "carolpass"},
+			wantErr: service.ErrInvalidCredentials,
+		},
+		{
+			name:    "Filter injection",
+			creds:   &models.Credentials{Username: "*", Password: "alicepass"},
+			wantErr: service.ErrInvalidCredentials,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			var received []map[string]interface{}
+			backend := service.NewLDAPBackend(config, provisionClient(&received))
+			details, err := backend.Authenticate(tt.creds)
+			if tt.wantErr != nil {
+				suite.True(errors.Is(err, tt.wantErr))
+				suite.Nil(details)
+				suite.Empty(received)
+				return
+			}
+			suite.Require().Nil(err)
+			suite.Equal(tt.creds.Username, details.Username)
+			suite.Equal(tt.expectedAdmin, *details.IsAdmin)
+
+			// the local user is provisioned with the mapped admin flag
+			suite.Require().Len(received, 1)
+			suite.Equal("http://xmedia-user-svc:8002/api/v1/user/provision", received[0]["url"])
+			suite.Equal("internal_secret", received[0]["secret"])
+			suite.Equal(tt.expectedAdmin, received[0]["admin"])
+		})
+	}
+}
+
+func (suite *AuthServiceTestSuite) TestLDAPBackendUnavailable() {
+	server := suite.newLDAPServer()
+	defer server.Close()
+	var received []map[string]interface{}
+	creds := &models.Credentials{Username: "alice", Password: "alicepass"}
+
+	// wrong service account password isn't the user's fault
+	backend := service.NewLDAPBackend(&common.LDAPConfig{
+		URL:          server.URL,
+		BindDN:       "cn=xmedia,ou=services,dc=example,dc=org",
+		BindPassword: "wrong",
+		BaseDN:       ldapBaseDN,
+	}, provisionClient(&received))
+	_, err := backend.Authenticate(creds)
+	suite.NotNil(err)
+	suite.False(errors.Is(err, service.ErrInvalidCredentials))
+
+	// anonymous bind is used without the service account
+	backend = service.NewLDAPBackend(&common.LDAPConfig{URL: server.URL, BaseDN: ldapBaseDN}, provisionClient(&received))
+	details, err := backend.Authenticate(creds)
+	suite.Require().Nil(err)
+	suite.False(*details.IsAdmin)
+
+	// the user service is down
+	backend = service.NewLDAPBackend(&common.LDAPConfig{URL: server.URL, BaseDN: ldapBaseDN}, &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		},
+	})
+	_, err = backend.Authenticate(creds)
+	suite.NotNil(err)
+	suite.False(errors.Is(err, service.ErrInvalidCredentials))
+
+	server.Close()
+	_, err = backend.Authenticate(creds)
+	suite.NotNil(err)
+	suite.False(errors.Is(err, service.ErrInvalidCredentials))
+}
+
+func (suite *AuthServiceTestSuite) TestLoginWithLDAP() {
+	server := suite.newLDAPServer()
+	defer server.Close()
+	var received []map[string]interface{}
+	backend := service.NewLDAPBackend(&common.LDAPConfig{
+		URL:         server.URL,
+		BaseDN:      ldapBaseDN,
+		AdminGroups: []string{ldapAdminGroup},
+	}, provisionClient(&received))
+	common.Config.LoginMaxAttempts = 2
+	authService := service.NewAuthService(backend, suite.authRepo, suite.keys, suite.limiter)
+
+	token, err := authService.Login(&models.Credentials{Username: "alice", Password: "alicepass"}, testClient)
+	suite.Require().Nil(err)
+	details, err := authService.ValidateToken(token.AccessToken)
+	suite.Require().Nil(err)
+	suite.Equal("alice", details.Username)
+	suite.True(*details.IsAdmin)
+
+	// the wrong LDAP passwords count towards the lockout
+	for i := 0; i < 2; i++ {
+		_, err = authService.Login(&models.Credentials{Username: "bob", Password: "wrong"}, testClient)
+		suite.True(errors.Is(err, service.ErrInvalidCredentials))
+	}
+	_, err = authService.Login(&models.Credentials{Username: "bob", Password: "bobpass"}, testClient)
+	var rateLimitErr *service.RateLimitError
+	suite.True(errors.As(err, &rateLimitErr))
+}
+
+func (suite *AuthServiceTestSuite) TestNewCredentialBackend() {
+	for _, name := range []string{"", "user", "ldap"} {
+		common.Config.CredentialBackend = name
+		backend, err := service.NewCredentialBackend(suite.httpClient)
+		suite.Nil(err)
+		suite.NotNil(backend)
+	}
+
+	common.Config.CredentialBackend = "kerberos"
+	_, err := service.NewCredentialBackend(suite.httpClient)
+	suite.NotNil(err)
+}
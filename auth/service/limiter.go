@@ -0,0 +1,195 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/data"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMaxUserAttempts = 5
+	defaultMaxIPAttempts   = 20
+	defaultAttemptsWindow  = 15 * time.Minute
+	defaultLockout         = time.Minute
+	defaultMaxLockout      = time.Hour
+	// lockoutLevelTTL defines after how long without the lockout its duration is reset
+	lockoutLevelTTL = 24 * time.Hour
+)
+
+// RateLimitError is returned when the login attempts are blocked
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("Too many failed login attempts, try again in %d seconds", retryAfterSeconds(e.RetryAfter))
+}
+
+// RetryAfterSeconds returns the value of the Retry-After header
+func (e *RateLimitError) RetryAfterSeconds() int {
+	return retryAfterSeconds(e.RetryAfter)
+}
+
+// retryAfterSeconds rounds the duration up to the full seconds
+func retryAfterSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// LoginLimiter throttles the failed login attempts per username and per IP
+// address with the sliding window and locks them out for exponentially longer
+type LoginLimiter interface {
+	Check(username, ip string) error
+	Failure(username, ip string)
+	Success(username string)
+	UnlockUser(username string) error
+	UnlockIP(ip string) error
+}
+
+type loginLimiter struct {
+	repo data.LoginAttemptRepository
+}
+
+// NewLoginLimiter creates new instance of the login limiter
+func NewLoginLimiter(repo data.LoginAttemptRepository) LoginLimiter {
+	return &loginLimiter{repo}
+}
+
+// limit defines the limit of the failed attempts for the key
+type limit struct {
+	key         string
+	maxAttempts int
+}
+
+// limits returns the limits for provided username and ip address
+func limits(username, ip string) []*limit {
+	maxUserAttempts := common.Config.LoginMaxAttempts
+	if maxUserAttempts <= 0 {
+		maxUserAttempts = defaultMaxUserAttempts
+	}
+	maxIPAttempts := common.Config.LoginMaxIPAttempts
+	if maxIPAttempts <= 0 {
+		maxIPAttempts = defaultMaxIPAttempts
+	}
+
+	limits := []*limit{{userKey(username), maxUserAttempts}}
+	if ip != "" {
+		limits = append(limits, &limit{ipKey(ip), maxIPAttempts})
+	}
+	return limits
+}
+
+// Check returns RateLimitError if the username or the ip address is locked out
+func (l *loginLimiter) Check(username, ip string) error {
+	var retryAfter time.Duration
+	for _, limit := range limits(username, ip) {
+		lockout, err := l.repo.GetLockout(limit.key)
+		if err != nil {
+			log.Errorf("Couldn't check the lockout of %s: %v", limit.key, err)
+			continue // don't block the login if the database is down
+		}
+		if lockout > retryAfter {
+			retryAfter = lockout
+		}
+	}
+
+	if retryAfter > 0 {
+		log.Warnf("Login attempt of %s from %s is blocked for %v", username, ip, retryAfter)
+		return &RateLimitError{retryAfter}
+	}
+	return nil
+}
+
+// Failure records the failed attempt and locks out the username or the ip
+// address which exceeded the limit
+func (l *loginLimiter) Failure(username, ip string) {
+	window := time.Duration(common.Config.LoginWindowSeconds) * time.Second
+	if window <= 0 {
+		window = defaultAttemptsWindow
+	}
+
+	for _, limit := range limits(username, ip) {
+		count, err := l.repo.AddFailure(limit.key, time.Now(), window)
+		if err != nil {
+			log.Errorf("Couldn't save the failed login attempt of %s: %v", limit.key, err)
+			continue
+		}
+		if count < limit.maxAttempts {
+			continue
+		}
+
+		level, err := l.repo.IncrLockoutLevel(limit.key, lockoutLevelTTL)
+		if err != nil {
+			log.Errorf("Couldn't increment the lockout level of %s: %v", limit.key, err)
+			level = 1
+		}
+		duration := lockoutDuration(level)
+		if err := l.repo.Lock(limit.key, duration); err != nil {
+			log.Errorf("Couldn't lock out %s: %v", limit.key, err)
+			continue
+		}
+		l.repo.ClearFailures(limit.key)
+		log.Warnf("Locked out %s for %v after %d failed login attempts", limit.key, duration, count)
+	}
+}
+
+// Success clears the failed attempts of the user, failures of the ip address
+// are kept, so one valid account can't be used to reset them
+func (l *loginLimiter) Success(username string) {
+	if err := l.repo.Unlock(userKey(username)); err != nil {
+		log.Errorf("Couldn't clear the failed login attempts of %s: %v", username, err)
+	}
+}
+
+// UnlockUser removes the lockout of the user
+func (l *loginLimiter) UnlockUser(username string) error {
+	if err := l.repo.Unlock(userKey(username)); err != nil {
+		log.Errorf("Couldn't unlock the user %s: %v", username, err)
+		return fmt.Errorf("Couldn't unlock the user")
+	}
+	log.Infof("Successfully unlocked the user %s", username)
+	return nil
+}
+
+// UnlockIP removes the lockout of the ip address
+func (l *loginLimiter) UnlockIP(ip string) error {
+	if err := l.repo.Unlock(ipKey(ip)); err != nil {
+		log.Errorf("Couldn't unlock the ip address %s: %v", ip, err)
+		return fmt.Errorf("Couldn't unlock the ip address")
+	}
+	log.Infof("Successfully unlocked the ip address %s", ip)
+	return nil
+}
+
+// lockoutDuration doubles the lockout duration with each consecutive lockout
+func lockoutDuration(level int) time.Duration {
+	base := time.Duration(common.Config.LoginLockoutSeconds) * time.Second
+	if base <= 0 {
+		base = defaultLockout
+	}
+	max := time.Duration(common.Config.LoginMaxLockoutSeconds) * time.Second
+	if max <= 0 {
+		max = defaultMaxLockout
+	}
+
+	duration := base
+	for i := 1; i < level && duration < max; i++ {
+		duration *= 2
+	}
+	if duration > max {
+		duration = max
+	}
+	return duration
+}
+
+func userKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,97 @@
+package service_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+)
+
+// invalidCredentials mocks the user service response for the wrong password
+func invalidCredentials(req *http.Request) (*http.Response, error) {
+	jsonStr := `{"code": 500, "message": "Invalid user credentials"}`
+	return &http.Response{
+		StatusCode: 500,
+		Body:       ioutil.NopCloser(bytes.NewReader([]byte(jsonStr))),
+	}, nil
+}
+
+func (suite *AuthServiceTestSuite) TestLoginLockout() {
+	common.Config.LoginMaxAttempts = 3
+	common.Config.LoginMaxIPAttempts = 5
+	attemptsRepo := mocks.NewMockLoginAttemptRepository()
+	limiter := service.NewLoginLimiter(attemptsRepo)
+	authService := service.NewAuthService(service.NewUserServiceBackend(&mocks.MockClient{DoFunc: invalidCredentials}), suite.authRepo, suite.keys, limiter)
+	creds := &models.Credentials{Username: "JohnDoe", Password: "wrong"}
+
+	for i := 0; i < 3; i++ {
+		_, err := authService.Login(creds, testClient)
+		suite.NotNil(err)
+		var rateLimitErr *service.RateLimitError
+		suite.False(errors.As(err, &rateLimitErr))
+	}
+
+	// user is locked out, even with the correct password
+	_, err := authService.Login(creds, testClient)
+	var rateLimitErr *service.RateLimitError
+	suite.Require().True(errors.As(err, &rateLimitErr))
+	suite.Equal(60, rateLimitErr.RetryAfterSeconds())
+
+	// lockout is doubled with each consecutive lockout
+	suite.Nil(limiter.UnlockUser("JohnDoe"))
+	attemptsRepo.Levels["user:johndoe"] = 2
+	for i := 0; i < 3; i++ {
+		authService.Login(&models.Credentials{Username: "johndoe", Password: "wrong"}, &models.ClientInfo{IP: "10.0.0.2"})
+	}
+	_, err = authService.Login(creds, &models.ClientInfo{IP: "10.0.0.2"})
+	suite.Require().True(errors.As(err, &rateLimitErr))
+	suite.Equal(240, rateLimitErr.RetryAfterSeconds())
+
+	// ip address is locked out after failures for different usernames
+	suite.Nil(limiter.UnlockUser("JohnDoe"))
+	suite.Nil(limiter.Check("JaneDoe", testClient.IP))
+	for i := 0; i < 2; i++ {
+		authService.Login(&models.Credentials{Username: "user" + string(rune('a'+i)), Password: "wrong"}, testClient)
+	}
+	err = limiter.Check("JaneDoe", testClient.IP)
+	suite.True(errors.As(err, &rateLimitErr))
+	suite.Nil(limiter.Check("JaneDoe", "10.0.0.3"))
+
+	suite.Nil(limiter.UnlockIP(testClient.IP))
+	suite.Nil(limiter.Check("JaneDoe", testClient.IP))
+}
+
+func (suite *AuthServiceTestSuite) TestLoginLockoutReset() {
+	common.Config.LoginMaxAttempts = 3
+	common.Config.LoginMaxLockoutSeconds = 90
+	attemptsRepo := mocks.NewMockLoginAttemptRepository()
+	limiter := service.NewLoginLimiter(attemptsRepo)
+	failing := service.NewAuthService(service.NewUserServiceBackend(&mocks.MockClient{DoFunc: invalidCredentials}), suite.authRepo, suite.keys, limiter)
+	creds := &models.Credentials{Username: "JohnDoe", Password: "test1231"}
+
+	// successful login clears the failed attempts of the user
+	for i := 0; i < 2; i++ {
+		failing.Login(creds, testClient)
+	}
+	suite.limiter = limiter
+	suite.generateToken("JohnDoe", false)
+	for i := 0; i < 2; i++ {
+		failing.Login(creds, testClient)
+	}
+	suite.Nil(limiter.Check("JohnDoe", testClient.IP))
+
+	// lockout never exceeds the maximum
+	attemptsRepo.Levels["user:johndoe"] = 10
+	failing.Login(creds, testClient)
+	err := limiter.Check("JohnDoe", testClient.IP)
+	var rateLimitErr *service.RateLimitError
+	suite.Require().True(errors.As(err, &rateLimitErr))
+	suite.True(rateLimitErr.RetryAfter <= 90*time.Second)
+	suite.True(rateLimitErr.RetryAfter > 60*time.Second)
+}
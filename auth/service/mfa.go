@@ -0,0 +1,71 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/0x113/x-media/auth/httpclient"
+	"github.com/0x113/x-media/auth/models"
+)
+
+// ErrMFAConflict is returned when the two-factor authentication is already
+// enabled on enrollment or isn't enabled on confirmation or removal
+var ErrMFAConflict = errors.New("Two-factor authentication state doesn't allow this operation")
+
+// MFAService defines the enrollment of the authenticator app, the secrets
+// and recovery codes are stored by the user service
+type MFAService interface {
+	EnrollTOTP(username string) (*models.TOTPEnrollment, error)
+	ConfirmTOTP(username, code string) error
+	DisableTOTP(username, code string) error
+}
+
+type mfaService struct {
+	httpClient httpclient.HTTPClient
+}
+
+// NewMFAService creates new instance of the MFA service
+func NewMFAService(httpClient httpclient.HTTPClient) MFAService {
+	return &mfaService{httpClient}
+}
+
+// EnrollTOTP generates the new secret and recovery codes of the user, they
+// are required on the login only after the enrollment is confirmed
+func (s *mfaService) EnrollTOTP(username string) (*models.TOTPEnrollment, error) {
+	enrollment := new(models.TOTPEnrollment)
+	payload := map[string]string{"username": username}
+	if err := userServiceRequest(s.httpClient, http.MethodPost, "/totp/enroll", payload, enrollment); err != nil {
+		return nil, mfaError(err)
+	}
+	return enrollment, nil
+}
+
+// ConfirmTOTP enables the second factor with the first code from the authenticator app
+func (s *mfaService) ConfirmTOTP(username, code string) error {
+	payload := map[string]string{"username": username, "code": code}
+	return mfaError(userServiceRequest(s.httpClient, http.MethodPost, "/totp/confirm", payload, nil))
+}
+
+// DisableTOTP removes the second factor, the user must provide the current
+// code or one of the recovery codes
+func (s *mfaService) DisableTOTP(username, code string) error {
+	payload := map[string]string{"username": username, "code": code}
+	return mfaError(userServiceRequest(s.httpClient, http.MethodPost, "/totp/disable", payload, nil))
+}
+
+// mfaError maps the response of the user service to the errors of the MFA service
+func mfaError(err error) error {
+	var userErr *userServiceError
+	if !errors.As(err, &userErr) {
+		return err
+	}
+	switch userErr.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrInvalidMFACode
+	case http.StatusConflict:
+		return fmt.Errorf("%w: %s", ErrMFAConflict, userErr.Message)
+	default:
+		return rateLimitError(err)
+	}
+}
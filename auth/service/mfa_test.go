@@ -0,0 +1,167 @@
+package service_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+)
+
+// login starts the login of the user with the second factor and returns the MFA token
+func (suite *AuthServiceTestSuite) login(authService service.AuthService, username string) string {
+	token, err := authService.Login(&models.Credentials{Username: username, Password: "password"}, testClient)
+	suite.Require().Nil(token)
+	var mfaErr *service.MFARequiredError
+	suite.Require().True(errors.As(err, &mfaErr), "unexpected error: %v", err)
+	suite.Equal(service.MFAChallengeTTL, mfaErr.ExpiresIn)
+	return mfaErr.Token
+}
+
+func (suite *AuthServiceTestSuite) TestLoginWithSecondFactor() {
+	users := mocks.NewMockMFAUserService("123456")
+	users.Enabled["JohnDoe"] = true
+	authService := service.NewAuthService(service.NewUserServiceBackend(users.Client()), suite.authRepo, suite.keys, suite.limiter)
+
+	// the user without the second factor gets the tokens right away
+	token, err := authService.Login(&models.Credentials{Username: "alice", Password: "password"}, testClient)
+	suite.Require().Nil(err)
+	suite.NotEmpty(token.AccessToken)
+
+	// the correct password alone doesn't start the session
+	mfaToken := suite.login(authService, "JohnDoe")
+	suite.Len(suite.authRepo.MFAChallenges, 1)
+	suite.Len(suite.authRepo.Families, 1)
+
+	testCases := []struct {
+		name string
+		req  *models.MFALogin
+		err  error
+	}{
+		{
+			name: "Missing code",
+			req:  &models.MFALogin{MFAToken: mfaToken},
+		},
+		{
+			name: "Unknown MFA token",
+			req:  &models.MFALogin{MFAToken: "unknown", Code: "123456"},
+			err:  service.ErrMFAChallengeInvalid,
+		},
+		{
+			name: "Wrong code",
+			req:  &models.MFALogin{MFAToken: mfaToken, Code: "654321"},
+			err:  service.ErrInvalidMFACode,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			token, err := authService.VerifyMFA(tt.req, testClient)
+			suite.Nil(token)
+			suite.Require().NotNil(err)
+			if tt.err != nil {
+				suite.True(errors.Is(err, tt.err), "unexpected error: %v", err)
+			}
+		})
+	}
+
+	// the wrong code doesn't invalidate the MFA token
+	token, err = authService.VerifyMFA(&models.MFALogin{MFAToken: mfaToken, Code: "123456"}, testClient)
+	suite.Require().Nil(err)
+	details, err := authService.ValidateToken(token.AccessToken)
+	suite.Require().Nil(err)
+	suite.Equal("JohnDoe", details.Username)
+	suite.False(details.MFARequired)
+
+	// the MFA token can be used only once
+	_, err = authService.VerifyMFA(&models.MFALogin{MFAToken: mfaToken, Code: "123456"}, testClient)
+	suite.True(errors.Is(err, service.ErrMFAChallengeInvalid))
+	suite.Empty(suite.authRepo.MFAChallenges)
+}
+
+func (suite *AuthServiceTestSuite) TestSecondFactorAttempts() {
+	users := mocks.NewMockMFAUserService("123456")
+	users.Enabled["JohnDoe"] = true
+	authService := service.NewAuthService(service.NewUserServiceBackend(users.Client()), suite.authRepo, suite.keys, suite.limiter)
+
+	// the correct password doesn't reset the counter of the wrong codes
+	common.Config.LoginMaxAttempts = 10
+	for i := 0; i < 2; i++ {
+		mfaToken := suite.login(authService, "JohnDoe")
+		for j := 0; j < 5; j++ {
+			_, err := authService.VerifyMFA(&models.MFALogin{MFAToken: mfaToken, Code: "000000"}, testClient)
+			suite.Require().True(errors.Is(err, service.ErrInvalidMFACode), "unexpected error: %v", err)
+		}
+		// the MFA token is dropped after too many wrong codes
+		_, err := authService.VerifyMFA(&models.MFALogin{MFAToken: mfaToken, Code: "123456"}, testClient)
+		suite.True(errors.Is(err, service.ErrMFAChallengeInvalid), "unexpected error: %v", err)
+	}
+
+	// the user is locked out after the tenth wrong code
+	var rateLimitErr *service.RateLimitError
+	_, err := authService.Login(&models.Credentials{Username: "JohnDoe", Password: "password"}, testClient)
+	suite.True(errors.As(err, &rateLimitErr), "unexpected error: %v", err)
+}
+
+func (suite *AuthServiceTestSuite) TestLockedSecondFactor() {
+	users := mocks.NewMockMFAUserService("123456")
+	users.Enabled["JohnDoe"] = true
+	authService := service.NewAuthService(service.NewUserServiceBackend(users.Client()), suite.authRepo, suite.keys, suite.limiter)
+
+	// the user service locks out the second factor regardless of the MFA token
+	mfaToken := suite.login(authService, "JohnDoe")
+	users.Locked["JohnDoe"] = true
+	var rateLimitErr *service.RateLimitError
+	_, err := authService.VerifyMFA(&models.MFALogin{MFAToken: mfaToken, Code: "123456"}, testClient)
+	suite.Require().True(errors.As(err, &rateLimitErr), "unexpected error: %v", err)
+	suite.Equal(60, rateLimitErr.RetryAfterSeconds())
+	err = service.NewMFAService(users.Client()).DisableTOTP("JohnDoe", "123456")
+	suite.True(errors.As(err, &rateLimitErr), "unexpected error: %v", err)
+
+	// the MFA token can be used after the lockout
+	users.Locked["JohnDoe"] = false
+	token, err := authService.VerifyMFA(&models.MFALogin{MFAToken: mfaToken, Code: "123456"}, testClient)
+	suite.Nil(err)
+	suite.NotNil(token)
+}
+
+func (suite *AuthServiceTestSuite) TestExpiredSecondFactor() {
+	users := mocks.NewMockMFAUserService("123456")
+	users.Enabled["JohnDoe"] = true
+	authService := service.NewAuthService(service.NewUserServiceBackend(users.Client()), suite.authRepo, suite.keys, suite.limiter)
+
+	mfaToken := suite.login(authService, "JohnDoe")
+	for _, challenge := range suite.authRepo.MFAChallenges {
+		challenge.ExpiresAt = time.Now().Add(-time.Second)
+	}
+	_, err := authService.VerifyMFA(&models.MFALogin{MFAToken: mfaToken, Code: "123456"}, testClient)
+	suite.True(errors.Is(err, service.ErrMFAChallengeInvalid))
+}
+
+func (suite *AuthServiceTestSuite) TestTOTPEnrollment() {
+	users := mocks.NewMockMFAUserService("123456")
+	mfaService := service.NewMFAService(users.Client())
+
+	err := mfaService.ConfirmTOTP("JohnDoe", "123456")
+	suite.True(errors.Is(err, service.ErrMFAConflict))
+
+	enrollment, err := mfaService.EnrollTOTP("JohnDoe")
+	suite.Require().Nil(err)
+	suite.NotEmpty(enrollment.Secret)
+	suite.Len(enrollment.RecoveryCodes, 1)
+
+	err = mfaService.ConfirmTOTP("JohnDoe", "000000")
+	suite.True(errors.Is(err, service.ErrInvalidMFACode))
+	suite.Nil(mfaService.ConfirmTOTP("JohnDoe", "123456"))
+	suite.True(users.Enabled["JohnDoe"])
+
+	_, err = mfaService.EnrollTOTP("JohnDoe")
+	suite.True(errors.Is(err, service.ErrMFAConflict))
+
+	err = mfaService.DisableTOTP("JohnDoe", "000000")
+	suite.True(errors.Is(err, service.ErrInvalidMFACode))
+	suite.Nil(mfaService.DisableTOTP("JohnDoe", "123456"))
+	suite.False(users.Enabled["JohnDoe"])
+}
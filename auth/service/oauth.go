@@ -0,0 +1,511 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/data"
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-playground/validator"
+	log "github.com/sirupsen/logrus"
+	"github.com/twinj/uuid"
+)
+
+const (
+	// AuthorizationCodeTTL defines how long the authorization code can be exchanged for the tokens
+	AuthorizationCodeTTL = time.Minute
+
+	defaultIssuer = "http://localhost:8003"
+)
+
+var (
+	// oidcScopes defines the OpenID Connect scopes, they don't grant access to any of the services
+	oidcScopes = []string{"openid", "profile"}
+	// supportedScopes defines the scopes which can be requested by the OAuth clients
+	supportedScopes = append(append([]string{}, oidcScopes...), apiScopes...)
+)
+
+var (
+	// ErrClientNotFound is returned when there is no OAuth client with provided id
+	ErrClientNotFound = errors.New("Client not found")
+	// ErrInvalidRegistration is returned when the client registration is incomplete
+	ErrInvalidRegistration = errors.New("Invalid client registration")
+)
+
+// OAuthService defines the OAuth 2.0 authorization server
+type OAuthService interface {
+	RegisterClient(reg *models.ClientRegistration) (*models.RegisteredClient, error)
+	GetClients() ([]*models.OAuthClient, error)
+	DeleteClient(id string) error
+	CheckAuthorization(req *models.AuthorizeRequest, user *models.UuidAccessDetails) (*models.AuthorizationInfo, error)
+	Authorize(req *models.ConsentRequest, user *models.UuidAccessDetails) (*models.AuthorizationResult, error)
+	Token(req *models.TokenRequest, client *models.ClientInfo) (*models.OAuthTokenResponse, error)
+	Introspect(req *models.IntrospectionRequest) (*models.Introspection, error)
+	DeviceAuthorization(req *models.DeviceCodeRequest) (*models.DeviceCodeResponse, error)
+	CheckDeviceCode(userCode string, user *models.UuidAccessDetails) (*models.AuthorizationInfo, error)
+	ApproveDevice(req *models.DeviceApproval, user *models.UuidAccessDetails) error
+	UserInfo(user *models.UuidAccessDetails) *models.UserInfo
+	Discovery() *models.OpenIDConfiguration
+}
+
+type oauthService struct {
+	repo        data.OAuthRepository
+	authService AuthService
+	keys        KeyManager
+}
+
+// NewOAuthService creates new instance of the OAuth service
+func NewOAuthService(repo data.OAuthRepository, authService AuthService, keys KeyManager) OAuthService {
+	return &oauthService{repo, authService, keys}
+}
+
+// RegisterClient registers new OAuth client, confidential clients get the
+// secret which is stored only as a hash
+func (s *oauthService) RegisterClient(reg *models.ClientRegistration) (*models.RegisteredClient, error) {
+	validate := validator.New()
+	if err := validate.Struct(reg); err != nil {
+		log.Errorf("Couldn't validate the client registration: %v", err)
+		return nil, fmt.Errorf("%w: name and at least one redirect uri are required", ErrInvalidRegistration)
+	}
+	for _, redirectURI := range reg.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			return nil, fmt.Errorf("%w: redirect uri %q must use https, http on the loopback interface or "+
+				"a private-use scheme like com.example.app and can't contain a fragment", ErrInvalidRegistration, redirectURI)
+		}
+	}
+
+	client := &models.OAuthClient{
+		ClientID:     uuid.NewV4().String(),
+		Name:         reg.Name,
+		RedirectURIs: reg.RedirectURIs,
+		Confidential: reg.Confidential,
+		CreatedAt:    time.Now(),
+	}
+	registered := &models.RegisteredClient{OAuthClient: client}
+	if reg.Confidential {
+		secret, err := randomToken()
+		if err != nil {
+			log.Errorf("Couldn't generate the client secret: %v", err)
+			return nil, fmt.Errorf("Couldn't generate the client secret")
+		}
+		client.SecretHash = hashToken(secret)
+		registered.ClientSecret = secret
+	}
+
+	if err := s.repo.SaveClient(client); err != nil {
+		log.Errorf("Couldn't save the client %s: %v", client.Name, err)
+		return nil, fmt.Errorf("Couldn't save the client")
+	}
+	client.SecretHash = ""
+
+	log.Infof("Successfully registered the OAuth client %s [id=%s]", client.Name, client.ClientID)
+	return registered, nil
+}
+
+// GetClients returns all of the registered clients without their secrets
+func (s *oauthService) GetClients() ([]*models.OAuthClient, error) {
+	clients, err := s.repo.GetClients()
+	if err != nil {
+		log.Errorf("Couldn't get the clients: %v", err)
+		return nil, fmt.Errorf("Couldn't get the clients")
+	}
+	for _, client := range clients {
+		client.SecretHash = ""
+	}
+	return clients, nil
+}
+
+// DeleteClient removes the client, its refresh tokens can no longer be used
+func (s *oauthService) DeleteClient(id string) error {
+	if err := s.repo.DeleteClient(id); err != nil {
+		log.Errorf("Couldn't delete the client [id=%s]: %v", id, err)
+		return ErrClientNotFound
+	}
+
+	log.Infof("Successfully deleted the OAuth client [id=%s]", id)
+	return nil
+}
+
+// CheckAuthorization validates the authorization request and returns what
+// should be presented to the user on the consent screen
+func (s *oauthService) CheckAuthorization(req *models.AuthorizeRequest, user *models.UuidAccessDetails) (*models.AuthorizationInfo, error) {
+	client, scopes, err := s.validateAuthorizeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	scopes = grantedScopes(scopes, user.AccessDetails)
+
+	consented := false
+	if granted, err := s.repo.GetConsent(user.Username, client.ClientID); err == nil {
+		consented = containsAll(strings.Fields(granted), scopes)
+	}
+
+	return &models.AuthorizationInfo{
+		ClientID:   client.ClientID,
+		ClientName: client.Name,
+		Scopes:     scopes,
+		Consented:  consented,
+	}, nil
+}
+
+// Authorize issues the authorization code if the user approved the request
+// and returns the redirect uri of the client with the code or the error
+func (s *oauthService) Authorize(req *models.ConsentRequest, user *models.UuidAccessDetails) (*models.AuthorizationResult, error) {
+	client, scopes, err := s.validateAuthorizeRequest(&req.AuthorizeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	if !req.Approved {
+		log.Infof("%s denied access to the client %s", user.Username, client.ClientID)
+		params.Set("error", "access_denied")
+		return &models.AuthorizationResult{RedirectTo: redirectURL(req.RedirectURI, params)}, nil
+	}
+	scopes = grantedScopes(scopes, user.AccessDetails)
+	if len(scopes) == 0 {
+		log.Infof("%s hasn't been granted any of the scopes requested by the client %s", user.Username, client.ClientID)
+		params.Set("error", "invalid_scope")
+		return &models.AuthorizationResult{RedirectTo: redirectURL(req.RedirectURI, params)}, nil
+	}
+
+	if err := s.repo.SaveConsent(user.Username, client.ClientID, strings.Join(scopes, " ")); err != nil {
+		log.Errorf("Couldn't save the consent of %s to the client %s: %v", user.Username, client.ClientID, err)
+		return nil, fmt.Errorf("Couldn't save the consent")
+	}
+
+	codeStr, err := randomToken()
+	if err != nil {
+		log.Errorf("Couldn't generate the authorization code: %v", err)
+		return nil, fmt.Errorf("Couldn't generate the authorization code")
+	}
+	code := &models.AuthorizationCode{
+		ClientID:      client.ClientID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		Username:      user.Username,
+		IsAdmin:       user.IsAdmin != nil && *user.IsAdmin && containsAny(scopes, adminScopes),
+		UserScopes:    scopes,
+	}
+	if err := s.repo.SaveCode(hashToken(codeStr), code, AuthorizationCodeTTL); err != nil {
+		log.Errorf("Couldn't save the authorization code: %v", err)
+		return nil, fmt.Errorf("Couldn't save the authorization code")
+	}
+
+	log.Infof("Successfully issued the authorization code for %s to the client %s", user.Username, client.ClientID)
+	params.Set("code", codeStr)
+	return &models.AuthorizationResult{RedirectTo: redirectURL(req.RedirectURI, params)}, nil
+}
+
+// Token exchanges the authorization code or the refresh token for new tokens
+func (s *oauthService) Token(req *models.TokenRequest, client *models.ClientInfo) (*models.OAuthTokenResponse, error) {
+	oauthClient, err := s.authenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	clientInfo := &models.ClientInfo{
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		ClientID:  oauthClient.ClientID,
+	}
+
+	switch req.GrantType {
+	case "authorization_code":
+		return s.exchangeCode(req, clientInfo)
+	case "refresh_token":
+		token, err := s.authService.Refresh(req.RefreshToken, clientInfo)
+		if err != nil {
+			return nil, &models.OAuthError{Code: "invalid_grant", Description: err.Error()}
+		}
+		return tokenResponse(token, ""), nil
+	case DeviceCodeGrantType:
+		return s.exchangeDeviceCode(req, clientInfo)
+	default:
+		return nil, &models.OAuthError{Code: "unsupported_grant_type", Description: "Supported grant types are authorization_code, refresh_token and " + DeviceCodeGrantType}
+	}
+}
+
+// exchangeCode verifies the authorization code and the PKCE code verifier and issues new tokens
+func (s *oauthService) exchangeCode(req *models.TokenRequest, client *models.ClientInfo) (*models.OAuthTokenResponse, error) {
+	invalidGrant := &models.OAuthError{Code: "invalid_grant", Description: "Authorization code is invalid or expired"}
+
+	code, err := s.repo.TakeCode(hashToken(req.Code))
+	if err != nil {
+		log.Errorf("Couldn't get the authorization code for the client %s: %v", client.ClientID, err)
+		return nil, invalidGrant
+	}
+	if code.ClientID != client.ClientID || code.RedirectURI != req.RedirectURI {
+		log.Errorf("Authorization code has been issued to the client %s, used by %s", code.ClientID, client.ClientID)
+		return nil, invalidGrant
+	}
+	if !verifyCodeChallenge(req.CodeVerifier, code.CodeChallenge) {
+		log.Errorf("Invalid PKCE code verifier sent by the client %s", client.ClientID)
+		return nil, &models.OAuthError{Code: "invalid_grant", Description: "Invalid code verifier"}
+	}
+
+	token, err := s.authService.IssueTokens(&models.AccessDetails{
+		Username: code.Username,
+		IsAdmin:  &code.IsAdmin,
+		Scopes:   code.UserScopes,
+	}, client)
+	if err != nil {
+		return nil, err // no need to log, IssueTokens does it
+	}
+	response := tokenResponse(token, code.Scope)
+
+	if containsAll(strings.Fields(code.Scope), []string{"openid"}) {
+		response.IDToken, err = s.idToken(code.Username, code.Scope, code.Nonce, client.ClientID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// idToken generates the OpenID Connect ID token for the user authorized with provided scope
+func (s *oauthService) idToken(username, scope, nonce, clientID string) (string, error) {
+	now := time.Now()
+	claims := &models.IDTokenClaims{
+		Nonce: nonce,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    issuer(),
+			Subject:   username,
+			Audience:  clientID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(AccessTokenTTL).Unix(),
+		},
+	}
+	if containsAll(strings.Fields(scope), []string{"profile"}) {
+		claims.PreferredUsername = username
+	}
+
+	kid, signingKey, err := s.keys.SigningKey()
+	if err != nil {
+		log.Errorf("Couldn't get the signing key: %v", err)
+		return "", fmt.Errorf("Couldn't generate the ID token")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	idToken, err := token.SignedString(signingKey)
+	if err != nil {
+		log.Errorf("Couldn't sign the ID token: %v", err)
+		return "", fmt.Errorf("Couldn't generate the ID token")
+	}
+	return idToken, nil
+}
+
+// UserInfo returns the claims about the authenticated user
+func (s *oauthService) UserInfo(user *models.UuidAccessDetails) *models.UserInfo {
+	return &models.UserInfo{
+		Sub:               user.Username,
+		PreferredUsername: user.Username,
+		IsAdmin:           user.IsAdmin != nil && *user.IsAdmin,
+	}
+}
+
+// Discovery returns the OpenID Connect discovery document
+func (s *oauthService) Discovery() *models.OpenIDConfiguration {
+	iss := issuer()
+	authorizeURL := common.Config.OAuthAuthorizeURL
+	if authorizeURL == "" {
+		authorizeURL = iss + "/oauth/authorize"
+	}
+	return &models.OpenIDConfiguration{
+		Issuer:                            iss,
+		AuthorizationEndpoint:             authorizeURL,
+		TokenEndpoint:                     iss + "/api/v1/auth/oauth/token",
+		UserinfoEndpoint:                  iss + "/api/v1/auth/oauth/userinfo",
+		IntrospectionEndpoint:             iss + "/api/v1/auth/oauth/introspect",
+		JWKSURI:                           iss + "/.well-known/jwks.json",
+		ScopesSupported:                   supportedScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", DeviceCodeGrantType},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{"RS256"},
+		TokenEndpointAuthMethodsSupported: []string{"none", "client_secret_basic", "client_secret_post"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		DeviceAuthorizationEndpoint:       iss + "/api/v1/auth/oauth/device/code",
+	}
+}
+
+// validateAuthorizeRequest checks the client, the redirect uri, the requested
+// scopes and the PKCE code challenge of the authorization request
+func (s *oauthService) validateAuthorizeRequest(req *models.AuthorizeRequest) (*models.OAuthClient, []string, error) {
+	client, err := s.repo.GetClient(req.ClientID)
+	if err != nil {
+		log.Errorf("Couldn't get the client [id=%s]: %v", req.ClientID, err)
+		return nil, nil, &models.OAuthError{Code: "invalid_client", Description: "Unknown client"}
+	}
+	if !containsAll(client.RedirectURIs, []string{req.RedirectURI}) {
+		return nil, nil, &models.OAuthError{Code: "invalid_request", Description: "Redirect uri isn't registered for the client"}
+	}
+	if req.ResponseType != "code" {
+		return nil, nil, &models.OAuthError{Code: "unsupported_response_type", Description: "Only the code response type is supported"}
+	}
+	if req.CodeChallenge == "" {
+		return nil, nil, &models.OAuthError{Code: "invalid_request", Description: "PKCE code challenge is required"}
+	}
+	if req.CodeChallengeMethod != "S256" {
+		return nil, nil, &models.OAuthError{Code: "invalid_request", Description: "Only the S256 code challenge method is supported"}
+	}
+	scopes, err := parseScopes(req.Scope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, scopes, nil
+}
+
+// parseScopes splits the scope requested by the client, at least one of the
+// supported scopes is required
+func parseScopes(scope string) ([]string, error) {
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 || !containsAll(supportedScopes, scopes) {
+		return nil, &models.OAuthError{Code: "invalid_scope", Description: "At least one scope is required, supported scopes are " + strings.Join(supportedScopes, ", ")}
+	}
+	return scopes, nil
+}
+
+// grantedScopes returns the requested scopes which the user can grant to the
+// client. The access token of the client gets only these scopes, never all of
+// the scopes of the user.
+func grantedScopes(requested []string, user *models.AccessDetails) []string {
+	allowed := allowedScopes(user)
+	granted := []string{}
+	for _, scope := range requested {
+		if containsAll(oidcScopes, []string{scope}) || containsAll(allowed, []string{scope}) {
+			granted = append(granted, scope)
+		}
+	}
+	return granted
+}
+
+// validRedirectURI checks if the browser can be safely redirected to the uri:
+// https, http only on the loopback interface used by the native apps, or the
+// private-use scheme of the native apps in the reverse domain notation (RFC 8252).
+// Schemes like javascript: or data: are rejected.
+func validRedirectURI(redirectURI string) bool {
+	u, err := url.Parse(redirectURI)
+	if err != nil || u.Scheme == "" || u.Fragment != "" {
+		return false
+	}
+	switch scheme := strings.ToLower(u.Scheme); scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		if u.Hostname() == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(u.Hostname())
+		return ip != nil && ip.IsLoopback()
+	default:
+		return strings.Contains(scheme, ".")
+	}
+}
+
+// authenticateClient checks the client id and, for confidential clients, the client secret
+func (s *oauthService) authenticateClient(clientID, secret string) (*models.OAuthClient, error) {
+	invalidClient := &models.OAuthError{Code: "invalid_client", Description: "Client authentication failed"}
+
+	client, err := s.repo.GetClient(clientID)
+	if err != nil {
+		log.Errorf("Couldn't get the client [id=%s]: %v", clientID, err)
+		return nil, invalidClient
+	}
+	if client.Confidential && subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(client.SecretHash)) != 1 {
+		log.Errorf("Invalid secret of the client [id=%s]", clientID)
+		return nil, invalidClient
+	}
+	return client, nil
+}
+
+// verifyCodeChallenge checks if the code verifier matches the S256 code challenge (RFC 7636)
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// tokenResponse converts the token details to the response of the token endpoint
+func tokenResponse(token *models.TokenDetails, scope string) *models.OAuthTokenResponse {
+	return &models.OAuthTokenResponse{
+		AccessToken:  token.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(AccessTokenTTL.Seconds()),
+		RefreshToken: token.RefreshToken,
+		Scope:        scope,
+	}
+}
+
+// redirectURL appends the params to the query of the redirect uri
+func redirectURL(redirectURI string, params url.Values) string {
+	u, _ := url.Parse(redirectURI) // already validated during the client registration
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// issuer returns the configured issuer without the trailing slash
+func issuer() string {
+	if common.Config.Issuer == "" {
+		return defaultIssuer
+	}
+	return strings.TrimSuffix(common.Config.Issuer, "/")
+}
+
+// randomToken generates random, URL safe string with 256 bits of entropy
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the SHA-256 hash of the high entropy token, so the tokens
+// aren't stored in plain text
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// containsAll checks if all of the values are in the set
+func containsAll(set, values []string) bool {
+	for _, value := range values {
+		found := false
+		for _, s := range set {
+			if s == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
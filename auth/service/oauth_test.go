@@ -0,0 +1,446 @@
+package service_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// testVerifier defines the PKCE code verifier used in the tests
+const testVerifier = "dBjftJeZ4CVP-mJ0kz4ugigDZa4ZvTQlWJ4Bfp8kz3ZV"
+
+// codeChallenge returns the S256 code challenge of the verifier
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// newOAuthService creates the OAuth service with the registered public client
+func (suite *AuthServiceTestSuite) newOAuthService() (service.OAuthService, *models.RegisteredClient) {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	oauthService := service.NewOAuthService(mocks.NewMockOAuthRepository(), suite.authService, suite.keys)
+	client, err := oauthService.RegisterClient(&models.ClientRegistration{
+		Name:         "Kodi add-on",
+		RedirectURIs: []string{"http://127.0.0.1:8123/callback"},
+	})
+	suite.Require().Nil(err)
+	return oauthService, client
+}
+
+// authorize approves the authorization request and returns the authorization code
+func (suite *AuthServiceTestSuite) authorize(oauthService service.OAuthService, clientID, scope string) string {
+	user := &models.UuidAccessDetails{AccessDetails: &models.AccessDetails{Username: "JohnDoe", IsAdmin: new(bool)}}
+	result, err := oauthService.Authorize(&models.ConsentRequest{
+		AuthorizeRequest: models.AuthorizeRequest{
+			ResponseType:        "code",
+			ClientID:            clientID,
+			RedirectURI:         "http://127.0.0.1:8123/callback",
+			Scope:               scope,
+			State:               "xyz",
+			Nonce:               "n-0S6",
+			CodeChallenge:       codeChallenge(testVerifier),
+			CodeChallengeMethod: "S256",
+		},
+		Approved: true,
+	}, user)
+	suite.Require().Nil(err)
+
+	u, err := url.Parse(result.RedirectTo)
+	suite.Require().Nil(err)
+	suite.Equal("xyz", u.Query().Get("state"))
+	return u.Query().Get("code")
+}
+
+func (suite *AuthServiceTestSuite) TestRegisterClient() {
+	oauthService, _ := suite.newOAuthService()
+
+	testCases := []struct {
+		name    string
+		reg     *models.ClientRegistration
+		wantErr bool
+	}{
+		{
+			name:    "Confidential client",
+			reg:     &models.ClientRegistration{Name: "Web", RedirectURIs: []string{"https://media.example.com/callback"}, Confidential: true},
+			wantErr: false,
+		},
+		{
+			name:    "Native app redirect uri",
+			reg:     &models.ClientRegistration{Name: "App", RedirectURIs: []string{"com.example.app:/callback"}},
+			wantErr: false,
+		},
+		{
+			name:    "Missing redirect uri",
+			reg:     &models.ClientRegistration{Name: "Web"},
+			wantErr: true,
+		},
+		{
+			name:    "Loopback redirect uri",
+			reg:     &models.ClientRegistration{Name: "CLI", RedirectURIs: []string{"http://localhost:8123/callback"}},
+			wantErr: false,
+		},
+		{
+			name:    "Relative redirect uri",
+			reg:     &models.ClientRegistration{Name: "Web", RedirectURIs: []string{"/callback"}},
+			wantErr: true,
+		},
+		{
+			name:    "JavaScript redirect uri",
+			reg:     &models.ClientRegistration{Name: "Evil", RedirectURIs: []string{"javascript:alert(document.cookie)"}},
+			wantErr: true,
+		},
+		{
+			name:    "Data redirect uri",
+			reg:     &models.ClientRegistration{Name: "Evil", RedirectURIs: []string{"data:text/html,<script>alert(1)</script>"}},
+			wantErr: true,
+		},
+		{
+			name:    "Plain http redirect uri",
+			reg:     &models.ClientRegistration{Name: "Web", RedirectURIs: []string{"http://media.example.com/callback"}},
+			wantErr: true,
+		},
+		{
+			name:    "Custom scheme without the domain",
+			reg:     &models.ClientRegistration{Name: "App", RedirectURIs: []string{"kodi:/callback"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			client, err := oauthService.RegisterClient(tt.reg)
+			if tt.wantErr {
+				suite.True(errors.Is(err, service.ErrInvalidRegistration))
+				suite.Nil(client)
+			} else {
+				suite.Nil(err)
+				suite.NotEmpty(client.ClientID)
+				suite.Empty(client.SecretHash)
+				suite.Equal(tt.reg.Confidential, client.ClientSecret != "")
+			}
+		})
+	}
+
+	// secrets are never returned after the registration
+	clients, err := oauthService.GetClients()
+	suite.Nil(err)
+	suite.Len(clients, 4)
+	for _, client := range clients {
+		suite.Empty(client.SecretHash)
+	}
+}
+
+func (suite *AuthServiceTestSuite) TestCheckAuthorization() {
+	oauthService, client := suite.newOAuthService()
+	user := &models.UuidAccessDetails{AccessDetails: &models.AccessDetails{Username: "JohnDoe", IsAdmin: new(bool)}}
+	valid := models.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            client.ClientID,
+		RedirectURI:         "http://127.0.0.1:8123/callback",
+		Scope:               "openid profile",
+		CodeChallenge:       codeChallenge(testVerifier),
+		CodeChallengeMethod: "S256",
+	}
+
+	testCases := []struct {
+		name    string
+		modify  func(req *models.AuthorizeRequest)
+		errCode string
+	}{
+		{
+			name:   "Valid request",
+			modify: func(req *models.AuthorizeRequest) {},
+		},
+		{
+			name:    "Unknown client",
+			modify:  func(req *models.AuthorizeRequest) { req.ClientID = "unknown" },
+			errCode: "invalid_client",
+		},
+		{
+			name:    "Unregistered redirect uri",
+			modify:  func(req *models.AuthorizeRequest) { req.RedirectURI = "http://evil.example.com/callback" },
+			errCode: "invalid_request",
+		},
+		{
+			name:    "Implicit flow",
+			modify:  func(req *models.AuthorizeRequest) { req.ResponseType = "token" },
+			errCode: "unsupported_response_type",
+		},
+		{
+			name:    "Missing code challenge",
+			modify:  func(req *models.AuthorizeRequest) { req.CodeChallenge = "" },
+			errCode: "invalid_request",
+		},
+		{
+			name:    "Plain code challenge",
+			modify:  func(req *models.AuthorizeRequest) { req.CodeChallengeMethod = "plain" },
+			errCode: "invalid_request",
+		},
+		{
+			name:    "Unknown scope",
+			modify:  func(req *models.AuthorizeRequest) { req.Scope = "openid admin" },
+			errCode: "invalid_scope",
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			req := valid
+			tt.modify(&req)
+			info, err := oauthService.CheckAuthorization(&req, user)
+			if tt.errCode != "" {
+				var oauthErr *models.OAuthError
+				suite.Require().True(errors.As(err, &oauthErr))
+				suite.Equal(tt.errCode, oauthErr.Code)
+				suite.Nil(info)
+			} else {
+				suite.Nil(err)
+				suite.Equal("Kodi add-on", info.ClientName)
+				suite.Equal([]string{"openid", "profile"}, info.Scopes)
+				suite.False(info.Consented)
+			}
+		})
+	}
+
+	// the consent is remembered
+	suite.authorize(oauthService, client.ClientID, "openid profile")
+	info, err := oauthService.CheckAuthorization(&valid, user)
+	suite.Nil(err)
+	suite.True(info.Consented)
+}
+
+func (suite *AuthServiceTestSuite) TestAuthorizeDenied() {
+	oauthService, client := suite.newOAuthService()
+	user := &models.UuidAccessDetails{AccessDetails: &models.AccessDetails{Username: "JohnDoe", IsAdmin: new(bool)}}
+
+	result, err := oauthService.Authorize(&models.ConsentRequest{
+		AuthorizeRequest: models.AuthorizeRequest{
+			ResponseType:        "code",
+			ClientID:            client.ClientID,
+			RedirectURI:         "http://127.0.0.1:8123/callback",
+			Scope:               "openid",
+			State:               "xyz",
+			CodeChallenge:       codeChallenge(testVerifier),
+			CodeChallengeMethod: "S256",
+		},
+		Approved: false,
+	}, user)
+	suite.Nil(err)
+	suite.Equal("http://127.0.0.1:8123/callback?error=access_denied&state=xyz", result.RedirectTo)
+}
+
+func (suite *AuthServiceTestSuite) TestAuthorizationCodeGrant() {
+	oauthService, client := suite.newOAuthService()
+	code := suite.authorize(oauthService, client.ClientID, "openid profile")
+
+	// wrong verifier burns the code
+	_, err := oauthService.Token(&models.TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         code,
+		RedirectURI:  "http://127.0.0.1:8123/callback",
+		CodeVerifier: "wrong-verifier-wrong-verifier-wrong-verifier",
+		ClientID:     client.ClientID,
+	}, testClient)
+	suite.NotNil(err)
+
+	code = suite.authorize(oauthService, client.ClientID, "openid profile")
+	req := &models.TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         code,
+		RedirectURI:  "http://127.0.0.1:8123/callback",
+		CodeVerifier: testVerifier,
+		ClientID:     client.ClientID,
+	}
+	token, err := oauthService.Token(req, testClient)
+	suite.Require().Nil(err)
+	suite.Equal("Bearer", token.TokenType)
+	suite.Equal("openid profile", token.Scope)
+	suite.EqualValues(service.AccessTokenTTL.Seconds(), token.ExpiresIn)
+
+	// the access token works like the one from the login
+	accessDetails, err := suite.authService.ValidateToken(token.AccessToken)
+	suite.Nil(err)
+	suite.Equal("JohnDoe", accessDetails.Username)
+	sessions, err := suite.authService.GetSessions("JohnDoe", "")
+	suite.Nil(err)
+	suite.Require().Len(sessions, 1)
+	suite.Equal(client.ClientID, sessions[0].ClientID)
+
+	// ID token is signed with the published keys
+	claims := new(models.IDTokenClaims)
+	_, err = jwt.ParseWithClaims(token.IDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		return suite.keys.PublicKey(t.Header["kid"].(string))
+	})
+	suite.Nil(err)
+	suite.Equal("JohnDoe", claims.Subject)
+	suite.Equal("JohnDoe", claims.PreferredUsername)
+	suite.Equal(client.ClientID, claims.Audience)
+	suite.Equal("http://localhost:8003", claims.Issuer)
+	suite.Equal("n-0S6", claims.Nonce)
+
+	// authorization code can be used only once
+	_, err = oauthService.Token(req, testClient)
+	var oauthErr *models.OAuthError
+	suite.Require().True(errors.As(err, &oauthErr))
+	suite.Equal("invalid_grant", oauthErr.Code)
+
+	// refresh token grant
+	refreshed, err := oauthService.Token(&models.TokenRequest{
+		GrantType:    "refresh_token",
+		RefreshToken: token.RefreshToken,
+		ClientID:     client.ClientID,
+	}, testClient)
+	suite.Require().Nil(err)
+	suite.NotEqual(token.AccessToken, refreshed.AccessToken)
+
+	// refresh token of the OAuth client can't be used by the first party refresh
+	_, err = suite.authService.Refresh(refreshed.RefreshToken, testClient)
+	suite.Equal(service.ErrClientMismatch, err)
+}
+
+func (suite *AuthServiceTestSuite) TestAuthorizationScopes() {
+	oauthService, client := suite.newOAuthService()
+	isAdmin := true
+	admin := &models.UuidAccessDetails{AccessDetails: &models.AccessDetails{Username: "admin", IsAdmin: &isAdmin}}
+	user := &models.UuidAccessDetails{AccessDetails: &models.AccessDetails{Username: "JohnDoe", IsAdmin: new(bool)}}
+
+	authorize := func(user *models.UuidAccessDetails, scope string) *url.URL {
+		result, err := oauthService.Authorize(&models.ConsentRequest{
+			AuthorizeRequest: models.AuthorizeRequest{
+				ResponseType:        "code",
+				ClientID:            client.ClientID,
+				RedirectURI:         "http://127.0.0.1:8123/callback",
+				Scope:               scope,
+				CodeChallenge:       codeChallenge(testVerifier),
+				CodeChallengeMethod: "S256",
+			},
+			Approved: true,
+		}, user)
+		suite.Require().Nil(err)
+		u, err := url.Parse(result.RedirectTo)
+		suite.Require().Nil(err)
+		return u
+	}
+	exchange := func(code string) *models.OAuthTokenResponse {
+		token, err := oauthService.Token(&models.TokenRequest{
+			GrantType:    "authorization_code",
+			Code:         code,
+			RedirectURI:  "http://127.0.0.1:8123/callback",
+			CodeVerifier: testVerifier,
+			ClientID:     client.ClientID,
+		}, testClient)
+		suite.Require().Nil(err)
+		return token
+	}
+
+	testCases := []struct {
+		name           string
+		user           *models.UuidAccessDetails
+		scope          string
+		expectedScopes []string
+	}{
+		{
+			name:           "Admin requesting the library scope",
+			user:           admin,
+			scope:          "openid library:read",
+			expectedScopes: []string{"openid", service.ScopeLibraryRead},
+		},
+		{
+			name:           "Admin requesting the admin scope",
+			user:           admin,
+			scope:          "users:manage",
+			expectedScopes: []string{service.ScopeUsersManage},
+		},
+		{
+			name:           "User requesting the admin scope",
+			user:           user,
+			scope:          "stream users:manage",
+			expectedScopes: []string{service.ScopeStream},
+		},
+		{
+			name:           "Only the OpenID Connect scopes",
+			user:           admin,
+			scope:          "openid profile",
+			expectedScopes: []string{"openid", "profile"},
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			token := exchange(authorize(tt.user, tt.scope).Query().Get("code"))
+			suite.Equal(strings.Join(tt.expectedScopes, " "), token.Scope)
+
+			accessDetails, err := suite.authService.ValidateToken(token.AccessToken)
+			suite.Require().Nil(err)
+			suite.Equal(tt.expectedScopes, accessDetails.Scopes)
+			suite.Equal(service.HasScope(&models.AccessDetails{Scopes: tt.expectedScopes}, service.ScopeUsersManage), *accessDetails.IsAdmin)
+			suite.False(service.HasScope(accessDetails.AccessDetails, service.ScopeLibraryScan))
+		})
+	}
+
+	// none of the requested scopes can be granted
+	u := authorize(user, "users:manage")
+	suite.Equal("invalid_scope", u.Query().Get("error"))
+	suite.Empty(u.Query().Get("code"))
+}
+
+func (suite *AuthServiceTestSuite) TestConfidentialClient() {
+	oauthService, _ := suite.newOAuthService()
+	client, err := oauthService.RegisterClient(&models.ClientRegistration{
+		Name:         "Web",
+		RedirectURIs: []string{"http://127.0.0.1:8123/callback"},
+		Confidential: true,
+	})
+	suite.Require().Nil(err)
+
+	testCases := []struct {
+		name    string
+		secret  string
+		errCode string
+	}{
+		{
+			name:    "Missing secret",
+			secret:  "",
+			errCode: "invalid_client",
+		},
+		{
+			name:    "Wrong secret",
+			secret:  "wrong",
+			errCode: "invalid_client",
+		},
+		{
+			name:   "Correct secret",
+			secret: client.ClientSecret,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			code := suite.authorize(oauthService, client.ClientID, "library:read")
+			token, err := oauthService.Token(&models.TokenRequest{
+				GrantType:    "authorization_code",
+				Code:         code,
+				RedirectURI:  "http://127.0.0.1:8123/callback",
+				CodeVerifier: testVerifier,
+				ClientID:     client.ClientID,
+				ClientSecret: tt.secret,
+			}, testClient)
+			if tt.errCode != "" {
+				var oauthErr *models.OAuthError
+				suite.Require().True(errors.As(err, &oauthErr))
+				suite.Equal(tt.errCode, oauthErr.Code)
+			} else {
+				suite.Nil(err)
+				suite.Empty(token.IDToken) // openid scope wasn't requested
+			}
+		})
+	}
+}
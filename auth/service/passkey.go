@@ -0,0 +1,338 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/data"
+	"github.com/0x113/x-media/auth/httpclient"
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/fxamacker/cbor/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// PasskeyTimeout defines how long the user has to answer the WebAuthn challenge
+const PasskeyTimeout = 5 * time.Minute
+
+const (
+	ceremonyCreate = "webauthn.create"
+	ceremonyGet    = "webauthn.get"
+)
+
+var (
+	// ErrPasskeyInvalid is returned when the new credential can't be verified
+	ErrPasskeyInvalid = errors.New("Passkey registration is invalid or expired")
+	// ErrPasskeyRejected is returned when the passkey login can't be verified
+	ErrPasskeyRejected = errors.New("Couldn't sign in with the passkey")
+	// ErrPasskeyNotFound is returned when the user doesn't have the passkey with provided id
+	ErrPasskeyNotFound = errors.New("Passkey not found")
+	// ErrLastCredential is returned when the user would be left without any way to sign in
+	ErrLastCredential = errors.New("Account must keep either the password or a passkey")
+)
+
+// PasskeyService defines the registration of the WebAuthn credentials and
+// the passwordless login with them, the credentials are stored by the user service
+type PasskeyService interface {
+	BeginRegistration(user *models.UuidAccessDetails) (*models.PublicKeyCredentialCreationOptions, error)
+	FinishRegistration(user *models.UuidAccessDetails, registration *models.PasskeyRegistration) (*models.Passkey, error)
+	BeginLogin(req *models.PasskeyLoginRequest) (*models.PublicKeyCredentialRequestOptions, error)
+	FinishLogin(assertion *models.PasskeyAssertion, client *models.ClientInfo) (*models.TokenDetails, error)
+	GetPasskeys(username string) ([]*models.Passkey, error)
+	DeletePasskey(username, id string) error
+	RemovePassword(username string) error
+}
+
+type passkeyService struct {
+	httpClient  httpclient.HTTPClient
+	repo        data.WebAuthnRepository
+	authService AuthService
+}
+
+// NewPasskeyService creates new instance of the passkey service
+func NewPasskeyService(httpClient httpclient.HTTPClient, repo data.WebAuthnRepository, authService AuthService) PasskeyService {
+	return &passkeyService{
+		httpClient:  httpClient,
+		repo:        repo,
+		authService: authService,
+	}
+}
+
+// BeginRegistration returns the options of the new credential, the passkeys
+// the user already has are excluded
+func (s *passkeyService) BeginRegistration(user *models.UuidAccessDetails) (*models.PublicKeyCredentialCreationOptions, error) {
+	passkeys, err := s.storedPasskeys(user.Username)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := s.newChallenge(ceremonyCreate, user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &models.PublicKeyCredentialCreationOptions{
+		RP: models.RelyingParty{ID: common.Config.WebAuthnRPID, Name: common.Config.WebAuthnRPName},
+		User: models.WebAuthnUser{
+			ID:          models.Base64URL(user.Username),
+			Name:        user.Username,
+			DisplayName: user.Username,
+		},
+		Challenge: challenge,
+		PubKeyCredParams: []models.CredentialParameter{
+			{Type: "public-key", Alg: coseAlgES256},
+			{Type: "public-key", Alg: coseAlgRS256},
+		},
+		Timeout:            PasskeyTimeout.Milliseconds(),
+		ExcludeCredentials: credentialDescriptors(passkeys),
+		AuthenticatorSelection: models.AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: "required",
+		},
+		Attestation: "none",
+	}
+	return options, nil
+}
+
+// FinishRegistration verifies the new credential and stores its public key
+func (s *passkeyService) FinishRegistration(user *models.UuidAccessDetails, registration *models.PasskeyRegistration) (*models.Passkey, error) {
+	if registration.Type != "public-key" || len(registration.RawID) == 0 || registration.ID != base64.RawURLEncoding.EncodeToString(registration.RawID) {
+		return nil, fmt.Errorf("%w: invalid credential id", ErrPasskeyInvalid)
+	}
+	if len(registration.Name) > 64 {
+		return nil, fmt.Errorf("%w: name is too long", ErrPasskeyInvalid)
+	}
+	challenge, err := parseClientData(registration.Response.ClientDataJSON, ceremonyCreate)
+	if err != nil {
+		log.Errorf("Invalid passkey registration of %s: %v", user.Username, err)
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyInvalid, err)
+	}
+	pending, err := s.repo.TakeChallenge(hashToken(challenge))
+	if err != nil || pending.Type != ceremonyCreate || pending.Username != user.Username {
+		log.Errorf("Unknown passkey registration challenge of %s: %v", user.Username, err)
+		return nil, fmt.Errorf("%w: unknown challenge", ErrPasskeyInvalid)
+	}
+
+	att := new(attestationObject)
+	if err := cbor.Unmarshal(registration.Response.AttestationObject, att); err != nil {
+		return nil, fmt.Errorf("%w: invalid attestation object", ErrPasskeyInvalid)
+	}
+	authData, err := parseAuthenticatorData(att.AuthData)
+	if err != nil || authData.PublicKey == nil {
+		log.Errorf("Invalid authenticator data in the passkey registration of %s: %v", user.Username, err)
+		return nil, fmt.Errorf("%w: invalid authenticator data", ErrPasskeyInvalid)
+	}
+	if !bytes.Equal(authData.CredentialID, registration.RawID) {
+		return nil, fmt.Errorf("%w: credential id doesn't match", ErrPasskeyInvalid)
+	}
+	key, alg, err := parseCOSEKey(authData.PublicKey)
+	if err != nil {
+		log.Errorf("Unsupported passkey public key of %s: %v", user.Username, err)
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyInvalid, err)
+	}
+	clientDataHash := sha256.Sum256(registration.Response.ClientDataJSON)
+	if err := verifyAttestation(att, clientDataHash[:], key, alg); err != nil {
+		log.Errorf("Couldn't verify the passkey attestation of %s: %v", user.Username, err)
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyInvalid, err)
+	}
+
+	name := registration.Name
+	if name == "" {
+		name = "Passkey"
+	}
+	stored := &models.StoredPasskey{
+		ID:        registration.ID,
+		Username:  user.Username,
+		Name:      name,
+		PublicKey: authData.PublicKey,
+		SignCount: authData.SignCount,
+	}
+	if err := userServiceRequest(s.httpClient, http.MethodPost, "/passkeys", stored, nil); err != nil {
+		return nil, err
+	}
+
+	return &models.Passkey{ID: stored.ID, Name: stored.Name, CreatedAt: time.Now()}, nil
+}
+
+// BeginLogin returns the challenge for the passkey login, without the
+// username any discoverable credential of the relying party can be used
+func (s *passkeyService) BeginLogin(req *models.PasskeyLoginRequest) (*models.PublicKeyCredentialRequestOptions, error) {
+	var allowed []*models.CredentialDescriptor
+	if req.Username != "" {
+		// unknown users get the challenge as well, so the response doesn't reveal them
+		passkeys, err := s.storedPasskeys(req.Username)
+		if err != nil {
+			log.Errorf("Couldn't get the passkeys of %s: %v", req.Username, err)
+		}
+		allowed = credentialDescriptors(passkeys)
+	}
+	challenge, err := s.newChallenge(ceremonyGet, req.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PublicKeyCredentialRequestOptions{
+		Challenge:        challenge,
+		Timeout:          PasskeyTimeout.Milliseconds(),
+		RPID:             common.Config.WebAuthnRPID,
+		AllowCredentials: allowed,
+		UserVerification: "required",
+	}, nil
+}
+
+// FinishLogin verifies the signed challenge with the stored public key and
+// issues the tokens for the owner of the passkey
+func (s *passkeyService) FinishLogin(assertion *models.PasskeyAssertion, client *models.ClientInfo) (*models.TokenDetails, error) {
+	if assertion.Type != "public-key" || len(assertion.RawID) == 0 {
+		return nil, ErrPasskeyRejected
+	}
+	challenge, err := parseClientData(assertion.Response.ClientDataJSON, ceremonyGet)
+	if err != nil {
+		log.Errorf("Invalid passkey login: %v", err)
+		return nil, ErrPasskeyRejected
+	}
+	pending, err := s.repo.TakeChallenge(hashToken(challenge))
+	if err != nil || pending.Type != ceremonyGet {
+		log.Errorf("Unknown passkey login challenge: %v", err)
+		return nil, ErrPasskeyRejected
+	}
+
+	id := base64.RawURLEncoding.EncodeToString(assertion.RawID)
+	stored := new(models.StoredPasskey)
+	if err := userServiceRequest(s.httpClient, http.MethodGet, "/passkeys/"+id, nil, stored); err != nil {
+		if errors.Is(err, errUserNotFound) {
+			return nil, ErrPasskeyRejected
+		}
+		return nil, err
+	}
+	if pending.Username != "" && pending.Username != stored.Username {
+		log.Errorf("Passkey %s doesn't belong to %s", id, pending.Username)
+		return nil, ErrPasskeyRejected
+	}
+	if len(assertion.Response.UserHandle) > 0 && string(assertion.Response.UserHandle) != stored.Username {
+		log.Errorf("User handle of the passkey %s doesn't match", id)
+		return nil, ErrPasskeyRejected
+	}
+
+	authData, err := parseAuthenticatorData(assertion.Response.AuthenticatorData)
+	if err != nil {
+		log.Errorf("Invalid authenticator data of the passkey %s: %v", id, err)
+		return nil, ErrPasskeyRejected
+	}
+	key, alg, err := parseCOSEKey(stored.PublicKey)
+	if err != nil {
+		log.Errorf("Invalid stored public key of the passkey %s: %v", id, err)
+		return nil, ErrPasskeyRejected
+	}
+	clientDataHash := sha256.Sum256(assertion.Response.ClientDataJSON)
+	signed := append(append([]byte{}, assertion.Response.AuthenticatorData...), clientDataHash[:]...)
+	if err := verifySignature(key, alg, signed, assertion.Response.Signature); err != nil {
+		log.Errorf("Couldn't verify the signature of the passkey %s: %v", id, err)
+		return nil, ErrPasskeyRejected
+	}
+
+	// the user service checks the signature counter, the lower value means the passkey was cloned
+	accessDetails := new(models.AccessDetails)
+	login := map[string]uint32{"sign_count": authData.SignCount}
+	if err := userServiceRequest(s.httpClient, http.MethodPost, "/passkeys/"+id+"/login", login, accessDetails); err != nil {
+		var userErr *userServiceError
+		if errors.As(err, &userErr) && userErr.StatusCode < http.StatusInternalServerError {
+			return nil, ErrPasskeyRejected
+		}
+		return nil, err
+	}
+
+	return s.authService.IssueTokens(accessDetails, client)
+}
+
+// GetPasskeys returns the passkeys of the user without their public keys
+func (s *passkeyService) GetPasskeys(username string) ([]*models.Passkey, error) {
+	stored, err := s.storedPasskeys(username)
+	if err != nil {
+		return nil, err
+	}
+	passkeys := []*models.Passkey{}
+	for _, p := range stored {
+		passkeys = append(passkeys, &models.Passkey{
+			ID:         p.ID,
+			Name:       p.Name,
+			CreatedAt:  p.CreatedAt,
+			LastUsedAt: p.LastUsedAt,
+		})
+	}
+	return passkeys, nil
+}
+
+// DeletePasskey removes the passkey of the user
+func (s *passkeyService) DeletePasskey(username, id string) error {
+	endpoint := "/passkeys/" + url.PathEscape(id) + "?username=" + url.QueryEscape(username)
+	return credentialError(userServiceRequest(s.httpClient, http.MethodDelete, endpoint, nil, nil))
+}
+
+// RemovePassword makes the account passwordless, the user must have a passkey
+func (s *passkeyService) RemovePassword(username string) error {
+	endpoint := "/password?username=" + url.QueryEscape(username)
+	return credentialError(userServiceRequest(s.httpClient, http.MethodDelete, endpoint, nil, nil))
+}
+
+// storedPasskeys returns the passkeys of the user from the user service
+func (s *passkeyService) storedPasskeys(username string) ([]*models.StoredPasskey, error) {
+	passkeys := []*models.StoredPasskey{}
+	endpoint := "/passkeys?username=" + url.QueryEscape(username)
+	if err := userServiceRequest(s.httpClient, http.MethodGet, endpoint, nil, &passkeys); err != nil {
+		return nil, err
+	}
+	return passkeys, nil
+}
+
+// newChallenge generates the random challenge and stores it until it is signed
+func (s *passkeyService) newChallenge(ceremony, username string) (models.Base64URL, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		log.Errorf("Couldn't generate the WebAuthn challenge: %v", err)
+		return nil, fmt.Errorf("Couldn't generate the challenge")
+	}
+	pending := &models.WebAuthnChallenge{Type: ceremony, Username: username}
+	hash := hashToken(base64.RawURLEncoding.EncodeToString(challenge))
+	if err := s.repo.SaveChallenge(hash, pending, PasskeyTimeout); err != nil {
+		log.Errorf("Couldn't save the WebAuthn challenge: %v", err)
+		return nil, fmt.Errorf("Couldn't save the challenge")
+	}
+	return challenge, nil
+}
+
+// credentialDescriptors lists the ids of the passkeys
+func credentialDescriptors(passkeys []*models.StoredPasskey) []*models.CredentialDescriptor {
+	descriptors := []*models.CredentialDescriptor{}
+	for _, p := range passkeys {
+		id, err := base64.RawURLEncoding.DecodeString(p.ID)
+		if err != nil {
+			continue
+		}
+		descriptors = append(descriptors, &models.CredentialDescriptor{Type: "public-key", ID: id})
+	}
+	return descriptors
+}
+
+// credentialError maps the responses of the user service to the passkey errors
+func credentialError(err error) error {
+	var userErr *userServiceError
+	if !errors.As(err, &userErr) {
+		return err
+	}
+	switch userErr.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrPasskeyNotFound, userErr.Message)
+	case http.StatusConflict:
+		return fmt.Errorf("%w: %s", ErrLastCredential, userErr.Message)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,305 @@
+package service_test
+
+import (
+	"errors"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+)
+
+const passkeyOrigin = "http://localhost:3000"
+
+// newPasskeyService configures the relying party and returns the passkey
+// service backed by the mocked user service
+func (suite *AuthServiceTestSuite) newPasskeyService(users *mocks.MockPasskeyUserService) (service.PasskeyService, *mocks.MockWebAuthnRepository) {
+	common.Config.WebAuthnRPID = "localhost"
+	common.Config.WebAuthnRPName = "x-media"
+	common.Config.WebAuthnOrigins = []string{passkeyOrigin}
+	repo := mocks.NewMockWebAuthnRepository()
+	authService := service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	return service.NewPasskeyService(users.Client(), repo, authService), repo
+}
+
+// registerPasskey registers the credential of the authenticator for the user
+func (suite *AuthServiceTestSuite) registerPasskey(passkeyService service.PasskeyService, user *models.UuidAccessDetails, authenticator *mocks.MockAuthenticator) {
+	options, err := passkeyService.BeginRegistration(user)
+	suite.Require().Nil(err)
+	registration, err := authenticator.Register(options, "")
+	suite.Require().Nil(err)
+	_, err = passkeyService.FinishRegistration(user, registration)
+	suite.Require().Nil(err)
+}
+
+func passkeyUser(username string) *models.UuidAccessDetails {
+	return &models.UuidAccessDetails{AccessDetails: &models.AccessDetails{Username: username}}
+}
+
+func (suite *AuthServiceTestSuite) TestPasskeyRegistration() {
+	testCases := []struct {
+		name    string
+		forge   func(a *mocks.MockAuthenticator, r *models.PasskeyRegistration)
+		wantErr bool
+	}{
+		{
+			name:  "None attestation",
+			forge: func(a *mocks.MockAuthenticator, r *models.PasskeyRegistration) {},
+		},
+		{
+			name:  "Packed self attestation",
+			forge: func(a *mocks.MockAuthenticator, r *models.PasskeyRegistration) { a.Attestation = "packed" },
+		},
+		{
+			name:    "Unsupported attestation format",
+			forge:   func(a *mocks.MockAuthenticator, r *models.PasskeyRegistration) { a.Attestation = "tpm" },
+			wantErr: true,
+		},
+		{
+			name:    "Foreign origin",
+			forge:   func(a *mocks.MockAuthenticator, r *models.PasskeyRegistration) { a.Origin = "https://evil.example.org" },
+			wantErr: true,
+		},
+		{
+			name:    "Another relying party",
+			forge:   func(a *mocks.MockAuthenticator, r *models.PasskeyRegistration) { a.RPID = "example.org" },
+			wantErr: true,
+		},
+		{
+			name:    "User not verified",
+			forge:   func(a *mocks.MockAuthenticator, r *models.PasskeyRegistration) { a.Unverified = true },
+			wantErr: true,
+		},
+		{
+			name: "Mismatched credential id",
+			forge: func(a *mocks.MockAuthenticator, r *models.PasskeyRegistration) {
+				r.RawID = append(r.RawID, 0)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Wrong ceremony",
+			forge: func(a *mocks.MockAuthenticator, r *models.PasskeyRegistration) {
+				r.Response.ClientDataJSON = []byte(`{"type":"webauthn.get","challenge":"AAAA","origin":"http://localhost:3000"}`)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			users := mocks.NewMockPasskeyUserService("JohnDoe")
+			passkeyService, repo := suite.newPasskeyService(users)
+			authenticator, err := mocks.NewMockAuthenticator("localhost", passkeyOrigin)
+			suite.Require().Nil(err)
+			user := passkeyUser("JohnDoe")
+
+			options, err := passkeyService.BeginRegistration(user)
+			suite.Require().Nil(err)
+			suite.Equal("localhost", options.RP.ID)
+			suite.Equal("JohnDoe", string(options.User.ID))
+			suite.Len(options.Challenge, 32)
+			suite.Len(repo.Challenges, 1)
+
+			// the authenticator is misconfigured before it answers the challenge
+			tt.forge(authenticator, &models.PasskeyRegistration{})
+			registration, err := authenticator.Register(options, "YubiKey")
+			suite.Require().Nil(err)
+			tt.forge(authenticator, registration)
+
+			passkey, err := passkeyService.FinishRegistration(user, registration)
+			if tt.wantErr {
+				suite.True(errors.Is(err, service.ErrPasskeyInvalid), "unexpected error: %v", err)
+				suite.Empty(users.Passkeys)
+				return
+			}
+			suite.Require().Nil(err)
+			suite.Empty(repo.Challenges)
+			suite.Equal(authenticator.ID(), passkey.ID)
+			suite.Equal("YubiKey", passkey.Name)
+			suite.Equal(authenticator.PublicKey(), users.Passkeys[passkey.ID].PublicKey)
+			suite.Equal("JohnDoe", users.Passkeys[passkey.ID].Username)
+
+			// the challenge can be used only once
+			_, err = passkeyService.FinishRegistration(user, registration)
+			suite.True(errors.Is(err, service.ErrPasskeyInvalid))
+
+			// the registered passkey is excluded from the next registration
+			options, err = passkeyService.BeginRegistration(user)
+			suite.Require().Nil(err)
+			suite.Require().Len(options.ExcludeCredentials, 1)
+			suite.Equal(authenticator.CredentialID, []byte(options.ExcludeCredentials[0].ID))
+		})
+	}
+}
+
+func (suite *AuthServiceTestSuite) TestPasskeyRegistrationOfAnotherUser() {
+	users := mocks.NewMockPasskeyUserService("JohnDoe", "alice")
+	passkeyService, _ := suite.newPasskeyService(users)
+	authenticator, err := mocks.NewMockAuthenticator("localhost", passkeyOrigin)
+	suite.Require().Nil(err)
+
+	// the challenge issued to alice can't be used to add the passkey to another account
+	options, err := passkeyService.BeginRegistration(passkeyUser("alice"))
+	suite.Require().Nil(err)
+	registration, err := authenticator.Register(options, "")
+	suite.Require().Nil(err)
+	_, err = passkeyService.FinishRegistration(passkeyUser("JohnDoe"), registration)
+	suite.True(errors.Is(err, service.ErrPasskeyInvalid))
+	suite.Empty(users.Passkeys)
+}
+
+func (suite *AuthServiceTestSuite) TestPasskeyLogin() {
+	users := mocks.NewMockPasskeyUserService("JohnDoe", "alice")
+	users.Admins["JohnDoe"] = true
+	passkeyService, repo := suite.newPasskeyService(users)
+	authService := service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	authenticator, err := mocks.NewMockAuthenticator("localhost", passkeyOrigin)
+	suite.Require().Nil(err)
+	suite.registerPasskey(passkeyService, passkeyUser("JohnDoe"), authenticator)
+
+	testCases := []struct {
+		name     string
+		username string
+		forge    func(a *models.PasskeyAssertion)
+		wantErr  bool
+	}{
+		{
+			name:  "Discoverable credential",
+			forge: func(a *models.PasskeyAssertion) {},
+		},
+		{
+			name:     "Credential of the user",
+			username: "JohnDoe",
+			forge:    func(a *models.PasskeyAssertion) {},
+		},
+		{
+			name:     "Credential of another user",
+			username: "alice",
+			forge:    func(a *models.PasskeyAssertion) {},
+			wantErr:  true,
+		},
+		{
+			name:    "Wrong user handle",
+			forge:   func(a *models.PasskeyAssertion) { a.Response.UserHandle = []byte("alice") },
+			wantErr: true,
+		},
+		{
+			name:    "Forged signature",
+			forge:   func(a *models.PasskeyAssertion) { a.Response.Signature[len(a.Response.Signature)-1]++ },
+			wantErr: true,
+		},
+		{
+			name:    "Unknown credential",
+			forge:   func(a *models.PasskeyAssertion) { a.RawID = []byte("unknown") },
+			wantErr: true,
+		},
+		{
+			name: "User not present",
+			forge: func(a *models.PasskeyAssertion) {
+				a.Response.AuthenticatorData[32] = 0
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			options, err := passkeyService.BeginLogin(&models.PasskeyLoginRequest{Username: tt.username})
+			suite.Require().Nil(err)
+			suite.Equal("localhost", options.RPID)
+			if tt.username == "JohnDoe" {
+				suite.Require().Len(options.AllowCredentials, 1)
+				suite.Equal(authenticator.CredentialID, []byte(options.AllowCredentials[0].ID))
+			} else {
+				suite.Empty(options.AllowCredentials)
+			}
+
+			assertion, err := authenticator.Login(options, "JohnDoe")
+			suite.Require().Nil(err)
+			tt.forge(assertion)
+			token, err := passkeyService.FinishLogin(assertion, testClient)
+			if tt.wantErr {
+				suite.True(errors.Is(err, service.ErrPasskeyRejected), "unexpected error: %v", err)
+				suite.Nil(token)
+				return
+			}
+			suite.Require().Nil(err)
+			suite.Empty(repo.Challenges)
+
+			// the result is the token pair of the authentication service
+			details, err := authService.ValidateToken(token.AccessToken)
+			suite.Require().Nil(err)
+			suite.Equal("JohnDoe", details.Username)
+			suite.True(*details.IsAdmin)
+			suite.Equal(authenticator.SignCount, users.Passkeys[authenticator.ID()].SignCount)
+		})
+	}
+
+	// the replayed assertion is rejected, the challenge was already used
+	options, err := passkeyService.BeginLogin(&models.PasskeyLoginRequest{})
+	suite.Require().Nil(err)
+	assertion, err := authenticator.Login(options, "")
+	suite.Require().Nil(err)
+	_, err = passkeyService.FinishLogin(assertion, testClient)
+	suite.Require().Nil(err)
+	_, err = passkeyService.FinishLogin(assertion, testClient)
+	suite.True(errors.Is(err, service.ErrPasskeyRejected))
+
+	// the cloned authenticator doesn't increase the signature counter
+	authenticator.SignCount--
+	options, err = passkeyService.BeginLogin(&models.PasskeyLoginRequest{})
+	suite.Require().Nil(err)
+	assertion, err = authenticator.Login(options, "")
+	suite.Require().Nil(err)
+	_, err = passkeyService.FinishLogin(assertion, testClient)
+	suite.True(errors.Is(err, service.ErrPasskeyRejected))
+
+	// the user is present, but the authenticator didn't verify them
+	authenticator.Unverified = true
+	options, err = passkeyService.BeginLogin(&models.PasskeyLoginRequest{})
+	suite.Require().Nil(err)
+	suite.Equal("required", options.UserVerification)
+	assertion, err = authenticator.Login(options, "")
+	suite.Require().Nil(err)
+	suite.Equal(byte(0x01), assertion.Response.AuthenticatorData[32])
+	_, err = passkeyService.FinishLogin(assertion, testClient)
+	suite.True(errors.Is(err, service.ErrPasskeyRejected))
+}
+
+func (suite *AuthServiceTestSuite) TestPasswordlessAccount() {
+	users := mocks.NewMockPasskeyUserService("JohnDoe")
+	passkeyService, _ := suite.newPasskeyService(users)
+	first, err := mocks.NewMockAuthenticator("localhost", passkeyOrigin)
+	suite.Require().Nil(err)
+	second, err := mocks.NewMockAuthenticator("localhost", passkeyOrigin)
+	suite.Require().Nil(err)
+
+	// the password can't be removed without a passkey
+	err = passkeyService.RemovePassword("JohnDoe")
+	suite.True(errors.Is(err, service.ErrLastCredential))
+
+	suite.registerPasskey(passkeyService, passkeyUser("JohnDoe"), first)
+	suite.registerPasskey(passkeyService, passkeyUser("JohnDoe"), second)
+	suite.Nil(passkeyService.RemovePassword("JohnDoe"))
+	suite.False(users.Passwords["JohnDoe"])
+
+	passkeys, err := passkeyService.GetPasskeys("JohnDoe")
+	suite.Require().Nil(err)
+	suite.Len(passkeys, 2)
+
+	// the passkey of another user isn't found
+	err = passkeyService.DeletePasskey("alice", first.ID())
+	suite.True(errors.Is(err, service.ErrPasskeyNotFound))
+
+	// the last passkey of the passwordless account is kept
+	suite.Nil(passkeyService.DeletePasskey("JohnDoe", first.ID()))
+	err = passkeyService.DeletePasskey("JohnDoe", second.ID())
+	suite.True(errors.Is(err, service.ErrLastCredential))
+
+	passkeys, err = passkeyService.GetPasskeys("JohnDoe")
+	suite.Require().Nil(err)
+	suite.Require().Len(passkeys, 1)
+	suite.Equal(second.ID(), passkeys[0].ID)
+	suite.Equal("Passkey", passkeys[0].Name)
+}
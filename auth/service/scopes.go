@@ -0,0 +1,52 @@
+package service
+
+import "github.com/0x113/x-media/auth/models"
+
+const (
+	// ScopeLibraryRead allows browsing the movies and the tv shows
+	ScopeLibraryRead = "library:read"
+	// ScopeLibraryScan allows updating the library from the disk and the metadata providers
+	ScopeLibraryScan = "library:scan"
+	// ScopeLibraryEdit allows fixing the metadata of the library
+	ScopeLibraryEdit = "library:edit"
+	// ScopeUsersManage allows managing the users
+	ScopeUsersManage = "users:manage"
+	// ScopeStream allows streaming the videos
+	ScopeStream = "stream"
+)
+
+var (
+	// userScopes are granted to the regular users
+	userScopes = []string{ScopeLibraryRead, ScopeStream}
+	// adminScopes are granted only to the admins
+	adminScopes = []string{ScopeLibraryScan, ScopeLibraryEdit, ScopeUsersManage}
+	// apiScopes are all of the scopes checked by the services
+	apiScopes = append(append([]string{}, userScopes...), adminScopes...)
+)
+
+// allowedScopes returns the scopes of the user, the users whose scopes
+// weren't assigned by the user service get the scopes of their admin flag
+func allowedScopes(accessDetails *models.AccessDetails) []string {
+	if len(accessDetails.Scopes) > 0 {
+		return accessDetails.Scopes
+	}
+	if accessDetails.IsAdmin != nil && *accessDetails.IsAdmin {
+		return append(append([]string{}, userScopes...), adminScopes...)
+	}
+	return userScopes
+}
+
+// HasScope checks if the user has been granted the scope
+func HasScope(accessDetails *models.AccessDetails, scope string) bool {
+	return accessDetails != nil && containsAll(allowedScopes(accessDetails), []string{scope})
+}
+
+// containsAny checks if any of the values is in the set
+func containsAny(set, values []string) bool {
+	for _, value := range values {
+		if containsAll(set, []string{value}) {
+			return true
+		}
+	}
+	return false
+}
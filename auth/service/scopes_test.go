@@ -0,0 +1,90 @@
+package service_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+)
+
+func (suite *AuthServiceTestSuite) TestTokenScopes() {
+	testCases := []struct {
+		name           string
+		response       string
+		expectedScopes []string
+	}{
+		{
+			name:           "Scopes of the role",
+			response:       `{"username": "scanner", "is_admin": false, "role": "scanner", "scopes": ["library:scan"]}`,
+			expectedScopes: []string{service.ScopeLibraryScan},
+		},
+		{
+			name:           "User without scopes",
+			response:       `{"username": "JohnDoe", "is_admin": false}`,
+			expectedScopes: []string{service.ScopeLibraryRead, service.ScopeStream},
+		},
+		{
+			name:     "Admin without scopes",
+			response: `{"username": "admin", "is_admin": true}`,
+			expectedScopes: []string{
+				service.ScopeLibraryRead, service.ScopeStream,
+				service.ScopeLibraryScan, service.ScopeLibraryEdit, service.ScopeUsersManage,
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			httpClient := &mocks.MockClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: 200,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte(tt.response))),
+					}, nil
+				},
+			}
+			authService := service.NewAuthService(service.NewUserServiceBackend(httpClient), suite.authRepo, suite.keys, suite.limiter)
+			token, err := authService.Login(&models.Credentials{Username: "JohnDoe", Password: "test1231"}, testClient)
+			suite.Require().Nil(err)
+
+			// the scopes are embedded in the access token and kept after the refresh
+			details, err := authService.ValidateToken(token.AccessToken)
+			suite.Require().Nil(err)
+			suite.Equal(tt.expectedScopes, details.Scopes)
+
+			refreshed, err := authService.Refresh(token.RefreshToken, testClient)
+			suite.Require().Nil(err)
+			details, err = authService.ValidateToken(refreshed.AccessToken)
+			suite.Require().Nil(err)
+			suite.Equal(tt.expectedScopes, details.Scopes)
+		})
+	}
+}
+
+func (suite *AuthServiceTestSuite) TestGenerateJWTKeepsDetails() {
+	isAdmin := true
+	details := &models.AccessDetails{Username: "admin", IsAdmin: &isAdmin}
+	token, err := suite.authService.GenerateJWT(details)
+	suite.Require().Nil(err)
+
+	// the scopes are embedded only in the token
+	suite.Nil(details.Scopes)
+	validated, err := suite.authService.ExtractAccessTokenMetadata(token.AccessToken)
+	suite.Require().Nil(err)
+	suite.Contains(validated.Scopes, service.ScopeUsersManage)
+}
+
+func (suite *AuthServiceTestSuite) TestHasScope() {
+	isAdmin := true
+	suite.True(service.HasScope(&models.AccessDetails{Username: "admin", IsAdmin: &isAdmin}, service.ScopeUsersManage))
+	suite.False(service.HasScope(&models.AccessDetails{Username: "JohnDoe", IsAdmin: new(bool)}, service.ScopeLibraryScan))
+	suite.False(service.HasScope(&models.AccessDetails{
+		Username: "kid",
+		IsAdmin:  new(bool),
+		Scopes:   []string{service.ScopeLibraryRead, service.ScopeStream},
+	}, service.ScopeLibraryScan))
+	suite.False(service.HasScope(nil, service.ScopeLibraryRead))
+}
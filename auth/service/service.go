@@ -1,15 +1,13 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"strings"
 	"time"
 
 	"github.com/0x113/x-media/auth/common"
 	"github.com/0x113/x-media/auth/data"
-	"github.com/0x113/x-media/auth/httpclient"
 	"github.com/0x113/x-media/auth/models"
 
 	"github.com/dgrijalva/jwt-go"
@@ -18,65 +16,195 @@ import (
 	"github.com/twinj/uuid"
 )
 
+const (
+	// AccessTokenTTL defines how long the access token is valid
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL defines how long the refresh token is valid
+	RefreshTokenTTL = 7 * 24 * time.Hour
+	// MFAChallengeTTL defines how long the user has to provide the second factor after the password
+	MFAChallengeTTL = 5 * time.Minute
+
+	// maxMFAAttempts defines how many wrong codes are accepted with the same MFA token
+	maxMFAAttempts = 5
+)
+
+// defaultAudience defines the services accepting the access tokens if it isn't configured
+var defaultAudience = []string{"movie-svc", "tvshow-svc", "user-svc"}
+
+var (
+	// ErrTokenRevoked is returned when the token is correctly signed, but its uuid
+	// is no longer stored in the database e.g. after logout
+	ErrTokenRevoked = errors.New("Token has been revoked")
+	// ErrTokenReused is returned when already rotated refresh token is used again,
+	// all of the tokens from its family are revoked
+	ErrTokenReused = errors.New("Refresh token has been already used")
+	// ErrClientMismatch is returned when the refresh token issued to one client
+	// is used by another one
+	ErrClientMismatch = errors.New("Refresh token has been issued to another client")
+	// ErrMFAChallengeInvalid is returned when the MFA token is unknown, expired
+	// or was used too many times with the wrong code
+	ErrMFAChallengeInvalid = errors.New("Two-factor authentication request is invalid or expired")
+)
+
+// MFARequiredError is returned by the login with the correct password when
+// the user has to confirm it with the second factor
+type MFARequiredError struct {
+	Token     string
+	ExpiresIn time.Duration
+}
+
+func (e *MFARequiredError) Error() string {
+	return "Two-factor authentication code is required"
+}
+
 // AuthService defines authentication service
 type AuthService interface {
-	Login(creds *models.Credentials) (*models.TokenDetails, error)
-	Refresh(tokenStr string) (*models.TokenDetails, error)
+	Login(creds *models.Credentials, client *models.ClientInfo) (*models.TokenDetails, error)
+	VerifyMFA(req *models.MFALogin, client *models.ClientInfo) (*models.TokenDetails, error)
+	Logout(tokenStr string) error
+	Refresh(tokenStr string, client *models.ClientInfo) (*models.TokenDetails, error)
+	IssueTokens(accessDetails *models.AccessDetails, client *models.ClientInfo) (*models.TokenDetails, error)
+	LoginVerified(accessDetails *models.AccessDetails, client *models.ClientInfo) (*models.TokenDetails, error)
+	ValidateToken(tokenStr string) (*models.UuidAccessDetails, error)
+	Introspect(tokenStr, tokenTypeHint string) *models.Introspection
 	GenerateJWT(accessDetails *models.AccessDetails) (*models.TokenDetails, error)
 	ExtractTokenMetadata(tokenString, secret string) (*models.UuidAccessDetails, error)
+	ExtractAccessTokenMetadata(tokenString string) (*models.UuidAccessDetails, error)
+	JWKS() (*models.JWKS, error)
+	GetSessions(username, currentID string) ([]*models.Session, error)
+	RevokeSession(username, id string) error
+	RevokeSessions(username, exceptID string) error
+	UnlockUser(username string) error
+	UnlockIP(ip string) error
+	CreateAPIKey(owner *models.AccessDetails, req *models.APIKeyRequest) (*models.CreatedAPIKey, error)
+	GetAPIKeys(username string) ([]*models.APIKey, error)
+	RevokeAPIKey(username, id string) error
+	RevokeAPIKeys(username string) error
 }
 
 type authService struct {
-	httpClient httpclient.HTTPClient
-	repo       data.AuthRepository
+	credentials CredentialBackend
+	repo        data.AuthRepository
+	keys        KeyManager
+	limiter     LoginLimiter
 }
 
 // NewAuthService creates new instance of authentication service
-func NewAuthService(httpClient httpclient.HTTPClient, repo data.AuthRepository) AuthService {
-	return &authService{httpClient, repo}
+func NewAuthService(credentials CredentialBackend, repo data.AuthRepository, keys KeyManager, limiter LoginLimiter) AuthService {
+	return &authService{credentials, repo, keys, limiter}
 }
 
-// Login calls the user service to check if provided credentials are correct
-// and generates authentication token
-func (s *authService) Login(creds *models.Credentials) (*models.TokenDetails, error) {
-	// convert credentials to json
-	jsonCreds, err := json.Marshal(creds)
+// Login calls the credential backend to check if provided credentials are
+// correct and generates authentication token
+func (s *authService) Login(creds *models.Credentials, client *models.ClientInfo) (*models.TokenDetails, error) {
+	// check if the user or the client isn't locked out after too many failed attempts
+	if err := s.limiter.Check(creds.Username, client.IP); err != nil {
+		return nil, err
+	}
+
+	accessDetails, err := s.credentials.Authenticate(creds)
 	if err != nil {
-		log.Errorf("Couldn't convert credentials to json: %v", err)
-		return nil, fmt.Errorf("Couldn't convert credentials to the json")
+		if errors.Is(err, ErrInvalidCredentials) {
+			s.limiter.Failure(creds.Username, client.IP)
+		}
+		return nil, err
+	}
 
+	// the attempts aren't reset until the second factor is provided, otherwise
+	// the correct password would reset the counter of the wrong codes
+	if accessDetails.MFARequired {
+		return nil, s.startMFAChallenge(accessDetails.Username, client)
 	}
-	// call the user service to check is provided data is correct
-	req, err := http.NewRequest(http.MethodPost, "http://xmedia-user-svc:8002/api/v1/user/validate", bytes.NewBuffer(jsonCreds))
+
+	s.limiter.Success(creds.Username)
+
+	return s.IssueTokens(accessDetails, client)
+}
+
+// LoginVerified finishes the login of the user whose identity has been
+// verified without the password, e.g. by the identity provider. The second
+// factor enrolled in the local account is still required.
+func (s *authService) LoginVerified(accessDetails *models.AccessDetails, client *models.ClientInfo) (*models.TokenDetails, error) {
+	if accessDetails.MFARequired {
+		return nil, s.startMFAChallenge(accessDetails.Username, client)
+	}
+	return s.IssueTokens(accessDetails, client)
+}
+
+// startMFAChallenge saves the login waiting for the second factor and returns
+// MFARequiredError with the token identifying it
+func (s *authService) startMFAChallenge(username string, client *models.ClientInfo) error {
+	token, err := randomToken()
 	if err != nil {
-		log.Errorf("Couldn't prepare request: %v", err)
-		return nil, fmt.Errorf("Couldn't prepare the request")
+		log.Errorf("Couldn't generate the MFA token: %v", err)
+		return fmt.Errorf("Couldn't generate the two-factor authentication token")
+	}
+	challenge := &models.MFAChallenge{
+		Username:  username,
+		IP:        client.IP,
+		ExpiresAt: time.Now().Add(MFAChallengeTTL),
+	}
+	if err := s.repo.SaveMFAChallenge(hashToken(token), challenge); err != nil {
+		log.Errorf("Couldn't save the MFA challenge [username=%s]: %v", username, err)
+		return fmt.Errorf("Couldn't save the two-factor authentication request")
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	res, err := s.httpClient.Do(req)
+	log.Infof("Password accepted, waiting for the second factor [username=%s]", username)
+	return &MFARequiredError{Token: token, ExpiresIn: MFAChallengeTTL}
+}
+
+// VerifyMFA finishes the login started with the correct password, the code
+// is checked by the credential backend and the MFA token can be used only
+// until the first correct code
+func (s *authService) VerifyMFA(req *models.MFALogin, client *models.ClientInfo) (*models.TokenDetails, error) {
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		log.Errorf("Couldn't validate the MFA login: %v", err)
+		return nil, fmt.Errorf("Couldn't validate provided request. MFA token and code are required.")
+	}
+
+	hash := hashToken(req.MFAToken)
+	challenge, err := s.repo.TakeMFAChallenge(hash)
 	if err != nil {
-		log.Errorf("Couldn't to execute request: %v", err)
-		return nil, fmt.Errorf("Couldn't connect to the user service")
+		log.Errorf("Couldn't get the MFA challenge: %v", err)
+		return nil, ErrMFAChallengeInvalid
+	}
+	if err := s.limiter.Check(challenge.Username, client.IP); err != nil {
+		s.retryMFAChallenge(hash, challenge)
+		return nil, err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		log.Errorf("Expected status code: %d, got: %d", http.StatusOK, res.StatusCode)
-		errMsg := new(models.Error)
-		if err := json.NewDecoder(res.Body).Decode(errMsg); err != nil {
-			return nil, fmt.Errorf("Couldn't decode the response from the user service")
+	accessDetails, err := s.credentials.VerifySecondFactor(challenge.Username, req.Code)
+	if err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			s.limiter.Failure(challenge.Username, client.IP)
+			challenge.Attempts++
 		}
-		return nil, fmt.Errorf(errMsg.Message) // return the error message from the user service
+		s.retryMFAChallenge(hash, challenge)
+		return nil, err
 	}
-	defer res.Body.Close()
 
-	// decode the response
-	accessDetails := new(models.AccessDetails)
-	if err := json.NewDecoder(res.Body).Decode(accessDetails); err != nil {
-		log.Errorf("Couldn't decode the response: %v", err)
-		return nil, fmt.Errorf("Couldn't decode the response from the user service")
+	s.limiter.Success(challenge.Username)
+	accessDetails.MFARequired = false
+
+	return s.IssueTokens(accessDetails, client)
+}
+
+// retryMFAChallenge saves the challenge again after the failed attempt, so
+// the user can correct the code without entering the password again
+func (s *authService) retryMFAChallenge(hash string, challenge *models.MFAChallenge) {
+	if challenge.Attempts >= maxMFAAttempts {
+		log.Infof("Too many wrong codes, the password is required again [username=%s]", challenge.Username)
+		return
 	}
+	if err := s.repo.SaveMFAChallenge(hash, challenge); err != nil {
+		log.Errorf("Couldn't save the MFA challenge [username=%s]: %v", challenge.Username, err)
+	}
+}
 
+// IssueTokens generates the access and refresh token for already authenticated
+// user and starts new family of the refresh tokens
+func (s *authService) IssueTokens(accessDetails *models.AccessDetails, client *models.ClientInfo) (*models.TokenDetails, error) {
 	// generate the access and refresh token
 	token, err := s.GenerateJWT(accessDetails)
 	if err != nil {
@@ -88,13 +216,35 @@ func (s *authService) Login(creds *models.Credentials) (*models.TokenDetails, er
 		log.Errorf("Unable to save the access and refresh token to the database: %v", err)
 		return nil, fmt.Errorf("Unable to save the access and refresh token to the database")
 	}
+	now := time.Now()
+	family := &models.TokenFamily{
+		ID:          token.FamilyID,
+		Username:    accessDetails.Username,
+		AccessUuid:  token.AccessUuid,
+		RefreshUuid: token.RefreshUuid,
+		IP:          client.IP,
+		UserAgent:   client.UserAgent,
+		ClientID:    client.ClientID,
+		CreatedAt:   now,
+		LastUsedAt:  now,
+	}
+	if err := s.repo.SaveFamily(family, time.Unix(token.RtExpires, 0)); err != nil {
+		log.Errorf("Unable to save the token family to the database: %v", err)
+		return nil, fmt.Errorf("Unable to save the access and refresh token to the database")
+	}
 
 	log.Infof("Successfully generated and saved the access and refresh token for: %s", accessDetails.Username)
 	return token, nil
 }
 
-// GenerateJWT generates new token from provided data
+// GenerateJWT generates new token from provided data, the access token is
+// signed with the current RSA key and the refresh token with the refresh secret
 func (s *authService) GenerateJWT(accessDetails *models.AccessDetails) (*models.TokenDetails, error) {
+	return s.generateJWT(accessDetails, uuid.NewV4().String())
+}
+
+// generateJWT generates new token which belongs to provided refresh token family
+func (s *authService) generateJWT(accessDetails *models.AccessDetails, familyID string) (*models.TokenDetails, error) {
 	// validation
 	validate := validator.New()
 	if err := validate.Struct(accessDetails); err != nil {
@@ -102,34 +252,45 @@ func (s *authService) GenerateJWT(accessDetails *models.AccessDetails) (*models.
 		return nil, fmt.Errorf("Provided credentials are invalid")
 	}
 
-	td := &models.TokenDetails{}
+	// embed the scopes, so the services don't have to derive them from the admin
+	// flag, the details of the caller are left untouched
+	details := *accessDetails
+	details.Scopes = allowedScopes(accessDetails)
+
+	td := &models.TokenDetails{FamilyID: familyID}
 	var err error
-	td.AtExpires = time.Now().Add(15 * time.Minute).Unix()
+	now := time.Now()
+	td.AtExpires = now.Add(AccessTokenTTL).Unix()
 	td.AccessUuid = uuid.NewV4().String()
 	// access token
 	atClaims := &models.TokenClaims{
-		accessDetails,
-		td.AccessUuid,
-		jwt.StandardClaims{
-			ExpiresAt: td.AtExpires,
-		},
+		Details:        &details,
+		Family:         familyID,
+		Audience:       tokenAudience(),
+		StandardClaims: registeredClaims(accessDetails.Username, td.AccessUuid, now, td.AtExpires),
 	}
-	at := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims)
-	td.AccessToken, err = at.SignedString([]byte(common.Config.AccessSecret))
+	kid, signingKey, err := s.keys.SigningKey()
+	if err != nil {
+		log.Errorf("Couldn't get the signing key: %v", err)
+		return nil, fmt.Errorf("Couldn't generate the authentication token")
+	}
+	at := jwt.NewWithClaims(jwt.SigningMethodRS256, atClaims)
+	at.Header["kid"] = kid
+	td.AccessToken, err = at.SignedString(signingKey)
 	if err != nil {
 		log.Errorf("Couldn't sign the authentication token: %v", err)
 		return nil, fmt.Errorf("Couldn't generate the authentication token")
 	}
 
 	// refresh token
-	td.RtExpires = time.Now().Add(7 * 24 * time.Hour).Unix()
+	td.RtExpires = now.Add(RefreshTokenTTL).Unix()
 	td.RefreshUuid = uuid.NewV4().String()
+	// only the authentication service accepts the refresh tokens
 	rtClaims := &models.TokenClaims{
-		accessDetails,
-		td.RefreshUuid,
-		jwt.StandardClaims{
-			ExpiresAt: td.RtExpires,
-		},
+		Details:        &details,
+		Family:         familyID,
+		Audience:       []string{issuer()},
+		StandardClaims: registeredClaims(accessDetails.Username, td.RefreshUuid, now, td.RtExpires),
 	}
 	rt := jwt.NewWithClaims(jwt.SigningMethodHS256, rtClaims)
 	td.RefreshToken, err = rt.SignedString([]byte(common.Config.RefreshSecret))
@@ -141,28 +302,71 @@ func (s *authService) GenerateJWT(accessDetails *models.AccessDetails) (*models.
 	return td, nil
 }
 
-// Refresh the access token
-func (s *authService) Refresh(tokenStr string) (*models.TokenDetails, error) {
+// registeredClaims returns the registered claims of the token with provided id
+func registeredClaims(subject, id string, issuedAt time.Time, expiresAt int64) jwt.StandardClaims {
+	return jwt.StandardClaims{
+		Id:        id,
+		Issuer:    issuer(),
+		Subject:   subject,
+		IssuedAt:  issuedAt.Unix(),
+		NotBefore: issuedAt.Unix(),
+		ExpiresAt: expiresAt,
+	}
+}
+
+// tokenAudience returns the services accepting the access tokens
+func tokenAudience() []string {
+	if len(common.Config.TokenAudience) == 0 {
+		return defaultAudience
+	}
+	return common.Config.TokenAudience
+}
+
+// Refresh rotates the refresh token and generates new access token. If the
+// refresh token has been already rotated, whole family of tokens is revoked.
+func (s *authService) Refresh(tokenStr string, client *models.ClientInfo) (*models.TokenDetails, error) {
 	claims, err := s.ExtractTokenMetadata(tokenStr, common.Config.RefreshSecret)
 	if err != nil {
 		return nil, err // no need to log, ExtractTokenMetadata does it
 	}
 
-	// remove previous token from the database
-	refreshUuid := claims.Uuid
-	if err := s.repo.Delete(refreshUuid); err != nil {
+	family, err := s.repo.GetFamily(claims.Family)
+	if err != nil {
+		log.Errorf("Couldn't refresh the token; family [id=%s] of %s doesn't exist: %v", claims.Family, claims.Username, err)
+		return nil, ErrTokenRevoked
+	}
+	if family.RefreshUuid != claims.Uuid {
+		log.Warnf("Refresh token [uuid=%s] of %s has been reused, revoking family [id=%s]", claims.Uuid, claims.Username, family.ID)
+		s.revokeFamily(family)
+		return nil, ErrTokenReused
+	}
+	if family.ClientID != client.ClientID {
+		log.Errorf("Refresh token [uuid=%s] of %s has been issued to another client", claims.Uuid, claims.Username)
+		return nil, ErrClientMismatch
+	}
+
+	// the user may have been disabled or got another role since the login
+	accessDetails, err := s.currentDetails(claims.AccessDetails)
+	if err != nil {
+		if errors.Is(err, errUserInactive) {
+			log.Warnf("Couldn't refresh the token of %s, revoking family [id=%s]: %v", claims.Username, family.ID, err)
+			s.revokeFamily(family)
+			return nil, ErrTokenRevoked
+		}
+		return nil, err
+	}
+
+	// remove previous tokens from the database, the access token may have already expired
+	if err := s.repo.Delete(claims.Uuid); err != nil {
 		log.Errorf("Couldn't refresh the token; unable to delete previous one: %v", err)
 		return nil, fmt.Errorf("Couldn't to refresh the token; unable to delete the previous one")
 	}
+	s.repo.Delete(family.AccessUuid)
 
-	accessDetails := &models.AccessDetails{
-		Username: claims.Username,
-		IsAdmin:  claims.IsAdmin,
-	}
 	// create new token
-	token, err := s.GenerateJWT(accessDetails)
+	token, err := s.generateJWT(accessDetails, family.ID)
 	if err != nil {
-		return nil, err // no need to log, GenerateJWT does it
+		return nil, err // no need to log, generateJWT does it
 	}
 
 	// save new token to the database
@@ -170,19 +374,95 @@ func (s *authService) Refresh(tokenStr string) (*models.TokenDetails, error) {
 		log.Errorf("Couldn't refresh the token; unable to save both tokens to the database: %v", err)
 		return nil, fmt.Errorf("Couldn't refresh the token; unable to save the access and refresh token to the database")
 	}
+	family.AccessUuid = token.AccessUuid
+	family.RefreshUuid = token.RefreshUuid
+	family.IP = client.IP
+	family.UserAgent = client.UserAgent
+	family.LastUsedAt = time.Now()
+	if err := s.repo.SaveFamily(family, time.Unix(token.RtExpires, 0)); err != nil {
+		log.Errorf("Couldn't refresh the token; unable to save the token family: %v", err)
+		return nil, fmt.Errorf("Couldn't refresh the token; unable to save the access and refresh token to the database")
+	}
 
 	log.Infof("Successfully refresh the token for %s", claims.Username)
 	return token, nil
 }
 
-// ExtractTokenMetadata extracts data from provided JSON Web Token
+// currentDetails limits the scopes and the admin privileges of the refreshed
+// token to the current ones of the user, they're never extended, so the token
+// issued to the OAuth client keeps only the granted scopes
+func (s *authService) currentDetails(claims *models.AccessDetails) (*models.AccessDetails, error) {
+	current, err := s.credentials.CurrentUser(claims.Username)
+	if err != nil {
+		log.Errorf("Couldn't get the current role of %s: %v", claims.Username, err)
+		return nil, err
+	}
+
+	scopes := grantedScopes(allowedScopes(claims), current)
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: none of the scopes of %s is allowed anymore", errUserInactive, claims.Username)
+	}
+	isAdmin := claims.IsAdmin != nil && *claims.IsAdmin &&
+		current.IsAdmin != nil && *current.IsAdmin && containsAny(scopes, adminScopes)
+	return &models.AccessDetails{
+		Username: claims.Username,
+		IsAdmin:  &isAdmin,
+		Scopes:   scopes,
+	}, nil
+}
+
+// ExtractTokenMetadata extracts data from provided JSON Web Token signed with the secret
 func (s *authService) ExtractTokenMetadata(tokenString, secret string) (*models.UuidAccessDetails, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &models.TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
+	claims, err := parseClaims(tokenString, secretKeyFunc(secret))
+	if err != nil {
+		return nil, err
+	}
+	return tokenMetadata(claims), nil
+}
+
+// ExtractAccessTokenMetadata extracts data from provided access token signed
+// with one of the RSA keys
+func (s *authService) ExtractAccessTokenMetadata(tokenString string) (*models.UuidAccessDetails, error) {
+	claims, err := parseClaims(tokenString, s.publicKeyFunc)
+	if err != nil {
+		return nil, err
+	}
+	return tokenMetadata(claims), nil
+}
+
+// publicKeyFunc returns the public key of the RSA key which signed the access token
+func (s *authService) publicKeyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+	kid, _ := token.Header["kid"].(string)
+	return s.keys.PublicKey(kid)
+}
+
+// secretKeyFunc returns the key func of the tokens signed with the secret
+func secretKeyFunc(secret string) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(secret), nil
-	})
+	}
+}
+
+// JWKS returns the public keys which can be used to verify the access tokens
+func (s *authService) JWKS() (*models.JWKS, error) {
+	jwks, err := s.keys.JWKS()
+	if err != nil {
+		log.Errorf("Couldn't get the public keys: %v", err)
+		return nil, fmt.Errorf("Couldn't get the public keys")
+	}
+	return jwks, nil
+}
+
+// parseClaims parses the token with provided key func and returns its claims,
+// the tokens issued by another issuer are rejected
+func parseClaims(tokenString string, keyFunc jwt.Keyfunc) (*models.TokenClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &models.TokenClaims{}, keyFunc)
 
 	// make sure that token is not nil
 	if token == nil {
@@ -191,13 +471,95 @@ func (s *authService) ExtractTokenMetadata(tokenString, secret string) (*models.
 
 	}
 	// return claims if token is valid and token claims are same as models.TokenClaims
-	if claims, ok := token.Claims.(*models.TokenClaims); ok && token.Valid {
-		return &models.UuidAccessDetails{
-			claims.Details,
-			claims.Uuid,
-		}, nil
+	if claims, ok := token.Claims.(*models.TokenClaims); ok && token.Valid && claims.Details != nil {
+		// the tokens issued before the iss claim are still accepted
+		if !claims.VerifyIssuer(issuer(), false) {
+			log.Errorf("Token [jti=%s] has been issued by %s", claims.TokenID(), claims.Issuer)
+			return nil, fmt.Errorf("Couldn't parse provided token")
+		}
+		return claims, nil
 	}
 
 	log.Errorf("Couldn't parse the token: %v", err)
 	return nil, fmt.Errorf("Couldn't parse provided token")
 }
+
+// tokenMetadata returns the access details stored in the token claims
+func tokenMetadata(claims *models.TokenClaims) *models.UuidAccessDetails {
+	return &models.UuidAccessDetails{
+		AccessDetails: claims.Details,
+		Uuid:          claims.TokenID(),
+		Family:        claims.Family,
+	}
+}
+
+// ValidateToken checks if provided access token or API key hasn't been revoked
+// and returns the details of its owner
+func (s *authService) ValidateToken(tokenStr string) (*models.UuidAccessDetails, error) {
+	if strings.HasPrefix(tokenStr, APIKeyPrefix) {
+		return s.validateAPIKey(tokenStr)
+	}
+	return s.validateAccessToken(tokenStr)
+}
+
+// validateAccessToken extracts data from provided access token and checks if
+// the token hasn't been revoked
+func (s *authService) validateAccessToken(tokenStr string) (*models.UuidAccessDetails, error) {
+	accessDetails, err := s.ExtractAccessTokenMetadata(tokenStr)
+	if err != nil {
+		return nil, err // no need to log, ExtractAccessTokenMetadata does it
+	}
+
+	username, err := s.repo.Get(accessDetails.Uuid)
+	if err != nil || username != accessDetails.Username {
+		log.Errorf("Token [uuid=%s] of %s is no longer valid: %v", accessDetails.Uuid, accessDetails.Username, err)
+		return nil, ErrTokenRevoked
+	}
+
+	return accessDetails, nil
+}
+
+// Logout removes the access token and the whole family of refresh tokens
+// it belongs to from the database
+func (s *authService) Logout(tokenStr string) error {
+	accessDetails, err := s.validateAccessToken(tokenStr)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(accessDetails.Uuid); err != nil {
+		log.Errorf("Couldn't delete the access token of %s: %v", accessDetails.Username, err)
+		return fmt.Errorf("Couldn't log out; unable to delete the access token")
+	}
+	family, err := s.repo.GetFamily(accessDetails.Family)
+	if err != nil {
+		log.Warnf("Couldn't get the token family [id=%s] of %s: %v", accessDetails.Family, accessDetails.Username, err)
+	} else {
+		s.revokeFamily(family)
+	}
+
+	log.Infof("Successfully logged out %s", accessDetails.Username)
+	return nil
+}
+
+// revokeFamily removes the current tokens of the family and the family itself
+func (s *authService) revokeFamily(family *models.TokenFamily) {
+	for _, uuid := range []string{family.AccessUuid, family.RefreshUuid} {
+		if err := s.repo.Delete(uuid); err != nil {
+			log.Debugf("Couldn't delete the token [uuid=%s]: %v", uuid, err)
+		}
+	}
+	if err := s.repo.DeleteFamily(family); err != nil {
+		log.Errorf("Couldn't delete the token family [id=%s]: %v", family.ID, err)
+	}
+}
+
+// UnlockUser removes the login lockout of the user
+func (s *authService) UnlockUser(username string) error {
+	return s.limiter.UnlockUser(username)
+}
+
+// UnlockIP removes the login lockout of the ip address
+func (s *authService) UnlockIP(ip string) error {
+	return s.limiter.UnlockIP(ip)
+}
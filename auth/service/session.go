@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/0x113/x-media/auth/models"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrSessionNotFound is returned when the user has no session with provided id
+var ErrSessionNotFound = errors.New("Session not found")
+
+// GetSessions returns the sessions of the user sorted from the most recently used,
+// the session with currentID is marked as the current one
+func (s *authService) GetSessions(username, currentID string) ([]*models.Session, error) {
+	families, err := s.repo.GetFamilies(username)
+	if err != nil {
+		log.Errorf("Couldn't get the sessions of %s: %v", username, err)
+		return nil, fmt.Errorf("Couldn't get the sessions")
+	}
+
+	sessions := []*models.Session{}
+	for _, family := range families {
+		sessions = append(sessions, &models.Session{
+			ID:         family.ID,
+			IP:         family.IP,
+			UserAgent:  family.UserAgent,
+			ClientID:   family.ClientID,
+			CreatedAt:  family.CreatedAt,
+			LastUsedAt: family.LastUsedAt,
+			Current:    family.ID == currentID,
+		})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+// RevokeSession revokes the access and refresh token of the user session
+func (s *authService) RevokeSession(username, id string) error {
+	family, err := s.repo.GetFamily(id)
+	if err != nil || family.Username != username {
+		log.Errorf("Couldn't find the session [id=%s] of %s: %v", id, username, err)
+		return ErrSessionNotFound
+	}
+
+	s.revokeFamily(family)
+	log.Infof("Successfully revoked the session [id=%s] of %s", id, username)
+	return nil
+}
+
+// RevokeSessions revokes all of the user sessions except the one with provided id
+func (s *authService) RevokeSessions(username, exceptID string) error {
+	families, err := s.repo.GetFamilies(username)
+	if err != nil {
+		log.Errorf("Couldn't get the sessions of %s: %v", username, err)
+		return fmt.Errorf("Couldn't get the sessions")
+	}
+
+	for _, family := range families {
+		if family.ID != exceptID {
+			s.revokeFamily(family)
+		}
+	}
+
+	log.Infof("Successfully revoked the sessions of %s", username)
+	return nil
+}
@@ -0,0 +1,49 @@
+package service_test
+
+import (
+	"errors"
+
+	"github.com/0x113/x-media/auth/service"
+)
+
+func (suite *AuthServiceTestSuite) TestSessions() {
+	suite.authService = service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	current := suite.generateToken("JohnDoe", false)
+	tv := suite.generateToken("JohnDoe", false)
+	phone := suite.generateToken("JohnDoe", false)
+	other := suite.generateToken("JaneDoe", false)
+
+	sessions, err := suite.authService.GetSessions("JohnDoe", current.FamilyID)
+	suite.Nil(err)
+	suite.Len(sessions, 3)
+	for _, session := range sessions {
+		suite.Equal(session.ID == current.FamilyID, session.Current)
+		suite.Equal(testClient.UserAgent, session.UserAgent)
+	}
+
+	// session of another user can't be revoked
+	err = suite.authService.RevokeSession("JohnDoe", other.FamilyID)
+	suite.True(errors.Is(err, service.ErrSessionNotFound))
+	err = suite.authService.RevokeSession("JohnDoe", "non-existent")
+	suite.True(errors.Is(err, service.ErrSessionNotFound))
+
+	suite.Nil(suite.authService.RevokeSession("JohnDoe", tv.FamilyID))
+	_, err = suite.authService.ValidateToken(tv.AccessToken)
+	suite.True(errors.Is(err, service.ErrTokenRevoked))
+	_, err = suite.authService.Refresh(tv.RefreshToken, testClient)
+	suite.True(errors.Is(err, service.ErrTokenRevoked))
+
+	// revoke all except current
+	suite.Nil(suite.authService.RevokeSessions("JohnDoe", current.FamilyID))
+	_, err = suite.authService.ValidateToken(phone.AccessToken)
+	suite.True(errors.Is(err, service.ErrTokenRevoked))
+	_, err = suite.authService.ValidateToken(current.AccessToken)
+	suite.Nil(err)
+	_, err = suite.authService.ValidateToken(other.AccessToken)
+	suite.Nil(err)
+
+	sessions, err = suite.authService.GetSessions("JohnDoe", current.FamilyID)
+	suite.Nil(err)
+	suite.Len(sessions, 1)
+	suite.True(sessions[0].Current)
+}
@@ -0,0 +1,502 @@
+package service
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/data"
+	"github.com/0x113/x-media/auth/httpclient"
+	"github.com/0x113/x-media/auth/models"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-playground/validator"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// SSOStateTTL defines how long the user has to sign in with the identity provider
+	SSOStateTTL = 10 * time.Minute
+	// oidcMetadataTTL defines how long the discovery document and the keys of the provider are cached
+	oidcMetadataTTL = time.Hour
+
+	defaultUsernameClaim = "preferred_username"
+)
+
+// defaultSSOScopes are requested when the scopes of the provider aren't configured
+var defaultSSOScopes = []string{"openid", "profile", "email"}
+
+var (
+	// ErrSSOProviderNotFound is returned when there is no identity provider with provided name
+	ErrSSOProviderNotFound = errors.New("Identity provider not found")
+	// ErrSSOStateInvalid is returned when the callback doesn't match any pending sign in
+	ErrSSOStateInvalid = errors.New("Sign in request is invalid or expired")
+	// ErrSSOLoginRejected is returned when the identity provider doesn't
+	// confirm the identity of the user
+	ErrSSOLoginRejected = errors.New("Couldn't sign in with the identity provider")
+	// ErrSSOUserNotFound is returned when the user doesn't have the local
+	// account and the provider doesn't create them
+	ErrSSOUserNotFound = errors.New("There is no account for the user")
+	// ErrSSOAccountNotLinked is returned when the identity matches the local
+	// account with the password, the user has to link the identity first
+	ErrSSOAccountNotLinked = errors.New("Sign in with the password and link the identity provider first")
+	// ErrSSOIdentityLinked is returned when the identity is already linked with another account
+	ErrSSOIdentityLinked = errors.New("The identity is already linked with another account")
+)
+
+// SSOService defines the single sign-on with the external OpenID Connect
+// identity providers, the signed in users get the tokens of the authentication service
+type SSOService interface {
+	Providers() []*models.SSOProvider
+	Authorize(provider string) (*models.SSOAuthorization, error)
+	Link(provider, username string) (*models.SSOAuthorization, error)
+	Callback(provider string, callback *models.SSOCallback, client *models.ClientInfo) (*models.TokenDetails, error)
+}
+
+type ssoService struct {
+	httpClient  httpclient.HTTPClient
+	repo        data.SSORepository
+	authService AuthService
+
+	mu       sync.Mutex
+	metadata map[string]*oidcMetadata
+}
+
+// oidcMetadata defines the cached discovery document and the keys of the provider
+type oidcMetadata struct {
+	config    *models.OpenIDConfiguration
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// NewSSOService creates new instance of the SSO service
+func NewSSOService(httpClient httpclient.HTTPClient, repo data.SSORepository, authService AuthService) SSOService {
+	return &ssoService{
+		httpClient:  httpClient,
+		repo:        repo,
+		authService: authService,
+		metadata:    map[string]*oidcMetadata{},
+	}
+}
+
+// Providers returns the configured identity providers sorted by name
+func (s *ssoService) Providers() []*models.SSOProvider {
+	providers := []*models.SSOProvider{}
+	for name, provider := range common.Config.OIDCProviders {
+		displayName := provider.DisplayName
+		if displayName == "" {
+			displayName = name
+		}
+		providers = append(providers, &models.SSOProvider{Name: name, DisplayName: displayName})
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].Name < providers[j].Name
+	})
+	return providers
+}
+
+// Authorize starts the authorization code flow with PKCE, the state, nonce
+// and code verifier are stored until the callback
+func (s *ssoService) Authorize(name string) (*models.SSOAuthorization, error) {
+	return s.authorize(name, "")
+}
+
+// Link starts the authorization code flow of the signed in user, the callback
+// links the identity with their account, so they can sign in with the provider
+func (s *ssoService) Link(name, username string) (*models.SSOAuthorization, error) {
+	return s.authorize(name, username)
+}
+
+// authorize saves the state of the sign in and returns the authorization URL
+// of the provider, the identity is linked with the account of the link user
+func (s *ssoService) authorize(name, link string) (*models.SSOAuthorization, error) {
+	provider, err := ssoProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := s.discover(name, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	var values [3]string
+	for i := range values {
+		if values[i], err = randomToken(); err != nil {
+			log.Errorf("Couldn't generate the SSO state: %v", err)
+			return nil, fmt.Errorf("Couldn't start the sign in")
+		}
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+	ssoState := &models.SSOState{
+		Provider:     name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		Link:         link,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.repo.SaveState(hashToken(state), ssoState, SSOStateTTL); err != nil {
+		log.Errorf("Couldn't save the SSO state: %v", err)
+		return nil, fmt.Errorf("Couldn't start the sign in")
+	}
+
+	scopes := provider.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultSSOScopes
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {provider.ClientID},
+		"redirect_uri":          {provider.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	return &models.SSOAuthorization{
+		AuthorizationURL: redirectURL(metadata.config.AuthorizationEndpoint, params),
+		State:            state,
+	}, nil
+}
+
+// Callback exchanges the authorization code for the ID token, maps its subject
+// to the linked local user and issues the tokens of the authentication service.
+// The identity which isn't linked yet is mapped by the username claim only to
+// the new users and the users without the password, the second factor of the
+// local account is still required.
+func (s *ssoService) Callback(name string, callback *models.SSOCallback, client *models.ClientInfo) (*models.TokenDetails, error) {
+	provider, err := ssoProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	validate := validator.New()
+	if err := validate.Struct(callback); err != nil {
+		return nil, ErrSSOStateInvalid
+	}
+
+	state, err := s.repo.TakeState(hashToken(callback.State))
+	if err != nil || state.Provider != name {
+		log.Errorf("Invalid SSO state for the provider %s: %v", name, err)
+		return nil, ErrSSOStateInvalid
+	}
+	metadata, err := s.discover(name, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	idToken, err := s.exchangeCode(provider, metadata, callback.Code, state.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := s.verifyIDToken(name, provider, idToken, state.Nonce)
+	if err != nil {
+		log.Errorf("Couldn't verify the ID token from the provider %s: %v", name, err)
+		return nil, ErrSSOLoginRejected
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		log.Errorf("ID token from the provider %s doesn't contain the sub claim", name)
+		return nil, ErrSSOLoginRejected
+	}
+	linked, err := s.repo.GetLink(name, subject)
+	if err != nil {
+		log.Errorf("Couldn't get the linked user of the provider %s: %v", name, err)
+		return nil, fmt.Errorf("Couldn't get the linked user")
+	}
+
+	var req *provisionRequest
+	switch {
+	case state.Link != "":
+		if linked != "" && linked != state.Link {
+			log.Errorf("Identity of the provider %s is already linked [username=%s, link=%s]", name, linked, state.Link)
+			return nil, ErrSSOIdentityLinked
+		}
+		req = &provisionRequest{Username: state.Link, ExistingOnly: true}
+	case linked != "":
+		req = &provisionRequest{Username: linked, ExistingOnly: true}
+	default:
+		usernameClaim := provider.UsernameClaim
+		if usernameClaim == "" {
+			usernameClaim = defaultUsernameClaim
+		}
+		username, _ := claims[usernameClaim].(string)
+		if username == "" {
+			log.Errorf("ID token from the provider %s doesn't contain the %s claim", name, usernameClaim)
+			return nil, ErrSSOLoginRejected
+		}
+		req = &provisionRequest{Username: username, ExistingOnly: !provider.AutoCreate, WithoutPassword: true}
+	}
+	if provider.GroupsClaim != "" {
+		admin := containsAny(claimValues(claims[provider.GroupsClaim]), provider.AdminGroups)
+		req.Admin = &admin
+	}
+	accessDetails, err := callUserService(s.httpClient, "/provision", req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errUserNotFound):
+			return nil, ErrSSOUserNotFound
+		case errors.Is(err, errUserHasPassword):
+			return nil, ErrSSOAccountNotLinked
+		}
+		log.Errorf("Couldn't provision SSO user [username=%s]: %v", req.Username, err)
+		return nil, fmt.Errorf("Couldn't provision the local user")
+	}
+	if linked == "" {
+		if err := s.repo.SaveLink(name, subject, accessDetails.Username); err != nil {
+			log.Errorf("Couldn't link the identity of the provider %s [username=%s]: %v", name, accessDetails.Username, err)
+			return nil, fmt.Errorf("Couldn't link the identity")
+		}
+		log.Infof("Linked the identity of the provider %s [username=%s]", name, accessDetails.Username)
+	}
+
+	log.Infof("Successfully signed in with the provider %s [username=%s]", name, accessDetails.Username)
+	return s.authService.LoginVerified(accessDetails, client)
+}
+
+// exchangeCode calls the token endpoint of the provider and returns the ID token
+func (s *ssoService) exchangeCode(provider *common.OIDCProvider, metadata *oidcMetadata, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {provider.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if provider.ClientSecret == "" {
+		form.Set("client_id", provider.ClientID)
+	}
+	req, err := http.NewRequest(http.MethodPost, metadata.config.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		log.Errorf("Couldn't prepare the token request: %v", err)
+		return "", fmt.Errorf("Couldn't prepare the request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if provider.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(provider.ClientID), url.QueryEscape(provider.ClientSecret))
+	}
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		log.Errorf("Couldn't call the token endpoint: %v", err)
+		return "", fmt.Errorf("Couldn't connect to the identity provider")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		oauthErr := new(models.OAuthError)
+		json.NewDecoder(res.Body).Decode(oauthErr)
+		log.Errorf("Token endpoint of the identity provider responded with %d: %v", res.StatusCode, oauthErr)
+		return "", ErrSSOLoginRejected
+	}
+	token := new(models.OIDCTokenResponse)
+	if err := json.NewDecoder(res.Body).Decode(token); err != nil || token.IDToken == "" {
+		log.Errorf("Couldn't decode the ID token from the token response: %v", err)
+		return "", ErrSSOLoginRejected
+	}
+	return token.IDToken, nil
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiration and nonce
+// of the ID token (OpenID Connect Core section 3.1.3.7)
+func (s *ssoService) verifyIDToken(name string, provider *common.OIDCProvider, idToken, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return s.publicKey(name, provider, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.VerifyIssuer(trimIssuer(provider.Issuer), true) {
+		return nil, fmt.Errorf("Invalid issuer: %v", claims["iss"])
+	}
+	audience := claimValues(claims["aud"])
+	if !containsAll(audience, []string{provider.ClientID}) {
+		return nil, fmt.Errorf("Invalid audience: %v", claims["aud"])
+	}
+	if azp, ok := claims["azp"].(string); (ok || len(audience) > 1) && azp != provider.ClientID {
+		return nil, fmt.Errorf("Invalid authorized party: %v", claims["azp"])
+	}
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, fmt.Errorf("ID token is expired")
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, fmt.Errorf("Invalid nonce")
+	}
+	return claims, nil
+}
+
+// publicKey returns the key of the provider with provided kid, the keys are
+// fetched again once when the provider has rotated them
+func (s *ssoService) publicKey(name string, provider *common.OIDCProvider, kid string) (*rsa.PublicKey, error) {
+	metadata, err := s.discover(name, provider)
+	if err != nil {
+		return nil, err
+	}
+	if key := findKey(metadata.keys, kid); key != nil {
+		return key, nil
+	}
+
+	keys, err := s.fetchKeys(metadata.config.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	metadata.keys = keys
+	s.mu.Unlock()
+	if key := findKey(keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("Unknown key: %s", kid)
+}
+
+// discover returns the cached discovery document and the keys of the provider
+func (s *ssoService) discover(name string, provider *common.OIDCProvider) (*oidcMetadata, error) {
+	s.mu.Lock()
+	metadata, ok := s.metadata[name]
+	s.mu.Unlock()
+	if ok && time.Since(metadata.fetchedAt) < oidcMetadataTTL {
+		return metadata, nil
+	}
+
+	issuer := trimIssuer(provider.Issuer)
+	config := new(models.OpenIDConfiguration)
+	if err := s.getJSON(issuer+"/.well-known/openid-configuration", config); err != nil {
+		log.Errorf("Couldn't get the discovery document of the provider %s: %v", name, err)
+		return nil, fmt.Errorf("Couldn't connect to the identity provider")
+	}
+	if trimIssuer(config.Issuer) != issuer {
+		log.Errorf("Issuer of the provider %s doesn't match the discovery document: %s", name, config.Issuer)
+		return nil, fmt.Errorf("Identity provider is misconfigured")
+	}
+	keys, err := s.fetchKeys(config.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata = &oidcMetadata{config: config, keys: keys, fetchedAt: time.Now()}
+	s.mu.Lock()
+	s.metadata[name] = metadata
+	s.mu.Unlock()
+	return metadata, nil
+}
+
+// fetchKeys returns the RSA signing keys of the provider by their kid
+func (s *ssoService) fetchKeys(jwksURI string) (map[string]*rsa.PublicKey, error) {
+	jwks := new(models.JWKS)
+	if err := s.getJSON(jwksURI, jwks); err != nil {
+		log.Errorf("Couldn't get the keys of the identity provider: %v", err)
+		return nil, fmt.Errorf("Couldn't connect to the identity provider")
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := jwkPublicKey(jwk)
+		if err != nil {
+			log.Errorf("Couldn't parse the key %s of the identity provider: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+// getJSON decodes the JSON response of the GET request
+func (s *ssoService) getJSON(u string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Expected status code: %d, got: %d", http.StatusOK, res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// ssoProvider returns the configuration of the identity provider
+func ssoProvider(name string) (*common.OIDCProvider, error) {
+	provider, ok := common.Config.OIDCProviders[name]
+	if !ok {
+		return nil, ErrSSOProviderNotFound
+	}
+	return &provider, nil
+}
+
+// jwkPublicKey converts the JSON Web Key to the RSA public key
+func jwkPublicKey(jwk *models.JWK) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+// findKey returns the key with provided kid, the only key of the provider is
+// used when the token doesn't have the kid
+func findKey(keys map[string]*rsa.PublicKey, kid string) *rsa.PublicKey {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return keys[kid]
+}
+
+// claimValues converts the claim which is a string or an array of strings to the slice
+func claimValues(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := []string{}
+		for _, value := range v {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// trimIssuer removes the trailing slash from the issuer URL
+func trimIssuer(issuer string) string {
+	return strings.TrimSuffix(issuer, "/")
+}
@@ -0,0 +1,337 @@
+package service_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/0x113/x-media/auth/common"
+	"github.com/0x113/x-media/auth/mocks"
+	"github.com/0x113/x-media/auth/models"
+	"github.com/0x113/x-media/auth/service"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ssoUsers mocks the provision endpoint of the user service, only the users
+// in the map exist, the other ones are created unless existing_only is set.
+// The users with the password are refused when without_password is set.
+type ssoUsers struct {
+	users     map[string]bool
+	passwords map[string]bool
+	totp      map[string]bool
+	requests  []map[string]interface{}
+}
+
+// client calls the stub identity provider and mocks the user service
+func (u *ssoUsers) client() *mocks.MockClient {
+	return &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Host != "xmedia-user-svc:8002" {
+				return http.DefaultClient.Do(req)
+			}
+
+			payload := map[string]interface{}{}
+			json.NewDecoder(req.Body).Decode(&payload)
+			u.requests = append(u.requests, payload)
+			username := payload["username"].(string)
+			admin, exists := u.users[username]
+			if !exists && payload["existing_only"] == true {
+				return &http.Response{
+					StatusCode: 404,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"code": 404, "message": "User not found"}`))),
+				}, nil
+			}
+			if exists && u.passwords[username] && payload["without_password"] == true {
+				return &http.Response{
+					StatusCode: 409,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"code": 409, "message": "User signs in with the password"}`))),
+				}, nil
+			}
+			if isAdmin, ok := payload["admin"].(bool); ok {
+				admin = isAdmin
+			}
+			u.users[username] = admin
+			jsonStr := fmt.Sprintf(`{"username": %q, "is_admin": %t, "mfa_required": %t}`, username, admin, u.totp[username])
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(jsonStr))),
+			}, nil
+		},
+	}
+}
+
+// newSSOProvider starts the stub identity provider and configures it as "stub"
+func (suite *AuthServiceTestSuite) newSSOProvider() *mocks.MockOIDCProvider {
+	idp, err := mocks.NewMockOIDCProvider("x-media", "client_secret")
+	suite.Require().Nil(err)
+	common.Config.OIDCProviders = map[string]common.OIDCProvider{
+		"stub": {
+			DisplayName:  "Stub IdP",
+			Issuer:       idp.URL + "/",
+			ClientID:     "x-media",
+			ClientSecret: "client_secret",
+			RedirectURL:  "http://localhost:3000/sso/callback",
+			GroupsClaim:  "groups",
+			AdminGroups:  []string{"xmedia-admins"},
+			AutoCreate:   true,
+		},
+		"google": {ClientID: "google"},
+	}
+	return idp
+}
+
+func (suite *AuthServiceTestSuite) TestSSOProviders() {
+	idp := suite.newSSOProvider()
+	defer idp.Close()
+	ssoService := service.NewSSOService(http.DefaultClient, mocks.NewMockSSORepository(), nil)
+
+	suite.Equal([]*models.SSOProvider{
+		{Name: "google", DisplayName: "google"},
+		{Name: "stub", DisplayName: "Stub IdP"},
+	}, ssoService.Providers())
+
+	_, err := ssoService.Authorize("github")
+	suite.True(errors.Is(err, service.ErrSSOProviderNotFound))
+}
+
+func (suite *AuthServiceTestSuite) TestSSOLogin() {
+	idp := suite.newSSOProvider()
+	defer idp.Close()
+	users := &ssoUsers{users: map[string]bool{"JohnDoe": true}}
+	repo := mocks.NewMockSSORepository()
+	authService := service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	ssoService := service.NewSSOService(users.client(), repo, authService)
+
+	testCases := []struct {
+		name          string
+		claims        jwt.MapClaims
+		autoCreate    bool
+		expectedUser  string
+		expectedAdmin bool
+		wantErr       error
+	}{
+		{
+			name:          "New user from the admin group",
+			claims:        jwt.MapClaims{"preferred_username": "alice", "groups": []string{"staff", "xmedia-admins"}},
+			autoCreate:    true,
+			expectedUser:  "alice",
+			expectedAdmin: true,
+		},
+		{
+			name:          "Existing admin removed from the group",
+			claims:        jwt.MapClaims{"preferred_username": "JohnDoe", "groups": "staff"},
+			expectedUser:  "JohnDoe",
+			expectedAdmin: false,
+		},
+		{
+			name:    "Unknown user without auto create",
+			claims:  jwt.MapClaims{"preferred_username": "bob"},
+			wantErr: service.ErrSSOUserNotFound,
+		},
+		{
+			name:       "Missing username claim",
+			claims:     jwt.MapClaims{"email": "bob@example.org"},
+			autoCreate: true,
+			wantErr:    service.ErrSSOLoginRejected,
+		},
+		{
+			name:       "Foreign issuer",
+			claims:     jwt.MapClaims{"preferred_username": "bob", "iss": "https://evil.example.org"},
+			autoCreate: true,
+			wantErr:    service.ErrSSOLoginRejected,
+		},
+		{
+			name:       "Token issued to another client",
+			claims:     jwt.MapClaims{"preferred_username": "bob", "aud": []string{"other"}},
+			autoCreate: true,
+			wantErr:    service.ErrSSOLoginRejected,
+		},
+		{
+			name:       "Another authorized party",
+			claims:     jwt.MapClaims{"preferred_username": "bob", "aud": []string{"x-media", "other"}, "azp": "other"},
+			autoCreate: true,
+			wantErr:    service.ErrSSOLoginRejected,
+		},
+		{
+			name:       "Replayed nonce",
+			claims:     jwt.MapClaims{"preferred_username": "bob", "nonce": "n-0S6_WzA2Mj"},
+			autoCreate: true,
+			wantErr:    service.ErrSSOLoginRejected,
+		},
+		{
+			name:       "Expired token",
+			claims:     jwt.MapClaims{"preferred_username": "bob", "exp": time.Now().Add(-time.Minute).Unix()},
+			autoCreate: true,
+			wantErr:    service.ErrSSOLoginRejected,
+		},
+		{
+			name:       "Token without expiration",
+			claims:     jwt.MapClaims{"preferred_username": "bob", "exp": nil},
+			autoCreate: true,
+			wantErr:    service.ErrSSOLoginRejected,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			provider := common.Config.OIDCProviders["stub"]
+			provider.AutoCreate = tt.autoCreate
+			common.Config.OIDCProviders["stub"] = provider
+
+			authorization, err := ssoService.Authorize("stub")
+			suite.Require().Nil(err)
+			authURL, err := url.Parse(authorization.AuthorizationURL)
+			suite.Require().Nil(err)
+			suite.Equal(idp.URL+"/authorize", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+			suite.Equal(authorization.State, authURL.Query().Get("state"))
+			suite.Equal("S256", authURL.Query().Get("code_challenge_method"))
+			suite.Equal("openid profile email", authURL.Query().Get("scope"))
+
+			code, err := idp.Authorize(authorization.AuthorizationURL, tt.claims)
+			suite.Require().Nil(err)
+			token, err := ssoService.Callback("stub", &models.SSOCallback{Code: code, State: authorization.State}, testClient)
+			if tt.wantErr != nil {
+				suite.True(errors.Is(err, tt.wantErr), "unexpected error: %v", err)
+				suite.Nil(token)
+				return
+			}
+			suite.Require().Nil(err)
+
+			// the result is the token pair of the authentication service
+			details, err := authService.ValidateToken(token.AccessToken)
+			suite.Require().Nil(err)
+			suite.Equal(tt.expectedUser, details.Username)
+			suite.Equal(tt.expectedAdmin, *details.IsAdmin)
+			suite.Equal(tt.expectedAdmin, users.users[tt.expectedUser])
+		})
+	}
+	suite.Empty(repo.States)
+}
+
+func (suite *AuthServiceTestSuite) TestSSOCallbackState() {
+	idp := suite.newSSOProvider()
+	defer idp.Close()
+	users := &ssoUsers{users: map[string]bool{}}
+	authService := service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	ssoService := service.NewSSOService(users.client(), mocks.NewMockSSORepository(), authService)
+	claims := jwt.MapClaims{"preferred_username": "alice"}
+
+	// unknown state
+	_, err := ssoService.Callback("stub", &models.SSOCallback{Code: "code", State: "unknown"}, testClient)
+	suite.True(errors.Is(err, service.ErrSSOStateInvalid))
+
+	// state can be used only once
+	authorization, err := ssoService.Authorize("stub")
+	suite.Require().Nil(err)
+	code, err := idp.Authorize(authorization.AuthorizationURL, claims)
+	suite.Require().Nil(err)
+	_, err = ssoService.Callback("stub", &models.SSOCallback{Code: code, State: authorization.State}, testClient)
+	suite.Nil(err)
+	_, err = ssoService.Callback("stub", &models.SSOCallback{Code: code, State: authorization.State}, testClient)
+	suite.True(errors.Is(err, service.ErrSSOStateInvalid))
+
+	// state started for another provider
+	common.Config.OIDCProviders["other"] = common.Config.OIDCProviders["stub"]
+	authorization, err = ssoService.Authorize("other")
+	suite.Require().Nil(err)
+	code, err = idp.Authorize(authorization.AuthorizationURL, claims)
+	suite.Require().Nil(err)
+	_, err = ssoService.Callback("stub", &models.SSOCallback{Code: code, State: authorization.State}, testClient)
+	suite.True(errors.Is(err, service.ErrSSOStateInvalid))
+
+	// code of another sign in is rejected by the provider, the PKCE verifier doesn't match
+	first, err := ssoService.Authorize("stub")
+	suite.Require().Nil(err)
+	second, err := ssoService.Authorize("stub")
+	suite.Require().Nil(err)
+	code, err = idp.Authorize(first.AuthorizationURL, claims)
+	suite.Require().Nil(err)
+	_, err = ssoService.Callback("stub", &models.SSOCallback{Code: code, State: second.State}, testClient)
+	suite.True(errors.Is(err, service.ErrSSOLoginRejected))
+
+	// keys rotated by the provider are fetched again
+	suite.Require().Nil(idp.RotateKey())
+	authorization, err = ssoService.Authorize("stub")
+	suite.Require().Nil(err)
+	code, err = idp.Authorize(authorization.AuthorizationURL, claims)
+	suite.Require().Nil(err)
+	_, err = ssoService.Callback("stub", &models.SSOCallback{Code: code, State: authorization.State}, testClient)
+	suite.Nil(err)
+}
+
+func (suite *AuthServiceTestSuite) TestSSOLink() {
+	idp := suite.newSSOProvider()
+	defer idp.Close()
+	users := &ssoUsers{
+		users:     map[string]bool{"JohnDoe": true, "carol": false, "dave": false},
+		passwords: map[string]bool{"JohnDoe": true, "carol": true},
+		totp:      map[string]bool{"carol": true, "dave": true},
+	}
+	repo := mocks.NewMockSSORepository()
+	authService := service.NewAuthService(service.NewUserServiceBackend(suite.httpClient), suite.authRepo, suite.keys, suite.limiter)
+	ssoService := service.NewSSOService(users.client(), repo, authService)
+
+	signIn := func(authorization *models.SSOAuthorization, claims jwt.MapClaims) (*models.TokenDetails, error) {
+		code, err := idp.Authorize(authorization.AuthorizationURL, claims)
+		suite.Require().Nil(err)
+		return ssoService.Callback("stub", &models.SSOCallback{Code: code, State: authorization.State}, testClient)
+	}
+
+	// the username claim doesn't map the identity to the account with the password
+	authorization, err := ssoService.Authorize("stub")
+	suite.Require().Nil(err)
+	_, err = signIn(authorization, jwt.MapClaims{"preferred_username": "JohnDoe", "sub": "subject-john"})
+	suite.True(errors.Is(err, service.ErrSSOAccountNotLinked), "unexpected error: %v", err)
+	suite.Empty(repo.Links)
+
+	// the signed in user links the identity with another username
+	authorization, err = ssoService.Link("stub", "JohnDoe")
+	suite.Require().Nil(err)
+	token, err := signIn(authorization, jwt.MapClaims{"preferred_username": "john.doe", "sub": "subject-john"})
+	suite.Require().Nil(err)
+	details, err := authService.ValidateToken(token.AccessToken)
+	suite.Require().Nil(err)
+	suite.Equal("JohnDoe", details.Username)
+	suite.Equal("JohnDoe", repo.Links["stub subject-john"])
+
+	// the linked subject is mapped to the linked user despite the username claim
+	authorization, err = ssoService.Authorize("stub")
+	suite.Require().Nil(err)
+	token, err = signIn(authorization, jwt.MapClaims{"preferred_username": "dave", "sub": "subject-john"})
+	suite.Require().Nil(err)
+	details, err = authService.ValidateToken(token.AccessToken)
+	suite.Require().Nil(err)
+	suite.Equal("JohnDoe", details.Username)
+
+	// the identity linked with another account can't be linked again
+	authorization, err = ssoService.Link("stub", "carol")
+	suite.Require().Nil(err)
+	_, err = signIn(authorization, jwt.MapClaims{"preferred_username": "carol", "sub": "subject-john"})
+	suite.True(errors.Is(err, service.ErrSSOIdentityLinked), "unexpected error: %v", err)
+	suite.Equal("JohnDoe", repo.Links["stub subject-john"])
+
+	// the second factor of the local account is still required
+	var mfaErr *service.MFARequiredError
+	authorization, err = ssoService.Link("stub", "carol")
+	suite.Require().Nil(err)
+	token, err = signIn(authorization, jwt.MapClaims{"preferred_username": "carol", "sub": "subject-carol"})
+	suite.True(errors.As(err, &mfaErr), "unexpected error: %v", err)
+	suite.Nil(token)
+	authorization, err = ssoService.Authorize("stub")
+	suite.Require().Nil(err)
+	token, err = signIn(authorization, jwt.MapClaims{"preferred_username": "dave"})
+	suite.True(errors.As(err, &mfaErr), "unexpected error: %v", err)
+	suite.Nil(token)
+
+	// the identity without the subject is rejected
+	authorization, err = ssoService.Authorize("stub")
+	suite.Require().Nil(err)
+	_, err = signIn(authorization, jwt.MapClaims{"preferred_username": "dave", "sub": nil})
+	suite.True(errors.Is(err, service.ErrSSOLoginRejected), "unexpected error: %v", err)
+}
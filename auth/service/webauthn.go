@@ -0,0 +1,244 @@
+package service
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0x113/x-media/auth/common"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// the COSE algorithms of the supported passkeys
+const (
+	coseAlgES256 = -7
+	coseAlgRS256 = -257
+)
+
+// the flags of the authenticator data
+const (
+	flagUserPresent        = 0x01
+	flagUserVerified       = 0x04
+	flagAttestedCredential = 0x40
+)
+
+// clientData defines the fields of clientDataJSON checked by the relying party
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// authenticatorData defines the parsed authenticator data, the credential is
+// present only in the attestation
+type authenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	CredentialID []byte
+	PublicKey    []byte // COSE encoded
+}
+
+// attestationObject defines the CBOR encoded attestation of the new credential
+type attestationObject struct {
+	Format    string                     `cbor:"fmt"`
+	Statement map[string]cbor.RawMessage `cbor:"attStmt"`
+	AuthData  []byte                     `cbor:"authData"`
+}
+
+// packedStatement defines the attestation statement of the packed format
+type packedStatement struct {
+	Alg int64    `cbor:"alg"`
+	Sig []byte   `cbor:"sig"`
+	X5C [][]byte `cbor:"x5c"`
+}
+
+// parseClientData checks the type and origin of the client data and returns the challenge
+func parseClientData(raw []byte, ceremony string) (string, error) {
+	cd := new(clientData)
+	if err := json.Unmarshal(raw, cd); err != nil {
+		return "", fmt.Errorf("invalid client data: %v", err)
+	}
+	if cd.Type != ceremony {
+		return "", fmt.Errorf("unexpected ceremony type: %s", cd.Type)
+	}
+	if !containsAny([]string{cd.Origin}, common.Config.WebAuthnOrigins) {
+		return "", fmt.Errorf("origin %s isn't allowed", cd.Origin)
+	}
+	if _, err := base64.RawURLEncoding.DecodeString(cd.Challenge); err != nil || cd.Challenge == "" {
+		return "", errors.New("invalid challenge")
+	}
+	return cd.Challenge, nil
+}
+
+// parseAuthenticatorData parses the authenticator data and checks that it
+// was created for the relying party with the user present and verified, the
+// passkey replaces both the password and the second factor
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, errors.New("authenticator data is too short")
+	}
+	ad := &authenticatorData{
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	rpIDHash := sha256.Sum256([]byte(common.Config.WebAuthnRPID))
+	if !bytes.Equal(ad.RPIDHash, rpIDHash[:]) {
+		return nil, errors.New("authenticator data was created for another relying party")
+	}
+	if ad.Flags&flagUserPresent == 0 {
+		return nil, errors.New("user wasn't present")
+	}
+	if ad.Flags&flagUserVerified == 0 {
+		return nil, errors.New("user wasn't verified")
+	}
+	if ad.Flags&flagAttestedCredential == 0 {
+		return ad, nil
+	}
+
+	// attested credential data: aaguid, credential id length, credential id, public key
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return nil, errors.New("attested credential data is too short")
+	}
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || len(rest) < idLen {
+		return nil, errors.New("invalid credential id")
+	}
+	ad.CredentialID = rest[:idLen]
+	rest = rest[idLen:]
+
+	// the public key may be followed by the extensions
+	var key cbor.RawMessage
+	decoder := cbor.NewDecoder(bytes.NewReader(rest))
+	if err := decoder.Decode(&key); err != nil {
+		return nil, fmt.Errorf("invalid credential public key: %v", err)
+	}
+	ad.PublicKey = rest[:decoder.NumBytesRead()]
+	return ad, nil
+}
+
+// parseCOSEKey decodes the ES256 or RS256 public key, the meaning of the
+// parameters with negative labels depends on the key type
+func parseCOSEKey(raw []byte) (crypto.PublicKey, int64, error) {
+	var params map[int64]cbor.RawMessage
+	if err := cbor.Unmarshal(raw, &params); err != nil {
+		return nil, 0, fmt.Errorf("invalid COSE key: %v", err)
+	}
+	var kty, alg int64
+	if err := cbor.Unmarshal(params[1], &kty); err != nil {
+		return nil, 0, errors.New("COSE key without the key type")
+	}
+	if err := cbor.Unmarshal(params[3], &alg); err != nil {
+		return nil, 0, errors.New("COSE key without the algorithm")
+	}
+
+	switch {
+	case kty == 2 && alg == coseAlgES256:
+		var crv int64
+		var x, y []byte
+		if cbor.Unmarshal(params[-1], &crv) != nil || cbor.Unmarshal(params[-2], &x) != nil || cbor.Unmarshal(params[-3], &y) != nil {
+			return nil, 0, errors.New("invalid EC2 key parameters")
+		}
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("only the P-256 curve is supported")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, 0, errors.New("point isn't on the curve")
+		}
+		return key, alg, nil
+	case kty == 3 && alg == coseAlgRS256:
+		var n, e []byte
+		if cbor.Unmarshal(params[-1], &n) != nil || cbor.Unmarshal(params[-2], &e) != nil || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("invalid RSA key parameters")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < 2048 {
+			return nil, 0, errors.New("RSA key is too short")
+		}
+		return key, alg, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported key type %d with algorithm %d", kty, alg)
+	}
+}
+
+// verifySignature checks the signature of the data made with the COSE algorithm
+func verifySignature(key crypto.PublicKey, alg int64, data, sig []byte) error {
+	digest := sha256.Sum256(data)
+	switch pub := key.(type) {
+	case *ecdsa.PublicKey:
+		if alg != coseAlgES256 {
+			break
+		}
+		var esSig struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(sig, &esSig); err != nil || len(rest) > 0 {
+			return errors.New("invalid ECDSA signature")
+		}
+		if !ecdsa.Verify(pub, digest[:], esSig.R, esSig.S) {
+			return errors.New("ECDSA signature doesn't match")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if alg != coseAlgRS256 {
+			break
+		}
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+	}
+	return fmt.Errorf("algorithm %d doesn't match the key", alg)
+}
+
+// verifyAttestation checks the attestation statement, only the none and the
+// packed formats are accepted, the packed attestation is signed either with
+// the credential key itself or with the leaf of the certificate chain
+func verifyAttestation(att *attestationObject, clientDataHash []byte, key crypto.PublicKey, alg int64) error {
+	switch att.Format {
+	case "none":
+		if len(att.Statement) != 0 {
+			return errors.New("none attestation with the statement")
+		}
+		return nil
+	case "packed":
+		raw, err := cbor.Marshal(att.Statement)
+		if err != nil {
+			return err
+		}
+		stmt := new(packedStatement)
+		if err := cbor.Unmarshal(raw, stmt); err != nil {
+			return fmt.Errorf("invalid packed statement: %v", err)
+		}
+		signed := append(append([]byte{}, att.AuthData...), clientDataHash...)
+		if len(stmt.X5C) == 0 {
+			if stmt.Alg != alg {
+				return errors.New("self attestation algorithm doesn't match the key")
+			}
+			return verifySignature(key, alg, signed, stmt.Sig)
+		}
+		cert, err := x509.ParseCertificate(stmt.X5C[0])
+		if err != nil {
+			return fmt.Errorf("invalid attestation certificate: %v", err)
+		}
+		switch stmt.Alg {
+		case coseAlgES256, coseAlgRS256:
+			return verifySignature(cert.PublicKey, stmt.Alg, signed, stmt.Sig)
+		default:
+			return fmt.Errorf("unsupported attestation algorithm %d", stmt.Alg)
+		}
+	default:
+		return fmt.Errorf("unsupported attestation format %s", att.Format)
+	}
+}
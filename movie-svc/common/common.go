@@ -26,6 +26,16 @@ type Configuration struct {
 	MovieDirectories []string `json:"movie_directories"`
 
 	TMDbAPIKey string `json:"tmdb_api_key"`
+
+	// AuthMode defines how the bearer tokens are validated: "local" or "remote"
+	AuthMode        string `json:"auth_mode"`
+	AuthJWKSURL     string `json:"auth_jwks_url"`
+	AuthValidateURL string `json:"auth_validate_url"`
+	// AuthCacheTTL defines for how many seconds remotely validated tokens are cached
+	AuthCacheTTL int `json:"auth_cache_ttl"`
+	// AuthIssuer and AuthAudience must match the iss and aud claims of the access tokens, empty values aren't checked
+	AuthIssuer   string `json:"auth_issuer"`
+	AuthAudience string `json:"auth_audience"`
 }
 
 // Config shares the global configuration
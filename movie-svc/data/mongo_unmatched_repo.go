@@ -0,0 +1,128 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/0x113/x-media/movie-svc/databases"
+	"github.com/0x113/x-media/movie-svc/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	unmatchedCollectionName = "unmatched_movies"
+)
+
+// unmatchedRepository manages the unmatched items CRUD
+type unmatchedRepository struct{}
+
+// NewMongoUnmatchedRepository returns new instance of the unmatched items repository
+func NewMongoUnmatchedRepository() UnmatchedRepository {
+	return &unmatchedRepository{}
+}
+
+// Save inserts new unmatched item or replaces existing one with the same id
+func (r *unmatchedRepository) Save(item *models.UnmatchedItem) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessionCopy := databases.Database.Session
+	defer sessionCopy.EndSession(ctx)
+
+	collection := sessionCopy.Client().Database(databases.Database.DbName).Collection(unmatchedCollectionName)
+
+	_, err := collection.ReplaceOne(
+		ctx,
+		bson.M{"_id": item.ID},
+		item,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetByID returns unmatched item from the database based on its id
+func (r *unmatchedRepository) GetByID(id primitive.ObjectID) (*models.UnmatchedItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessionCopy := databases.Database.Session
+	defer sessionCopy.EndSession(ctx)
+
+	collection := sessionCopy.Client().Database(databases.Database.DbName).Collection(unmatchedCollectionName)
+
+	var item models.UnmatchedItem
+	if err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&item); err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// GetByFilePath returns unmatched item from the database based on its file path
+func (r *unmatchedRepository) GetByFilePath(filePath string) (*models.UnmatchedItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessionCopy := databases.Database.Session
+	defer sessionCopy.EndSession(ctx)
+
+	collection := sessionCopy.Client().Database(databases.Database.DbName).Collection(unmatchedCollectionName)
+
+	var item models.UnmatchedItem
+	if err := collection.FindOne(ctx, bson.M{"file_path": filePath}).Decode(&item); err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// GetAll returns all unmatched items from the database
+func (r *unmatchedRepository) GetAll() ([]*models.UnmatchedItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessionCopy := databases.Database.Session
+	defer sessionCopy.EndSession(ctx)
+
+	collection := sessionCopy.Client().Database(databases.Database.DbName).Collection(unmatchedCollectionName)
+
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"updated_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	var items []*models.UnmatchedItem
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Delete removes unmatched item from the database
+func (r *unmatchedRepository) Delete(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessionCopy := databases.Database.Session
+	defer sessionCopy.EndSession(ctx)
+
+	collection := sessionCopy.Client().Database(databases.Database.DbName).Collection(unmatchedCollectionName)
+
+	res, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
@@ -15,3 +15,12 @@ type MovieRepository interface {
 	GetAll() ([]*models.Movie, error)
 	GetByID(id primitive.ObjectID) (*models.Movie, error)
 }
+
+// UnmatchedRepository contains all methods for operation on the UnmatchedItem model
+type UnmatchedRepository interface {
+	Save(item *models.UnmatchedItem) error
+	GetByID(id primitive.ObjectID) (*models.UnmatchedItem, error)
+	GetByFilePath(filePath string) (*models.UnmatchedItem, error)
+	GetAll() ([]*models.UnmatchedItem, error)
+	Delete(id primitive.ObjectID) error
+}
@@ -18,6 +18,21 @@ type TMDbAPIClient struct {
 
 // GetTMDbQueryMovieInfo calls the TMDb API and returns new movie info.
 func (t *TMDbAPIClient) GetTMDbQueryMovieInfo(title, lang string) (*models.TMDbQueryMovie, error) {
+	results, err := t.SearchTMDbMovies(title, lang)
+	if err != nil {
+		return nil, err
+	}
+	// get title of the first result
+	if len(results) == 0 {
+		return nil, fmt.Errorf("Unable to find movie with title: %s", title)
+	}
+
+	return results[0], nil
+}
+
+// SearchTMDbMovies calls the TMDb API and returns all movies from the first
+// page of the search results.
+func (t *TMDbAPIClient) SearchTMDbMovies(title, lang string) ([]*models.TMDbQueryMovie, error) {
 	queryTitle := url.QueryEscape(title)
 	apiUrl := fmt.Sprintf("https://api.themoviedb.org/3/search/movie?api_key=%s&query=%s&language=%s", common.Config.TMDbAPIKey, queryTitle, lang)
 	// request
@@ -41,12 +56,8 @@ func (t *TMDbAPIClient) GetTMDbQueryMovieInfo(title, lang string) (*models.TMDbQ
 	if err := json.NewDecoder(res.Body).Decode(tmdbQueryRes); err != nil {
 		return nil, err
 	}
-	// get title of the first result
-	if len(tmdbQueryRes.Results) == 0 {
-		return nil, fmt.Errorf("Unable to find movie with title: %s", title)
-	}
 
-	return tmdbQueryRes.Results[0], nil
+	return tmdbQueryRes.Results, nil
 }
 
 // GetTMDbMovieInfo calls the TMDb API (https://api.themoviedb.org/3/movie/{movie_id}?api_key={api_key}&language={lang}
@@ -125,7 +125,7 @@ func (suite *TMDbAPIClientTestSuite) TestGetTMDbQueryMovieInfo() {
 	}
 
 	for _, tt := range testCases {
-		client := &mocks.MockClient{tt.DoFunc}
+		client := &mocks.MockClient{DoFunc: tt.DoFunc}
 		tmdbApiClient := &tmdb.TMDbAPIClient{client}
 		suite.Run(tt.name, func() {
 			movieQ, err := tmdbApiClient.GetTMDbQueryMovieInfo("Heat", "en")
@@ -140,6 +140,83 @@ func (suite *TMDbAPIClientTestSuite) TestGetTMDbQueryMovieInfo() {
 	}
 }
 
+func (suite *TMDbAPIClientTestSuite) TestSearchTMDbMovies() {
+	testCases := []struct {
+		name            string
+		DoFunc          func(req *http.Request) (*http.Response, error)
+		expectedResults int
+		wantErr         bool
+	}{
+		{
+			name: "Success; two results",
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				json := `{
+   "page":1,
+   "total_results":2,
+   "total_pages":1,
+   "results":[
+      {
+         "id":949,
+         "title":"Heat",
+         "release_date":"1995-12-15",
+         "original_title":"Heat"
+      },
+      {
+         "id":11448,
+         "title":"Heat",
+         "release_date":"1986-03-14",
+         "original_title":"Heat"
+      }
+   ]
+}`
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(json))),
+				}, nil
+			},
+			expectedResults: 2,
+			wantErr:         false,
+		},
+		{
+			name: "Success; empty results list",
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				json := `{"page":1, "total_results":0, "total_pages":1, "results":[]}`
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(json))),
+				}, nil
+			},
+			expectedResults: 0,
+			wantErr:         false,
+		},
+		{
+			name: "Wrong response status code",
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusUnauthorized,
+					Body:       ioutil.NopCloser(nil),
+				}, nil
+			},
+			expectedResults: 0,
+			wantErr:         true,
+		},
+	}
+
+	for _, tt := range testCases {
+		client := &mocks.MockClient{DoFunc: tt.DoFunc}
+		tmdbApiClient := &tmdb.TMDbAPIClient{Client: client}
+		suite.Run(tt.name, func() {
+			results, err := tmdbApiClient.SearchTMDbMovies("Heat", "en")
+			if tt.wantErr {
+				suite.NotNil(err)
+			} else {
+				suite.Nil(err)
+			}
+			suite.Len(results, tt.expectedResults)
+		})
+	}
+}
+
 func (suite *TMDbAPIClientTestSuite) TestGetTMDbMovieInfo() {
 	testCases := []struct {
 		name    string
@@ -267,7 +344,7 @@ func (suite *TMDbAPIClientTestSuite) TestGetTMDbMovieInfo() {
 	}
 
 	for _, tt := range testCases {
-		client := &mocks.MockClient{tt.DoFunc}
+		client := &mocks.MockClient{DoFunc: tt.DoFunc}
 		tmdbApiClient := &tmdb.TMDbAPIClient{client}
 
 		suite.Run(tt.name, func() {
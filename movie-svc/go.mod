@@ -3,7 +3,9 @@ module github.com/0x113/x-media/movie-svc
 go 1.14
 
 require (
+	github.com/0x113/x-media/auth v0.0.0
 	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/go-openapi/runtime v0.19.20
 	github.com/labstack/echo v3.3.10+incompatible
 	github.com/labstack/gommon v0.3.0 // indirect
@@ -14,3 +16,5 @@ require (
 	go.mongodb.org/mongo-driver v1.3.4
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 )
+
+replace github.com/0x113/x-media/auth => ../auth
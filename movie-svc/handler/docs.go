@@ -24,3 +24,8 @@ type updatedMovie struct {
 type movieListResponse struct {
 	Movies []*models.Movie `json:"movies"`
 }
+
+type assignUnmatchedPayload struct {
+	TMDbID   int    `json:"tmdb_id" example:"949"`
+	Language string `json:"language" example:"en"`
+}
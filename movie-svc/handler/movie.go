@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
+	authmw "github.com/0x113/x-media/auth/pkg/middleware"
 	"github.com/0x113/x-media/movie-svc/models"
 	"github.com/0x113/x-media/movie-svc/service"
 
@@ -15,8 +17,14 @@ type movieHandler struct {
 	movieService service.MovieService
 }
 
-// NewMovieHandler initiates the movie handlers
-func NewMovieHandler(router *echo.Echo, movieService service.MovieService) {
+// unmatchedListResponse is returned by GetUnmatchedItems
+type unmatchedListResponse struct {
+	Unmatched []*models.UnmatchedItem `json:"unmatched"`
+}
+
+// NewMovieHandler initiates the movie handlers, all of the api routes require
+// a valid token with the scope matching the route
+func NewMovieHandler(router *echo.Echo, movieService service.MovieService, validator authmw.Validator) {
 	h := &movieHandler{movieService}
 	// swagger
 	opts := middleware.RedocOpts{SpecURL: "/swagger.yaml"}
@@ -24,9 +32,18 @@ func NewMovieHandler(router *echo.Echo, movieService service.MovieService) {
 	router.File("/swagger.yaml", "./docs/swagger.yaml")
 	router.GET("/docs", echo.WrapHandler(sh))
 
-	router.POST("/api/v1/movies/update/all", h.UpdateAllMovies)
-	router.GET("/api/v1/movies/all", h.GetAllMovies)
-	router.GET("/api/v1/movies/:id", h.GetMovieByID)
+	read := authmw.RequireScope(authmw.ScopeLibraryRead)
+	scan := authmw.RequireScope(authmw.ScopeLibraryScan)
+	edit := authmw.RequireScope(authmw.ScopeLibraryEdit)
+
+	api := router.Group("/api/v1/movies", authmw.Middleware(validator))
+	api.POST("/update/all", h.UpdateAllMovies, scan)
+	api.GET("/all", h.GetAllMovies, read)
+	api.GET("/unmatched", h.GetUnmatchedItems, edit)
+	api.POST("/unmatched/:id/retry", h.RetryUnmatchedItem, edit)
+	api.POST("/unmatched/:id/ignore", h.IgnoreUnmatchedItem, edit)
+	api.POST("/unmatched/:id/assign", h.AssignUnmatchedItem, edit)
+	api.GET("/:id", h.GetMovieByID, read)
 }
 
 // @Summary Update all movies
@@ -102,3 +119,146 @@ func (h *movieHandler) GetMovieByID(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, movie)
 }
+
+// @Summary Get unmatched items
+// @Description Returns all files which couldn't be matched with the TMDb API along with the reason and candidates
+// @ID get-unmatched-items
+// @Produce  json
+// @Success 200 {object} unmatchedListResponse
+// @Failure 500 {object} models.Error
+// @Router /unmatched [get]
+// GetUnmatchedItems calls the service to get all items from the unmatched items queue
+func (h *movieHandler) GetUnmatchedItems(c echo.Context) error {
+	errMsg := new(models.Error)
+	items, err := h.movieService.GetUnmatchedItems()
+	if err != nil {
+		errMsg.Code = http.StatusInternalServerError
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &unmatchedListResponse{Unmatched: items})
+}
+
+// @Summary Retry unmatched item
+// @Description Searches the TMDb API for the unmatched file once again
+// @ID retry-unmatched-item
+// @Accept  json
+// @Produce  json
+// @Param id path string true "unmatched item id"
+// @Param name body updateAllMoviesPayload true "the language in which to update the movie data"
+// @Success 200 {object} models.Movie
+// @Failure 400 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /unmatched/{id}/retry [post]
+// RetryUnmatchedItem calls the service to match the unmatched file once again
+func (h *movieHandler) RetryUnmatchedItem(c echo.Context) error {
+	var reqBody struct {
+		Language string `json:"language"`
+	}
+	if err := c.Bind(&reqBody); err != nil {
+		errMsg := models.Error{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	movie, err := h.movieService.RetryUnmatchedItem(c.Param("id"), reqBody.Language)
+	if err != nil {
+		errMsg := models.Error{
+			Code:    unmatchedStatus(err),
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, movie)
+}
+
+// @Summary Ignore unmatched item
+// @Description Marks the unmatched file as ignored, so it's skipped by the next update
+// @ID ignore-unmatched-item
+// @Produce  json
+// @Param id path string true "unmatched item id"
+// @Success 200 {object} models.UnmatchedItem
+// @Failure 400 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /unmatched/{id}/ignore [post]
+// IgnoreUnmatchedItem calls the service to ignore the unmatched file
+func (h *movieHandler) IgnoreUnmatchedItem(c echo.Context) error {
+	errMsg := new(models.Error)
+	item, err := h.movieService.IgnoreUnmatchedItem(c.Param("id"))
+	if err != nil {
+		errMsg.Code = unmatchedStatus(err)
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, item)
+}
+
+// @Summary Assign unmatched item
+// @Description Gets the movie with provided TMDb ID from the TMDb API and saves it for the unmatched file
+// @ID assign-unmatched-item
+// @Accept  json
+// @Produce  json
+// @Param id path string true "unmatched item id"
+// @Param name body assignUnmatchedPayload true "TMDb ID of the movie and the language in which to update the movie data"
+// @Success 200 {object} models.Movie
+// @Failure 400 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /unmatched/{id}/assign [post]
+// AssignUnmatchedItem calls the service to assign the TMDb ID to the unmatched file manually
+func (h *movieHandler) AssignUnmatchedItem(c echo.Context) error {
+	var reqBody struct {
+		TMDbID   int    `json:"tmdb_id"`
+		Language string `json:"language"`
+	}
+	if err := c.Bind(&reqBody); err != nil {
+		errMsg := models.Error{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+	if reqBody.TMDbID <= 0 {
+		errMsg := models.Error{
+			Code:    http.StatusBadRequest,
+			Message: "Field tmdb_id is required",
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return errors.New(errMsg.Message)
+	}
+
+	movie, err := h.movieService.AssignUnmatchedItem(c.Param("id"), reqBody.TMDbID, reqBody.Language)
+	if err != nil {
+		errMsg := models.Error{
+			Code:    unmatchedStatus(err),
+			Message: err.Error(),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, movie)
+}
+
+// unmatchedStatus returns the status code of the unmatched items queue error
+func unmatchedStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidUnmatchedID):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrUnmatchedNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
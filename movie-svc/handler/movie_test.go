@@ -24,6 +24,7 @@ type MovieHandlerTestSuite struct {
 	router          *echo.Echo
 	httpClient      httpclient.HTTPClient
 	movieRepository *mocks.MockMovieRepository
+	unmatchedRepo   *mocks.MockUnmatchedRepository
 	movieService    service.MovieService
 }
 
@@ -34,6 +35,7 @@ func (suite *MovieHandlerTestSuite) SetupTest() {
 	}
 	suite.router = echo.New()
 	suite.movieRepository = mocks.NewMockMovieRepository()
+	suite.unmatchedRepo = mocks.NewMockUnmatchedRepository()
 	logrus.SetOutput(ioutil.Discard)
 
 	// create temporary directries and files
@@ -119,8 +121,8 @@ func (suite *MovieHandlerTestSuite) TestUpdateAllMovies() {
 	}
 
 	for _, tt := range testCases {
-		suite.httpClient = &mocks.MockClient{tt.doFunc}
-		suite.movieService = service.NewMovieService(suite.movieRepository, suite.httpClient)
+		suite.httpClient = &mocks.MockClient{DoFunc: tt.doFunc}
+		suite.movieService = service.NewMovieService(suite.movieRepository, suite.unmatchedRepo, suite.httpClient)
 		h := movieHandler{suite.movieService}
 
 		suite.Run(tt.name, func() {
@@ -143,7 +145,7 @@ func (suite *MovieHandlerTestSuite) TestUpdateAllMovies() {
 func (suite *MovieHandlerTestSuite) TestGetAllMovies() {
 	// setup
 	suite.httpClient = &mocks.MockClient{}
-	suite.movieService = service.NewMovieService(suite.movieRepository, suite.httpClient)
+	suite.movieService = service.NewMovieService(suite.movieRepository, suite.unmatchedRepo, suite.httpClient)
 	h := movieHandler{suite.movieService}
 
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/movies/get/all", nil)
@@ -158,7 +160,7 @@ func (suite *MovieHandlerTestSuite) TestGetAllMovies() {
 func (suite *MovieHandlerTestSuite) TestGetMovieByID() {
 	// setup
 	suite.httpClient = &mocks.MockClient{}
-	suite.movieService = service.NewMovieService(suite.movieRepository, suite.httpClient)
+	suite.movieService = service.NewMovieService(suite.movieRepository, suite.unmatchedRepo, suite.httpClient)
 	h := movieHandler{suite.movieService}
 
 	testCases := []struct {
@@ -199,3 +201,142 @@ func (suite *MovieHandlerTestSuite) TestGetMovieByID() {
 		})
 	}
 }
+
+func (suite *MovieHandlerTestSuite) TestGetUnmatchedItems() {
+	suite.httpClient = &mocks.MockClient{}
+	suite.movieService = service.NewMovieService(suite.movieRepository, suite.unmatchedRepo, suite.httpClient)
+	h := movieHandler{suite.movieService}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/movies/unmatched", nil)
+	rec := httptest.NewRecorder()
+	c := suite.router.NewContext(req, rec)
+
+	err := h.GetUnmatchedItems(c)
+	suite.Nil(err)
+	suite.Equal(200, rec.Code)
+}
+
+func (suite *MovieHandlerTestSuite) TestIgnoreUnmatchedItem() {
+	suite.httpClient = &mocks.MockClient{}
+	suite.movieService = service.NewMovieService(suite.movieRepository, suite.unmatchedRepo, suite.httpClient)
+	h := movieHandler{suite.movieService}
+
+	testCases := []struct {
+		name               string
+		id                 string
+		expectedStatusCode int
+		wantErr            bool
+	}{
+		{
+			name:               "Success",
+			id:                 "5f4bd6a4c6e9ab2e4f8b4567",
+			expectedStatusCode: 200,
+			wantErr:            false,
+		},
+		{
+			name:               "Non-existent item",
+			id:                 "5f4bd6a4c6e9ab2e4f8b4560",
+			expectedStatusCode: 404,
+			wantErr:            true,
+		},
+		{
+			name:               "Malformed id",
+			id:                 "heat",
+			expectedStatusCode: 400,
+			wantErr:            true,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			rec := httptest.NewRecorder()
+			c := suite.router.NewContext(req, rec)
+			c.SetPath("/api/v1/movies/unmatched/:id/ignore")
+			c.SetParamNames("id")
+			c.SetParamValues(tt.id)
+			err := h.IgnoreUnmatchedItem(c)
+			if tt.wantErr {
+				suite.NotNil(err)
+			} else {
+				suite.Nil(err)
+			}
+			suite.Equal(tt.expectedStatusCode, rec.Code)
+		})
+	}
+}
+
+func (suite *MovieHandlerTestSuite) TestAssignUnmatchedItem() {
+	suite.httpClient = &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			json := `{"id": 949, "title": "Heat", "original_title": "Heat", "original_language": "en"}`
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(json))),
+			}, nil
+		},
+	}
+	suite.movieService = service.NewMovieService(suite.movieRepository, suite.unmatchedRepo, suite.httpClient)
+	h := movieHandler{suite.movieService}
+
+	testCases := []struct {
+		name               string
+		id                 string
+		json               string
+		expectedStatusCode int
+		wantErr            bool
+	}{
+		{
+			name:               "Success",
+			id:                 "5f4bd6a4c6e9ab2e4f8b4567",
+			json:               `{"tmdb_id": 949, "language": "en"}`,
+			expectedStatusCode: 200,
+			wantErr:            false,
+		},
+		{
+			name:               "Non-existent item",
+			id:                 "5f4bd6a4c6e9ab2e4f8b4560",
+			json:               `{"tmdb_id": 949, "language": "en"}`,
+			expectedStatusCode: 404,
+			wantErr:            true,
+		},
+		{
+			name:               "Malformed id",
+			id:                 "heat",
+			json:               `{"tmdb_id": 949, "language": "en"}`,
+			expectedStatusCode: 400,
+			wantErr:            true,
+		},
+		{
+			name:               "Binding error; empty request body",
+			json:               ``,
+			expectedStatusCode: 400,
+			wantErr:            true,
+		},
+		{
+			name:               "Missing TMDb ID",
+			json:               `{"language": "en"}`,
+			expectedStatusCode: 400,
+			wantErr:            true,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.json))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			c := suite.router.NewContext(req, rec)
+			c.SetPath("/api/v1/movies/unmatched/:id/assign")
+			c.SetParamNames("id")
+			c.SetParamValues(tt.id)
+			err := h.AssignUnmatchedItem(c)
+			if tt.wantErr {
+				suite.NotNil(err)
+			} else {
+				suite.Nil(err)
+			}
+			suite.Equal(tt.expectedStatusCode, rec.Code)
+		})
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"log"
 	"net/http"
 
+	authmw "github.com/0x113/x-media/auth/pkg/middleware"
 	"github.com/0x113/x-media/movie-svc/common"
 	"github.com/0x113/x-media/movie-svc/data"
 	"github.com/0x113/x-media/movie-svc/databases"
@@ -51,9 +52,23 @@ func main() {
 		log.Fatalf("Unable to initialize server: %v", err)
 	}
 
+	httpClient := &http.Client{}
+	validator, err := authmw.NewValidator(httpClient, authmw.Config{
+		Mode:        common.Config.AuthMode,
+		JWKSURL:     common.Config.AuthJWKSURL,
+		ValidateURL: common.Config.AuthValidateURL,
+		CacheTTL:    common.Config.AuthCacheTTL,
+		Issuer:      common.Config.AuthIssuer,
+		Audience:    common.Config.AuthAudience,
+	})
+	if err != nil {
+		log.Fatalf("Unable to initialize token validator: %v", err)
+	}
+
 	movieRepository := data.NewMongoMovieRepository()
-	movieService := service.NewMovieService(movieRepository, &http.Client{})
-	handler.NewMovieHandler(srv.router, movieService)
+	unmatchedRepository := data.NewMongoUnmatchedRepository()
+	movieService := service.NewMovieService(movieRepository, unmatchedRepository, httpClient)
+	handler.NewMovieHandler(srv.router, movieService, validator)
 
 	srv.router.Start(":" + common.Config.Port)
 }
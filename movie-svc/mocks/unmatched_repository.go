@@ -0,0 +1,75 @@
+package mocks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/0x113/x-media/movie-svc/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockUnmatchedRepository represents in-memory unmatched items repository
+type MockUnmatchedRepository struct {
+	items map[primitive.ObjectID]*models.UnmatchedItem
+}
+
+// NewMockUnmatchedRepository creates new mocked unmatched items repository
+func NewMockUnmatchedRepository() *MockUnmatchedRepository {
+	var items = map[primitive.ObjectID]*models.UnmatchedItem{}
+	id, err := primitive.ObjectIDFromHex("5f4bd6a4c6e9ab2e4f8b4567")
+	if err != nil {
+		panic(err)
+	}
+	items[id] = &models.UnmatchedItem{
+		ID:        id,
+		FilePath:  "/home/y0x/Videos/Heat.1995.mp4",
+		Query:     "Heat",
+		Reason:    "Unable to find movie with title: Heat",
+		CreatedAt: time.Date(2020, 8, 30, 15, 4, 5, 0, time.UTC),
+		UpdatedAt: time.Date(2020, 8, 30, 15, 4, 5, 0, time.UTC),
+	}
+	return &MockUnmatchedRepository{items}
+}
+
+// Save unmatched item in memory
+func (m *MockUnmatchedRepository) Save(item *models.UnmatchedItem) error {
+	m.items[item.ID] = item
+	return nil
+}
+
+// GetByID returns unmatched item from the mocked database by its id
+func (m *MockUnmatchedRepository) GetByID(id primitive.ObjectID) (*models.UnmatchedItem, error) {
+	if item, ok := m.items[id]; ok {
+		return item, nil
+	}
+	return nil, fmt.Errorf("Unable to find unmatched item with id: %s", id)
+}
+
+// GetByFilePath returns unmatched item from the mocked database by its file path
+func (m *MockUnmatchedRepository) GetByFilePath(filePath string) (*models.UnmatchedItem, error) {
+	for _, item := range m.items {
+		if item.FilePath == filePath {
+			return item, nil
+		}
+	}
+	return nil, fmt.Errorf("Unable to find unmatched item with file path: %s", filePath)
+}
+
+// GetAll returns all unmatched items from the mocked database
+func (m *MockUnmatchedRepository) GetAll() ([]*models.UnmatchedItem, error) {
+	var items []*models.UnmatchedItem
+	for _, item := range m.items {
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Delete unmatched item from memory
+func (m *MockUnmatchedRepository) Delete(id primitive.ObjectID) error {
+	if _, ok := m.items[id]; !ok {
+		return fmt.Errorf("Unable to find unmatched item with id: %s", id)
+	}
+	delete(m.items, id)
+	return nil
+}
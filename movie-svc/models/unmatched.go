@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UnmatchedItem defines a file which couldn't be matched with the TMDb API
+// or couldn't be saved to the database. It waits in the review queue until
+// it's retried, ignored or assigned manually.
+type UnmatchedItem struct {
+	ID         primitive.ObjectID    `bson:"_id" json:"id" example:"5f4bd6a4c6e9ab2e4f8b4567"`
+	FilePath   string                `bson:"file_path" json:"file_path" example:"/home/0x113/Movies/Heat.1995.mp4"`
+	Query      string                `bson:"query" json:"query" example:"Heat"`
+	Reason     string                `bson:"reason" json:"reason" example:"Unable to find movie with title: Heat"`
+	Candidates []*UnmatchedCandidate `bson:"candidates" json:"candidates"`
+	Ignored    bool                  `bson:"ignored" json:"ignored" example:"false"`
+	CreatedAt  time.Time             `bson:"created_at" json:"created_at" example:"2020-08-30T15:04:05Z"`
+	UpdatedAt  time.Time             `bson:"updated_at" json:"updated_at" example:"2020-08-30T15:04:05Z"`
+}
+
+// UnmatchedCandidate defines one of the TMDb search results for the unmatched item
+type UnmatchedCandidate struct {
+	TMDbID        int    `bson:"tmdb_id" json:"tmdb_id" example:"949"`
+	Title         string `bson:"title" json:"title" example:"Heat"`
+	OriginalTitle string `bson:"original_title" json:"original_title" example:"Heat"`
+	ReleaseDate   string `bson:"release_date" json:"release_date" example:"1995-12-15"`
+	PosterPath    string `bson:"poster_path" json:"poster_path" example:"/rrBuGu0Pjq7Y2BWSI6teGfZzviY.jpg"`
+}
@@ -1,8 +1,10 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/0x113/x-media/movie-svc/common"
 	"github.com/0x113/x-media/movie-svc/data"
@@ -16,6 +18,12 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ErrInvalidUnmatchedID is returned when the unmatched item id isn't a valid ObjectID
+var ErrInvalidUnmatchedID = errors.New("Invalid unmatched item id")
+
+// ErrUnmatchedNotFound is returned when the unmatched item doesn't exist
+var ErrUnmatchedNotFound = errors.New("Unmatched item not found")
+
 // MovieService defines the movie service
 type MovieService interface {
 	UpdateMovieByID(id int, lang, filePath string, mutex *sync.Mutex) (*models.Movie, error)
@@ -23,23 +31,32 @@ type MovieService interface {
 	GetAllMovies() ([]*models.Movie, error)
 	GetLocalTMDbID(filename string) (int, error)
 	GetMovieByID(id string) (*models.Movie, error)
+	GetUnmatchedItems() ([]*models.UnmatchedItem, error)
+	RetryUnmatchedItem(id, lang string) (*models.Movie, error)
+	IgnoreUnmatchedItem(id string) (*models.UnmatchedItem, error)
+	AssignUnmatchedItem(id string, tmdbID int, lang string) (*models.Movie, error)
 }
 
+// maxCandidates defines how many TMDb search results are stored
+// with the unmatched item
+const maxCandidates = 5
+
 type movieService struct {
-	repo       data.MovieRepository
-	httpClient httpclient.HTTPClient
+	repo          data.MovieRepository
+	unmatchedRepo data.UnmatchedRepository
+	httpClient    httpclient.HTTPClient
 }
 
 // NewMovieService returns new insance of the movie service
-func NewMovieService(repo data.MovieRepository, httpClient httpclient.HTTPClient) MovieService {
-	return &movieService{repo, httpClient}
+func NewMovieService(repo data.MovieRepository, unmatchedRepo data.UnmatchedRepository, httpClient httpclient.HTTPClient) MovieService {
+	return &movieService{repo, unmatchedRepo, httpClient}
 }
 
 // UpdateMovieByID calls the TMDb API to get data about movie
 // based on its ID and saves it to the database if doesn't exist
 // or updates if exists.
 func (s *movieService) UpdateMovieByID(id int, lang, filePath string, mutex *sync.Mutex) (*models.Movie, error) {
-	tmdbAPIClient := &tmdb.TMDbAPIClient{s.httpClient}
+	tmdbAPIClient := &tmdb.TMDbAPIClient{Client: s.httpClient}
 	tmdbMovie, err := tmdbAPIClient.GetTMDbMovieInfo(id, lang)
 	if err != nil {
 		log.Errorf("Unable to get the data from the TMDb API [movie_id: %d, lang: %s]: %v", id, lang, err)
@@ -69,6 +86,7 @@ func (s *movieService) UpdateMovieByID(id int, lang, filePath string, mutex *syn
 	}
 
 	mutex.Lock()
+	defer mutex.Unlock()
 	// check if movie exists in the database NOTE: maybe getting by TMDb's ID is better ? ¯\_(ツ)_/¯
 	// get movie based on it's title
 	dbMovie, err := s.repo.GetByOriginalTitle(movie.OriginalTitle) // NOTE: it's 11:29 PM CET and I have no idea how to handle this error
@@ -88,20 +106,24 @@ func (s *movieService) UpdateMovieByID(id int, lang, filePath string, mutex *syn
 		log.Infof("Successfully updated movie [%s]", movie.Title)
 	}
 
-	mutex.Unlock()
 	return movie, nil
 }
 
 // UpdateAllMovies scans the given directories for video files like mp4, mkv etc.
 // Then it calls the TMDb API to get data about every single one and saves new movie
 // to the database if it doesn't exist or updates movie if there is already one.
+// Files which couldn't be matched are stored in the unmatched items queue,
+// ignored files are skipped.
 func (s *movieService) UpdateAllMovies(lang string) (map[string]string, map[string]string) {
 	errorsMap := make(map[string]string)
 	type moviePathID struct {
-		filepath string
-		id       int
+		filepath   string
+		query      string
+		id         int
+		candidates []*models.UnmatchedCandidate
 	}
 	var movieIDs []*moviePathID // contains list of moviePathID (filepath: tmdb_id)
+	ignoredFiles := s.getIgnoredFiles()
 
 	for _, dir := range common.Config.MovieDirectories {
 		// get files from the given directories
@@ -114,16 +136,17 @@ func (s *movieService) UpdateAllMovies(lang string) (map[string]string, map[stri
 		// send request to the TMDb API to get movie id
 		// FIXME: error handling like 401 from TMDb's API
 		for _, f := range files {
-			title, err := filenameparser.CreateTitle(f)
-			if err != nil {
-				errorsMap[f] = err.Error()
+			if ignoredFiles[f] {
+				log.Debugf("Skipping ignored file [%s]", f)
+				continue
 			}
-			id, err := s.GetLocalTMDbID(title)
+			query, results, err := s.searchFile(f)
 			if err != nil {
-				errorsMap[title] = err.Error()
+				errorsMap[f] = err.Error()
+				s.saveUnmatchedItem(f, query, err.Error(), results)
 				continue
 			}
-			movieIDs = append(movieIDs, &moviePathID{f, id})
+			movieIDs = append(movieIDs, &moviePathID{f, query, results[0].ID, toCandidates(results)})
 		}
 	}
 
@@ -136,15 +159,15 @@ func (s *movieService) UpdateAllMovies(lang string) (map[string]string, map[stri
 		go func(m *moviePathID) {
 			defer wg.Done()
 			movie, err := s.UpdateMovieByID(m.id, lang, m.filepath, &mutex)
+			mutex.Lock()
+			defer mutex.Unlock()
 			if err != nil {
-				mutex.Lock()
 				errorsMap[m.filepath] = err.Error()
-				mutex.Unlock()
+				s.saveUnmatchedCandidates(m.filepath, m.query, err.Error(), m.candidates)
 				return
 			}
-			mutex.Lock()
 			updatedMovies[movie.DirPath] = movie.Title
-			mutex.Unlock()
+			s.removeUnmatchedItem(m.filepath)
 		}(m)
 	}
 	wg.Wait()
@@ -165,7 +188,7 @@ func (s *movieService) GetAllMovies() ([]*models.Movie, error) {
 
 // GetLocalTMDbID calls the TMDb API to get movie ID based on its title.
 func (s *movieService) GetLocalTMDbID(title string) (int, error) {
-	tmdbAPIClient := &tmdb.TMDbAPIClient{s.httpClient}
+	tmdbAPIClient := &tmdb.TMDbAPIClient{Client: s.httpClient}
 	tmdbQMovie, err := tmdbAPIClient.GetTMDbQueryMovieInfo(title, "en") // NOTE: "lang" param is probably useless
 	if err != nil {
 		return 0, err
@@ -191,3 +214,194 @@ func (s *movieService) GetMovieByID(id string) (*models.Movie, error) {
 	log.Infof("Successfully found movie with id: %s", id)
 	return movie, nil
 }
+
+// GetUnmatchedItems returns all items from the unmatched items queue
+func (s *movieService) GetUnmatchedItems() ([]*models.UnmatchedItem, error) {
+	items, err := s.unmatchedRepo.GetAll()
+	if err != nil {
+		log.Errorf("Couldn't get unmatched items from the database: %v", err)
+		return nil, fmt.Errorf("Couldn't get unmatched items from the database")
+	}
+
+	log.Infoln("Successfully found all unmatched items")
+	return items, nil
+}
+
+// RetryUnmatchedItem searches the TMDb API for the unmatched file once again.
+// If the movie is found and saved, the item is removed from the queue.
+func (s *movieService) RetryUnmatchedItem(id, lang string) (*models.Movie, error) {
+	item, err := s.getUnmatchedItem(id)
+	if err != nil {
+		return nil, err
+	}
+
+	query, results, err := s.searchFile(item.FilePath)
+	if err != nil {
+		s.saveUnmatchedItem(item.FilePath, query, err.Error(), results)
+		return nil, err
+	}
+
+	var mutex sync.Mutex
+	movie, err := s.UpdateMovieByID(results[0].ID, lang, item.FilePath, &mutex)
+	if err != nil {
+		s.saveUnmatchedCandidates(item.FilePath, query, err.Error(), toCandidates(results))
+		return nil, err
+	}
+	s.removeUnmatchedItem(item.FilePath)
+
+	log.Infof("Successfully matched unmatched item [%s]", item.FilePath)
+	return movie, nil
+}
+
+// IgnoreUnmatchedItem marks the unmatched item as ignored, so it's skipped
+// by the next update
+func (s *movieService) IgnoreUnmatchedItem(id string) (*models.UnmatchedItem, error) {
+	item, err := s.getUnmatchedItem(id)
+	if err != nil {
+		return nil, err
+	}
+
+	item.Ignored = true
+	item.UpdatedAt = time.Now()
+	if err := s.unmatchedRepo.Save(item); err != nil {
+		log.Errorf("Couldn't ignore unmatched item [%s]: %v", id, err)
+		return nil, fmt.Errorf("Couldn't save the unmatched item to the database")
+	}
+
+	log.Infof("Successfully ignored unmatched item [%s]", item.FilePath)
+	return item, nil
+}
+
+// AssignUnmatchedItem gets the movie with provided TMDb ID and saves it for
+// the unmatched file. The item is removed from the queue on success.
+func (s *movieService) AssignUnmatchedItem(id string, tmdbID int, lang string) (*models.Movie, error) {
+	item, err := s.getUnmatchedItem(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var mutex sync.Mutex
+	movie, err := s.UpdateMovieByID(tmdbID, lang, item.FilePath, &mutex)
+	if err != nil {
+		return nil, err
+	}
+	s.removeUnmatchedItem(item.FilePath)
+
+	log.Infof("Successfully assigned movie [%s] to the unmatched item [%s]", movie.Title, item.FilePath)
+	return movie, nil
+}
+
+// searchFile creates title from the file name and calls the TMDb API to search
+// for it. It returns the title, search results and an error if there are no results.
+func (s *movieService) searchFile(filePath string) (string, []*models.TMDbQueryMovie, error) {
+	title, err := filenameparser.CreateTitle(filePath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tmdbAPIClient := &tmdb.TMDbAPIClient{Client: s.httpClient}
+	results, err := tmdbAPIClient.SearchTMDbMovies(title, "en")
+	if err != nil {
+		return title, nil, err
+	}
+	if len(results) == 0 {
+		return title, nil, fmt.Errorf("Unable to find movie with title: %s", title)
+	}
+
+	return title, results, nil
+}
+
+// getUnmatchedItem converts provided id to the ObjectID and returns
+// the unmatched item with this id
+func (s *movieService) getUnmatchedItem(id string) (*models.UnmatchedItem, error) {
+	itemID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		log.Errorf("Unable to convert string id to ObjectID: %s", id)
+		return nil, fmt.Errorf("%w: %s", ErrInvalidUnmatchedID, id)
+	}
+
+	item, err := s.unmatchedRepo.GetByID(itemID)
+	if err != nil {
+		log.Errorf("Unable to get unmatched item by id: %s; err: %v", id, err)
+		return nil, fmt.Errorf("%w: %s", ErrUnmatchedNotFound, id)
+	}
+
+	return item, nil
+}
+
+// getIgnoredFiles returns set of file paths which are marked as ignored
+func (s *movieService) getIgnoredFiles() map[string]bool {
+	ignoredFiles := make(map[string]bool)
+	items, err := s.unmatchedRepo.GetAll()
+	if err != nil {
+		log.Errorf("Couldn't get unmatched items from the database: %v", err)
+		return ignoredFiles
+	}
+
+	for _, item := range items {
+		if item.Ignored {
+			ignoredFiles[item.FilePath] = true
+		}
+	}
+	return ignoredFiles
+}
+
+// saveUnmatchedItem stores the file in the unmatched items queue along
+// with the TMDb search results
+func (s *movieService) saveUnmatchedItem(filePath, query, reason string, results []*models.TMDbQueryMovie) {
+	s.saveUnmatchedCandidates(filePath, query, reason, toCandidates(results))
+}
+
+// saveUnmatchedCandidates stores the file in the unmatched items queue. If the file
+// is already there, it updates the reason and candidates and keeps the rest.
+func (s *movieService) saveUnmatchedCandidates(filePath, query, reason string, candidates []*models.UnmatchedCandidate) {
+	now := time.Now()
+	item, err := s.unmatchedRepo.GetByFilePath(filePath)
+	if item == nil || err != nil {
+		item = &models.UnmatchedItem{
+			ID:        primitive.NewObjectID(),
+			FilePath:  filePath,
+			CreatedAt: now,
+		}
+	}
+	item.Query = query
+	item.Reason = reason
+	item.Candidates = candidates
+	item.UpdatedAt = now
+
+	if err := s.unmatchedRepo.Save(item); err != nil {
+		log.Errorf("Couldn't save unmatched item [%s]: %v", filePath, err)
+		return
+	}
+	log.Infof("Saved unmatched item [%s]: %s", filePath, reason)
+}
+
+// removeUnmatchedItem removes the file from the unmatched items queue if it's there
+func (s *movieService) removeUnmatchedItem(filePath string) {
+	item, err := s.unmatchedRepo.GetByFilePath(filePath)
+	if item == nil || err != nil {
+		return
+	}
+
+	if err := s.unmatchedRepo.Delete(item.ID); err != nil {
+		log.Errorf("Couldn't remove unmatched item [%s]: %v", filePath, err)
+	}
+}
+
+// toCandidates converts the TMDb search results to the unmatched item candidates
+func toCandidates(results []*models.TMDbQueryMovie) []*models.UnmatchedCandidate {
+	var candidates []*models.UnmatchedCandidate
+	for i, r := range results {
+		if i == maxCandidates {
+			break
+		}
+		candidates = append(candidates, &models.UnmatchedCandidate{
+			TMDbID:        r.ID,
+			Title:         r.Title,
+			OriginalTitle: r.OriginalTitle,
+			ReleaseDate:   r.ReleaseDate,
+			PosterPath:    r.PosterPath,
+		})
+	}
+	return candidates
+}
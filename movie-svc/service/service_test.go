@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"sync"
 	"testing"
 
@@ -21,10 +22,11 @@ import (
 // MovieServiceTestSuite represents test suite for the movie service
 type MovieServiceTestSuite struct {
 	suite.Suite
-	httpClient   httpclient.HTTPClient
-	movieID      primitive.ObjectID
-	movieRepo    *mocks.MockMovieRepository
-	movieService service.MovieService
+	httpClient    httpclient.HTTPClient
+	movieID       primitive.ObjectID
+	movieRepo     *mocks.MockMovieRepository
+	unmatchedRepo *mocks.MockUnmatchedRepository
+	movieService  service.MovieService
 }
 
 // SetupTest initiates mocked database and disables the logrus output
@@ -36,6 +38,7 @@ func (suite *MovieServiceTestSuite) SetupTest() {
 		TMDbAPIKey: "fake-key",
 	}
 	suite.movieRepo = mocks.NewMockMovieRepository()
+	suite.unmatchedRepo = mocks.NewMockUnmatchedRepository()
 	logrus.SetOutput(ioutil.Discard)
 }
 
@@ -241,8 +244,8 @@ func (suite *MovieServiceTestSuite) TestUpdateMovieByID() {
 
 	var mutex sync.Mutex
 	for _, tt := range testCases {
-		suite.httpClient = &mocks.MockClient{tt.doFunc}
-		suite.movieService = service.NewMovieService(suite.movieRepo, suite.httpClient)
+		suite.httpClient = &mocks.MockClient{DoFunc: tt.doFunc}
+		suite.movieService = service.NewMovieService(suite.movieRepo, suite.unmatchedRepo, suite.httpClient)
 		suite.Run(tt.name, func() {
 			_, err := suite.movieService.UpdateMovieByID(tt.id, tt.lang, tt.filePath, &mutex) // NOTE: handle movie return
 			if tt.wantErr {
@@ -317,8 +320,8 @@ func (suite *MovieServiceTestSuite) TestGetLocalTMDbID() {
 	}
 
 	for _, tt := range testCases {
-		suite.httpClient = &mocks.MockClient{tt.doFunc}
-		suite.movieService = service.NewMovieService(suite.movieRepo, suite.httpClient)
+		suite.httpClient = &mocks.MockClient{DoFunc: tt.doFunc}
+		suite.movieService = service.NewMovieService(suite.movieRepo, suite.unmatchedRepo, suite.httpClient)
 		suite.Run(tt.name, func() {
 			id, err := suite.movieService.GetLocalTMDbID(tt.filename)
 			if tt.wantErr {
@@ -398,8 +401,8 @@ func (suite *MovieServiceTestSuite) TestUpdateAllMovies() {
 	}
 
 	for _, tt := range testCases {
-		suite.httpClient = &mocks.MockClient{tt.doFunc}
-		suite.movieService = service.NewMovieService(suite.movieRepo, suite.httpClient)
+		suite.httpClient = &mocks.MockClient{DoFunc: tt.doFunc}
+		suite.movieService = service.NewMovieService(suite.movieRepo, suite.unmatchedRepo, suite.httpClient)
 		suite.Run(tt.name, func() {
 			updatedMovies, errors := suite.movieService.UpdateAllMovies("en")
 			suite.NotNil(errors)
@@ -410,7 +413,7 @@ func (suite *MovieServiceTestSuite) TestUpdateAllMovies() {
 
 func (suite *MovieServiceTestSuite) TestGetAll() {
 	suite.httpClient = &mocks.MockClient{}
-	suite.movieService = service.NewMovieService(suite.movieRepo, suite.httpClient)
+	suite.movieService = service.NewMovieService(suite.movieRepo, suite.unmatchedRepo, suite.httpClient)
 
 	expectedMovies := []*models.Movie{
 		&models.Movie{
@@ -498,3 +501,202 @@ func (suite *MovieServiceTestSuite) TestGetMovieByID() {
 		})
 	}
 }
+
+func (suite *MovieServiceTestSuite) TestGetUnmatchedItems() {
+	suite.httpClient = &mocks.MockClient{}
+	suite.movieService = service.NewMovieService(suite.movieRepo, suite.unmatchedRepo, suite.httpClient)
+
+	items, err := suite.movieService.GetUnmatchedItems()
+	suite.Nil(err)
+	suite.Len(items, 1)
+	suite.Equal("/home/y0x/Videos/Heat.1995.mp4", items[0].FilePath)
+}
+
+func (suite *MovieServiceTestSuite) TestRetryUnmatchedItem() {
+	testCases := []struct {
+		name          string
+		id            string
+		doFunc        func(req *http.Request) (*http.Response, error)
+		wantErr       bool
+		wantUnmatched bool
+	}{
+		{
+			name: "Success",
+			id:   "5f4bd6a4c6e9ab2e4f8b4567",
+			doFunc: func(req *http.Request) (*http.Response, error) {
+				json := `{"id": 949, "title": "Heat", "original_title": "Heat", "original_language": "en"}`
+				if strings.Contains(req.URL.Path, "/search/movie") {
+					json = `{"page": 1, "results": [{"id": 949, "title": "Heat", "original_title": "Heat"}]}`
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(json))),
+				}, nil
+			},
+			wantErr:       false,
+			wantUnmatched: false,
+		},
+		{
+			name: "Still no results",
+			id:   "5f4bd6a4c6e9ab2e4f8b4567",
+			doFunc: func(req *http.Request) (*http.Response, error) {
+				json := `{"page": 1, "results": []}`
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(json))),
+				}, nil
+			},
+			wantErr:       true,
+			wantUnmatched: true,
+		},
+		{
+			name:          "Non-existent item",
+			id:            "5f4bd6a4c6e9ab2e4f8b4560",
+			wantErr:       true,
+			wantUnmatched: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.SetupTest()
+		suite.httpClient = &mocks.MockClient{DoFunc: tt.doFunc}
+		suite.movieService = service.NewMovieService(suite.movieRepo, suite.unmatchedRepo, suite.httpClient)
+		suite.Run(tt.name, func() {
+			movie, err := suite.movieService.RetryUnmatchedItem(tt.id, "en")
+			if tt.wantErr {
+				suite.NotNil(err)
+				suite.Nil(movie)
+			} else {
+				suite.Nil(err)
+				suite.Equal("Heat", movie.Title)
+			}
+
+			_, err = suite.unmatchedRepo.GetByFilePath("/home/y0x/Videos/Heat.1995.mp4")
+			suite.Equal(tt.wantUnmatched, err == nil)
+		})
+	}
+}
+
+func (suite *MovieServiceTestSuite) TestIgnoreUnmatchedItem() {
+	suite.httpClient = &mocks.MockClient{}
+	suite.movieService = service.NewMovieService(suite.movieRepo, suite.unmatchedRepo, suite.httpClient)
+
+	testCases := []struct {
+		name    string
+		id      string
+		wantErr bool
+	}{
+		{
+			name:    "Success",
+			id:      "5f4bd6a4c6e9ab2e4f8b4567",
+			wantErr: false,
+		},
+		{
+			name:    "Incorrect object id",
+			id:      "123",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			item, err := suite.movieService.IgnoreUnmatchedItem(tt.id)
+			if tt.wantErr {
+				suite.NotNil(err)
+				suite.Nil(item)
+			} else {
+				suite.Nil(err)
+				suite.True(item.Ignored)
+			}
+		})
+	}
+}
+
+func (suite *MovieServiceTestSuite) TestUpdateAllMoviesUnmatched() {
+	tmpdir, err := ioutil.TempDir("", "update-all-unmatched-*")
+	suite.Nil(err)
+	unknownFile, err := ioutil.TempFile(tmpdir, "Unknown.Movie.2020-*.mp4")
+	suite.Nil(err)
+	ignoredFile, err := ioutil.TempFile(tmpdir, "Ignored.Movie.2020-*.mkv")
+	suite.Nil(err)
+
+	common.Config = &common.Configuration{
+		MovieDirectories: []string{tmpdir},
+	}
+	suite.Nil(suite.unmatchedRepo.Save(&models.UnmatchedItem{
+		ID:       primitive.NewObjectID(),
+		FilePath: ignoredFile.Name(),
+		Ignored:  true,
+	}))
+
+	suite.httpClient = &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			json := `{"page": 1, "results": []}`
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(json))),
+			}, nil
+		},
+	}
+	suite.movieService = service.NewMovieService(suite.movieRepo, suite.unmatchedRepo, suite.httpClient)
+
+	updatedMovies, errorsMap := suite.movieService.UpdateAllMovies("en")
+	suite.Empty(updatedMovies)
+	suite.Contains(errorsMap, unknownFile.Name())
+	suite.NotContains(errorsMap, ignoredFile.Name())
+
+	item, err := suite.unmatchedRepo.GetByFilePath(unknownFile.Name())
+	suite.Nil(err)
+	suite.False(item.Ignored)
+	suite.Equal(errorsMap[unknownFile.Name()], item.Reason)
+}
+
+func (suite *MovieServiceTestSuite) TestAssignUnmatchedItem() {
+	testCases := []struct {
+		name    string
+		id      string
+		doFunc  func(req *http.Request) (*http.Response, error)
+		wantErr bool
+	}{
+		{
+			name: "Success",
+			id:   "5f4bd6a4c6e9ab2e4f8b4567",
+			doFunc: func(req *http.Request) (*http.Response, error) {
+				json := `{"id": 949, "title": "Heat", "original_title": "Heat", "original_language": "en"}`
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(json))),
+				}, nil
+			},
+			wantErr: false,
+		},
+		{
+			name: "Failure - unable to get data from the TMDb API",
+			id:   "5f4bd6a4c6e9ab2e4f8b4567",
+			doFunc: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusNotFound,
+					Body:       ioutil.NopCloser(nil),
+				}, nil
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.SetupTest()
+		suite.httpClient = &mocks.MockClient{DoFunc: tt.doFunc}
+		suite.movieService = service.NewMovieService(suite.movieRepo, suite.unmatchedRepo, suite.httpClient)
+		suite.Run(tt.name, func() {
+			movie, err := suite.movieService.AssignUnmatchedItem(tt.id, 949, "en")
+			if tt.wantErr {
+				suite.NotNil(err)
+				suite.Nil(movie)
+			} else {
+				suite.Nil(err)
+				suite.Equal(949, movie.TMDbID)
+				suite.Equal("/home/y0x/Videos/Heat.1995.mp4", movie.DirPath)
+			}
+		})
+	}
+}
@@ -24,6 +24,24 @@ type Configuration struct {
 	DbPassword string `json:"db_password"`
 
 	TVShowDirectories []string `json:"tv_show_directories"`
+
+	TMDbAPIKey   string `json:"tmdb_api_key"`
+	TMDbLanguage string `json:"tmdb_language"`
+
+	// MetadataProviders defines the order in which the metadata providers are tried
+	MetadataProviders []string `json:"metadata_providers"`
+	// LibraryProviders overrides the providers order for the tv show directories
+	LibraryProviders map[string][]string `json:"library_providers"`
+
+	// AuthMode defines how the bearer tokens are validated: "local" or "remote"
+	AuthMode        string `json:"auth_mode"`
+	AuthJWKSURL     string `json:"auth_jwks_url"`
+	AuthValidateURL string `json:"auth_validate_url"`
+	// AuthCacheTTL defines for how many seconds remotely validated tokens are cached
+	AuthCacheTTL int `json:"auth_cache_ttl"`
+	// AuthIssuer and AuthAudience must match the iss and aud claims of the access tokens, empty values aren't checked
+	AuthIssuer   string `json:"auth_issuer"`
+	AuthAudience string `json:"auth_audience"`
 }
 
 // Config shares the global configuration
@@ -0,0 +1,120 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/0x113/x-media/tvshow/databases"
+	"github.com/0x113/x-media/tvshow/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	episodeCollectionName = "episodes"
+)
+
+// episodeRepository manages the episodes CRUD
+type episodeRepository struct{}
+
+// NewMongoEpisodeRepository returns new instance of the episode repository
+func NewMongoEpisodeRepository() EpisodeRepository {
+	return &episodeRepository{}
+}
+
+// GetByID returns episode from the database based on its id
+func (r *episodeRepository) GetByID(id primitive.ObjectID) (*models.Episode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessionCopy := databases.Database.Session
+	defer sessionCopy.EndSession(ctx)
+
+	collection := sessionCopy.Client().Database(databases.Database.DbName).Collection(episodeCollectionName)
+
+	var episode models.Episode
+	if err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&episode); err != nil {
+		return nil, err
+	}
+
+	return &episode, nil
+}
+
+// GetByTVShowID returns all episodes of the tv show sorted by season and number
+func (r *episodeRepository) GetByTVShowID(tvShowID primitive.ObjectID) ([]*models.Episode, error) {
+	return r.find(bson.M{"tv_show_id": tvShowID})
+}
+
+// GetBySeason returns episodes of the tv show season sorted by number
+func (r *episodeRepository) GetBySeason(tvShowID primitive.ObjectID, season int) ([]*models.Episode, error) {
+	return r.find(bson.M{"tv_show_id": tvShowID, "season": season})
+}
+
+func (r *episodeRepository) find(query bson.M) ([]*models.Episode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sessionCopy := databases.Database.Session
+	defer sessionCopy.EndSession(ctx)
+
+	collection := sessionCopy.Client().Database(databases.Database.DbName).Collection(episodeCollectionName)
+
+	opts := options.Find().SetSort(bson.D{{Key: "season", Value: 1}, {Key: "number", Value: 1}})
+	cursor, err := collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	episodes := []*models.Episode{}
+	if err := cursor.All(ctx, &episodes); err != nil {
+		return nil, err
+	}
+
+	return episodes, nil
+}
+
+// ReplaceByTVShowID removes all episodes of the tv show and inserts the new ones
+func (r *episodeRepository) ReplaceByTVShowID(tvShowID primitive.ObjectID, episodes []*models.Episode) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sessionCopy := databases.Database.Session
+	defer sessionCopy.EndSession(ctx)
+
+	collection := sessionCopy.Client().Database(databases.Database.DbName).Collection(episodeCollectionName)
+
+	if _, err := collection.DeleteMany(ctx, bson.M{"tv_show_id": tvShowID}); err != nil {
+		return err
+	}
+	if len(episodes) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, len(episodes))
+	for i, episode := range episodes {
+		documents[i] = episode
+	}
+	if _, err := collection.InsertMany(ctx, documents); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateEpisodeIndexes creates indexes used by the episode lookups
+func CreateEpisodeIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sessionCopy := databases.Database.Session
+	defer sessionCopy.EndSession(ctx)
+
+	collection := sessionCopy.Client().Database(databases.Database.DbName).Collection(episodeCollectionName)
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "tv_show_id", Value: 1}, {Key: "season", Value: 1}, {Key: "number", Value: 1}},
+	})
+	return err
+}
@@ -2,11 +2,14 @@ package data
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/0x113/x-media/tvshow/databases"
 	"github.com/0x113/x-media/tvshow/models"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const (
@@ -42,7 +45,8 @@ func (r *tvShowRepository) GetByName(name string) (*models.TVShow, error) {
 	sessionCopy := databases.Database.Session
 	defer sessionCopy.EndSession(context.TODO())
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 	collection := sessionCopy.Client().Database(databases.Database.DbName).Collection(collectionName)
 
 	var tvShow models.TVShow
@@ -53,12 +57,30 @@ func (r *tvShowRepository) GetByName(name string) (*models.TVShow, error) {
 	return &tvShow, nil
 }
 
+// GetByDirPath returns TVShow matched with the directory if exists and an error
+func (r *tvShowRepository) GetByDirPath(dirPath string) (*models.TVShow, error) {
+	sessionCopy := databases.Database.Session
+	defer sessionCopy.EndSession(context.TODO())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	collection := sessionCopy.Client().Database(databases.Database.DbName).Collection(collectionName)
+
+	var tvShow models.TVShow
+	if err := collection.FindOne(ctx, bson.M{"dir_path": dirPath}).Decode(&tvShow); err != nil {
+		return nil, err
+	}
+
+	return &tvShow, nil
+}
+
 // Update existing tv show
 func (r *tvShowRepository) Update(tvShow *models.TVShow) error {
 	sessionCopy := databases.Database.Session
 	defer sessionCopy.EndSession(context.TODO())
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 	collection := sessionCopy.Client().Database(databases.Database.DbName).Collection(collectionName)
 
 	_, err := collection.UpdateOne(
@@ -74,21 +96,79 @@ func (r *tvShowRepository) Update(tvShow *models.TVShow) error {
 	return nil
 }
 
-// GetAll returns all of the tv shows from the database
-func (r *tvShowRepository) GetAll() ([]*models.TVShow, error) {
+// Find returns page of the tv shows matching the filter and the total
+// number of the matching tv shows
+func (r *tvShowRepository) Find(filter *models.TVShowFilter) ([]*models.TVShow, int64, error) {
 	sessionCopy := databases.Database.Session
 	defer sessionCopy.EndSession(context.TODO())
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 	collection := sessionCopy.Client().Database(databases.Database.DbName).Collection(collectionName)
 
-	cursor, err := collection.Find(ctx, bson.M{})
+	query := bson.M{}
+	if filter.Genre != "" {
+		query["genres"] = filter.Genre
+	}
+	if filter.Language != "" {
+		query["language"] = filter.Language
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.Network != "" {
+		query["network"] = filter.Network
+	}
+	if filter.Year != 0 {
+		// premiered is stored as YYYY-MM-DD string, so it can be compared lexically
+		query["premiered"] = bson.M{
+			"$gte": fmt.Sprintf("%04d-01-01", filter.Year),
+			"$lt":  fmt.Sprintf("%04d-01-01", filter.Year+1),
+		}
+	}
+
+	total, err := collection.CountDocuments(ctx, query)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	sortOrder := 1
+	if filter.SortDesc {
+		sortOrder = -1
+	}
+	// cast and seasons are returned only with the show details
+	opts := options.Find().
+		SetProjection(bson.M{"cast": 0, "seasons": 0}).
+		SetSort(bson.D{{Key: filter.SortBy, Value: sortOrder}, {Key: "_id", Value: 1}}).
+		SetSkip(int64((filter.Page - 1) * filter.PerPage)).
+		SetLimit(int64(filter.PerPage))
+
+	cursor, err := collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
 	}
-	var tvShows []*models.TVShow
+	tvShows := []*models.TVShow{}
 	if err := cursor.All(ctx, &tvShows); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return tvShows, nil
+	return tvShows, total, nil
+}
+
+// CreateTVShowIndexes creates indexes used by the tv show listing
+func CreateTVShowIndexes() error {
+	sessionCopy := databases.Database.Session
+	defer sessionCopy.EndSession(context.TODO())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	collection := sessionCopy.Client().Database(databases.Database.DbName).Collection(collectionName)
+
+	var indexes []mongo.IndexModel
+	for _, field := range []string{"name", "genres", "language", "status", "network", "premiered", "rating", "runtime", "dir_path"} {
+		indexes = append(indexes, mongo.IndexModel{Keys: bson.D{{Key: field, Value: 1}}})
+	}
+	if _, err := collection.Indexes().CreateMany(ctx, indexes); err != nil {
+		return err
+	}
+	return nil
 }
@@ -2,12 +2,32 @@ package data
 
 import (
 	"github.com/0x113/x-media/tvshow/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // TVShowRepository contains all methods for operation on TVShow model
 type TVShowRepository interface {
 	Save(tvShow *models.TVShow) error
 	GetByName(name string) (*models.TVShow, error)
+	GetByDirPath(dirPath string) (*models.TVShow, error)
 	Update(tvShow *models.TVShow) error
-	GetAll() ([]*models.TVShow, error)
+	Find(filter *models.TVShowFilter) ([]*models.TVShow, int64, error)
+}
+
+// UnmatchedRepository contains all methods for operation on UnmatchedItem model
+type UnmatchedRepository interface {
+	Save(item *models.UnmatchedItem) error
+	GetByID(id primitive.ObjectID) (*models.UnmatchedItem, error)
+	GetByDirPath(dirPath string) (*models.UnmatchedItem, error)
+	GetAll() ([]*models.UnmatchedItem, error)
+	Delete(id primitive.ObjectID) error
+}
+
+// EpisodeRepository contains all methods for operation on Episode model
+type EpisodeRepository interface {
+	GetByID(id primitive.ObjectID) (*models.Episode, error)
+	GetByTVShowID(tvShowID primitive.ObjectID) ([]*models.Episode, error)
+	GetBySeason(tvShowID primitive.ObjectID, season int) ([]*models.Episode, error)
+	ReplaceByTVShowID(tvShowID primitive.ObjectID, episodes []*models.Episode) error
 }
@@ -25,7 +25,8 @@ func (db *MongoDB) Init() error {
 	// mongodb connection options
 	clientOptions := options.Client().ApplyURI(fmt.Sprintf("mongodb://%s", common.Config.DbAddr))
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
 	// connect to MongoDB
 	client, err := mongo.Connect(ctx, clientOptions)
@@ -28,9 +28,140 @@ var doc = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/": {
+            "get": {
+                "description": "Returns page of the tv shows from the database matching the filter",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get tv shows",
+                "operationId": "get-tv-shows",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "genre of the tv show",
+                        "name": "genre",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "language of the tv show",
+                        "name": "language",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "status of the tv show, e.g. Running or Ended",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "network or web channel of the tv show",
+                        "name": "network",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "year in which the tv show premiered",
+                        "name": "year",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "sort field (name, rating, premiered, runtime), prefix with - for descending order",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page number, starts from 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "number of tv shows per page, max 100",
+                        "name": "per_page",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.tvShowListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/episodes/{id}/stream": {
+            "get": {
+                "description": "Streams the episode video file, supports HTTP Range requests",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "summary": "Stream episode",
+                "operationId": "stream-episode",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "id of the episode",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "byte range, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Partial Content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
         "/get": {
             "post": {
-                "description": "Returns tv shows",
+                "description": "Returns tv show along with its cast and seasons",
                 "consumes": [
                     "application/json"
                 ],
@@ -72,19 +203,216 @@ var doc = `{
                 }
             }
         },
-        "/get/all": {
+        "/provider": {
+            "post": {
+                "description": "Gets the existing tv show from the provided metadata provider and saves it, so the next updates use this provider first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Set tv show provider",
+                "operationId": "set-tv-show-provider",
+                "parameters": [
+                    {
+                        "description": "name of the tv show, metadata provider and optional id of the tv show",
+                        "name": "name",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.tvShowProviderPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TVShow"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/unmatched": {
             "get": {
-                "description": "Returns all the tv shows from the database",
+                "description": "Returns all directories which couldn't be matched with the metadata providers along with the reason and candidates",
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get all tv shows",
-                "operationId": "get-all-tv-shows",
+                "summary": "Get unmatched items",
+                "operationId": "get-unmatched-items",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.tvShowListResponse"
+                            "$ref": "#/definitions/handler.unmatchedListResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/unmatched/{id}/assign": {
+            "post": {
+                "description": "Gets the tv show with provided id from the metadata provider and saves it for the unmatched directory",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Assign unmatched item",
+                "operationId": "assign-unmatched-item",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "unmatched item id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "metadata provider and id of the tv show",
+                        "name": "name",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.assignUnmatchedPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TVShow"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/unmatched/{id}/ignore": {
+            "post": {
+                "description": "Marks the unmatched directory as ignored, so it's skipped by the next update",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Ignore unmatched item",
+                "operationId": "ignore-unmatched-item",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "unmatched item id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.UnmatchedItem"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/unmatched/{id}/retry": {
+            "post": {
+                "description": "Calls the metadata providers for the unmatched directory once again",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Retry unmatched item",
+                "operationId": "retry-unmatched-item",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "unmatched item id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TVShow"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
                         }
                     },
                     "500": {
@@ -97,7 +425,7 @@ var doc = `{
             }
         },
         "/update/all": {
-            "get": {
+            "post": {
                 "description": "Calls the third party API (TVMaze at this moment) to get data about tv shows from the local drive",
                 "produces": [
                     "application/json"
@@ -113,12 +441,139 @@ var doc = `{
                     }
                 }
             }
+        },
+        "/{id}/episodes": {
+            "get": {
+                "description": "Returns all indexed episodes of the tv show",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get episodes",
+                "operationId": "get-episodes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "id of the tv show",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.episodeListResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/{id}/seasons/{season}/download": {
+            "get": {
+                "description": "Streams ZIP archive with all episodes of the tv show season",
+                "produces": [
+                    "application/zip"
+                ],
+                "summary": "Download season",
+                "operationId": "download-season",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "id of the tv show",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "season number",
+                        "name": "season",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Error"
+                        }
+                    }
+                }
+            }
         }
     },
     "definitions": {
+        "handler.assignUnmatchedPayload": {
+            "type": "object",
+            "properties": {
+                "provider": {
+                    "type": "string",
+                    "example": "tvmaze"
+                },
+                "provider_id": {
+                    "type": "string",
+                    "example": "184"
+                }
+            }
+        },
+        "handler.episodeListResponse": {
+            "type": "object",
+            "properties": {
+                "episodes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Episode"
+                    }
+                }
+            }
+        },
         "handler.tvShowListResponse": {
             "type": "object",
             "properties": {
+                "page": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "per_page": {
+                    "type": "integer",
+                    "example": 20
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 42
+                },
                 "tv_shows": {
                     "type": "array",
                     "items": {
@@ -136,6 +591,23 @@ var doc = `{
                 }
             }
         },
+        "handler.tvShowProviderPayload": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "BoJack Horseman"
+                },
+                "provider": {
+                    "type": "string",
+                    "example": "tmdb"
+                },
+                "provider_id": {
+                    "type": "string",
+                    "example": "61222"
+                }
+            }
+        },
         "handler.tvShowUpdateResponse": {
             "type": "object",
             "properties": {
@@ -153,6 +625,75 @@ var doc = `{
                 }
             }
         },
+        "handler.unmatchedListResponse": {
+            "type": "object",
+            "properties": {
+                "unmatched": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.UnmatchedItem"
+                    }
+                }
+            }
+        },
+        "models.CastMember": {
+            "type": "object",
+            "properties": {
+                "character_image_url": {
+                    "type": "string",
+                    "example": "https://static.tvmaze.com/uploads/images/original_untouched/1/4282.jpg"
+                },
+                "character_name": {
+                    "type": "string",
+                    "example": "BoJack Horseman"
+                },
+                "image_url": {
+                    "type": "string",
+                    "example": "https://static.tvmaze.com/uploads/images/original_untouched/1/3604.jpg"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Will Arnett"
+                },
+                "tvmaze_person_id": {
+                    "type": "integer",
+                    "example": 20962
+                }
+            }
+        },
+        "models.Episode": {
+            "type": "object",
+            "properties": {
+                "file_name": {
+                    "type": "string",
+                    "example": "BoJack.Horseman.S01E03.mkv"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "5f4e2b9cc6e9ab2e4f8b4568"
+                },
+                "number": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "season": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 367001600
+                },
+                "tv_show_id": {
+                    "type": "string",
+                    "example": "507f1f77bcf86cd799439011"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2020-08-30T15:04:05Z"
+                }
+            }
+        },
         "models.Error": {
             "type": "object",
             "properties": {
@@ -166,6 +707,69 @@ var doc = `{
                 }
             }
         },
+        "models.Externals": {
+            "type": "object",
+            "properties": {
+                "imdb": {
+                    "type": "string",
+                    "example": "tt3398228"
+                },
+                "thetvdb": {
+                    "type": "integer",
+                    "example": 282254
+                },
+                "tvrage": {
+                    "type": "integer",
+                    "example": 37394
+                }
+            }
+        },
+        "models.Schedule": {
+            "type": "object",
+            "properties": {
+                "days": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "Friday"
+                    ]
+                },
+                "time": {
+                    "type": "string",
+                    "example": "21:00"
+                }
+            }
+        },
+        "models.Season": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "type": "string",
+                    "example": "2014-08-22"
+                },
+                "episode_count": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "name": {
+                    "type": "string"
+                },
+                "number": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "poster_url": {
+                    "type": "string",
+                    "example": "https://static.tvmaze.com/uploads/images/original_untouched/24/60941.jpg"
+                },
+                "premiere_date": {
+                    "type": "string",
+                    "example": "2014-08-22"
+                }
+            }
+        },
         "models.TVShow": {
             "type": "object",
             "required": [
@@ -175,15 +779,24 @@ var doc = `{
                 "name",
                 "poster_url",
                 "premiered",
-                "rating",
                 "runtime",
                 "summary"
             ],
             "properties": {
+                "cast": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CastMember"
+                    }
+                },
                 "dir_path": {
                     "type": "string",
                     "example": "tvshows/BoJack Horseman"
                 },
+                "externals": {
+                    "type": "object",
+                    "$ref": "#/definitions/models.Externals"
+                },
                 "genres": {
                     "type": "array",
                     "items": {
@@ -206,6 +819,14 @@ var doc = `{
                     "type": "string",
                     "example": "BoJack Horseman"
                 },
+                "network": {
+                    "type": "string",
+                    "example": "Netflix"
+                },
+                "official_site": {
+                    "type": "string",
+                    "example": "https://www.netflix.com/title/70298933"
+                },
                 "poster_url": {
                     "type": "string",
                     "example": "https://static.tvmaze.com/uploads/images/original_untouched/236/590384.jpg"
@@ -214,16 +835,119 @@ var doc = `{
                     "type": "string",
                     "example": "2014-08-22"
                 },
+                "provider": {
+                    "type": "string",
+                    "example": "tvmaze"
+                },
+                "provider_id": {
+                    "type": "string",
+                    "example": "184"
+                },
                 "rating": {
                     "type": "number",
                     "example": 8.1
                 },
                 "runtime": {
-                    "type": "integer"
+                    "type": "integer",
+                    "example": 25
+                },
+                "schedule": {
+                    "type": "object",
+                    "$ref": "#/definitions/models.Schedule"
+                },
+                "seasons": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Season"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "Ended"
                 },
                 "summary": {
                     "type": "string",
                     "example": "Meet the most beloved sitcom horse of the '90s, 20 years later."
+                },
+                "tmdb_id": {
+                    "type": "integer",
+                    "example": 61222
+                },
+                "tvmaze_id": {
+                    "type": "integer",
+                    "example": 184
+                },
+                "type": {
+                    "type": "string",
+                    "example": "Animation"
+                }
+            }
+        },
+        "models.UnmatchedCandidate": {
+            "type": "object",
+            "properties": {
+                "language": {
+                    "type": "string",
+                    "example": "English"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "BoJack Horseman"
+                },
+                "premiered": {
+                    "type": "string",
+                    "example": "2014-08-22"
+                },
+                "provider": {
+                    "type": "string",
+                    "example": "tvmaze"
+                },
+                "provider_id": {
+                    "type": "string",
+                    "example": "184"
+                },
+                "score": {
+                    "type": "number",
+                    "example": 17.8
+                }
+            }
+        },
+        "models.UnmatchedItem": {
+            "type": "object",
+            "properties": {
+                "candidates": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.UnmatchedCandidate"
+                    }
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2020-08-30T15:04:05Z"
+                },
+                "dir_path": {
+                    "type": "string",
+                    "example": "tvshows/BoJack Horseman"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "5f4bd6a4c6e9ab2e4f8b4567"
+                },
+                "ignored": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "query": {
+                    "type": "string",
+                    "example": "BoJack Horseman"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "Couldn't validate tv show [dir=BoJack Horseman]"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2020-08-30T15:04:05Z"
                 }
             }
         }
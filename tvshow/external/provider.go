@@ -0,0 +1,13 @@
+package external
+
+import "github.com/0x113/x-media/tvshow/models"
+
+// Provider describes the tv show metadata provider
+type Provider interface {
+	// Name returns the name used to select the provider in the config
+	Name() string
+	// Search returns tv shows matching the title, the best match goes first
+	Search(title string) ([]*models.SearchResult, error)
+	// GetTVShow returns the tv show with cast and seasons by its provider id
+	GetTVShow(id string) (*models.TVShow, error)
+}
@@ -0,0 +1,164 @@
+package tmdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/0x113/x-media/tvshow/common"
+	"github.com/0x113/x-media/tvshow/models"
+	"github.com/0x113/x-media/tvshow/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ProviderName is the name of the TMDb metadata provider
+const ProviderName = "tmdb"
+
+// imageBaseURL is prepended to the TMDb image paths
+const imageBaseURL = "https://image.tmdb.org/t/p/original"
+
+// Provider gets tv show metadata from the TMDb api
+type Provider struct {
+	Client utils.HttpClient
+}
+
+// Name returns the name of the provider
+func (p *Provider) Name() string {
+	return ProviderName
+}
+
+// Search calls TMDb api and returns the first page of the search results
+func (p *Provider) Search(title string) ([]*models.SearchResult, error) {
+	apiUrl := fmt.Sprintf("https://api.themoviedb.org/3/search/tv?api_key=%s&query=%s&language=%s", common.Config.TMDbAPIKey, url.QueryEscape(title), language())
+
+	tmdbResponse := new(models.TMDbTVSearchResponse)
+	if err := p.get(apiUrl, tmdbResponse); err != nil {
+		log.Debugf("Unable to search TMDb tv show [%s]; err: %v", title, err)
+		return nil, err
+	}
+
+	var results []*models.SearchResult
+	for _, r := range tmdbResponse.Results {
+		results = append(results, &models.SearchResult{
+			Provider:   ProviderName,
+			ProviderID: strconv.Itoa(r.ID),
+			Name:       r.Name,
+			Language:   r.OriginalLanguage,
+			Premiered:  r.FirstAirDate,
+			Score:      r.Popularity,
+		})
+	}
+	return results, nil
+}
+
+// GetTVShow calls TMDb api and returns the tv show with provided TMDb ID
+// along with its cast and external ids
+func (p *Provider) GetTVShow(id string) (*models.TVShow, error) {
+	tmdbID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid TMDb ID: %s", id)
+	}
+	apiUrl := fmt.Sprintf("https://api.themoviedb.org/3/tv/%d?api_key=%s&language=%s&append_to_response=credits,external_ids", tmdbID, common.Config.TMDbAPIKey, language())
+
+	tmdbTVShow := new(models.TMDbTVShow)
+	if err := p.get(apiUrl, tmdbTVShow); err != nil {
+		log.Debugf("Unable to get TMDb tv show [id=%d]; err: %v", tmdbID, err)
+		return nil, err
+	}
+	return toTVShow(tmdbTVShow), nil
+}
+
+// get sends GET request to the TMDb api and decodes the response into v
+func (p *Provider) get(apiUrl string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, apiUrl, nil)
+	if err != nil {
+		return err
+	}
+	res, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Expected 200 status code, got %d", res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// language returns language of the TMDb responses from the config
+func language() string {
+	if common.Config.TMDbLanguage == "" {
+		return "en-US"
+	}
+	return common.Config.TMDbLanguage
+}
+
+// toTVShow creates new TVShow object from the TMDb data
+func toTVShow(tmdbTVShow *models.TMDbTVShow) *models.TVShow {
+	tvShow := &models.TVShow{
+		Provider:     ProviderName,
+		ProviderID:   strconv.Itoa(tmdbTVShow.ID),
+		TMDbID:       tmdbTVShow.ID,
+		Name:         tmdbTVShow.Name,
+		Type:         tmdbTVShow.Type,
+		Status:       tmdbTVShow.Status,
+		Language:     tmdbTVShow.OriginalLanguage,
+		Premiered:    tmdbTVShow.FirstAirDate,
+		Rating:       tmdbTVShow.VoteAverage,
+		Summary:      tmdbTVShow.Overview,
+		OfficialSite: tmdbTVShow.Homepage,
+		Externals: &models.Externals{
+			TVRage:  tmdbTVShow.ExternalIDs.TVRageID,
+			TheTVDB: tmdbTVShow.ExternalIDs.TVDbID,
+			IMDb:    tmdbTVShow.ExternalIDs.IMDbID,
+		},
+	}
+	if tmdbTVShow.PosterPath != "" {
+		tvShow.PosterURL = imageBaseURL + tmdbTVShow.PosterPath
+	}
+	if len(tmdbTVShow.EpisodeRunTime) > 0 {
+		tvShow.Runtime = tmdbTVShow.EpisodeRunTime[0]
+	}
+	if len(tmdbTVShow.Networks) > 0 {
+		tvShow.Network = tmdbTVShow.Networks[0].Name
+	}
+	// TMDb returns ISO 639-1 codes, language name is stored to match TVmaze
+	for _, lang := range tmdbTVShow.SpokenLanguages {
+		if lang.ISO6391 == tmdbTVShow.OriginalLanguage && lang.EnglishName != "" {
+			tvShow.Language = lang.EnglishName
+			break
+		}
+	}
+	for _, genre := range tmdbTVShow.Genres {
+		tvShow.Genres = append(tvShow.Genres, genre.Name)
+	}
+
+	for _, member := range tmdbTVShow.Credits.Cast {
+		castMember := &models.CastMember{
+			Name:          member.Name,
+			CharacterName: member.Character,
+		}
+		if member.ProfilePath != "" {
+			castMember.ImageURL = imageBaseURL + member.ProfilePath
+		}
+		tvShow.Cast = append(tvShow.Cast, castMember)
+	}
+	for _, tmdbSeason := range tmdbTVShow.Seasons {
+		season := &models.Season{
+			Number:       tmdbSeason.SeasonNumber,
+			Name:         tmdbSeason.Name,
+			EpisodeCount: tmdbSeason.EpisodeCount,
+			PremiereDate: tmdbSeason.AirDate,
+		}
+		if tmdbSeason.PosterPath != "" {
+			season.PosterURL = imageBaseURL + tmdbSeason.PosterPath
+		}
+		tvShow.Seasons = append(tvShow.Seasons, season)
+	}
+
+	return tvShow
+}
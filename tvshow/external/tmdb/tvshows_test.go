@@ -0,0 +1,140 @@
+package tmdb_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/0x113/x-media/tvshow/common"
+	"github.com/0x113/x-media/tvshow/external/tmdb"
+	"github.com/0x113/x-media/tvshow/mocks"
+	"github.com/0x113/x-media/tvshow/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	common.Config = &common.Configuration{TMDbAPIKey: "fake-key"}
+}
+
+func TestSearch(t *testing.T) {
+	client := &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, "/3/search/tv", req.URL.Path)
+			assert.Equal(t, "Attack on Titan", req.URL.Query().Get("query"))
+			assert.Equal(t, "fake-key", req.URL.Query().Get("api_key"))
+			assert.Equal(t, "en-US", req.URL.Query().Get("language"))
+			json := `{"page": 1, "total_results": 1, "total_pages": 1, "results": [{"id": 1429, "name": "Attack on Titan", "original_name": "進撃の巨人", "original_language": "ja", "first_air_date": "2013-04-07", "popularity": 97.3}]}`
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(json))),
+			}, nil
+		},
+	}
+
+	provider := &tmdb.Provider{Client: client}
+	results, err := provider.Search("Attack on Titan")
+	assert.Nil(t, err)
+	assert.Equal(t, []*models.SearchResult{
+		{
+			Provider:   "tmdb",
+			ProviderID: "1429",
+			Name:       "Attack on Titan",
+			Language:   "ja",
+			Premiered:  "2013-04-07",
+			Score:      97.3,
+		},
+	}, results)
+}
+
+func TestGetTVShow(t *testing.T) {
+	testCases := []struct {
+		name       string
+		id         string
+		statusCode int
+		json       string
+		wantErr    bool
+	}{
+		{
+			name:       "Success",
+			id:         "1429",
+			statusCode: http.StatusOK,
+			json: `{
+	"id": 1429, "name": "Attack on Titan", "original_language": "ja", "type": "Scripted", "status": "Ended",
+	"homepage": "https://shingeki.tv", "overview": "Humans fight titans.", "first_air_date": "2013-04-07",
+	"episode_run_time": [24, 25], "vote_average": 8.6, "poster_path": "/poster.jpg",
+	"spoken_languages": [{"english_name": "English", "iso_639_1": "en"}, {"english_name": "Japanese", "iso_639_1": "ja"}],
+	"genres": [{"id": 16, "name": "Animation"}, {"id": 10759, "name": "Action & Adventure"}],
+	"networks": [{"id": 1, "name": "MBS"}],
+	"seasons": [{"id": 3, "season_number": 1, "name": "Season 1", "episode_count": 25, "air_date": "2013-04-07", "poster_path": "/season1.jpg"}],
+	"credits": {"cast": [{"id": 7, "name": "Yuki Kaji", "character": "Eren Yeager", "profile_path": null}]},
+	"external_ids": {"imdb_id": "tt2560140", "tvdb_id": 267440, "tvrage_id": 0}
+}`,
+			wantErr: false,
+		},
+		{
+			name:       "Not found",
+			id:         "1",
+			statusCode: http.StatusNotFound,
+			json:       `{"status_code": 34, "status_message": "The resource you requested could not be found."}`,
+			wantErr:    true,
+		},
+		{
+			name:    "Invalid id",
+			id:      "abc",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mocks.MockClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					assert.Equal(t, "/3/tv/"+tt.id, req.URL.Path)
+					assert.Equal(t, "credits,external_ids", req.URL.Query().Get("append_to_response"))
+					return &http.Response{
+						StatusCode: tt.statusCode,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte(tt.json))),
+					}, nil
+				},
+			}
+
+			provider := &tmdb.Provider{Client: client}
+			tvShow, err := provider.GetTVShow(tt.id)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				assert.Nil(t, tvShow)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, &models.TVShow{
+				Provider:     "tmdb",
+				ProviderID:   "1429",
+				TMDbID:       1429,
+				Name:         "Attack on Titan",
+				Type:         "Scripted",
+				Status:       "Ended",
+				Network:      "MBS",
+				Language:     "Japanese",
+				Genres:       []string{"Animation", "Action & Adventure"},
+				Runtime:      24,
+				Premiered:    "2013-04-07",
+				Rating:       8.6,
+				PosterURL:    "https://image.tmdb.org/t/p/original/poster.jpg",
+				Summary:      "Humans fight titans.",
+				OfficialSite: "https://shingeki.tv",
+				Externals: &models.Externals{
+					TheTVDB: 267440,
+					IMDb:    "tt2560140",
+				},
+				Cast: []*models.CastMember{
+					{Name: "Yuki Kaji", CharacterName: "Eren Yeager"},
+				},
+				Seasons: []*models.Season{
+					{Number: 1, Name: "Season 1", EpisodeCount: 25, PremiereDate: "2013-04-07", PosterURL: "https://image.tmdb.org/t/p/original/season1.jpg"},
+				},
+			}, tvShow)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package tvmaze
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/0x113/x-media/tvshow/models"
+	"github.com/0x113/x-media/tvshow/utils"
+)
+
+// ProviderName is the name of the TVmaze metadata provider
+const ProviderName = "tvmaze"
+
+// Provider gets tv show metadata from the TVmaze api
+type Provider struct {
+	Client utils.HttpClient
+}
+
+// Name returns the name of the provider
+func (p *Provider) Name() string {
+	return ProviderName
+}
+
+// Search calls TVmaze api and returns all of the search results
+func (p *Provider) Search(title string) ([]*models.SearchResult, error) {
+	tvMazeResults, err := SearchTVmazeTVShows(p.Client, title)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*models.SearchResult
+	for _, r := range tvMazeResults {
+		results = append(results, &models.SearchResult{
+			Provider:   ProviderName,
+			ProviderID: strconv.Itoa(r.Show.ID),
+			Name:       r.Show.Name,
+			Language:   r.Show.Language,
+			Premiered:  r.Show.Premiered,
+			Score:      r.Score,
+		})
+	}
+	return results, nil
+}
+
+// GetTVShow calls TVmaze api and returns the tv show with provided TVmaze ID
+func (p *Provider) GetTVShow(id string) (*models.TVShow, error) {
+	tvMazeID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid TVmaze ID: %s", id)
+	}
+
+	tvMazeInfo, err := GetTVmazeTVShowByID(p.Client, tvMazeID)
+	if err != nil {
+		return nil, err
+	}
+	return toTVShow(tvMazeInfo), nil
+}
+
+// toTVShow creates new TVShow object from the TVmaze data
+func toTVShow(tvMazeInfo *models.TVmazeTVShow) *models.TVShow {
+	tvShow := &models.TVShow{
+		Provider:     ProviderName,
+		ProviderID:   strconv.Itoa(tvMazeInfo.Show.ID),
+		TVmazeID:     tvMazeInfo.Show.ID,
+		Name:         tvMazeInfo.Show.Name,
+		Type:         tvMazeInfo.Show.Type,
+		Status:       tvMazeInfo.Show.Status,
+		Network:      tvMazeInfo.Show.Network.Name,
+		Language:     tvMazeInfo.Show.Language,
+		Genres:       tvMazeInfo.Show.Genres,
+		Runtime:      tvMazeInfo.Show.Runtime,
+		Premiered:    tvMazeInfo.Show.Premiered,
+		Rating:       tvMazeInfo.Show.Rating.Average,
+		PosterURL:    tvMazeInfo.Show.Image.Original,
+		Summary:      tvMazeInfo.Show.Summary,
+		OfficialSite: tvMazeInfo.Show.OfficialSite,
+		Schedule: &models.Schedule{
+			Time: tvMazeInfo.Show.Schedule.Time,
+			Days: tvMazeInfo.Show.Schedule.Days,
+		},
+		Externals: &models.Externals{
+			TVRage:  tvMazeInfo.Show.Externals.Tvrage,
+			TheTVDB: tvMazeInfo.Show.Externals.Thetvdb,
+			IMDb:    tvMazeInfo.Show.Externals.Imdb,
+		},
+		Cast:    toCast(tvMazeInfo.Show.Embedded.Cast),
+		Seasons: toSeasons(tvMazeInfo.Show.Embedded.Seasons),
+	}
+	// streaming services are returned as web channel instead of network
+	if tvShow.Network == "" {
+		tvShow.Network = tvMazeInfo.Show.WebChannel.Name
+	}
+	return tvShow
+}
+
+// toCast converts TVmaze cast to the cast stored with the tv show
+func toCast(tvMazeCast []*models.TVmazeCastMember) []*models.CastMember {
+	var cast []*models.CastMember
+	for _, member := range tvMazeCast {
+		castMember := &models.CastMember{
+			TVmazePersonID: member.Person.ID,
+			Name:           member.Person.Name,
+			CharacterName:  member.Character.Name,
+		}
+		if member.Person.Image != nil {
+			castMember.ImageURL = member.Person.Image.Original
+		}
+		if member.Character.Image != nil {
+			castMember.CharacterImageURL = member.Character.Image.Original
+		}
+		cast = append(cast, castMember)
+	}
+	return cast
+}
+
+// toSeasons converts TVmaze seasons to the seasons stored with the tv show
+func toSeasons(tvMazeSeasons []*models.TVmazeSeason) []*models.Season {
+	var seasons []*models.Season
+	for _, tvMazeSeason := range tvMazeSeasons {
+		season := &models.Season{
+			Number:       tvMazeSeason.Number,
+			Name:         tvMazeSeason.Name,
+			EpisodeCount: tvMazeSeason.EpisodeOrder,
+			PremiereDate: tvMazeSeason.PremiereDate,
+			EndDate:      tvMazeSeason.EndDate,
+		}
+		if tvMazeSeason.Image != nil {
+			season.PosterURL = tvMazeSeason.Image.Original
+		}
+		seasons = append(seasons, season)
+	}
+	return seasons
+}
@@ -16,6 +16,20 @@ import (
 
 // GetTVmazeTVShowInfo calls TVmaze api and returns new TVmaze object
 func GetTVmazeTVShowInfo(client utils.HttpClient, title string) (*models.TVmazeTVShow, error) {
+	tvMazeResponse, err := SearchTVmazeTVShows(client, title)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tvMazeResponse) == 0 {
+		return nil, fmt.Errorf("Unable to find tv show with title: %s", title)
+	}
+
+	return tvMazeResponse[0], nil // get first match
+}
+
+// SearchTVmazeTVShows calls TVmaze api and returns all of the search results
+func SearchTVmazeTVShows(client utils.HttpClient, title string) ([]*models.TVmazeTVShow, error) {
 	query := url.QueryEscape(title)
 	apiUrl := fmt.Sprintf("https://api.tvmaze.com/search/shows?q=%s", query)
 	// request
@@ -44,11 +58,38 @@ func GetTVmazeTVShowInfo(client utils.HttpClient, title string) (*models.TVmazeT
 		return nil, err
 	}
 
-	var tvMazeInfo *models.TVmazeTVShow
-	if len(tvMazeResponse) >= 1 {
-		tvMazeInfo = tvMazeResponse[0] // get first match
+	return tvMazeResponse, nil
+}
+
+// GetTVmazeTVShowByID calls TVmaze api and returns the tv show with provided TVmaze ID
+// along with the embedded cast and seasons
+func GetTVmazeTVShowByID(client utils.HttpClient, id int) (*models.TVmazeTVShow, error) {
+	apiUrl := fmt.Sprintf("https://api.tvmaze.com/shows/%d?embed[]=cast&embed[]=seasons", id)
+	// request
+	req, err := http.NewRequest("GET", apiUrl, nil)
+	if err != nil {
+		log.Debugf("Unable to prepare request for show [id=%d]; err: %v", id, err)
+		return nil, err
+	}
+	// response
+	res, err := client.Do(req)
+	if err != nil {
+		log.Debugf("Unable to send request to the TVmaze api; err: %v", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	// check status code
+	if res.StatusCode != http.StatusOK {
+		log.Debugf("Expected status code: %d; got: %d", http.StatusOK, res.StatusCode)
+		return nil, fmt.Errorf("Expected 200 status code, got %d", res.StatusCode)
+	}
+	// decode, lookup returns the show object without the search score
+	tvMazeInfo := new(models.TVmazeTVShow)
+	if err := json.NewDecoder(res.Body).Decode(&tvMazeInfo.Show); err != nil {
+		log.Debugf("Unable to decode TVmaze info for show[id=%d]; err: %v", id, err)
+		return nil, err
 	}
 
 	return tvMazeInfo, nil
-
 }
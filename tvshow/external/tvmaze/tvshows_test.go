@@ -113,3 +113,112 @@ func TestFailDoFuncErrorGetTVmazeTVShowInfo(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Nil(t, tvMazeInfo)
 }
+
+func TestNoResultsGetTVmazeTVShowInfo(t *testing.T) {
+	client := &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`[]`))),
+			}, nil
+		},
+	}
+
+	tvMazeInfo, err := tvmaze.GetTVmazeTVShowInfo(client, "No such show")
+	assert.NotNil(t, err)
+	assert.Nil(t, tvMazeInfo)
+}
+
+func TestSearchTVmazeTVShows(t *testing.T) {
+	client := &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			json := `[
+	{"score": 25.4, "show": {"id": 526, "name": "The Office", "premiered": "2005-03-24"}},
+	{"score": 20.1, "show": {"id": 2347, "name": "The Office", "premiered": "2001-07-09"}}
+]`
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(json))),
+			}, nil
+		},
+	}
+
+	results, err := tvmaze.SearchTVmazeTVShows(client, "The Office")
+	assert.Nil(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, 2347, results[1].Show.ID)
+}
+
+func TestGetTVmazeTVShowByID(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusCode int
+		json       string
+		wantErr    bool
+	}{
+		{
+			name:       "Success",
+			statusCode: http.StatusOK,
+			json:       `{"id": 526, "name": "The Office", "language": "English", "rating": {"average": 8.5}}`,
+			wantErr:    false,
+		},
+		{
+			name:       "Not found",
+			statusCode: http.StatusNotFound,
+			json:       `{"name": "Not Found", "status": 404}`,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mocks.MockClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					assert.Equal(t, "/shows/526", req.URL.Path)
+					assert.Equal(t, []string{"cast", "seasons"}, req.URL.Query()["embed[]"])
+					return &http.Response{
+						StatusCode: tt.statusCode,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte(tt.json))),
+					}, nil
+				},
+			}
+
+			tvMazeInfo, err := tvmaze.GetTVmazeTVShowByID(client, 526)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				assert.Nil(t, tvMazeInfo)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, "The Office", tvMazeInfo.Show.Name)
+				assert.Equal(t, float32(8.5), tvMazeInfo.Show.Rating.Average)
+			}
+		})
+	}
+}
+
+func TestGetTVmazeTVShowByIDEmbeds(t *testing.T) {
+	client := &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			json := `{"id": 526, "name": "The Office", "_embedded": {
+	"cast": [{"person": {"id": 5, "name": "Steve Carell", "image": {"original": "http://static.tvmaze.com/person.jpg"}}, "character": {"id": 7, "name": "Michael Scott", "image": null}}],
+	"seasons": [{"id": 2297, "number": 1, "name": "", "episodeOrder": 6, "premiereDate": "2005-03-24", "endDate": "2005-04-26", "image": {"original": "http://static.tvmaze.com/season.jpg"}}]
+}}`
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(json))),
+			}, nil
+		},
+	}
+
+	tvMazeInfo, err := tvmaze.GetTVmazeTVShowByID(client, 526)
+	assert.Nil(t, err)
+	if assert.Len(t, tvMazeInfo.Show.Embedded.Cast, 1) {
+		assert.Equal(t, "Steve Carell", tvMazeInfo.Show.Embedded.Cast[0].Person.Name)
+		assert.Equal(t, "Michael Scott", tvMazeInfo.Show.Embedded.Cast[0].Character.Name)
+		assert.Nil(t, tvMazeInfo.Show.Embedded.Cast[0].Character.Image)
+	}
+	if assert.Len(t, tvMazeInfo.Show.Embedded.Seasons, 1) {
+		assert.Equal(t, 6, tvMazeInfo.Show.Embedded.Seasons[0].EpisodeOrder)
+		assert.Equal(t, "http://static.tvmaze.com/season.jpg", tvMazeInfo.Show.Embedded.Seasons[0].Image.Original)
+	}
+}
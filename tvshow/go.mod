@@ -3,7 +3,9 @@ module github.com/0x113/x-media/tvshow
 go 1.14
 
 require (
+	github.com/0x113/x-media/auth v0.0.0
 	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/go-openapi/jsonreference v0.19.4 // indirect
 	github.com/go-openapi/runtime v0.19.20
 	github.com/go-playground/validator/v10 v10.3.0
@@ -21,3 +23,5 @@ require (
 	golang.org/x/tools v0.0.0-20200717024301-6ddee64345a6 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 )
+
+replace github.com/0x113/x-media/auth => ../auth
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/0x113/x-media/tvshow/models"
+	"github.com/0x113/x-media/tvshow/service"
+
+	"github.com/labstack/echo"
+	log "github.com/sirupsen/logrus"
+)
+
+// @Summary Get episodes
+// @Description Returns all indexed episodes of the tv show
+// @ID get-episodes
+// @Produce json
+// @Param id path string true "id of the tv show"
+// @Success 200 {object} episodeListResponse
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /{id}/episodes [get]
+// GetEpisodes calls service layer and returns all episodes of the tv show
+func (h *tvShowHandler) GetEpisodes(c echo.Context) error {
+	episodes, err := h.tvShowService.GetEpisodes(c.Param("id"))
+	if err != nil {
+		return episodeError(c, err)
+	}
+
+	msg := map[string]interface{}{
+		"episodes": episodes,
+	}
+	return c.JSON(http.StatusOK, msg)
+}
+
+// @Summary Stream episode
+// @Description Streams the episode video file, supports HTTP Range requests
+// @ID stream-episode
+// @Produce octet-stream
+// @Param id path string true "id of the episode"
+// @Param Range header string false "byte range, e.g. bytes=0-1023"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Router /episodes/{id}/stream [get]
+// StreamEpisode calls service layer to get the episode and serves its file
+func (h *tvShowHandler) StreamEpisode(c echo.Context) error {
+	episode, err := h.tvShowService.GetEpisode(c.Param("id"))
+	if err != nil {
+		return episodeError(c, err)
+	}
+
+	file, err := os.Open(episode.FilePath)
+	if err != nil {
+		log.Errorf("Couldn't open episode file [%s]; err: %v", episode.FilePath, err)
+		return episodeError(c, fmt.Errorf("%w: %s", service.ErrEpisodeNotFound, episode.ID.Hex()))
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		log.Errorf("Couldn't stat episode file [%s]; err: %v", episode.FilePath, err)
+		return episodeError(c, err)
+	}
+
+	// ServeContent handles Range, If-Range and If-Modified-Since headers
+	http.ServeContent(c.Response(), c.Request(), episode.FileName, info.ModTime(), file)
+	return nil
+}
+
+// @Summary Download season
+// @Description Streams ZIP archive with all episodes of the tv show season
+// @ID download-season
+// @Produce application/zip
+// @Param id path string true "id of the tv show"
+// @Param season path int true "season number"
+// @Success 200 {file} file
+// @Failure 400 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Router /{id}/seasons/{season}/download [get]
+// DownloadSeason calls service layer to get the season episodes and
+// writes them to the response as ZIP archive
+func (h *tvShowHandler) DownloadSeason(c echo.Context) error {
+	season, err := strconv.Atoi(c.Param("season"))
+	if err != nil || season < 0 {
+		errMsg := &models.Error{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Invalid season: %s", c.Param("season")),
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return errors.New(errMsg.Message)
+	}
+
+	episodes, err := h.tvShowService.GetSeasonEpisodes(c.Param("id"), season)
+	if err != nil {
+		return episodeError(c, err)
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "application/zip")
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="season_%02d.zip"`, season))
+	res.WriteHeader(http.StatusOK)
+
+	// headers are already sent, so errors can only be logged
+	if err := writeZIP(res, episodes); err != nil {
+		log.Errorf("Couldn't write season [%d] archive of the tv show [%s]; err: %v", season, c.Param("id"), err)
+		return err
+	}
+	return nil
+}
+
+// writeZIP writes episode files one by one to the ZIP archive. Video files
+// are already compressed, so they are stored without compression.
+func writeZIP(w io.Writer, episodes []*models.Episode) error {
+	zw := zip.NewWriter(w)
+	for _, episode := range episodes {
+		if err := writeZIPEntry(zw, episode); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeZIPEntry(zw *zip.Writer, episode *models.Episode) error {
+	file, err := os.Open(episode.FilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = episode.FileName
+	header.Method = zip.Store
+	entry, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, file)
+	return err
+}
+
+// episodeError writes error response with the status code based on the service error
+func episodeError(c echo.Context, err error) error {
+	errMsg := &models.Error{
+		Code:    http.StatusInternalServerError,
+		Message: err.Error(),
+	}
+	switch {
+	case errors.Is(err, service.ErrEpisodeNotFound):
+		errMsg.Code = http.StatusNotFound
+	case errors.Is(err, service.ErrPathNotAllowed):
+		errMsg.Code = http.StatusForbidden
+	}
+	c.JSON(errMsg.Code, errMsg)
+	return err
+}
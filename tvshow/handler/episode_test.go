@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	authmw "github.com/0x113/x-media/auth/pkg/middleware"
+	"github.com/0x113/x-media/tvshow/common"
+	"github.com/0x113/x-media/tvshow/mocks"
+	"github.com/0x113/x-media/tvshow/models"
+	"github.com/0x113/x-media/tvshow/service"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// setupEpisodes creates episode files in the temporary tv show directory
+// and returns router with the episodes indexed
+func setupEpisodes(t *testing.T) (*echo.Echo, primitive.ObjectID, []*models.Episode, func()) {
+	library, err := ioutil.TempDir("", "tvshows-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	common.Config = &common.Configuration{
+		TVShowDirectories: []string{library},
+	}
+
+	tvShowID := primitive.NewObjectID()
+	var episodes []*models.Episode
+	for i, name := range []string{"Dark.S01E01.mkv", "Dark.S01E02.mkv"} {
+		path := filepath.Join(library, name)
+		if err := ioutil.WriteFile(path, []byte("episode content "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		episodes = append(episodes, &models.Episode{
+			ID:       primitive.NewObjectID(),
+			TVShowID: tvShowID,
+			Season:   1,
+			Number:   i + 1,
+			FilePath: path,
+			FileName: name,
+		})
+	}
+	episodeRepo := mocks.NewMockEpisodeRepository()
+	episodeRepo.ReplaceByTVShowID(tvShowID, episodes)
+
+	tvShowService := service.NewTVShowService(nil, mocks.NewMockTVShowRepository(), mocks.NewMockUnmatchedRepository(), episodeRepo)
+	e := echo.New()
+	validator := &mocks.MockValidator{
+		Tokens: map[string]*authmw.AccessDetails{"user-token": {Username: "JohnDoe"}},
+	}
+	NewTVShowHandler(e, tvShowService, validator)
+
+	return e, tvShowID, episodes, func() { os.RemoveAll(library) }
+}
+
+// newAuthRequest creates the GET request with the token accepted by the mocked validator
+func newAuthRequest(target string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer user-token")
+	return req
+}
+
+func TestGetEpisodes(t *testing.T) {
+	e, tvShowID, _, cleanup := setupEpisodes(t)
+	defer cleanup()
+
+	req := newAuthRequest("/api/v1/tvshows/" + tvShowID.Hex() + "/episodes")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Dark.S01E02.mkv")
+	assert.NotContains(t, rec.Body.String(), "file_path")
+
+	// request without the token
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/tvshows/"+tvShowID.Hex()+"/episodes", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestStreamEpisode(t *testing.T) {
+	e, _, episodes, cleanup := setupEpisodes(t)
+	defer cleanup()
+
+	testCases := []struct {
+		name               string
+		id                 string
+		rangeHeader        string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "Whole file",
+			id:                 episodes[0].ID.Hex(),
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "episode content Dark.S01E01.mkv",
+		},
+		{
+			name:               "Range",
+			id:                 episodes[0].ID.Hex(),
+			rangeHeader:        "bytes=8-14",
+			expectedStatusCode: http.StatusPartialContent,
+			expectedBody:       "content",
+		},
+		{
+			name:               "Non-existent episode",
+			id:                 primitive.NewObjectID().Hex(),
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newAuthRequest("/api/v1/tvshows/episodes/" + tt.id + "/stream")
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatusCode, rec.Code)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, rec.Body.String())
+			}
+		})
+	}
+
+	// file outside of the tv show directories
+	common.Config.TVShowDirectories = []string{"testdata"}
+	req := newAuthRequest("/api/v1/tvshows/episodes/" + episodes[0].ID.Hex() + "/stream")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestDownloadSeason(t *testing.T) {
+	e, tvShowID, _, cleanup := setupEpisodes(t)
+	defer cleanup()
+
+	testCases := []struct {
+		name               string
+		season             string
+		expectedStatusCode int
+	}{
+		{
+			name:               "Success",
+			season:             "1",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Non-existent season",
+			season:             "2",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Invalid season",
+			season:             "first",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newAuthRequest("/api/v1/tvshows/" + tvShowID.Hex() + "/seasons/" + tt.season + "/download")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.expectedStatusCode, rec.Code)
+		})
+	}
+
+	// check the archive content
+	req := newAuthRequest("/api/v1/tvshows/" + tvShowID.Hex() + "/seasons/1/download")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, "application/zip", rec.Header().Get(echo.HeaderContentType))
+
+	body := rec.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if assert.NoError(t, err) && assert.Len(t, zr.File, 2) {
+		assert.Equal(t, "Dark.S01E01.mkv", zr.File[0].Name)
+		f, err := zr.File[1].Open()
+		assert.NoError(t, err)
+		content, _ := ioutil.ReadAll(f)
+		assert.Equal(t, "episode content Dark.S01E02.mkv", string(content))
+	}
+}
+
+func TestQueryToken(t *testing.T) {
+	e, tvShowID, episodes, cleanup := setupEpisodes(t)
+	defer cleanup()
+
+	testCases := []struct {
+		name               string
+		target             string
+		expectedStatusCode int
+	}{
+		{
+			name:               "Stream",
+			target:             "/api/v1/tvshows/episodes/" + episodes[0].ID.Hex() + "/stream?access_token=user-token",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Download",
+			target:             "/api/v1/tvshows/" + tvShowID.Hex() + "/seasons/1/download?access_token=user-token",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Invalid token",
+			target:             "/api/v1/tvshows/episodes/" + episodes[0].ID.Hex() + "/stream?access_token=invalid",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Not allowed on the other routes",
+			target:             "/api/v1/tvshows/" + tvShowID.Hex() + "/episodes?access_token=user-token",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tt.expectedStatusCode, rec.Code)
+		})
+	}
+}
@@ -4,3 +4,18 @@ package handler
 type tvShowNamePayload struct {
 	Name string `json:"name" example:"BoJack Horseman"`
 }
+
+// assignUnmatchedPayload represents request body that should be sent
+// to assign the tv show to the unmatched directory
+type assignUnmatchedPayload struct {
+	Provider   string `json:"provider" example:"tvmaze"`
+	ProviderID string `json:"provider_id" example:"184"`
+}
+
+// tvShowProviderPayload represents request body that should be sent
+// to change the metadata provider of the tv show
+type tvShowProviderPayload struct {
+	Name       string `json:"name" example:"BoJack Horseman"`
+	Provider   string `json:"provider" example:"tmdb"`
+	ProviderID string `json:"provider_id" example:"61222"`
+}
@@ -5,9 +5,16 @@ import "github.com/0x113/x-media/tvshow/models"
 // NOTE: these models are only for docs, they are not used in the handlers
 type tvShowListResponse struct {
 	TVShows []*models.TVShow `json:"tv_shows"`
+	Page    int              `json:"page" example:"1"`
+	PerPage int              `json:"per_page" example:"20"`
+	Total   int64            `json:"total" example:"42"`
 }
 
 type tvShowUpdateResponse struct {
 	Errors       map[string]string `json:"errors"`
 	UpdatedShows map[string]string `json:"updated_shows"`
 }
+
+type episodeListResponse struct {
+	Episodes []*models.Episode `json:"episodes"`
+}
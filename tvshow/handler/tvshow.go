@@ -1,8 +1,13 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
+	authmw "github.com/0x113/x-media/auth/pkg/middleware"
 	"github.com/0x113/x-media/tvshow/models"
 	"github.com/0x113/x-media/tvshow/service"
 
@@ -10,12 +15,18 @@ import (
 	"github.com/labstack/echo"
 )
 
+// unmatchedListResponse is returned by GetUnmatchedItems
+type unmatchedListResponse struct {
+	Unmatched []*models.UnmatchedItem `json:"unmatched"`
+}
+
 type tvShowHandler struct {
 	tvShowService service.TVShowService
 }
 
-// NewTVShowHandler initiates tv show handlers
-func NewTVShowHandler(router *echo.Echo, tvShowService service.TVShowService) {
+// NewTVShowHandler initiates tv show handlers, all of the api routes require
+// a valid token with the scope matching the route
+func NewTVShowHandler(router *echo.Echo, tvShowService service.TVShowService, validator authmw.Validator) {
 	handler := &tvShowHandler{tvShowService}
 
 	// swagger
@@ -24,14 +35,32 @@ func NewTVShowHandler(router *echo.Echo, tvShowService service.TVShowService) {
 	router.File("/swagger.yaml", "./docs/swagger.yaml")
 	router.GET("/docs", echo.WrapHandler(sh))
 
-	// TODO: use groups
-	router.POST("/api/v1/tvshows/get", handler.GetTVShow)
-	router.GET("/api/v1/tvshows/get/all", handler.GetAllTVShows)
-	router.GET("/api/v1/tvshows/update/all", handler.UpdateAllTVShows)
+	read := authmw.RequireScope(authmw.ScopeLibraryRead)
+	scan := authmw.RequireScope(authmw.ScopeLibraryScan)
+	edit := authmw.RequireScope(authmw.ScopeLibraryEdit)
+	stream := authmw.RequireScope(authmw.ScopeStream)
+
+	api := router.Group("/api/v1/tvshows", authmw.Middleware(validator))
+	api.POST("/get", handler.GetTVShow, read)
+	api.GET("", handler.GetTVShows, read)
+	api.POST("/update/all", handler.UpdateAllTVShows, scan)
+	api.POST("/provider", handler.SetTVShowProvider, edit)
+	api.GET("/unmatched", handler.GetUnmatchedItems, edit)
+	api.POST("/unmatched/:id/retry", handler.RetryUnmatchedItem, edit)
+	api.POST("/unmatched/:id/ignore", handler.IgnoreUnmatchedItem, edit)
+	api.POST("/unmatched/:id/assign", handler.AssignUnmatchedItem, edit)
+	api.GET("/:id/episodes", handler.GetEpisodes, read)
+
+	// the video players can't set the headers, so only the stream and download
+	// routes accept the token in the access_token query param
+	queryToken := authmw.QueryTokenMiddleware(validator)
+	media := router.Group("/api/v1/tvshows")
+	media.GET("/:id/seasons/:season/download", handler.DownloadSeason, queryToken, stream)
+	media.GET("/episodes/:id/stream", handler.StreamEpisode, queryToken, stream)
 }
 
 // @Summary Get tv show
-// @Description Returns tv shows
+// @Description Returns tv show along with its cast and seasons
 // @ID get-tvshow-by-name
 // @Accept  json
 // @Produce  json
@@ -66,7 +95,7 @@ func (h *tvShowHandler) GetTVShow(c echo.Context) error {
 // @ID update-all-tv-shows
 // @Produce  json
 // @Success 200 {object} tvShowUpdateResponse
-// @Router /update/all [get]
+// @Router /update/all [post]
 // UpdateAllTVShows calls service layer and updates all tv shows which
 // are in specified dirs
 func (h *tvShowHandler) UpdateAllTVShows(c echo.Context) error {
@@ -78,19 +107,40 @@ func (h *tvShowHandler) UpdateAllTVShows(c echo.Context) error {
 	return c.JSON(http.StatusOK, response)
 }
 
-// @Summary Get all tv shows
-// @Description Returns all the tv shows from the database
-// @ID get-all-tv-shows
+// @Summary Get tv shows
+// @Description Returns page of the tv shows from the database matching the filter
+// @ID get-tv-shows
 // @Produce json
+// @Param genre query string false "genre of the tv show"
+// @Param language query string false "language of the tv show"
+// @Param status query string false "status of the tv show, e.g. Running or Ended"
+// @Param network query string false "network or web channel of the tv show"
+// @Param year query int false "year in which the tv show premiered"
+// @Param sort query string false "sort field (name, rating, premiered, runtime), prefix with - for descending order"
+// @Param page query int false "page number, starts from 1"
+// @Param per_page query int false "number of tv shows per page, max 100"
 // @Success 200 {object} tvShowListResponse
+// @Failure 400 {object} models.Error
 // @Failure 500 {object} models.Error
-// @Router /get/all [get]
-// GetAllTVShows calls service layer and returns all tv shows from the database
-func (h *tvShowHandler) GetAllTVShows(c echo.Context) error {
+// @Router / [get]
+// GetTVShows calls service layer and returns filtered, sorted and paginated
+// tv shows from the database
+func (h *tvShowHandler) GetTVShows(c echo.Context) error {
 	errMsg := &models.Error{}
-	tvShows, err := h.tvShowService.GetAllTVShows()
+	filter, err := parseTVShowFilter(c)
+	if err != nil {
+		errMsg.Code = http.StatusBadRequest
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	tvShows, total, err := h.tvShowService.GetTVShows(filter)
 	if err != nil {
 		errMsg.Code = http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidFilter) {
+			errMsg.Code = http.StatusBadRequest
+		}
 		errMsg.Message = err.Error()
 		c.JSON(errMsg.Code, errMsg)
 		return err
@@ -98,6 +148,212 @@ func (h *tvShowHandler) GetAllTVShows(c echo.Context) error {
 
 	msg := map[string]interface{}{
 		"tv_shows": tvShows,
+		"page":     filter.Page,
+		"per_page": filter.PerPage,
+		"total":    total,
 	}
 	return c.JSON(http.StatusOK, msg)
 }
+
+// parseTVShowFilter creates tv show filter from the query parameters
+func parseTVShowFilter(c echo.Context) (*models.TVShowFilter, error) {
+	filter := &models.TVShowFilter{
+		Genre:    c.QueryParam("genre"),
+		Language: c.QueryParam("language"),
+		Status:   c.QueryParam("status"),
+		Network:  c.QueryParam("network"),
+		SortBy:   c.QueryParam("sort"),
+	}
+	if strings.HasPrefix(filter.SortBy, "-") {
+		filter.SortBy = strings.TrimPrefix(filter.SortBy, "-")
+		filter.SortDesc = true
+	}
+
+	ints := map[string]*int{
+		"year":     &filter.Year,
+		"page":     &filter.Page,
+		"per_page": &filter.PerPage,
+	}
+	for name, value := range ints {
+		param := c.QueryParam(name)
+		if param == "" {
+			continue
+		}
+		i, err := strconv.Atoi(param)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s parameter: %s", name, param)
+		}
+		*value = i
+	}
+
+	return filter, nil
+}
+
+// @Summary Get unmatched items
+// @Description Returns all directories which couldn't be matched with the metadata providers along with the reason and candidates
+// @ID get-unmatched-items
+// @Produce json
+// @Success 200 {object} unmatchedListResponse
+// @Failure 500 {object} models.Error
+// @Router /unmatched [get]
+// GetUnmatchedItems calls service layer and returns all items from the unmatched items queue
+func (h *tvShowHandler) GetUnmatchedItems(c echo.Context) error {
+	errMsg := &models.Error{}
+	items, err := h.tvShowService.GetUnmatchedItems()
+	if err != nil {
+		errMsg.Code = http.StatusInternalServerError
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &unmatchedListResponse{Unmatched: items})
+}
+
+// @Summary Retry unmatched item
+// @Description Calls the metadata providers for the unmatched directory once again
+// @ID retry-unmatched-item
+// @Produce json
+// @Param id path string true "unmatched item id"
+// @Success 200 {object} models.TVShow
+// @Failure 400 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /unmatched/{id}/retry [post]
+// RetryUnmatchedItem calls service layer to match the unmatched directory once again
+func (h *tvShowHandler) RetryUnmatchedItem(c echo.Context) error {
+	errMsg := &models.Error{}
+	tvShow, err := h.tvShowService.RetryUnmatchedItem(c.Param("id"))
+	if err != nil {
+		errMsg.Code = unmatchedStatus(err)
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, tvShow)
+}
+
+// @Summary Ignore unmatched item
+// @Description Marks the unmatched directory as ignored, so it's skipped by the next update
+// @ID ignore-unmatched-item
+// @Produce json
+// @Param id path string true "unmatched item id"
+// @Success 200 {object} models.UnmatchedItem
+// @Failure 400 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /unmatched/{id}/ignore [post]
+// IgnoreUnmatchedItem calls service layer to ignore the unmatched directory
+func (h *tvShowHandler) IgnoreUnmatchedItem(c echo.Context) error {
+	errMsg := &models.Error{}
+	item, err := h.tvShowService.IgnoreUnmatchedItem(c.Param("id"))
+	if err != nil {
+		errMsg.Code = unmatchedStatus(err)
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, item)
+}
+
+// @Summary Assign unmatched item
+// @Description Gets the tv show with provided id from the metadata provider and saves it for the unmatched directory
+// @ID assign-unmatched-item
+// @Accept json
+// @Produce json
+// @Param id path string true "unmatched item id"
+// @Param name body assignUnmatchedPayload true "metadata provider and id of the tv show"
+// @Success 200 {object} models.TVShow
+// @Failure 400 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /unmatched/{id}/assign [post]
+// AssignUnmatchedItem calls service layer to assign the tv show to the unmatched directory manually
+func (h *tvShowHandler) AssignUnmatchedItem(c echo.Context) error {
+	errMsg := &models.Error{}
+	payload := new(assignUnmatchedPayload)
+	if err := c.Bind(payload); err != nil {
+		errMsg.Code = http.StatusBadRequest
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+	if payload.Provider == "" || payload.ProviderID == "" {
+		errMsg.Code = http.StatusBadRequest
+		errMsg.Message = "Fields provider and provider_id are required"
+		c.JSON(errMsg.Code, errMsg)
+		return errors.New(errMsg.Message)
+	}
+
+	tvShow, err := h.tvShowService.AssignUnmatchedItem(c.Param("id"), payload.Provider, payload.ProviderID)
+	if err != nil {
+		errMsg.Code = unmatchedStatus(err)
+		if errors.Is(err, service.ErrUnknownProvider) {
+			errMsg.Code = http.StatusBadRequest
+		}
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, tvShow)
+}
+
+// unmatchedStatus returns the status code of the unmatched items queue error
+func unmatchedStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidUnmatchedID):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrUnmatchedNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// @Summary Set tv show provider
+// @Description Gets the existing tv show from the provided metadata provider and saves it, so the next updates use this provider first
+// @ID set-tv-show-provider
+// @Accept json
+// @Produce json
+// @Param name body tvShowProviderPayload true "name of the tv show, metadata provider and optional id of the tv show"
+// @Success 200 {object} models.TVShow
+// @Failure 400 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /provider [post]
+// SetTVShowProvider calls service layer to change the metadata provider of the tv show
+func (h *tvShowHandler) SetTVShowProvider(c echo.Context) error {
+	errMsg := &models.Error{}
+	payload := new(tvShowProviderPayload)
+	if err := c.Bind(payload); err != nil {
+		errMsg.Code = http.StatusBadRequest
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+	if payload.Name == "" || payload.Provider == "" {
+		errMsg.Code = http.StatusBadRequest
+		errMsg.Message = "Fields name and provider are required"
+		c.JSON(errMsg.Code, errMsg)
+		return errors.New(errMsg.Message)
+	}
+
+	tvShow, err := h.tvShowService.SetTVShowProvider(payload.Name, payload.Provider, payload.ProviderID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTVShowNotFound):
+			errMsg.Code = http.StatusNotFound
+		case errors.Is(err, service.ErrUnknownProvider):
+			errMsg.Code = http.StatusBadRequest
+		default:
+			errMsg.Code = http.StatusInternalServerError
+		}
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, tvShow)
+}
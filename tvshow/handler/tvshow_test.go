@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -9,7 +10,10 @@ import (
 	"testing"
 
 	"github.com/0x113/x-media/tvshow/common"
+	"github.com/0x113/x-media/tvshow/external"
+	"github.com/0x113/x-media/tvshow/external/tvmaze"
 	"github.com/0x113/x-media/tvshow/mocks"
+	"github.com/0x113/x-media/tvshow/models"
 	"github.com/0x113/x-media/tvshow/service"
 
 	"github.com/labstack/echo"
@@ -25,7 +29,7 @@ func TestGetTVShow(t *testing.T) {
 	// setup
 	client := &mocks.MockClient{}
 	tvShowRepo := mocks.NewMockTVShowRepository()
-	tvShowService := service.NewTVShowService(client, tvShowRepo)
+	tvShowService := service.NewTVShowService([]external.Provider{&tvmaze.Provider{Client: client}}, tvShowRepo, mocks.NewMockUnmatchedRepository(), mocks.NewMockEpisodeRepository())
 	e := echo.New()
 
 	testCases := []struct {
@@ -74,21 +78,60 @@ func TestGetTVShow(t *testing.T) {
 	}
 }
 
-func TestGetAllTVShows(t *testing.T) {
+func TestGetTVShows(t *testing.T) {
 	// setup
 	client := &mocks.MockClient{}
 	tvShowRepo := mocks.NewMockTVShowRepository()
-	tvShowService := service.NewTVShowService(client, tvShowRepo)
+	tvShowService := service.NewTVShowService([]external.Provider{&tvmaze.Provider{Client: client}}, tvShowRepo, mocks.NewMockUnmatchedRepository(), mocks.NewMockEpisodeRepository())
 	e := echo.New()
 
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/tvshows/get/all", nil)
-	rec := httptest.NewRecorder()
-	c := e.NewContext(req, rec)
+	testCases := []struct {
+		name               string
+		query              string
+		expectedStatusCode int
+		wantErr            bool
+	}{
+		{
+			name:               "Success",
+			query:              "",
+			expectedStatusCode: 200,
+			wantErr:            false,
+		},
+		{
+			name:               "Success with filters",
+			query:              "?genre=Comedy&year=2014&sort=-rating&page=1&per_page=10",
+			expectedStatusCode: 200,
+			wantErr:            false,
+		},
+		{
+			name:               "Invalid year",
+			query:              "?year=twenty",
+			expectedStatusCode: 400,
+			wantErr:            true,
+		},
+		{
+			name:               "Unknown sort field",
+			query:              "?sort=weight",
+			expectedStatusCode: 400,
+			wantErr:            true,
+		},
+	}
 
 	handler := tvShowHandler{tvShowService}
-
-	if assert.NoError(t, handler.GetAllTVShows(c)) {
-		assert.Equal(t, http.StatusOK, rec.Code)
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/tvshows"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := handler.GetTVShows(c)
+			// check error
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tt.expectedStatusCode, rec.Code)
+		})
 	}
 }
 
@@ -154,20 +197,17 @@ func TestUpdateAllTVShows(t *testing.T) {
 	{
 	}]
 			`
-			return &http.Response{
-				StatusCode: http.StatusOK,
-				Body:       ioutil.NopCloser(bytes.NewReader([]byte(json))),
-			}, nil
+			return mocks.TVmazeResponse(req, json), nil
 		},
 	}
 	tvShowRepo := mocks.NewMockTVShowRepository()
-	tvShowService := service.NewTVShowService(client, tvShowRepo)
+	tvShowService := service.NewTVShowService([]external.Provider{&tvmaze.Provider{Client: client}}, tvShowRepo, mocks.NewMockUnmatchedRepository(), mocks.NewMockEpisodeRepository())
 	common.Config = &common.Configuration{
 		TVShowDirectories: []string{"../service/testdata/three_shows/"},
 	}
 	e := echo.New()
 
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/tvshows/update/all", nil)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tvshows/update/all", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
@@ -177,3 +217,227 @@ func TestUpdateAllTVShows(t *testing.T) {
 		assert.Equal(t, http.StatusOK, rec.Code)
 	}
 }
+
+func TestGetUnmatchedItems(t *testing.T) {
+	// setup
+	client := &mocks.MockClient{}
+	tvShowService := service.NewTVShowService([]external.Provider{&tvmaze.Provider{Client: client}}, mocks.NewMockTVShowRepository(), mocks.NewMockUnmatchedRepository(), mocks.NewMockEpisodeRepository())
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/tvshows/unmatched", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	handler := tvShowHandler{tvShowService}
+
+	if assert.NoError(t, handler.GetUnmatchedItems(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+}
+
+func TestIgnoreUnmatchedItem(t *testing.T) {
+	// setup
+	client := &mocks.MockClient{}
+	tvShowService := service.NewTVShowService([]external.Provider{&tvmaze.Provider{Client: client}}, mocks.NewMockTVShowRepository(), mocks.NewMockUnmatchedRepository(), mocks.NewMockEpisodeRepository())
+	e := echo.New()
+
+	testCases := []struct {
+		name               string
+		id                 string
+		expectedStatusCode int
+		wantErr            bool
+	}{
+		{
+			name:               "Success",
+			id:                 "5f4bd6a4c6e9ab2e4f8b4567",
+			expectedStatusCode: 200,
+			wantErr:            false,
+		},
+		{
+			name:               "Non-existent item",
+			id:                 "5f4bd6a4c6e9ab2e4f8b4560",
+			expectedStatusCode: 404,
+			wantErr:            true,
+		},
+		{
+			name:               "Malformed id",
+			id:                 "the-office",
+			expectedStatusCode: 400,
+			wantErr:            true,
+		},
+	}
+
+	handler := tvShowHandler{tvShowService}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/tvshows/unmatched/:id/ignore")
+			c.SetParamNames("id")
+			c.SetParamValues(tt.id)
+
+			err := handler.IgnoreUnmatchedItem(c)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tt.expectedStatusCode, rec.Code)
+		})
+	}
+}
+
+func TestIgnoreUnmatchedItemSaveError(t *testing.T) {
+	unmatchedRepo := mocks.NewMockUnmatchedRepository()
+	unmatchedRepo.SaveErr = errors.New("connection refused")
+	tvShowService := service.NewTVShowService(nil, mocks.NewMockTVShowRepository(), unmatchedRepo, mocks.NewMockEpisodeRepository())
+	handler := tvShowHandler{tvShowService}
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetPath("/api/v1/tvshows/unmatched/:id/ignore")
+	c.SetParamNames("id")
+	c.SetParamValues("5f4bd6a4c6e9ab2e4f8b4567")
+
+	assert.NotNil(t, handler.IgnoreUnmatchedItem(c))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
+
+func TestAssignUnmatchedItem(t *testing.T) {
+	// setup
+	client := &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			json := `{"id": 526, "name": "The Office", "language": "English", "genres": ["Comedy"], "runtime": 30, "premiered": "2005-03-24", "rating": {"average": 8.5}, "image": {"original": "http://static.tvmaze.com/uploads/images/original_untouched/85/213184.jpg"}, "summary": "One of the best tv shows, no doubt"}`
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(json))),
+			}, nil
+		},
+	}
+	tvShowService := service.NewTVShowService([]external.Provider{&tvmaze.Provider{Client: client}}, mocks.NewMockTVShowRepository(), mocks.NewMockUnmatchedRepository(), mocks.NewMockEpisodeRepository())
+	e := echo.New()
+
+	testCases := []struct {
+		name               string
+		json               string
+		expectedStatusCode int
+		wantErr            bool
+	}{
+		{
+			name:               "Success",
+			json:               `{"provider": "tvmaze", "provider_id": "526"}`,
+			expectedStatusCode: 200,
+			wantErr:            false,
+		},
+		{
+			name:               "Invalid JSON",
+			json:               ``,
+			expectedStatusCode: 400,
+			wantErr:            true,
+		},
+		{
+			name:               "Missing provider ID",
+			json:               `{"provider": "tvmaze"}`,
+			expectedStatusCode: 400,
+			wantErr:            true,
+		},
+		{
+			name:               "Unknown provider",
+			json:               `{"provider": "thetvdb", "provider_id": "73244"}`,
+			expectedStatusCode: 400,
+			wantErr:            true,
+		},
+	}
+
+	handler := tvShowHandler{tvShowService}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.json))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/tvshows/unmatched/:id/assign")
+			c.SetParamNames("id")
+			c.SetParamValues("5f4bd6a4c6e9ab2e4f8b4567")
+
+			err := handler.AssignUnmatchedItem(c)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tt.expectedStatusCode, rec.Code)
+		})
+	}
+}
+
+func TestSetTVShowProvider(t *testing.T) {
+	// setup
+	provider := &mocks.MockProvider{
+		ProviderName: "tmdb",
+		TVShows: map[string]*models.TVShow{
+			"61222": {
+				Name:      "BoJack Horseman",
+				Language:  "English",
+				Genres:    []string{"Animation", "Comedy"},
+				Runtime:   25,
+				Premiered: "2014-08-22",
+				Rating:    8.5,
+				PosterURL: "https://image.tmdb.org/t/p/original/pB9L0jAnEQLMKgexqCEocEW8TA.jpg",
+				Summary:   "Meet the most beloved sitcom horse of the '90s, 20 years later.",
+			},
+		},
+	}
+	tvShowService := service.NewTVShowService([]external.Provider{provider}, mocks.NewMockTVShowRepository(), mocks.NewMockUnmatchedRepository(), mocks.NewMockEpisodeRepository())
+	e := echo.New()
+
+	testCases := []struct {
+		name               string
+		json               string
+		expectedStatusCode int
+		wantErr            bool
+	}{
+		{
+			name:               "Success",
+			json:               `{"name": "BoJack Horseman", "provider": "tmdb", "provider_id": "61222"}`,
+			expectedStatusCode: 200,
+			wantErr:            false,
+		},
+		{
+			name:               "Missing provider",
+			json:               `{"name": "BoJack Horseman"}`,
+			expectedStatusCode: 400,
+			wantErr:            true,
+		},
+		{
+			name:               "Unknown provider",
+			json:               `{"name": "BoJack Horseman", "provider": "thetvdb"}`,
+			expectedStatusCode: 400,
+			wantErr:            true,
+		},
+		{
+			name:               "Non-existent show",
+			json:               `{"name": "Silicon Valley", "provider": "tmdb"}`,
+			expectedStatusCode: 404,
+			wantErr:            true,
+		},
+	}
+
+	handler := tvShowHandler{tvShowService}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/tvshows/provider", strings.NewReader(tt.json))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := handler.SetTVShowProvider(c)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tt.expectedStatusCode, rec.Code)
+		})
+	}
+}
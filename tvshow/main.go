@@ -14,9 +14,13 @@ package main
 import (
 	"net/http"
 
+	authmw "github.com/0x113/x-media/auth/pkg/middleware"
 	"github.com/0x113/x-media/tvshow/common"
 	"github.com/0x113/x-media/tvshow/data"
 	"github.com/0x113/x-media/tvshow/databases"
+	"github.com/0x113/x-media/tvshow/external"
+	"github.com/0x113/x-media/tvshow/external/tmdb"
+	"github.com/0x113/x-media/tvshow/external/tvmaze"
 	"github.com/0x113/x-media/tvshow/handler"
 	"github.com/0x113/x-media/tvshow/service"
 
@@ -39,6 +43,12 @@ func (srv *Server) initServer() error {
 	if err := databases.Database.Init(); err != nil {
 		return err
 	}
+	if err := data.CreateTVShowIndexes(); err != nil {
+		log.Errorf("Unable to create tv show indexes, err: %v", err)
+	}
+	if err := data.CreateEpisodeIndexes(); err != nil {
+		log.Errorf("Unable to create episode indexes, err: %v", err)
+	}
 
 	// set up router
 	srv.router = echo.New()
@@ -54,9 +64,26 @@ func main() {
 	}
 
 	client := &http.Client{}
+	validator, err := authmw.NewValidator(client, authmw.Config{
+		Mode:        common.Config.AuthMode,
+		JWKSURL:     common.Config.AuthJWKSURL,
+		ValidateURL: common.Config.AuthValidateURL,
+		CacheTTL:    common.Config.AuthCacheTTL,
+		Issuer:      common.Config.AuthIssuer,
+		Audience:    common.Config.AuthAudience,
+	})
+	if err != nil {
+		log.Fatalf("Couldn't initialize token validator: %v", err)
+	}
+	providers := []external.Provider{&tvmaze.Provider{Client: client}}
+	if common.Config.TMDbAPIKey != "" {
+		providers = append(providers, &tmdb.Provider{Client: client})
+	}
 	tvShowRepository := data.NewMongoTVShowRepository()
-	tvShowService := service.NewTVShowService(client, tvShowRepository)
-	handler.NewTVShowHandler(srv.router, tvShowService)
+	unmatchedRepository := data.NewMongoUnmatchedRepository()
+	episodeRepository := data.NewMongoEpisodeRepository()
+	tvShowService := service.NewTVShowService(providers, tvShowRepository, unmatchedRepository, episodeRepository)
+	handler.NewTVShowHandler(srv.router, tvShowService, validator)
 
 	srv.router.Start(":" + common.Config.Port)
 }
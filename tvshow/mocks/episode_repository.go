@@ -0,0 +1,71 @@
+package mocks
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/0x113/x-media/tvshow/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockEpisodeRepository represents in-memory episode repository
+type MockEpisodeRepository struct {
+	episodes map[primitive.ObjectID]*models.Episode
+}
+
+// NewMockEpisodeRepository creates new MockEpisodeRepository
+func NewMockEpisodeRepository() *MockEpisodeRepository {
+	return &MockEpisodeRepository{map[primitive.ObjectID]*models.Episode{}}
+}
+
+// GetByID returns episode if exists
+func (r *MockEpisodeRepository) GetByID(id primitive.ObjectID) (*models.Episode, error) {
+	if episode, ok := r.episodes[id]; ok {
+		return episode, nil
+	}
+	return nil, fmt.Errorf("Couldn't find episode %s", id.Hex())
+}
+
+// GetByTVShowID returns all episodes of the tv show from memory
+func (r *MockEpisodeRepository) GetByTVShowID(tvShowID primitive.ObjectID) ([]*models.Episode, error) {
+	return r.find(func(episode *models.Episode) bool {
+		return episode.TVShowID == tvShowID
+	}), nil
+}
+
+// GetBySeason returns episodes of the tv show season from memory
+func (r *MockEpisodeRepository) GetBySeason(tvShowID primitive.ObjectID, season int) ([]*models.Episode, error) {
+	return r.find(func(episode *models.Episode) bool {
+		return episode.TVShowID == tvShowID && episode.Season == season
+	}), nil
+}
+
+func (r *MockEpisodeRepository) find(match func(episode *models.Episode) bool) []*models.Episode {
+	episodes := []*models.Episode{}
+	for _, episode := range r.episodes {
+		if match(episode) {
+			episodes = append(episodes, episode)
+		}
+	}
+	sort.Slice(episodes, func(i, j int) bool {
+		if episodes[i].Season != episodes[j].Season {
+			return episodes[i].Season < episodes[j].Season
+		}
+		return episodes[i].Number < episodes[j].Number
+	})
+	return episodes
+}
+
+// ReplaceByTVShowID replaces episodes of the tv show in memory
+func (r *MockEpisodeRepository) ReplaceByTVShowID(tvShowID primitive.ObjectID, episodes []*models.Episode) error {
+	for id, episode := range r.episodes {
+		if episode.TVShowID == tvShowID {
+			delete(r.episodes, id)
+		}
+	}
+	for _, episode := range episodes {
+		r.episodes[episode.ID] = episode
+	}
+	return nil
+}
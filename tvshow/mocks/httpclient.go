@@ -1,6 +1,13 @@
 package mocks
 
-import "net/http"
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+)
 
 // MockClient represents mocked http client
 type MockClient struct {
@@ -13,3 +20,33 @@ func (m *MockClient) Do(req *http.Request) (*http.Response, error) {
 	}
 	return &http.Response{}, nil
 }
+
+// TVmazeResponse responds to the TVmaze search with the provided search results
+// and to the show lookup with the matching show from the search results
+func TVmazeResponse(req *http.Request, searchJSON string) *http.Response {
+	if req.URL.Path == "/search/shows" {
+		return newResponse(http.StatusOK, searchJSON)
+	}
+
+	var results []struct {
+		Show json.RawMessage `json:"show"`
+	}
+	json.Unmarshal([]byte(searchJSON), &results)
+	for _, r := range results {
+		var show struct {
+			ID int `json:"id"`
+		}
+		json.Unmarshal(r.Show, &show)
+		if req.URL.Path == path.Join("/shows", strconv.Itoa(show.ID)) {
+			return newResponse(http.StatusOK, string(r.Show))
+		}
+	}
+	return newResponse(http.StatusNotFound, `{"name": "Not Found", "status": 404}`)
+}
+
+func newResponse(statusCode int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+	}
+}
@@ -0,0 +1,47 @@
+package mocks
+
+import (
+	"fmt"
+
+	"github.com/0x113/x-media/tvshow/models"
+)
+
+// MockProvider represents in-memory tv show metadata provider
+type MockProvider struct {
+	ProviderName string
+	TVShows      map[string]*models.TVShow
+	Searches     int
+}
+
+// Name returns the name of the provider
+func (p *MockProvider) Name() string {
+	return p.ProviderName
+}
+
+// Search returns tv shows with the provided name
+func (p *MockProvider) Search(title string) ([]*models.SearchResult, error) {
+	p.Searches++
+	var results []*models.SearchResult
+	for id, tvShow := range p.TVShows {
+		if tvShow.Name == title {
+			results = append(results, &models.SearchResult{
+				Provider:   p.ProviderName,
+				ProviderID: id,
+				Name:       tvShow.Name,
+			})
+		}
+	}
+	return results, nil
+}
+
+// GetTVShow returns copy of the tv show with the provided id
+func (p *MockProvider) GetTVShow(id string) (*models.TVShow, error) {
+	tvShow, ok := p.TVShows[id]
+	if !ok {
+		return nil, fmt.Errorf("Couldn't find tv show %s", id)
+	}
+	tvShowCopy := *tvShow
+	tvShowCopy.Provider = p.ProviderName
+	tvShowCopy.ProviderID = id
+	return &tvShowCopy, nil
+}
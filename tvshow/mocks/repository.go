@@ -2,6 +2,8 @@ package mocks
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/0x113/x-media/tvshow/models"
 )
@@ -45,20 +47,86 @@ func (r *MockTVShowRepository) GetByName(name string) (*models.TVShow, error) {
 	return nil, fmt.Errorf("Couldn't find show %s", name)
 }
 
-// Update existing show
+// GetByDirPath returns tv show matched with the directory if exists
+func (r *MockTVShowRepository) GetByDirPath(dirPath string) (*models.TVShow, error) {
+	for _, tvShow := range r.tvShows {
+		if tvShow.DirPath == dirPath {
+			return tvShow, nil
+		}
+	}
+	return nil, fmt.Errorf("Couldn't find show in %s", dirPath)
+}
+
+// Update existing show, the name can be changed as well
 func (r *MockTVShowRepository) Update(tvShow *models.TVShow) error {
-	if _, ok := r.tvShows[tvShow.Name]; !ok {
-		return fmt.Errorf("Couldn't find show %s", tvShow.Name)
+	for name, existingShow := range r.tvShows {
+		if name == tvShow.Name || (!existingShow.ID.IsZero() && existingShow.ID == tvShow.ID) {
+			delete(r.tvShows, name)
+			r.tvShows[tvShow.Name] = tvShow
+			return nil
+		}
 	}
-	r.tvShows[tvShow.Name] = tvShow
-	return nil
+	return fmt.Errorf("Couldn't find show %s", tvShow.Name)
+}
+
+// Find filters, sorts and paginates tv shows from memory
+func (r *MockTVShowRepository) Find(filter *models.TVShowFilter) ([]*models.TVShow, int64, error) {
+	tvShows := []*models.TVShow{}
+	for _, tvShow := range r.tvShows {
+		if filter.Genre != "" && !contains(tvShow.Genres, filter.Genre) {
+			continue
+		}
+		if filter.Language != "" && tvShow.Language != filter.Language {
+			continue
+		}
+		if filter.Status != "" && tvShow.Status != filter.Status {
+			continue
+		}
+		if filter.Network != "" && tvShow.Network != filter.Network {
+			continue
+		}
+		if filter.Year != 0 && !strings.HasPrefix(tvShow.Premiered, fmt.Sprintf("%04d-", filter.Year)) {
+			continue
+		}
+		tvShows = append(tvShows, tvShow)
+	}
+
+	less := func(i, j int) bool {
+		switch filter.SortBy {
+		case "rating":
+			return tvShows[i].Rating < tvShows[j].Rating
+		case "premiered":
+			return tvShows[i].Premiered < tvShows[j].Premiered
+		case "runtime":
+			return tvShows[i].Runtime < tvShows[j].Runtime
+		}
+		return tvShows[i].Name < tvShows[j].Name
+	}
+	sort.SliceStable(tvShows, func(i, j int) bool {
+		// the swapped arguments keep the equal elements in order, unlike !less(i, j)
+		if filter.SortDesc {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+
+	total := int64(len(tvShows))
+	start := (filter.Page - 1) * filter.PerPage
+	if start > len(tvShows) {
+		start = len(tvShows)
+	}
+	end := start + filter.PerPage
+	if end > len(tvShows) {
+		end = len(tvShows)
+	}
+	return tvShows[start:end], total, nil
 }
 
-// GetAll tv shows from memory
-func (r *MockTVShowRepository) GetAll() ([]*models.TVShow, error) {
-	var tvShows []*models.TVShow
-	for _, name := range r.tvShows {
-		tvShows = append(tvShows, name)
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
 	}
-	return tvShows, nil
+	return false
 }
@@ -0,0 +1,80 @@
+package mocks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/0x113/x-media/tvshow/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockUnmatchedRepository represents in-memory unmatched items repository
+type MockUnmatchedRepository struct {
+	items map[primitive.ObjectID]*models.UnmatchedItem
+	// SaveErr is returned by Save when it's set
+	SaveErr error
+}
+
+// NewMockUnmatchedRepository creates new MockUnmatchedRepository
+func NewMockUnmatchedRepository() *MockUnmatchedRepository {
+	var items = map[primitive.ObjectID]*models.UnmatchedItem{}
+	id, err := primitive.ObjectIDFromHex("5f4bd6a4c6e9ab2e4f8b4567")
+	if err != nil {
+		panic(err)
+	}
+	items[id] = &models.UnmatchedItem{
+		ID:        id,
+		DirPath:   "testdata/three_shows/The_Office",
+		Query:     "The Office",
+		Reason:    "Unable to find tv show with title: The Office",
+		CreatedAt: time.Date(2020, 8, 30, 15, 4, 5, 0, time.UTC),
+		UpdatedAt: time.Date(2020, 8, 30, 15, 4, 5, 0, time.UTC),
+	}
+	return &MockUnmatchedRepository{items: items}
+}
+
+// Save unmatched item in memory
+func (r *MockUnmatchedRepository) Save(item *models.UnmatchedItem) error {
+	if r.SaveErr != nil {
+		return r.SaveErr
+	}
+	r.items[item.ID] = item
+	return nil
+}
+
+// GetByID returns unmatched item if exists
+func (r *MockUnmatchedRepository) GetByID(id primitive.ObjectID) (*models.UnmatchedItem, error) {
+	if item, ok := r.items[id]; ok {
+		return item, nil
+	}
+	return nil, fmt.Errorf("Couldn't find unmatched item %s", id.Hex())
+}
+
+// GetByDirPath returns unmatched item by its directory path if exists
+func (r *MockUnmatchedRepository) GetByDirPath(dirPath string) (*models.UnmatchedItem, error) {
+	for _, item := range r.items {
+		if item.DirPath == dirPath {
+			return item, nil
+		}
+	}
+	return nil, fmt.Errorf("Couldn't find unmatched item %s", dirPath)
+}
+
+// GetAll unmatched items from memory
+func (r *MockUnmatchedRepository) GetAll() ([]*models.UnmatchedItem, error) {
+	var items []*models.UnmatchedItem
+	for _, item := range r.items {
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Delete unmatched item from memory
+func (r *MockUnmatchedRepository) Delete(id primitive.ObjectID) error {
+	if _, ok := r.items[id]; !ok {
+		return fmt.Errorf("Couldn't find unmatched item %s", id.Hex())
+	}
+	delete(r.items, id)
+	return nil
+}
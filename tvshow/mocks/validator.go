@@ -0,0 +1,18 @@
+package mocks
+
+import (
+	authmw "github.com/0x113/x-media/auth/pkg/middleware"
+)
+
+// MockValidator accepts the tokens from the Tokens map
+type MockValidator struct {
+	Tokens map[string]*authmw.AccessDetails
+}
+
+// Validate returns the access details of the known token
+func (m *MockValidator) Validate(token string) (*authmw.AccessDetails, error) {
+	if details, ok := m.Tokens[token]; ok {
+		return details, nil
+	}
+	return nil, authmw.ErrInvalidToken
+}
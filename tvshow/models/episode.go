@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Episode defines a video file of the tv show episode found on the local drive
+type Episode struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id" example:"5f4e2b9cc6e9ab2e4f8b4568"`
+	TVShowID  primitive.ObjectID `bson:"tv_show_id" json:"tv_show_id" example:"507f1f77bcf86cd799439011"`
+	Season    int                `bson:"season" json:"season" example:"1"`
+	Number    int                `bson:"number" json:"number" example:"3"`
+	FilePath  string             `bson:"file_path" json:"-"`
+	FileName  string             `bson:"file_name" json:"file_name" example:"BoJack.Horseman.S01E03.mkv"`
+	Size      int64              `bson:"size" json:"size" example:"367001600"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at" example:"2020-08-30T15:04:05Z"`
+}
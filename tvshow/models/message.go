@@ -2,5 +2,5 @@ package models
 
 // Message defines the response message
 type Message struct {
-	Message string `json:"message" example:"Successfully updated tv show`
+	Message string `json:"message" example:"Successfully updated tv show"`
 }
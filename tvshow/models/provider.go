@@ -0,0 +1,11 @@
+package models
+
+// SearchResult defines the tv show found by the metadata provider
+type SearchResult struct {
+	Provider   string
+	ProviderID string
+	Name       string
+	Language   string
+	Premiered  string
+	Score      float64
+}
@@ -0,0 +1,76 @@
+package models
+
+// TMDbTVSearchResponse information
+type TMDbTVSearchResponse struct {
+	Page         int                   `json:"page"`
+	TotalResults int                   `json:"total_results"`
+	TotalPages   int                   `json:"total_pages"`
+	Results      []*TMDbTVSearchResult `json:"results"`
+}
+
+// TMDbTVSearchResult information
+type TMDbTVSearchResult struct {
+	ID               int     `json:"id"`
+	Name             string  `json:"name"`
+	OriginalName     string  `json:"original_name"`
+	OriginalLanguage string  `json:"original_language"`
+	FirstAirDate     string  `json:"first_air_date"`
+	Popularity       float64 `json:"popularity"`
+	VoteAverage      float32 `json:"vote_average"`
+}
+
+// TMDbTVShow information
+type TMDbTVShow struct {
+	ID               int     `json:"id"`
+	Name             string  `json:"name"`
+	OriginalName     string  `json:"original_name"`
+	OriginalLanguage string  `json:"original_language"`
+	Type             string  `json:"type"`
+	Status           string  `json:"status"`
+	Homepage         string  `json:"homepage"`
+	Overview         string  `json:"overview"`
+	FirstAirDate     string  `json:"first_air_date"`
+	EpisodeRunTime   []int   `json:"episode_run_time"`
+	VoteAverage      float32 `json:"vote_average"`
+	PosterPath       string  `json:"poster_path"`
+
+	SpokenLanguages []struct {
+		EnglishName string `json:"english_name"`
+		ISO6391     string `json:"iso_639_1"`
+	} `json:"spoken_languages"`
+
+	Genres []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"genres"`
+
+	Networks []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"networks"`
+
+	Seasons []struct {
+		ID           int    `json:"id"`
+		SeasonNumber int    `json:"season_number"`
+		Name         string `json:"name"`
+		EpisodeCount int    `json:"episode_count"`
+		AirDate      string `json:"air_date"`
+		PosterPath   string `json:"poster_path"`
+	} `json:"seasons"`
+
+	// appended to the response with append_to_response=credits,external_ids
+	Credits struct {
+		Cast []struct {
+			ID          int    `json:"id"`
+			Name        string `json:"name"`
+			Character   string `json:"character"`
+			ProfilePath string `json:"profile_path"`
+		} `json:"cast"`
+	} `json:"credits"`
+
+	ExternalIDs struct {
+		IMDbID   string `json:"imdb_id"`
+		TVDbID   int    `json:"tvdb_id"`
+		TVRageID int    `json:"tvrage_id"`
+	} `json:"external_ids"`
+}
@@ -36,7 +36,10 @@ type TVmazeTVShow struct {
 			} `json:"country"`
 		} `json:"network"`
 
-		WebChannel interface{} `json:"webChannel"`
+		WebChannel struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+		} `json:"webChannel"`
 
 		Externals struct {
 			Tvrage  int    `json:"tvrage"`
@@ -61,5 +64,43 @@ type TVmazeTVShow struct {
 				Href string `json:"href"`
 			} `json:"previousepisode"`
 		} `json:"_links"`
+
+		// Embedded is returned only when embeds are requested
+		Embedded struct {
+			Cast    []*TVmazeCastMember `json:"cast"`
+			Seasons []*TVmazeSeason     `json:"seasons"`
+		} `json:"_embedded"`
 	} `json:"show"`
 }
+
+// TVmazeImage information
+type TVmazeImage struct {
+	Medium   string `json:"medium"`
+	Original string `json:"original"`
+}
+
+// TVmazeCastMember information
+type TVmazeCastMember struct {
+	Person struct {
+		ID    int          `json:"id"`
+		Name  string       `json:"name"`
+		Image *TVmazeImage `json:"image"`
+	} `json:"person"`
+
+	Character struct {
+		ID    int          `json:"id"`
+		Name  string       `json:"name"`
+		Image *TVmazeImage `json:"image"`
+	} `json:"character"`
+}
+
+// TVmazeSeason information
+type TVmazeSeason struct {
+	ID           int          `json:"id"`
+	Number       int          `json:"number"`
+	Name         string       `json:"name"`
+	EpisodeOrder int          `json:"episodeOrder"`
+	PremiereDate string       `json:"premiereDate"`
+	EndDate      string       `json:"endDate"`
+	Image        *TVmazeImage `json:"image"`
+}
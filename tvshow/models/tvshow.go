@@ -5,14 +5,73 @@ import "go.mongodb.org/mongo-driver/bson/primitive"
 // swagger:response tvShow
 // TVShow information
 type TVShow struct {
-	ID        primitive.ObjectID `bson:"_id" json:"id" validate:"omitempty" example:"507f1f77bcf86cd799439011"`
-	Name      string             `bson:"name" json:"name" validate:"required" example:"BoJack Horseman"`
-	Language  string             `bson:"language" json:"language" validate:"required" example:"English"`
-	Genres    []string           `bson:"genres" json:"genres" validate:"required" example:"Comedy,Drama"`
-	Runtime   int                `bson:"runtime" json:"runtime" validate:"required" example:25`
-	Premiered string             `bson:"premiered" json:"premiered" validate:"required" example:"2014-08-22"`
-	Rating    float32            `bson:"rating" json:"rating" validate:"required" example:"8.1"`
-	PosterURL string             `bson:"poster_url" json:"poster_url" validate:"required,url" example:"https://static.tvmaze.com/uploads/images/original_untouched/236/590384.jpg"`
-	Summary   string             `bson:"summary" json:"summary" validate:"required" example:"Meet the most beloved sitcom horse of the '90s, 20 years later."`
-	DirPath   string             `bson:"dir_path" json:"dir_path" validate:"required" example:"tvshows/BoJack Horseman"`
+	ID           primitive.ObjectID `bson:"_id" json:"id" validate:"omitempty" example:"507f1f77bcf86cd799439011"`
+	Provider     string             `bson:"provider" json:"provider" example:"tvmaze"`
+	ProviderID   string             `bson:"provider_id" json:"provider_id" example:"184"`
+	TVmazeID     int                `bson:"tvmaze_id" json:"tvmaze_id" example:"184"`
+	TMDbID       int                `bson:"tmdb_id" json:"tmdb_id" example:"61222"`
+	Name         string             `bson:"name" json:"name" validate:"required" example:"BoJack Horseman"`
+	Type         string             `bson:"type" json:"type" example:"Animation"`
+	Status       string             `bson:"status" json:"status" example:"Ended"`
+	Network      string             `bson:"network" json:"network" example:"Netflix"`
+	Language     string             `bson:"language" json:"language" validate:"required" example:"English"`
+	Genres       []string           `bson:"genres" json:"genres" validate:"required" example:"Comedy,Drama"`
+	Runtime      int                `bson:"runtime" json:"runtime" validate:"required" example:"25"`
+	Premiered    string             `bson:"premiered" json:"premiered" validate:"required" example:"2014-08-22"`
+	Rating       float32            `bson:"rating" json:"rating" validate:"omitempty,min=0,max=10" example:"8.1"`
+	PosterURL    string             `bson:"poster_url" json:"poster_url" validate:"required,url" example:"https://static.tvmaze.com/uploads/images/original_untouched/236/590384.jpg"`
+	Summary      string             `bson:"summary" json:"summary" validate:"required" example:"Meet the most beloved sitcom horse of the '90s, 20 years later."`
+	OfficialSite string             `bson:"official_site" json:"official_site" example:"https://www.netflix.com/title/70298933"`
+	Schedule     *Schedule          `bson:"schedule" json:"schedule"`
+	Externals    *Externals         `bson:"externals" json:"externals"`
+	Cast         []*CastMember      `bson:"cast" json:"cast,omitempty"`
+	Seasons      []*Season          `bson:"seasons" json:"seasons,omitempty"`
+	DirPath      string             `bson:"dir_path" json:"dir_path" validate:"required" example:"tvshows/BoJack Horseman"`
+}
+
+// Schedule defines when new episodes of the tv show are aired
+type Schedule struct {
+	Time string   `bson:"time" json:"time" example:"21:00"`
+	Days []string `bson:"days" json:"days" example:"Friday"`
+}
+
+// Externals defines ids of the tv show in the other databases
+type Externals struct {
+	TVRage  int    `bson:"tvrage" json:"tvrage" example:"37394"`
+	TheTVDB int    `bson:"thetvdb" json:"thetvdb" example:"282254"`
+	IMDb    string `bson:"imdb" json:"imdb" example:"tt3398228"`
+}
+
+// CastMember defines an actor from the main cast of the tv show
+// along with the character played
+type CastMember struct {
+	TVmazePersonID    int    `bson:"tvmaze_person_id" json:"tvmaze_person_id" example:"20962"`
+	Name              string `bson:"name" json:"name" example:"Will Arnett"`
+	ImageURL          string `bson:"image_url" json:"image_url" example:"https://static.tvmaze.com/uploads/images/original_untouched/1/3604.jpg"`
+	CharacterName     string `bson:"character_name" json:"character_name" example:"BoJack Horseman"`
+	CharacterImageURL string `bson:"character_image_url" json:"character_image_url" example:"https://static.tvmaze.com/uploads/images/original_untouched/1/4282.jpg"`
+}
+
+// Season defines the tv show season
+type Season struct {
+	Number       int    `bson:"number" json:"number" example:"1"`
+	Name         string `bson:"name" json:"name" example:""`
+	EpisodeCount int    `bson:"episode_count" json:"episode_count" example:"12"`
+	PremiereDate string `bson:"premiere_date" json:"premiere_date" example:"2014-08-22"`
+	EndDate      string `bson:"end_date" json:"end_date" example:"2014-08-22"`
+	PosterURL    string `bson:"poster_url" json:"poster_url" example:"https://static.tvmaze.com/uploads/images/original_untouched/24/60941.jpg"`
+}
+
+// TVShowFilter defines filtering, sorting and pagination
+// options for the tv show listing
+type TVShowFilter struct {
+	Genre    string
+	Language string
+	Status   string
+	Network  string
+	Year     int
+	SortBy   string
+	SortDesc bool
+	Page     int
+	PerPage  int
 }
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UnmatchedItem defines a tv show directory which couldn't be matched with
+// any of the metadata providers or couldn't be saved to the database. It waits in the review
+// queue until it's retried, ignored or assigned manually.
+type UnmatchedItem struct {
+	ID         primitive.ObjectID    `bson:"_id" json:"id" example:"5f4bd6a4c6e9ab2e4f8b4567"`
+	DirPath    string                `bson:"dir_path" json:"dir_path" example:"tvshows/BoJack Horseman"`
+	Query      string                `bson:"query" json:"query" example:"BoJack Horseman"`
+	Reason     string                `bson:"reason" json:"reason" example:"Couldn't validate tv show [dir=BoJack Horseman]"`
+	Candidates []*UnmatchedCandidate `bson:"candidates" json:"candidates"`
+	Ignored    bool                  `bson:"ignored" json:"ignored" example:"false"`
+	CreatedAt  time.Time             `bson:"created_at" json:"created_at" example:"2020-08-30T15:04:05Z"`
+	UpdatedAt  time.Time             `bson:"updated_at" json:"updated_at" example:"2020-08-30T15:04:05Z"`
+}
+
+// UnmatchedCandidate defines one of the metadata provider search results for the unmatched item
+type UnmatchedCandidate struct {
+	Provider   string  `bson:"provider" json:"provider" example:"tvmaze"`
+	ProviderID string  `bson:"provider_id" json:"provider_id" example:"184"`
+	Name       string  `bson:"name" json:"name" example:"BoJack Horseman"`
+	Language   string  `bson:"language" json:"language" example:"English"`
+	Premiered  string  `bson:"premiered" json:"premiered" example:"2014-08-22"`
+	Score      float64 `bson:"score" json:"score" example:"17.8"`
+}
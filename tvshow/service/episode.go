@@ -0,0 +1,212 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0x113/x-media/tvshow/common"
+	"github.com/0x113/x-media/tvshow/models"
+
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrEpisodeNotFound is returned when the episode or season doesn't exist
+	ErrEpisodeNotFound = errors.New("Episode not found")
+	// ErrPathNotAllowed is returned when the episode file is outside of the tv show directories
+	ErrPathNotAllowed = errors.New("Episode file is not in the tv show directories")
+)
+
+// videoExtensions defines which files are indexed as episodes
+var videoExtensions = map[string]bool{
+	".mkv":  true,
+	".mp4":  true,
+	".m4v":  true,
+	".avi":  true,
+	".mov":  true,
+	".webm": true,
+	".ts":   true,
+}
+
+// episodePatterns match season and episode numbers in the file name,
+// e.g. "The.Office.S02E05.mkv" or "The Office 2x05.mp4"
+var episodePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)s(\d{1,2})[ ._-]*e(\d{1,3})`),
+	regexp.MustCompile(`(?i)(?:^|[^a-z0-9])(\d{1,2})x(\d{2,3})(?:[^0-9]|$)`),
+}
+
+// GetEpisodes returns all indexed episodes of the tv show
+func (s *tvShowService) GetEpisodes(tvShowID string) ([]*models.Episode, error) {
+	showID, err := primitive.ObjectIDFromHex(tvShowID)
+	if err != nil {
+		log.Debugf("Couldn't convert [%s] to the ObjectID; err: %v", tvShowID, err)
+		return nil, fmt.Errorf("%w: invalid tv show id %s", ErrEpisodeNotFound, tvShowID)
+	}
+
+	episodes, err := s.episodeRepo.GetByTVShowID(showID)
+	if err != nil {
+		log.Debugf("Couldn't get episodes of the tv show [%s]; err: %v", tvShowID, err)
+		return nil, fmt.Errorf("Couldn't get episodes from the database")
+	}
+	return episodes, nil
+}
+
+// GetEpisode returns the episode if its file is contained in the tv show directories
+func (s *tvShowService) GetEpisode(id string) (*models.Episode, error) {
+	episodeID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		log.Debugf("Couldn't convert [%s] to the ObjectID; err: %v", id, err)
+		return nil, fmt.Errorf("%w: invalid episode id %s", ErrEpisodeNotFound, id)
+	}
+
+	episode, err := s.episodeRepo.GetByID(episodeID)
+	if err != nil {
+		log.Debugf("Couldn't get episode [%s] from the database; err: %v", id, err)
+		return nil, fmt.Errorf("%w: %s", ErrEpisodeNotFound, id)
+	}
+	if !isInTVShowDirectories(episode.FilePath) {
+		log.Warnf("Episode [%s] file [%s] is outside of the tv show directories", id, episode.FilePath)
+		return nil, ErrPathNotAllowed
+	}
+
+	return episode, nil
+}
+
+// GetSeasonEpisodes returns episodes of the tv show season if all
+// of their files are contained in the tv show directories
+func (s *tvShowService) GetSeasonEpisodes(tvShowID string, season int) ([]*models.Episode, error) {
+	showID, err := primitive.ObjectIDFromHex(tvShowID)
+	if err != nil {
+		log.Debugf("Couldn't convert [%s] to the ObjectID; err: %v", tvShowID, err)
+		return nil, fmt.Errorf("%w: invalid tv show id %s", ErrEpisodeNotFound, tvShowID)
+	}
+
+	episodes, err := s.episodeRepo.GetBySeason(showID, season)
+	if err != nil {
+		log.Debugf("Couldn't get season [%d] of the tv show [%s]; err: %v", season, tvShowID, err)
+		return nil, fmt.Errorf("Couldn't get episodes from the database")
+	}
+	if len(episodes) == 0 {
+		return nil, fmt.Errorf("%w: season %d of the tv show %s", ErrEpisodeNotFound, season, tvShowID)
+	}
+	for _, episode := range episodes {
+		if !isInTVShowDirectories(episode.FilePath) {
+			log.Warnf("Episode [%s] file [%s] is outside of the tv show directories", episode.ID.Hex(), episode.FilePath)
+			return nil, ErrPathNotAllowed
+		}
+	}
+
+	return episodes, nil
+}
+
+// indexEpisodes walks through the tv show directory and saves every video
+// file with the season and episode number in its name. Already indexed
+// episodes keep their ids.
+func (s *tvShowService) indexEpisodes(tvShow *models.TVShow) error {
+	existingEpisodes, err := s.episodeRepo.GetByTVShowID(tvShow.ID)
+	if err != nil {
+		return err
+	}
+	existingIDs := make(map[string]primitive.ObjectID)
+	for _, episode := range existingEpisodes {
+		existingIDs[episode.FilePath] = episode.ID
+	}
+
+	episodes := []*models.Episode{}
+	err = filepath.Walk(tvShow.DirPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !videoExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		season, number, ok := parseEpisodeNumber(info.Name())
+		if !ok {
+			log.Debugf("Couldn't find episode number in [%s]", path)
+			return nil
+		}
+
+		id, ok := existingIDs[path]
+		if !ok {
+			id = primitive.NewObjectID()
+		}
+		episodes = append(episodes, &models.Episode{
+			ID:        id,
+			TVShowID:  tvShow.ID,
+			Season:    season,
+			Number:    number,
+			FilePath:  path,
+			FileName:  info.Name(),
+			Size:      info.Size(),
+			UpdatedAt: time.Now(),
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.episodeRepo.ReplaceByTVShowID(tvShow.ID, episodes); err != nil {
+		return err
+	}
+	log.Infof("Successfully indexed %d episodes of the tv show [%s]", len(episodes), tvShow.Name)
+	return nil
+}
+
+// parseEpisodeNumber returns season and episode number from the file name
+func parseEpisodeNumber(fileName string) (int, int, bool) {
+	for _, pattern := range episodePatterns {
+		match := pattern.FindStringSubmatch(fileName)
+		if match == nil {
+			continue
+		}
+		season, _ := strconv.Atoi(match[1])
+		number, _ := strconv.Atoi(match[2])
+		return season, number, true
+	}
+	return 0, 0, false
+}
+
+// isInTVShowDirectories checks if the file, after resolving symlinks,
+// is located inside one of the configured tv show directories
+func isInTVShowDirectories(path string) bool {
+	for _, dir := range common.Config.TVShowDirectories {
+		if isInDirectory(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// isInDirectory checks if the path, after resolving symlinks, is located inside the directory
+func isInDirectory(path, dir string) bool {
+	realPath, err := resolvePath(path)
+	if err != nil {
+		return false
+	}
+	realDir, err := resolvePath(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(realDir, realPath)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolvePath returns absolute path with all symlinks evaluated
+func resolvePath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(absPath)
+}
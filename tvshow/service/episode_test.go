@@ -0,0 +1,170 @@
+package service_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/0x113/x-media/tvshow/common"
+	"github.com/0x113/x-media/tvshow/mocks"
+	"github.com/0x113/x-media/tvshow/models"
+	"github.com/0x113/x-media/tvshow/service"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// createFiles creates empty files in the directory
+func createFiles(dir string, names ...string) error {
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, []byte(name), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (suite *TVShowServiceTestSuite) TestIndexEpisodes() {
+	suite.client = &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			show := `{"id": 526, "name": "The Office", "language": "English", "genres": ["Comedy"], "runtime": 30, "premiered": "2005-03-24", "rating": {"average": 8.5}, "image": {"original": "http://static.tvmaze.com/uploads/images/original_untouched/85/213184.jpg"}, "summary": "One of the best tv shows, no doubt"}`
+			json := `[{"score": 25.4, "show": ` + show + `}]`
+			if req.URL.Path == "/shows/526" {
+				json = show
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(json))),
+			}, nil
+		},
+	}
+	suite.tvShowService = service.NewTVShowService(newProviders(suite.client), suite.tvShowRepo, suite.unmatchedRepo, suite.episodeRepo)
+
+	library, err := ioutil.TempDir("", "tvshows-*")
+	suite.Nil(err)
+	defer os.RemoveAll(library)
+	showDir := filepath.Join(library, "The_Office")
+	suite.Nil(createFiles(showDir,
+		"The.Office.S01E02.mkv",
+		"The.Office.S01E01.mkv",
+		"Season 2/The Office 2x03.mp4",
+		"Season 2/The Office 2x03.srt",
+		"Extras/Bloopers.mkv",
+	))
+	common.Config = &common.Configuration{
+		TVShowDirectories: []string{library},
+	}
+
+	var mutex sync.Mutex
+	tvShow, err := suite.tvShowService.UpdateTVShow(showDir, &mutex)
+	suite.Nil(err)
+
+	episodes, err := suite.tvShowService.GetEpisodes(tvShow.ID.Hex())
+	suite.Nil(err)
+	if suite.Len(episodes, 3) {
+		suite.Equal([]int{1, 1}, []int{episodes[0].Season, episodes[0].Number})
+		suite.Equal([]int{1, 2}, []int{episodes[1].Season, episodes[1].Number})
+		suite.Equal([]int{2, 3}, []int{episodes[2].Season, episodes[2].Number})
+		suite.Equal("The Office 2x03.mp4", episodes[2].FileName)
+	}
+
+	// episodes keep their ids after the next update
+	_, err = suite.tvShowService.UpdateTVShow(showDir, &mutex)
+	suite.Nil(err)
+	reindexedEpisodes, err := suite.tvShowService.GetEpisodes(tvShow.ID.Hex())
+	suite.Nil(err)
+	suite.Equal(episodes[0].ID, reindexedEpisodes[0].ID)
+
+	episode, err := suite.tvShowService.GetEpisode(episodes[0].ID.Hex())
+	suite.Nil(err)
+	suite.Equal(episodes[0].FilePath, episode.FilePath)
+
+	seasonEpisodes, err := suite.tvShowService.GetSeasonEpisodes(tvShow.ID.Hex(), 1)
+	suite.Nil(err)
+	suite.Len(seasonEpisodes, 2)
+
+	_, err = suite.tvShowService.GetSeasonEpisodes(tvShow.ID.Hex(), 5)
+	suite.True(errors.Is(err, service.ErrEpisodeNotFound))
+}
+
+func (suite *TVShowServiceTestSuite) TestGetEpisodePathContainment() {
+	suite.client = &mocks.MockClient{}
+	suite.tvShowService = service.NewTVShowService(newProviders(suite.client), suite.tvShowRepo, suite.unmatchedRepo, suite.episodeRepo)
+
+	library, err := ioutil.TempDir("", "tvshows-*")
+	suite.Nil(err)
+	defer os.RemoveAll(library)
+	outside, err := ioutil.TempDir("", "outside-*")
+	suite.Nil(err)
+	defer os.RemoveAll(outside)
+	suite.Nil(createFiles(library, "Dark/Dark.S01E01.mkv"))
+	suite.Nil(createFiles(outside, "secret.mkv"))
+	suite.Nil(os.Symlink(filepath.Join(outside, "secret.mkv"), filepath.Join(library, "Dark", "Dark.S01E02.mkv")))
+	common.Config = &common.Configuration{
+		TVShowDirectories: []string{library},
+	}
+
+	tvShowID := primitive.NewObjectID()
+	episodes := []*models.Episode{
+		{ID: primitive.NewObjectID(), TVShowID: tvShowID, Season: 1, Number: 1, FilePath: filepath.Join(library, "Dark", "Dark.S01E01.mkv")},
+		{ID: primitive.NewObjectID(), TVShowID: tvShowID, Season: 1, Number: 2, FilePath: filepath.Join(library, "Dark", "Dark.S01E02.mkv")},
+		{ID: primitive.NewObjectID(), TVShowID: tvShowID, Season: 2, Number: 1, FilePath: filepath.Join(library, "..", filepath.Base(outside), "secret.mkv")},
+	}
+	suite.Nil(suite.episodeRepo.ReplaceByTVShowID(tvShowID, episodes))
+
+	testCases := []struct {
+		name        string
+		id          string
+		expectedErr error
+	}{
+		{
+			name:        "Success",
+			id:          episodes[0].ID.Hex(),
+			expectedErr: nil,
+		},
+		{
+			name:        "Symlink outside of the tv show directories",
+			id:          episodes[1].ID.Hex(),
+			expectedErr: service.ErrPathNotAllowed,
+		},
+		{
+			name:        "Relative path outside of the tv show directories",
+			id:          episodes[2].ID.Hex(),
+			expectedErr: service.ErrPathNotAllowed,
+		},
+		{
+			name:        "Non-existent episode",
+			id:          primitive.NewObjectID().Hex(),
+			expectedErr: service.ErrEpisodeNotFound,
+		},
+		{
+			name:        "Invalid id",
+			id:          "123",
+			expectedErr: service.ErrEpisodeNotFound,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			episode, err := suite.tvShowService.GetEpisode(tt.id)
+			if tt.expectedErr != nil {
+				suite.True(errors.Is(err, tt.expectedErr))
+				suite.Nil(episode)
+			} else {
+				suite.Nil(err)
+				suite.Equal(tt.id, episode.ID.Hex())
+			}
+		})
+	}
+
+	// whole season is rejected if any of the episodes is outside
+	_, err = suite.tvShowService.GetSeasonEpisodes(tvShowID.Hex(), 1)
+	suite.True(errors.Is(err, service.ErrPathNotAllowed))
+}
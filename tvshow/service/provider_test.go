@@ -0,0 +1,183 @@
+package service_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/0x113/x-media/tvshow/common"
+	"github.com/0x113/x-media/tvshow/external"
+	"github.com/0x113/x-media/tvshow/mocks"
+	"github.com/0x113/x-media/tvshow/models"
+	"github.com/0x113/x-media/tvshow/service"
+)
+
+// newTVShow returns valid tv show with the provided name
+func newTVShow(name, language string) *models.TVShow {
+	return &models.TVShow{
+		Name:      name,
+		Language:  language,
+		Genres:    []string{"Anime"},
+		Runtime:   24,
+		Premiered: "2013-04-07",
+		Rating:    8.9,
+		PosterURL: "https://image.tmdb.org/t/p/original/poster.jpg",
+		Summary:   "Summary",
+	}
+}
+
+func (suite *TVShowServiceTestSuite) TestUpdateTVShowProviders() {
+	library, err := ioutil.TempDir("", "tvshows-*")
+	suite.Nil(err)
+	defer os.RemoveAll(library)
+	anime := filepath.Join(library, "anime")
+	suite.Nil(os.MkdirAll(filepath.Join(anime, "Attack_on_Titan"), 0755))
+	suite.Nil(os.MkdirAll(filepath.Join(library, "The_Office"), 0755))
+
+	testCases := []struct {
+		name             string
+		dirPath          string
+		config           *common.Configuration
+		tvMazeShows      map[string]*models.TVShow
+		tmdbShows        map[string]*models.TVShow
+		expectedProvider string
+		wantErr          bool
+	}{
+		{
+			name:             "Fallback to the next provider",
+			dirPath:          filepath.Join(anime, "Attack_on_Titan"),
+			config:           &common.Configuration{},
+			tvMazeShows:      map[string]*models.TVShow{},
+			tmdbShows:        map[string]*models.TVShow{"1429": newTVShow("Attack on Titan", "Japanese")},
+			expectedProvider: "tmdb",
+			wantErr:          false,
+		},
+		{
+			name:             "Default order",
+			dirPath:          filepath.Join(anime, "Attack_on_Titan"),
+			config:           &common.Configuration{},
+			tvMazeShows:      map[string]*models.TVShow{"919": newTVShow("Attack on Titan", "Japanese")},
+			tmdbShows:        map[string]*models.TVShow{"1429": newTVShow("Attack on Titan", "Japanese")},
+			expectedProvider: "tvmaze",
+			wantErr:          false,
+		},
+		{
+			name:    "Configured order",
+			dirPath: filepath.Join(anime, "Attack_on_Titan"),
+			config: &common.Configuration{
+				MetadataProviders: []string{"tmdb", "tvmaze"},
+			},
+			tvMazeShows:      map[string]*models.TVShow{"919": newTVShow("Attack on Titan", "Japanese")},
+			tmdbShows:        map[string]*models.TVShow{"1429": newTVShow("Attack on Titan", "Japanese")},
+			expectedProvider: "tmdb",
+			wantErr:          false,
+		},
+		{
+			name:    "Library order",
+			dirPath: filepath.Join(anime, "Attack_on_Titan"),
+			config: &common.Configuration{
+				MetadataProviders: []string{"tvmaze", "tmdb"},
+				LibraryProviders:  map[string][]string{anime: {"tmdb"}},
+			},
+			tvMazeShows:      map[string]*models.TVShow{"919": newTVShow("Attack on Titan", "Japanese")},
+			tmdbShows:        map[string]*models.TVShow{"1429": newTVShow("Attack on Titan", "Japanese")},
+			expectedProvider: "tmdb",
+			wantErr:          false,
+		},
+		{
+			name:    "Library order doesn't apply outside of the library",
+			dirPath: filepath.Join(library, "The_Office"),
+			config: &common.Configuration{
+				LibraryProviders: map[string][]string{anime: {"tmdb"}},
+			},
+			tvMazeShows:      map[string]*models.TVShow{"526": newTVShow("The Office", "English")},
+			tmdbShows:        map[string]*models.TVShow{"2316": newTVShow("The Office", "English")},
+			expectedProvider: "tvmaze",
+			wantErr:          false,
+		},
+		{
+			name:    "Unknown provider is skipped",
+			dirPath: filepath.Join(anime, "Attack_on_Titan"),
+			config: &common.Configuration{
+				MetadataProviders: []string{"thetvdb"},
+			},
+			tvMazeShows: map[string]*models.TVShow{"919": newTVShow("Attack on Titan", "Japanese")},
+			tmdbShows:   map[string]*models.TVShow{"1429": newTVShow("Attack on Titan", "Japanese")},
+			wantErr:     true,
+		},
+		{
+			name:        "Not found by any provider",
+			dirPath:     filepath.Join(anime, "Attack_on_Titan"),
+			config:      &common.Configuration{},
+			tvMazeShows: map[string]*models.TVShow{},
+			tmdbShows:   map[string]*models.TVShow{},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.SetupTest()
+		common.Config = tt.config
+		providers := []external.Provider{
+			&mocks.MockProvider{ProviderName: "tvmaze", TVShows: tt.tvMazeShows},
+			&mocks.MockProvider{ProviderName: "tmdb", TVShows: tt.tmdbShows},
+		}
+		suite.tvShowService = service.NewTVShowService(providers, suite.tvShowRepo, suite.unmatchedRepo, suite.episodeRepo)
+		suite.Run(tt.name, func() {
+			var mutex sync.Mutex
+			tvShow, err := suite.tvShowService.UpdateTVShow(tt.dirPath, &mutex)
+			if tt.wantErr {
+				suite.NotNil(err)
+				suite.Nil(tvShow)
+				_, err := suite.unmatchedRepo.GetByDirPath(tt.dirPath)
+				suite.Nil(err)
+			} else {
+				suite.Nil(err)
+				suite.Equal(tt.expectedProvider, tvShow.Provider)
+			}
+		})
+	}
+}
+
+func (suite *TVShowServiceTestSuite) TestUpdateTVShowKeepsProvider() {
+	library, err := ioutil.TempDir("", "tvshows-*")
+	suite.Nil(err)
+	defer os.RemoveAll(library)
+	dirPath := filepath.Join(library, "Attack_on_Titan")
+	suite.Nil(os.MkdirAll(dirPath, 0755))
+	common.Config = &common.Configuration{}
+
+	tvMaze := &mocks.MockProvider{ProviderName: "tvmaze", TVShows: map[string]*models.TVShow{"919": newTVShow("Attack on Titan", "Japanese")}}
+	tmdb := &mocks.MockProvider{ProviderName: "tmdb", TVShows: map[string]*models.TVShow{"1429": newTVShow("Attack on Titan", "Japanese")}}
+	suite.tvShowService = service.NewTVShowService([]external.Provider{tvMaze, tmdb}, suite.tvShowRepo, suite.unmatchedRepo, suite.episodeRepo)
+
+	var mutex sync.Mutex
+	tvShow, err := suite.tvShowService.UpdateTVShow(dirPath, &mutex)
+	suite.Nil(err)
+	suite.Equal("tvmaze", tvShow.Provider)
+
+	// the show is switched to TMDb manually
+	tvShow, err = suite.tvShowService.SetTVShowProvider("Attack on Titan", "tmdb", "1429")
+	suite.Nil(err)
+	suite.Equal("tmdb", tvShow.Provider)
+	suite.Equal("1429", tvShow.ProviderID)
+
+	// next update uses TMDb first and doesn't search again
+	tmdb.Searches = 0
+	tvShow, err = suite.tvShowService.UpdateTVShow(dirPath, &mutex)
+	suite.Nil(err)
+	suite.Equal("tmdb", tvShow.Provider)
+	suite.Equal(0, tmdb.Searches)
+
+	savedShow, err := suite.tvShowRepo.GetByDirPath(dirPath)
+	suite.Nil(err)
+	suite.Equal("tmdb", savedShow.Provider)
+
+	// errors
+	_, err = suite.tvShowService.SetTVShowProvider("Attack on Titan", "thetvdb", "")
+	suite.True(errors.Is(err, service.ErrUnknownProvider))
+	_, err = suite.tvShowService.SetTVShowProvider("Silicon Valley", "tmdb", "")
+	suite.True(errors.Is(err, service.ErrTVShowNotFound))
+}
@@ -1,17 +1,18 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/0x113/x-media/tvshow/common"
 	"github.com/0x113/x-media/tvshow/data"
-	"github.com/0x113/x-media/tvshow/external/tvmaze"
+	"github.com/0x113/x-media/tvshow/external"
 	"github.com/0x113/x-media/tvshow/models"
-	"github.com/0x113/x-media/tvshow/utils"
 
 	"github.com/go-playground/validator/v10"
 	log "github.com/sirupsen/logrus"
@@ -24,17 +25,68 @@ type TVShowService interface {
 	UpdateAllTVShows() (map[string]string, map[string]string)
 	UpdateTVShow(name string, mutex *sync.Mutex) (*models.TVShow, error)
 	GetTVShowByName(name string) (*models.TVShow, error)
-	GetAllTVShows() ([]*models.TVShow, error)
+	GetTVShows(filter *models.TVShowFilter) ([]*models.TVShow, int64, error)
+	GetUnmatchedItems() ([]*models.UnmatchedItem, error)
+	RetryUnmatchedItem(id string) (*models.TVShow, error)
+	IgnoreUnmatchedItem(id string) (*models.UnmatchedItem, error)
+	AssignUnmatchedItem(id, provider, providerID string) (*models.TVShow, error)
+	SetTVShowProvider(name, provider, providerID string) (*models.TVShow, error)
+	GetEpisodes(tvShowID string) ([]*models.Episode, error)
+	GetEpisode(id string) (*models.Episode, error)
+	GetSeasonEpisodes(tvShowID string, season int) ([]*models.Episode, error)
 }
 
+// maxCandidates defines how many search results of each metadata
+// provider are stored with the unmatched item
+const maxCandidates = 5
+
+// default and maximum page sizes of the tv show listing
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// sortFields maps sort keys accepted by the listing to the database fields
+var sortFields = map[string]string{
+	"name":      "name",
+	"rating":    "rating",
+	"premiered": "premiered",
+	"runtime":   "runtime",
+}
+
+// ErrInvalidFilter is returned when the tv show listing filter is malformed
+var ErrInvalidFilter = errors.New("Invalid tv show filter")
+
+// ErrTVShowNotFound is returned when the tv show doesn't exist
+var ErrTVShowNotFound = errors.New("TV show not found")
+
+// ErrUnknownProvider is returned when the metadata provider isn't registered
+var ErrUnknownProvider = errors.New("Unknown metadata provider")
+
+// ErrInvalidUnmatchedID is returned when the unmatched item id isn't a valid ObjectID
+var ErrInvalidUnmatchedID = errors.New("Invalid unmatched item id")
+
+// ErrUnmatchedNotFound is returned when the unmatched item doesn't exist
+var ErrUnmatchedNotFound = errors.New("Unmatched item not found")
+
 type tvShowService struct {
-	client     utils.HttpClient
-	tvShowRepo data.TVShowRepository
+	providers     map[string]external.Provider
+	providerOrder []string
+	tvShowRepo    data.TVShowRepository
+	unmatchedRepo data.UnmatchedRepository
+	episodeRepo   data.EpisodeRepository
 }
 
-// NewTVShowService creates new instance of TVShowService
-func NewTVShowService(client utils.HttpClient, tvShowRepo data.TVShowRepository) TVShowService {
-	return &tvShowService{client, tvShowRepo}
+// NewTVShowService creates new instance of TVShowService. Unless configured
+// otherwise, the metadata providers are tried in the provided order.
+func NewTVShowService(providers []external.Provider, tvShowRepo data.TVShowRepository, unmatchedRepo data.UnmatchedRepository, episodeRepo data.EpisodeRepository) TVShowService {
+	providersMap := make(map[string]external.Provider)
+	var providerOrder []string
+	for _, provider := range providers {
+		providersMap[provider.Name()] = provider
+		providerOrder = append(providerOrder, provider.Name())
+	}
+	return &tvShowService{providersMap, providerOrder, tvShowRepo, unmatchedRepo, episodeRepo}
 }
 
 // Save calls the db layer to save tv show
@@ -55,60 +107,163 @@ func (s *tvShowService) Save(tvShow *models.TVShow) error {
 }
 
 // UpdateTVShow reads directory names, removes special char like "_,/"
-// and calls tvmaze api to get data
+// and calls the metadata providers to get data. Providers are tried one by
+// one until the tv show is matched. Directories which couldn't be matched
+// are stored in the unmatched items queue.
 func (s *tvShowService) UpdateTVShow(dirPath string, mutex *sync.Mutex) (*models.TVShow, error) {
 	nameSplit := strings.Split(dirPath, "/")
 	name := createName(nameSplit[len(nameSplit)-1])
-	// get tv show data from TVmaze API
-	tvMazeInfo, err := tvmaze.GetTVmazeTVShowInfo(s.client, name)
+
+	mutex.Lock()
+	existingShow, _ := s.tvShowRepo.GetByDirPath(dirPath) // error means the directory isn't matched yet
+	mutex.Unlock()
+
+	var reasons []string
+	var candidates []*models.SearchResult
+	for _, provider := range s.getProviders(dirPath, existingShow) {
+		tvShow, results, err := matchTVShow(provider, name, existingShow)
+		if len(results) > maxCandidates {
+			results = results[:maxCandidates]
+		}
+		candidates = append(candidates, results...)
+		if err == nil {
+			tvShow, err = s.saveTVShow(tvShow, dirPath, mutex)
+		}
+		if err != nil {
+			log.Debugf("Couldn't match tv show [%s] with provider [%s]; err: %v", dirPath, provider.Name(), err)
+			reasons = append(reasons, fmt.Sprintf("%s: %v", provider.Name(), err))
+			continue
+		}
+
+		s.removeUnmatchedItem(dirPath, mutex)
+		return tvShow, nil
+	}
+
+	if len(reasons) == 0 {
+		reasons = append(reasons, "no metadata providers configured")
+	}
+	err := fmt.Errorf("Unable to match tv show with title %s: %s", name, strings.Join(reasons, "; "))
+	s.saveUnmatchedItem(dirPath, name, err.Error(), candidates, mutex)
+	return nil, err
+}
+
+// matchTVShow gets the tv show from the provider. The tv show which was already
+// matched with the provider is fetched by its id, otherwise the best search
+// result is used.
+func matchTVShow(provider external.Provider, name string, existingShow *models.TVShow) (*models.TVShow, []*models.SearchResult, error) {
+	if existingShow != nil && existingShow.Provider == provider.Name() && existingShow.ProviderID != "" {
+		tvShow, err := provider.GetTVShow(existingShow.ProviderID)
+		return tvShow, nil, err
+	}
+
+	results, err := provider.Search(name)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if len(results) == 0 {
+		return nil, nil, fmt.Errorf("Unable to find tv show with title: %s", name)
 	}
-	// create new TVShow object
-	tvShow := &models.TVShow{
-		Name:      tvMazeInfo.Show.Name,
-		Language:  tvMazeInfo.Show.Language,
-		Genres:    tvMazeInfo.Show.Genres,
-		Runtime:   tvMazeInfo.Show.Runtime,
-		Premiered: tvMazeInfo.Show.Premiered,
-		Rating:    tvMazeInfo.Show.Rating.Average,
-		PosterURL: tvMazeInfo.Show.Image.Original,
-		Summary:   tvMazeInfo.Show.Summary,
-		DirPath:   dirPath,
+
+	tvShow, err := provider.GetTVShow(results[0].ProviderID)
+	return tvShow, results, err
+}
+
+// getProviders returns the metadata providers in the order they should be tried
+// for the directory. The order is taken from the library config or the default
+// config, and the provider the tv show was already matched with goes first.
+func (s *tvShowService) getProviders(dirPath string, existingShow *models.TVShow) []external.Provider {
+	order := s.providerOrder
+	if common.Config != nil {
+		if len(common.Config.MetadataProviders) > 0 {
+			order = common.Config.MetadataProviders
+		}
+		// the most specific library wins
+		libraryDir := ""
+		for dir, libraryOrder := range common.Config.LibraryProviders {
+			if len(dir) > len(libraryDir) && isInDirectory(dirPath, dir) {
+				libraryDir = dir
+				order = libraryOrder
+			}
+		}
+	}
+	if existingShow != nil && existingShow.Provider != "" {
+		order = append([]string{existingShow.Provider}, order...)
+	}
+
+	var providers []external.Provider
+	added := make(map[string]bool)
+	for _, name := range order {
+		provider, ok := s.providers[name]
+		if !ok {
+			log.Warnf("Metadata provider [%s] is not registered", name)
+			continue
+		}
+		if added[name] {
+			continue
+		}
+		added[name] = true
+		providers = append(providers, provider)
 	}
+	return providers
+}
+
+// saveTVShow validates the tv show from the metadata provider and saves it
+// to the database if doesn't exist or updates if exists
+func (s *tvShowService) saveTVShow(tvShow *models.TVShow, dirPath string, mutex *sync.Mutex) (*models.TVShow, error) {
+	tvShow.DirPath = dirPath
 	// validate new TVShow object
 	validate := validator.New()
 	if err := validate.Struct(tvShow); err != nil {
-		log.Errorf("Couldn't validate tv show[dir=%s]; err: %v", name, err)
-		return nil, fmt.Errorf("Couldn't validate tv show [dir=%s]", name)
+		log.Errorf("Couldn't validate tv show[dir=%s]; err: %v", dirPath, err)
+		return nil, fmt.Errorf("Couldn't validate tv show [dir=%s]: %v", dirPath, err)
 	}
 
+	// the mutex guards only the lookup and save, the episodes of the other tv
+	// shows can be indexed in the meantime
 	mutex.Lock()
-	existingShow, err := s.tvShowRepo.GetByName(tvShow.Name)
+	err := s.upsertTVShow(tvShow, dirPath)
+	mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// the tv show is already saved, so missing episodes aren't treated as a failed match
+	if err := s.indexEpisodes(tvShow); err != nil {
+		log.Warnf("Couldn't index episodes of the tv show[%s]; err: %v", tvShow.Name, err)
+	}
+	return tvShow, nil
+}
+
+// upsertTVShow saves new tv show or updates the existing one with the same
+// directory or name, the caller must hold the mutex
+func (s *tvShowService) upsertTVShow(tvShow *models.TVShow, dirPath string) error {
+	existingShow, _ := s.tvShowRepo.GetByDirPath(dirPath) // error means there is no such show yet
+	if existingShow == nil {
+		existingShow, _ = s.tvShowRepo.GetByName(tvShow.Name)
+	}
 
 	if existingShow == nil {
 		if err := s.Save(tvShow); err != nil { // NOTE: here tv show is validated twice, need to be changed
 			log.Debugf("Couldn't save new tv show[%s]; err: %v", tvShow.Name, err)
-			return nil, err
+			return err
 		}
 		log.Infof("Successfully saved new tv show[%s]", tvShow.Name)
-	} else {
-		tvShow.ID = existingShow.ID
-		if err := s.tvShowRepo.Update(tvShow); err != nil {
-			log.Debugf("Couldn't update tv show[%s]; err: %v", tvShow.Name, err)
-			return nil, err
-		}
-		log.Infof("Successfully updated tv show[%s]", tvShow.Name)
+		return nil
 	}
-	// unlock mutex
-	mutex.Unlock()
-	return tvShow, nil
+
+	tvShow.ID = existingShow.ID
+	if err := s.tvShowRepo.Update(tvShow); err != nil {
+		log.Debugf("Couldn't update tv show[%s]; err: %v", tvShow.Name, err)
+		return err
+	}
+	log.Infof("Successfully updated tv show[%s]", tvShow.Name)
+	return nil
 }
 
 // UpdateAllTVShows reads directory names, removes special char like "_,/"
-// and calls TVmaze api to get data
+// and calls TVmaze api to get data. Ignored directories are skipped.
 func (s *tvShowService) UpdateAllTVShows() (map[string]string, map[string]string) {
-	tvShowDirs := getDirectories()
+	tvShowDirs := s.skipIgnoredDirectories(getDirectories())
 
 	// get data from TVmaze api
 	var wg sync.WaitGroup
@@ -149,16 +304,256 @@ func (s *tvShowService) GetTVShowByName(name string) (*models.TVShow, error) {
 	return tvShow, nil
 }
 
-// GetAllTVShows returns all tv show from the database
-func (s *tvShowService) GetAllTVShows() ([]*models.TVShow, error) {
-	tvShows, err := s.tvShowRepo.GetAll()
+// GetTVShows returns page of the tv shows matching the filter along with
+// the total number of matching tv shows
+func (s *tvShowService) GetTVShows(filter *models.TVShowFilter) ([]*models.TVShow, int64, error) {
+	if err := normalizeFilter(filter); err != nil {
+		log.Debugf("Couldn't validate tv show filter; err: %v", err)
+		return nil, 0, err
+	}
+
+	tvShows, total, err := s.tvShowRepo.Find(filter)
+	if err != nil {
+		log.Debugf("Couldn't get tv shows from the database; err: %v", err)
+		return nil, 0, fmt.Errorf("Couldn't get tv shows from the database")
+	}
+
+	log.Infof("Successfully found %d of %d tv shows", len(tvShows), total)
+	return tvShows, total, nil
+}
+
+// GetUnmatchedItems returns all items from the unmatched items queue
+func (s *tvShowService) GetUnmatchedItems() ([]*models.UnmatchedItem, error) {
+	items, err := s.unmatchedRepo.GetAll()
+	if err != nil {
+		log.Debugf("Couldn't get unmatched items from the database; err: %v", err)
+		return nil, fmt.Errorf("Couldn't get unmatched items from the database")
+	}
+
+	log.Infof("Successfully found all unmatched items")
+	return items, nil
+}
+
+// RetryUnmatchedItem calls the metadata providers for the unmatched directory once again.
+// If the tv show is found and saved, the item is removed from the queue.
+func (s *tvShowService) RetryUnmatchedItem(id string) (*models.TVShow, error) {
+	item, err := s.getUnmatchedItem(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var mutex sync.Mutex
+	tvShow, err := s.UpdateTVShow(item.DirPath, &mutex)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Successfully matched unmatched item [%s]", item.DirPath)
+	return tvShow, nil
+}
+
+// IgnoreUnmatchedItem marks the unmatched item as ignored, so it's skipped
+// by the next update
+func (s *tvShowService) IgnoreUnmatchedItem(id string) (*models.UnmatchedItem, error) {
+	item, err := s.getUnmatchedItem(id)
+	if err != nil {
+		return nil, err
+	}
+
+	item.Ignored = true
+	item.UpdatedAt = time.Now()
+	if err := s.unmatchedRepo.Save(item); err != nil {
+		log.Debugf("Couldn't ignore unmatched item [%s]; err: %v", id, err)
+		return nil, fmt.Errorf("Couldn't save the unmatched item to the database")
+	}
+
+	log.Infof("Successfully ignored unmatched item [%s]", item.DirPath)
+	return item, nil
+}
+
+// AssignUnmatchedItem gets the tv show with provided id from the metadata provider
+// and saves it for the unmatched directory. The item is removed from the queue on success.
+func (s *tvShowService) AssignUnmatchedItem(id, provider, providerID string) (*models.TVShow, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+	}
+	item, err := s.getUnmatchedItem(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tvShow, err := p.GetTVShow(providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var mutex sync.Mutex
+	tvShow, err = s.saveTVShow(tvShow, item.DirPath, &mutex)
+	if err != nil {
+		return nil, err
+	}
+	s.removeUnmatchedItem(item.DirPath, &mutex)
+
+	log.Infof("Successfully assigned tv show [%s] to the unmatched item [%s]", tvShow.Name, item.DirPath)
+	return tvShow, nil
+}
+
+// SetTVShowProvider gets the existing tv show from the provided metadata provider
+// and saves it, so the next updates use this provider first. If the provider id
+// isn't set, the tv show is searched by its name.
+func (s *tvShowService) SetTVShowProvider(name, provider, providerID string) (*models.TVShow, error) {
+	existingShow, err := s.GetTVShowByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrTVShowNotFound, name)
+	}
+
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+	}
+	// matchTVShow searches by the name unless the provider id is set
+	pinnedShow := &models.TVShow{Name: existingShow.Name, Provider: provider, ProviderID: providerID}
+	tvShow, _, err := matchTVShow(p, existingShow.Name, pinnedShow)
+	if err != nil {
+		return nil, err
+	}
+
+	var mutex sync.Mutex
+	tvShow, err = s.saveTVShow(tvShow, existingShow.DirPath, &mutex)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Successfully changed provider of the tv show [%s] to [%s]", name, provider)
+	return tvShow, nil
+}
+
+// getUnmatchedItem converts provided id to the ObjectID and returns
+// the unmatched item with this id
+func (s *tvShowService) getUnmatchedItem(id string) (*models.UnmatchedItem, error) {
+	itemID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		log.Debugf("Couldn't get all tv shows from the database; err: %v", err)
-		return nil, fmt.Errorf("Couldn't get all tv shows from the database")
+		log.Debugf("Unable to convert string id to ObjectID: %s", id)
+		return nil, fmt.Errorf("%w: %s", ErrInvalidUnmatchedID, id)
 	}
 
-	log.Infof("Successfully found all the shows")
-	return tvShows, nil
+	item, err := s.unmatchedRepo.GetByID(itemID)
+	if err != nil {
+		log.Debugf("Unable to get unmatched item by id: %s; err: %v", id, err)
+		return nil, fmt.Errorf("%w: %s", ErrUnmatchedNotFound, id)
+	}
+
+	return item, nil
+}
+
+// skipIgnoredDirectories removes directories which are marked as ignored
+// in the unmatched items queue
+func (s *tvShowService) skipIgnoredDirectories(dirs []string) []string {
+	items, err := s.unmatchedRepo.GetAll()
+	if err != nil {
+		log.Debugf("Couldn't get unmatched items from the database; err: %v", err)
+		return dirs
+	}
+
+	ignoredDirs := make(map[string]bool)
+	for _, item := range items {
+		if item.Ignored {
+			ignoredDirs[item.DirPath] = true
+		}
+	}
+
+	filteredDirs := []string{}
+	for _, dir := range dirs {
+		if ignoredDirs[dir] {
+			log.Debugf("Skipping ignored directory [%s]", dir)
+			continue
+		}
+		filteredDirs = append(filteredDirs, dir)
+	}
+	return filteredDirs
+}
+
+// saveUnmatchedItem stores the directory in the unmatched items queue. If the
+// directory is already there, it updates the reason and candidates and keeps the rest.
+func (s *tvShowService) saveUnmatchedItem(dirPath, query, reason string, results []*models.SearchResult, mutex *sync.Mutex) {
+	var candidates []*models.UnmatchedCandidate
+	for _, r := range results {
+		candidates = append(candidates, &models.UnmatchedCandidate{
+			Provider:   r.Provider,
+			ProviderID: r.ProviderID,
+			Name:       r.Name,
+			Language:   r.Language,
+			Premiered:  r.Premiered,
+			Score:      r.Score,
+		})
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	now := time.Now()
+	item, err := s.unmatchedRepo.GetByDirPath(dirPath)
+	if item == nil || err != nil {
+		item = &models.UnmatchedItem{
+			ID:        primitive.NewObjectID(),
+			DirPath:   dirPath,
+			CreatedAt: now,
+		}
+	}
+	item.Query = query
+	item.Reason = reason
+	item.Candidates = candidates
+	item.UpdatedAt = now
+
+	if err := s.unmatchedRepo.Save(item); err != nil {
+		log.Errorf("Couldn't save unmatched item [%s]; err: %v", dirPath, err)
+		return
+	}
+	log.Infof("Saved unmatched item [%s]: %s", dirPath, reason)
+}
+
+// removeUnmatchedItem removes the directory from the unmatched items queue if it's there
+func (s *tvShowService) removeUnmatchedItem(dirPath string, mutex *sync.Mutex) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	item, err := s.unmatchedRepo.GetByDirPath(dirPath)
+	if item == nil || err != nil {
+		return
+	}
+
+	if err := s.unmatchedRepo.Delete(item.ID); err != nil {
+		log.Errorf("Couldn't remove unmatched item [%s]; err: %v", dirPath, err)
+	}
+}
+
+// normalizeFilter validates the tv show filter and fills in the defaults
+func normalizeFilter(filter *models.TVShowFilter) error {
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	if filter.PerPage == 0 {
+		filter.PerPage = defaultPerPage
+	}
+	if filter.Page < 0 {
+		return fmt.Errorf("%w: page must be positive", ErrInvalidFilter)
+	}
+	if filter.PerPage < 0 || filter.PerPage > maxPerPage {
+		return fmt.Errorf("%w: per_page must be between 1 and %d", ErrInvalidFilter, maxPerPage)
+	}
+	if filter.Year < 0 {
+		return fmt.Errorf("%w: year must be positive", ErrInvalidFilter)
+	}
+	if filter.SortBy == "" {
+		filter.SortBy = "name"
+	}
+	field, ok := sortFields[filter.SortBy]
+	if !ok {
+		return fmt.Errorf("%w: unknown sort field %s", ErrInvalidFilter, filter.SortBy)
+	}
+	filter.SortBy = field
+	return nil
 }
 
 // directoryExists checks if a directory exists and
@@ -2,11 +2,15 @@ package service_test
 
 import (
 	"bytes"
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"sync"
 	"testing"
 
 	"github.com/0x113/x-media/tvshow/common"
+	"github.com/0x113/x-media/tvshow/external"
+	"github.com/0x113/x-media/tvshow/external/tvmaze"
 	"github.com/0x113/x-media/tvshow/mocks"
 	"github.com/0x113/x-media/tvshow/models"
 	"github.com/0x113/x-media/tvshow/service"
@@ -20,6 +24,8 @@ import (
 type TVShowServiceTestSuite struct {
 	suite.Suite
 	tvShowRepo    *mocks.MockTVShowRepository
+	unmatchedRepo *mocks.MockUnmatchedRepository
+	episodeRepo   *mocks.MockEpisodeRepository
 	tvShowService service.TVShowService
 	client        utils.HttpClient
 }
@@ -27,9 +33,16 @@ type TVShowServiceTestSuite struct {
 // SetupTest initiates mocked database and new tv show service
 func (suite *TVShowServiceTestSuite) SetupTest() {
 	suite.tvShowRepo = mocks.NewMockTVShowRepository()
+	suite.unmatchedRepo = mocks.NewMockUnmatchedRepository()
+	suite.episodeRepo = mocks.NewMockEpisodeRepository()
 	logrus.SetOutput(ioutil.Discard) // disable logrus
 }
 
+// newProviders returns TVmaze metadata provider with the mocked client
+func newProviders(client utils.HttpClient) []external.Provider {
+	return []external.Provider{&tvmaze.Provider{Client: client}}
+}
+
 // TestTVShowServiceTestSuite runs test suite
 func TestTVShowServiceTestSuite(t *testing.T) {
 	suite.Run(t, new(TVShowServiceTestSuite))
@@ -37,7 +50,7 @@ func TestTVShowServiceTestSuite(t *testing.T) {
 
 func (suite *TVShowServiceTestSuite) TestSave() {
 	suite.client = &mocks.MockClient{}
-	suite.tvShowService = service.NewTVShowService(suite.client, suite.tvShowRepo)
+	suite.tvShowService = service.NewTVShowService(newProviders(suite.client), suite.tvShowRepo, suite.unmatchedRepo, suite.episodeRepo)
 	testCases := []struct {
 		name    string
 		tvShow  *models.TVShow
@@ -154,25 +167,70 @@ func (suite *TVShowServiceTestSuite) TestUpdateAllTVShows() {
 	{
 	}]
 			`
-			return &http.Response{
-				StatusCode: http.StatusOK,
-				Body:       ioutil.NopCloser(bytes.NewReader([]byte(json))),
-			}, nil
+			return mocks.TVmazeResponse(req, json), nil
 		},
 	}
-	suite.tvShowService = service.NewTVShowService(suite.client, suite.tvShowRepo)
+	suite.tvShowService = service.NewTVShowService(newProviders(suite.client), suite.tvShowRepo, suite.unmatchedRepo, suite.episodeRepo)
 	common.Config = &common.Configuration{
 		TVShowDirectories: []string{"testdata/three_shows/"},
 	}
 
 	_, errMap := suite.tvShowService.UpdateAllTVShows()
-  expectedErrMap := map[string]string{}
+	expectedErrMap := map[string]string{}
 	suite.Equal(expectedErrMap, errMap)
 }
 
+func (suite *TVShowServiceTestSuite) TestUpdateTVShowCastAndSeasons() {
+	suite.client = &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			show := `{"id": 526, "name": "The Office", "type": "Scripted", "status": "Ended", "language": "English", "genres": ["Comedy"], "runtime": 30, "premiered": "2005-03-24", "rating": {"average": 8.5}, "webChannel": null, "network": {"id": 1, "name": "NBC"}, "image": {"original": "http://static.tvmaze.com/uploads/images/original_untouched/85/213184.jpg"}, "summary": "One of the best tv shows, no doubt"`
+			json := `[{"score": 25.4, "show": ` + show + `}}]`
+			if req.URL.Path == "/shows/526" {
+				json = show + `, "_embedded": {
+	"cast": [{"person": {"id": 5, "name": "Steve Carell", "image": {"original": "http://static.tvmaze.com/person.jpg"}}, "character": {"id": 7, "name": "Michael Scott", "image": null}}],
+	"seasons": [{"id": 2297, "number": 1, "name": "", "episodeOrder": 6, "premiereDate": "2005-03-24", "endDate": "2005-04-26", "image": {"original": "http://static.tvmaze.com/season.jpg"}}]
+}}`
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(json))),
+			}, nil
+		},
+	}
+	suite.tvShowService = service.NewTVShowService(newProviders(suite.client), suite.tvShowRepo, suite.unmatchedRepo, suite.episodeRepo)
+
+	var mutex sync.Mutex
+	tvShow, err := suite.tvShowService.UpdateTVShow("testdata/three_shows/The_Office", &mutex)
+	suite.Nil(err)
+	suite.Equal("NBC", tvShow.Network)
+	suite.Equal([]*models.CastMember{
+		&models.CastMember{
+			TVmazePersonID: 5,
+			Name:           "Steve Carell",
+			ImageURL:       "http://static.tvmaze.com/person.jpg",
+			CharacterName:  "Michael Scott",
+		},
+	}, tvShow.Cast)
+	suite.Equal([]*models.Season{
+		&models.Season{
+			Number:       1,
+			EpisodeCount: 6,
+			PremiereDate: "2005-03-24",
+			EndDate:      "2005-04-26",
+			PosterURL:    "http://static.tvmaze.com/season.jpg",
+		},
+	}, tvShow.Seasons)
+
+	// cast and seasons are returned with the show details
+	savedShow, err := suite.tvShowService.GetTVShowByName("The Office")
+	suite.Nil(err)
+	suite.Len(savedShow.Cast, 1)
+	suite.Len(savedShow.Seasons, 1)
+}
+
 func (suite *TVShowServiceTestSuite) TestGetTVShowByName() {
 	suite.client = &mocks.MockClient{}
-	suite.tvShowService = service.NewTVShowService(suite.client, suite.tvShowRepo)
+	suite.tvShowService = service.NewTVShowService(newProviders(suite.client), suite.tvShowRepo, suite.unmatchedRepo, suite.episodeRepo)
 	testCases := []struct {
 		name           string
 		tvShowName     string
@@ -216,43 +274,313 @@ func (suite *TVShowServiceTestSuite) TestGetTVShowByName() {
 	}
 }
 
-func (suite *TVShowServiceTestSuite) TestGetAllTVShows() {
+func (suite *TVShowServiceTestSuite) TestGetTVShows() {
 	suite.client = &mocks.MockClient{}
-	suite.tvShowService = service.NewTVShowService(suite.client, suite.tvShowRepo)
+	suite.tvShowService = service.NewTVShowService(newProviders(suite.client), suite.tvShowRepo, suite.unmatchedRepo, suite.episodeRepo)
+
+	// seed additional tv shows
+	theOffice := &models.TVShow{
+		Name:      "The Office",
+		Status:    "Ended",
+		Network:   "NBC",
+		Language:  "English",
+		Genres:    []string{"Comedy"},
+		Runtime:   30,
+		Premiered: "2005-03-24",
+		Rating:    8.6,
+		DirPath:   "testdata/three_shows/The_Office",
+	}
+	dark := &models.TVShow{
+		Name:      "Dark",
+		Status:    "Ended",
+		Network:   "Netflix",
+		Language:  "German",
+		Genres:    []string{"Drama", "Mystery"},
+		Runtime:   60,
+		Premiered: "2017-12-01",
+		Rating:    8.7,
+		DirPath:   "testdata/Dark",
+	}
+	suite.Nil(suite.tvShowRepo.Save(theOffice))
+	suite.Nil(suite.tvShowRepo.Save(dark))
+	boJack, _ := suite.tvShowRepo.GetByName("BoJack Horseman")
 
 	testCases := []struct {
 		name            string
+		filter          *models.TVShowFilter
 		expectedTVShows []*models.TVShow
+		expectedTotal   int64
 		wantErr         bool
 	}{
 		{
-			name: "Success",
-			expectedTVShows: []*models.TVShow{
-				&models.TVShow{
-					Name:      "BoJack Horseman",
-					Language:  "English",
-					Genres:    []string{"Comedy", "Drama"},
-					Runtime:   25,
-					Premiered: "2014-08-22",
-					Rating:    8.1,
-					PosterURL: "https://static.tvmaze.com/uploads/images/original_untouched/236/590384.jpg",
-					Summary:   "Meet the most beloved sitcom horse of the '90s, 20 years later.",
-					DirPath:   "testdata/three_shows/BoJack Horseman",
-				},
+			name:            "Default sorting by name",
+			filter:          &models.TVShowFilter{},
+			expectedTVShows: []*models.TVShow{boJack, dark, theOffice},
+			expectedTotal:   3,
+			wantErr:         false,
+		},
+		{
+			name:            "Filter by genre",
+			filter:          &models.TVShowFilter{Genre: "Comedy"},
+			expectedTVShows: []*models.TVShow{boJack, theOffice},
+			expectedTotal:   2,
+			wantErr:         false,
+		},
+		{
+			name:            "Filter by status, network and language",
+			filter:          &models.TVShowFilter{Status: "Ended", Network: "Netflix", Language: "German"},
+			expectedTVShows: []*models.TVShow{dark},
+			expectedTotal:   1,
+			wantErr:         false,
+		},
+		{
+			name:            "Filter by premiered year",
+			filter:          &models.TVShowFilter{Year: 2005},
+			expectedTVShows: []*models.TVShow{theOffice},
+			expectedTotal:   1,
+			wantErr:         false,
+		},
+		{
+			name:            "Sort by rating descending with pagination",
+			filter:          &models.TVShowFilter{SortBy: "rating", SortDesc: true, Page: 2, PerPage: 2},
+			expectedTVShows: []*models.TVShow{boJack},
+			expectedTotal:   3,
+			wantErr:         false,
+		},
+		{
+			name:            "Page out of range",
+			filter:          &models.TVShowFilter{Page: 3, PerPage: 2},
+			expectedTVShows: []*models.TVShow{},
+			expectedTotal:   3,
+			wantErr:         false,
+		},
+		{
+			name:            "Unknown sort field",
+			filter:          &models.TVShowFilter{SortBy: "weight"},
+			expectedTVShows: nil,
+			expectedTotal:   0,
+			wantErr:         true,
+		},
+		{
+			name:            "Too many per page",
+			filter:          &models.TVShowFilter{PerPage: 101},
+			expectedTVShows: nil,
+			expectedTotal:   0,
+			wantErr:         true,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			tvShows, total, err := suite.tvShowService.GetTVShows(tt.filter)
+			if tt.wantErr {
+				suite.True(errors.Is(err, service.ErrInvalidFilter))
+			} else {
+				suite.Nil(err)
+			}
+			suite.Equal(tt.expectedTVShows, tvShows)
+			suite.Equal(tt.expectedTotal, total)
+		})
+	}
+}
+
+func (suite *TVShowServiceTestSuite) TestGetUnmatchedItems() {
+	suite.client = &mocks.MockClient{}
+	suite.tvShowService = service.NewTVShowService(newProviders(suite.client), suite.tvShowRepo, suite.unmatchedRepo, suite.episodeRepo)
+
+	items, err := suite.tvShowService.GetUnmatchedItems()
+	suite.Nil(err)
+	suite.Len(items, 1)
+	suite.Equal("testdata/three_shows/The_Office", items[0].DirPath)
+}
+
+func (suite *TVShowServiceTestSuite) TestUpdateTVShowUnmatched() {
+	testCases := []struct {
+		name               string
+		json               string
+		expectedCandidates int
+	}{
+		{
+			name:               "No results",
+			json:               `[]`,
+			expectedCandidates: 0,
+		},
+		{
+			name: "Validation error; show without summary",
+			json: `[
+	{"score": 17.8, "show": {"id": 1, "name": "Unrated Show", "language": "English", "genres": ["Drama"], "runtime": 30, "premiered": "2020-01-01", "image": {"original": "http://static.tvmaze.com/1.jpg"}, "rating": {"average": null}}},
+	{"score": 10.2, "show": {"id": 2, "name": "Unrated Show 2"}}
+]`,
+			expectedCandidates: 2,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.SetupTest()
+		suite.client = &mocks.MockClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				return mocks.TVmazeResponse(req, tt.json), nil
 			},
-			wantErr: false,
+		}
+		suite.tvShowService = service.NewTVShowService(newProviders(suite.client), suite.tvShowRepo, suite.unmatchedRepo, suite.episodeRepo)
+		suite.Run(tt.name, func() {
+			var mutex sync.Mutex
+			tvShow, err := suite.tvShowService.UpdateTVShow("testdata/Unrated_Show", &mutex)
+			suite.NotNil(err)
+			suite.Nil(tvShow)
+
+			item, err := suite.unmatchedRepo.GetByDirPath("testdata/Unrated_Show")
+			suite.Nil(err)
+			suite.Equal("Unrated Show", item.Query)
+			suite.NotEmpty(item.Reason)
+			suite.Len(item.Candidates, tt.expectedCandidates)
+		})
+	}
+}
+
+func (suite *TVShowServiceTestSuite) TestRetryUnmatchedItem() {
+	testCases := []struct {
+		name          string
+		id            string
+		json          string
+		rating        float32
+		wantErr       bool
+		wantUnmatched bool
+	}{
+		{
+			name:          "Success",
+			id:            "5f4bd6a4c6e9ab2e4f8b4567",
+			json:          `[{"score": 25.4, "show": {"id": 526, "name": "The Office", "language": "English", "genres": ["Comedy"], "runtime": 30, "premiered": "2005-03-24", "rating": {"average": 8.5}, "image": {"original": "http://static.tvmaze.com/uploads/images/original_untouched/85/213184.jpg"}, "summary": "One of the best tv shows, no doubt"}}]`,
+			rating:        8.5,
+			wantErr:       false,
+			wantUnmatched: false,
+		},
+		{
+			name:          "Unrated show",
+			id:            "5f4bd6a4c6e9ab2e4f8b4567",
+			json:          `[{"score": 25.4, "show": {"id": 526, "name": "The Office", "language": "English", "genres": ["Comedy"], "runtime": 30, "premiered": "2005-03-24", "rating": {"average": null}, "image": {"original": "http://static.tvmaze.com/uploads/images/original_untouched/85/213184.jpg"}, "summary": "One of the best tv shows, no doubt"}}]`,
+			wantErr:       false,
+			wantUnmatched: false,
+		},
+		{
+			name:          "Still no results",
+			id:            "5f4bd6a4c6e9ab2e4f8b4567",
+			json:          `[]`,
+			wantErr:       true,
+			wantUnmatched: true,
+		},
+		{
+			name:          "Non-existent item",
+			id:            "5f4bd6a4c6e9ab2e4f8b4560",
+			wantErr:       true,
+			wantUnmatched: true,
 		},
 	}
 
 	for _, tt := range testCases {
+		suite.SetupTest()
+		suite.client = &mocks.MockClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				return mocks.TVmazeResponse(req, tt.json), nil
+			},
+		}
+		suite.tvShowService = service.NewTVShowService(newProviders(suite.client), suite.tvShowRepo, suite.unmatchedRepo, suite.episodeRepo)
 		suite.Run(tt.name, func() {
-			tvShows, err := suite.tvShowService.GetAllTVShows()
+			tvShow, err := suite.tvShowService.RetryUnmatchedItem(tt.id)
 			if tt.wantErr {
 				suite.NotNil(err)
+				suite.Nil(tvShow)
 			} else {
 				suite.Nil(err)
+				suite.Equal("The Office", tvShow.Name)
+				suite.Equal(tt.rating, tvShow.Rating)
+			}
+
+			_, err = suite.unmatchedRepo.GetByDirPath("testdata/three_shows/The_Office")
+			suite.Equal(tt.wantUnmatched, err == nil)
+		})
+	}
+}
+
+func (suite *TVShowServiceTestSuite) TestIgnoreUnmatchedItem() {
+	suite.client = &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`[]`))),
+			}, nil
+		},
+	}
+	suite.tvShowService = service.NewTVShowService(newProviders(suite.client), suite.tvShowRepo, suite.unmatchedRepo, suite.episodeRepo)
+
+	// ignored directory must be skipped by the next update
+	tvShowsDir, err := ioutil.TempDir("", "ignored-shows-*")
+	suite.Nil(err)
+	ignoredDir, err := ioutil.TempDir(tvShowsDir, "Ignored_Show-*")
+	suite.Nil(err)
+	common.Config = &common.Configuration{
+		TVShowDirectories: []string{tvShowsDir},
+	}
+	_, errMap := suite.tvShowService.UpdateAllTVShows()
+	suite.Contains(errMap, ignoredDir)
+
+	item, err := suite.unmatchedRepo.GetByDirPath(ignoredDir)
+	suite.Nil(err)
+	ignoredItem, err := suite.tvShowService.IgnoreUnmatchedItem(item.ID.Hex())
+	suite.Nil(err)
+	suite.True(ignoredItem.Ignored)
+
+	_, errMap = suite.tvShowService.UpdateAllTVShows()
+	suite.Empty(errMap)
+
+	// invalid id
+	ignoredItem, err = suite.tvShowService.IgnoreUnmatchedItem("123")
+	suite.NotNil(err)
+	suite.Nil(ignoredItem)
+}
+
+func (suite *TVShowServiceTestSuite) TestAssignUnmatchedItem() {
+	testCases := []struct {
+		name       string
+		statusCode int
+		json       string
+		wantErr    bool
+	}{
+		{
+			name:       "Success",
+			statusCode: http.StatusOK,
+			json:       `{"id": 526, "name": "The Office", "language": "English", "genres": ["Comedy"], "runtime": 30, "premiered": "2005-03-24", "rating": {"average": 8.5}, "image": {"original": "http://static.tvmaze.com/uploads/images/original_untouched/85/213184.jpg"}, "summary": "One of the best tv shows, no doubt"}`,
+			wantErr:    false,
+		},
+		{
+			name:       "Non-existent TVmaze ID",
+			statusCode: http.StatusNotFound,
+			json:       `{"name": "Not Found", "status": 404}`,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.SetupTest()
+		suite.client = &mocks.MockClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: tt.statusCode,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(tt.json))),
+				}, nil
+			},
+		}
+		suite.tvShowService = service.NewTVShowService(newProviders(suite.client), suite.tvShowRepo, suite.unmatchedRepo, suite.episodeRepo)
+		suite.Run(tt.name, func() {
+			tvShow, err := suite.tvShowService.AssignUnmatchedItem("5f4bd6a4c6e9ab2e4f8b4567", "tvmaze", "526")
+			if tt.wantErr {
+				suite.NotNil(err)
+				suite.Nil(tvShow)
+			} else {
+				suite.Nil(err)
+				suite.Equal("The Office", tvShow.Name)
+				suite.Equal("testdata/three_shows/The_Office", tvShow.DirPath)
 			}
-			suite.Equal(tt.expectedTVShows, tvShows)
 		})
 	}
 }
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/0x113/x-media/user/common"
+	"github.com/0x113/x-media/user/httpclient"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultInternalURL = "http://xmedia-auth-svc:8003/api/v1/auth/internal"
+	// InternalSecretHeader carries the shared secret of the internal routes of
+	// the authentication service and the user service
+	InternalSecretHeader = "X-Internal-Secret"
+)
+
+// SessionRevoker signs the user out of all of the devices and revokes the API keys
+type SessionRevoker interface {
+	RevokeSessions(username string) error
+	RevokeAPIKeys(username string) error
+}
+
+// sessionRevoker revokes the sessions through the internal route of the authentication service
+type sessionRevoker struct {
+	httpClient httpclient.HTTPClient
+	url        string
+	secret     string
+}
+
+// NewSessionRevoker creates the session revoker from the configuration
+func NewSessionRevoker(httpClient httpclient.HTTPClient) SessionRevoker {
+	internalURL := common.Config.AuthInternalURL
+	if internalURL == "" {
+		internalURL = defaultInternalURL
+	}
+	return &sessionRevoker{
+		httpClient: httpClient,
+		url:        strings.TrimSuffix(internalURL, "/"),
+		secret:     common.Config.AuthInternalSecret,
+	}
+}
+
+// RevokeSessions revokes all of the refresh tokens of the user, the issued
+// access tokens are rejected by the authentication service as well
+func (r *sessionRevoker) RevokeSessions(username string) error {
+	return r.revoke(username, "sessions")
+}
+
+// RevokeAPIKeys revokes all of the API keys of the user
+func (r *sessionRevoker) RevokeAPIKeys(username string) error {
+	return r.revoke(username, "api-keys")
+}
+
+// revoke deletes the resource of the user through the internal route
+func (r *sessionRevoker) revoke(username, resource string) error {
+	req, err := http.NewRequest(http.MethodDelete, r.url+"/users/"+url.PathEscape(username)+"/"+resource, nil)
+	if err != nil {
+		log.Errorf("Couldn't prepare the %s revocation request: %v", resource, err)
+		return err
+	}
+	req.Header.Set(InternalSecretHeader, r.secret)
+
+	res, err := r.httpClient.Do(req)
+	if err != nil {
+		log.Errorf("Couldn't connect to the authentication service: %v", err)
+		return fmt.Errorf("Couldn't connect to the authentication service")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("Expected 204 status code, got %d", res.StatusCode)
+	}
+	return nil
+}
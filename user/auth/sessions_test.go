@@ -0,0 +1,59 @@
+package auth_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/0x113/x-media/user/auth"
+	"github.com/0x113/x-media/user/common"
+	"github.com/0x113/x-media/user/mocks"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+)
+
+// SessionsTestSuite defines the test suite for the session revoker
+type SessionsTestSuite struct {
+	suite.Suite
+}
+
+// SetupTest disables the logrus output
+func (suite *SessionsTestSuite) SetupTest() {
+	logrus.SetOutput(ioutil.Discard)
+}
+
+// TestSessionsTestSuite runs the test suite
+func TestSessionsTestSuite(t *testing.T) {
+	suite.Run(t, new(SessionsTestSuite))
+}
+
+func (suite *SessionsTestSuite) TestSessionRevoker() {
+	common.Config = &common.Configuration{AuthInternalURL: "http://auth/api/v1/auth/internal/", AuthInternalSecret: "internal_secret"}
+	var requests []*http.Request
+	client := &mocks.MockClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			requests = append(requests, req)
+			status := http.StatusNoContent
+			if req.Header.Get("X-Internal-Secret") != "internal_secret" {
+				status = http.StatusForbidden
+			}
+			return &http.Response{StatusCode: status, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+		},
+	}
+
+	suite.Nil(auth.NewSessionRevoker(client).RevokeSessions("John Doe"))
+	suite.Require().Len(requests, 1)
+	suite.Equal(http.MethodDelete, requests[0].Method)
+	suite.Equal("http://auth/api/v1/auth/internal/users/John%20Doe/sessions", requests[0].URL.String())
+
+	suite.Nil(auth.NewSessionRevoker(client).RevokeAPIKeys("John Doe"))
+	suite.Require().Len(requests, 2)
+	suite.Equal(http.MethodDelete, requests[1].Method)
+	suite.Equal("http://auth/api/v1/auth/internal/users/John%20Doe/api-keys", requests[1].URL.String())
+
+	common.Config.AuthInternalSecret = "wrong_secret"
+	suite.NotNil(auth.NewSessionRevoker(client).RevokeSessions("JohnDoe"))
+	suite.NotNil(auth.NewSessionRevoker(client).RevokeAPIKeys("JohnDoe"))
+}
@@ -18,10 +18,54 @@ type Configuration struct {
 	LogMaxBackups int    `json:"log_max_backups"`
 	LogMaxAge     int    `json:"log_max_age"`
 
+	// DbDriver defines the database: mysql (default), sqlite or postgres
+	DbDriver string `json:"db_driver"`
+	// DbPath defines the database file of the sqlite driver
+	DbPath string `json:"db_path"`
+	// DbSSLMode defines the sslmode of the postgres driver (default disable)
+	DbSSLMode  string `json:"db_ssl_mode"`
 	DbAddr     string `json:"db_addr"`
 	DbName     string `json:"db_name"`
 	DbUsername string `json:"db_username"`
 	DbPassword string `json:"db_password"`
+
+	// TOTPIssuer defines the account issuer shown in the authenticator apps
+	TOTPIssuer string `json:"totp_issuer"`
+
+	// PasswordResetURL defines the page of the web frontend receiving the
+	// token from the password reset email in the token query parameter
+	PasswordResetURL string `json:"password_reset_url"`
+	// PasswordResetTTLMinutes defines for how long the reset token is valid (default 30)
+	PasswordResetTTLMinutes int `json:"password_reset_ttl_minutes"`
+	// TrustedProxies defines the addresses or CIDR ranges of the reverse proxies
+	// whose X-Forwarded-For header is trusted, the password resets are limited per client address
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	// MailSender defines how the emails are delivered: smtp, file or log (default)
+	MailSender   string `json:"mail_sender"`
+	MailFrom     string `json:"mail_from"`
+	MailFile     string `json:"mail_file"`
+	SMTPHost     string `json:"smtp_host"`
+	SMTPPort     string `json:"smtp_port"`
+	SMTPUsername string `json:"smtp_username"`
+	SMTPPassword string `json:"smtp_password"`
+
+	// AuthInternalURL defines the base of the internal routes of the
+	// authentication service, AuthInternalSecret must match its internal_secret.
+	// The secret also authorizes the authentication service to call the
+	// internal routes of this service, they're disabled without it
+	AuthInternalURL    string `json:"auth_internal_url"`
+	AuthInternalSecret string `json:"auth_internal_secret"`
+
+	// AuthMode defines how the bearer tokens are validated: "local" or "remote"
+	AuthMode        string `json:"auth_mode"`
+	AuthJWKSURL     string `json:"auth_jwks_url"`
+	AuthValidateURL string `json:"auth_validate_url"`
+	// AuthCacheTTL defines for how many seconds remotely validated tokens are cached
+	AuthCacheTTL int `json:"auth_cache_ttl"`
+	// AuthIssuer and AuthAudience must match the iss and aud claims of the access tokens, empty values aren't checked
+	AuthIssuer   string `json:"auth_issuer"`
+	AuthAudience string `json:"auth_audience"`
 }
 
 // Config shares the global configuration
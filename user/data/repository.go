@@ -1,9 +1,44 @@
 package data
 
-import "github.com/0x113/x-media/user/models"
+import (
+	"errors"
+	"time"
+
+	"github.com/0x113/x-media/user/models"
+)
+
+var (
+	// ErrNotFound is returned when the record doesn't exist in the database
+	ErrNotFound = errors.New("Record not found")
+	// ErrDuplicate is returned when the record violates the unique constraint
+	ErrDuplicate = errors.New("Record already exists")
+)
 
 // UserRepository contains all methods for operation on User model
 type UserRepository interface {
 	Create(u *models.User) error
 	Get(username string) (*models.User, error)
+	GetByEmail(email string) (*models.User, error)
+	List(offset, limit int) ([]*models.User, int, error)
+	Update(u *models.User) error
+	UseSecondFactor(u *models.User, lastStep int64, recoveryCodes []string) (bool, error)
+	AddTOTPFailure(u *models.User) (int, error)
+	LockTOTP(u *models.User, lockedUntil time.Time) error
+	Delete(username string) error
+}
+
+// PasskeyRepository contains all methods for operation on Passkey model
+type PasskeyRepository interface {
+	CreatePasskey(p *models.Passkey) error
+	GetPasskey(id string) (*models.Passkey, error)
+	GetPasskeys(userID int) ([]*models.Passkey, error)
+	UpdatePasskey(p *models.Passkey) error
+	DeletePasskey(id string) error
+}
+
+// PasswordResetRepository contains all methods for operation on PasswordResetToken model
+type PasswordResetRepository interface {
+	CreateResetToken(t *models.PasswordResetToken) error
+	TakeResetToken(hash string) (*models.PasswordResetToken, error)
+	DeleteResetTokens(userID int) error
 }
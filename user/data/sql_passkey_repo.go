@@ -0,0 +1,109 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/0x113/x-media/user/databases"
+	"github.com/0x113/x-media/user/models"
+)
+
+const passkeyColumns = "p.credential_id, p.user_id, u.username, p.name, p.public_key, p.sign_count, p.created_at, p.last_used_at"
+
+// passkeyRepository manages the passkeys CRUD
+type passkeyRepository struct {
+	db *databases.SQLDB
+}
+
+// NewPasskeyRepository returns a new instance of PasskeyRepository
+func NewPasskeyRepository(db *databases.SQLDB) PasskeyRepository {
+	return &passkeyRepository{db}
+}
+
+// CreatePasskey stores new passkey in the database
+func (r *passkeyRepository) CreatePasskey(p *models.Passkey) error {
+	query := "INSERT INTO passkey (credential_id, user_id, name, public_key, sign_count, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+
+	if _, err := r.db.Exec(query, p.ID, p.UserID, p.Name, p.PublicKey, p.SignCount, p.CreatedAt); err != nil {
+		if r.db.Dialect.IsDuplicate(err) {
+			return fmt.Errorf("%w: passkey %s already exists in the database", ErrDuplicate, p.ID)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetPasskey returns the passkey with provided credential id along with the username of its owner
+func (r *passkeyRepository) GetPasskey(id string) (*models.Passkey, error) {
+	query := "SELECT " + passkeyColumns + " FROM passkey p JOIN users u ON u.user_id = p.user_id WHERE p.credential_id = ?"
+
+	p, err := scanPasskey(r.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("There is no passkey %s in the database", id)
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetPasskeys returns all of the passkeys of the user
+func (r *passkeyRepository) GetPasskeys(userID int) ([]*models.Passkey, error) {
+	query := "SELECT " + passkeyColumns + " FROM passkey p JOIN users u ON u.user_id = p.user_id WHERE p.user_id = ? ORDER BY p.created_at"
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passkeys := []*models.Passkey{}
+	for rows.Next() {
+		p, err := scanPasskey(rows)
+		if err != nil {
+			return nil, err
+		}
+		passkeys = append(passkeys, p)
+	}
+	return passkeys, rows.Err()
+}
+
+// UpdatePasskey updates the signature counter and the last usage of the passkey
+func (r *passkeyRepository) UpdatePasskey(p *models.Passkey) error {
+	query := "UPDATE passkey SET sign_count = ?, last_used_at = ? WHERE credential_id = ?"
+
+	_, err := r.db.Exec(query, p.SignCount, p.LastUsedAt, p.ID)
+	return err
+}
+
+// DeletePasskey removes the passkey from the database
+func (r *passkeyRepository) DeletePasskey(id string) error {
+	query := "DELETE FROM passkey WHERE credential_id = ?"
+
+	res, err := r.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	if deleted, err := res.RowsAffected(); err == nil && deleted == 0 {
+		return fmt.Errorf("There is no passkey %s in the database", id)
+	}
+	return nil
+}
+
+// rowScanner is implemented by both sql.Row and sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPasskey(row rowScanner) (*models.Passkey, error) {
+	p := new(models.Passkey)
+	var lastUsedAt sql.NullTime
+	if err := row.Scan(&p.ID, &p.UserID, &p.Username, &p.Name, &p.PublicKey, &p.SignCount, &p.CreatedAt, &lastUsedAt); err != nil {
+		return nil, err
+	}
+	if lastUsedAt.Valid {
+		p.LastUsedAt = &lastUsedAt.Time
+	}
+	return p, nil
+}
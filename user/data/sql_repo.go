@@ -0,0 +1,190 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0x113/x-media/user/databases"
+	"github.com/0x113/x-media/user/models"
+)
+
+// userColumns are selected in the order expected by scanUser, the users
+// without the email have NULL, so the empty emails don't violate the unique constraint
+const userColumns = "user_id, username, password, COALESCE(email, ''), is_admin, role, disabled, totp_secret, totp_enabled, totp_last_step, recovery_codes, totp_failures, totp_locked_until, created_at, updated_at"
+
+// userRepository manages the user CRUD, the queries work with all of the
+// supported databases and their dialect takes care of the differences
+type userRepository struct {
+	db *databases.SQLDB
+}
+
+// NewUserRepository returns a new instance of UserRepository
+func NewUserRepository(db *databases.SQLDB) UserRepository {
+	return &userRepository{db}
+}
+
+// Create new user in the database
+func (r *userRepository) Create(u *models.User) error {
+	query := "INSERT INTO users (username, password, email, is_admin, role, created_at, updated_at) VALUES (?, ?, NULLIF(?, ''), ?, ?, ?, ?)"
+
+	if _, err := r.db.Exec(query, u.Username, u.Password, u.Email, u.IsAdmin, u.Role, u.CreatedAt, u.UpdatedAt); err != nil {
+		if r.db.Dialect.IsDuplicate(err) {
+			return fmt.Errorf("%w: user %s or its email already exists in the database", ErrDuplicate, u.Username)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Get user by username from the database
+func (r *userRepository) Get(username string) (*models.User, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE username = ?"
+
+	user, err := scanUser(r.db.QueryRow(query, username))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: there is no user %s in the database", ErrNotFound, username)
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetByEmail returns the user with provided email from the database
+func (r *userRepository) GetByEmail(email string) (*models.User, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE email = ?"
+
+	user, err := scanUser(r.db.QueryRow(query, email))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: there is no user with email %s in the database", ErrNotFound, email)
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// List returns the page of the users ordered by id along with the number of all users
+func (r *userRepository) List(offset, limit int) ([]*models.User, int, error) {
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + userColumns + " FROM users ORDER BY user_id LIMIT ? OFFSET ?"
+	rows, err := r.db.Query(query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+// Update the password, email, roles, status and second factor of the user in the database
+func (r *userRepository) Update(u *models.User) error {
+	query := "UPDATE users SET password = ?, email = NULLIF(?, ''), is_admin = ?, role = ?, disabled = ?, totp_secret = ?, totp_enabled = ?, totp_last_step = ?, recovery_codes = ?, totp_failures = ?, totp_locked_until = ?, updated_at = ? WHERE user_id = ?"
+
+	recoveryCodes := strings.Join(u.RecoveryCodes, ",")
+	if _, err := r.db.Exec(query, u.Password, u.Email, u.IsAdmin, u.Role, u.Disabled, u.TOTPSecret, u.TOTPEnabled, u.TOTPLastStep, recoveryCodes, u.TOTPFailures, u.TOTPLockedUntil, u.UpdatedAt, u.ID); err != nil {
+		if r.db.Dialect.IsDuplicate(err) {
+			return fmt.Errorf("%w: email %s is already used by another user", ErrDuplicate, u.Email)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// UseSecondFactor saves the TOTP step and recovery codes of the user and resets
+// the failures, only if the stored ones are still lastStep and recoveryCodes,
+// so the concurrent requests with the same code can't both use it
+func (r *userRepository) UseSecondFactor(u *models.User, lastStep int64, recoveryCodes []string) (bool, error) {
+	query := "UPDATE users SET totp_last_step = ?, recovery_codes = ?, totp_failures = 0, totp_locked_until = NULL, updated_at = ? WHERE user_id = ? AND totp_last_step = ? AND recovery_codes = ?"
+
+	res, err := r.db.Exec(query, u.TOTPLastStep, strings.Join(u.RecoveryCodes, ","), u.UpdatedAt, u.ID, lastStep, strings.Join(recoveryCodes, ","))
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// AddTOTPFailure increments the invalid codes of the user and returns their
+// number, the row is locked until the transaction ends, so every concurrent
+// request gets its own number
+func (r *userRepository) AddTOTPFailure(u *models.User) (int, error) {
+	tx, err := r.db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE users SET totp_failures = totp_failures + 1, updated_at = ? WHERE user_id = ?"
+	res, err := tx.Exec(r.db.Dialect.Rebind(query), u.UpdatedAt, u.ID)
+	if err != nil {
+		return 0, err
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		return 0, fmt.Errorf("%w: there is no user %s in the database", ErrNotFound, u.Username)
+	}
+	var failures int
+	if err := tx.QueryRow(r.db.Dialect.Rebind("SELECT totp_failures FROM users WHERE user_id = ?"), u.ID).Scan(&failures); err != nil {
+		return 0, err
+	}
+
+	return failures, tx.Commit()
+}
+
+// LockTOTP blocks the second factor of the user until lockedUntil
+func (r *userRepository) LockTOTP(u *models.User, lockedUntil time.Time) error {
+	_, err := r.db.Exec("UPDATE users SET totp_locked_until = ? WHERE user_id = ?", lockedUntil, u.ID)
+	return err
+}
+
+// Delete the user from the database, the passkeys are deleted along with it
+func (r *userRepository) Delete(username string) error {
+	res, err := r.db.Exec("DELETE FROM users WHERE username = ?", username)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: there is no user %s in the database", ErrNotFound, username)
+	}
+	return nil
+}
+
+// scanUser scans the row with userColumns
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	var recoveryCodes string
+	var lockedUntil sql.NullTime
+	if err := row.Scan(&user.ID, &user.Username, &user.Password, &user.Email, &user.IsAdmin, &user.Role, &user.Disabled, &user.TOTPSecret, &user.TOTPEnabled, &user.TOTPLastStep, &recoveryCodes, &user.TOTPFailures, &lockedUntil, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if lockedUntil.Valid {
+		user.TOTPLockedUntil = &lockedUntil.Time
+	}
+	if recoveryCodes != "" {
+		user.RecoveryCodes = strings.Split(recoveryCodes, ",")
+	}
+	return &user, nil
+}
@@ -0,0 +1,62 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/0x113/x-media/user/databases"
+	"github.com/0x113/x-media/user/models"
+)
+
+// passwordResetRepository manages the password reset tokens
+type passwordResetRepository struct {
+	db *databases.SQLDB
+}
+
+// NewPasswordResetRepository returns a new instance of PasswordResetRepository
+func NewPasswordResetRepository(db *databases.SQLDB) PasswordResetRepository {
+	return &passwordResetRepository{db}
+}
+
+// CreateResetToken stores the hash of the new token in the database
+func (r *passwordResetRepository) CreateResetToken(t *models.PasswordResetToken) error {
+	query := "INSERT INTO password_reset (token_hash, user_id, expires_at, created_at) VALUES (?, ?, ?, ?)"
+
+	_, err := r.db.Exec(query, t.TokenHash, t.UserID, t.ExpiresAt, t.CreatedAt)
+	return err
+}
+
+// TakeResetToken returns the token along with the username of its owner and
+// deletes it in the same transaction, only the transaction which actually
+// deleted the token gets it, so the token can be used only once
+func (r *passwordResetRepository) TakeResetToken(hash string) (*models.PasswordResetToken, error) {
+	tx, err := r.db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := "SELECT r.token_hash, r.user_id, u.username, r.expires_at, r.created_at FROM password_reset r JOIN users u ON u.user_id = r.user_id WHERE r.token_hash = ?"
+	var t models.PasswordResetToken
+	if err := tx.QueryRow(r.db.Dialect.Rebind(query), hash).Scan(&t.TokenHash, &t.UserID, &t.Username, &t.ExpiresAt, &t.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: there is no password reset token in the database", ErrNotFound)
+		}
+		return nil, err
+	}
+	res, err := tx.Exec(r.db.Dialect.Rebind("DELETE FROM password_reset WHERE token_hash = ?"), hash)
+	if err != nil {
+		return nil, err
+	}
+	if deleted, err := res.RowsAffected(); err != nil || deleted == 0 {
+		return nil, fmt.Errorf("%w: password reset token has already been used", ErrNotFound)
+	}
+
+	return &t, tx.Commit()
+}
+
+// DeleteResetTokens removes all of the tokens of the user
+func (r *passwordResetRepository) DeleteResetTokens(userID int) error {
+	_, err := r.db.Exec("DELETE FROM password_reset WHERE user_id = ?", userID)
+	return err
+}
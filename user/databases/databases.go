@@ -1,6 +1,133 @@
 package databases
 
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/0x113/x-media/user/common"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MySQL is the default database of the user service
+	MySQL = "mysql"
+	// SQLite keeps the users in a local file, meant for the small deployments
+	SQLite = "sqlite"
+	// PostgreSQL is the alternative database server
+	PostgreSQL = "postgres"
+)
+
+// Dialect hides the differences of the supported databases from the repositories
+type Dialect interface {
+	// Name returns the name of the database, one of MySQL, SQLite or PostgreSQL
+	Name() string
+	// Rebind replaces the ? placeholders of the query with the ones of the database
+	Rebind(query string) string
+	// IsDuplicate checks if the error is the violation of the unique constraint
+	IsDuplicate(err error) bool
+}
+
+// SQLDB manages the connection to the SQL database
+type SQLDB struct {
+	DB      *sql.DB
+	DbName  string
+	Dialect Dialect
+}
+
 // Database shares global database instance
 var (
-	Database MysqlDB
+	Database SQLDB
 )
+
+// Init connects to the database selected by the db_driver config
+func (database *SQLDB) Init() error {
+	driver := common.Config.DbDriver
+	if driver == "" {
+		driver = MySQL
+	}
+	log.Infof("Connecting to the %s database...", driver)
+
+	var dsn string
+	switch driver {
+	case MySQL:
+		dsn = mysqlDSN()
+	case SQLite:
+		path := common.Config.DbPath
+		if path == "" {
+			path = defaultSQLitePath
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
+		}
+		dsn = SQLiteDSN(path)
+	case PostgreSQL:
+		dsn = postgresDSN()
+	default:
+		return fmt.Errorf("Unknown database driver: %s", driver)
+	}
+
+	db, err := Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	*database = *db
+	database.DbName = common.Config.DbName
+	return nil
+}
+
+// Open connects to the database with the data source name of its driver
+func Open(driver, dsn string) (*SQLDB, error) {
+	var dialect Dialect
+	switch driver {
+	case MySQL:
+		dialect = mysqlDialect{}
+	case SQLite:
+		dialect = sqliteDialect{}
+	case PostgreSQL:
+		dialect = postgresDialect{}
+	default:
+		return nil, fmt.Errorf("Unknown database driver: %s", driver)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLDB{DB: db, Dialect: dialect}, nil
+}
+
+// Exec runs the query written with the ? placeholders
+func (database *SQLDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return database.DB.Exec(database.Dialect.Rebind(query), args...)
+}
+
+// Query runs the query written with the ? placeholders
+func (database *SQLDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return database.DB.Query(database.Dialect.Rebind(query), args...)
+}
+
+// QueryRow runs the query written with the ? placeholders
+func (database *SQLDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return database.DB.QueryRow(database.Dialect.Rebind(query), args...)
+}
+
+// Wait pings the database until it accepts the connections, the database
+// container usually starts along with the service
+func (database *SQLDB) Wait(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := database.DB.Ping()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Database isn't available after %s: %v", timeout, err)
+		}
+		log.Debugf("Waiting for the %s database: %v", database.Dialect.Name(), err)
+		time.Sleep(time.Second)
+	}
+}
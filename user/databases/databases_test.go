@@ -0,0 +1,42 @@
+package databases
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebind(t *testing.T) {
+	query := "UPDATE users SET email = NULLIF(?, ''), role = ? WHERE username = ? AND password <> '?'"
+
+	assert.Equal(t, query, mysqlDialect{}.Rebind(query))
+	assert.Equal(t, query, sqliteDialect{}.Rebind(query))
+	assert.Equal(t, "UPDATE users SET email = NULLIF($1, ''), role = $2 WHERE username = $3 AND password <> '?'", postgresDialect{}.Rebind(query))
+}
+
+func TestIsDuplicate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		dialect Dialect
+		err     error
+		want    bool
+	}{
+		{"MySQL duplicate entry", mysqlDialect{}, &mysql.MySQLError{Number: 1062}, true},
+		{"MySQL wrapped duplicate entry", mysqlDialect{}, fmt.Errorf("insert: %w", &mysql.MySQLError{Number: 1062}), true},
+		{"MySQL other error", mysqlDialect{}, &mysql.MySQLError{Number: 1452}, false},
+		{"PostgreSQL unique violation", postgresDialect{}, &pq.Error{Code: "23505"}, true},
+		{"PostgreSQL foreign key violation", postgresDialect{}, &pq.Error{Code: "23503"}, false},
+		{"Error of another database", postgresDialect{}, &mysql.MySQLError{Number: 1062}, false},
+		{"Plain error", sqliteDialect{}, errors.New("UNIQUE constraint failed"), false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.dialect.IsDuplicate(tt.err))
+		})
+	}
+}
@@ -1,39 +1,34 @@
 package databases
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/0x113/x-media/user/common"
 
-	_ "github.com/go-sql-driver/mysql"
-	log "github.com/sirupsen/logrus"
+	"github.com/go-sql-driver/mysql"
 )
 
-// MysqlDB maanages MySQL connection
-type MysqlDB struct {
-	DB     *sql.DB
-	DbName string
+// mysqlDialect uses the ? placeholders of the queries as they are
+type mysqlDialect struct{}
+
+// mysqlDSN returns the data source name of the configured MySQL database
+func mysqlDSN() string {
+	return fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", common.Config.DbUsername, common.Config.DbPassword, common.Config.DbAddr, common.Config.DbName)
+}
+
+// Name returns MySQL
+func (mysqlDialect) Name() string {
+	return MySQL
+}
+
+// Rebind returns the query unchanged
+func (mysqlDialect) Rebind(query string) string {
+	return query
 }
 
-// Init initializes MySQL database
-func (database *MysqlDB) Init() error {
-	log.Infoln("Connecting to the MySQL database...")
-	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", common.Config.DbUsername, common.Config.DbPassword, common.Config.DbAddr, common.Config.DbName)
-	db, err := sql.Open("mysql", dsn)
-	if err != nil {
-		return err
-	}
-
-	// TODO: find a way to use Ping method on docker
-	/*
-		if err := db.Ping(); err != nil {
-			return err
-		}
-	*/
-
-	// set db
-	database.DB = db
-	database.DbName = common.Config.DbName
-	return nil
+// IsDuplicate checks if the error is ER_DUP_ENTRY
+func (mysqlDialect) IsDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
 }
@@ -0,0 +1,64 @@
+package databases
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/0x113/x-media/user/common"
+
+	"github.com/lib/pq"
+)
+
+// uniqueViolation is the SQLSTATE of the unique constraint violation
+const uniqueViolation = "23505"
+
+// postgresDialect replaces the ? placeholders with the numbered ones
+type postgresDialect struct{}
+
+// postgresDSN returns the data source name of the configured PostgreSQL database
+func postgresDSN() string {
+	sslMode := common.Config.DbSSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	dsn := &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(common.Config.DbUsername, common.Config.DbPassword),
+		Host:     common.Config.DbAddr,
+		Path:     "/" + common.Config.DbName,
+		RawQuery: url.Values{"sslmode": {sslMode}}.Encode(),
+	}
+	return dsn.String()
+}
+
+// Name returns PostgreSQL
+func (postgresDialect) Name() string {
+	return PostgreSQL
+}
+
+// Rebind replaces the ? placeholders with $1, $2... skipping the string literals
+func (postgresDialect) Rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// IsDuplicate checks if the error is the unique_violation
+func (postgresDialect) IsDuplicate(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
@@ -0,0 +1,39 @@
+package databases
+
+import (
+	"errors"
+	"net/url"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const defaultSQLitePath = "data/users.db"
+
+// sqliteDialect uses the ? placeholders of the queries as they are
+type sqliteDialect struct{}
+
+// SQLiteDSN returns the data source name of the database file, the foreign
+// keys are enabled and the writers wait for each other instead of failing
+func SQLiteDSN(path string) string {
+	return "file:" + (&url.URL{Path: path}).EscapedPath() + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+}
+
+// Name returns SQLite
+func (sqliteDialect) Name() string {
+	return SQLite
+}
+
+// Rebind returns the query unchanged
+func (sqliteDialect) Rebind(query string) string {
+	return query
+}
+
+// IsDuplicate checks if the error is the violation of the unique or the primary key constraint
+func (sqliteDialect) IsDuplicate(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
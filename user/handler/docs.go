@@ -15,3 +15,10 @@ type userValidatePayload struct {
 	Username string `json:"username" example:"TheBill"`
 	Password string `json:"password" example:"hashedPasswordHere"`
 }
+
+type provisionPayload struct {
+	Username        string `json:"username" example:"TheBill"`
+	Admin           bool   `json:"admin" example:"false"`
+	ExistingOnly    bool   `json:"existing_only" example:"false"`
+	WithoutPassword bool   `json:"without_password" example:"true"`
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/0x113/x-media/user/auth"
+	"github.com/0x113/x-media/user/common"
+	"github.com/0x113/x-media/user/models"
+
+	"github.com/labstack/echo"
+)
+
+// requireInternalSecret allows only the authentication service knowing the
+// shared secret to call the internal routes, they're disabled without the secret
+func requireInternalSecret(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		errMsg := new(models.Error)
+		secret := common.Config.AuthInternalSecret
+		if secret == "" {
+			errMsg.Code = http.StatusForbidden
+			errMsg.Message = "Internal routes are disabled"
+			return c.JSON(errMsg.Code, errMsg)
+		}
+		provided := c.Request().Header.Get(auth.InternalSecretHeader)
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) != 1 {
+			errMsg.Code = http.StatusUnauthorized
+			errMsg.Message = "Invalid internal secret"
+			return c.JSON(errMsg.Code, errMsg)
+		}
+		return next(c)
+	}
+}
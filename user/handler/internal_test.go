@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/0x113/x-media/user/auth"
+	"github.com/0x113/x-media/user/common"
+	"github.com/0x113/x-media/user/mocks"
+	"github.com/0x113/x-media/user/models"
+
+	"github.com/labstack/echo"
+)
+
+// testInternalSecret defines the shared secret of the internal routes used in the tests
+const testInternalSecret = "internal_secret"
+
+// newInternalRequest creates the request of the authentication service with the shared secret
+func newInternalRequest(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(auth.InternalSecretHeader, testInternalSecret)
+	return req
+}
+
+func (suite *UserHandlerTestSuite) TestInternalRoutes() {
+	e := echo.New()
+	NewUserHandler(e, suite.userService, mocks.NewMockValidator())
+
+	routes := []struct {
+		method string
+		target string
+		json   string
+	}{
+		{http.MethodPost, "/api/v1/user/validate", `{"username": "JohnDoe", "password": "test1231"}`},
+		{http.MethodPost, "/api/v1/user/provision", `{"username": "ldapadmin", "admin": true}`},
+		{http.MethodGet, "/api/v1/user/claims?username=JohnDoe", ""},
+		{http.MethodDelete, "/api/v1/user/password?username=JohnDoe", ""},
+		{http.MethodPost, "/api/v1/user/passkeys", `{"id": "cred-1", "username": "JohnDoe", "name": "YubiKey", "public_key": "AQID", "sign_count": 1}`},
+		{http.MethodGet, "/api/v1/user/passkeys?username=JohnDoe", ""},
+		{http.MethodGet, "/api/v1/user/passkeys/cred-1", ""},
+		{http.MethodPost, "/api/v1/user/passkeys/cred-1/login", `{"sign_count": 2}`},
+		{http.MethodDelete, "/api/v1/user/passkeys/cred-1?username=JohnDoe", ""},
+		{http.MethodPost, "/api/v1/user/totp/enroll", `{"username": "JohnDoe"}`},
+		{http.MethodPost, "/api/v1/user/totp/confirm", `{"username": "JohnDoe", "code": "123456"}`},
+		{http.MethodPost, "/api/v1/user/totp/verify", `{"username": "JohnDoe", "code": "123456"}`},
+		{http.MethodPost, "/api/v1/user/totp/disable", `{"username": "JohnDoe", "code": "123456"}`},
+	}
+
+	testCases := []struct {
+		name               string
+		configured         string
+		secret             string
+		expectedStatusCode int
+	}{
+		{
+			name:               "Missing secret",
+			configured:         testInternalSecret,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Wrong secret",
+			configured:         testInternalSecret,
+			secret:             "guess",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Internal routes disabled",
+			configured:         "",
+			secret:             "",
+			expectedStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, route := range routes {
+		for _, tt := range testCases {
+			suite.Run(route.target+" "+tt.name, func() {
+				common.Config.AuthInternalSecret = tt.configured
+				req := httptest.NewRequest(route.method, route.target, strings.NewReader(route.json))
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				if tt.secret != "" {
+					req.Header.Set(auth.InternalSecretHeader, tt.secret)
+				}
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				suite.Equal(tt.expectedStatusCode, rec.Code)
+			})
+		}
+	}
+
+	// nothing has been provisioned or added without the secret
+	_, err := suite.userRepo.Get("ldapadmin")
+	suite.NotNil(err)
+	passkeys, err := suite.userService.GetPasskeys("JohnDoe")
+	suite.Nil(err)
+	suite.Empty(passkeys)
+	user, err := suite.userRepo.Get("JohnDoe")
+	suite.Require().Nil(err)
+	suite.Empty(user.TOTPSecret)
+	suite.Zero(user.TOTPFailures)
+
+	common.Config.AuthInternalSecret = testInternalSecret
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, newInternalRequest(http.MethodPost, "/api/v1/user/provision", strings.NewReader(`{"username": "ldapadmin", "admin": true}`)))
+	suite.Equal(http.StatusOK, rec.Code)
+}
+
+func (suite *UserHandlerTestSuite) TestGetClaims() {
+	e := echo.New()
+	NewUserHandler(e, suite.userService, mocks.NewMockValidator())
+	suite.Require().Nil(suite.userService.CreateUser(&models.User{Username: "alice", Password: "strongpassword"}))
+	suite.Require().Nil(suite.userService.SetDisabled("alice", true))
+
+	testCases := []struct {
+		name               string
+		username           string
+		expectedStatusCode int
+	}{
+		{"Existing user", "JohnDoe", http.StatusOK},
+		{"Disabled user", "alice", http.StatusForbidden},
+		{"Unknown user", "nobody", http.StatusNotFound},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, newInternalRequest(http.MethodGet, "/api/v1/user/claims?username="+tt.username, nil))
+			suite.Equal(tt.expectedStatusCode, rec.Code)
+			if tt.expectedStatusCode == http.StatusOK {
+				suite.Contains(rec.Body.String(), `"username":"JohnDoe"`)
+				suite.Contains(rec.Body.String(), `"role":"user"`)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	authmw "github.com/0x113/x-media/auth/pkg/middleware"
+	"github.com/0x113/x-media/user/models"
+	"github.com/0x113/x-media/user/service"
+
+	"github.com/labstack/echo"
+)
+
+// userError converts the error of the user management to the response
+func userError(c echo.Context, err error) error {
+	errMsg := &models.Error{
+		Code:    http.StatusInternalServerError,
+		Message: err.Error(),
+	}
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		errMsg.Code = http.StatusNotFound
+	case errors.Is(err, service.ErrUserExists):
+		errMsg.Code = http.StatusConflict
+	case errors.Is(err, service.ErrWrongPassword):
+		errMsg.Code = http.StatusForbidden
+	case errors.Is(err, service.ErrInvalidUser):
+		errMsg.Code = http.StatusBadRequest
+	}
+	c.JSON(errMsg.Code, errMsg)
+	return err
+}
+
+// isSelf checks if the authenticated user is the user from the path
+func isSelf(c echo.Context) bool {
+	details := authmw.GetAccessDetails(c)
+	return details != nil && details.Username == c.Param("username")
+}
+
+// selfOrAdmin allows the users to access their own account, the other
+// accounts require the users:manage scope, must be used after authmw.Middleware
+func selfOrAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	manageUsers := authmw.RequireScope(models.ScopeUsersManage)(next)
+	return func(c echo.Context) error {
+		if isSelf(c) {
+			return next(c)
+		}
+		return manageUsers(c)
+	}
+}
+
+// notSelf keeps the admins from deleting, disabling or demoting their own
+// account, so at least one admin is always left
+func notSelf(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if isSelf(c) {
+			errMsg := &models.Error{
+				Code:    http.StatusConflict,
+				Message: "Admins can't delete, disable or demote their own account",
+			}
+			return c.JSON(errMsg.Code, errMsg)
+		}
+		return next(c)
+	}
+}
+
+// @Summary List users
+// @Description Returns the page of the users, admin only
+// @ID list-users
+// @Produce  json
+// @Param page query int false "Page number, starts at 1"
+// @Param per_page query int false "Users per page, 20 by default and 100 at most"
+// @Success 200 {object} models.UserPage
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /users [get]
+// ListUsers calls the service to get the page of the users
+func (h *userHandler) ListUsers(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	perPage, _ := strconv.Atoi(c.QueryParam("per_page"))
+
+	users, err := h.userService.ListUsers(page, perPage)
+	if err != nil {
+		return userError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, users)
+}
+
+// @Summary Get user
+// @Description Returns the user, the users can get their own account and the admins any account
+// @ID get-user
+// @Produce  json
+// @Param username path string true "Username"
+// @Success 200 {object} models.UserProfile
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /users/{username} [get]
+// GetUser calls the service to get the user
+func (h *userHandler) GetUser(c echo.Context) error {
+	user, err := h.userService.GetUser(c.Param("username"))
+	if err != nil {
+		return userError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, user.Profile())
+}
+
+// @Summary Update user
+// @Description Changes the email of the user, the role can be changed only by the admins and not in their own account
+// @ID update-user
+// @Accept  json
+// @Produce  json
+// @Param username path string true "Username"
+// @Param name body models.UserUpdate true "Changed fields"
+// @Success 200 {object} models.UserProfile
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /users/{username} [patch]
+// UpdateUser calls the service to update the user
+func (h *userHandler) UpdateUser(c echo.Context) error {
+	errMsg := new(models.Error)
+	update := new(models.UserUpdate)
+	if err := c.Bind(update); err != nil {
+		errMsg.Code = http.StatusBadRequest
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+	if update.Role != nil && !authmw.HasScope(authmw.GetAccessDetails(c), models.ScopeUsersManage) {
+		errMsg.Code = http.StatusForbidden
+		errMsg.Message = "The users:manage scope is required to change the role"
+		return c.JSON(errMsg.Code, errMsg)
+	}
+	// the admins can't demote themselves, so at least one admin is always left
+	if update.Role != nil && isSelf(c) {
+		errMsg.Code = http.StatusConflict
+		errMsg.Message = "Admins can't change the role of their own account"
+		return c.JSON(errMsg.Code, errMsg)
+	}
+
+	user, err := h.userService.UpdateUser(c.Param("username"), update)
+	if err != nil {
+		return userError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// @Summary Change password
+// @Description Sets the new password of the user. The users changing their own password must provide the current one, the admins can reset the password of the other users without it
+// @ID change-password
+// @Accept  json
+// @Produce  json
+// @Param username path string true "Username"
+// @Param name body models.PasswordChange true "Current and new password"
+// @Success 204
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /users/{username}/password [put]
+// ChangePassword calls the service to change or reset the password of the user
+func (h *userHandler) ChangePassword(c echo.Context) error {
+	req := new(models.PasswordChange)
+	if err := c.Bind(req); err != nil {
+		errMsg := &models.Error{Code: http.StatusBadRequest, Message: err.Error()}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	var err error
+	if isSelf(c) {
+		err = h.userService.ChangePassword(c.Param("username"), req)
+	} else {
+		err = h.userService.ResetPassword(c.Param("username"), req.NewPassword)
+	}
+	if err != nil {
+		return userError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Delete user
+// @Description Deletes the user along with the passkeys, admin only
+// @ID delete-user
+// @Produce  json
+// @Param username path string true "Username"
+// @Success 204
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /users/{username} [delete]
+// DeleteUser calls the service to delete the user
+func (h *userHandler) DeleteUser(c echo.Context) error {
+	if err := h.userService.DeleteUser(c.Param("username")); err != nil {
+		return userError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Disable user
+// @Description Disables the user, the disabled users can't sign in. Admin only
+// @ID disable-user
+// @Produce  json
+// @Param username path string true "Username"
+// @Success 204
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /users/{username}/disable [post]
+// DisableUser calls the service to disable the user
+func (h *userHandler) DisableUser(c echo.Context) error {
+	if err := h.userService.SetDisabled(c.Param("username"), true); err != nil {
+		return userError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Enable user
+// @Description Enables the disabled user, admin only
+// @ID enable-user
+// @Produce  json
+// @Param username path string true "Username"
+// @Success 204
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /users/{username}/enable [post]
+// EnableUser calls the service to enable the user
+func (h *userHandler) EnableUser(c echo.Context) error {
+	if err := h.userService.SetDisabled(c.Param("username"), false); err != nil {
+		return userError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Promote user
+// @Description Grants the admin role to the user, admin only
+// @ID promote-user
+// @Produce  json
+// @Param username path string true "Username"
+// @Success 204
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /users/{username}/admin [post]
+// PromoteUser calls the service to make the user an admin
+func (h *userHandler) PromoteUser(c echo.Context) error {
+	if err := h.userService.SetAdmin(c.Param("username"), true); err != nil {
+		return userError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Demote user
+// @Description Replaces the admin role of the user with the user role, admin only
+// @ID demote-user
+// @Produce  json
+// @Param username path string true "Username"
+// @Success 204
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /users/{username}/admin [delete]
+// DemoteUser calls the service to take the admin role from the user
+func (h *userHandler) DemoteUser(c echo.Context) error {
+	if err := h.userService.SetAdmin(c.Param("username"), false); err != nil {
+		return userError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
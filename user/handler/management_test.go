@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/0x113/x-media/user/mocks"
+	"github.com/0x113/x-media/user/models"
+
+	"github.com/labstack/echo"
+)
+
+func (suite *UserHandlerTestSuite) TestUserManagement() {
+	e := echo.New()
+	NewUserHandler(e, suite.userService, mocks.NewMockValidator())
+	suite.Require().Nil(suite.userRepo.Create(&models.User{ID: 1, Username: "admin", Role: models.RoleAdmin, IsAdmin: true}))
+	request := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	testCases := []struct {
+		name               string
+		method             string
+		target             string
+		token              string
+		body               string
+		expectedStatusCode int
+	}{
+		{"List without token", http.MethodGet, "/api/v1/user/users", "", "", http.StatusUnauthorized},
+		{"List as user", http.MethodGet, "/api/v1/user/users", "user", "", http.StatusForbidden},
+		{"List as admin", http.MethodGet, "/api/v1/user/users?page=1&per_page=1", "admin", "", http.StatusOK},
+		{"Get own account", http.MethodGet, "/api/v1/user/users/JohnDoe", "user", "", http.StatusOK},
+		{"Get another account", http.MethodGet, "/api/v1/user/users/admin", "user", "", http.StatusForbidden},
+		{"Get unknown user", http.MethodGet, "/api/v1/user/users/nobody", "admin", "", http.StatusNotFound},
+		{"Update own email", http.MethodPatch, "/api/v1/user/users/JohnDoe", "user", `{"email": "john@example.org"}`, http.StatusOK},
+		{"Update own role", http.MethodPatch, "/api/v1/user/users/JohnDoe", "user", `{"role": "admin"}`, http.StatusForbidden},
+		{"Duplicate email", http.MethodPatch, "/api/v1/user/users/admin", "admin", `{"email": "john@example.org"}`, http.StatusConflict},
+		{"Change role as admin", http.MethodPatch, "/api/v1/user/users/JohnDoe", "admin", `{"role": "editor"}`, http.StatusOK},
+		{"Change own role as admin", http.MethodPatch, "/api/v1/user/users/admin", "admin", `{"role": "user"}`, http.StatusConflict},
+		{"Invalid email", http.MethodPatch, "/api/v1/user/users/JohnDoe", "user", `{"email": "john"}`, http.StatusBadRequest},
+		{"Invalid role", http.MethodPatch, "/api/v1/user/users/JohnDoe", "admin", `{"role": "root"}`, http.StatusBadRequest},
+		{"Too short password", http.MethodPut, "/api/v1/user/users/JohnDoe/password", "user", `{"current_password": "test1231", "new_password": "short"}`, http.StatusBadRequest},
+		{"Reset too short password", http.MethodPut, "/api/v1/user/users/JohnDoe/password", "admin", `{"new_password": "short"}`, http.StatusBadRequest},
+		{"Change password with wrong current", http.MethodPut, "/api/v1/user/users/JohnDoe/password", "user", `{"current_password": "wrong", "new_password": "newpassword"}`, http.StatusForbidden},
+		{"Change own password", http.MethodPut, "/api/v1/user/users/JohnDoe/password", "user", `{"current_password": "test1231", "new_password": "newpassword"}`, http.StatusNoContent},
+		{"Reset password as user", http.MethodPut, "/api/v1/user/users/admin/password", "user", `{"new_password": "newpassword"}`, http.StatusForbidden},
+		{"Reset password as admin", http.MethodPut, "/api/v1/user/users/JohnDoe/password", "admin", `{"new_password": "resetpassword"}`, http.StatusNoContent},
+		{"Disable as user", http.MethodPost, "/api/v1/user/users/JohnDoe/disable", "user", "", http.StatusForbidden},
+		{"Disable own account", http.MethodPost, "/api/v1/user/users/admin/disable", "admin", "", http.StatusConflict},
+		{"Disable", http.MethodPost, "/api/v1/user/users/JohnDoe/disable", "admin", "", http.StatusNoContent},
+		{"Enable", http.MethodPost, "/api/v1/user/users/JohnDoe/enable", "admin", "", http.StatusNoContent},
+		{"Promote", http.MethodPost, "/api/v1/user/users/JohnDoe/admin", "admin", "", http.StatusNoContent},
+		{"Demote own account", http.MethodDelete, "/api/v1/user/users/admin/admin", "admin", "", http.StatusConflict},
+		{"Demote", http.MethodDelete, "/api/v1/user/users/JohnDoe/admin", "admin", "", http.StatusNoContent},
+		{"Promote unknown user", http.MethodPost, "/api/v1/user/users/nobody/admin", "admin", "", http.StatusNotFound},
+		{"Delete as user", http.MethodDelete, "/api/v1/user/users/JohnDoe", "user", "", http.StatusForbidden},
+		{"Delete own account", http.MethodDelete, "/api/v1/user/users/admin", "admin", "", http.StatusConflict},
+		{"Delete", http.MethodDelete, "/api/v1/user/users/JohnDoe", "admin", "", http.StatusNoContent},
+		{"Delete unknown user", http.MethodDelete, "/api/v1/user/users/JohnDoe", "admin", "", http.StatusNotFound},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			rec := request(tt.method, tt.target, tt.token, tt.body)
+			suite.Equal(tt.expectedStatusCode, rec.Code, rec.Body.String())
+			suite.NotContains(rec.Body.String(), "$2a$")
+		})
+	}
+
+	rec := request(http.MethodGet, "/api/v1/user/users?per_page=1", "admin", "")
+	page := new(models.UserPage)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), page))
+	suite.Equal(1, page.Total)
+	suite.Require().Len(page.Users, 1)
+	suite.Equal("admin", page.Users[0].Username)
+}
+
+func (suite *UserHandlerTestSuite) TestCreateDuplicateUser() {
+	e := echo.New()
+	NewUserHandler(e, suite.userService, mocks.NewMockValidator())
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/user/create", strings.NewReader(`{"username": "JohnDoe", "password": "strongpassword"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	suite.Equal(http.StatusConflict, rec.Code)
+}
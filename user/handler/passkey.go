@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/0x113/x-media/user/models"
+	"github.com/0x113/x-media/user/service"
+
+	"github.com/labstack/echo"
+)
+
+// passkeyError converts the error of the passkey operation to the response
+func passkeyError(c echo.Context, err error) error {
+	errMsg := &models.Error{
+		Code:    http.StatusInternalServerError,
+		Message: err.Error(),
+	}
+	switch {
+	case errors.Is(err, service.ErrPasskeyNotFound):
+		errMsg.Code = http.StatusNotFound
+	case errors.Is(err, service.ErrSignCount):
+		errMsg.Code = http.StatusForbidden
+	case errors.Is(err, service.ErrLastCredential):
+		errMsg.Code = http.StatusConflict
+	}
+	c.JSON(errMsg.Code, errMsg)
+	return err
+}
+
+// @Summary Add passkey
+// @Description Stores the passkey verified by the authentication service
+// @ID add-passkey
+// @Accept  json
+// @Produce  json
+// @Param name body models.Passkey true "Passkey"
+// @Param X-Internal-Secret header string true "Shared secret of the services"
+// @Success 201 {object} models.Message
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /passkeys [post]
+// AddPasskey calls the service to store new passkey of the user
+func (h *userHandler) AddPasskey(c echo.Context) error {
+	p := new(models.Passkey)
+	if err := c.Bind(p); err != nil {
+		errMsg := &models.Error{Code: http.StatusBadRequest, Message: err.Error()}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	if err := h.userService.AddPasskey(p); err != nil {
+		return passkeyError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, &models.Message{Message: "Successfully added new passkey"})
+}
+
+// @Summary Get passkeys
+// @Description Returns the passkeys of the user
+// @ID get-passkeys
+// @Produce  json
+// @Param username query string true "Username"
+// @Param X-Internal-Secret header string true "Shared secret of the services"
+// @Success 200 {array} models.Passkey
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /passkeys [get]
+// GetPasskeys calls the service to get the passkeys of the user
+func (h *userHandler) GetPasskeys(c echo.Context) error {
+	passkeys, err := h.userService.GetPasskeys(c.QueryParam("username"))
+	if err != nil {
+		return passkeyError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, passkeys)
+}
+
+// @Summary Get passkey
+// @Description Returns the passkey with provided credential id along with its owner
+// @ID get-passkey
+// @Produce  json
+// @Param id path string true "Base64url encoded credential id"
+// @Param X-Internal-Secret header string true "Shared secret of the services"
+// @Success 200 {object} models.Passkey
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Router /passkeys/{id} [get]
+// GetPasskey calls the service to get the passkey
+func (h *userHandler) GetPasskey(c echo.Context) error {
+	p, err := h.userService.GetPasskey(c.Param("id"))
+	if err != nil {
+		return passkeyError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// @Summary Log in with passkey
+// @Description Records the usage of the passkey whose assertion has been verified by the authentication service
+// @ID login-passkey
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Base64url encoded credential id"
+// @Param name body models.PasskeyLogin true "Signature counter"
+// @Param X-Internal-Secret header string true "Shared secret of the services"
+// @Success 200 {object} models.TokenClaims
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /passkeys/{id}/login [post]
+// LoginPasskey calls the service to check the signature counter and returns the claims of the user
+func (h *userHandler) LoginPasskey(c echo.Context) error {
+	login := new(models.PasskeyLogin)
+	if err := c.Bind(login); err != nil {
+		errMsg := &models.Error{Code: http.StatusBadRequest, Message: err.Error()}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	claims, err := h.userService.LoginPasskey(c.Param("id"), login)
+	if err != nil {
+		return passkeyError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, claims)
+}
+
+// @Summary Delete passkey
+// @Description Deletes the passkey of the user, the last passkey of the account without the password can't be deleted
+// @ID delete-passkey
+// @Produce  json
+// @Param id path string true "Base64url encoded credential id"
+// @Param username query string true "Username"
+// @Param X-Internal-Secret header string true "Shared secret of the services"
+// @Success 204
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Router /passkeys/{id} [delete]
+// DeletePasskey calls the service to delete the passkey of the user
+func (h *userHandler) DeletePasskey(c echo.Context) error {
+	if err := h.userService.DeletePasskey(c.QueryParam("username"), c.Param("id")); err != nil {
+		return passkeyError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// @Summary Remove password
+// @Description Removes the password of the user with at least one passkey
+// @ID remove-password
+// @Produce  json
+// @Param username query string true "Username"
+// @Param X-Internal-Secret header string true "Shared secret of the services"
+// @Success 204
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /password [delete]
+// RemovePassword calls the service to remove the password of the user
+func (h *userHandler) RemovePassword(c echo.Context) error {
+	if err := h.userService.RemovePassword(c.QueryParam("username")); err != nil {
+		return passkeyError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/0x113/x-media/user/mocks"
+	"github.com/0x113/x-media/user/models"
+
+	"github.com/labstack/echo"
+)
+
+func (suite *UserHandlerTestSuite) TestPasskeys() {
+	e := echo.New()
+	NewUserHandler(e, suite.userService, mocks.NewMockValidator())
+	request := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, newInternalRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := request(http.MethodPost, "/api/v1/user/passkeys", `{"id": "cred-1", "username": "JohnDoe", "name": "YubiKey", "public_key": "AQID", "sign_count": 1}`)
+	suite.Equal(http.StatusCreated, rec.Code)
+	rec = request(http.MethodPost, "/api/v1/user/passkeys", `{"id": "cred-1"}`)
+	suite.Equal(http.StatusInternalServerError, rec.Code)
+
+	rec = request(http.MethodGet, "/api/v1/user/passkeys?username=JohnDoe", "")
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Contains(rec.Body.String(), `"name":"YubiKey"`)
+
+	rec = request(http.MethodGet, "/api/v1/user/passkeys/cred-1", "")
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Contains(rec.Body.String(), `"public_key":"AQID"`)
+	rec = request(http.MethodGet, "/api/v1/user/passkeys/cred-2", "")
+	suite.Equal(http.StatusNotFound, rec.Code)
+
+	rec = request(http.MethodPost, "/api/v1/user/passkeys/cred-1/login", `{"sign_count": 2}`)
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Contains(rec.Body.String(), `"username":"JohnDoe"`)
+	rec = request(http.MethodPost, "/api/v1/user/passkeys/cred-1/login", `{"sign_count": 2}`)
+	suite.Equal(http.StatusForbidden, rec.Code)
+
+	rec = request(http.MethodDelete, "/api/v1/user/password?username=JohnDoe", "")
+	suite.Equal(http.StatusNoContent, rec.Code)
+	rec = request(http.MethodDelete, "/api/v1/user/passkeys/cred-1?username=JohnDoe", "")
+	suite.Equal(http.StatusConflict, rec.Code)
+
+	suite.userService.AddPasskey(&models.Passkey{ID: "cred-2", Username: "JohnDoe", PublicKey: []byte{1}})
+	rec = request(http.MethodDelete, "/api/v1/user/passkeys/cred-1?username=JohnDoe", "")
+	suite.Equal(http.StatusNoContent, rec.Code)
+	rec = request(http.MethodDelete, "/api/v1/user/passkeys/cred-1?username=JohnDoe", "")
+	suite.Equal(http.StatusNotFound, rec.Code)
+}
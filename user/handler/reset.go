@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	authmw "github.com/0x113/x-media/auth/pkg/middleware"
+	"github.com/0x113/x-media/user/common"
+	"github.com/0x113/x-media/user/models"
+	"github.com/0x113/x-media/user/service"
+
+	"github.com/labstack/echo"
+)
+
+// resetError converts the error of the password reset to the response
+func resetError(c echo.Context, err error) error {
+	errMsg := &models.Error{
+		Code:    http.StatusInternalServerError,
+		Message: err.Error(),
+	}
+	if errors.Is(err, service.ErrInvalidResetRequest) || errors.Is(err, service.ErrResetTokenInvalid) {
+		errMsg.Code = http.StatusBadRequest
+	}
+	c.JSON(errMsg.Code, errMsg)
+	return err
+}
+
+// @Summary Request password reset
+// @Description Sends the one-time password reset link to the email. The response is the same whether the email is registered, the requests are over the limits of the email or the client address, or the email couldn't be sent
+// @ID request-password-reset
+// @Accept  json
+// @Produce  json
+// @Param name body models.PasswordResetRequest true "Email of the account"
+// @Success 202
+// @Failure 400 {object} models.Error
+// @Router /password/reset [post]
+// RequestPasswordReset calls the service to send the password reset email
+func (h *userHandler) RequestPasswordReset(c echo.Context) error {
+	req := new(models.PasswordResetRequest)
+	if err := c.Bind(req); err != nil {
+		errMsg := &models.Error{Code: http.StatusBadRequest, Message: err.Error()}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	if err := h.userService.RequestPasswordReset(req, authmw.ClientIP(c.Request(), common.Config.TrustedProxies)); err != nil {
+		return resetError(c, err)
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// @Summary Confirm password reset
+// @Description Sets the new password with the token from the password reset email and signs the user out of all of the devices
+// @ID confirm-password-reset
+// @Accept  json
+// @Produce  json
+// @Param name body models.PasswordResetConfirm true "Token and new password"
+// @Success 204
+// @Failure 400 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /password/reset/confirm [post]
+// ConfirmPasswordReset calls the service to set the new password with the reset token
+func (h *userHandler) ConfirmPasswordReset(c echo.Context) error {
+	req := new(models.PasswordResetConfirm)
+	if err := c.Bind(req); err != nil {
+		errMsg := &models.Error{Code: http.StatusBadRequest, Message: err.Error()}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	if err := h.userService.ConfirmPasswordReset(req); err != nil {
+		return resetError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+
+	"github.com/0x113/x-media/user/mocks"
+	"github.com/0x113/x-media/user/models"
+
+	"github.com/labstack/echo"
+)
+
+func (suite *UserHandlerTestSuite) TestPasswordReset() {
+	e := echo.New()
+	NewUserHandler(e, suite.userService, mocks.NewMockValidator())
+	email := "john@example.org"
+	_, err := suite.userService.UpdateUser("JohnDoe", &models.UserUpdate{Email: &email})
+	suite.Require().Nil(err)
+	request := func(target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// the unknown emails get the same response
+	rec := request("/api/v1/user/password/reset", `{"email": "nobody@example.org"}`)
+	suite.Equal(http.StatusAccepted, rec.Code)
+	suite.Empty(suite.mailer.Messages)
+	rec = request("/api/v1/user/password/reset", `{"email": "not an email"}`)
+	suite.Equal(http.StatusBadRequest, rec.Code)
+
+	rec = request("/api/v1/user/password/reset", `{"email": "john@example.org"}`)
+	suite.Equal(http.StatusAccepted, rec.Code)
+	suite.Require().Len(suite.mailer.Messages, 1)
+	match := regexp.MustCompile(`token=([A-Za-z0-9_-]+)`).FindStringSubmatch(suite.mailer.Messages[0].Body)
+	suite.Require().Len(match, 2)
+	token := match[1]
+
+	testCases := []struct {
+		name               string
+		body               string
+		expectedStatusCode int
+	}{
+		{"Too short", `{"token": "` + token + `", "new_password": "short"}`, http.StatusBadRequest},
+		{"Unknown token", `{"token": "unknown", "new_password": "newpassword"}`, http.StatusBadRequest},
+		{"Success", `{"token": "` + token + `", "new_password": "newpassword"}`, http.StatusNoContent},
+		{"Used token", `{"token": "` + token + `", "new_password": "newpassword"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			rec := request("/api/v1/user/password/reset/confirm", tt.body)
+			suite.Equal(tt.expectedStatusCode, rec.Code, rec.Body.String())
+		})
+	}
+
+	suite.Equal([]string{"JohnDoe"}, suite.sessions.Revoked)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, newInternalRequest(http.MethodPost, "/api/v1/user/validate", strings.NewReader(`{"username": "JohnDoe", "password": "newpassword"}`)))
+	suite.Equal(http.StatusOK, rec.Code)
+
+	// the failed emails and the requests over the limit get the same response
+	suite.mailer.Err = errors.New("connection refused")
+	rec = request("/api/v1/user/password/reset", `{"email": "john@example.org"}`)
+	suite.Equal(http.StatusAccepted, rec.Code)
+	suite.mailer.Err = nil
+	for i := 0; i < 2; i++ {
+		rec = request("/api/v1/user/password/reset", `{"email": "john@example.org"}`)
+		suite.Equal(http.StatusAccepted, rec.Code)
+	}
+	suite.Len(suite.mailer.Messages, 2)
+}
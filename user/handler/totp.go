@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/0x113/x-media/user/models"
+	"github.com/0x113/x-media/user/service"
+
+	"github.com/labstack/echo"
+)
+
+// totpError converts the error of the two-factor authentication to the response
+func totpError(c echo.Context, err error) error {
+	errMsg := &models.Error{
+		Code:    http.StatusInternalServerError,
+		Message: err.Error(),
+	}
+	switch {
+	case errors.Is(err, service.ErrTOTPInvalid):
+		errMsg.Code = http.StatusUnauthorized
+	case errors.Is(err, service.ErrTOTPEnabled), errors.Is(err, service.ErrTOTPDisabled):
+		errMsg.Code = http.StatusConflict
+	}
+	var lockedErr *service.TOTPLockedError
+	if errors.As(err, &lockedErr) {
+		errMsg.Code = http.StatusTooManyRequests
+		c.Response().Header().Set("Retry-After", strconv.Itoa(lockedErr.RetryAfterSeconds()))
+	}
+	c.JSON(errMsg.Code, errMsg)
+	return err
+}
+
+// bindTOTPRequest binds the username and code of the request
+func bindTOTPRequest(c echo.Context) (*models.TOTPRequest, error) {
+	req := new(models.TOTPRequest)
+	if err := c.Bind(req); err != nil {
+		errMsg := &models.Error{Code: http.StatusBadRequest, Message: err.Error()}
+		c.JSON(errMsg.Code, errMsg)
+		return nil, err
+	}
+	return req, nil
+}
+
+// @Summary Enroll authenticator app
+// @Description Generates the TOTP secret and recovery codes of the user, two-factor authentication is enabled after the confirmation
+// @ID enroll-totp
+// @Accept  json
+// @Produce  json
+// @Param name body models.TOTPEnrollRequest true "User"
+// @Param X-Internal-Secret header string true "Shared secret of the services"
+// @Success 200 {object} models.TOTPEnrollment
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /totp/enroll [post]
+// EnrollTOTP calls the service to generate the secret of the authenticator app
+func (h *userHandler) EnrollTOTP(c echo.Context) error {
+	req := new(models.TOTPEnrollRequest)
+	if err := c.Bind(req); err != nil {
+		errMsg := &models.Error{Code: http.StatusBadRequest, Message: err.Error()}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	enrollment, err := h.userService.EnrollTOTP(req)
+	if err != nil {
+		return totpError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, enrollment)
+}
+
+// @Summary Confirm authenticator app
+// @Description Enables two-factor authentication after the user provides the first code from the authenticator app
+// @ID confirm-totp
+// @Accept  json
+// @Produce  json
+// @Param name body models.TOTPRequest true "Username and code"
+// @Param X-Internal-Secret header string true "Shared secret of the services"
+// @Success 200 {object} models.Message
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 429 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /totp/confirm [post]
+// ConfirmTOTP calls the service to enable two-factor authentication
+func (h *userHandler) ConfirmTOTP(c echo.Context) error {
+	req, err := bindTOTPRequest(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.userService.ConfirmTOTP(req); err != nil {
+		return totpError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, &models.Message{Message: "Successfully enabled two-factor authentication"})
+}
+
+// @Summary Verify second factor
+// @Description Checks the code from the authenticator app or one of the recovery codes after the user provided the password
+// @ID verify-totp
+// @Accept  json
+// @Produce  json
+// @Param name body models.TOTPRequest true "Username and code"
+// @Param X-Internal-Secret header string true "Shared secret of the services"
+// @Success 200 {object} models.TokenClaims
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 429 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /totp/verify [post]
+// VerifyTOTP calls the service to check the second factor of the user
+func (h *userHandler) VerifyTOTP(c echo.Context) error {
+	req, err := bindTOTPRequest(c)
+	if err != nil {
+		return err
+	}
+
+	claims, err := h.userService.VerifyTOTP(req)
+	if err != nil {
+		return totpError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, claims)
+}
+
+// @Summary Disable two-factor authentication
+// @Description Removes the TOTP secret and recovery codes of the user, requires the current code or one of the recovery codes
+// @ID disable-totp
+// @Accept  json
+// @Produce  json
+// @Param name body models.TOTPRequest true "Username and code"
+// @Param X-Internal-Secret header string true "Shared secret of the services"
+// @Success 200 {object} models.Message
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 429 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /totp/disable [post]
+// DisableTOTP calls the service to disable two-factor authentication
+func (h *userHandler) DisableTOTP(c echo.Context) error {
+	req, err := bindTOTPRequest(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.userService.DisableTOTP(req); err != nil {
+		return totpError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, &models.Message{Message: "Successfully disabled two-factor authentication"})
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/0x113/x-media/user/mocks"
+	"github.com/0x113/x-media/user/models"
+
+	"github.com/labstack/echo"
+)
+
+func (suite *UserHandlerTestSuite) TestTOTP() {
+	e := echo.New()
+	NewUserHandler(e, suite.userService, mocks.NewMockValidator())
+	request := func(target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, newInternalRequest(http.MethodPost, target, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := request("/api/v1/user/totp/enroll", `{"username": "JohnDoe"}`)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	enrollment := new(models.TOTPEnrollment)
+	suite.Require().Nil(json.Unmarshal(rec.Body.Bytes(), enrollment))
+
+	rec = request("/api/v1/user/totp/confirm", `{"username": "JohnDoe", "code": "12345"}`)
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+	rec = request("/api/v1/user/totp/confirm", `{"username": "JohnDoe", "code": "`+mocks.TOTPCode(enrollment.Secret, time.Now())+`"}`)
+	suite.Equal(http.StatusOK, rec.Code)
+	rec = request("/api/v1/user/totp/enroll", `{"username": "JohnDoe"}`)
+	suite.Equal(http.StatusConflict, rec.Code)
+
+	rec = request("/api/v1/user/validate", `{"username": "JohnDoe", "password": "test1231"}`)
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Contains(rec.Body.String(), `"mfa_required":true`)
+
+	rec = request("/api/v1/user/totp/verify", `{"username": "JohnDoe", "code": "`+enrollment.RecoveryCodes[0]+`"}`)
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.NotContains(rec.Body.String(), "mfa_required")
+	rec = request("/api/v1/user/totp/verify", `{"username": "JohnDoe", "code": "`+enrollment.RecoveryCodes[0]+`"}`)
+	suite.Equal(http.StatusUnauthorized, rec.Code)
+	rec = request("/api/v1/user/totp/verify", `{"username": "JohnDoe"}`)
+	suite.Equal(http.StatusInternalServerError, rec.Code)
+
+	// the user is locked out after too many invalid codes, even the valid one is rejected
+	for i := 0; i < 4; i++ {
+		rec = request("/api/v1/user/totp/verify", `{"username": "JohnDoe", "code": "000000"}`)
+		suite.Equal(http.StatusUnauthorized, rec.Code)
+	}
+	rec = request("/api/v1/user/totp/verify", `{"username": "JohnDoe", "code": "`+enrollment.RecoveryCodes[1]+`"}`)
+	suite.Equal(http.StatusTooManyRequests, rec.Code)
+	suite.Equal("60", rec.Header().Get("Retry-After"))
+	user, err := suite.userRepo.Get("JohnDoe")
+	suite.Require().Nil(err)
+	user.TOTPLockedUntil = nil
+	suite.Require().Nil(suite.userRepo.Update(user))
+
+	rec = request("/api/v1/user/totp/disable", `{"username": "JohnDoe", "code": "`+enrollment.RecoveryCodes[1]+`"}`)
+	suite.Equal(http.StatusOK, rec.Code)
+	rec = request("/api/v1/user/totp/verify", `{"username": "JohnDoe", "code": "`+enrollment.RecoveryCodes[2]+`"}`)
+	suite.Equal(http.StatusConflict, rec.Code)
+}
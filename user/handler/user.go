@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
+	authmw "github.com/0x113/x-media/auth/pkg/middleware"
 	"github.com/0x113/x-media/user/models"
 	"github.com/0x113/x-media/user/service"
 
@@ -14,8 +16,10 @@ type userHandler struct {
 	userService service.UserService
 }
 
-// NewUserHandler initiates user handlers
-func NewUserHandler(router *echo.Echo, userService service.UserService) {
+// NewUserHandler initiates user handlers, the internal routes are called only by
+// the authentication service with the shared secret and the management routes
+// require a valid token
+func NewUserHandler(router *echo.Echo, userService service.UserService, validator authmw.Validator) {
 	handler := &userHandler{userService}
 	// swagger
 	opts := middleware.RedocOpts{SpecURL: "/swagger.yaml"}
@@ -24,7 +28,35 @@ func NewUserHandler(router *echo.Echo, userService service.UserService) {
 	router.GET("/docs", echo.WrapHandler(sh))
 
 	router.POST("/api/v1/user/create", handler.CreateUser)
-	router.POST("/api/v1/user/validate", handler.ValidateUser)
+	router.POST("/api/v1/user/validate", handler.ValidateUser, requireInternalSecret)
+	router.POST("/api/v1/user/provision", handler.ProvisionUser, requireInternalSecret)
+	router.GET("/api/v1/user/claims", handler.GetClaims, requireInternalSecret)
+	router.DELETE("/api/v1/user/password", handler.RemovePassword, requireInternalSecret)
+	router.POST("/api/v1/user/password/reset", handler.RequestPasswordReset)
+	router.POST("/api/v1/user/password/reset/confirm", handler.ConfirmPasswordReset)
+
+	router.POST("/api/v1/user/passkeys", handler.AddPasskey, requireInternalSecret)
+	router.GET("/api/v1/user/passkeys", handler.GetPasskeys, requireInternalSecret)
+	router.GET("/api/v1/user/passkeys/:id", handler.GetPasskey, requireInternalSecret)
+	router.POST("/api/v1/user/passkeys/:id/login", handler.LoginPasskey, requireInternalSecret)
+	router.DELETE("/api/v1/user/passkeys/:id", handler.DeletePasskey, requireInternalSecret)
+
+	router.POST("/api/v1/user/totp/enroll", handler.EnrollTOTP, requireInternalSecret)
+	router.POST("/api/v1/user/totp/confirm", handler.ConfirmTOTP, requireInternalSecret)
+	router.POST("/api/v1/user/totp/verify", handler.VerifyTOTP, requireInternalSecret)
+	router.POST("/api/v1/user/totp/disable", handler.DisableTOTP, requireInternalSecret)
+
+	manageUsers := authmw.RequireScope(models.ScopeUsersManage)
+	users := router.Group("/api/v1/user/users", authmw.Middleware(validator))
+	users.GET("", handler.ListUsers, manageUsers)
+	users.GET("/:username", handler.GetUser, selfOrAdmin)
+	users.PATCH("/:username", handler.UpdateUser, selfOrAdmin)
+	users.PUT("/:username/password", handler.ChangePassword, selfOrAdmin)
+	users.DELETE("/:username", handler.DeleteUser, manageUsers, notSelf)
+	users.POST("/:username/disable", handler.DisableUser, manageUsers, notSelf)
+	users.POST("/:username/enable", handler.EnableUser, manageUsers)
+	users.POST("/:username/admin", handler.PromoteUser, manageUsers)
+	users.DELETE("/:username/admin", handler.DemoteUser, manageUsers, notSelf)
 }
 
 // @Summary Create user
@@ -35,6 +67,7 @@ func NewUserHandler(router *echo.Echo, userService service.UserService) {
 // @Param name body userPayload true "User credentials"
 // @Success 201 {object} userCreateResponse
 // @Failure 400 {object} models.Error
+// @Failure 409 {object} models.Error
 // @Failure 500 {object} models.Error
 // @Router /create [post]
 // CreateUser calls service layer to create a new user in the database
@@ -50,12 +83,15 @@ func (h *userHandler) CreateUser(c echo.Context) error {
 
 	if err := h.userService.CreateUser(u); err != nil {
 		errMsg.Code = http.StatusInternalServerError
+		if errors.Is(err, service.ErrUserExists) {
+			errMsg.Code = http.StatusConflict
+		}
 		errMsg.Message = err.Error()
 		c.JSON(errMsg.Code, errMsg)
 		return err
 	}
 
-	msg := &models.Message{"Successfully create new user"}
+	msg := &models.Message{Message: "Successfully create new user"}
 	return c.JSON(http.StatusCreated, msg)
 }
 
@@ -65,8 +101,11 @@ func (h *userHandler) CreateUser(c echo.Context) error {
 // @Accept  json
 // @Produce  json
 // @Param name body userValidatePayload true "User credentials"
+// @Param X-Internal-Secret header string true "Shared secret of the services"
 // @Success 200 {object} models.TokenClaims
 // @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
 // @Failure 500 {object} models.Error
 // @Router /validate [post]
 // ValidateUser calls the service to check if provided credentials matches with
@@ -92,3 +131,80 @@ func (h *userHandler) ValidateUser(c echo.Context) error {
 	return c.JSON(http.StatusOK, claims)
 
 }
+
+// @Summary Provision user
+// @Description Creates or updates the local record of the user authenticated by an external identity provider
+// @ID provision-user
+// @Accept  json
+// @Produce  json
+// @Param name body provisionPayload true "Externally authenticated user"
+// @Param X-Internal-Secret header string true "Shared secret of the services"
+// @Success 200 {object} models.TokenClaims
+// @Failure 400 {object} models.Error
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 409 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /provision [post]
+// ProvisionUser calls the service to make sure that the externally
+// authenticated user has a local record
+func (h *userHandler) ProvisionUser(c echo.Context) error {
+	errMsg := new(models.Error)
+	req := new(models.ProvisionRequest)
+	if err := c.Bind(req); err != nil {
+		errMsg.Code = http.StatusBadRequest
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	claims, err := h.userService.ProvisionUser(req)
+	if err != nil {
+		errMsg.Code = http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			errMsg.Code = http.StatusNotFound
+		case errors.Is(err, service.ErrUserHasPassword):
+			errMsg.Code = http.StatusConflict
+		}
+		errMsg.Message = err.Error()
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, claims)
+}
+
+// @Summary Get user claims
+// @Description Returns the current claims of the user, the authentication service checks them on every refresh
+// @ID get-claims
+// @Produce  json
+// @Param username query string true "Username"
+// @Param X-Internal-Secret header string true "Shared secret of the services"
+// @Success 200 {object} models.TokenClaims
+// @Failure 401 {object} models.Error
+// @Failure 403 {object} models.Error
+// @Failure 404 {object} models.Error
+// @Failure 500 {object} models.Error
+// @Router /claims [get]
+// GetClaims calls the service to get the current claims of the user
+func (h *userHandler) GetClaims(c echo.Context) error {
+	claims, err := h.userService.GetClaims(c.QueryParam("username"))
+	if err != nil {
+		errMsg := &models.Error{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			errMsg.Code = http.StatusNotFound
+		case errors.Is(err, service.ErrUserDisabled):
+			errMsg.Code = http.StatusForbidden
+		}
+		c.JSON(errMsg.Code, errMsg)
+		return err
+	}
+
+	return c.JSON(http.StatusOK, claims)
+}
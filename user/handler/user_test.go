@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/0x113/x-media/user/common"
+	"github.com/0x113/x-media/user/data"
 	"github.com/0x113/x-media/user/mocks"
 	"github.com/0x113/x-media/user/service"
 
@@ -18,20 +20,41 @@ import (
 // UserHandlerTestSuite defines the test suite
 type UserHandlerTestSuite struct {
 	suite.Suite
-	userRepo    *mocks.MockUserRepository
+	backend     *mocks.Backend
+	repos       *mocks.Repositories
+	userRepo    data.UserRepository
+	passkeyRepo data.PasskeyRepository
+	resetRepo   data.PasswordResetRepository
+	mailer      *mocks.MockMailSender
+	sessions    *mocks.MockSessionRevoker
 	userService service.UserService
 }
 
-// SetupTest inititates mocked database and new user service
+// SetupTest opens the database of the backend and creates new user service
 func (suite *UserHandlerTestSuite) SetupTest() {
-	suite.userRepo = mocks.NewMockUserRepository()
-	suite.userService = service.NewUserService(suite.userRepo)
+	common.Config = &common.Configuration{TOTPIssuer: "x-media", AuthInternalSecret: testInternalSecret, PasswordResetURL: "http://localhost:3000/reset-password"}
 	logrus.SetOutput(ioutil.Discard)
+	repos, err := suite.backend.Open()
+	suite.Require().Nil(err)
+	suite.repos = repos
+	suite.userRepo, suite.passkeyRepo, suite.resetRepo = repos.Users, repos.Passkeys, repos.Resets
+	suite.mailer = new(mocks.MockMailSender)
+	suite.sessions = new(mocks.MockSessionRevoker)
+	suite.userService = service.NewUserService(suite.userRepo, suite.passkeyRepo, suite.resetRepo, suite.mailer, suite.sessions)
+}
+
+// TearDownTest closes the database of the backend
+func (suite *UserHandlerTestSuite) TearDownTest() {
+	suite.repos.Close()
 }
 
 // TestUserHandlerTestSuite runs the test suite
 func TestUserHandlerTestSuite(t *testing.T) {
-	suite.Run(t, new(UserHandlerTestSuite))
+	for _, backend := range mocks.Backends() {
+		t.Run(backend.Name, func(t *testing.T) {
+			suite.Run(t, &UserHandlerTestSuite{backend: backend})
+		})
+	}
 }
 
 func (suite *UserHandlerTestSuite) TestCreateUser() {
@@ -125,3 +148,61 @@ func (suite *UserHandlerTestSuite) TestValidateUser() {
 		})
 	}
 }
+
+func (suite *UserHandlerTestSuite) TestProvisionUser() {
+	e := echo.New()
+	h := &userHandler{suite.userService}
+	testCases := []struct {
+		name               string
+		json               string
+		expectedStatusCode int
+		wantErr            bool
+	}{
+		{
+			name:               "Success",
+			json:               `{"username": "ldapuser", "admin": true}`,
+			expectedStatusCode: 200,
+			wantErr:            false,
+		},
+		{
+			name:               "Bad request",
+			json:               `{"username: "ldapuser"}`,
+			expectedStatusCode: 400,
+			wantErr:            true,
+		},
+		{
+			name:               "Invalid username",
+			json:               `{"username": "x"}`,
+			expectedStatusCode: 500,
+			wantErr:            true,
+		},
+		{
+			name:               "Unknown user",
+			json:               `{"username": "stranger", "existing_only": true}`,
+			expectedStatusCode: 404,
+			wantErr:            true,
+		},
+		{
+			name:               "User with the password",
+			json:               `{"username": "JohnDoe", "without_password": true}`,
+			expectedStatusCode: 409,
+			wantErr:            true,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/user/provision", strings.NewReader(tt.json))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			err := h.ProvisionUser(c)
+			if tt.wantErr {
+				suite.NotNil(err)
+			} else {
+				suite.Nil(err)
+			}
+			suite.Equal(tt.expectedStatusCode, rec.Code)
+		})
+	}
+}
@@ -1,13 +1,4 @@
 CREATE DATABASE IF NOT EXISTS xmedia_users;
 USE xmedia_users;
 
-CREATE TABLE IF NOT EXISTS user (
-  user_id int NOT NULL AUTO_INCREMENT,
-  username varchar(255) NOT NULL UNIQUE,
-  password varchar(60) NOT NULL,
-  is_admin BOOLEAN NOT NULL,
-  created_at DATETIME NOT NULL,
-  updated_at DATETIME NOT NULL,
-  PRIMARY KEY(user_id)
-);
-
+-- the tables are created by the migrations of the user service, see migrations/sql/mysql
@@ -0,0 +1,227 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x113/x-media/user/common"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// SMTPSender delivers the emails through the SMTP server
+	SMTPSender = "smtp"
+	// FileSender appends the emails to the file, meant for the local development
+	FileSender = "file"
+	// LogSender writes the emails to the log, meant for the local development
+	LogSender = "log"
+
+	defaultFrom     = "x-media <no-reply@localhost>"
+	defaultFile     = "logs/mail.log"
+	defaultSMTPPort = "587"
+	queueSize       = 100
+)
+
+// Message defines the plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers the emails
+type Sender interface {
+	Send(msg *Message) error
+}
+
+// NewSender creates the sender based on the configuration, the emails are
+// written to the log when no sender is configured
+func NewSender() (Sender, error) {
+	from := common.Config.MailFrom
+	if from == "" {
+		from = defaultFrom
+	}
+
+	switch common.Config.MailSender {
+	case SMTPSender:
+		if common.Config.SMTPHost == "" {
+			return nil, fmt.Errorf("SMTP host must be configured for the smtp mail sender")
+		}
+		port := common.Config.SMTPPort
+		if port == "" {
+			port = defaultSMTPPort
+		}
+		return NewSMTPSender(common.Config.SMTPHost, port, common.Config.SMTPUsername, common.Config.SMTPPassword, from), nil
+	case FileSender:
+		path := common.Config.MailFile
+		if path == "" {
+			path = defaultFile
+		}
+		return NewFileSender(path, from), nil
+	case LogSender, "":
+		return NewLogSender(from), nil
+	default:
+		return nil, fmt.Errorf("Unknown mail sender: %s", common.Config.MailSender)
+	}
+}
+
+// smtpSender sends the emails through the SMTP server, the connection is
+// upgraded with STARTTLS when the server supports it
+type smtpSender struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPSender creates the sender authenticating with the username and the
+// password, the authentication is skipped when the username is empty
+func NewSMTPSender(host, port, username, password, from string) Sender {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &smtpSender{
+		addr: net.JoinHostPort(host, port),
+		auth: auth,
+		from: from,
+	}
+}
+
+// Send delivers the message to the SMTP server
+func (s *smtpSender) Send(msg *Message) error {
+	from, err := envelopeAddress(s.from)
+	if err != nil {
+		return err
+	}
+	to, err := envelopeAddress(msg.To)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(s.addr, s.auth, from, []string{to}, buildMessage(s.from, msg, time.Now()))
+}
+
+// fileSender appends the emails to the file
+type fileSender struct {
+	mu   sync.Mutex
+	path string
+	from string
+}
+
+// NewFileSender creates the sender appending the emails to the file
+func NewFileSender(path, from string) Sender {
+	return &fileSender{path: path, from: from}
+}
+
+// Send appends the message to the file
+func (s *fileSender) Send(msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(buildMessage(s.from, msg, time.Now()), "\r\n"...)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// logSender writes the emails to the log
+type logSender struct {
+	from string
+}
+
+// NewLogSender creates the sender writing the emails to the log
+func NewLogSender(from string) Sender {
+	return &logSender{from}
+}
+
+// Send writes the message to the log
+func (s *logSender) Send(msg *Message) error {
+	log.WithFields(log.Fields{
+		"from":    s.from,
+		"to":      msg.To,
+		"subject": msg.Subject,
+	}).Info(msg.Body)
+	return nil
+}
+
+// queueSender delivers the emails in the background, so the requests don't
+// wait for the mail server and their timing doesn't reveal if an email was sent
+type queueSender struct {
+	sender Sender
+	queue  chan *Message
+}
+
+// NewQueueSender creates the sender queueing the emails for the sender, the
+// delivery failures are only logged
+func NewQueueSender(sender Sender) Sender {
+	s := &queueSender{sender: sender, queue: make(chan *Message, queueSize)}
+	go s.run()
+	return s
+}
+
+// Send queues the copy of the message, the message is dropped when the queue is full
+func (s *queueSender) Send(msg *Message) error {
+	m := *msg
+	select {
+	case s.queue <- &m:
+		return nil
+	default:
+		return errors.New("Mail queue is full")
+	}
+}
+
+// run delivers the queued emails one by one
+func (s *queueSender) run() {
+	for msg := range s.queue {
+		if err := s.sender.Send(msg); err != nil {
+			log.Errorf("Couldn't send the email [subject=%s]: %v", msg.Subject, err)
+		}
+	}
+}
+
+// buildMessage formats the message with the headers, the header values are
+// stripped of the line breaks so they can't inject another header
+func buildMessage(from string, msg *Message, date time.Time) []byte {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", msg.Subject)
+	header("Date", date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=UTF-8")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.Replace(strings.Replace(msg.Body, "\r\n", "\n", -1), "\n", "\r\n", -1))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// envelopeAddress returns the bare address of the "Name <address>" form
+func envelopeAddress(address string) (string, error) {
+	if strings.ContainsAny(address, "\r\n") {
+		return "", fmt.Errorf("Invalid email address: %q", address)
+	}
+	if start := strings.LastIndex(address, "<"); start >= 0 {
+		end := strings.LastIndex(address, ">")
+		if end < start {
+			return "", fmt.Errorf("Invalid email address: %q", address)
+		}
+		address = address[start+1 : end]
+	}
+	return strings.TrimSpace(address), nil
+}
@@ -0,0 +1,126 @@
+package mail
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0x113/x-media/user/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildMessage(t *testing.T) {
+	date := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	msg := &Message{
+		To:      "john@example.org\r\nBcc: eve@example.org",
+		Subject: "Reset your password",
+		Body:    "Hi JohnDoe,\n\nopen the link.\n",
+	}
+
+	raw := string(buildMessage("x-media <no-reply@localhost>", msg, date))
+	assert.Contains(t, raw, "From: x-media <no-reply@localhost>\r\n")
+	assert.Contains(t, raw, "To: john@example.orgBcc: eve@example.org\r\n")
+	assert.NotContains(t, raw, "\r\nBcc:")
+	assert.Contains(t, raw, "Date: Fri, 01 May 2020 12:00:00 +0000\r\n")
+	assert.True(t, strings.HasSuffix(raw, "\r\n\r\nHi JohnDoe,\r\n\r\nopen the link.\r\n\r\n"))
+}
+
+func TestEnvelopeAddress(t *testing.T) {
+	testCases := []struct {
+		address string
+		want    string
+		wantErr bool
+	}{
+		{address: "john@example.org", want: "john@example.org"},
+		{address: "x-media <no-reply@localhost>", want: "no-reply@localhost"},
+		{address: "broken <john@example.org", wantErr: true},
+		{address: "john@example.org\r\nRCPT TO:<eve@example.org>", wantErr: true},
+	}
+
+	for _, tt := range testCases {
+		got, err := envelopeAddress(tt.address)
+		if tt.wantErr {
+			assert.NotNil(t, err, tt.address)
+			continue
+		}
+		assert.Nil(t, err, tt.address)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestFileSender(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	common.Config = &common.Configuration{MailSender: FileSender, MailFile: filepath.Join(dir, "mail.log")}
+	sender, err := NewSender()
+	assert.Nil(t, err)
+
+	assert.Nil(t, sender.Send(&Message{To: "john@example.org", Subject: "First", Body: "first"}))
+	assert.Nil(t, sender.Send(&Message{To: "john@example.org", Subject: "Second", Body: "second"}))
+	content, err := ioutil.ReadFile(common.Config.MailFile)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "Subject: First")
+	assert.Contains(t, string(content), "Subject: Second")
+	assert.Contains(t, string(content), "From: x-media <no-reply@localhost>")
+}
+
+func TestNewSender(t *testing.T) {
+	common.Config = &common.Configuration{MailSender: SMTPSender}
+	_, err := NewSender()
+	assert.NotNil(t, err)
+
+	common.Config = &common.Configuration{MailSender: "carrier-pigeon"}
+	_, err = NewSender()
+	assert.NotNil(t, err)
+
+	common.Config = &common.Configuration{MailSender: SMTPSender, SMTPHost: "smtp.example.org"}
+	sender, err := NewSender()
+	assert.Nil(t, err)
+	assert.Equal(t, "smtp.example.org:587", sender.(*smtpSender).addr)
+}
+
+// blockingSender passes the emails to the test once it's released
+type blockingSender struct {
+	release chan struct{}
+	sent    chan *Message
+}
+
+func (s *blockingSender) Send(msg *Message) error {
+	<-s.release
+	s.sent <- msg
+	return nil
+}
+
+func TestQueueSender(t *testing.T) {
+	backend := &blockingSender{release: make(chan struct{}), sent: make(chan *Message, queueSize+1)}
+	sender := NewQueueSender(backend)
+
+	// the sender doesn't wait for the delivery
+	msg := &Message{To: "john@example.org", Subject: "Reset your password", Body: "first"}
+	assert.Nil(t, sender.Send(msg))
+	msg.Body = "changed"
+
+	// the messages are dropped once the queue is full
+	queued := 0
+	for ; queued <= queueSize; queued++ {
+		if err := sender.Send(&Message{To: "john@example.org", Subject: "Queued"}); err != nil {
+			break
+		}
+	}
+	assert.True(t, queued >= queueSize-1 && queued <= queueSize, "queued %d messages", queued)
+
+	close(backend.release)
+	select {
+	case sent := <-backend.sent:
+		assert.Equal(t, "first", sent.Body)
+	case <-time.After(time.Second):
+		t.Fatal("the queued email wasn't delivered")
+	}
+}
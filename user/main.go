@@ -11,10 +11,21 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	authmw "github.com/0x113/x-media/auth/pkg/middleware"
+	"github.com/0x113/x-media/user/auth"
 	"github.com/0x113/x-media/user/common"
 	"github.com/0x113/x-media/user/data"
 	"github.com/0x113/x-media/user/databases"
 	"github.com/0x113/x-media/user/handler"
+	"github.com/0x113/x-media/user/mail"
+	"github.com/0x113/x-media/user/migrations"
 	"github.com/0x113/x-media/user/service"
 
 	"github.com/labstack/echo"
@@ -36,7 +47,10 @@ func (srv *Server) initServer() error {
 	if err := databases.Database.Init(); err != nil {
 		return err
 	}
-	log.Infof("Successfully connected to the MySQL database")
+	if err := databases.Database.Wait(30 * time.Second); err != nil {
+		return err
+	}
+	log.Infof("Successfully connected to the %s database", databases.Database.Dialect.Name())
 
 	// set up router
 	srv.router = echo.New()
@@ -45,16 +59,109 @@ func (srv *Server) initServer() error {
 
 }
 
+// migrate runs the migration command, the pending migrations are applied
+// before the server starts when no command is provided
+func migrate(command string, version int) error {
+	migrator, err := migrations.NewMigrator(&databases.Database)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "", "up":
+		applied, err := migrator.Up()
+		if err != nil {
+			return err
+		}
+		log.Infof("Database schema is up to date [version=%d, applied=%d]", migrator.Latest(), applied)
+	case "down":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		if version < 0 {
+			// roll back the last applied migration
+			version = 0
+			for i := len(statuses) - 1; i >= 0; i-- {
+				if statuses[i].AppliedAt != nil {
+					version = statuses[i].Version - 1
+					break
+				}
+			}
+		}
+		rolledBack, err := migrator.Down(version)
+		if err != nil {
+			return err
+		}
+		log.Infof("Successfully rolled back database schema [version=%d, rolled_back=%d]", version, rolledBack)
+	case "baseline":
+		if version < 0 {
+			version = migrator.Latest()
+		}
+		return migrator.Baseline(version)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("Unknown migrate command: %s", command)
+	}
+	return nil
+}
+
 func main() {
+	command := flag.String("migrate", "", "run the migration command and exit: up, down, status or baseline")
+	version := flag.Int("version", -1, "target version of the down (default: previous one) and baseline (default: latest) commands")
+	flag.Parse()
+
 	srv := &Server{}
 
 	if err := srv.initServer(); err != nil {
 		log.Fatalf("Couldn't initialize server: %v", err)
 	}
 
-	userRepository := data.NewMySQLUserRepository()
-	userService := service.NewUserService(userRepository)
-	handler.NewUserHandler(srv.router, userService)
+	if err := migrate(*command, *version); err != nil {
+		log.Fatalf("Couldn't migrate the database: %v", err)
+	}
+	if *command != "" {
+		return
+	}
+
+	validator, err := authmw.NewValidator(&http.Client{}, authmw.Config{
+		Mode:        common.Config.AuthMode,
+		JWKSURL:     common.Config.AuthJWKSURL,
+		ValidateURL: common.Config.AuthValidateURL,
+		CacheTTL:    common.Config.AuthCacheTTL,
+		Issuer:      common.Config.AuthIssuer,
+		Audience:    common.Config.AuthAudience,
+	})
+	if err != nil {
+		log.Fatalf("Unable to initialize token validator: %v", err)
+	}
+
+	mailer, err := mail.NewSender()
+	if err != nil {
+		log.Fatalf("Unable to initialize mail sender: %v", err)
+	}
+	// the emails are delivered in the background, so the password reset
+	// responses don't depend on the mail server
+	mailer = mail.NewQueueSender(mailer)
+
+	db := &databases.Database
+	userRepository := data.NewUserRepository(db)
+	userService := service.NewUserService(userRepository, data.NewPasskeyRepository(db), data.NewPasswordResetRepository(db), mailer, auth.NewSessionRevoker(&http.Client{}))
+	handler.NewUserHandler(srv.router, userService, validator)
 
 	srv.router.Start(":" + common.Config.Port)
 	// close the database connection
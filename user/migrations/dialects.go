@@ -0,0 +1,87 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/0x113/x-media/user/databases"
+)
+
+const (
+	// lockName is the MySQL named lock held while the migrations run
+	lockName = "xmedia_users_schema_migrations"
+	// lockKey is the PostgreSQL advisory lock held while the migrations run
+	lockKey = 7310611510011599
+)
+
+// dialect defines how the migrations are tracked and locked in one of the
+// databases, the lock keeps the instances starting at the same time from
+// applying the migrations twice
+type dialect struct {
+	createTable string
+	lock        func(ctx context.Context, conn *sql.Conn) error
+	unlock      func(ctx context.Context, conn *sql.Conn)
+}
+
+var dialects = map[string]*dialect{
+	databases.MySQL: {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version bigint NOT NULL,
+  name varchar(255) NOT NULL,
+  applied_at DATETIME NOT NULL,
+  PRIMARY KEY(version)
+)`,
+		lock: func(ctx context.Context, conn *sql.Conn) error {
+			var locked sql.NullInt64
+			if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(lockTimeout.Seconds())).Scan(&locked); err != nil {
+				return err
+			}
+			if locked.Int64 != 1 {
+				return ErrLockTimeout
+			}
+			return nil
+		},
+		unlock: func(ctx context.Context, conn *sql.Conn) {
+			conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+		},
+	},
+	databases.PostgreSQL: {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version bigint NOT NULL PRIMARY KEY,
+  name varchar(255) NOT NULL,
+  applied_at timestamp with time zone NOT NULL
+)`,
+		lock: func(ctx context.Context, conn *sql.Conn) error {
+			deadline := time.Now().Add(lockTimeout)
+			for {
+				var locked bool
+				if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lockKey).Scan(&locked); err != nil {
+					return err
+				}
+				if locked {
+					return nil
+				}
+				if time.Now().After(deadline) {
+					return ErrLockTimeout
+				}
+				time.Sleep(500 * time.Millisecond)
+			}
+		},
+		unlock: func(ctx context.Context, conn *sql.Conn) {
+			conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+		},
+	},
+	// SQLite allows one writer at a time and its schema changes are
+	// transactional, the instance applying the migration second fails on the
+	// duplicate version and rolls back, so no lock is needed
+	databases.SQLite: {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version bigint NOT NULL PRIMARY KEY,
+  name varchar(255) NOT NULL,
+  applied_at DATETIME NOT NULL
+)`,
+		lock:   func(ctx context.Context, conn *sql.Conn) error { return nil },
+		unlock: func(ctx context.Context, conn *sql.Conn) {},
+	},
+}
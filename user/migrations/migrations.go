@@ -0,0 +1,336 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0x113/x-media/user/databases"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// lockTimeout limits how long the instance waits for another one applying the migrations
+const lockTimeout = 60 * time.Second
+
+// files contains the migrations of each database in the directory named after its dialect
+//
+//go:embed sql/*/*.sql
+var files embed.FS
+
+// fileNamePattern matches the migration files e.g. 0001_create_user.up.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrLockTimeout is returned when another instance holds the migration lock for too long
+var ErrLockTimeout = errors.New("Couldn't acquire the migration lock")
+
+// Migration defines the versioned schema change along with its rollback
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status defines the migration and whether it was applied
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrator applies and rolls back the migrations embedded in the binary
+type Migrator interface {
+	Up() (int, error)
+	Down(version int) (int, error)
+	Baseline(version int) error
+	Status() ([]*Status, error)
+	Latest() int
+}
+
+type migrator struct {
+	db         *databases.SQLDB
+	dialect    *dialect
+	migrations []*Migration
+}
+
+// NewMigrator creates the migrator of the migrations embedded for the database
+func NewMigrator(db *databases.SQLDB) (Migrator, error) {
+	name := db.Dialect.Name()
+	d, ok := dialects[name]
+	if !ok {
+		return nil, fmt.Errorf("There are no migrations for the %s database", name)
+	}
+	dir, err := fs.Sub(files, path.Join("sql", name))
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &migrator{db, d, migrations}, nil
+}
+
+// Load reads the migrations from the directory, each version must have both
+// the up and the down file
+func Load(dir fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(dir, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			return nil, fmt.Errorf("Unexpected migration file: %s", entry.Name())
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("Invalid migration version: %s", entry.Name())
+		}
+		content, err := fs.ReadFile(dir, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("Migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if len(SplitStatements(m.Up)) == 0 || len(SplitStatements(m.Down)) == 0 {
+			return nil, fmt.Errorf("Migration %d_%s must have both up and down steps", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Latest returns the version of the newest embedded migration
+func (m *migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies all of the pending migrations and returns their number
+func (m *migrator) Up() (int, error) {
+	applied := 0
+	err := m.withLock(func(conn *sql.Conn) error {
+		versions, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		if err := m.checkKnown(versions); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(conn, migration.Up, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", migration.Version, migration.Name, time.Now()); err != nil {
+				return fmt.Errorf("Couldn't apply migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			log.Infof("Successfully applied migration [version=%d, name=%s]", migration.Version, migration.Name)
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the applied migrations newer than the version and returns their number
+func (m *migrator) Down(version int) (int, error) {
+	rolledBack := 0
+	err := m.withLock(func(conn *sql.Conn) error {
+		versions, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		if err := m.checkKnown(versions); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version <= version {
+				break
+			}
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			if err := m.apply(conn, migration.Down, "DELETE FROM schema_migrations WHERE version = ?", migration.Version); err != nil {
+				return fmt.Errorf("Couldn't roll back migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			log.Infof("Successfully rolled back migration [version=%d, name=%s]", migration.Version, migration.Name)
+			rolledBack++
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Baseline marks the migrations up to the version as applied without running
+// them, meant for the databases created before the migrations were introduced
+func (m *migrator) Baseline(version int) error {
+	return m.withLock(func(conn *sql.Conn) error {
+		versions, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok || migration.Version > version {
+				continue
+			}
+			if _, err := conn.ExecContext(context.Background(), m.db.Dialect.Rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"), migration.Version, migration.Name, time.Now()); err != nil {
+				return err
+			}
+			log.Infof("Successfully marked migration as applied [version=%d, name=%s]", migration.Version, migration.Name)
+		}
+		return nil
+	})
+}
+
+// Status returns all of the embedded migrations along with the time they were applied
+func (m *migrator) Status() ([]*Status, error) {
+	var statuses []*Status
+	err := m.withLock(func(conn *sql.Conn) error {
+		versions, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			status := &Status{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := versions[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return m.checkKnown(versions)
+	})
+	return statuses, err
+}
+
+// checkKnown refuses to work with the database migrated by a newer version of the service
+func (m *migrator) checkKnown(versions map[int]time.Time) error {
+	for version := range versions {
+		if version > m.Latest() {
+			return fmt.Errorf("Database schema version %d is newer than the latest known migration %d", version, m.Latest())
+		}
+	}
+	return nil
+}
+
+// withLock runs the function on the dedicated connection holding the
+// migration lock, the schema_migrations table is created if it's missing
+func (m *migrator) withLock(fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.db.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := m.dialect.lock(ctx, conn); err != nil {
+		return err
+	}
+	defer m.dialect.unlock(ctx, conn)
+
+	if _, err := conn.ExecContext(ctx, m.dialect.createTable); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// appliedVersions returns the applied migrations along with the time they were applied
+func appliedVersions(conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(context.Background(), "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
+
+// apply runs the statements of the migration step along with the change of
+// schema_migrations in one transaction. MySQL commits the DDL statements
+// implicitly, so each of its migrations should contain a single schema change
+func (m *migrator) apply(conn *sql.Conn, script, query string, args ...interface{}) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range SplitStatements(script) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, m.db.Dialect.Rebind(query), args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SplitStatements splits the script into the statements ending with the
+// semicolon at the end of the line, the comment lines are skipped
+func SplitStatements(script string) []string {
+	var statements []string
+	var current []string
+	for _, line := range strings.Split(strings.Replace(script, "\r\n", "\n", -1), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current = append(current, line)
+		if strings.HasSuffix(trimmed, ";") {
+			statement := strings.TrimSpace(strings.Join(current, "\n"))
+			statements = append(statements, strings.TrimSuffix(statement, ";"))
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		statements = append(statements, strings.TrimSpace(strings.Join(current, "\n")))
+	}
+	return statements
+}
@@ -0,0 +1,180 @@
+package migrations
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/0x113/x-media/user/databases"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	for name := range dialects {
+		t.Run(name, func(t *testing.T) {
+			dir, err := fs.Sub(files, path.Join("sql", name))
+			assert.Nil(t, err)
+			migrations, err := Load(dir)
+			assert.Nil(t, err)
+			assert.NotEmpty(t, migrations)
+
+			// the versions are contiguous, so a missing file is noticed
+			for i, m := range migrations {
+				assert.Equal(t, i+1, m.Version)
+				assert.NotEmpty(t, SplitStatements(m.Up), m.Name)
+				assert.NotEmpty(t, SplitStatements(m.Down), m.Name)
+			}
+		})
+	}
+}
+
+func TestSQLiteMigrator(t *testing.T) {
+	logrus.SetOutput(ioutil.Discard)
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := databases.Open(databases.SQLite, databases.SQLiteDSN(filepath.Join(dir, "users.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.DB.Close()
+
+	m, err := NewMigrator(db)
+	assert.Nil(t, err)
+	statuses, err := m.Status()
+	assert.Nil(t, err)
+	assert.Len(t, statuses, m.Latest())
+	assert.Nil(t, statuses[0].AppliedAt)
+
+	applied, err := m.Up()
+	assert.Nil(t, err)
+	assert.Equal(t, m.Latest(), applied)
+	_, err = db.Exec("INSERT INTO users (username, password, is_admin, created_at, updated_at) VALUES (?, '', ?, ?, ?)", "JohnDoe", false, time.Now(), time.Now())
+	assert.Nil(t, err)
+
+	// the second run has nothing to do
+	applied, err = m.Up()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, applied)
+	statuses, err = m.Status()
+	assert.Nil(t, err)
+	assert.NotNil(t, statuses[len(statuses)-1].AppliedAt)
+
+	rolledBack, err := m.Down(0)
+	assert.Nil(t, err)
+	assert.Equal(t, m.Latest(), rolledBack)
+	_, err = db.Exec("SELECT COUNT(*) FROM users")
+	assert.NotNil(t, err)
+
+	// the baseline only records the versions
+	assert.Nil(t, m.Baseline(m.Latest()))
+	statuses, err = m.Status()
+	assert.Nil(t, err)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	_, err = db.Exec("SELECT COUNT(*) FROM users")
+	assert.NotNil(t, err)
+
+	// the schema of the newer service isn't touched
+	_, err = db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", 9999, "from_the_future", time.Now())
+	assert.Nil(t, err)
+	_, err = m.Up()
+	assert.NotNil(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	file := func(content string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(content)}
+	}
+
+	testCases := []struct {
+		name    string
+		dir     fstest.MapFS
+		want    []int
+		wantErr bool
+	}{
+		{
+			name: "Sorted by version",
+			dir: fstest.MapFS{
+				"0010_add_column.up.sql":   file("ALTER TABLE t ADD c int;"),
+				"0010_add_column.down.sql": file("ALTER TABLE t DROP COLUMN c;"),
+				"0002_create.up.sql":       file("CREATE TABLE t (id int);"),
+				"0002_create.down.sql":     file("DROP TABLE t;"),
+			},
+			want: []int{2, 10},
+		},
+		{
+			name: "Missing down step",
+			dir: fstest.MapFS{
+				"0001_create.up.sql": file("CREATE TABLE t (id int);"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Empty down step",
+			dir: fstest.MapFS{
+				"0001_create.up.sql":   file("CREATE TABLE t (id int);"),
+				"0001_create.down.sql": file("\n-- nothing to do\n"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Same version with two names",
+			dir: fstest.MapFS{
+				"0001_create.up.sql":  file("CREATE TABLE t (id int);"),
+				"0001_other.down.sql": file("DROP TABLE t;"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unexpected file",
+			dir: fstest.MapFS{
+				"README.md": file("migrations"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Version zero",
+			dir: fstest.MapFS{
+				"0000_create.up.sql":   file("CREATE TABLE t (id int);"),
+				"0000_create.down.sql": file("DROP TABLE t;"),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := Load(tt.dir)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			versions := []int{}
+			for _, m := range migrations {
+				versions = append(versions, m.Version)
+			}
+			assert.Equal(t, tt.want, versions)
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := "-- add the role\r\nALTER TABLE user\n  ADD role varchar(32) NOT NULL DEFAULT 'user';\n\nUPDATE user SET role = 'admin' WHERE is_admin;\nSELECT 1"
+
+	assert.Equal(t, []string{
+		"ALTER TABLE user\n  ADD role varchar(32) NOT NULL DEFAULT 'user'",
+		"UPDATE user SET role = 'admin' WHERE is_admin",
+		"SELECT 1",
+	}, SplitStatements(script))
+	assert.Empty(t, SplitStatements("\n-- comment only\n"))
+}
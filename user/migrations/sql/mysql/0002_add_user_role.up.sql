@@ -0,0 +1,2 @@
+ALTER TABLE user ADD role varchar(32) NOT NULL DEFAULT 'user';
+UPDATE user SET role = 'admin' WHERE is_admin;
@@ -0,0 +1,11 @@
+CREATE TABLE passkey (
+  credential_id varchar(512) CHARACTER SET ascii NOT NULL,
+  user_id int NOT NULL,
+  name varchar(64) NOT NULL,
+  public_key blob NOT NULL,
+  sign_count int unsigned NOT NULL DEFAULT 0,
+  created_at DATETIME NOT NULL,
+  last_used_at DATETIME NULL,
+  PRIMARY KEY(credential_id),
+  FOREIGN KEY(user_id) REFERENCES user(user_id) ON DELETE CASCADE
+);
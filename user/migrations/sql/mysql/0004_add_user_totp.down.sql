@@ -0,0 +1 @@
+ALTER TABLE user DROP COLUMN totp_secret, DROP COLUMN totp_enabled, DROP COLUMN totp_last_step, DROP COLUMN recovery_codes, DROP COLUMN totp_failures, DROP COLUMN totp_locked_until;
@@ -0,0 +1,7 @@
+ALTER TABLE user
+  ADD totp_secret varchar(64) NOT NULL DEFAULT '',
+  ADD totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD totp_last_step bigint NOT NULL DEFAULT 0,
+  ADD recovery_codes varchar(1024) NOT NULL DEFAULT '',
+  ADD totp_failures int NOT NULL DEFAULT 0,
+  ADD totp_locked_until DATETIME NULL;
@@ -0,0 +1 @@
+ALTER TABLE user DROP COLUMN email, DROP COLUMN disabled;
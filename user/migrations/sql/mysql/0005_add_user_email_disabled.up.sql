@@ -0,0 +1,3 @@
+ALTER TABLE user
+  ADD email varchar(254) NULL UNIQUE,
+  ADD disabled BOOLEAN NOT NULL DEFAULT FALSE;
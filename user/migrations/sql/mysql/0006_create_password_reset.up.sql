@@ -0,0 +1,8 @@
+CREATE TABLE password_reset (
+  token_hash char(64) CHARACTER SET ascii NOT NULL,
+  user_id int NOT NULL,
+  expires_at DATETIME NOT NULL,
+  created_at DATETIME NOT NULL,
+  PRIMARY KEY(token_hash),
+  FOREIGN KEY(user_id) REFERENCES user(user_id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+-- user is a reserved word in PostgreSQL, all of the databases use the same name
+RENAME TABLE user TO users;
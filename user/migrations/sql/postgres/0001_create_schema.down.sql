@@ -0,0 +1,3 @@
+DROP TABLE password_reset;
+DROP TABLE passkey;
+DROP TABLE users;
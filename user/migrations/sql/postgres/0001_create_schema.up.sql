@@ -0,0 +1,34 @@
+CREATE TABLE users (
+  user_id serial PRIMARY KEY,
+  username varchar(255) NOT NULL UNIQUE,
+  password varchar(60) NOT NULL,
+  email varchar(254) NULL UNIQUE,
+  is_admin boolean NOT NULL,
+  role varchar(32) NOT NULL DEFAULT 'user',
+  disabled boolean NOT NULL DEFAULT FALSE,
+  totp_secret varchar(64) NOT NULL DEFAULT '',
+  totp_enabled boolean NOT NULL DEFAULT FALSE,
+  totp_last_step bigint NOT NULL DEFAULT 0,
+  recovery_codes varchar(1024) NOT NULL DEFAULT '',
+  totp_failures integer NOT NULL DEFAULT 0,
+  totp_locked_until timestamp with time zone NULL,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone NOT NULL
+);
+
+CREATE TABLE passkey (
+  credential_id varchar(512) NOT NULL PRIMARY KEY,
+  user_id integer NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+  name varchar(64) NOT NULL,
+  public_key bytea NOT NULL,
+  sign_count bigint NOT NULL DEFAULT 0,
+  created_at timestamp with time zone NOT NULL,
+  last_used_at timestamp with time zone NULL
+);
+
+CREATE TABLE password_reset (
+  token_hash char(64) NOT NULL PRIMARY KEY,
+  user_id integer NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+  expires_at timestamp with time zone NOT NULL,
+  created_at timestamp with time zone NOT NULL
+);
@@ -0,0 +1,115 @@
+package mocks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/0x113/x-media/user/data"
+	"github.com/0x113/x-media/user/databases"
+	"github.com/0x113/x-media/user/migrations"
+)
+
+const (
+	// MySQLTestDSN and PostgresTestDSN name the environment variables with
+	// the databases the tests run against, they're wiped before each test
+	MySQLTestDSN    = "USER_TEST_MYSQL_DSN"
+	PostgresTestDSN = "USER_TEST_POSTGRES_DSN"
+)
+
+// Backend provides the repositories of one of the storage backends to the test suites
+type Backend struct {
+	Name   string
+	driver string
+	dsn    string
+}
+
+// Repositories holds the repositories of the backend seeded with JohnDoe
+type Repositories struct {
+	Users    data.UserRepository
+	Passkeys data.PasskeyRepository
+	Resets   data.PasswordResetRepository
+	Close    func()
+}
+
+// Backends returns the in-memory mocks and SQLite along with MySQL and
+// PostgreSQL if their data source names are set in the environment
+func Backends() []*Backend {
+	backends := []*Backend{{Name: "mock"}, {Name: databases.SQLite, driver: databases.SQLite}}
+	if dsn := os.Getenv(MySQLTestDSN); dsn != "" {
+		backends = append(backends, &Backend{Name: databases.MySQL, driver: databases.MySQL, dsn: dsn})
+	}
+	if dsn := os.Getenv(PostgresTestDSN); dsn != "" {
+		backends = append(backends, &Backend{Name: databases.PostgreSQL, driver: databases.PostgreSQL, dsn: dsn})
+	}
+	return backends
+}
+
+// Open returns the repositories of the empty database with JohnDoe
+func (b *Backend) Open() (*Repositories, error) {
+	if b.driver == "" {
+		users := NewMockUserRepository()
+		return &Repositories{
+			Users:    users,
+			Passkeys: NewMockPasskeyRepository(),
+			Resets:   NewMockPasswordResetRepository(users),
+			Close:    func() {},
+		}, nil
+	}
+
+	dsn, cleanup := b.dsn, func() {}
+	if b.driver == databases.SQLite {
+		dir, err := ioutil.TempDir("", "users-*")
+		if err != nil {
+			return nil, err
+		}
+		dsn, cleanup = databases.SQLiteDSN(filepath.Join(dir, "users.db")), func() { os.RemoveAll(dir) }
+	}
+	db, err := databases.Open(b.driver, dsn)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	repos := &Repositories{
+		Users:    data.NewUserRepository(db),
+		Passkeys: data.NewPasskeyRepository(db),
+		Resets:   data.NewPasswordResetRepository(db),
+		Close: func() {
+			db.DB.Close()
+			cleanup()
+		},
+	}
+
+	if err := migrate(db); err != nil {
+		repos.Close()
+		return nil, err
+	}
+	seed := NewMockUserRepository().users["JohnDoe"]
+	seed.CreatedAt, seed.UpdatedAt = time.Now(), time.Now()
+	if err := repos.Users.Create(seed); err != nil {
+		repos.Close()
+		return nil, err
+	}
+	return repos, nil
+}
+
+// migrate rolls back the migrations left by the previous test and applies them again
+func migrate(db *databases.SQLDB) error {
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	if _, err := migrator.Down(0); err != nil {
+		return err
+	}
+	_, err = migrator.Up()
+	return err
+}
+
+// the mocks are interchangeable with the databases
+var (
+	_ data.UserRepository          = (*MockUserRepository)(nil)
+	_ data.PasskeyRepository       = (*MockPasskeyRepository)(nil)
+	_ data.PasswordResetRepository = (*MockPasswordResetRepository)(nil)
+)
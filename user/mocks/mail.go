@@ -0,0 +1,19 @@
+package mocks
+
+import "github.com/0x113/x-media/user/mail"
+
+// MockMailSender keeps the sent emails in memory
+type MockMailSender struct {
+	Messages []*mail.Message
+	Err      error
+}
+
+// Send stores the copy of the message or returns the configured error
+func (s *MockMailSender) Send(msg *mail.Message) error {
+	if s.Err != nil {
+		return s.Err
+	}
+	m := *msg
+	s.Messages = append(s.Messages, &m)
+	return nil
+}
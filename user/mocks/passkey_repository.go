@@ -0,0 +1,72 @@
+package mocks
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/0x113/x-media/user/models"
+)
+
+// MockPasskeyRepository represents in-memory passkey repository
+type MockPasskeyRepository struct {
+	passkeys map[string]*models.Passkey
+}
+
+// NewMockPasskeyRepository creates new instance of MockPasskeyRepository
+func NewMockPasskeyRepository() *MockPasskeyRepository {
+	return &MockPasskeyRepository{map[string]*models.Passkey{}}
+}
+
+// CreatePasskey stores the copy of the passkey in memory
+func (r *MockPasskeyRepository) CreatePasskey(p *models.Passkey) error {
+	if _, ok := r.passkeys[p.ID]; ok {
+		return fmt.Errorf("Passkey %s already exists", p.ID)
+	}
+	passkey := *p
+	r.passkeys[p.ID] = &passkey
+	return nil
+}
+
+// GetPasskey returns the copy of the passkey
+func (r *MockPasskeyRepository) GetPasskey(id string) (*models.Passkey, error) {
+	p, ok := r.passkeys[id]
+	if !ok {
+		return nil, fmt.Errorf("Passkey %s doesn't exist", id)
+	}
+	passkey := *p
+	return &passkey, nil
+}
+
+// GetPasskeys returns copies of the passkeys of the user
+func (r *MockPasskeyRepository) GetPasskeys(userID int) ([]*models.Passkey, error) {
+	passkeys := []*models.Passkey{}
+	for _, p := range r.passkeys {
+		if p.UserID == userID {
+			passkey := *p
+			passkeys = append(passkeys, &passkey)
+		}
+	}
+	sort.Slice(passkeys, func(i, j int) bool {
+		return passkeys[i].CreatedAt.Before(passkeys[j].CreatedAt)
+	})
+	return passkeys, nil
+}
+
+// UpdatePasskey stores the copy of the updated passkey
+func (r *MockPasskeyRepository) UpdatePasskey(p *models.Passkey) error {
+	if _, ok := r.passkeys[p.ID]; !ok {
+		return fmt.Errorf("Passkey %s doesn't exist", p.ID)
+	}
+	passkey := *p
+	r.passkeys[p.ID] = &passkey
+	return nil
+}
+
+// DeletePasskey removes the passkey from memory
+func (r *MockPasskeyRepository) DeletePasskey(id string) error {
+	if _, ok := r.passkeys[id]; !ok {
+		return fmt.Errorf("Passkey %s doesn't exist", id)
+	}
+	delete(r.passkeys, id)
+	return nil
+}
@@ -0,0 +1,62 @@
+package mocks
+
+import (
+	"fmt"
+
+	"github.com/0x113/x-media/user/data"
+	"github.com/0x113/x-media/user/models"
+)
+
+// MockPasswordResetRepository represents in-memory password reset repository,
+// the usernames are resolved with the user repository like the SQL join does
+type MockPasswordResetRepository struct {
+	tokens map[string]*models.PasswordResetToken
+	users  *MockUserRepository
+}
+
+// NewMockPasswordResetRepository creates new instance of MockPasswordResetRepository
+func NewMockPasswordResetRepository(users *MockUserRepository) *MockPasswordResetRepository {
+	return &MockPasswordResetRepository{map[string]*models.PasswordResetToken{}, users}
+}
+
+// CreateResetToken stores the copy of the token in memory
+func (r *MockPasswordResetRepository) CreateResetToken(t *models.PasswordResetToken) error {
+	if _, ok := r.tokens[t.TokenHash]; ok {
+		return fmt.Errorf("%w: password reset token already exists", data.ErrDuplicate)
+	}
+	token := *t
+	r.tokens[t.TokenHash] = &token
+	return nil
+}
+
+// TakeResetToken removes the token from memory and returns it along with the username of its owner
+func (r *MockPasswordResetRepository) TakeResetToken(hash string) (*models.PasswordResetToken, error) {
+	t, ok := r.tokens[hash]
+	if !ok {
+		return nil, fmt.Errorf("%w: password reset token doesn't exist", data.ErrNotFound)
+	}
+	delete(r.tokens, hash)
+
+	for _, u := range r.users.users {
+		if u.ID == t.UserID {
+			t.Username = u.Username
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: password reset token doesn't exist", data.ErrNotFound)
+}
+
+// DeleteResetTokens removes all of the tokens of the user from memory
+func (r *MockPasswordResetRepository) DeleteResetTokens(userID int) error {
+	for hash, t := range r.tokens {
+		if t.UserID == userID {
+			delete(r.tokens, hash)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of the stored tokens
+func (r *MockPasswordResetRepository) Len() int {
+	return len(r.tokens)
+}
@@ -2,7 +2,11 @@ package mocks
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/0x113/x-media/user/data"
 	"github.com/0x113/x-media/user/models"
 )
 
@@ -26,18 +30,134 @@ func NewMockUserRepository() *MockUserRepository {
 // Create new user in memory
 func (r *MockUserRepository) Create(u *models.User) error {
 	if _, ok := r.users[u.Username]; ok {
-		return fmt.Errorf("Couldn't create new user: user [username=%s] already exists", u.Username)
+		return fmt.Errorf("%w: user [username=%s] already exists", data.ErrDuplicate, u.Username)
+	}
+	if r.emailTaken(u) {
+		return fmt.Errorf("%w: email %s is already used", data.ErrDuplicate, u.Email)
 	}
 
 	r.users[u.Username] = u
 	return nil
 }
 
-// Get user by username from memory
+// Get the copy of the user by username from memory
 func (r *MockUserRepository) Get(username string) (*models.User, error) {
 	if user, ok := r.users[username]; ok {
-		return user, nil
+		u := *user
+		return &u, nil
+	}
+
+	return nil, fmt.Errorf("%w: user with username: %s; doesn't exist", data.ErrNotFound, username)
+}
+
+// GetByEmail returns the copy of the user with provided email from memory
+func (r *MockUserRepository) GetByEmail(email string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			u := *user
+			return &u, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: user with email: %s; doesn't exist", data.ErrNotFound, email)
+}
+
+// List returns the page of the users ordered by id and username
+func (r *MockUserRepository) List(offset, limit int) ([]*models.User, int, error) {
+	users := []*models.User{}
+	for _, u := range r.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].ID != users[j].ID {
+			return users[i].ID < users[j].ID
+		}
+		return users[i].Username < users[j].Username
+	})
+
+	total := len(users)
+	if offset > total {
+		offset = total
+	}
+	if offset+limit < total {
+		users = users[:offset+limit]
+	}
+	return users[offset:], total, nil
+}
+
+// Update user in memory
+func (r *MockUserRepository) Update(u *models.User) error {
+	if _, ok := r.users[u.Username]; !ok {
+		return fmt.Errorf("%w: user with username: %s; doesn't exist", data.ErrNotFound, u.Username)
+	}
+	if r.emailTaken(u) {
+		return fmt.Errorf("%w: email %s is already used", data.ErrDuplicate, u.Email)
+	}
+
+	user := *u
+	r.users[u.Username] = &user
+	return nil
+}
+
+// UseSecondFactor saves the second factor of the user in memory if the stored
+// step and recovery codes weren't changed in the meantime
+func (r *MockUserRepository) UseSecondFactor(u *models.User, lastStep int64, recoveryCodes []string) (bool, error) {
+	user, ok := r.users[u.Username]
+	if !ok {
+		return false, fmt.Errorf("%w: user with username: %s; doesn't exist", data.ErrNotFound, u.Username)
+	}
+	if user.TOTPLastStep != lastStep || strings.Join(user.RecoveryCodes, ",") != strings.Join(recoveryCodes, ",") {
+		return false, nil
+	}
+
+	user.TOTPLastStep = u.TOTPLastStep
+	user.RecoveryCodes = append([]string(nil), u.RecoveryCodes...)
+	user.TOTPFailures = 0
+	user.TOTPLockedUntil = nil
+	user.UpdatedAt = u.UpdatedAt
+	return true, nil
+}
+
+// AddTOTPFailure increments the invalid codes of the user in memory
+func (r *MockUserRepository) AddTOTPFailure(u *models.User) (int, error) {
+	user, ok := r.users[u.Username]
+	if !ok {
+		return 0, fmt.Errorf("%w: user with username: %s; doesn't exist", data.ErrNotFound, u.Username)
 	}
+	user.TOTPFailures++
+	user.UpdatedAt = u.UpdatedAt
+	return user.TOTPFailures, nil
+}
 
-	return nil, fmt.Errorf("User with username: %s; doesn't exist", username)
+// LockTOTP blocks the second factor of the user in memory
+func (r *MockUserRepository) LockTOTP(u *models.User, lockedUntil time.Time) error {
+	user, ok := r.users[u.Username]
+	if !ok {
+		return fmt.Errorf("%w: user with username: %s; doesn't exist", data.ErrNotFound, u.Username)
+	}
+	user.TOTPLockedUntil = &lockedUntil
+	return nil
+}
+
+// Delete user from memory
+func (r *MockUserRepository) Delete(username string) error {
+	if _, ok := r.users[username]; !ok {
+		return fmt.Errorf("%w: user with username: %s; doesn't exist", data.ErrNotFound, username)
+	}
+
+	delete(r.users, username)
+	return nil
+}
+
+// emailTaken checks if another user has the email of the user
+func (r *MockUserRepository) emailTaken(u *models.User) bool {
+	if u.Email == "" {
+		return false
+	}
+	for username, user := range r.users {
+		if username != u.Username && user.Email == u.Email {
+			return true
+		}
+	}
+	return false
 }
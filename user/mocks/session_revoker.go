@@ -0,0 +1,26 @@
+package mocks
+
+// MockSessionRevoker records the users whose sessions and API keys were revoked
+type MockSessionRevoker struct {
+	Revoked        []string
+	RevokedAPIKeys []string
+	Err            error
+}
+
+// RevokeSessions records the username or returns the configured error
+func (r *MockSessionRevoker) RevokeSessions(username string) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.Revoked = append(r.Revoked, username)
+	return nil
+}
+
+// RevokeAPIKeys records the username or returns the configured error
+func (r *MockSessionRevoker) RevokeAPIKeys(username string) error {
+	if r.Err != nil {
+		return r.Err
+	}
+	r.RevokedAPIKeys = append(r.RevokedAPIKeys, username)
+	return nil
+}
@@ -0,0 +1,28 @@
+package mocks
+
+import (
+	authmw "github.com/0x113/x-media/auth/pkg/middleware"
+	"github.com/0x113/x-media/user/models"
+)
+
+// MockValidator accepts the tokens stored in memory
+type MockValidator struct {
+	Tokens map[string]*authmw.AccessDetails
+}
+
+// NewMockValidator creates the validator accepting the "admin" token of an
+// admin and the "user" token of JohnDoe
+func NewMockValidator() *MockValidator {
+	return &MockValidator{map[string]*authmw.AccessDetails{
+		"admin": {Username: "admin", IsAdmin: true, Scopes: models.RoleScopes[models.RoleAdmin]},
+		"user":  {Username: "JohnDoe", Scopes: models.RoleScopes[models.RoleUser]},
+	}}
+}
+
+// Validate returns the details of the stored token
+func (v *MockValidator) Validate(token string) (*authmw.AccessDetails, error) {
+	if details, ok := v.Tokens[token]; ok {
+		return details, nil
+	}
+	return nil, authmw.ErrInvalidToken
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Passkey defines the WebAuthn credential registered by the user, the
+// authentication service verifies the signatures with its public key
+type Passkey struct {
+	ID         string     `json:"id" validate:"required,max=512"` // base64url encoded credential id
+	UserID     int        `json:"-"`
+	Username   string     `json:"username" validate:"required"`
+	Name       string     `json:"name" validate:"max=64"`
+	PublicKey  []byte     `json:"public_key" validate:"required"` // COSE encoded
+	SignCount  uint32     `json:"sign_count"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// PasskeyLogin defines the signature counter of the verified assertion
+type PasskeyLogin struct {
+	SignCount uint32 `json:"sign_count"`
+}
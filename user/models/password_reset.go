@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// PasswordResetToken defines the one-time token sent to the user who forgot
+// the password, only its hash is stored
+type PasswordResetToken struct {
+	TokenHash string
+	UserID    int
+	Username  string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// PasswordResetRequest defines the email of the account whose password was forgotten
+type PasswordResetRequest struct {
+	Email string `json:"email" validate:"required,email,max=254" example:"john@example.org"`
+}
+
+// PasswordResetConfirm defines the token from the email along with the new password
+type PasswordResetConfirm struct {
+	Token       string `json:"token" validate:"required,max=128" example:"Yq3n0Fh2oTr7w5cKbE1xJgVd8sZuMiLpAaQe6NyRt4k"`
+	NewPassword string `json:"new_password" validate:"required,gte=8" example:"newpassword"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// ProvisionRequest describes the user authenticated by an external identity
+// provider which should have a local user record
+type ProvisionRequest struct {
+	Username string `json:"username" validate:"required,min=3,max=32"`
+	// Admin defines if the provider grants the admin role, the role of the
+	// existing user isn't changed when it's omitted
+	Admin *bool `json:"admin,omitempty"`
+	// ExistingOnly disables creating the user on the first login
+	ExistingOnly bool `json:"existing_only"`
+	// WithoutPassword refuses the existing users with the local password, the
+	// identity provider can't take over the account the user hasn't linked
+	WithoutPassword bool `json:"without_password"`
+}
@@ -0,0 +1,33 @@
+package models
+
+const (
+	// RoleAdmin has every scope
+	RoleAdmin = "admin"
+	// RoleEditor can browse, stream, scan and fix the metadata of the library
+	RoleEditor = "editor"
+	// RoleUser can only browse and stream the library, e.g. the kids' accounts
+	RoleUser = "user"
+	// RoleScanner can only scan the library, e.g. the helper scripts
+	RoleScanner = "scanner"
+)
+
+const (
+	// ScopeLibraryRead allows browsing the movies and the tv shows
+	ScopeLibraryRead = "library:read"
+	// ScopeLibraryScan allows updating the library from the disk and the metadata providers
+	ScopeLibraryScan = "library:scan"
+	// ScopeLibraryEdit allows fixing the metadata of the library
+	ScopeLibraryEdit = "library:edit"
+	// ScopeUsersManage allows managing the users
+	ScopeUsersManage = "users:manage"
+	// ScopeStream allows streaming the videos
+	ScopeStream = "stream"
+)
+
+// RoleScopes defines the scopes granted by each of the roles
+var RoleScopes = map[string][]string{
+	RoleAdmin:   {ScopeLibraryRead, ScopeLibraryScan, ScopeLibraryEdit, ScopeUsersManage, ScopeStream},
+	RoleEditor:  {ScopeLibraryRead, ScopeLibraryScan, ScopeLibraryEdit, ScopeStream},
+	RoleUser:    {ScopeLibraryRead, ScopeStream},
+	RoleScanner: {ScopeLibraryScan},
+}
@@ -1,8 +1,13 @@
 package models
 
-// TokenClaims defines details like username and is_admin, which are needed
-// to generate access token
+// TokenClaims defines details like username, is_admin and the scopes, which
+// are needed to generate access token
 type TokenClaims struct {
-	Username string `json:"username" example:"TheBill"`
-	IsAdmin  bool   `json:"is_admin" example:"false"`
+	Username string   `json:"username" example:"TheBill"`
+	IsAdmin  bool     `json:"is_admin" example:"false"`
+	Role     string   `json:"role" example:"user"`
+	Scopes   []string `json:"scopes" example:"library:read,stream"`
+	// MFARequired is set when the password is correct, but the user has to
+	// provide the code of the second factor before getting the tokens
+	MFARequired bool `json:"mfa_required,omitempty" example:"false"`
 }
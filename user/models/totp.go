@@ -0,0 +1,21 @@
+package models
+
+// TOTPEnrollment defines the secret of the new authenticator app along with
+// the recovery codes, they are returned only once
+type TOTPEnrollment struct {
+	Secret        string   `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	URI           string   `json:"uri" example:"otpauth://totp/x-media:JohnDoe?algorithm=SHA1&digits=6&issuer=x-media&period=30&secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	QRCode        string   `json:"qr_code" example:"data:image/png;base64,iVBORw0KGgo="`
+	RecoveryCodes []string `json:"recovery_codes" example:"k7mzq-4tn2x,v3hpa-9cw6e"`
+}
+
+// TOTPEnrollRequest defines the user starting the two-factor authentication enrollment
+type TOTPEnrollRequest struct {
+	Username string `json:"username" validate:"required" example:"JohnDoe"`
+}
+
+// TOTPRequest defines the code from the authenticator app or one of the recovery codes
+type TOTPRequest struct {
+	Username string `json:"username" validate:"required" example:"JohnDoe"`
+	Code     string `json:"code" validate:"required,max=32" example:"123456"`
+}
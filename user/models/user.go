@@ -7,7 +7,83 @@ type User struct {
 	ID        int       `json:"id" validate:"omitempty"`
 	Username  string    `json:"username" validate:"required,min=3,max=32"`
 	Password  string    `json:"password" validate:"required,gte=8"`
+	Email     string    `json:"email" validate:"omitempty,email,max=254"`
 	IsAdmin   bool      `json:"is_admin" validate:"isdefault"`
+	Role      string    `json:"role" validate:"isdefault"`
+	Disabled  bool      `json:"disabled" validate:"isdefault"`
 	CreatedAt time.Time `json:"created_at" validate:"isdefault"`
 	UpdatedAt time.Time `json:"updated_at" validate:"isdefault"`
+
+	// two-factor authentication, the secret is enabled after the first valid code
+	TOTPSecret    string   `json:"-"`
+	TOTPEnabled   bool     `json:"-"`
+	TOTPLastStep  int64    `json:"-"` // time step of the last accepted code, it can't be used again
+	RecoveryCodes []string `json:"-"` // SHA-256 hashes of the unused recovery codes
+	// TOTPFailures counts the invalid codes since the last accepted one, the
+	// user is locked out until TOTPLockedUntil after every few of them
+	TOTPFailures    int        `json:"-"`
+	TOTPLockedUntil *time.Time `json:"-"`
+}
+
+// EffectiveRole returns the role of the user, the users created before the
+// roles were introduced are treated as admins or regular users
+func (u *User) EffectiveRole() string {
+	if u.Role != "" {
+		return u.Role
+	}
+	if u.IsAdmin {
+		return RoleAdmin
+	}
+	return RoleUser
+}
+
+// Profile returns the user without the password hash and the secrets
+func (u *User) Profile() *UserProfile {
+	return &UserProfile{
+		ID:          u.ID,
+		Username:    u.Username,
+		Email:       u.Email,
+		IsAdmin:     u.EffectiveRole() == RoleAdmin,
+		Role:        u.EffectiveRole(),
+		Disabled:    u.Disabled,
+		HasPassword: u.Password != "",
+		TOTPEnabled: u.TOTPEnabled,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+	}
+}
+
+// UserProfile defines the user returned by the management API
+type UserProfile struct {
+	ID          int       `json:"id" example:"1"`
+	Username    string    `json:"username" example:"JohnDoe"`
+	Email       string    `json:"email,omitempty" example:"john@example.org"`
+	IsAdmin     bool      `json:"is_admin" example:"false"`
+	Role        string    `json:"role" example:"user"`
+	Disabled    bool      `json:"disabled" example:"false"`
+	HasPassword bool      `json:"has_password" example:"true"`
+	TOTPEnabled bool      `json:"totp_enabled" example:"false"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// UserPage defines the page of the users
+type UserPage struct {
+	Users   []*UserProfile `json:"users"`
+	Page    int            `json:"page" example:"1"`
+	PerPage int            `json:"per_page" example:"20"`
+	Total   int            `json:"total" example:"42"`
+}
+
+// UserUpdate defines the changed fields of the user, the omitted fields are kept
+type UserUpdate struct {
+	Email *string `json:"email,omitempty" validate:"omitempty,email,max=254" example:"john@example.org"`
+	Role  *string `json:"role,omitempty" validate:"omitempty,oneof=admin editor user scanner" example:"editor"`
+}
+
+// PasswordChange defines the new password of the user, the current password
+// is required when the users change their own password
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password,omitempty" example:"oldpassword"`
+	NewPassword     string `json:"new_password" validate:"required,gte=8" example:"newpassword"`
 }
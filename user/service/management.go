@@ -0,0 +1,229 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0x113/x-media/user/data"
+	"github.com/0x113/x-media/user/models"
+
+	"github.com/go-playground/validator"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+var (
+	// ErrWrongPassword is returned when the current password provided along with the new one is wrong
+	ErrWrongPassword = errors.New("Current password is wrong")
+	// ErrInvalidUser is returned when the changed email, role or password doesn't pass the validation
+	ErrInvalidUser = errors.New("Invalid user data")
+)
+
+// ListUsers returns the page of the users, the pages are numbered from 1
+func (s *userService) ListUsers(page, perPage int) (*models.UserPage, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	users, total, err := s.repo.List((page-1)*perPage, perPage)
+	if err != nil {
+		log.Errorf("Couldn't list users [page=%d, per_page=%d]: %v", page, perPage, err)
+		return nil, fmt.Errorf("Couldn't get the users from the database")
+	}
+
+	profiles := make([]*models.UserProfile, len(users))
+	for i, user := range users {
+		profiles[i] = user.Profile()
+	}
+	return &models.UserPage{
+		Users:   profiles,
+		Page:    page,
+		PerPage: perPage,
+		Total:   total,
+	}, nil
+}
+
+// UpdateUser changes the email and the role of the user, the admin flag follows the role
+func (s *userService) UpdateUser(username string, update *models.UserUpdate) (*models.UserProfile, error) {
+	validation := validator.New()
+	if err := validation.Struct(update); err != nil {
+		log.Errorf("Couldn't validate user update: %v", err)
+		return nil, fmt.Errorf("%w: email must be valid and role must be one of: admin, editor, user, scanner", ErrInvalidUser)
+	}
+	user, err := s.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Email != nil {
+		user.Email = *update.Email
+	}
+	roleChanged := update.Role != nil && *update.Role != user.EffectiveRole()
+	if update.Role != nil {
+		user.Role = *update.Role
+		user.IsAdmin = user.Role == models.RoleAdmin
+	}
+	if err := s.updateUser(user); err != nil {
+		return nil, err
+	}
+	if roleChanged {
+		s.revokeAccess(username)
+	}
+
+	log.Infof("Successfully updated user [username=%s, role=%s]", username, user.EffectiveRole())
+	return user.Profile(), nil
+}
+
+// ChangePassword sets the new password of the user who proved the knowledge
+// of the current one, the passwordless accounts can't set it this way
+func (s *userService) ChangePassword(username string, req *models.PasswordChange) error {
+	user, err := s.GetUser(username)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		log.Errorf("Wrong current password on password change [username=%s]", username)
+		return ErrWrongPassword
+	}
+
+	if err := s.setPassword(user, req.NewPassword); err != nil {
+		return err
+	}
+	log.Infof("Successfully changed password [username=%s]", username)
+	return nil
+}
+
+// ResetPassword sets the new password of the user without the current one
+// and signs the user out of all of the devices
+func (s *userService) ResetPassword(username, password string) error {
+	user, err := s.GetUser(username)
+	if err != nil {
+		return err
+	}
+
+	if err := s.setPassword(user, password); err != nil {
+		return err
+	}
+	s.revokeSessions(username)
+	log.Infof("Successfully reset password [username=%s]", username)
+	return nil
+}
+
+// DeleteUser removes the user along with the passkeys and revokes the
+// sessions and API keys of the user
+func (s *userService) DeleteUser(username string) error {
+	if err := s.repo.Delete(username); err != nil {
+		log.Errorf("Couldn't delete user [username=%s]: %v", username, err)
+		if errors.Is(err, data.ErrNotFound) {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, username)
+		}
+		return fmt.Errorf("Couldn't delete the user")
+	}
+	s.revokeAccess(username)
+
+	log.Infof("Successfully deleted user [username=%s]", username)
+	return nil
+}
+
+// SetDisabled disables or enables the user, the disabled users can't sign in
+// and their sessions and API keys are revoked
+func (s *userService) SetDisabled(username string, disabled bool) error {
+	user, err := s.GetUser(username)
+	if err != nil {
+		return err
+	}
+	if user.Disabled == disabled {
+		return nil
+	}
+
+	user.Disabled = disabled
+	if err := s.updateUser(user); err != nil {
+		return err
+	}
+	if disabled {
+		s.revokeAccess(username)
+	}
+	log.Infof("Successfully changed status of user [username=%s, disabled=%t]", username, disabled)
+	return nil
+}
+
+// SetAdmin promotes the user to the admin or demotes the admin to the regular
+// user, the tokens issued with the previous role are revoked
+func (s *userService) SetAdmin(username string, admin bool) error {
+	user, err := s.GetUser(username)
+	if err != nil {
+		return err
+	}
+	if admin == (user.EffectiveRole() == models.RoleAdmin) {
+		return nil
+	}
+
+	user.IsAdmin = admin
+	user.Role = models.RoleUser
+	if admin {
+		user.Role = models.RoleAdmin
+	}
+	if err := s.updateUser(user); err != nil {
+		return err
+	}
+	s.revokeAccess(username)
+	log.Infof("Successfully changed role of user [username=%s, role=%s]", username, user.Role)
+	return nil
+}
+
+// revokeAccess revokes the sessions and API keys of the user whose status or
+// role has changed, the failures are only logged because the user has already
+// been saved and the refresh checks the user again anyway
+func (s *userService) revokeAccess(username string) {
+	if err := s.sessions.RevokeSessions(username); err != nil {
+		log.Errorf("Couldn't revoke sessions [username=%s]: %v", username, err)
+	} else {
+		log.Infof("Successfully revoked sessions [username=%s]", username)
+	}
+	if err := s.sessions.RevokeAPIKeys(username); err != nil {
+		log.Errorf("Couldn't revoke API keys [username=%s]: %v", username, err)
+	} else {
+		log.Infof("Successfully revoked API keys [username=%s]", username)
+	}
+}
+
+// setPassword validates, hashes and saves the new password of the user
+func (s *userService) setPassword(user *models.User, password string) error {
+	validation := validator.New()
+	if err := validation.Var(password, "required,gte=8"); err != nil {
+		return fmt.Errorf("%w: password should be at least 8 characters long", ErrInvalidUser)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 11)
+	if err != nil {
+		log.Errorf("Couldn't hash password for user: %v", err)
+		return fmt.Errorf("Couldn't hash password")
+	}
+
+	user.Password = string(hash)
+	return s.updateUser(user)
+}
+
+// updateUser saves the user, the duplicate email is reported as ErrUserExists
+func (s *userService) updateUser(user *models.User) error {
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(user); err != nil {
+		log.Errorf("Couldn't update user [username=%s]: %v", user.Username, err)
+		if errors.Is(err, data.ErrDuplicate) {
+			return fmt.Errorf("%w: email %s is already used", ErrUserExists, user.Email)
+		}
+		return fmt.Errorf("Couldn't update the user: %v", err)
+	}
+	return nil
+}
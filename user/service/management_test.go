@@ -0,0 +1,182 @@
+package service_test
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0x113/x-media/user/models"
+	"github.com/0x113/x-media/user/service"
+)
+
+func (suite *UserServiceTestSuite) TestListUsers() {
+	// the databases assign the ids in the order of creation
+	suite.Require().Nil(suite.userRepo.Delete("JohnDoe"))
+	for i := 1; i <= 25; i++ {
+		suite.Require().Nil(suite.userRepo.Create(&models.User{ID: i, Username: fmt.Sprintf("user%02d", i)}))
+	}
+
+	testCases := []struct {
+		name      string
+		page      int
+		perPage   int
+		wantPage  int
+		wantCount int
+		wantFirst string
+	}{
+		{name: "Defaults", wantPage: 1, wantCount: 20, wantFirst: "user01"},
+		{name: "Second page", page: 2, perPage: 10, wantPage: 2, wantCount: 10, wantFirst: "user11"},
+		{name: "Last page", page: 3, perPage: 10, wantPage: 3, wantCount: 5, wantFirst: "user21"},
+		{name: "After the last page", page: 5, perPage: 10, wantPage: 5, wantCount: 0},
+		{name: "Too many per page", perPage: 1000, wantPage: 1, wantCount: 25, wantFirst: "user01"},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			page, err := suite.userService.ListUsers(tt.page, tt.perPage)
+			suite.Require().Nil(err)
+			suite.Equal(tt.wantPage, page.Page)
+			suite.Equal(25, page.Total)
+			suite.Require().Len(page.Users, tt.wantCount)
+			if tt.wantCount > 0 {
+				suite.Equal(tt.wantFirst, page.Users[0].Username)
+			}
+		})
+	}
+}
+
+func (suite *UserServiceTestSuite) TestUpdateUser() {
+	email := "john@example.org"
+	role := models.RoleAdmin
+	profile, err := suite.userService.UpdateUser("JohnDoe", &models.UserUpdate{Email: &email, Role: &role})
+	suite.Require().Nil(err)
+	suite.Equal(email, profile.Email)
+	suite.True(profile.IsAdmin)
+	suite.True(profile.HasPassword)
+
+	suite.Equal([]string{"JohnDoe"}, suite.sessions.Revoked)
+	suite.Equal([]string{"JohnDoe"}, suite.sessions.RevokedAPIKeys)
+
+	// the omitted fields are kept and the sessions aren't revoked without the role change
+	profile, err = suite.userService.UpdateUser("JohnDoe", &models.UserUpdate{})
+	suite.Require().Nil(err)
+	suite.Equal(email, profile.Email)
+	suite.Equal(models.RoleAdmin, profile.Role)
+	suite.Len(suite.sessions.Revoked, 1)
+
+	suite.Require().Nil(suite.userService.CreateUser(&models.User{Username: "alice", Password: "strongpassword"}))
+	_, err = suite.userService.UpdateUser("alice", &models.UserUpdate{Email: &email})
+	suite.True(errors.Is(err, service.ErrUserExists), "unexpected error: %v", err)
+	user, err := suite.userService.GetUser("alice")
+	suite.Require().Nil(err)
+	suite.Empty(user.Email)
+
+	invalid := "root"
+	_, err = suite.userService.UpdateUser("alice", &models.UserUpdate{Role: &invalid})
+	suite.True(errors.Is(err, service.ErrInvalidUser), "unexpected error: %v", err)
+	_, err = suite.userService.UpdateUser("alice", &models.UserUpdate{Email: &invalid})
+	suite.True(errors.Is(err, service.ErrInvalidUser), "unexpected error: %v", err)
+	_, err = suite.userService.UpdateUser("nobody", &models.UserUpdate{})
+	suite.True(errors.Is(err, service.ErrUserNotFound))
+}
+
+func (suite *UserServiceTestSuite) TestChangePassword() {
+	testCases := []struct {
+		name    string
+		req     *models.PasswordChange
+		wantErr error
+	}{
+		{
+			name:    "Wrong current password",
+			req:     &models.PasswordChange{CurrentPassword: "wrongpassword", NewPassword: "newpassword"},
+			wantErr: service.ErrWrongPassword,
+		},
+		{
+			name:    "Too short",
+			req:     &models.PasswordChange{CurrentPassword: "test1231", NewPassword: "short"},
+			wantErr: service.ErrInvalidUser,
+		},
+		{
+			name: "Success",
+			req:  &models.PasswordChange{CurrentPassword: "test1231", NewPassword: "newpassword"},
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			err := suite.userService.ChangePassword("JohnDoe", tt.req)
+			_, loginErr := suite.userService.ValidateUser(&models.Credentials{Username: "JohnDoe", Password: tt.req.NewPassword})
+			if tt.name == "Success" {
+				suite.Nil(err)
+				suite.Nil(loginErr)
+				return
+			}
+			suite.NotNil(err)
+			if tt.wantErr != nil {
+				suite.True(errors.Is(err, tt.wantErr))
+			}
+			suite.NotNil(loginErr)
+		})
+	}
+
+	// the admin doesn't need the current password
+	err := suite.userService.ResetPassword("JohnDoe", "short")
+	suite.True(errors.Is(err, service.ErrInvalidUser), "unexpected error: %v", err)
+	suite.Nil(suite.userService.ResetPassword("JohnDoe", "resetpassword"))
+	_, err = suite.userService.ValidateUser(&models.Credentials{Username: "JohnDoe", Password: "resetpassword"})
+	suite.Nil(err)
+	err = suite.userService.ResetPassword("nobody", "resetpassword")
+	suite.True(errors.Is(err, service.ErrUserNotFound))
+}
+
+func (suite *UserServiceTestSuite) TestDisableUser() {
+	creds := &models.Credentials{Username: "JohnDoe", Password: "test1231"}
+
+	suite.Nil(suite.userService.SetDisabled("JohnDoe", true))
+	_, err := suite.userService.ValidateUser(creds)
+	suite.True(errors.Is(err, service.ErrUserDisabled))
+	_, err = suite.userService.ProvisionUser(&models.ProvisionRequest{Username: "JohnDoe"})
+	suite.True(errors.Is(err, service.ErrUserDisabled))
+
+	suite.Equal([]string{"JohnDoe"}, suite.sessions.Revoked)
+	suite.Equal([]string{"JohnDoe"}, suite.sessions.RevokedAPIKeys)
+
+	// enabling the user doesn't revoke anything
+	suite.Nil(suite.userService.SetDisabled("JohnDoe", false))
+	_, err = suite.userService.ValidateUser(creds)
+	suite.Nil(err)
+	suite.Len(suite.sessions.Revoked, 1)
+
+	err = suite.userService.SetDisabled("nobody", true)
+	suite.True(errors.Is(err, service.ErrUserNotFound))
+}
+
+func (suite *UserServiceTestSuite) TestSetAdmin() {
+	suite.Nil(suite.userService.SetAdmin("JohnDoe", true))
+	claims, err := suite.userService.ValidateUser(&models.Credentials{Username: "JohnDoe", Password: "test1231"})
+	suite.Require().Nil(err)
+	suite.True(claims.IsAdmin)
+	suite.Contains(claims.Scopes, models.ScopeUsersManage)
+
+	suite.Nil(suite.userService.SetAdmin("JohnDoe", false))
+	claims, err = suite.userService.ValidateUser(&models.Credentials{Username: "JohnDoe", Password: "test1231"})
+	suite.Require().Nil(err)
+	suite.False(claims.IsAdmin)
+	suite.Equal(models.RoleUser, claims.Role)
+	suite.Equal([]string{"JohnDoe", "JohnDoe"}, suite.sessions.Revoked)
+	suite.Equal([]string{"JohnDoe", "JohnDoe"}, suite.sessions.RevokedAPIKeys)
+
+	// the unchanged role keeps the sessions
+	suite.Nil(suite.userService.SetAdmin("JohnDoe", false))
+	suite.Len(suite.sessions.Revoked, 2)
+}
+
+func (suite *UserServiceTestSuite) TestDeleteUser() {
+	suite.Nil(suite.userService.DeleteUser("JohnDoe"))
+	_, err := suite.userService.GetUser("JohnDoe")
+	suite.True(errors.Is(err, service.ErrUserNotFound))
+	suite.Equal([]string{"JohnDoe"}, suite.sessions.Revoked)
+	suite.Equal([]string{"JohnDoe"}, suite.sessions.RevokedAPIKeys)
+
+	err = suite.userService.DeleteUser("JohnDoe")
+	suite.True(errors.Is(err, service.ErrUserNotFound))
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0x113/x-media/user/models"
+
+	"github.com/go-playground/validator"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrPasskeyNotFound is returned when the user doesn't have the passkey with provided id
+	ErrPasskeyNotFound = errors.New("Passkey not found")
+	// ErrSignCount is returned when the signature counter of the passkey
+	// didn't increase, which means that the authenticator might be cloned
+	ErrSignCount = errors.New("Signature counter of the passkey didn't increase")
+	// ErrLastCredential is returned when the user would be left without both
+	// the password and the passkey
+	ErrLastCredential = errors.New("Account must keep a password or at least one passkey")
+)
+
+// AddPasskey stores the passkey verified by the authentication service
+func (s *userService) AddPasskey(p *models.Passkey) error {
+	validation := validator.New()
+	if err := validation.Struct(p); err != nil {
+		log.Errorf("Couldn't validate passkey: %v", err)
+		return fmt.Errorf("Couldn't validate provided passkey. Credential id and public key are required, name can be max 64 characters long.")
+	}
+	user, err := s.GetUser(p.Username)
+	if err != nil {
+		return err
+	}
+
+	if p.Name == "" {
+		p.Name = "Passkey"
+	}
+	p.UserID = user.ID
+	p.CreatedAt = time.Now()
+	p.LastUsedAt = nil
+	if err := s.passkeys.CreatePasskey(p); err != nil {
+		log.Errorf("Couldn't create passkey [username=%s]: %v", p.Username, err)
+		return fmt.Errorf("Couldn't create new passkey: %v", err)
+	}
+
+	log.Infof("Successfully added new passkey [username=%s, name=%s]", p.Username, p.Name)
+	return nil
+}
+
+// GetPasskeys returns the passkeys of the user
+func (s *userService) GetPasskeys(username string) ([]*models.Passkey, error) {
+	user, err := s.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+
+	passkeys, err := s.passkeys.GetPasskeys(user.ID)
+	if err != nil {
+		log.Errorf("Couldn't get passkeys [username=%s]: %v", username, err)
+		return nil, fmt.Errorf("Couldn't get the passkeys from the database")
+	}
+	return passkeys, nil
+}
+
+// GetPasskey returns the passkey with provided credential id
+func (s *userService) GetPasskey(id string) (*models.Passkey, error) {
+	p, err := s.passkeys.GetPasskey(id)
+	if err != nil {
+		log.Errorf("Couldn't get passkey [id=%s]: %v", id, err)
+		return nil, ErrPasskeyNotFound
+	}
+	return p, nil
+}
+
+// LoginPasskey records the usage of the passkey whose assertion has been
+// verified and returns the claims of its owner
+func (s *userService) LoginPasskey(id string, login *models.PasskeyLogin) (*models.TokenClaims, error) {
+	p, err := s.GetPasskey(id)
+	if err != nil {
+		return nil, err
+	}
+	// authenticators which don't implement the counter always send zero
+	if (login.SignCount != 0 || p.SignCount != 0) && login.SignCount <= p.SignCount {
+		log.Errorf("Signature counter of passkey didn't increase [id=%s, stored=%d, received=%d]", id, p.SignCount, login.SignCount)
+		return nil, ErrSignCount
+	}
+
+	user, err := s.GetUser(p.Username)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		log.Errorf("Disabled user tried to sign in with passkey [username=%s]", user.Username)
+		return nil, ErrUserDisabled
+	}
+
+	now := time.Now()
+	p.SignCount = login.SignCount
+	p.LastUsedAt = &now
+	if err := s.passkeys.UpdatePasskey(p); err != nil {
+		log.Errorf("Couldn't update passkey [id=%s]: %v", id, err)
+		return nil, fmt.Errorf("Couldn't update the passkey")
+	}
+
+	log.Infof("Successfully logged in with passkey [username=%s, name=%s]", user.Username, p.Name)
+	return tokenClaims(user), nil
+}
+
+// DeletePasskey removes the passkey of the user, the last passkey can't be
+// removed from the account without the password
+func (s *userService) DeletePasskey(username, id string) error {
+	p, err := s.GetPasskey(id)
+	if err != nil {
+		return err
+	}
+	if p.Username != username {
+		return ErrPasskeyNotFound
+	}
+
+	user, err := s.GetUser(username)
+	if err != nil {
+		return err
+	}
+	if user.Password == "" {
+		passkeys, err := s.GetPasskeys(username)
+		if err != nil {
+			return err
+		}
+		if len(passkeys) <= 1 {
+			return ErrLastCredential
+		}
+	}
+
+	if err := s.passkeys.DeletePasskey(id); err != nil {
+		log.Errorf("Couldn't delete passkey [id=%s]: %v", id, err)
+		return fmt.Errorf("Couldn't delete the passkey")
+	}
+
+	log.Infof("Successfully deleted passkey [username=%s, name=%s]", username, p.Name)
+	return nil
+}
+
+// RemovePassword removes the password of the user who has at least one
+// passkey, so the account can be used only with the passkeys
+func (s *userService) RemovePassword(username string) error {
+	passkeys, err := s.GetPasskeys(username)
+	if err != nil {
+		return err
+	}
+	if len(passkeys) == 0 {
+		return ErrLastCredential
+	}
+
+	user, err := s.GetUser(username)
+	if err != nil {
+		return err
+	}
+	user.Password = ""
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(user); err != nil {
+		log.Errorf("Couldn't remove password [username=%s]: %v", username, err)
+		return fmt.Errorf("Couldn't update the user: %v", err)
+	}
+
+	log.Infof("Successfully removed password [username=%s]", username)
+	return nil
+}
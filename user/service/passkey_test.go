@@ -0,0 +1,117 @@
+package service_test
+
+import (
+	"errors"
+
+	"github.com/0x113/x-media/user/models"
+	"github.com/0x113/x-media/user/service"
+)
+
+func (suite *UserServiceTestSuite) TestAddPasskey() {
+	testCases := []struct {
+		name    string
+		passkey *models.Passkey
+		wantErr bool
+	}{
+		{
+			name:    "Success",
+			passkey: &models.Passkey{ID: "cred-1", Username: "JohnDoe", PublicKey: []byte{1, 2, 3}},
+			wantErr: false,
+		},
+		{
+			name:    "Duplicate credential",
+			passkey: &models.Passkey{ID: "cred-1", Username: "JohnDoe", PublicKey: []byte{1, 2, 3}},
+			wantErr: true,
+		},
+		{
+			name:    "Missing public key",
+			passkey: &models.Passkey{ID: "cred-2", Username: "JohnDoe"},
+			wantErr: true,
+		},
+		{
+			name:    "Non-existent user",
+			passkey: &models.Passkey{ID: "cred-3", Username: "JanKowalski", PublicKey: []byte{1, 2, 3}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			err := suite.userService.AddPasskey(tt.passkey)
+			if tt.wantErr {
+				suite.NotNil(err)
+			} else {
+				suite.Nil(err)
+			}
+		})
+	}
+
+	passkeys, err := suite.userService.GetPasskeys("JohnDoe")
+	suite.Nil(err)
+	suite.Require().Len(passkeys, 1)
+	suite.Equal("Passkey", passkeys[0].Name)
+	user, err := suite.userRepo.Get("JohnDoe")
+	suite.Require().Nil(err)
+	suite.Equal(user.ID, passkeys[0].UserID)
+}
+
+func (suite *UserServiceTestSuite) TestLoginPasskey() {
+	suite.Require().Nil(suite.userService.AddPasskey(&models.Passkey{ID: "cred-1", Username: "JohnDoe", PublicKey: []byte{1}, SignCount: 5}))
+	suite.Require().Nil(suite.userService.AddPasskey(&models.Passkey{ID: "cred-2", Username: "JohnDoe", PublicKey: []byte{1}}))
+
+	testCases := []struct {
+		name      string
+		id        string
+		signCount uint32
+		wantErr   error
+	}{
+		{name: "Counter increased", id: "cred-1", signCount: 6},
+		{name: "Counter replayed", id: "cred-1", signCount: 6, wantErr: service.ErrSignCount},
+		{name: "Counter went backwards", id: "cred-1", signCount: 2, wantErr: service.ErrSignCount},
+		{name: "Authenticator without counter", id: "cred-2", signCount: 0},
+		{name: "Authenticator without counter used again", id: "cred-2", signCount: 0},
+		{name: "Unknown passkey", id: "cred-3", signCount: 1, wantErr: service.ErrPasskeyNotFound},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			claims, err := suite.userService.LoginPasskey(tt.id, &models.PasskeyLogin{SignCount: tt.signCount})
+			if tt.wantErr != nil {
+				suite.True(errors.Is(err, tt.wantErr))
+				return
+			}
+			suite.Require().Nil(err)
+			suite.Equal("JohnDoe", claims.Username)
+			suite.Equal(models.RoleUser, claims.Role)
+
+			p, err := suite.userService.GetPasskey(tt.id)
+			suite.Require().Nil(err)
+			suite.Equal(tt.signCount, p.SignCount)
+			suite.NotNil(p.LastUsedAt)
+		})
+	}
+}
+
+func (suite *UserServiceTestSuite) TestPasswordlessAccount() {
+	// password can't be removed without the passkey
+	suite.True(errors.Is(suite.userService.RemovePassword("JohnDoe"), service.ErrLastCredential))
+
+	suite.Require().Nil(suite.userService.AddPasskey(&models.Passkey{ID: "cred-1", Username: "JohnDoe", PublicKey: []byte{1}}))
+	suite.Require().Nil(suite.userService.AddPasskey(&models.Passkey{ID: "cred-2", Username: "JohnDoe", PublicKey: []byte{1}}))
+	suite.Require().Nil(suite.userService.RemovePassword("JohnDoe"))
+
+	// the password no longer works
+	_, err := suite.userService.ValidateUser(&models.Credentials{Username: "JohnDoe", Password: "test1231"})
+	suite.NotNil(err)
+	_, err = suite.userService.ValidateUser(&models.Credentials{Username: "JohnDoe", Password: ""})
+	suite.NotNil(err)
+
+	// passkeys of other users can't be deleted
+	suite.True(errors.Is(suite.userService.DeletePasskey("admin", "cred-1"), service.ErrPasskeyNotFound))
+
+	// the last passkey is kept
+	suite.Nil(suite.userService.DeletePasskey("JohnDoe", "cred-1"))
+	suite.True(errors.Is(suite.userService.DeletePasskey("JohnDoe", "cred-2"), service.ErrLastCredential))
+	_, err = suite.userService.LoginPasskey("cred-2", &models.PasskeyLogin{})
+	suite.Nil(err)
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x113/x-media/user/common"
+	"github.com/0x113/x-media/user/data"
+	"github.com/0x113/x-media/user/mail"
+	"github.com/0x113/x-media/user/models"
+
+	"github.com/go-playground/validator"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultResetTTL = 30 * time.Minute
+
+	// resetEmailLimit and resetIPLimit define how many password resets can be
+	// requested for one email and from one address within resetLimitWindow
+	resetEmailLimit  = 3
+	resetIPLimit     = 20
+	resetLimitWindow = time.Hour
+)
+
+var (
+	// ErrResetTokenInvalid is returned when the password reset token is unknown, used or expired
+	ErrResetTokenInvalid = errors.New("Invalid or expired password reset token")
+	// ErrInvalidResetRequest is returned when the email or the new password doesn't pass the validation
+	ErrInvalidResetRequest = errors.New("Invalid password reset request")
+)
+
+// RequestPasswordReset sends the one-time link to the email of the user. The
+// unknown emails, the disabled users, the requests over the limits of the
+// email or the client address and the failures are only logged, so the
+// response doesn't reveal which emails are registered
+func (s *userService) RequestPasswordReset(req *models.PasswordResetRequest, clientIP string) error {
+	validation := validator.New()
+	if err := validation.Struct(req); err != nil {
+		log.Errorf("Couldn't validate password reset request: %v", err)
+		return fmt.Errorf("%w: email must be valid", ErrInvalidResetRequest)
+	}
+	if !s.limiter.allow("ip:"+clientIP, resetIPLimit) || !s.limiter.allow("email:"+strings.ToLower(req.Email), resetEmailLimit) {
+		log.Warnf("Too many password reset requests [email=%s, ip=%s]", req.Email, clientIP)
+		return nil
+	}
+
+	user, err := s.repo.GetByEmail(req.Email)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			log.Infof("Password reset requested for unknown email [email=%s]", req.Email)
+			return nil
+		}
+		log.Errorf("Couldn't get user by email [email=%s]: %v", req.Email, err)
+		return nil
+	}
+	if user.Disabled {
+		log.Infof("Password reset requested for disabled user [username=%s]", user.Username)
+		return nil
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		log.Errorf("Couldn't generate password reset token: %v", err)
+		return nil
+	}
+	// only the latest link works
+	if err := s.resets.DeleteResetTokens(user.ID); err != nil {
+		log.Errorf("Couldn't delete password reset tokens [username=%s]: %v", user.Username, err)
+		return nil
+	}
+	now := time.Now()
+	ttl := resetTTL()
+	if err := s.resets.CreateResetToken(&models.PasswordResetToken{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}); err != nil {
+		log.Errorf("Couldn't save password reset token [username=%s]: %v", user.Username, err)
+		return nil
+	}
+
+	msg := &mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nsomeone requested a password reset for your account. Open the link below to set a new password, it expires in %d minutes:\n\n%s\n\nIf it wasn't you, ignore this email and your password won't change.\n",
+			user.Username, int(ttl.Minutes()), resetLink(token)),
+	}
+	if err := s.mailer.Send(msg); err != nil {
+		log.Errorf("Couldn't send password reset email [username=%s]: %v", user.Username, err)
+		return nil
+	}
+
+	log.Infof("Successfully sent password reset email [username=%s]", user.Username)
+	return nil
+}
+
+// resetLimiter counts the password reset requests in memory within the
+// fixed windows, every instance of the service keeps its own counters
+type resetLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*resetWindow
+	pruned  time.Time
+}
+
+// resetWindow defines the number of the requests since its start
+type resetWindow struct {
+	start time.Time
+	count int
+}
+
+func newResetLimiter() *resetLimiter {
+	return &resetLimiter{windows: map[string]*resetWindow{}, pruned: time.Now()}
+}
+
+// allow counts the request of the key and checks that it's within the limit,
+// the expired windows are removed once per window so the map doesn't grow forever
+func (l *resetLimiter) allow(key string, limit int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.pruned) >= resetLimitWindow {
+		for k, w := range l.windows {
+			if now.Sub(w.start) >= resetLimitWindow {
+				delete(l.windows, k)
+			}
+		}
+		l.pruned = now
+	}
+	window, ok := l.windows[key]
+	if !ok || now.Sub(window.start) >= resetLimitWindow {
+		window = &resetWindow{start: now}
+		l.windows[key] = window
+	}
+	window.count++
+	return window.count <= limit
+}
+
+// ConfirmPasswordReset sets the new password of the owner of the token and
+// signs the user out of all of the devices. The token is deleted even if it
+// turns out to be expired
+func (s *userService) ConfirmPasswordReset(req *models.PasswordResetConfirm) error {
+	validation := validator.New()
+	if err := validation.Struct(req); err != nil {
+		log.Errorf("Couldn't validate password reset confirmation: %v", err)
+		return fmt.Errorf("%w: token is required and password should be at least 8 characters long", ErrInvalidResetRequest)
+	}
+
+	token, err := s.resets.TakeResetToken(hashToken(req.Token))
+	if err != nil {
+		log.Errorf("Couldn't get password reset token: %v", err)
+		if errors.Is(err, data.ErrNotFound) {
+			return ErrResetTokenInvalid
+		}
+		return fmt.Errorf("Couldn't get the password reset token from the database")
+	}
+	if time.Now().After(token.ExpiresAt) {
+		log.Errorf("Expired password reset token [username=%s]", token.Username)
+		return ErrResetTokenInvalid
+	}
+	user, err := s.GetUser(token.Username)
+	if err != nil {
+		return err
+	}
+	if user.Disabled {
+		log.Errorf("Disabled user tried to reset password [username=%s]", user.Username)
+		return ErrResetTokenInvalid
+	}
+
+	if err := s.setPassword(user, req.NewPassword); err != nil {
+		return err
+	}
+	if err := s.resets.DeleteResetTokens(user.ID); err != nil {
+		log.Errorf("Couldn't delete password reset tokens [username=%s]: %v", user.Username, err)
+	}
+	s.revokeSessions(user.Username)
+
+	log.Infof("Successfully reset password with token [username=%s]", user.Username)
+	return nil
+}
+
+// revokeSessions signs the user out after the password reset, the failure
+// is only logged because the password has already been changed
+func (s *userService) revokeSessions(username string) {
+	if err := s.sessions.RevokeSessions(username); err != nil {
+		log.Errorf("Couldn't revoke sessions after password reset [username=%s]: %v", username, err)
+		return
+	}
+	log.Infof("Successfully revoked sessions after password reset [username=%s]", username)
+}
+
+// resetTTL returns the configured lifetime of the password reset tokens
+func resetTTL() time.Duration {
+	if common.Config.PasswordResetTTLMinutes > 0 {
+		return time.Duration(common.Config.PasswordResetTTLMinutes) * time.Minute
+	}
+	return defaultResetTTL
+}
+
+// resetLink returns the page of the web frontend with the token in the query
+func resetLink(token string) string {
+	link, err := url.Parse(common.Config.PasswordResetURL)
+	if err != nil {
+		return common.Config.PasswordResetURL + "?token=" + token
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+// randomToken returns the random url-safe token
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken hashes the token before it's stored, the tokens are random enough for the fast hash
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,171 @@
+package service_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/0x113/x-media/user/data"
+	"github.com/0x113/x-media/user/models"
+	"github.com/0x113/x-media/user/service"
+)
+
+var resetTokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// testClientIP is the address the password resets are requested from
+const testClientIP = "192.168.1.10"
+
+// requestReset requests the password reset of JohnDoe and returns the token from the email
+func (suite *UserServiceTestSuite) requestReset() string {
+	suite.Require().Nil(suite.userService.RequestPasswordReset(&models.PasswordResetRequest{Email: "john@example.org"}, testClientIP))
+	suite.Require().NotEmpty(suite.mailer.Messages)
+	msg := suite.mailer.Messages[len(suite.mailer.Messages)-1]
+	match := resetTokenPattern.FindStringSubmatch(msg.Body)
+	suite.Require().Len(match, 2)
+	return match[1]
+}
+
+func (suite *UserServiceTestSuite) TestPasswordReset() {
+	email := "john@example.org"
+	_, err := suite.userService.UpdateUser("JohnDoe", &models.UserUpdate{Email: &email})
+	suite.Require().Nil(err)
+
+	// the unknown emails don't reveal anything
+	suite.Nil(suite.userService.RequestPasswordReset(&models.PasswordResetRequest{Email: "nobody@example.org"}, testClientIP))
+	suite.Empty(suite.mailer.Messages)
+	err = suite.userService.RequestPasswordReset(&models.PasswordResetRequest{Email: "not an email"}, testClientIP)
+	suite.True(errors.Is(err, service.ErrInvalidResetRequest))
+
+	first := suite.requestReset()
+	token := suite.requestReset()
+	msg := suite.mailer.Messages[1]
+	suite.Equal(email, msg.To)
+	suite.Contains(msg.Body, "http://localhost:3000/reset-password?token="+token)
+	suite.Contains(msg.Body, "30 minutes")
+
+	testCases := []struct {
+		name    string
+		req     *models.PasswordResetConfirm
+		wantErr error
+	}{
+		{
+			name:    "Too short",
+			req:     &models.PasswordResetConfirm{Token: token, NewPassword: "short"},
+			wantErr: service.ErrInvalidResetRequest,
+		},
+		{
+			name:    "Replaced token",
+			req:     &models.PasswordResetConfirm{Token: first, NewPassword: "newpassword"},
+			wantErr: service.ErrResetTokenInvalid,
+		},
+		{
+			name: "Success",
+			req:  &models.PasswordResetConfirm{Token: token, NewPassword: "newpassword"},
+		},
+		{
+			name:    "Used token",
+			req:     &models.PasswordResetConfirm{Token: token, NewPassword: "anotherpassword"},
+			wantErr: service.ErrResetTokenInvalid,
+		},
+	}
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			err := suite.userService.ConfirmPasswordReset(tt.req)
+			if tt.wantErr != nil {
+				suite.True(errors.Is(err, tt.wantErr), "unexpected error: %v", err)
+				return
+			}
+			suite.Nil(err)
+		})
+	}
+
+	_, err = suite.userService.ValidateUser(&models.Credentials{Username: "JohnDoe", Password: "newpassword"})
+	suite.Nil(err)
+	suite.Equal([]string{"JohnDoe"}, suite.sessions.Revoked)
+}
+
+func (suite *UserServiceTestSuite) TestPasswordResetExpiredToken() {
+	user, err := suite.userRepo.Get("JohnDoe")
+	suite.Require().Nil(err)
+	sum := sha256.Sum256([]byte("expiredtoken"))
+	hash := hex.EncodeToString(sum[:])
+	suite.Require().Nil(suite.resetRepo.CreateResetToken(&models.PasswordResetToken{
+		TokenHash: hash,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(-time.Minute),
+		CreatedAt: time.Now().Add(-time.Hour),
+	}))
+
+	err = suite.userService.ConfirmPasswordReset(&models.PasswordResetConfirm{Token: "expiredtoken", NewPassword: "newpassword"})
+	suite.True(errors.Is(err, service.ErrResetTokenInvalid))
+	_, err = suite.resetRepo.TakeResetToken(hash)
+	suite.True(errors.Is(err, data.ErrNotFound))
+	_, err = suite.userService.ValidateUser(&models.Credentials{Username: "JohnDoe", Password: "test1231"})
+	suite.Nil(err)
+	suite.Empty(suite.sessions.Revoked)
+}
+
+func (suite *UserServiceTestSuite) TestPasswordResetDisabledUser() {
+	email := "john@example.org"
+	_, err := suite.userService.UpdateUser("JohnDoe", &models.UserUpdate{Email: &email})
+	suite.Require().Nil(err)
+	token := suite.requestReset()
+
+	// the disabled users neither receive the email nor use the sent link
+	suite.Require().Nil(suite.userService.SetDisabled("JohnDoe", true))
+	suite.Nil(suite.userService.RequestPasswordReset(&models.PasswordResetRequest{Email: email}, testClientIP))
+	suite.Len(suite.mailer.Messages, 1)
+	err = suite.userService.ConfirmPasswordReset(&models.PasswordResetConfirm{Token: token, NewPassword: "newpassword"})
+	suite.True(errors.Is(err, service.ErrResetTokenInvalid))
+}
+
+func (suite *UserServiceTestSuite) TestPasswordResetMailFailure() {
+	email := "john@example.org"
+	_, err := suite.userService.UpdateUser("JohnDoe", &models.UserUpdate{Email: &email})
+	suite.Require().Nil(err)
+
+	// the failure is only logged, so the response is the same as for the unknown emails
+	suite.mailer.Err = errors.New("connection refused")
+	err = suite.userService.RequestPasswordReset(&models.PasswordResetRequest{Email: email}, testClientIP)
+	suite.Nil(err)
+	suite.Empty(suite.mailer.Messages)
+}
+
+func (suite *UserServiceTestSuite) TestPasswordResetLimits() {
+	email := "john@example.org"
+	_, err := suite.userService.UpdateUser("JohnDoe", &models.UserUpdate{Email: &email})
+	suite.Require().Nil(err)
+
+	// only three emails per hour are sent to the address
+	for i := 0; i < 4; i++ {
+		suite.Nil(suite.userService.RequestPasswordReset(&models.PasswordResetRequest{Email: email}, testClientIP))
+	}
+	suite.Len(suite.mailer.Messages, 3)
+
+	// the client can't request the resets of many emails
+	for i := 0; i < 20; i++ {
+		unknown := fmt.Sprintf("user%d@example.org", i)
+		suite.Nil(suite.userService.RequestPasswordReset(&models.PasswordResetRequest{Email: unknown}, "192.168.1.20"))
+	}
+	suite.Require().Nil(suite.userService.CreateUser(&models.User{Username: "alice", Password: "strongpassword"}))
+	aliceEmail := "alice@example.org"
+	_, err = suite.userService.UpdateUser("alice", &models.UserUpdate{Email: &aliceEmail})
+	suite.Require().Nil(err)
+	suite.Nil(suite.userService.RequestPasswordReset(&models.PasswordResetRequest{Email: aliceEmail}, "192.168.1.20"))
+	suite.Len(suite.mailer.Messages, 3)
+	suite.Nil(suite.userService.RequestPasswordReset(&models.PasswordResetRequest{Email: aliceEmail}, testClientIP))
+	suite.Len(suite.mailer.Messages, 4)
+}
+
+func (suite *UserServiceTestSuite) TestAdminResetRevokesSessions() {
+	suite.Require().Nil(suite.userService.ResetPassword("JohnDoe", "resetpassword"))
+	suite.Equal([]string{"JohnDoe"}, suite.sessions.Revoked)
+
+	// the failed revocation doesn't undo the reset
+	suite.sessions.Err = errors.New("connection refused")
+	suite.Nil(suite.userService.ResetPassword("JohnDoe", "anotherpassword"))
+}
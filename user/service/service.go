@@ -1,10 +1,13 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/0x113/x-media/user/auth"
 	"github.com/0x113/x-media/user/data"
+	"github.com/0x113/x-media/user/mail"
 	"github.com/0x113/x-media/user/models"
 
 	"github.com/go-playground/validator"
@@ -12,20 +15,58 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	// ErrUserNotFound is returned when there is no user with provided username
+	ErrUserNotFound = errors.New("User not found")
+	// ErrUserExists is returned when the username or the email is already taken
+	ErrUserExists = errors.New("User already exists")
+	// ErrUserDisabled is returned when the disabled user tries to sign in
+	ErrUserDisabled = errors.New("User account is disabled")
+	// ErrUserHasPassword is returned when the external identity isn't linked
+	// with the existing user who signs in with the password
+	ErrUserHasPassword = errors.New("User signs in with the password, the identity has to be linked first")
+)
+
 // UserService describes user service
 type UserService interface {
 	CreateUser(u *models.User) error
 	ValidateUser(creds *models.Credentials) (*models.TokenClaims, error)
 	GetUser(username string) (*models.User, error)
+	ProvisionUser(req *models.ProvisionRequest) (*models.TokenClaims, error)
+	GetClaims(username string) (*models.TokenClaims, error)
+	AddPasskey(p *models.Passkey) error
+	GetPasskeys(username string) ([]*models.Passkey, error)
+	GetPasskey(id string) (*models.Passkey, error)
+	LoginPasskey(id string, login *models.PasskeyLogin) (*models.TokenClaims, error)
+	DeletePasskey(username, id string) error
+	RemovePassword(username string) error
+	EnrollTOTP(req *models.TOTPEnrollRequest) (*models.TOTPEnrollment, error)
+	ConfirmTOTP(req *models.TOTPRequest) error
+	VerifyTOTP(req *models.TOTPRequest) (*models.TokenClaims, error)
+	DisableTOTP(req *models.TOTPRequest) error
+	ListUsers(page, perPage int) (*models.UserPage, error)
+	UpdateUser(username string, update *models.UserUpdate) (*models.UserProfile, error)
+	ChangePassword(username string, req *models.PasswordChange) error
+	ResetPassword(username, password string) error
+	DeleteUser(username string) error
+	SetDisabled(username string, disabled bool) error
+	SetAdmin(username string, admin bool) error
+	RequestPasswordReset(req *models.PasswordResetRequest, clientIP string) error
+	ConfirmPasswordReset(req *models.PasswordResetConfirm) error
 }
 
 type userService struct {
-	repo data.UserRepository
+	repo     data.UserRepository
+	passkeys data.PasskeyRepository
+	resets   data.PasswordResetRepository
+	mailer   mail.Sender
+	sessions auth.SessionRevoker
+	limiter  *resetLimiter
 }
 
 // NewUserService creates new instance of UserService
-func NewUserService(repo data.UserRepository) UserService {
-	return &userService{repo}
+func NewUserService(repo data.UserRepository, passkeys data.PasskeyRepository, resets data.PasswordResetRepository, mailer mail.Sender, sessions auth.SessionRevoker) UserService {
+	return &userService{repo, passkeys, resets, mailer, sessions, newResetLimiter()}
 }
 
 // CreateUser calls the database layer to create new user in the database
@@ -44,11 +85,15 @@ func (s *userService) CreateUser(u *models.User) error {
 		return fmt.Errorf("Couldn't hash password")
 	}
 	u.Password = string(hash)
+	u.Role = models.RoleUser
 	u.CreatedAt = time.Now()
 	u.UpdatedAt = time.Now()
 
 	if err := s.repo.Create(u); err != nil {
 		log.Errorf("Couldn't create user: %v", err)
+		if errors.Is(err, data.ErrDuplicate) {
+			return fmt.Errorf("%w: %s", ErrUserExists, u.Username)
+		}
 		return fmt.Errorf("Couldn't create new user: %v", err)
 	}
 
@@ -67,8 +112,24 @@ func (s *userService) ValidateUser(creds *models.Credentials) (*models.TokenClai
 		log.Errorf("Wrong password for user [username=%s]: %v", creds.Username, err)
 		return nil, fmt.Errorf("Invalid user credentials")
 	}
+	if user.Disabled {
+		log.Errorf("Disabled user tried to sign in [username=%s]", creds.Username)
+		return nil, ErrUserDisabled
+	}
+
+	return provisionedClaims(user), nil
+}
 
-	return &models.TokenClaims{user.Username, user.IsAdmin}, nil
+// tokenClaims returns the claims describing the user and the scopes granted
+// by the role
+func tokenClaims(user *models.User) *models.TokenClaims {
+	role := user.EffectiveRole()
+	return &models.TokenClaims{
+		Username: user.Username,
+		IsAdmin:  role == models.RoleAdmin,
+		Role:     role,
+		Scopes:   models.RoleScopes[role],
+	}
 }
 
 // GetUser calls the database layer to get user by username from the database
@@ -76,9 +137,104 @@ func (s *userService) GetUser(username string) (*models.User, error) {
 	user, err := s.repo.Get(username)
 	if err != nil {
 		log.Errorf("Couldn't get user [username=%s]: %v", username, err)
+		if errors.Is(err, data.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrUserNotFound, username)
+		}
 		return nil, fmt.Errorf("Couldn't get the user from the database: %v", err)
 	}
 
 	log.Infof("Successfully found user [username=%s]", username)
 	return user, nil
 }
+
+// GetClaims returns the current claims of the user, the authentication
+// service checks them on every refresh, so the disabled users and the
+// changed roles don't keep the privileges of the issued tokens
+func (s *userService) GetClaims(username string) (*models.TokenClaims, error) {
+	user, err := s.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		log.Errorf("Disabled user tried to refresh the token [username=%s]", username)
+		return nil, ErrUserDisabled
+	}
+
+	return tokenClaims(user), nil
+}
+
+// ProvisionUser makes sure that the user authenticated by an external identity
+// provider has a local record. New users are created without a password so
+// they can't log in with local credentials. The admin role of the provisioned
+// users follows the provider while the other roles are kept, the role of the
+// local users with a password is changed only through the management API
+func (s *userService) ProvisionUser(req *models.ProvisionRequest) (*models.TokenClaims, error) {
+	validation := validator.New()
+	if err := validation.Struct(req); err != nil {
+		log.Errorf("Couldn't validate provisioned user: %v", err)
+		return nil, fmt.Errorf("Couldn't validate provided user data. Username must be at least 3 characters long and max 32 characters long.")
+	}
+	admin := req.Admin != nil && *req.Admin
+
+	user, err := s.repo.Get(req.Username)
+	if err != nil {
+		if req.ExistingOnly {
+			log.Errorf("Couldn't get provisioned user [username=%s]: %v", req.Username, err)
+			return nil, fmt.Errorf("%w: %s", ErrUserNotFound, req.Username)
+		}
+		role := models.RoleUser
+		if admin {
+			role = models.RoleAdmin
+		}
+		user = &models.User{
+			Username:  req.Username,
+			IsAdmin:   admin,
+			Role:      role,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := s.repo.Create(user); err != nil {
+			log.Errorf("Couldn't provision user [username=%s]: %v", req.Username, err)
+			return nil, fmt.Errorf("Couldn't create new user: %v", err)
+		}
+		log.Infof("Successfully provisioned new user [username=%s]", req.Username)
+		return tokenClaims(user), nil
+	}
+
+	if user.Disabled {
+		log.Errorf("Disabled user tried to sign in with the external provider [username=%s]", req.Username)
+		return nil, ErrUserDisabled
+	}
+	if req.WithoutPassword && user.Password != "" {
+		log.Errorf("Unlinked external identity matches the user with the password [username=%s]", req.Username)
+		return nil, fmt.Errorf("%w: %s", ErrUserHasPassword, req.Username)
+	}
+
+	current := user.EffectiveRole()
+	if user.Password != "" || req.Admin == nil || admin == (current == models.RoleAdmin) {
+		return provisionedClaims(user), nil
+	}
+
+	role := models.RoleUser
+	if admin {
+		role = models.RoleAdmin
+	}
+	user.Role = role
+	user.IsAdmin = admin
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(user); err != nil {
+		log.Errorf("Couldn't update provisioned user [username=%s]: %v", req.Username, err)
+		return nil, fmt.Errorf("Couldn't update the user: %v", err)
+	}
+
+	log.Infof("Successfully updated role of provisioned user [username=%s, role=%s]", req.Username, role)
+	return provisionedClaims(user), nil
+}
+
+// provisionedClaims returns the claims of the existing user, the directory
+// login must be confirmed with the second factor if the user enrolled one
+func provisionedClaims(user *models.User) *models.TokenClaims {
+	claims := tokenClaims(user)
+	claims.MFARequired = user.TOTPEnabled
+	return claims
+}
@@ -1,9 +1,12 @@
 package service_test
 
 import (
+	"errors"
 	"io/ioutil"
 	"testing"
 
+	"github.com/0x113/x-media/user/common"
+	"github.com/0x113/x-media/user/data"
 	"github.com/0x113/x-media/user/mocks"
 	"github.com/0x113/x-media/user/models"
 	"github.com/0x113/x-media/user/service"
@@ -15,20 +18,41 @@ import (
 // UserServiceTestSuite defines test suite for UserService
 type UserServiceTestSuite struct {
 	suite.Suite
-	userRepo    *mocks.MockUserRepository
+	backend     *mocks.Backend
+	repos       *mocks.Repositories
+	userRepo    data.UserRepository
+	passkeyRepo data.PasskeyRepository
+	resetRepo   data.PasswordResetRepository
+	mailer      *mocks.MockMailSender
+	sessions    *mocks.MockSessionRevoker
 	userService service.UserService
 }
 
-// SetupTest initiates mocked database and new user service
+// SetupTest opens the database of the backend and creates new user service
 func (suite *UserServiceTestSuite) SetupTest() {
-	suite.userRepo = mocks.NewMockUserRepository()
-	suite.userService = service.NewUserService(suite.userRepo)
+	common.Config = &common.Configuration{TOTPIssuer: "x-media", PasswordResetURL: "http://localhost:3000/reset-password"}
 	logrus.SetOutput(ioutil.Discard)
+	repos, err := suite.backend.Open()
+	suite.Require().Nil(err)
+	suite.repos = repos
+	suite.userRepo, suite.passkeyRepo, suite.resetRepo = repos.Users, repos.Passkeys, repos.Resets
+	suite.mailer = new(mocks.MockMailSender)
+	suite.sessions = new(mocks.MockSessionRevoker)
+	suite.userService = service.NewUserService(suite.userRepo, suite.passkeyRepo, suite.resetRepo, suite.mailer, suite.sessions)
 }
 
-// TestUserServiceTestSuite runs test suite
+// TearDownTest closes the database of the backend
+func (suite *UserServiceTestSuite) TearDownTest() {
+	suite.repos.Close()
+}
+
+// TestUserServiceTestSuite runs the test suite
 func TestUserServiceTestSuite(t *testing.T) {
-	suite.Run(t, new(UserServiceTestSuite))
+	for _, backend := range mocks.Backends() {
+		t.Run(backend.Name, func(t *testing.T) {
+			suite.Run(t, &UserServiceTestSuite{backend: backend})
+		})
+	}
 }
 
 func (suite *UserServiceTestSuite) TestCreateUser() {
@@ -70,6 +94,15 @@ func (suite *UserServiceTestSuite) TestCreateUser() {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Provided role",
+			user: &models.User{
+				Username: "editorUser",
+				Password: "editorPass",
+				Role:     models.RoleEditor,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range testCases {
@@ -95,7 +128,6 @@ func (suite *UserServiceTestSuite) TestGetUser() {
 			name:     "Success",
 			username: "JohnDoe", // this user exists in a mocked database
 			expectedUser: &models.User{
-				ID:       420,
 				Username: "JohnDoe",
 				Password: "$2a$11$zBkkaUb7woE6Y4oGeqrzYeNlmZ.e/3IbNCfxEYtASk.YHJFYGpfzK",
 				IsAdmin:  false,
@@ -116,7 +148,9 @@ func (suite *UserServiceTestSuite) TestGetUser() {
 			if tt.wantErr {
 				suite.NotNil(err)
 			} else {
-				suite.Nil(err)
+				suite.Require().Nil(err)
+				// the id and the timestamps are assigned by the backend
+				tt.expectedUser.ID, tt.expectedUser.CreatedAt, tt.expectedUser.UpdatedAt = user.ID, user.CreatedAt, user.UpdatedAt
 			}
 
 			suite.Equal(tt.expectedUser, user)
@@ -140,6 +174,36 @@ func (suite *UserServiceTestSuite) TestValidateUser() {
 			expectedClaims: &models.TokenClaims{
 				Username: "JohnDoe",
 				IsAdmin:  false,
+				Role:     models.RoleUser,
+				Scopes:   []string{models.ScopeLibraryRead, models.ScopeStream},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Scanner",
+			creds: &models.Credentials{
+				Username: "scanner",
+				Password: "test1231",
+			},
+			expectedClaims: &models.TokenClaims{
+				Username: "scanner",
+				IsAdmin:  false,
+				Role:     models.RoleScanner,
+				Scopes:   []string{models.ScopeLibraryScan},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Admin without role",
+			creds: &models.Credentials{
+				Username: "admin",
+				Password: "test1231",
+			},
+			expectedClaims: &models.TokenClaims{
+				Username: "admin",
+				IsAdmin:  true,
+				Role:     models.RoleAdmin,
+				Scopes:   models.RoleScopes[models.RoleAdmin],
 			},
 			wantErr: false,
 		},
@@ -163,6 +227,10 @@ func (suite *UserServiceTestSuite) TestValidateUser() {
 		},
 	}
 
+	hash := "$2a$11$zBkkaUb7woE6Y4oGeqrzYeNlmZ.e/3IbNCfxEYtASk.YHJFYGpfzK" // test1231
+	suite.userRepo.Create(&models.User{Username: "scanner", Password: hash, Role: models.RoleScanner})
+	suite.userRepo.Create(&models.User{Username: "admin", Password: hash, IsAdmin: true})
+
 	for _, tt := range testCases {
 		suite.Run(tt.name, func() {
 			claims, err := suite.userService.ValidateUser(tt.creds)
@@ -175,3 +243,117 @@ func (suite *UserServiceTestSuite) TestValidateUser() {
 		})
 	}
 }
+
+func (suite *UserServiceTestSuite) TestGetClaims() {
+	claims, err := suite.userService.GetClaims("JohnDoe")
+	suite.Require().Nil(err)
+	suite.Equal(&models.TokenClaims{
+		Username: "JohnDoe",
+		Role:     models.RoleUser,
+		Scopes:   models.RoleScopes[models.RoleUser],
+	}, claims)
+
+	// the role is read from the database, not from the issued token
+	suite.Require().Nil(suite.userService.SetAdmin("JohnDoe", true))
+	claims, err = suite.userService.GetClaims("JohnDoe")
+	suite.Require().Nil(err)
+	suite.True(claims.IsAdmin)
+
+	suite.Require().Nil(suite.userService.SetDisabled("JohnDoe", true))
+	_, err = suite.userService.GetClaims("JohnDoe")
+	suite.True(errors.Is(err, service.ErrUserDisabled))
+	_, err = suite.userService.GetClaims("nobody")
+	suite.True(errors.Is(err, service.ErrUserNotFound))
+}
+
+func (suite *UserServiceTestSuite) TestProvisionUser() {
+	admin, notAdmin := true, false
+	testCases := []struct {
+		name         string
+		req          *models.ProvisionRequest
+		expectedRole string
+		wantErr      bool
+	}{
+		{
+			name:         "New user",
+			req:          &models.ProvisionRequest{Username: "ldapuser", Admin: &notAdmin},
+			expectedRole: models.RoleUser,
+		},
+		{
+			name:         "New admin",
+			req:          &models.ProvisionRequest{Username: "ldapadmin", Admin: &admin},
+			expectedRole: models.RoleAdmin,
+		},
+		{
+			name:         "Promote provisioned user",
+			req:          &models.ProvisionRequest{Username: "ldapuser", Admin: &admin},
+			expectedRole: models.RoleAdmin,
+		},
+		{
+			name:         "Keep the role without the admin flag",
+			req:          &models.ProvisionRequest{Username: "ldapuser", ExistingOnly: true},
+			expectedRole: models.RoleAdmin,
+		},
+		{
+			name:         "Demote admin removed from the group",
+			req:          &models.ProvisionRequest{Username: "ldapuser", Admin: &notAdmin},
+			expectedRole: models.RoleUser,
+		},
+		{
+			name:         "Keep the role of the local user",
+			req:          &models.ProvisionRequest{Username: "JohnDoe", Admin: &admin},
+			expectedRole: models.RoleUser,
+		},
+		{
+			name:         "Keep the editor role",
+			req:          &models.ProvisionRequest{Username: "editor", Admin: &notAdmin},
+			expectedRole: models.RoleEditor,
+		},
+		{
+			name:         "New user without the admin flag",
+			req:          &models.ProvisionRequest{Username: "ssouser"},
+			expectedRole: models.RoleUser,
+		},
+		{
+			name:    "Invalid username",
+			req:     &models.ProvisionRequest{Username: "x"},
+			wantErr: true,
+		},
+	}
+
+	suite.userRepo.Create(&models.User{Username: "editor", Role: models.RoleEditor})
+
+	for _, tt := range testCases {
+		suite.Run(tt.name, func() {
+			claims, err := suite.userService.ProvisionUser(tt.req)
+			if tt.wantErr {
+				suite.NotNil(err)
+				return
+			}
+			suite.Nil(err)
+			suite.Equal(tt.expectedRole, claims.Role)
+			suite.Equal(models.RoleScopes[tt.expectedRole], claims.Scopes)
+
+			user, err := suite.userRepo.Get(tt.req.Username)
+			suite.Nil(err)
+			suite.Equal(tt.expectedRole, user.EffectiveRole())
+		})
+	}
+
+	// unknown users aren't created when the provider disables it
+	_, err := suite.userService.ProvisionUser(&models.ProvisionRequest{Username: "stranger", ExistingOnly: true})
+	suite.True(errors.Is(err, service.ErrUserNotFound))
+	_, err = suite.userRepo.Get("stranger")
+	suite.NotNil(err)
+
+	// the identity provider can't take over the account with the password
+	_, err = suite.userService.ProvisionUser(&models.ProvisionRequest{Username: "JohnDoe", ExistingOnly: true, WithoutPassword: true})
+	suite.True(errors.Is(err, service.ErrUserHasPassword))
+	claims, err := suite.userService.ProvisionUser(&models.ProvisionRequest{Username: "ldapuser", WithoutPassword: true})
+	suite.Nil(err)
+	suite.Equal("ldapuser", claims.Username)
+
+	// provisioned users can't log in with local credentials
+	_, err = suite.userService.ValidateUser(&models.Credentials{Username: "ldapuser", Password: ""})
+	suite.NotNil(err)
+}
@@ -0,0 +1,369 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/0x113/x-media/user/common"
+	"github.com/0x113/x-media/user/models"
+
+	"github.com/go-playground/validator"
+	log "github.com/sirupsen/logrus"
+	"github.com/skip2/go-qrcode"
+)
+
+// TOTP parameters (RFC 6238) supported by all of the popular authenticator apps
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	// totpSkew defines how many time steps before and after the current one are accepted
+	totpSkew = 1
+
+	recoveryCodeCount = 10
+	defaultTOTPIssuer = "x-media"
+
+	// totpMaxFailures defines after how many invalid codes the user is locked
+	// out, the lockout doubles with each of them up to totpMaxLockout
+	totpMaxFailures = 5
+	totpLockout     = time.Minute
+	totpMaxLockout  = time.Hour
+)
+
+// recoveryEncoding encodes the recovery codes with lowercase letters and digits
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+var (
+	// ErrTOTPInvalid is returned when the code doesn't match the secret of the user
+	ErrTOTPInvalid = errors.New("Invalid authentication code")
+	// ErrTOTPEnabled is returned when the user enrolls again without disabling the current authenticator app
+	ErrTOTPEnabled = errors.New("Two-factor authentication is already enabled")
+	// ErrTOTPDisabled is returned when the user doesn't have the two-factor authentication enabled
+	ErrTOTPDisabled = errors.New("Two-factor authentication isn't enabled")
+)
+
+// TOTPLockedError is returned when the user is locked out after too many invalid codes
+type TOTPLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TOTPLockedError) Error() string {
+	return fmt.Sprintf("Too many invalid authentication codes, try again in %d seconds", e.RetryAfterSeconds())
+}
+
+// RetryAfterSeconds returns the value of the Retry-After header
+func (e *TOTPLockedError) RetryAfterSeconds() int {
+	return int((e.RetryAfter + time.Second - 1) / time.Second)
+}
+
+// EnrollTOTP generates the new secret and recovery codes of the user, the
+// second factor is required only after the enrollment is confirmed with a valid code
+func (s *userService) EnrollTOTP(req *models.TOTPEnrollRequest) (*models.TOTPEnrollment, error) {
+	validation := validator.New()
+	if err := validation.Struct(req); err != nil {
+		return nil, fmt.Errorf("Couldn't validate provided request. Username is required.")
+	}
+	user, err := s.GetUser(req.Username)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTOTPEnabled
+	}
+
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		log.Errorf("Couldn't generate TOTP secret: %v", err)
+		return nil, fmt.Errorf("Couldn't generate the secret")
+	}
+	codes, hashes, err := recoveryCodes()
+	if err != nil {
+		log.Errorf("Couldn't generate recovery codes: %v", err)
+		return nil, fmt.Errorf("Couldn't generate the recovery codes")
+	}
+
+	user.TOTPSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = hashes
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(user); err != nil {
+		log.Errorf("Couldn't save TOTP secret [username=%s]: %v", user.Username, err)
+		return nil, fmt.Errorf("Couldn't update the user: %v", err)
+	}
+
+	uri := totpURI(user.Username, user.TOTPSecret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		log.Errorf("Couldn't generate QR code: %v", err)
+		return nil, fmt.Errorf("Couldn't generate the QR code")
+	}
+
+	log.Infof("Successfully started two-factor authentication enrollment [username=%s]", user.Username)
+	return &models.TOTPEnrollment{
+		Secret:        user.TOTPSecret,
+		URI:           uri,
+		QRCode:        "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// ConfirmTOTP enables the second factor after the user proves that the
+// authenticator app has the secret, the recovery codes aren't accepted here
+func (s *userService) ConfirmTOTP(req *models.TOTPRequest) error {
+	user, err := s.totpUser(req)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabled {
+		return ErrTOTPEnabled
+	}
+	if user.TOTPSecret == "" {
+		return ErrTOTPDisabled
+	}
+	if err := checkTOTPLockout(user, time.Now()); err != nil {
+		return err
+	}
+	step, ok := verifyTOTP(user.TOTPSecret, req.Code, user.TOTPLastStep, time.Now())
+	if !ok {
+		log.Errorf("Invalid TOTP code on confirmation [username=%s]", user.Username)
+		return s.totpFailure(user)
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	user.TOTPFailures = 0
+	user.TOTPLockedUntil = nil
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(user); err != nil {
+		log.Errorf("Couldn't enable TOTP [username=%s]: %v", user.Username, err)
+		return fmt.Errorf("Couldn't update the user: %v", err)
+	}
+
+	log.Infof("Successfully enabled two-factor authentication [username=%s]", user.Username)
+	return nil
+}
+
+// VerifyTOTP checks the second factor of the user who already provided the
+// password, the recovery code is accepted only once
+func (s *userService) VerifyTOTP(req *models.TOTPRequest) (*models.TokenClaims, error) {
+	user, err := s.totpUser(req)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrTOTPDisabled
+	}
+	if err := s.checkSecondFactor(user, req.Code); err != nil {
+		return nil, err
+	}
+
+	return tokenClaims(user), nil
+}
+
+// DisableTOTP removes the secret and recovery codes of the user, the user
+// must provide the current code or one of the recovery codes
+func (s *userService) DisableTOTP(req *models.TOTPRequest) error {
+	user, err := s.totpUser(req)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTOTPDisabled
+	}
+	if err := s.checkSecondFactor(user, req.Code); err != nil {
+		return err
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = nil
+	user.UpdatedAt = time.Now()
+	if err := s.repo.Update(user); err != nil {
+		log.Errorf("Couldn't disable TOTP [username=%s]: %v", user.Username, err)
+		return fmt.Errorf("Couldn't update the user: %v", err)
+	}
+
+	log.Infof("Successfully disabled two-factor authentication [username=%s]", user.Username)
+	return nil
+}
+
+// totpUser validates the request and returns its user
+func (s *userService) totpUser(req *models.TOTPRequest) (*models.User, error) {
+	validation := validator.New()
+	if err := validation.Struct(req); err != nil {
+		return nil, fmt.Errorf("Couldn't validate provided request. Username and code are required.")
+	}
+	return s.GetUser(req.Username)
+}
+
+// checkSecondFactor accepts either the TOTP code or one of the recovery codes
+// and saves the user only if the code wasn't used by the concurrent request,
+// so neither of them can be used again
+func (s *userService) checkSecondFactor(user *models.User, code string) error {
+	if err := checkTOTPLockout(user, time.Now()); err != nil {
+		return err
+	}
+	lastStep, recoveryCodes := user.TOTPLastStep, user.RecoveryCodes
+	if step, ok := verifyTOTP(user.TOTPSecret, code, user.TOTPLastStep, time.Now()); ok {
+		user.TOTPLastStep = step
+	} else if i := findRecoveryCode(user.RecoveryCodes, code); i >= 0 {
+		user.RecoveryCodes = append(user.RecoveryCodes[:i:i], user.RecoveryCodes[i+1:]...)
+		log.Infof("Recovery code used [username=%s, remaining=%d]", user.Username, len(user.RecoveryCodes))
+	} else {
+		log.Errorf("Invalid second factor [username=%s]", user.Username)
+		return s.totpFailure(user)
+	}
+
+	user.TOTPFailures = 0
+	user.TOTPLockedUntil = nil
+	user.UpdatedAt = time.Now()
+	used, err := s.repo.UseSecondFactor(user, lastStep, recoveryCodes)
+	if err != nil {
+		log.Errorf("Couldn't save used second factor [username=%s]: %v", user.Username, err)
+		return fmt.Errorf("Couldn't update the user: %v", err)
+	}
+	if !used {
+		log.Errorf("Second factor has been used by another request [username=%s]", user.Username)
+		return s.totpFailure(user)
+	}
+	return nil
+}
+
+// checkTOTPLockout returns TOTPLockedError if the user is locked out after
+// too many invalid codes
+func checkTOTPLockout(user *models.User, now time.Time) error {
+	if user.TOTPLockedUntil != nil && user.TOTPLockedUntil.After(now) {
+		log.Warnf("Second factor of the locked out user is blocked [username=%s]", user.Username)
+		return &TOTPLockedError{user.TOTPLockedUntil.Sub(now)}
+	}
+	return nil
+}
+
+// totpFailure records the invalid code and locks out the user after every
+// totpMaxFailures of them, the lockout doubles until the code is accepted.
+// The failures are counted by the database, so the concurrent requests can't
+// overwrite each other's failure.
+func (s *userService) totpFailure(user *models.User) error {
+	user.UpdatedAt = time.Now()
+	failures, err := s.repo.AddTOTPFailure(user)
+	if err != nil {
+		log.Errorf("Couldn't save the invalid second factor [username=%s]: %v", user.Username, err)
+		return fmt.Errorf("Couldn't update the user: %v", err)
+	}
+	user.TOTPFailures = failures
+	if failures%totpMaxFailures != 0 {
+		return ErrTOTPInvalid
+	}
+
+	lockout := totpLockout
+	for i := 1; i < failures/totpMaxFailures && lockout < totpMaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > totpMaxLockout {
+		lockout = totpMaxLockout
+	}
+	lockedUntil := time.Now().Add(lockout)
+	if err := s.repo.LockTOTP(user, lockedUntil); err != nil {
+		log.Errorf("Couldn't lock out the second factor [username=%s]: %v", user.Username, err)
+		return fmt.Errorf("Couldn't update the user: %v", err)
+	}
+	user.TOTPLockedUntil = &lockedUntil
+	log.Warnf("Locked out the second factor for %v after %d invalid codes [username=%s]", lockout, failures, user.Username)
+	return ErrTOTPInvalid
+}
+
+// totpURI returns the otpauth URI understood by the authenticator apps
+func totpURI(username, secret string) string {
+	issuer := common.Config.TOTPIssuer
+	if issuer == "" {
+		issuer = defaultTOTPIssuer
+	}
+	params := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(totpPeriod)},
+	}
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(username)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// totpCode computes the code of the time step
+func totpCode(secret []byte, step int64) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// verifyTOTP checks the code against the time steps around now, the steps up
+// to the last accepted one are rejected, so the code can't be replayed
+func verifyTOTP(encodedSecret, code string, lastStep int64, now time.Time) (int64, bool) {
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(encodedSecret)
+	if err != nil || len(secret) == 0 || len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// recoveryCodes generates the recovery codes in the xxxxx-xxxxx format along with their hashes
+func recoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := recoveryEncoding.EncodeToString(b)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes the normalized code, the codes are random enough for the fast hash
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// findRecoveryCode returns the index of the code hash or -1
+func findRecoveryCode(hashes []string, code string) int {
+	hash := hashRecoveryCode(code)
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			return i
+		}
+	}
+	return -1
+}
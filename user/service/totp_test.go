@@ -0,0 +1,168 @@
+package service_test
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/0x113/x-media/user/mocks"
+	"github.com/0x113/x-media/user/models"
+	"github.com/0x113/x-media/user/service"
+)
+
+func (suite *UserServiceTestSuite) TestTOTP() {
+	creds := &models.Credentials{Username: "JohnDoe", Password: "test1231"}
+	totpRequest := func(code string) *models.TOTPRequest {
+		return &models.TOTPRequest{Username: "JohnDoe", Code: code}
+	}
+
+	enrollment, err := suite.userService.EnrollTOTP(&models.TOTPEnrollRequest{Username: "JohnDoe"})
+	suite.Require().Nil(err)
+	suite.True(strings.HasPrefix(enrollment.URI, "otpauth://totp/x-media:JohnDoe?"))
+	suite.Contains(enrollment.URI, "secret="+enrollment.Secret)
+	suite.True(strings.HasPrefix(enrollment.QRCode, "data:image/png;base64,"))
+	suite.Len(enrollment.RecoveryCodes, 10)
+
+	// the second factor isn't required until the enrollment is confirmed
+	claims, err := suite.userService.ValidateUser(creds)
+	suite.Require().Nil(err)
+	suite.False(claims.MFARequired)
+	_, err = suite.userService.VerifyTOTP(totpRequest(mocks.TOTPCode(enrollment.Secret, time.Now())))
+	suite.True(errors.Is(err, service.ErrTOTPDisabled))
+
+	// recovery codes don't confirm the enrollment
+	err = suite.userService.ConfirmTOTP(totpRequest(enrollment.RecoveryCodes[0]))
+	suite.True(errors.Is(err, service.ErrTOTPInvalid))
+	err = suite.userService.ConfirmTOTP(totpRequest("000000x"))
+	suite.True(errors.Is(err, service.ErrTOTPInvalid))
+	code := mocks.TOTPCode(enrollment.Secret, time.Now())
+	suite.Require().Nil(suite.userService.ConfirmTOTP(totpRequest(code)))
+
+	_, err = suite.userService.EnrollTOTP(&models.TOTPEnrollRequest{Username: "JohnDoe"})
+	suite.True(errors.Is(err, service.ErrTOTPEnabled))
+
+	claims, err = suite.userService.ValidateUser(creds)
+	suite.Require().Nil(err)
+	suite.True(claims.MFARequired)
+
+	// the code used for the confirmation can't be replayed, the next one is accepted
+	_, err = suite.userService.VerifyTOTP(totpRequest(code))
+	suite.True(errors.Is(err, service.ErrTOTPInvalid))
+	claims, err = suite.userService.VerifyTOTP(totpRequest(mocks.TOTPCode(enrollment.Secret, time.Now().Add(30*time.Second))))
+	suite.Require().Nil(err)
+	suite.Equal("JohnDoe", claims.Username)
+	suite.False(claims.MFARequired)
+
+	// recovery codes are accepted once, regardless of the case and the dash
+	recoveryCode := strings.ToUpper(strings.Replace(enrollment.RecoveryCodes[1], "-", "", 1))
+	_, err = suite.userService.VerifyTOTP(totpRequest(recoveryCode))
+	suite.Nil(err)
+	_, err = suite.userService.VerifyTOTP(totpRequest(recoveryCode))
+	suite.True(errors.Is(err, service.ErrTOTPInvalid))
+	user, err := suite.userService.GetUser("JohnDoe")
+	suite.Require().Nil(err)
+	suite.Len(user.RecoveryCodes, 9)
+	suite.NotContains(user.RecoveryCodes, enrollment.RecoveryCodes[2])
+
+	_, err = suite.userService.VerifyTOTP(&models.TOTPRequest{Username: "JohnDoe"})
+	suite.NotNil(err)
+
+	// disabling requires the second factor
+	err = suite.userService.DisableTOTP(totpRequest("123456"))
+	suite.True(errors.Is(err, service.ErrTOTPInvalid))
+	suite.Nil(suite.userService.DisableTOTP(totpRequest(enrollment.RecoveryCodes[2])))
+	claims, err = suite.userService.ValidateUser(creds)
+	suite.Require().Nil(err)
+	suite.False(claims.MFARequired)
+	err = suite.userService.DisableTOTP(totpRequest(enrollment.RecoveryCodes[3]))
+	suite.True(errors.Is(err, service.ErrTOTPDisabled))
+}
+
+func (suite *UserServiceTestSuite) TestTOTPLockout() {
+	totpRequest := func(code string) *models.TOTPRequest {
+		return &models.TOTPRequest{Username: "JohnDoe", Code: code}
+	}
+	enrollment, err := suite.userService.EnrollTOTP(&models.TOTPEnrollRequest{Username: "JohnDoe"})
+	suite.Require().Nil(err)
+
+	// the invalid codes of the confirmation count as well
+	for i := 0; i < 4; i++ {
+		err = suite.userService.ConfirmTOTP(totpRequest("000000"))
+		suite.True(errors.Is(err, service.ErrTOTPInvalid))
+	}
+	suite.Require().Nil(suite.userService.ConfirmTOTP(totpRequest(mocks.TOTPCode(enrollment.Secret, time.Now()))))
+
+	// the accepted code resets the failures, the fifth invalid code in a row locks out the user
+	for i := 0; i < 5; i++ {
+		_, err = suite.userService.VerifyTOTP(totpRequest("000000"))
+		suite.True(errors.Is(err, service.ErrTOTPInvalid))
+	}
+	_, err = suite.userService.VerifyTOTP(totpRequest(enrollment.RecoveryCodes[0]))
+	var lockedErr *service.TOTPLockedError
+	suite.Require().True(errors.As(err, &lockedErr))
+	suite.Equal(60, lockedErr.RetryAfterSeconds())
+	err = suite.userService.DisableTOTP(totpRequest(enrollment.RecoveryCodes[0]))
+	suite.True(errors.As(err, &lockedErr))
+
+	// the next lockout is twice as long
+	user, err := suite.userService.GetUser("JohnDoe")
+	suite.Require().Nil(err)
+	suite.Equal(5, user.TOTPFailures)
+	expired := time.Now().Add(-time.Second)
+	user.TOTPLockedUntil = &expired
+	suite.Require().Nil(suite.userRepo.Update(user))
+	for i := 0; i < 5; i++ {
+		_, err = suite.userService.VerifyTOTP(totpRequest("000000"))
+		suite.True(errors.Is(err, service.ErrTOTPInvalid))
+	}
+	_, err = suite.userService.VerifyTOTP(totpRequest(enrollment.RecoveryCodes[0]))
+	suite.Require().True(errors.As(err, &lockedErr))
+	suite.Equal(120, lockedErr.RetryAfterSeconds())
+
+	// the recovery code is accepted after the lockout and resets the failures
+	user, err = suite.userService.GetUser("JohnDoe")
+	suite.Require().Nil(err)
+	user.TOTPLockedUntil = &expired
+	suite.Require().Nil(suite.userRepo.Update(user))
+	_, err = suite.userService.VerifyTOTP(totpRequest(enrollment.RecoveryCodes[0]))
+	suite.Nil(err)
+	user, err = suite.userService.GetUser("JohnDoe")
+	suite.Require().Nil(err)
+	suite.Zero(user.TOTPFailures)
+	suite.Nil(user.TOTPLockedUntil)
+}
+
+func (suite *UserServiceTestSuite) TestTOTPConcurrentRequests() {
+	totpRequest := func(code string) *models.TOTPRequest {
+		return &models.TOTPRequest{Username: "JohnDoe", Code: code}
+	}
+	enrollment, err := suite.userService.EnrollTOTP(&models.TOTPEnrollRequest{Username: "JohnDoe"})
+	suite.Require().Nil(err)
+	suite.Require().Nil(suite.userService.ConfirmTOTP(totpRequest(mocks.TOTPCode(enrollment.Secret, time.Now()))))
+
+	// both requests read the user before either of them saved the used code
+	first, err := suite.userRepo.Get("JohnDoe")
+	suite.Require().Nil(err)
+	second, err := suite.userRepo.Get("JohnDoe")
+	suite.Require().Nil(err)
+	lastStep, recoveryCodes := first.TOTPLastStep, first.RecoveryCodes
+	first.RecoveryCodes = first.RecoveryCodes[1:]
+	second.RecoveryCodes = second.RecoveryCodes[1:]
+	used, err := suite.userRepo.UseSecondFactor(first, lastStep, recoveryCodes)
+	suite.Require().Nil(err)
+	suite.True(used)
+	used, err = suite.userRepo.UseSecondFactor(second, lastStep, recoveryCodes)
+	suite.Require().Nil(err)
+	suite.False(used)
+
+	// the failures are counted by the repository, the stale user doesn't reset them
+	for i := 1; i <= 3; i++ {
+		failures, err := suite.userRepo.AddTOTPFailure(second)
+		suite.Require().Nil(err)
+		suite.Equal(i, failures)
+	}
+	user, err := suite.userService.GetUser("JohnDoe")
+	suite.Require().Nil(err)
+	suite.Equal(3, user.TOTPFailures)
+	suite.Len(user.RecoveryCodes, 9)
+}